@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// QuorumAlert notifies an event's organizer the first time any of its
+// slots reaches ThresholdPercentage availability. Alerts fire once.
+type QuorumAlert struct {
+	ID                  string     `json:"id"`
+	EventID             string     `json:"eventId"`
+	ThresholdPercentage float64    `json:"thresholdPercentage"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	FiredAt             *time.Time `json:"firedAt,omitempty"`
+	// FiredSlotID is the slot that met the threshold
+	FiredSlotID string `json:"firedSlotId,omitempty"`
+}
+
+type CreateQuorumAlertRequest struct {
+	ThresholdPercentage float64 `json:"thresholdPercentage" binding:"required"`
+}
+
+// alertRegistry is the in-memory quorum alert store
+type alertRegistry struct {
+	mu     sync.RWMutex
+	alerts map[string]QuorumAlert
+}
+
+func newAlertRegistry() *alertRegistry {
+	return &alertRegistry{alerts: make(map[string]QuorumAlert)}
+}
+
+func (r *alertRegistry) Save(alert QuorumAlert) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts[alert.ID] = alert
+}
+
+// Delete removes an alert if it belongs to the event
+func (r *alertRegistry) Delete(eventID, id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	alert, ok := r.alerts[id]
+	if !ok || alert.EventID != eventID {
+		return false
+	}
+	delete(r.alerts, id)
+	return true
+}
+
+// ForEvent returns an event's alerts, oldest first
+func (r *alertRegistry) ForEvent(eventID string) []QuorumAlert {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	alertList := []QuorumAlert{}
+	for _, alert := range r.alerts {
+		if alert.EventID == eventID {
+			alertList = append(alertList, alert)
+		}
+	}
+	sort.Slice(alertList, func(i, j int) bool { return alertList[i].CreatedAt.Before(alertList[j].CreatedAt) })
+	return alertList
+}
+
+// Pending returns the alerts that haven't fired, grouped by event
+func (r *alertRegistry) Pending() map[string][]QuorumAlert {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	pending := map[string][]QuorumAlert{}
+	for _, alert := range r.alerts {
+		if alert.FiredAt == nil {
+			pending[alert.EventID] = append(pending[alert.EventID], alert)
+		}
+	}
+	return pending
+}
+
+// Forget drops a deleted event's alerts
+func (r *alertRegistry) Forget(eventID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, alert := range r.alerts {
+		if alert.EventID == eventID {
+			delete(r.alerts, id)
+		}
+	}
+}
+
+var quorumAlerts = newAlertRegistry()
+
+// evaluateQuorumAlerts is the background job checking pending alerts
+func evaluateQuorumAlerts(now time.Time) {
+	currentScheduler().evaluatePendingAlerts(now)
+}
+
+func (s *Scheduler) evaluatePendingAlerts(now time.Time) {
+	for eventID, pending := range quorumAlerts.Pending() {
+		if err := s.evaluateAlerts(eventID, pending, now); err != nil {
+			log.Printf("Quorum alerts for %s failed, will retry: %v", eventID, err)
+		}
+	}
+}
+
+// evaluateAlerts fires the event's pending alerts whose threshold its best
+// slot meets. Alerts on events no longer polling are left pending.
+func (s *Scheduler) evaluateAlerts(eventID string, pending []QuorumAlert, now time.Time) error {
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return err
+	}
+	if event.Status != "active" {
+		return nil
+	}
+	recommendations, err := s.Recommendations(eventID)
+	if err != nil {
+		return err
+	}
+
+	var best *Recommendation
+	for i := range recommendations {
+		if best == nil || recommendations[i].AvailabilityPercentage > best.AvailabilityPercentage {
+			best = &recommendations[i]
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	for _, alert := range pending {
+		if best.AvailabilityPercentage < alert.ThresholdPercentage {
+			continue
+		}
+		firedAt := now
+		alert.FiredAt = &firedAt
+		alert.FiredSlotID = best.TimeSlot.ID
+		quorumAlerts.Save(alert)
+		notifyQuorumReached(event, alert, *best)
+	}
+	return nil
+}
+
+func notifyQuorumReached(event Event, alert QuorumAlert, rec Recommendation) {
+	organizer, ok := users.Get(event.OrganizerID)
+	if !ok {
+		log.Printf("Quorum alert %s fired for %s but its organizer is unknown", alert.ID, event.ID)
+		return
+	}
+	msg := Message{
+		To:      organizer,
+		OrgID:   event.OrgID,
+		EventID: event.ID,
+		Subject: "Quorum reached: " + event.Title,
+		Body: fmt.Sprintf("%.0f%% of participants are available for %s on %s, meeting your %.0f%% alert.",
+			rec.AvailabilityPercentage, event.Title, rec.TimeSlot.StartTime.UTC().Format("Mon Jan 2 2006, 15:04 MST"), alert.ThresholdPercentage),
+	}
+	if err := notifier.Notify(msg); err != nil {
+		log.Printf("Failed to notify %s about %s: %v", organizer.ID, event.ID, err)
+	}
+}
+
+// Quorum alert handlers
+func listQuorumAlerts(c *gin.Context) {
+	eventID := c.Param("eventId")
+	if _, err := contextScheduler(c).GetEvent(eventID); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, quorumAlerts.ForEvent(eventID))
+}
+
+func createQuorumAlert(c *gin.Context) {
+	eventID := c.Param("eventId")
+	if _, err := contextScheduler(c).GetEvent(eventID); err != nil {
+		respondError(c, err)
+		return
+	}
+	var req CreateQuorumAlertRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ThresholdPercentage <= 0 || req.ThresholdPercentage > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Threshold must be above 0 and at most 100"})
+		return
+	}
+
+	alert := QuorumAlert{
+		ID:                  uuid.New().String(),
+		EventID:             eventID,
+		ThresholdPercentage: req.ThresholdPercentage,
+		CreatedAt:           clock.Now(),
+	}
+	quorumAlerts.Save(alert)
+	c.JSON(http.StatusCreated, alert)
+}
+
+func deleteQuorumAlert(c *gin.Context) {
+	if !quorumAlerts.Delete(c.Param("eventId"), c.Param("alertId")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetAlerts(t *testing.T) {
+	previous := quorumAlerts
+	quorumAlerts = newAlertRegistry()
+	t.Cleanup(func() { quorumAlerts = previous })
+}
+
+func TestQuorumAlertFiresOnce(t *testing.T) {
+	resetDirectory(t)
+	resetAlerts(t)
+	scheduler, fake := newTestScheduler(t)
+	recorder := &recordingNotifier{}
+	previous := notifier
+	notifier = recorder
+	t.Cleanup(func() { notifier = previous })
+	users.Save(User{ID: "ada", OrgID: "acme", Email: "ada@acme.test"})
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30, Invitees: []string{"bob", "cy"}})
+	require.NoError(t, err)
+	start := fake.Now().Add(24 * time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	quorumAlerts.Save(QuorumAlert{ID: "a1", EventID: event.ID, ThresholdPercentage: 80, CreatedAt: fake.Now()})
+
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+	scheduler.evaluatePendingAlerts(fake.Now())
+	assert.Empty(t, recorder.messages, "50% is below the threshold")
+
+	_, err = scheduler.SubmitAvailability(event.ID, "cy", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+	scheduler.evaluatePendingAlerts(fake.Now())
+	scheduler.evaluatePendingAlerts(fake.Now())
+	require.Len(t, recorder.messages, 1)
+	assert.Equal(t, "Quorum reached: Kickoff", recorder.messages[0].Subject)
+	assert.Equal(t, "ada", recorder.messages[0].To.ID)
+
+	alerts := quorumAlerts.ForEvent(event.ID)
+	require.Len(t, alerts, 1)
+	assert.NotNil(t, alerts[0].FiredAt)
+	assert.Equal(t, slot.ID, alerts[0].FiredSlotID)
+
+	require.NoError(t, scheduler.DeleteEvent(event.ID))
+	assert.Empty(t, quorumAlerts.ForEvent(event.ID))
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// analyticsCounter tallies domain events by type as a lightweight usage
+// metric for operators
+type analyticsCounter struct {
+	mu     sync.Mutex
+	counts map[DomainEventType]int
+}
+
+func newAnalyticsCounter() *analyticsCounter {
+	return &analyticsCounter{counts: make(map[DomainEventType]int)}
+}
+
+// Record is a DomainEventHandler
+func (a *analyticsCounter) Record(event DomainEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.counts[event.Type]++
+}
+
+// Snapshot returns a copy of the current counts
+func (a *analyticsCounter) Snapshot() map[DomainEventType]int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make(map[DomainEventType]int, len(a.counts))
+	for eventType, count := range a.counts {
+		out[eventType] = count
+	}
+	return out
+}
+
+var analytics = newAnalyticsCounter()
+
+func getAnalytics(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"counts": analytics.Snapshot()})
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// sessionClaims are carried in the bearer tokens issued after login
+type sessionClaims struct {
+	OrgID string `json:"org,omitempty"`
+	Role  string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// signingKeys holds the key session tokens are signed with. When the key
+// is rotated the previous one still verifies tokens until they expire.
+type signingKeys struct {
+	mu       sync.RWMutex
+	current  []byte
+	previous []byte
+}
+
+func (k *signingKeys) Current() []byte {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+// Verification returns the keys tokens may be signed with, newest first
+func (k *signingKeys) Verification() jwt.VerificationKeySet {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	set := jwt.VerificationKeySet{Keys: []jwt.VerificationKey{k.current}}
+	if k.previous != nil {
+		set.Keys = append(set.Keys, k.previous)
+	}
+	return set
+}
+
+func (k *signingKeys) Rotate(key []byte) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.previous, k.current = k.current, key
+}
+
+// jwtKeys signs session tokens. The key is the JWT_SIGNING_KEY secret;
+// without one a random key is generated, so sessions don't survive a
+// restart.
+var jwtKeys = loadSigningKeys()
+
+func loadSigningKeys() *signingKeys {
+	keys := &signingKeys{}
+	secrets.OnRotate(func(name, value string) {
+		if name == "JWT_SIGNING_KEY" && value != "" {
+			keys.Rotate([]byte(value))
+		}
+	})
+	if key := secrets.Get("JWT_SIGNING_KEY"); key != "" {
+		keys.current = []byte(key)
+		return keys
+	}
+	log.Printf("JWT_SIGNING_KEY not set, using an ephemeral signing key")
+	keys.current = make([]byte, 32)
+	if _, err := rand.Read(keys.current); err != nil {
+		log.Fatalf("Failed to generate signing key: %v", err)
+	}
+	return keys
+}
+
+// randomToken returns an unguessable URL-safe token
+func randomToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// signAccessToken returns a signed bearer token for the user, good until
+// expiresAt while the session lasts
+func signAccessToken(user User, sessionID string, now, expiresAt time.Time) (string, error) {
+	claims := sessionClaims{
+		OrgID: user.OrgID,
+		Role:  user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID,
+			Subject:   user.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtKeys.Current())
+}
+
+func parseSessionToken(token string) (*sessionClaims, error) {
+	claims := &sessionClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return jwtKeys.Verification(), nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithTimeFunc(clock.Now))
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+const (
+	currentUserKey    = "currentUser"
+	currentSessionKey = "currentSession"
+)
+
+// authenticate resolves a bearer token into the current user, or failing
+// that a client certificate into a service. Requests with neither continue
+// anonymously; the route policy decides who may call what.
+func authenticate(c *gin.Context) {
+	header := c.GetHeader("Authorization")
+	if header == "" {
+		if authenticateService(c) {
+			c.Next()
+		}
+		return
+	}
+
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Malformed Authorization header"})
+		return
+	}
+
+	claims, err := parseSessionToken(token)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+
+	session, err := sessions.Active(claims.ID, clock.Now())
+	if errors.Is(err, ErrNotFound) || err == nil && session.UserID != claims.Subject {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session has ended"})
+		return
+	}
+	if err != nil {
+		log.Printf("Checking session %s: %v", claims.ID, err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Sessions can't be checked right now"})
+		return
+	}
+
+	user, ok := users.Get(claims.Subject)
+	if !ok || user.Deactivated {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unknown user"})
+		return
+	}
+
+	c.Set(currentUserKey, user)
+	c.Set(currentSessionKey, session.ID)
+	if session.ImpersonatorID != "" {
+		c.Request = c.Request.WithContext(withImpersonator(c.Request.Context(), session.ImpersonatorID))
+		c.Header(impersonatedByHeader, session.ImpersonatorID)
+	}
+	c.Next()
+}
+
+// currentUser returns the authenticated user, if any
+func currentUser(c *gin.Context) (User, bool) {
+	value, ok := c.Get(currentUserKey)
+	if !ok {
+		return User{}, false
+	}
+	user, ok := value.(User)
+	return user, ok
+}
+
+func getMe(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func updateMySettings(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var req UserSettings
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.MinNoticeMinutes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Minimum notice cannot be negative"})
+		return
+	}
+	if req.MaxMeetingsPerDay < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Max meetings per day cannot be negative"})
+		return
+	}
+	if !validResponseUpdates(req.ResponseUpdates) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Response updates must be immediate, hourly or daily"})
+		return
+	}
+	if req.Shift != nil {
+		if err := req.Shift.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.QuietHours != nil {
+		if err := req.QuietHours.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.EventDefaults != nil {
+		if err := req.EventDefaults.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	user.Settings = req
+	user.UpdatedAt = clock.Now()
+	users.Save(user)
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBatchOperations bounds a single batch request
+const maxBatchOperations = 100
+
+// atomicOperations are the routes an atomic batch may contain: the event,
+// time slot and availability writes that run against the batch's
+// transaction. Deletes are left out because they move records to the trash,
+// which a rollback wouldn't undo.
+var atomicOperations = []*regexp.Regexp{
+	regexp.MustCompile(`^POST /api/v1/events$`),
+	regexp.MustCompile(`^PUT /api/v1/events/[^/]+$`),
+	regexp.MustCompile(`^POST /api/v1/events/[^/]+/finalize$`),
+	regexp.MustCompile(`^POST /api/v1/events/[^/]+/timeslots$`),
+	regexp.MustCompile(`^PUT /api/v1/events/[^/]+/timeslots/[^/]+$`),
+	regexp.MustCompile(`^POST /api/v1/events/[^/]+/users/[^/]+/availability$`),
+	regexp.MustCompile(`^PUT /api/v1/events/[^/]+/users/[^/]+/availability/[^/]+$`),
+}
+
+// batchReference matches {{name.field}} placeholders for a field of an
+// earlier operation's response
+var batchReference = regexp.MustCompile(`\{\{([A-Za-z0-9_-]+)\.([A-Za-z0-9_]+)\}\}`)
+
+// errBatchFailed rolls back an atomic batch after an operation fails
+var errBatchFailed = errors.New("batch operation failed")
+
+// BatchOperation is one API call in a batch. Path and Body may refer to an
+// earlier operation's response with {{id.field}}, e.g. the event a batch
+// just created: "/api/v1/events/{{event.id}}/timeslots".
+type BatchOperation struct {
+	ID     string          `json:"id,omitempty"`
+	Method string          `json:"method" binding:"required"`
+	Path   string          `json:"path" binding:"required"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations" binding:"required"`
+	// Atomic runs the operations in one transaction: either all of them
+	// take effect or none do
+	Atomic bool `json:"atomic"`
+}
+
+// BatchResult is an operation's response. Operations after a failure
+// aren't run and report 424 Failed Dependency.
+type BatchResult struct {
+	ID     string          `json:"id,omitempty"`
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+	// RolledBack is set when an atomic batch failed and nothing was kept
+	RolledBack bool `json:"rolledBack,omitempty"`
+}
+
+// batchTx is the transaction an atomic batch's operations run in. Their
+// domain events collect on bus and are published once it commits.
+type batchTx struct {
+	store Store
+	bus   *EventBus
+}
+
+type batchTxKey struct{}
+
+func validateBatch(req BatchRequest) error {
+	if len(req.Operations) == 0 {
+		return invalid("A batch needs at least one operation")
+	}
+	if len(req.Operations) > maxBatchOperations {
+		return invalid(fmt.Sprintf("A batch can have at most %d operations", maxBatchOperations))
+	}
+	seen := map[string]bool{}
+	for i, op := range req.Operations {
+		if !strings.HasPrefix(op.Path, "/api/v1/") || strings.HasPrefix(op.Path, "/api/v1/batch") {
+			return invalid(fmt.Sprintf("Operation %d must call an API path other than the batch endpoint", i))
+		}
+		if op.ID != "" {
+			if seen[op.ID] {
+				return invalid(fmt.Sprintf("Operation ID %q is used twice", op.ID))
+			}
+			seen[op.ID] = true
+		}
+		if req.Atomic && !atomicOperation(op) {
+			return invalid(fmt.Sprintf("%s %s can't run in an atomic batch", op.Method, op.Path))
+		}
+	}
+	return nil
+}
+
+// atomicOperation checks the operation's route, ignoring references that
+// will fill in IDs
+func atomicOperation(op BatchOperation) bool {
+	path, _, _ := strings.Cut(op.Path, "?")
+	route := strings.ToUpper(op.Method) + " " + batchReference.ReplaceAllString(path, "ref")
+	for _, pattern := range atomicOperations {
+		if pattern.MatchString(route) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveReferences fills the {{id.field}} placeholders in s from earlier
+// responses
+func resolveReferences(s string, responses map[string]map[string]interface{}) (string, error) {
+	var missing error
+	resolved := batchReference.ReplaceAllStringFunc(s, func(ref string) string {
+		parts := batchReference.FindStringSubmatch(ref)
+		value, ok := responses[parts[1]][parts[2]]
+		if !ok {
+			missing = invalid(fmt.Sprintf("Reference %s doesn't match an earlier response", ref))
+			return ref
+		}
+		return fmt.Sprint(value)
+	})
+	return resolved, missing
+}
+
+// runBatch dispatches the operations through the router in order, with the
+// credentials and address of the outer request, stopping at the first one
+// that fails. It reports whether all of them succeeded.
+func runBatch(ctx context.Context, router *gin.Engine, outer *http.Request, ops []BatchOperation) ([]BatchResult, bool) {
+	results := make([]BatchResult, 0, len(ops))
+	responses := map[string]map[string]interface{}{}
+	failed := false
+	for _, op := range ops {
+		result := BatchResult{ID: op.ID, Status: http.StatusFailedDependency}
+		if failed {
+			results = append(results, result)
+			continue
+		}
+
+		path, err := resolveReferences(op.Path, responses)
+		var body string
+		if err == nil {
+			body, err = resolveReferences(string(op.Body), responses)
+		}
+		if err != nil {
+			result.Status = http.StatusBadRequest
+			result.Body, _ = json.Marshal(gin.H{"error": err.Error()})
+			results = append(results, result)
+			failed = true
+			continue
+		}
+
+		req, err := http.NewRequestWithContext(ctx, strings.ToUpper(op.Method), path, strings.NewReader(body))
+		if err != nil {
+			result.Status = http.StatusBadRequest
+			result.Body, _ = json.Marshal(gin.H{"error": err.Error()})
+			results = append(results, result)
+			failed = true
+			continue
+		}
+		// Throttling and access logs key on the client's address
+		req.RemoteAddr, req.TLS = outer.RemoteAddr, outer.TLS
+		req.Header = outer.Header.Clone()
+		req.Header.Del("Content-Length")
+		req.Header.Del("Accept-Encoding")
+		req.Header.Del("Accept")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		result.Status = w.Code
+		if response := bytes.TrimSpace(w.Body.Bytes()); len(response) > 0 && json.Valid(response) {
+			result.Body = response
+		}
+		results = append(results, result)
+		if w.Code >= 400 {
+			failed = true
+			continue
+		}
+		if op.ID != "" {
+			fields := map[string]interface{}{}
+			json.Unmarshal(result.Body, &fields)
+			responses[op.ID] = fields
+		}
+	}
+	return results, !failed
+}
+
+// batchHandler runs a batch of API calls against router. Atomic batches
+// hold the store's transaction for their duration.
+func batchHandler(router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req BatchRequest
+		if err := bindJSON(c, &req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateBatch(req); err != nil {
+			respondError(c, err)
+			return
+		}
+
+		if !req.Atomic {
+			results, _ := runBatch(c.Request.Context(), router, c.Request, req.Operations)
+			c.JSON(http.StatusOK, BatchResponse{Results: results})
+			return
+		}
+
+		var queued []DomainEvent
+		pending := newEventBus()
+		pending.SubscribeAll(func(e DomainEvent) { queued = append(queued, e) })
+		var results []BatchResult
+		err := store.WithTransaction(func(tx Store) error {
+			ctx := context.WithValue(c.Request.Context(), batchTxKey{}, batchTx{store: tx, bus: pending})
+			var ok bool
+			if results, ok = runBatch(ctx, router, c.Request, req.Operations); !ok {
+				return errBatchFailed
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errBatchFailed) {
+			respondError(c, err)
+			return
+		}
+		if err == nil {
+			for _, e := range queued {
+				bus.Publish(e)
+			}
+		}
+		c.JSON(http.StatusOK, BatchResponse{Results: results, RolledBack: err != nil})
+	}
+}
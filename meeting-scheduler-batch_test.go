@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func batchSlots(n int) []BatchOperation {
+	start := time.Date(2025, 2, 3, 9, 0, 0, 0, time.UTC)
+	ops := []BatchOperation{{ID: "event", Method: "POST", Path: "/api/v1/events",
+		Body: json.RawMessage(`{"title":"Offsite","organizerId":"ada","requiredDuration":60}`)}}
+	for i := 0; i < n; i++ {
+		slotStart := start.Add(time.Duration(i) * time.Hour)
+		body, _ := json.Marshal(CreateTimeSlotRequest{StartTime: slotStart, EndTime: slotStart.Add(time.Hour)})
+		ops = append(ops, BatchOperation{Method: "POST", Path: "/api/v1/events/{{event.id}}/timeslots", Body: body})
+	}
+	return ops
+}
+
+func TestBatchRunsOperationsInOrderWithReferences(t *testing.T) {
+	router := newTestRouter(t)
+
+	w := doJSON(router, "POST", "/api/v1/batch", BatchRequest{Operations: batchSlots(30)})
+	require.Equal(t, http.StatusOK, w.Code)
+	var response BatchResponse
+	decodeJSON(t, w, &response)
+	require.Len(t, response.Results, 31)
+	for _, result := range response.Results {
+		assert.Equal(t, http.StatusCreated, result.Status)
+	}
+	var event Event
+	require.NoError(t, json.Unmarshal(response.Results[0].Body, &event))
+	slots, err := store.ListTimeSlots(event.ID)
+	require.NoError(t, err)
+	assert.Len(t, slots, 30)
+}
+
+func TestAtomicBatchRollsBackOnFailure(t *testing.T) {
+	router := newTestRouter(t)
+	ops := batchSlots(2)
+	ops = append(ops, BatchOperation{Method: "POST", Path: "/api/v1/events/{{event.id}}/timeslots",
+		Body: json.RawMessage(`{"startTime":"2025-02-03T10:00:00Z","endTime":"2025-02-03T09:00:00Z"}`)})
+	ops = append(ops, batchSlots(1)[1])
+
+	w := doJSON(router, "POST", "/api/v1/batch", BatchRequest{Operations: ops, Atomic: true})
+	require.Equal(t, http.StatusOK, w.Code)
+	var response BatchResponse
+	decodeJSON(t, w, &response)
+	assert.True(t, response.RolledBack)
+	require.Len(t, response.Results, 5)
+	assert.Equal(t, http.StatusBadRequest, response.Results[3].Status)
+	assert.Equal(t, http.StatusFailedDependency, response.Results[4].Status)
+	var rolledBack Event
+	require.NoError(t, json.Unmarshal(response.Results[0].Body, &rolledBack))
+	_, err := store.GetEvent(rolledBack.ID)
+	assert.ErrorIs(t, err, ErrNotFound, "nothing is kept")
+	slots, err := store.ListTimeSlots(rolledBack.ID)
+	require.NoError(t, err)
+	assert.Empty(t, slots)
+
+	w = doJSON(router, "POST", "/api/v1/batch", BatchRequest{Operations: batchSlots(2), Atomic: true})
+	var committed BatchResponse
+	decodeJSON(t, w, &committed)
+	assert.False(t, committed.RolledBack)
+	assert.Len(t, events, 1)
+	assert.Len(t, timeSlots, 2)
+
+	w = doJSON(router, "POST", "/api/v1/batch", BatchRequest{Atomic: true,
+		Operations: []BatchOperation{{Method: "DELETE", Path: "/api/v1/events/abc"}}})
+	assert.Equal(t, http.StatusBadRequest, w.Code, "deletes can't be rolled back")
+}
+
+func TestBatchedCallsAreThrottledPerClient(t *testing.T) {
+	t.Setenv("THROTTLE_MAX_FAILURES", "3")
+	useFakeClock(t, time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	router := newTestRouter(t)
+
+	// redeem looks up an invite token through /batch from addr
+	redeem := func(addr, token string) int {
+		body, err := json.Marshal(BatchRequest{Operations: []BatchOperation{{Method: "GET", Path: "/api/v1/invites/" + token}}})
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/batch", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response BatchResponse
+		decodeJSON(t, w, &response)
+		require.Len(t, response.Results, 1)
+		return response.Results[0].Status
+	}
+
+	for _, token := range []string{"guess-1", "guess-2", "guess-3"} {
+		require.Equal(t, http.StatusNotFound, redeem("203.0.113.7:40000", token))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, redeem("203.0.113.7:40001", "guess-4"))
+	assert.Equal(t, http.StatusNotFound, redeem("198.51.100.2:40000", "guess-4"), "other clients aren't locked out")
+}
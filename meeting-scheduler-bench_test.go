@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Benchmark workload size: every participant answers every slot, the
+// worst case for the recommendation engine's participants × slots ×
+// responses hot path
+const (
+	benchParticipants = 100
+	benchSlots        = 200
+)
+
+// syntheticWorkload stores an event with the given number of hour-long
+// slots, each answered by every participant, about 60% of them available.
+// The responses are the same on every run.
+func syntheticWorkload(tb testing.TB, participants, slots int) (*Scheduler, string) {
+	scheduler, fake := newTestScheduler(tb)
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Benchmark", OrganizerID: "organizer", RequiredDuration: 60})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	random := rand.New(rand.NewSource(1))
+	start := fake.Now().Add(24 * time.Hour)
+	for i := 0; i < slots; i++ {
+		slot := TimeSlot{
+			ID:        fmt.Sprintf("slot-%03d", i),
+			EventID:   event.ID,
+			StartTime: start.Add(time.Duration(i) * time.Hour),
+			EndTime:   start.Add(time.Duration(i+1) * time.Hour),
+		}
+		if err := store.CreateTimeSlot(slot); err != nil {
+			tb.Fatal(err)
+		}
+		for u := 0; u < participants; u++ {
+			status := "unavailable"
+			if random.Intn(10) < 6 {
+				status = "available"
+			}
+			avail := UserAvailability{
+				ID:         fmt.Sprintf("avail-%03d-%03d", i, u),
+				UserID:     fmt.Sprintf("user-%03d", u),
+				EventID:    event.ID,
+				TimeSlotID: slot.ID,
+				Status:     status,
+			}
+			if err := store.CreateAvailability(avail); err != nil {
+				tb.Fatal(err)
+			}
+		}
+	}
+	return scheduler, event.ID
+}
+
+func BenchmarkRecommendations(b *testing.B) {
+	scheduler, eventID := syntheticWorkload(b, benchParticipants, benchSlots)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := scheduler.Recommendations(eventID); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The overlap matrix is the event's participant × participant heatmap
+func BenchmarkOverlap(b *testing.B) {
+	scheduler, eventID := syntheticWorkload(b, benchParticipants, benchSlots)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := scheduler.Overlap(eventID); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkEndpoint measures a GET through the router, encoding included
+func benchmarkEndpoint(b *testing.B, path func(eventID string) string) {
+	router := newTestRouter(b)
+	_, eventID := syntheticWorkload(b, benchParticipants, benchSlots)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(http.MethodGet, path(eventID), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			b.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+	}
+}
+
+func BenchmarkRecommendationsEndpoint(b *testing.B) {
+	benchmarkEndpoint(b, func(eventID string) string { return "/api/v1/events/" + eventID + "/recommendations" })
+}
+
+func BenchmarkOverlapEndpoint(b *testing.B) {
+	benchmarkEndpoint(b, func(eventID string) string { return "/api/v1/events/" + eventID + "/overlap" })
+}
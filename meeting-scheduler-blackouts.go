@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Blackout is an organization-wide period (code freeze, all-hands day) in
+// which no meetings should be scheduled
+type Blackout struct {
+	ID        string    `json:"id"`
+	OrgID     string    `json:"orgId"`
+	Name      string    `json:"name"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type CreateBlackoutRequest struct {
+	Name      string    `json:"name" binding:"required"`
+	StartTime time.Time `json:"startTime" binding:"required"`
+	EndTime   time.Time `json:"endTime" binding:"required"`
+}
+
+// blackoutRegistry is the in-memory blackout store
+type blackoutRegistry struct {
+	mu        sync.RWMutex
+	blackouts map[string]Blackout
+}
+
+func newBlackoutRegistry() *blackoutRegistry {
+	return &blackoutRegistry{blackouts: make(map[string]Blackout)}
+}
+
+func (r *blackoutRegistry) Save(blackout Blackout) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.blackouts[blackout.ID] = blackout
+}
+
+func (r *blackoutRegistry) Delete(orgID, id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	blackout, ok := r.blackouts[id]
+	if !ok || blackout.OrgID != orgID {
+		return false
+	}
+	delete(r.blackouts, id)
+	return true
+}
+
+// List returns the organization's blackouts in start order
+func (r *blackoutRegistry) List(orgID string) []Blackout {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	blackoutList := []Blackout{}
+	for _, blackout := range r.blackouts {
+		if blackout.OrgID == orgID {
+			blackoutList = append(blackoutList, blackout)
+		}
+	}
+	sort.Slice(blackoutList, func(i, j int) bool {
+		return blackoutList[i].StartTime.Before(blackoutList[j].StartTime)
+	})
+	return blackoutList
+}
+
+// Overlapping returns the organization's blackouts intersecting [start, end)
+func (r *blackoutRegistry) Overlapping(orgID string, start, end time.Time) []Blackout {
+	var overlapping []Blackout
+	for _, blackout := range r.List(orgID) {
+		if blackout.StartTime.Before(end) && start.Before(blackout.EndTime) {
+			overlapping = append(overlapping, blackout)
+		}
+	}
+	return overlapping
+}
+
+var blackouts = newBlackoutRegistry()
+
+// Blackout handlers
+func listBlackouts(c *gin.Context) {
+	c.JSON(http.StatusOK, blackouts.List(c.Param("orgId")))
+}
+
+func createBlackout(c *gin.Context) {
+	var req CreateBlackoutRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.EndTime.After(req.StartTime) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "End time must be after start time"})
+		return
+	}
+
+	blackout := Blackout{
+		ID:        uuid.New().String(),
+		OrgID:     c.Param("orgId"),
+		Name:      req.Name,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		CreatedAt: clock.Now(),
+	}
+	blackouts.Save(blackout)
+	c.JSON(http.StatusCreated, blackout)
+}
+
+func deleteBlackout(c *gin.Context) {
+	if !blackouts.Delete(c.Param("orgId"), c.Param("blackoutId")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Blackout not found"})
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
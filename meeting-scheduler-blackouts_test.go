@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetBlackouts(t *testing.T) {
+	previous := blackouts
+	blackouts = newBlackoutRegistry()
+	t.Cleanup(func() { blackouts = previous })
+}
+
+func TestGeneratorSkipsBlackouts(t *testing.T) {
+	resetDirectory(t)
+	resetBlackouts(t)
+	scheduler, fake := newTestScheduler(t)
+	users.Save(User{ID: "ada", OrgID: "acme", Role: RoleOrganizer})
+
+	day := fake.Now().Add(24 * time.Hour)
+	blackouts.Save(Blackout{ID: "b1", OrgID: "acme", Name: "All-hands", StartTime: day.Add(time.Hour), EndTime: day.Add(2 * time.Hour)})
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 60})
+	require.NoError(t, err)
+
+	slots, err := scheduler.GenerateTimeSlots(event.ID, GenerateTimeSlotsRequest{From: day, To: day.Add(4 * time.Hour), StepMinutes: 30})
+	require.NoError(t, err)
+	var starts []time.Time
+	for _, slot := range slots {
+		starts = append(starts, slot.StartTime)
+	}
+	assert.Equal(t, []time.Time{day, day.Add(2 * time.Hour), day.Add(150 * time.Minute), day.Add(3 * time.Hour)}, starts)
+
+	_, err = scheduler.GenerateTimeSlots(event.ID, GenerateTimeSlotsRequest{From: day, To: day.Add(1000 * time.Hour), StepMinutes: 1})
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+func TestRecommendationsWarnOnBlackouts(t *testing.T) {
+	resetDirectory(t)
+	resetBlackouts(t)
+	scheduler, fake := newTestScheduler(t)
+	users.Save(User{ID: "ada", OrgID: "acme", Role: RoleOrganizer})
+
+	day := fake.Now().Add(24 * time.Hour)
+	blackouts.Save(Blackout{ID: "b1", OrgID: "acme", Name: "Code freeze", StartTime: day, EndTime: day.Add(24 * time.Hour)})
+	blackouts.Save(Blackout{ID: "b2", OrgID: "other", Name: "Elsewhere", StartTime: day, EndTime: day.Add(72 * time.Hour)})
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 60})
+	require.NoError(t, err)
+	frozen, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: day.Add(time.Hour), EndTime: day.Add(2 * time.Hour)})
+	require.NoError(t, err)
+	clear, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: day.Add(48 * time.Hour), EndTime: day.Add(49 * time.Hour)})
+	require.NoError(t, err)
+	for _, slot := range []TimeSlot{frozen, clear} {
+		_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+		require.NoError(t, err)
+	}
+
+	recommendations, err := scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	require.Len(t, recommendations, 2)
+	for _, rec := range recommendations {
+		if rec.TimeSlot.ID == frozen.ID {
+			assert.Equal(t, []string{`Overlaps blackout "Code freeze"`}, rec.Warnings)
+		} else {
+			assert.Empty(t, rec.Warnings)
+		}
+	}
+}
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultBookingHorizonDays = 30
+	maxBookingHorizonDays     = 365
+	// maxBookingSlots bounds one slots listing
+	maxBookingSlots = 200
+)
+
+// ErrSlotNotOffered means a booking asked for a time the page doesn't offer,
+// or one taken since the booker loaded the page
+var ErrSlotNotOffered = errors.New("slot not offered")
+
+// WeeklyWindow is a recurring period on some weekdays, in a time zone
+type WeeklyWindow struct {
+	// Days are lowercase three-letter weekdays ("mon"); empty means every day
+	Days     []string `json:"days,omitempty"`
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	TimeZone string   `json:"timeZone,omitempty"`
+}
+
+func (w WeeklyWindow) validate() error {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return err
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return err
+	}
+	if end <= start {
+		return fmt.Errorf("window %s-%s must end after it starts", w.Start, w.End)
+	}
+	for _, day := range w.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("invalid weekday %q", day)
+		}
+	}
+	if _, err := time.LoadLocation(w.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone %q", w.TimeZone)
+	}
+	return nil
+}
+
+func (w WeeklyWindow) appliesOn(day time.Weekday) bool {
+	return ProtectedWindow{Days: w.Days}.appliesOn(day)
+}
+
+// Contains reports whether [start, end) lies within one occurrence
+func (w WeeklyWindow) Contains(start, end time.Time) bool {
+	loc, err := time.LoadLocation(w.TimeZone)
+	if err != nil {
+		return false
+	}
+	from, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	to, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+
+	local := start.In(loc)
+	if !w.appliesOn(local.Weekday()) {
+		return false
+	}
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	return !start.Before(day.Add(from)) && !end.After(day.Add(to))
+}
+
+// BookingPage lets outsiders book time with its owner, Calendly-style,
+// through a public link carrying Token
+type BookingPage struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+	OrgID  string `json:"orgId"`
+	Token  string `json:"token"`
+	Title  string `json:"title"`
+	// Windows are when the owner takes bookings
+	Windows []WeeklyWindow `json:"windows"`
+	// Durations are the plain meeting lengths bookers choose from, in
+	// minutes; MeetingTypeIDs offer the org's meeting types alongside them
+	Durations        []int     `json:"durations,omitempty"`
+	MeetingTypeIDs   []string  `json:"meetingTypes,omitempty"`
+	MinNoticeMinutes int       `json:"minNoticeMinutes"`
+	HorizonDays      int       `json:"horizonDays"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+type BookingPageRequest struct {
+	Title            string         `json:"title" binding:"required"`
+	Windows          []WeeklyWindow `json:"windows" binding:"required"`
+	Durations        []int          `json:"durations"`
+	MeetingTypeIDs   []string       `json:"meetingTypes"`
+	MinNoticeMinutes int            `json:"minNoticeMinutes"`
+	HorizonDays      int            `json:"horizonDays"`
+}
+
+// PublicBookingPage is what bookers see; it leaves out the owner's IDs
+type PublicBookingPage struct {
+	Title        string              `json:"title"`
+	Host         string              `json:"host"`
+	Durations    []int               `json:"durations,omitempty"`
+	MeetingTypes []PublicMeetingType `json:"meetingTypes,omitempty"`
+}
+
+// PublicMeetingType is a meeting type as bookers see it
+type PublicMeetingType struct {
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	DurationMinutes int        `json:"durationMinutes"`
+	LocationMode    string     `json:"locationMode,omitempty"`
+	Questions       []Question `json:"questions,omitempty"`
+}
+
+// BookSlotRequest books either a meeting type, answering its questions, or
+// one of the page's plain durations
+type BookSlotRequest struct {
+	Name            string            `json:"name" binding:"required"`
+	Email           string            `json:"email" binding:"required"`
+	StartTime       time.Time         `json:"startTime" binding:"required"`
+	MeetingTypeID   string            `json:"meetingTypeId"`
+	DurationMinutes int               `json:"durationMinutes"`
+	Answers         map[string]string `json:"answers"`
+	Notes           string            `json:"notes"`
+}
+
+type BookingConfirmation struct {
+	Event    Event    `json:"event"`
+	TimeSlot TimeSlot `json:"timeslot"`
+}
+
+// bookingOption is one kind of meeting a page offers: a plain duration or
+// a meeting type
+type bookingOption struct {
+	minutes     int
+	meetingType *MeetingType
+}
+
+func (o bookingOption) buffer() int {
+	if o.meetingType == nil {
+		return 0
+	}
+	return o.meetingType.BufferMinutes
+}
+
+func (o bookingOption) questions() []Question {
+	if o.meetingType == nil {
+		return nil
+	}
+	return o.meetingType.Questions
+}
+
+// option resolves what a booker asked for: the meeting type if given,
+// otherwise the plain duration
+func (p BookingPage) option(meetingTypeID string, minutes int) (bookingOption, error) {
+	if meetingTypeID != "" {
+		meetingType, ok := meetingTypes.Get(p.OrgID, meetingTypeID)
+		if !ok || !containsString(p.MeetingTypeIDs, meetingTypeID) {
+			return bookingOption{}, invalid("Meeting type not offered")
+		}
+		return bookingOption{minutes: meetingType.DurationMinutes, meetingType: &meetingType}, nil
+	}
+	if !p.offersDuration(minutes) {
+		return bookingOption{}, invalid("Duration not offered")
+	}
+	return bookingOption{minutes: minutes}, nil
+}
+
+func (p BookingPage) offersDuration(minutes int) bool {
+	for _, d := range p.Durations {
+		if d == minutes {
+			return true
+		}
+	}
+	return false
+}
+
+// bookingPageRegistry is the in-memory booking page store, one per user.
+// bookMu serializes bookings so a slot can't be claimed twice.
+type bookingPageRegistry struct {
+	mu     sync.RWMutex
+	bookMu sync.Mutex
+	pages  map[string]BookingPage // by user ID
+}
+
+func newBookingPageRegistry() *bookingPageRegistry {
+	return &bookingPageRegistry{pages: make(map[string]BookingPage)}
+}
+
+func (r *bookingPageRegistry) ForUser(userID string) (BookingPage, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	page, ok := r.pages[userID]
+	return page, ok
+}
+
+func (r *bookingPageRegistry) ByToken(token string) (BookingPage, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, page := range r.pages {
+		if page.Token == token {
+			return page, true
+		}
+	}
+	return BookingPage{}, false
+}
+
+func (r *bookingPageRegistry) Save(page BookingPage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pages[page.UserID] = page
+}
+
+func (r *bookingPageRegistry) Delete(userID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.pages[userID]; !ok {
+		return false
+	}
+	delete(r.pages, userID)
+	return true
+}
+
+var bookingPages = newBookingPageRegistry()
+
+// bookingPlanner checks the owner's calendars and scheduling rules, with
+// the page's minimum notice and the option's buffer
+func (s *Scheduler) bookingPlanner(ctx context.Context, page BookingPage, option bookingOption, from, to time.Time) (planner, error) {
+	p, warnings, err := s.newPlanner(ctx, Event{
+		OrganizerID:      page.UserID,
+		OrgID:            page.OrgID,
+		Invitees:         []string{page.UserID},
+		MinNoticeMinutes: page.MinNoticeMinutes,
+		BufferMinutes:    option.buffer(),
+	}, from, to)
+	for _, warning := range warnings {
+		log.Printf("Booking page %s: %s", page.ID, warning)
+	}
+	return p, err
+}
+
+// offered reports whether the page offers [start, end)
+func (p planner) offered(page BookingPage, start, end time.Time) bool {
+	if end.After(p.rules.now.AddDate(0, 0, page.HorizonDays)) {
+		return false
+	}
+	inWindow := false
+	for _, window := range page.Windows {
+		inWindow = inWindow || window.Contains(start, end)
+	}
+	return inWindow && len(p.rules.violations(start, end)) == 0 && p.available(page.UserID, start, end)
+}
+
+// BookingSlots lists the start times the page offers for an option between
+// from and to, on the duration's natural grid (every 15, 30 or 60 minutes)
+// unless the organization sets its own slot granularity
+func (s *Scheduler) BookingSlots(ctx context.Context, page BookingPage, option bookingOption, from, to time.Time) ([]TimeSlot, error) {
+	if !to.After(from) {
+		return nil, invalid("To must be after from")
+	}
+
+	p, err := s.bookingPlanner(ctx, page, option, from, to)
+	if err != nil {
+		return nil, err
+	}
+	step := time.Duration(slotGranularity(option.minutes)) * time.Minute
+	if granularity, ok := orgGranularity(page.OrgID); ok {
+		step = granularity
+	}
+	duration := time.Duration(option.minutes) * time.Minute
+	slots := []TimeSlot{}
+	for start := from.Truncate(step); !start.Add(duration).After(to) && len(slots) < maxBookingSlots; start = start.Add(step) {
+		if start.Before(from) {
+			continue
+		}
+		if p.offered(page, start, start.Add(duration)) {
+			slots = append(slots, TimeSlot{StartTime: start, EndTime: start.Add(duration)})
+		}
+	}
+	return slots, nil
+}
+
+// Book confirms a booker's chosen slot: the booker becomes a guest user of
+// the owner's organization and the meeting is booked and finalized, which
+// sends both sides an invite
+func (s *Scheduler) Book(ctx context.Context, page BookingPage, req BookSlotRequest) (BookingConfirmation, error) {
+	address, err := mail.ParseAddress(req.Email)
+	if err != nil {
+		return BookingConfirmation{}, invalid("Invalid email address")
+	}
+	option, err := page.option(req.MeetingTypeID, req.DurationMinutes)
+	if err != nil {
+		return BookingConfirmation{}, err
+	}
+	if err := checkAnswers(option.questions(), req.Answers); err != nil {
+		return BookingConfirmation{}, err
+	}
+
+	bookingPages.bookMu.Lock()
+	defer bookingPages.bookMu.Unlock()
+
+	start := req.StartTime
+	end := start.Add(time.Duration(option.minutes) * time.Minute)
+	p, err := s.bookingPlanner(ctx, page, option, start, end)
+	if err != nil {
+		return BookingConfirmation{}, err
+	}
+	if !p.offered(page, start, end) {
+		return BookingConfirmation{}, ErrSlotNotOffered
+	}
+
+	guest, ok := users.FindByEmail(page.OrgID, address.Address)
+	if !ok {
+		now := s.clock.Now()
+		guest = User{
+			ID:        uuid.New().String(),
+			Name:      req.Name,
+			Email:     address.Address,
+			OrgID:     page.OrgID,
+			Role:      RoleGuest,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		users.Save(guest)
+	}
+
+	title := page.Title
+	if option.meetingType != nil {
+		title = option.meetingType.Name
+	}
+	event, slot, err := s.bookMeeting(CreateEventRequest{
+		Title:            fmt.Sprintf("%s with %s", title, req.Name),
+		Description:      bookingDescription(req.Notes, option.questions(), req.Answers),
+		OrganizerID:      page.UserID,
+		RequiredDuration: option.minutes,
+		Invitees:         []string{guest.ID},
+		MeetingTypeID:    req.MeetingTypeID,
+	}, start)
+	if err != nil {
+		return BookingConfirmation{}, err
+	}
+	return BookingConfirmation{Event: event, TimeSlot: slot}, nil
+}
+
+// bookingDescription puts the booker's notes and answers in the invite
+func bookingDescription(notes string, questions []Question, answers map[string]string) string {
+	lines := []string{}
+	if notes != "" {
+		lines = append(lines, notes)
+	}
+	for _, q := range questions {
+		if answer := answers[q.ID]; answer != "" {
+			lines = append(lines, q.Prompt+": "+answer)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Booking page owner handlers
+func getMyBookingPage(c *gin.Context) {
+	user, _ := currentUser(c)
+	page, ok := bookingPages.ForUser(user.ID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking page not found"})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func putMyBookingPage(c *gin.Context) {
+	user, _ := currentUser(c)
+	var req BookingPageRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, window := range req.Windows {
+		if err := window.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if len(req.Durations) == 0 && len(req.MeetingTypeIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Offer at least one duration or meeting type"})
+		return
+	}
+	for _, minutes := range req.Durations {
+		if minutes <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Durations must be positive"})
+			return
+		}
+	}
+	for _, id := range req.MeetingTypeIDs {
+		if _, ok := meetingTypes.Get(user.OrgID, id); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown meeting type %q", id)})
+			return
+		}
+	}
+	if req.MinNoticeMinutes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Minimum notice cannot be negative"})
+		return
+	}
+	horizon := req.HorizonDays
+	if horizon == 0 {
+		horizon = defaultBookingHorizonDays
+	}
+	if horizon < 0 || horizon > maxBookingHorizonDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Horizon must be between 1 and 365 days"})
+		return
+	}
+
+	now := clock.Now()
+	page, ok := bookingPages.ForUser(user.ID)
+	if !ok {
+		page = BookingPage{ID: uuid.New().String(), UserID: user.ID, OrgID: user.OrgID, Token: randomToken(), CreatedAt: now}
+	}
+	page.Title = req.Title
+	page.Windows = req.Windows
+	page.Durations = req.Durations
+	page.MeetingTypeIDs = req.MeetingTypeIDs
+	page.MinNoticeMinutes = req.MinNoticeMinutes
+	page.HorizonDays = horizon
+	page.UpdatedAt = now
+	bookingPages.Save(page)
+	c.JSON(http.StatusOK, page)
+}
+
+func deleteMyBookingPage(c *gin.Context) {
+	user, _ := currentUser(c)
+	if !bookingPages.Delete(user.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking page not found"})
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// Public booking handlers; the token is the only credential. Pages are
+// only public while their owner has the booking pages flag.
+func bookingPageFromToken(c *gin.Context) (BookingPage, bool) {
+	page, ok := bookingPages.ByToken(c.Param("token"))
+	if ok {
+		owner, _ := users.Get(page.UserID)
+		ok = flagEnabled(FlagBookingPages, owner)
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking page not found"})
+	}
+	return page, ok
+}
+
+func getPublicBookingPage(c *gin.Context) {
+	page, ok := bookingPageFromToken(c)
+	if !ok {
+		return
+	}
+	host := page.UserID
+	if owner, ok := users.Get(page.UserID); ok && owner.Name != "" {
+		host = owner.Name
+	}
+	public := PublicBookingPage{Title: page.Title, Host: host, Durations: page.Durations}
+	for _, id := range page.MeetingTypeIDs {
+		// Meeting types deleted since the page was saved are no longer offered
+		if meetingType, ok := meetingTypes.Get(page.OrgID, id); ok {
+			public.MeetingTypes = append(public.MeetingTypes, PublicMeetingType{
+				ID:              meetingType.ID,
+				Name:            meetingType.Name,
+				DurationMinutes: meetingType.DurationMinutes,
+				LocationMode:    meetingType.LocationMode,
+				Questions:       meetingType.Questions,
+			})
+		}
+	}
+	c.JSON(http.StatusOK, public)
+}
+
+// listBookingSlots takes ?meetingType= or ?duration= (defaulting to the
+// first offered) and optional RFC 3339 ?from= and ?to= (defaulting to the
+// next 7 days)
+func listBookingSlots(c *gin.Context) {
+	page, ok := bookingPageFromToken(c)
+	if !ok {
+		return
+	}
+
+	meetingTypeID, minutes := c.Query("meetingType"), 0
+	if raw := c.Query("duration"); raw != "" {
+		var err error
+		if minutes, err = strconv.Atoi(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid duration"})
+			return
+		}
+	} else if meetingTypeID == "" {
+		if len(page.Durations) > 0 {
+			minutes = page.Durations[0]
+		} else if len(page.MeetingTypeIDs) > 0 {
+			meetingTypeID = page.MeetingTypeIDs[0]
+		}
+	}
+	option, err := page.option(meetingTypeID, minutes)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	from, to := clock.Now(), clock.Now().AddDate(0, 0, 7)
+	for name, target := range map[string]*time.Time{"from": &from, "to": &to} {
+		if raw := c.Query(name); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	slots, err := contextScheduler(c).BookingSlots(c.Request.Context(), page, option, from, to)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, slots)
+}
+
+func bookSlot(c *gin.Context) {
+	page, ok := bookingPageFromToken(c)
+	if !ok {
+		return
+	}
+	var req BookSlotRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	confirmation, err := contextScheduler(c).Book(c.Request.Context(), page, req)
+	if errors.Is(err, ErrSlotNotOffered) {
+		c.JSON(http.StatusConflict, gin.H{"error": "That time is no longer available"})
+		return
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, confirmation)
+}
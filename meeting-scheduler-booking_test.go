@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetBookingPages(t *testing.T) {
+	previous := bookingPages
+	bookingPages = newBookingPageRegistry()
+	t.Cleanup(func() { bookingPages = previous })
+}
+
+func TestBookingPageSlotsAndBooking(t *testing.T) {
+	resetDirectory(t)
+	resetDeclared(t)
+	resetBookingPages(t)
+	scheduler, fake := newTestScheduler(t)
+	users.Save(User{ID: "host", Name: "Hana", OrgID: "acme", Role: RoleMember})
+	page := BookingPage{
+		ID:          "page",
+		UserID:      "host",
+		OrgID:       "acme",
+		Token:       "tok",
+		Title:       "Intro call",
+		Windows:     []WeeklyWindow{{Days: []string{"mon"}, Start: "09:00", End: "11:00"}},
+		Durations:   []int{30},
+		HorizonDays: 30,
+	}
+	bookingPages.Save(page)
+
+	monday := fake.Now().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	slots, err := scheduler.BookingSlots(context.Background(), page, bookingOption{minutes: 30}, monday, monday.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Len(t, slots, 7)
+	assert.Equal(t, monday.Add(9*time.Hour), slots[0].StartTime)
+
+	_, err = page.option("", 45)
+	assert.Error(t, err)
+
+	req := BookSlotRequest{Name: "Olga", Email: "olga@example.com", StartTime: monday.Add(9*time.Hour + 30*time.Minute), DurationMinutes: 30}
+	confirmation, err := scheduler.Book(context.Background(), page, req)
+	require.NoError(t, err)
+	assert.Equal(t, "finalized", confirmation.Event.Status)
+	assert.Equal(t, "Intro call with Olga", confirmation.Event.Title)
+
+	guest, ok := users.FindByEmail("acme", "olga@example.com")
+	require.True(t, ok)
+	assert.Equal(t, RoleGuest, guest.Role)
+	assert.Equal(t, []string{guest.ID}, confirmation.Event.Invitees)
+
+	slots, err = scheduler.BookingSlots(context.Background(), page, bookingOption{minutes: 30}, monday, monday.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Len(t, slots, 4)
+
+	_, err = scheduler.Book(context.Background(), page, req)
+	assert.ErrorIs(t, err, ErrSlotNotOffered)
+
+	// Outside the page's windows
+	req.StartTime = monday.Add(14 * time.Hour)
+	_, err = scheduler.Book(context.Background(), page, req)
+	assert.ErrorIs(t, err, ErrSlotNotOffered)
+}
+
+func TestBookingMeetingTypeAppliesBufferAndQuestions(t *testing.T) {
+	resetDirectory(t)
+	resetDeclared(t)
+	resetBookingPages(t)
+	resetMeetingTypes(t)
+	scheduler, fake := newTestScheduler(t)
+	users.Save(User{ID: "host", Name: "Hana", OrgID: "acme", Role: RoleMember})
+	meetingTypes.Save(MeetingType{
+		ID:              "demo",
+		OrgID:           "acme",
+		Name:            "Demo",
+		DurationMinutes: 30,
+		BufferMinutes:   15,
+		LocationMode:    LocationVideo,
+		Location:        "https://meet.example.com/hana",
+		Questions:       []Question{{ID: "company", Prompt: "Company", Kind: QuestionText, Required: true}},
+	})
+	page := BookingPage{
+		ID:             "page",
+		UserID:         "host",
+		OrgID:          "acme",
+		Title:          "Hana",
+		Windows:        []WeeklyWindow{{Days: []string{"mon"}, Start: "09:00", End: "11:00"}},
+		MeetingTypeIDs: []string{"demo"},
+		HorizonDays:    30,
+	}
+
+	monday := fake.Now().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	req := BookSlotRequest{Name: "Olga", Email: "olga@example.com", StartTime: monday.Add(9 * time.Hour), MeetingTypeID: "demo"}
+	_, err := scheduler.Book(context.Background(), page, req)
+	assert.Error(t, err, "the required question is unanswered")
+
+	req.Answers = map[string]string{"company": "Initech"}
+	confirmation, err := scheduler.Book(context.Background(), page, req)
+	require.NoError(t, err)
+	assert.Equal(t, "Demo with Olga", confirmation.Event.Title)
+	assert.Equal(t, "Company: Initech", confirmation.Event.Description)
+	assert.Equal(t, "https://meet.example.com/hana", confirmation.Event.Location)
+	assert.Equal(t, 15, confirmation.Event.BufferMinutes)
+
+	// 09:00-09:30 is booked, so with the buffer the next start is 09:45
+	option, err := page.option("demo", 0)
+	require.NoError(t, err)
+	slots, err := scheduler.BookingSlots(context.Background(), page, option, monday, monday.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.NotEmpty(t, slots)
+	assert.Equal(t, monday.Add(9*time.Hour+45*time.Minute), slots[0].StartTime)
+}
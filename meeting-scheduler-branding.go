@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Branding customizes how an organization's notifications, calendar files
+// and poll pages look to participants
+type Branding struct {
+	LogoURL      string `json:"logoUrl,omitempty"`
+	PrimaryColor string `json:"primaryColor,omitempty"`
+	ReplyTo      string `json:"replyTo,omitempty"`
+	Footer       string `json:"footer,omitempty"`
+}
+
+var defaultBranding = Branding{
+	PrimaryColor: "#2f6fed",
+	Footer:       "Sent by Meeting Scheduler",
+}
+
+var hexColor = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}){1,2}$`)
+
+// brandingForOrg returns the organization's branding with defaults filled
+// in for anything it hasn't set
+func brandingForOrg(orgID string) (Branding, string) {
+	org, ok := organizations.Get(orgID)
+	if !ok {
+		return defaultBranding, ""
+	}
+
+	branding := org.Branding
+	if branding.PrimaryColor == "" {
+		branding.PrimaryColor = defaultBranding.PrimaryColor
+	}
+	if branding.Footer == "" {
+		branding.Footer = defaultBranding.Footer
+	}
+	return branding, org.Name
+}
+
+// Branding handlers
+func getBranding(c *gin.Context) {
+	org, ok := organizations.Get(c.Param("orgId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+	c.JSON(http.StatusOK, org.Branding)
+}
+
+func updateBranding(c *gin.Context) {
+	org, ok := organizations.Get(c.Param("orgId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	var req Branding
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.PrimaryColor != "" && !hexColor.MatchString(req.PrimaryColor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Primary color must be a hex color such as #2f6fed"})
+		return
+	}
+
+	org.Branding = req
+	org.UpdatedAt = clock.Now()
+	organizations.Save(org)
+	c.JSON(http.StatusOK, org.Branding)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingNotifier struct {
+	messages []Message
+}
+
+func (n *recordingNotifier) Notify(msg Message) error {
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+func TestBrandingAppliesToNotificationsAndCalendars(t *testing.T) {
+	resetDirectory(t)
+	scheduler, fake := newTestScheduler(t)
+	recorder := &recordingNotifier{}
+	previous := notifier
+	notifier = recorder
+	t.Cleanup(func() { notifier = previous })
+	scheduler.bus.Subscribe(EventFinalized, notifyEventFinalized)
+
+	organizations.Save(Organization{ID: "acme", Name: "Acme", Branding: Branding{
+		LogoURL:      "https://acme.test/logo.png",
+		PrimaryColor: "#ff6600",
+		ReplyTo:      "meetings@acme.test",
+	}})
+	users.Save(User{ID: "ada", Email: "ada@acme.test", OrgID: "acme", Role: RoleOrganizer})
+	users.Save(User{ID: "bob", Email: "bob@acme.test", OrgID: "acme", Role: RoleMember})
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Planning", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	assert.Equal(t, "acme", event.OrgID)
+	start := fake.Now().Add(24 * time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+
+	_, err = scheduler.FinalizeEvent(event.ID, FinalizeEventRequest{TimeSlotID: slot.ID})
+	require.NoError(t, err)
+	require.Len(t, recorder.messages, 2)
+
+	msg := recorder.messages[0]
+	assert.Contains(t, msg.Calendar, "SUMMARY:Acme: Planning")
+	assert.Contains(t, msg.Calendar, "ORGANIZER;CN=Acme:mailto:meetings@acme.test")
+	assert.Contains(t, msg.Calendar, "Sent by Meeting Scheduler")
+
+	email, err := renderEmail(msg, "scheduler@example.test")
+	require.NoError(t, err)
+	body := string(email)
+	assert.Contains(t, body, "Reply-To: meetings@acme.test")
+	assert.Contains(t, body, "From: Acme <scheduler@example.test>")
+	assert.Contains(t, body, "#ff6600")
+	assert.Contains(t, body, `src="https://acme.test/logo.png"`)
+	assert.Contains(t, body, "text/calendar")
+}
+
+func TestBrandingEndpointsAndPollPage(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	organizations.Save(Organization{ID: "acme", Name: "Acme"})
+	admin := User{ID: "ada", OrgID: "acme", Role: RoleAdmin}
+	users.Save(admin)
+	token, err := issueSessionToken(admin)
+	require.NoError(t, err)
+
+	put := func(body string) int {
+		req, _ := http.NewRequest("PUT", "/api/v1/organizations/acme/branding", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusBadRequest, put(`{"primaryColor":"orange"}`))
+	assert.Equal(t, http.StatusOK, put(`{"primaryColor":"#ff6600","footer":"Acme Corp"}`))
+
+	org, _ := organizations.Get("acme")
+	assert.Equal(t, "#ff6600", org.Branding.PrimaryColor)
+
+	event := Event{ID: "evt1", Title: "Planning <Q3>", OrganizerID: "ada", OrgID: "acme", RequiredDuration: 30}
+	require.NoError(t, store.CreateEvent(event))
+
+	w := doJSON(router, "GET", "/poll/evt1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "#ff6600")
+	assert.Contains(t, w.Body.String(), "Acme Corp")
+	assert.Contains(t, w.Body.String(), "Planning &lt;Q3&gt;")
+
+	w = doJSON(router, "GET", "/api/v1/events/evt1/ics", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestICSFoldsLongLines(t *testing.T) {
+	folded := icsFold("DESCRIPTION:" + strings.Repeat("x", 100))
+	for _, line := range strings.Split(folded, "\r\n") {
+		assert.LessOrEqual(t, len(line), 75)
+	}
+	assert.Equal(t, `a\, b\; c\\n\n`, icsEscape("a, b; c\\n\n"))
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+)
+
+// BrokerPublisher sends serialized domain events to an external message
+// broker so downstream systems can subscribe instead of polling the API
+type BrokerPublisher interface {
+	Publish(subject string, data []byte) error
+	Close() error
+}
+
+type natsPublisher struct {
+	conn *nats.Conn
+}
+
+func newNATSPublisher(url string) (*natsPublisher, error) {
+	conn, err := nats.Connect(url, nats.Name("meeting-scheduler"))
+	if err != nil {
+		return nil, err
+	}
+	return &natsPublisher{conn: conn}, nil
+}
+
+func (p *natsPublisher) Publish(subject string, data []byte) error {
+	return p.conn.Publish(subject, data)
+}
+
+func (p *natsPublisher) Close() error {
+	return p.conn.Drain()
+}
+
+// kafkaPublisher writes every event to a single topic keyed by subject, so
+// consumers can partition on event type
+type kafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+func newKafkaPublisher(brokers, topic string) *kafkaPublisher {
+	return &kafkaPublisher{writer: &kafka.Writer{
+		Addr:         kafka.TCP(strings.Split(brokers, ",")...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: 50 * time.Millisecond,
+	}}
+}
+
+func (p *kafkaPublisher) Publish(subject string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return p.writer.WriteMessages(ctx, kafka.Message{Key: []byte(subject), Value: data})
+}
+
+func (p *kafkaPublisher) Close() error {
+	return p.writer.Close()
+}
+
+// brokerFromEnv builds the publisher selected by BROKER_KIND ("nats" or
+// "kafka"). It returns nil when streaming is not configured.
+func brokerFromEnv() (BrokerPublisher, error) {
+	switch kind := getenv("BROKER_KIND", ""); kind {
+	case "":
+		return nil, nil
+	case "nats":
+		publisher, err := newNATSPublisher(getenv("BROKER_URL", nats.DefaultURL))
+		if err != nil {
+			return nil, err
+		}
+		return publisher, nil
+	case "kafka":
+		return newKafkaPublisher(getenv("BROKER_URL", "localhost:9092"), getenv("BROKER_TOPIC", "meeting-scheduler.events")), nil
+	default:
+		return nil, fmt.Errorf("unknown BROKER_KIND %q", kind)
+	}
+}
+
+// brokerForwarder relays bus events to a broker from a background goroutine
+// so a slow or unavailable broker never delays API requests. Events are
+// dropped (and logged) when the buffer is full.
+type brokerForwarder struct {
+	publisher BrokerPublisher
+	prefix    string
+	queue     chan DomainEvent
+	done      chan struct{}
+}
+
+func newBrokerForwarder(publisher BrokerPublisher, prefix string, buffer int) *brokerForwarder {
+	f := &brokerForwarder{
+		publisher: publisher,
+		prefix:    prefix,
+		queue:     make(chan DomainEvent, buffer),
+		done:      make(chan struct{}),
+	}
+	go f.run()
+	return f
+}
+
+// Enqueue is a DomainEventHandler
+func (f *brokerForwarder) Enqueue(event DomainEvent) {
+	select {
+	case f.queue <- event:
+	default:
+		log.Printf("Broker queue full, dropping %s for event %s", event.Type, event.EventID)
+	}
+}
+
+func (f *brokerForwarder) run() {
+	defer close(f.done)
+	for event := range f.queue {
+		data, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("Failed to encode %s for broker: %v", event.Type, err)
+			continue
+		}
+		if err := f.publisher.Publish(f.prefix+"."+string(event.Type), data); err != nil {
+			log.Printf("Failed to publish %s to broker: %v", event.Type, err)
+		}
+	}
+}
+
+// Close flushes queued events and closes the publisher
+func (f *brokerForwarder) Close() error {
+	close(f.queue)
+	<-f.done
+	return f.publisher.Close()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingPublisher struct {
+	mu       sync.Mutex
+	subjects []string
+	payloads [][]byte
+	closed   bool
+}
+
+func (p *recordingPublisher) Publish(subject string, data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.subjects = append(p.subjects, subject)
+	p.payloads = append(p.payloads, data)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestBrokerForwarderPublishesBusEvents(t *testing.T) {
+	publisher := &recordingPublisher{}
+	forwarder := newBrokerForwarder(publisher, "scheduler", 16)
+
+	b := newEventBus()
+	b.SubscribeAll(forwarder.Enqueue)
+	b.Publish(DomainEvent{Type: EventCreated, EventID: "e1"})
+	b.Publish(DomainEvent{Type: EventFinalized, EventID: "e1"})
+
+	require.NoError(t, forwarder.Close())
+	assert.True(t, publisher.closed)
+	assert.Equal(t, []string{"scheduler.event.created", "scheduler.event.finalized"}, publisher.subjects)
+
+	var decoded DomainEvent
+	require.NoError(t, json.Unmarshal(publisher.payloads[0], &decoded))
+	assert.Equal(t, "e1", decoded.EventID)
+}
+
+func TestBrokerFromEnv(t *testing.T) {
+	t.Setenv("BROKER_KIND", "")
+	publisher, err := brokerFromEnv()
+	assert.NoError(t, err)
+	assert.Nil(t, publisher)
+
+	t.Setenv("BROKER_KIND", "carrier-pigeon")
+	_, err = brokerFromEnv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// DomainEventType names something that happened in the scheduling domain
+type DomainEventType string
+
+const (
+	EventCreated          DomainEventType = "event.created"
+	EventUpdated          DomainEventType = "event.updated"
+	EventDeleted          DomainEventType = "event.deleted"
+	EventFinalized        DomainEventType = "event.finalized"
+	EventCancelled        DomainEventType = "event.cancelled"
+	EventRestored         DomainEventType = "event.restored"
+	EventImported         DomainEventType = "event.imported"
+	TimeSlotCreated       DomainEventType = "timeslot.created"
+	TimeSlotUpdated       DomainEventType = "timeslot.updated"
+	TimeSlotDeleted       DomainEventType = "timeslot.deleted"
+	AvailabilitySubmitted DomainEventType = "availability.submitted"
+	AvailabilityUpdated   DomainEventType = "availability.updated"
+	AvailabilityDeleted   DomainEventType = "availability.deleted"
+)
+
+// DomainEvent is published on the bus after a change has been persisted.
+// Payload carries the affected entity (Event, TimeSlot or UserAvailability);
+// event and time slot deletions leave it nil. OrgID is the owning event's
+// organization, used to route the event to that organization's webhooks.
+type DomainEvent struct {
+	Type       DomainEventType `json:"type"`
+	EventID    string          `json:"eventId"`
+	OrgID      string          `json:"orgId,omitempty"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Payload    interface{}     `json:"payload,omitempty"`
+	// ImpersonatorID is the operator who made the change while
+	// impersonating a user
+	ImpersonatorID string `json:"impersonatorId,omitempty"`
+}
+
+// DomainEventHandler consumes published domain events
+type DomainEventHandler func(DomainEvent)
+
+// EventBus is an in-process publish/subscribe hub. Delivery is synchronous
+// and in subscription order, so handlers doing slow work (network calls)
+// should hand it off rather than block the publishing request.
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers map[DomainEventType][]DomainEventHandler
+	all      []DomainEventHandler
+}
+
+func newEventBus() *EventBus {
+	return &EventBus{handlers: make(map[DomainEventType][]DomainEventHandler)}
+}
+
+// Subscribe registers a handler for one event type
+func (b *EventBus) Subscribe(eventType DomainEventType, handler DomainEventHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+// SubscribeAll registers a handler for every event type
+func (b *EventBus) SubscribeAll(handler DomainEventHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.all = append(b.all, handler)
+}
+
+// Publish delivers the event to its subscribers. A panicking handler is
+// logged and does not prevent delivery to the others.
+func (b *EventBus) Publish(event DomainEvent) {
+	b.mu.RLock()
+	handlers := append(append([]DomainEventHandler(nil), b.handlers[event.Type]...), b.all...)
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		deliver(handler, event)
+	}
+}
+
+func deliver(handler DomainEventHandler, event DomainEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Domain event handler for %s panicked: %v", event.Type, r)
+		}
+	}()
+	handler(event)
+}
+
+// bus is the process-wide event bus. Consumers subscribe in
+// registerSubscribers.
+var bus = newEventBus()
+
+// registerSubscribers attaches the built-in consumers to the bus
+func registerSubscribers(b *EventBus) {
+	b.SubscribeAll(analytics.Record)
+	b.SubscribeAll(recordActivity)
+	b.SubscribeAll(publishChanges)
+	b.Subscribe(EventCreated, notifyPollOpened)
+	b.Subscribe(EventFinalized, notifyEventFinalized)
+	b.Subscribe(AvailabilitySubmitted, notifyResponse)
+	b.Subscribe(AvailabilityUpdated, notifyResponse)
+	for _, eventType := range []DomainEventType{EventCreated, EventFinalized, EventCancelled, AvailabilitySubmitted} {
+		b.Subscribe(eventType, recordFeed)
+	}
+	if materialized != nil {
+		b.SubscribeAll(materialized.Observe)
+	}
+}
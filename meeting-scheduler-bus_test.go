@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBusDeliversToSubscribers(t *testing.T) {
+	b := newEventBus()
+
+	var created, all []DomainEventType
+	b.Subscribe(EventCreated, func(e DomainEvent) { created = append(created, e.Type) })
+	b.Subscribe(EventCreated, func(DomainEvent) { panic("broken subscriber") })
+	b.SubscribeAll(func(e DomainEvent) { all = append(all, e.Type) })
+
+	b.Publish(DomainEvent{Type: EventCreated})
+	b.Publish(DomainEvent{Type: EventDeleted})
+
+	assert.Equal(t, []DomainEventType{EventCreated}, created)
+	assert.Equal(t, []DomainEventType{EventCreated, EventDeleted}, all)
+}
+
+func TestSchedulerPublishesDomainEvents(t *testing.T) {
+	resetStorage(t)
+	fake := newFakeClock(time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	b := newEventBus()
+	counter := newAnalyticsCounter()
+	b.SubscribeAll(counter.Record)
+	scheduler := newScheduler(store, fake, b)
+
+	var finalized []DomainEvent
+	b.Subscribe(EventFinalized, func(e DomainEvent) { finalized = append(finalized, e) })
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "user1", RequiredDuration: 30})
+	require.NoError(t, err)
+	start := fake.Now().Add(time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "alice", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+	_, err = scheduler.FinalizeEvent(event.ID, FinalizeEventRequest{TimeSlotID: slot.ID})
+	require.NoError(t, err)
+
+	// Failed operations publish nothing
+	_, err = scheduler.FinalizeEvent(event.ID, FinalizeEventRequest{TimeSlotID: "missing"})
+	require.Error(t, err)
+
+	assert.Equal(t, map[DomainEventType]int{
+		EventCreated:          1,
+		TimeSlotCreated:       1,
+		AvailabilitySubmitted: 1,
+		EventFinalized:        1,
+	}, counter.Snapshot())
+
+	require.Len(t, finalized, 1)
+	assert.Equal(t, event.ID, finalized[0].EventID)
+	assert.Equal(t, fake.Now(), finalized[0].OccurredAt)
+}
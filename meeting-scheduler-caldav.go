@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// caldavConnector reads busy time from a CalDAV calendar collection, such
+// as iCloud or a self-hosted server. It asks for a free-busy report and
+// falls back to querying events when the server doesn't support one.
+type caldavConnector struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+}
+
+func newCalDAVConnector(conn CalendarConnection, secret string) CalendarConnector {
+	return &caldavConnector{
+		url:      conn.URL,
+		username: conn.Username,
+		password: secret,
+		client:   &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+const caldavFreeBusyQuery = `<?xml version="1.0" encoding="utf-8"?>
+<C:free-busy-query xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <C:time-range start="%s" end="%s"/>
+</C:free-busy-query>`
+
+const caldavCalendarQuery = `<?xml version="1.0" encoding="utf-8"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop>
+    <C:calendar-data><C:expand start="%[1]s" end="%[2]s"/></C:calendar-data>
+  </D:prop>
+  <C:filter>
+    <C:comp-filter name="VCALENDAR">
+      <C:comp-filter name="VEVENT">
+        <C:time-range start="%[1]s" end="%[2]s"/>
+      </C:comp-filter>
+    </C:comp-filter>
+  </C:filter>
+</C:calendar-query>`
+
+// caldavMultistatus is the subset of a WebDAV multistatus response holding
+// calendar data
+type caldavMultistatus struct {
+	Responses []struct {
+		Propstats []struct {
+			CalendarData string `xml:"prop>calendar-data"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+func (c *caldavConnector) report(ctx context.Context, body string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "REPORT", c.url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Header.Set("Depth", "1")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	return c.client.Do(req)
+}
+
+func (c *caldavConnector) FreeBusy(ctx context.Context, from, to time.Time) ([]BusyInterval, error) {
+	start, end := from.UTC().Format(icsTimeFormat), to.UTC().Format(icsTimeFormat)
+
+	resp, err := c.report(ctx, fmt.Sprintf(caldavFreeBusyQuery, start, end))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK && strings.HasPrefix(resp.Header.Get("Content-Type"), "text/calendar") {
+		return parseFreeBusy(io.LimitReader(resp.Body, maxICSUploadBytes))
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("credentials rejected")
+	}
+
+	return c.queryEvents(ctx, start, end)
+}
+
+// queryEvents treats every event in the range as busy time
+func (c *caldavConnector) queryEvents(ctx context.Context, start, end string) ([]BusyInterval, error) {
+	resp, err := c.report(ctx, fmt.Sprintf(caldavCalendarQuery, start, end))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("calendar query failed: %s", resp.Status)
+	}
+
+	var multistatus caldavMultistatus
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, maxICSUploadBytes)).Decode(&multistatus); err != nil {
+		return nil, fmt.Errorf("parsing calendar query response: %w", err)
+	}
+
+	var busy []BusyInterval
+	for _, response := range multistatus.Responses {
+		for _, propstat := range response.Propstats {
+			if propstat.CalendarData == "" {
+				continue
+			}
+			blocks, err := parseICSBlocks(strings.NewReader(propstat.CalendarData), time.UTC)
+			if err != nil {
+				return nil, err
+			}
+			for _, block := range blocks {
+				busy = append(busy, BusyInterval{Start: block.StartTime, End: block.EndTime})
+			}
+		}
+	}
+	return busy, nil
+}
+
+// parseFreeBusy reads the busy periods of VFREEBUSY components. Periods are
+// "start/end" or "start/duration"; FREE periods are skipped.
+func parseFreeBusy(r io.Reader) ([]BusyInterval, error) {
+	lines, err := icsUnfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var busy []BusyInterval
+	for _, line := range lines {
+		name, params, value := icsProperty(line)
+		if name != "FREEBUSY" || strings.EqualFold(params["FBTYPE"], "FREE") {
+			continue
+		}
+		for _, period := range strings.Split(value, ",") {
+			startText, endText, ok := strings.Cut(period, "/")
+			if !ok {
+				return nil, fmt.Errorf("invalid period %q", period)
+			}
+			start, err := time.Parse(icsTimeFormat, startText)
+			if err != nil {
+				return nil, fmt.Errorf("invalid period %q", period)
+			}
+			var end time.Time
+			if strings.HasPrefix(endText, "P") {
+				d, err := icsParseDuration(endText)
+				if err != nil {
+					return nil, err
+				}
+				end = start.Add(d)
+			} else if end, err = time.Parse(icsTimeFormat, endText); err != nil {
+				return nil, fmt.Errorf("invalid period %q", period)
+			}
+			busy = append(busy, BusyInterval{Start: start, End: end})
+		}
+	}
+	return busy, nil
+}
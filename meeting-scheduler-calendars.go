@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// BusyInterval is a period in which a user's external calendar is busy
+type BusyInterval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// CalendarConnector reads free/busy information from an external calendar
+type CalendarConnector interface {
+	FreeBusy(ctx context.Context, from, to time.Time) ([]BusyInterval, error)
+}
+
+// calendarConnectors builds a connector for each supported kind from a
+// connection and its decrypted secret
+var calendarConnectors = map[string]func(conn CalendarConnection, secret string) CalendarConnector{
+	"caldav": newCalDAVConnector,
+}
+
+// CalendarConnection links a user to an external calendar. The secret is
+// only ever held encrypted.
+type CalendarConnection struct {
+	ID       string `json:"id"`
+	UserID   string `json:"userId"`
+	Kind     string `json:"kind"`
+	URL      string `json:"url"`
+	Username string `json:"username,omitempty"`
+
+	SealedSecret []byte `json:"-"`
+
+	LastSyncedAt *time.Time `json:"lastSyncedAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+type CreateCalendarConnectionRequest struct {
+	Kind     string `json:"kind" binding:"required"`
+	URL      string `json:"url" binding:"required"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// connector decrypts the connection's secret and builds its connector
+func (conn CalendarConnection) connector() (CalendarConnector, error) {
+	build, ok := calendarConnectors[conn.Kind]
+	if !ok {
+		return nil, fmt.Errorf("unsupported calendar kind %q", conn.Kind)
+	}
+	secret := ""
+	if len(conn.SealedSecret) > 0 {
+		var err error
+		if secret, err = openSecret(conn.SealedSecret); err != nil {
+			return nil, fmt.Errorf("decrypting calendar credentials: %w", err)
+		}
+	}
+	return build(conn, secret), nil
+}
+
+// calendarRegistry is the in-memory store of calendar connections
+type calendarRegistry struct {
+	mu          sync.RWMutex
+	connections map[string]CalendarConnection
+}
+
+func newCalendarRegistry() *calendarRegistry {
+	return &calendarRegistry{connections: make(map[string]CalendarConnection)}
+}
+
+func (r *calendarRegistry) Save(conn CalendarConnection) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.connections[conn.ID] = conn
+}
+
+func (r *calendarRegistry) Delete(userID, id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	conn, ok := r.connections[id]
+	if !ok || conn.UserID != userID {
+		return false
+	}
+	delete(r.connections, id)
+	return true
+}
+
+// ListForUser returns a user's connections, oldest first
+func (r *calendarRegistry) ListForUser(userID string) []CalendarConnection {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	connList := []CalendarConnection{}
+	for _, conn := range r.connections {
+		if conn.UserID == userID {
+			connList = append(connList, conn)
+		}
+	}
+	sort.Slice(connList, func(i, j int) bool { return connList[i].CreatedAt.Before(connList[j].CreatedAt) })
+	return connList
+}
+
+// rewrap moves every connection's credentials under the active
+// encryption key and returns how many changed
+func (r *calendarRegistry) rewrap() (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := 0
+	for id, conn := range r.connections {
+		if len(conn.SealedSecret) == 0 {
+			continue
+		}
+		sealed, ok, err := rewrapSecret(conn.SealedSecret)
+		if err != nil {
+			return changed, fmt.Errorf("connection %s: %w", id, err)
+		}
+		if ok {
+			conn.SealedSecret = sealed
+			r.connections[id] = conn
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+var calendars = newCalendarRegistry()
+
+// userFreeBusy merges the busy intervals from all of a user's connected
+// calendars
+func userFreeBusy(ctx context.Context, userID string, from, to time.Time) ([]BusyInterval, error) {
+	var busy []BusyInterval
+	for _, conn := range calendars.ListForUser(userID) {
+		connector, err := conn.connector()
+		if err != nil {
+			return nil, err
+		}
+		callCtx, cancel := context.WithTimeout(ctx, integrationTimeout())
+		intervals, err := connector.FreeBusy(callCtx, from, to)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("%s calendar %s: %w", conn.Kind, conn.URL, err)
+		}
+		busy = append(busy, intervals...)
+
+		now := clock.Now()
+		conn.LastSyncedAt = &now
+		calendars.Save(conn)
+	}
+	return busy, nil
+}
+
+// ApplyFreeBusy answers every time slot of the event for the user:
+// available when no busy interval overlaps the meeting, unavailable
+// otherwise. Existing answers are updated.
+func (s *Scheduler) ApplyFreeBusy(eventID, userID string, busy []BusyInterval) ([]UserAvailability, error) {
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return nil, err
+	}
+	slots, err := s.store.ListTimeSlots(eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	answers := make([]UserAvailability, 0, len(slots))
+	for _, slot := range slots {
+		end := slot.StartTime.Add(event.duration())
+		status := "available"
+		for _, interval := range busy {
+			if interval.Start.Before(end) && slot.StartTime.Before(interval.End) {
+				status = "unavailable"
+				break
+			}
+		}
+
+		req := UserAvailabilityRequest{TimeSlotID: slot.ID, Status: status}
+		avail, err := s.UpdateAvailability(eventID, userID, slot.ID, req)
+		if errors.Is(err, ErrAvailabilityNotFound) {
+			avail, err = s.SubmitAvailability(eventID, userID, req)
+		}
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, avail)
+	}
+	return answers, nil
+}
+
+// Calendar connection handlers
+func listCalendarConnections(c *gin.Context) {
+	user, _ := currentUser(c)
+	c.JSON(http.StatusOK, calendars.ListForUser(user.ID))
+}
+
+func createCalendarConnection(c *gin.Context) {
+	user, _ := currentUser(c)
+
+	var req CreateCalendarConnectionRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := calendarConnectors[req.Kind]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported calendar kind"})
+		return
+	}
+
+	conn := CalendarConnection{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		Kind:      req.Kind,
+		URL:       req.URL,
+		Username:  req.Username,
+		CreatedAt: clock.Now(),
+	}
+	if req.Password != "" {
+		sealed, err := sealSecret(req.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		conn.SealedSecret = sealed
+	}
+	calendars.Save(conn)
+	c.JSON(http.StatusCreated, conn)
+}
+
+func deleteCalendarConnection(c *gin.Context) {
+	user, _ := currentUser(c)
+	if !calendars.Delete(user.ID, c.Param("connectionId")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar connection not found"})
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// syncAvailability answers the event's slots for the signed-in user from
+// their connected calendars
+func syncAvailability(c *gin.Context) {
+	user, _ := currentUser(c)
+	if user.ID != c.Param("userId") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Can only sync your own availability"})
+		return
+	}
+
+	scheduler := contextScheduler(c)
+	eventID := c.Param("eventId")
+	if _, err := scheduler.GetEvent(eventID); err != nil {
+		respondError(c, err)
+		return
+	}
+	slots, err := scheduler.ListTimeSlots(eventID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if len(slots) == 0 {
+		c.JSON(http.StatusOK, []UserAvailability{})
+		return
+	}
+
+	from, to := slots[0].StartTime, slots[0].EndTime
+	for _, slot := range slots {
+		if slot.StartTime.Before(from) {
+			from = slot.StartTime
+		}
+		if slot.EndTime.After(to) {
+			to = slot.EndTime
+		}
+	}
+
+	busy, err := userFreeBusy(c.Request.Context(), user.ID, from, to)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	answers, err := scheduler.ApplyFreeBusy(eventID, user.ID, busy)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, answers)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCalDAV serves a free-busy report, or only calendar-query when
+// freeBusy is false, as iCloud does
+func fakeCalDAV(t *testing.T, freeBusy bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "ada@icloud.test" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case strings.Contains(string(body), "free-busy-query") && freeBusy:
+			w.Header().Set("Content-Type", "text/calendar")
+			io.WriteString(w, "BEGIN:VCALENDAR\r\nBEGIN:VFREEBUSY\r\n"+
+				"FREEBUSY;FBTYPE=BUSY:20250115T140000Z/PT1H,20250116T090000Z/20250116T093000Z\r\n"+
+				"FREEBUSY;FBTYPE=FREE:20250117T090000Z/PT8H\r\n"+
+				"END:VFREEBUSY\r\nEND:VCALENDAR\r\n")
+		case strings.Contains(string(body), "free-busy-query"):
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusMultiStatus)
+			io.WriteString(w, `<?xml version="1.0"?>
+<d:multistatus xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">
+  <d:response><d:href>/cal/1.ics</d:href><d:propstat><d:prop><cal:calendar-data>BEGIN:VCALENDAR
+BEGIN:VEVENT
+DTSTART:20250115T140000Z
+DTEND:20250115T150000Z
+END:VEVENT
+END:VCALENDAR
+</cal:calendar-data></d:prop></d:propstat></d:response>
+</d:multistatus>`)
+		}
+	}))
+}
+
+func TestCalDAVFreeBusy(t *testing.T) {
+	from := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	for _, freeBusy := range []bool{true, false} {
+		server := fakeCalDAV(t, freeBusy)
+		defer server.Close()
+
+		sealed, err := sealSecret("secret")
+		require.NoError(t, err)
+		connector, err := CalendarConnection{Kind: "caldav", URL: server.URL, Username: "ada@icloud.test", SealedSecret: sealed}.connector()
+		require.NoError(t, err)
+
+		busy, err := connector.FreeBusy(context.Background(), from, from.Add(72*time.Hour))
+		require.NoError(t, err)
+		require.NotEmpty(t, busy)
+		assert.Equal(t, BusyInterval{Start: from.Add(14 * time.Hour), End: from.Add(15 * time.Hour)}, busy[0])
+		if freeBusy {
+			assert.Len(t, busy, 2)
+		}
+	}
+
+	server := fakeCalDAV(t, true)
+	defer server.Close()
+	connector, _ := CalendarConnection{Kind: "caldav", URL: server.URL, Username: "ada@icloud.test"}.connector()
+	_, err := connector.FreeBusy(context.Background(), from, from.Add(time.Hour))
+	assert.Error(t, err)
+}
+
+func TestApplyFreeBusyAnswersSlots(t *testing.T) {
+	scheduler, _ := newTestScheduler(t)
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 60})
+	require.NoError(t, err)
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	busySlot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: day.Add(14 * time.Hour), EndTime: day.Add(15 * time.Hour)})
+	require.NoError(t, err)
+	freeSlot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: day.Add(16 * time.Hour), EndTime: day.Add(17 * time.Hour)})
+	require.NoError(t, err)
+
+	// An earlier manual answer is overwritten
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: busySlot.ID, Status: "available"})
+	require.NoError(t, err)
+
+	_, err = scheduler.ApplyFreeBusy(event.ID, "bob", []BusyInterval{{Start: day.Add(14*time.Hour + 30*time.Minute), End: day.Add(15 * time.Hour)}})
+	require.NoError(t, err)
+
+	busyAnswer, err := scheduler.GetAvailability(event.ID, "bob", busySlot.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "unavailable", busyAnswer.Status)
+	freeAnswer, err := scheduler.GetAvailability(event.ID, "bob", freeSlot.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "available", freeAnswer.Status)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// inviteeLimit is how many people organizerID may invite to one event.
+// MAX_INVITEES sets it for everyone but admins, who aren't capped; 0
+// removes the cap.
+func inviteeLimit(organizerID string) int {
+	if organizer, ok := users.Get(organizerID); ok && organizer.Role == RoleAdmin {
+		return 0
+	}
+	return getenvInt("MAX_INVITEES", 500)
+}
+
+// inviteeWarningThreshold is the invitee count above which an event is
+// flagged as large. INVITEE_WARNING_THRESHOLD sets it.
+func inviteeWarningThreshold() int {
+	return getenvInt("INVITEE_WARNING_THRESHOLD", 100)
+}
+
+// countInvitees counts the distinct people invited, not counting the
+// organizer
+func countInvitees(organizerID string, invitees []string) int {
+	seen := map[string]bool{organizerID: true}
+	for _, id := range invitees {
+		seen[id] = true
+	}
+	return len(seen) - 1
+}
+
+func validateInviteeCapacity(req CreateEventRequest) error {
+	limit := inviteeLimit(req.OrganizerID)
+	if n := countInvitees(req.OrganizerID, req.Invitees); limit > 0 && n > limit {
+		return invalid(fmt.Sprintf("Events can invite at most %d people; this one invites %d", limit, n))
+	}
+	return nil
+}
+
+// inviteeWarnings explains what changes for a large event, if this is one
+func inviteeWarnings(event Event) []string {
+	n := countInvitees(event.OrganizerID, event.Invitees)
+	if threshold := inviteeWarningThreshold(); threshold <= 0 || n <= threshold {
+		return nil
+	}
+	return []string{fmt.Sprintf("%d invitees is a large event; notifications may take a while to reach everyone", n)}
+}
+
+// warnLargeEvent adds an X-Scheduler-Warning header for each warning about
+// the event's size
+func warnLargeEvent(c *gin.Context, event Event) {
+	for _, warning := range inviteeWarnings(event) {
+		c.Writer.Header().Add("X-Scheduler-Warning", warning)
+	}
+}
+
+// fanOutThreshold is the recipient count above which notifications leave
+// the request and go out in batches from the background. FANOUT_THRESHOLD
+// sets it.
+func fanOutThreshold() int {
+	return getenvInt("FANOUT_THRESHOLD", 50)
+}
+
+// fanOutBatchSize is how many messages go out together. FANOUT_BATCH_SIZE
+// sets it.
+func fanOutBatchSize() int {
+	return max(getenvInt("FANOUT_BATCH_SIZE", 25), 1)
+}
+
+// fanOutQueue sends batches of notifications from a background goroutine,
+// like webhookDispatcher, so inviting a crowd doesn't hold up the request
+type fanOutQueue struct {
+	queue chan []Message
+	done  chan struct{}
+}
+
+func newFanOutQueue(buffer int) *fanOutQueue {
+	q := &fanOutQueue{
+		queue: make(chan []Message, buffer),
+		done:  make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Enqueue hands a batch to the background. A full queue sends it inline
+// rather than dropping it.
+func (q *fanOutQueue) Enqueue(batch []Message) {
+	select {
+	case q.queue <- batch:
+	default:
+		log.Printf("Notification queue full, sending %d messages inline", len(batch))
+		sendMessages(batch)
+	}
+}
+
+func (q *fanOutQueue) run() {
+	defer close(q.done)
+	for batch := range q.queue {
+		sendMessages(batch)
+	}
+}
+
+// Close flushes queued batches
+func (q *fanOutQueue) Close() {
+	close(q.queue)
+	<-q.done
+}
+
+// fanOut is the background queue large notification runs go through. When
+// it is nil, as in tests, everything is sent inline.
+var fanOut *fanOutQueue
+
+// notifyAll delivers one message per recipient: inline for a few, and in
+// batches from the background above the fan-out threshold
+func notifyAll(messages []Message) {
+	if fanOut == nil || len(messages) <= fanOutThreshold() {
+		sendMessages(messages)
+		return
+	}
+	size := fanOutBatchSize()
+	for start := 0; start < len(messages); start += size {
+		fanOut.Enqueue(messages[start:min(start+size, len(messages))])
+	}
+}
+
+func sendMessages(messages []Message) {
+	for _, msg := range messages {
+		if err := notifier.Notify(msg); err != nil {
+			log.Printf("Failed to send %s notification to %s about %s: %v", msg.Kind, msg.To.ID, msg.EventID, err)
+		}
+	}
+}
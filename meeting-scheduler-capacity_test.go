@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInviteeCapacity(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	t.Setenv("MAX_INVITEES", "3")
+	t.Setenv("INVITEE_WARNING_THRESHOLD", "2")
+	users.Save(User{ID: "ada", Role: RoleOrganizer})
+	users.Save(User{ID: "root", Role: RoleAdmin})
+
+	create := func(organizerID string, invitees ...string) (Event, error) {
+		return currentScheduler().CreateEvent(CreateEventRequest{Title: "All hands", OrganizerID: organizerID, RequiredDuration: 30, Invitees: invitees})
+	}
+	_, err := create("ada", "ada", "bob", "cy", "dan", "bob")
+	assert.NoError(t, err, "the organizer and repeats don't count")
+	_, err = create("ada", "bob", "cy", "dan", "eve")
+	var validation *ValidationError
+	assert.ErrorAs(t, err, &validation)
+	_, err = create("root", "bob", "cy", "dan", "eve")
+	assert.NoError(t, err, "admins aren't capped")
+
+	w := doJSON(router, http.MethodPost, "/api/v1/events", CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 30, Invitees: []string{"bob", "cy"}})
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get("X-Scheduler-Warning"))
+	w = doJSON(router, http.MethodPost, "/api/v1/events", CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 30, Invitees: []string{"bob", "cy", "dan"}})
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Header().Get("X-Scheduler-Warning"), "3 invitees")
+	w = doJSON(router, http.MethodPost, "/api/v1/events", CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 30, Invitees: []string{"bob", "cy", "dan", "eve"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// blockingNotifier records messages once release is closed
+type blockingNotifier struct {
+	release chan struct{}
+	mu      sync.Mutex
+	sent    []Message
+}
+
+func (n *blockingNotifier) Notify(msg Message) error {
+	<-n.release
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = append(n.sent, msg)
+	return nil
+}
+
+func TestLargeInvitationsFanOutInTheBackground(t *testing.T) {
+	resetDirectory(t)
+	scheduler, _ := newTestScheduler(t)
+	t.Setenv("FANOUT_THRESHOLD", "3")
+	t.Setenv("FANOUT_BATCH_SIZE", "2")
+	recorder := &blockingNotifier{release: make(chan struct{})}
+	previous, previousFanOut := notifier, fanOut
+	notifier, fanOut = recorder, newFanOutQueue(10)
+	t.Cleanup(func() { notifier, fanOut = previous, previousFanOut })
+	scheduler.bus.Subscribe(EventCreated, notifyPollOpened)
+
+	var invitees []string
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("user-%d", i)
+		users.Save(User{ID: id})
+		invitees = append(invitees, id)
+	}
+	_, err := scheduler.CreateEvent(CreateEventRequest{Title: "All hands", OrganizerID: "ada", RequiredDuration: 30, Invitees: invitees})
+	require.NoError(t, err, "returns without waiting for delivery")
+	recorder.mu.Lock()
+	assert.Empty(t, recorder.sent)
+	recorder.mu.Unlock()
+
+	close(recorder.release)
+	fanOut.Close()
+	require.Len(t, recorder.sent, 5)
+	for i, msg := range recorder.sent {
+		assert.Equal(t, invitees[i], msg.To.ID)
+		assert.Equal(t, MessagePollOpened, msg.Kind)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Challenge providers
+const (
+	ChallengeHCaptcha  = "hcaptcha"
+	ChallengeTurnstile = "turnstile"
+)
+
+// challengeTokenHeader carries the token the challenge widget produced
+const challengeTokenHeader = "X-Challenge-Token"
+
+// ErrChallengeFailed is returned when the provider rejects a token
+var ErrChallengeFailed = errors.New("challenge verification failed")
+
+// ChallengePolicy asks anonymous respondents to an organization's events
+// and booking pages to pass a CAPTCHA first. An empty provider turns it off.
+type ChallengePolicy struct {
+	Provider string `json:"provider,omitempty"`
+	// SiteKey is the public key clients render the widget with
+	SiteKey string `json:"siteKey,omitempty"`
+	// SecretRef names the secret holding the provider's secret key
+	SecretRef string `json:"secretRef,omitempty"`
+}
+
+func (p ChallengePolicy) enabled() bool {
+	return p.Provider != ""
+}
+
+func (p ChallengePolicy) validate() error {
+	if !p.enabled() {
+		return nil
+	}
+	if _, ok := challengeVerifiers[p.Provider]; !ok {
+		return fmt.Errorf("unknown challenge provider %q", p.Provider)
+	}
+	if p.SiteKey == "" || p.SecretRef == "" {
+		return errors.New("siteKey and secretRef are required")
+	}
+	return nil
+}
+
+// ChallengeVerifier checks a challenge token with its provider. It returns
+// ErrChallengeFailed when the token isn't valid, and other errors when the
+// provider couldn't be asked.
+type ChallengeVerifier interface {
+	VerifyChallenge(ctx context.Context, secret, token, remoteIP string) error
+}
+
+// siteVerifier speaks the siteverify protocol hCaptcha and Turnstile share:
+// a form post of the secret and token, answered with whether it passed
+type siteVerifier struct {
+	url    string
+	client *http.Client
+}
+
+func (v siteVerifier) VerifyChallenge(ctx context.Context, secret, token, remoteIP string) error {
+	form := url.Values{"secret": {secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("siteverify: %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("siteverify: %w", err)
+	}
+	if !result.Success {
+		return fmt.Errorf("%w: %s", ErrChallengeFailed, strings.Join(result.ErrorCodes, ", "))
+	}
+	return nil
+}
+
+// challengeVerifiers are the supported providers by name
+var challengeVerifiers = map[string]ChallengeVerifier{
+	ChallengeHCaptcha:  siteVerifier{url: "https://api.hcaptcha.com/siteverify", client: &http.Client{Timeout: 10 * time.Second}},
+	ChallengeTurnstile: siteVerifier{url: "https://challenges.cloudflare.com/turnstile/v0/siteverify", client: &http.Client{Timeout: 10 * time.Second}},
+}
+
+// challengeOrg is the organization whose policy covers the request: the
+// booking page's on token routes, otherwise the event's. The event is read
+// through requestScheduler, since inside an atomic batch the batch's
+// transaction holds the store.
+func challengeOrg(c *gin.Context) (Organization, bool) {
+	orgID := ""
+	if token := c.Param("token"); token != "" {
+		page, ok := bookingPages.ByToken(token)
+		if !ok {
+			return Organization{}, false
+		}
+		orgID = page.OrgID
+	} else {
+		event, err := requestScheduler(c).GetEvent(c.Param("eventId"))
+		if err != nil {
+			return Organization{}, false
+		}
+		orgID = event.OrgID
+	}
+	return organizations.Get(orgID)
+}
+
+// requireChallenge makes anonymous submissions pass the organization's
+// challenge. Signed-in users, and requests the handler will turn away
+// anyway, go straight through.
+func requireChallenge(c *gin.Context) {
+	if _, ok := currentUser(c); ok {
+		c.Next()
+		return
+	}
+	org, ok := challengeOrg(c)
+	if !ok || !org.Challenge.enabled() {
+		c.Next()
+		return
+	}
+	policy := org.Challenge
+
+	token := c.GetHeader(challengeTokenHeader)
+	if token == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error":     "A challenge must be completed before responding",
+			"challenge": gin.H{"provider": policy.Provider, "siteKey": policy.SiteKey},
+		})
+		return
+	}
+	err := challengeVerifiers[policy.Provider].VerifyChallenge(c.Request.Context(), secrets.Get(policy.SecretRef), token, c.ClientIP())
+	if errors.Is(err, ErrChallengeFailed) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error":     "Challenge verification failed",
+			"challenge": gin.H{"provider": policy.Provider, "siteKey": policy.SiteKey},
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Verifying %s challenge for organization %s: %v", policy.Provider, org.ID, err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Could not verify the challenge; try again"})
+		return
+	}
+	c.Next()
+}
+
+func updateChallengePolicy(c *gin.Context) {
+	org, ok := organizations.Get(c.Param("orgId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+	var req ChallengePolicy
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org.Challenge = req
+	org.UpdatedAt = clock.Now()
+	organizations.Save(org)
+	c.JSON(http.StatusOK, req)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymousResponsesPassTheOrganizationsChallenge(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	t.Setenv("TURNSTILE_SECRET", "s3cret")
+
+	var verified []string
+	siteverify := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		verified = append(verified, r.PostForm.Get("response"))
+		if r.PostForm.Get("secret") == "s3cret" && r.PostForm.Get("response") == "good" {
+			w.Write([]byte(`{"success": true}`))
+			return
+		}
+		w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+	}))
+	defer siteverify.Close()
+	previous := challengeVerifiers[ChallengeTurnstile]
+	challengeVerifiers[ChallengeTurnstile] = siteVerifier{url: siteverify.URL, client: siteverify.Client()}
+	t.Cleanup(func() { challengeVerifiers[ChallengeTurnstile] = previous })
+
+	organizations.Save(Organization{ID: "acme"})
+	users.Save(User{ID: "ada", OrgID: "acme", Role: RoleOrganizer})
+	scheduler := currentScheduler()
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	start := time.Now().Add(24 * time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+
+	respond := func(challengeToken string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/events/"+event.ID+"/users/visitor-"+challengeToken+"/availability",
+			strings.NewReader(`{"timeslotId": "`+slot.ID+`", "status": "available"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if challengeToken != "" {
+			req.Header.Set(challengeTokenHeader, challengeToken)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Without a policy nothing is asked
+	require.Equal(t, http.StatusCreated, respond("").Code)
+
+	org, _ := organizations.Get("acme")
+	org.Challenge = ChallengePolicy{Provider: ChallengeTurnstile, SiteKey: "site-1", SecretRef: "TURNSTILE_SECRET"}
+	organizations.Save(org)
+
+	var refused struct {
+		Challenge ChallengePolicy `json:"challenge"`
+	}
+	w := respond("")
+	require.Equal(t, http.StatusForbidden, w.Code)
+	decodeJSON(t, w, &refused)
+	assert.Equal(t, ChallengePolicy{Provider: ChallengeTurnstile, SiteKey: "site-1"}, refused.Challenge, "the secret's name isn't shown")
+
+	assert.Equal(t, http.StatusForbidden, respond("forged").Code)
+	assert.Equal(t, http.StatusCreated, respond("good").Code)
+	assert.Equal(t, []string{"forged", "good"}, verified)
+
+	// Atomic batches are checked against the batch's transaction
+	batch := func(challengeToken string) BatchResponse {
+		body, _ := json.Marshal(BatchRequest{Atomic: true, Operations: []BatchOperation{{
+			Method: "POST", Path: "/api/v1/events/" + event.ID + "/users/visitor-batch/availability",
+			Body: json.RawMessage(`{"timeslotId": "` + slot.ID + `", "status": "available"}`),
+		}}})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/batch", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(challengeTokenHeader, challengeToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response BatchResponse
+		decodeJSON(t, w, &response)
+		return response
+	}
+	refusedBatch := batch("forged")
+	assert.True(t, refusedBatch.RolledBack)
+	assert.Equal(t, http.StatusForbidden, refusedBatch.Results[0].Status)
+	acceptedBatch := batch("good")
+	assert.False(t, acceptedBatch.RolledBack)
+	assert.Equal(t, http.StatusCreated, acceptedBatch.Results[0].Status)
+}
+
+func TestChallengePolicyValidation(t *testing.T) {
+	assert.NoError(t, ChallengePolicy{}.validate())
+	assert.NoError(t, ChallengePolicy{Provider: ChallengeHCaptcha, SiteKey: "site-1", SecretRef: "HCAPTCHA_SECRET"}.validate())
+	assert.Error(t, ChallengePolicy{Provider: "recaptcha", SiteKey: "site-1", SecretRef: "SECRET"}.validate())
+	assert.Error(t, ChallengePolicy{Provider: ChallengeTurnstile, SiteKey: "site-1"}.validate())
+}
@@ -0,0 +1,20 @@
+package main
+
+import "time"
+
+// Clock is the source of the current time for handlers, background jobs and
+// expiry checks. Production code uses the wall clock; tests install a fake so
+// deadline and reminder logic can be exercised deterministically.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// clock is the process-wide time source
+var clock Clock = realClock{}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a manually advanced Clock for tests
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	f.waiters = append(f.waiters, fakeWaiter{at: f.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward and fires any After channels now due
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	pending := f.waiters[:0]
+	for _, w := range f.waiters {
+		if !f.now.Before(w.at) {
+			w.ch <- f.now
+		} else {
+			pending = append(pending, w)
+		}
+	}
+	f.waiters = pending
+}
+
+// useFakeClock installs a fake clock for the duration of a test
+func useFakeClock(t *testing.T, now time.Time) *fakeClock {
+	fake := newFakeClock(now)
+	previous := clock
+	clock = fake
+	t.Cleanup(func() { clock = previous })
+	return fake
+}
+
+func TestHandlersUseInjectedClock(t *testing.T) {
+	events = make(map[string]Event)
+	fixed := time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC)
+	useFakeClock(t, fixed)
+
+	router := setupRouter()
+
+	reqBody, _ := json.Marshal(CreateEventRequest{
+		Title:            "Team Meeting",
+		OrganizerID:      "user1",
+		RequiredDuration: 60,
+	})
+	req, _ := http.NewRequest("POST", "/api/v1/events", bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response Event
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, fixed.Equal(response.CreatedAt))
+	assert.True(t, fixed.Equal(response.UpdatedAt))
+}
+
+func TestJobSchedulerRunsDueJobs(t *testing.T) {
+	fake := newFakeClock(time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	scheduler := newJobScheduler(fake, time.Minute)
+
+	var runs []time.Time
+	scheduler.Register("reminders", 10*time.Minute, func(now time.Time) {
+		runs = append(runs, now)
+	})
+
+	assert.Empty(t, scheduler.RunDue())
+
+	fake.Advance(9 * time.Minute)
+	assert.Empty(t, scheduler.RunDue())
+
+	fake.Advance(time.Minute)
+	assert.Equal(t, []string{"reminders"}, scheduler.RunDue())
+	assert.Len(t, runs, 1)
+
+	// The next run is scheduled one interval after the last one
+	fake.Advance(5 * time.Minute)
+	assert.Empty(t, scheduler.RunDue())
+	fake.Advance(5 * time.Minute)
+	assert.Equal(t, []string{"reminders"}, scheduler.RunDue())
+	assert.Len(t, runs, 2)
+}
+
+func TestJobSchedulerSurvivesPanics(t *testing.T) {
+	fake := newFakeClock(time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	scheduler := newJobScheduler(fake, time.Minute)
+
+	ran := false
+	scheduler.Register("broken", time.Minute, func(time.Time) { panic("boom") })
+	scheduler.Register("healthy", time.Minute, func(time.Time) { ran = true })
+
+	fake.Advance(time.Minute)
+	assert.Equal(t, []string{"broken", "healthy"}, scheduler.RunDue())
+	assert.True(t, ran)
+}
@@ -2,12 +2,15 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 // Domain Models
@@ -16,10 +19,37 @@ type Event struct {
 	Title            string    `json:"title" binding:"required"`
 	Description      string    `json:"description"`
 	OrganizerID      string    `json:"organizerId" binding:"required"`
+	OrgID            string    `json:"orgId,omitempty"`
 	RequiredDuration int       `json:"requiredDuration" binding:"required"` // in minutes
+	DurationSeconds  int       `json:"durationSeconds,omitempty"`
+	MinNoticeMinutes int       `json:"minNoticeMinutes,omitempty"`
 	Status           string    `json:"status"`
+	FinalTimeSlotID  string    `json:"finalTimeslotId,omitempty"`
 	CreatedAt        time.Time `json:"createdAt"`
 	UpdatedAt        time.Time `json:"updatedAt"`
+
+	// AllowProtectedWindows is set by admins to schedule over lunch/focus time
+	AllowProtectedWindows bool `json:"allowProtectedWindows,omitempty"`
+	// Priority is low, normal (the default) or high; only admins change it
+	Priority string `json:"priority,omitempty"`
+	// Invitees count towards recommendations even before they respond
+	Invitees []string `json:"invitees,omitempty"`
+	// MeetingTypeID is the meeting type the event's defaults came from
+	MeetingTypeID string `json:"meetingTypeId,omitempty"`
+	// BufferMinutes keeps participants' other meetings this far away
+	BufferMinutes int    `json:"bufferMinutes,omitempty"`
+	LocationMode  string `json:"locationMode,omitempty"`
+	Location      string `json:"location,omitempty"`
+	// Questions are asked of participants alongside their availability
+	Questions []Question `json:"questions,omitempty"`
+	// DependsOn lists events this one must be scheduled after
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// WorkspaceID is the workspace the event belongs to, if any
+	WorkspaceID string `json:"workspaceId,omitempty"`
+	// Settings override the organizer's defaults for this event
+	Settings *EventSettings `json:"settings,omitempty"`
+	// LegalHold, set by admins, keeps the event from being deleted
+	LegalHold *LegalHold `json:"legalHold,omitempty"`
 }
 
 type TimeSlot struct {
@@ -32,28 +62,84 @@ type TimeSlot struct {
 }
 
 type UserAvailability struct {
-	ID         string    `json:"id"`
-	UserID     string    `json:"userId"`
-	EventID    string    `json:"eventId"`
-	TimeSlotID string    `json:"timeslotId" binding:"required"`
-	Status     string    `json:"status" binding:"required"`
-	CreatedAt  time.Time `json:"createdAt"`
-	UpdatedAt  time.Time `json:"updatedAt"`
+	ID         string `json:"id"`
+	UserID     string `json:"userId"`
+	EventID    string `json:"eventId"`
+	TimeSlotID string `json:"timeslotId" binding:"required"`
+	Status     string `json:"status" binding:"required"`
+	// ConditionalOn makes an available response hold only if that user
+	// attends too
+	ConditionalOn string `json:"conditionalOn,omitempty"`
+	// AvailableFrom and AvailableUntil narrow an available response to
+	// part of the slot
+	AvailableFrom  *time.Time `json:"availableFrom,omitempty"`
+	AvailableUntil *time.Time `json:"availableUntil,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
 }
 
 type Recommendation struct {
-	TimeSlot              TimeSlot `json:"timeslot"`
-	AvailableUsers        []string `json:"availableUsers"`
-	UnavailableUsers      []string `json:"unavailableUsers"`
+	TimeSlot               TimeSlot `json:"timeslot"`
+	AvailableUsers         []string `json:"availableUsers"`
+	UnavailableUsers       []string `json:"unavailableUsers"`
 	AvailabilityPercentage float64  `json:"availabilityPercentage"`
+	// Score ranks recommendations: the availability percentage less any
+	// penalties, such as for protected windows
+	Score float64 `json:"score"`
+	// Explanation breaks Score down into its parts
+	Explanation ScoreExplanation `json:"explanation"`
+	// SoftUnavailableUsers have reached their daily meeting cap that day
+	SoftUnavailableUsers []string `json:"softUnavailableUsers,omitempty"`
+	// Warnings lists scheduling rules the slot breaks, such as blackouts
+	Warnings []string `json:"warnings,omitempty"`
+	// MeetingsToMove lists lower-priority meetings a high-priority event
+	// would displace at this time
+	MeetingsToMove []MeetingToMove `json:"meetingsToMove,omitempty"`
+	// ConditionalUsers are available only because the people they
+	// depend on are
+	ConditionalUsers []string `json:"conditionalUsers,omitempty"`
+	// Window is where in the slot to hold the meeting, set when some
+	// participants can only make part of it
+	Window         *AttendanceWindow `json:"window,omitempty"`
+	PeakAttendance int               `json:"peakAttendance,omitempty"`
+}
+
+// MeetingToMove is a participant's meeting that overlaps a recommendation
+type MeetingToMove struct {
+	EventID  string   `json:"eventId"`
+	Title    string   `json:"title"`
+	Priority string   `json:"priority,omitempty"`
+	UserIDs  []string `json:"userIds"`
 }
 
 // Request/Response models
 type CreateEventRequest struct {
-	Title            string `json:"title" binding:"required"`
-	Description      string `json:"description"`
-	OrganizerID      string `json:"organizerId" binding:"required"`
-	RequiredDuration int    `json:"requiredDuration" binding:"required"`
+	Title       string `json:"title" binding:"required"`
+	Description string `json:"description"`
+	OrganizerID string `json:"organizerId" binding:"required"`
+	// RequiredDuration may be left out when a meeting type supplies it
+	RequiredDuration int `json:"requiredDuration"`
+	// Duration is an ISO-8601 alternative to RequiredDuration for lengths
+	// that aren't whole minutes, e.g. PT90S
+	Duration        string `json:"duration"`
+	DurationSeconds int    `json:"-"`
+	// MinNoticeMinutes keeps slots from being proposed too close to now
+	MinNoticeMinutes int      `json:"minNoticeMinutes"`
+	Invitees         []string `json:"invitees"`
+	// InviteEmails invites people by address; those without an account
+	// get a guest one
+	InviteEmails []string `json:"inviteEmails,omitempty"`
+	// MeetingTypeID fills in the duration, buffer and location when unset
+	MeetingTypeID string     `json:"meetingTypeId"`
+	BufferMinutes int        `json:"bufferMinutes"`
+	LocationMode  string     `json:"locationMode"`
+	Location      string     `json:"location"`
+	Questions     []Question `json:"questions"`
+	DependsOn     []string   `json:"dependsOn"`
+	// WorkspaceID fills in the workspace's defaults and participants
+	WorkspaceID string `json:"workspaceId"`
+	// Settings override the organizer's defaults for this event
+	Settings *EventSettings `json:"settings,omitempty"`
 }
 
 type CreateTimeSlotRequest struct {
@@ -64,10 +150,23 @@ type CreateTimeSlotRequest struct {
 type UserAvailabilityRequest struct {
 	TimeSlotID string `json:"timeslotId" binding:"required"`
 	Status     string `json:"status" binding:"required,oneof=available unavailable"`
+	// Answers to the event's questions, keyed by question ID
+	Answers        map[string]string `json:"answers,omitempty"`
+	ConditionalOn  string            `json:"conditionalOn,omitempty"`
+	AvailableFrom  *time.Time        `json:"availableFrom,omitempty"`
+	AvailableUntil *time.Time        `json:"availableUntil,omitempty"`
+}
+
+type FinalizeEventRequest struct {
+	TimeSlotID string `json:"timeslotId" binding:"required"`
+	// ReplaceTimeSlotID is the final slot a finalized event is being moved
+	// from. It guards against overwriting someone else's choice.
+	ReplaceTimeSlotID string `json:"replaceTimeslotId,omitempty"`
 }
 
 type RecommendationsResponse struct {
-	Recommendations []Recommendation `json:"recommendations"`
+	Recommendations []Recommendation       `json:"recommendations"`
+	Analysis        RecommendationAnalysis `json:"analysis"`
 }
 
 // In-memory storage (would use a database in production)
@@ -76,401 +175,594 @@ var timeSlots = make(map[string]TimeSlot)
 var userAvailability = make(map[string]UserAvailability)
 
 func main() {
+	migrateOnly := flag.Bool("migrate", false, "apply database migrations and exit")
+	flag.Parse()
+
+	// Schema migrations, applied by --migrate or before serving when
+	// MIGRATE_ON_START is set
+	var err error
+	if migrations, err = migratorFromEnv(); err != nil {
+		log.Fatalf("Failed to configure migrations: %v", err)
+	}
+	if *migrateOnly && migrations == nil {
+		log.Fatal("--migrate needs DATABASE_URL")
+	}
+	if *migrateOnly || (migrations != nil && getenvBool("MIGRATE_ON_START", false)) {
+		status, err := migrations.Up()
+		if err != nil {
+			log.Fatalf("Failed to apply migrations: %v", err)
+		}
+		log.Printf("Database is at migration %d of %d", status.Version, status.Latest)
+		if *migrateOnly {
+			return
+		}
+	}
+	if store, err = storeFromEnv(); err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	if logged, ok := store.(*eventSourcedStore); ok {
+		// The log is the source of truth for timelines too
+		eventLog = logged
+		logged.Redeliver(recordActivity)
+	}
+	// A change feed of every committed transaction, for warm standbys
+	if changes = changeFeedFromEnv(); changes != nil {
+		store = newFeedStore(store, changes)
+	}
+	// Lockouts, sessions, two-factor enrollments and the impersonation log
+	// are shared through the database when there is one
+	throttles = throttleStoreFor(store)
+	sessions = &sessionRegistry{store: sessionStoreFor(store)}
+	twoFactors = &twoFactorRegistry{store: twoFactorStoreFor(store)}
+	impersonations = &impersonationLog{store: impersonationStoreFor(store)}
+
+	if path := getenv("ORGANIZATIONS_FILE", ""); path != "" {
+		if err := loadOrganizations(organizations, path); err != nil {
+			log.Fatalf("Failed to load organizations: %v", err)
+		}
+	}
+	if path := getenv("FEATURE_FLAGS_FILE", ""); path != "" {
+		if err := loadFeatureFlags(featureFlags, path); err != nil {
+			log.Fatalf("Failed to load feature flags: %v", err)
+		}
+	}
+	if path := getenv("POLICY_FILE", ""); path != "" {
+		if policy, err = loadPolicy(path); err != nil {
+			log.Fatalf("Failed to load authorization policy: %v", err)
+		}
+	}
+	if path := getenv("SERVICE_IDENTITIES_FILE", ""); path != "" {
+		if err := loadServiceIdentities(serviceIdentities, path); err != nil {
+			log.Fatalf("Failed to load service identities: %v", err)
+		}
+	}
+
+	maintenance = maintenanceFromEnv()
+	materialized = recommendationViewsFromEnv()
+	// A standby stays read-only, following the primary, until promoted
+	if standby = standbyFromEnv(); standby != nil {
+		maintenance.Set(MaintenanceStatus{ReadOnly: true, Message: standbyMaintenance}, clock.Now())
+		go standby.Run()
+	}
+	// Personal data is masked in logs, gin's request logs included
+	if getenvBool("REDACT_LOGS", true) {
+		log.SetOutput(redactingWriter{w: os.Stderr})
+		gin.DefaultWriter = redactingWriter{w: os.Stdout}
+		gin.DefaultErrorWriter = redactingWriter{w: os.Stderr}
+	}
 	router := gin.Default()
+	if err := configureProxies(router); err != nil {
+		log.Fatalf("Failed to configure trusted proxies: %v", err)
+	}
+	registerRoutes(router)
+	smsSender = smsSenderFromEnv()
+	pushSenders = pushSendersFromEnv()
+	notifier = notifierFromEnv()
+	registerSubscribers(bus)
 
-	// Event endpoints
-	router.POST("/api/v1/events", createEvent)
-	router.GET("/api/v1/events", listEvents)
-	router.GET("/api/v1/events/:eventId", getEvent)
-	router.PUT("/api/v1/events/:eventId", updateEvent)
-	router.DELETE("/api/v1/events/:eventId", deleteEvent)
+	// Optional event streaming to Kafka/NATS
+	publisher, err := brokerFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to connect to message broker: %v", err)
+	}
+	if publisher != nil {
+		forwarder := newBrokerForwarder(publisher, getenv("BROKER_SUBJECT_PREFIX", "scheduler"), getenvInt("BROKER_BUFFER", 1024))
+		defer forwarder.Close()
+		bus.SubscribeAll(forwarder.Enqueue)
+	}
 
-	// TimeSlot endpoints
-	router.POST("/api/v1/events/:eventId/timeslots", createTimeSlot)
-	router.GET("/api/v1/events/:eventId/timeslots", listTimeSlots)
-	router.PUT("/api/v1/events/:eventId/timeslots/:timeslotId", updateTimeSlot)
-	router.DELETE("/api/v1/events/:eventId/timeslots/:timeslotId", deleteTimeSlot)
+	// Signed webhook deliveries to organizations' registered endpoints
+	dispatcher := newWebhookDispatcher(webhooks, webhookDeliveries, getenvInt("WEBHOOK_BUFFER", 1024))
+	defer dispatcher.Close()
+	bus.SubscribeAll(dispatcher.Enqueue)
 
-	// UserAvailability endpoints
-	router.POST("/api/v1/events/:eventId/users/:userId/availability", createUserAvailability)
-	router.GET("/api/v1/events/:eventId/users/:userId/availability", getUserAvailability)
-	router.PUT("/api/v1/events/:eventId/users/:userId/availability/:timeslotId", updateUserAvailability)
-	router.DELETE("/api/v1/events/:eventId/users/:userId/availability/:timeslotId", deleteUserAvailability)
+	// Large notification runs go out in batches from the background
+	fanOut = newFanOutQueue(getenvInt("FANOUT_BUFFER", 256))
+	defer fanOut.Close()
 
-	// Recommendations endpoint
-	router.GET("/api/v1/events/:eventId/recommendations", getRecommendations)
+	// Background jobs run for the lifetime of the process
+	registerJobs(jobs)
+	jobs.Start(nil)
 
 	// Start the server
-	if err := router.Run(":8080"); err != nil {
+	if err := serve(router); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
 
+// registerRoutes wires every API endpoint onto the router
+func registerRoutes(router *gin.Engine) {
+	router.Use(setSecurityHeaders, compressResponses, envelopeResponses, selectFields, enforceMaintenance)
+	// Who may call each route is set out in policy.json
+	api := router.Group("/api/v1", authenticate, recordHeldAccess, recordImpersonation, authorize)
+	// Signed links and logins lock out clients that keep failing
+	guarded := throttle(throttleLimitsFromEnv())
+
+	// Authentication endpoints
+	api.GET("/auth/sso/:orgId/login", guarded, oidcLogin)
+	api.GET("/auth/sso/:orgId/callback", guarded, oidcCallback)
+	api.POST("/auth/refresh", guarded, refreshSession)
+	api.POST("/auth/two-factor", guarded, forbidImpersonation, verifyTwoFactor)
+	api.GET("/users/me", getMe)
+	api.GET("/users/me/export", exportMyData)
+	api.POST("/users/me/consent", recordMyConsent)
+	api.PUT("/users/me/settings", updateMySettings)
+	api.PUT("/users/me/pins/:eventId", pinEvent)
+	api.DELETE("/users/me/pins/:eventId", unpinEvent)
+	api.PUT("/users/me/phone", forbidImpersonation, setMyPhone)
+	api.POST("/users/me/phone/verify", guarded, forbidImpersonation, verifyMyPhone)
+	api.DELETE("/users/me/phone", forbidImpersonation, deleteMyPhone)
+	api.GET("/users/me/devices", listMyDevices)
+	api.POST("/users/me/devices", forbidImpersonation, registerMyDevice)
+	api.DELETE("/users/me/devices/:deviceId", forbidImpersonation, deleteMyDevice)
+	api.GET("/users/me/sessions", listMySessions)
+	api.DELETE("/users/me/sessions/:sessionId", forbidImpersonation, revokeMySession)
+	api.POST("/users/me/two-factor", forbidImpersonation, enrollTwoFactor)
+	api.DELETE("/users/me/two-factor", forbidImpersonation, disableTwoFactor)
+	api.GET("/push/vapid-public-key", getVAPIDPublicKey)
+	api.GET("/users/me/flags", listMyFlags)
+	api.GET("/maintenance", getMaintenance)
+	api.PUT("/maintenance", putMaintenance)
+	api.GET("/users/:userId/notifications", listNotifications)
+	api.POST("/users/:userId/notifications/read", markAllNotificationsRead)
+	api.POST("/users/:userId/notifications/:notificationId/read", markNotificationRead)
+	api.GET("/users/me/calendars", listCalendarConnections)
+	api.POST("/users/me/calendars", createCalendarConnection)
+	api.DELETE("/users/me/calendars/:connectionId", deleteCalendarConnection)
+	api.GET("/users/me/booking-page", requireFeature(FlagBookingPages), getMyBookingPage)
+	api.PUT("/users/me/booking-page", requireFeature(FlagBookingPages), putMyBookingPage)
+	api.DELETE("/users/me/booking-page", requireFeature(FlagBookingPages), deleteMyBookingPage)
+
+	// Public booking endpoints, authorized by the page token
+	api.GET("/booking/:token", guarded, getPublicBookingPage)
+	api.GET("/booking/:token/slots", guarded, listBookingSlots)
+	api.POST("/booking/:token", guarded, requireChallenge, bookSlot)
+
+	// Guest invitation links, authorized by their token
+	api.GET("/invites/:token", guarded, redeemGuestInvite)
+
+	// Organization endpoints
+	api.GET("/organizations/:orgId", getOrganization)
+	api.GET("/organizations/:orgId/users", searchDirectory)
+	api.GET("/organizations/:orgId/users/:userId/export", exportMemberData)
+	api.POST("/integrations/availability", pushAvailability)
+	api.POST("/planning/windows", findWindows)
+	api.POST("/schedule", scheduleMeeting)
+	api.GET("/meeting-types", listMeetingTypes)
+	api.POST("/meeting-types", createMeetingType)
+	api.GET("/meeting-types/:typeId", getMeetingType)
+	api.PUT("/meeting-types/:typeId", updateMeetingType)
+	api.DELETE("/meeting-types/:typeId", deleteMeetingType)
+	api.GET("/workspaces", listWorkspaces)
+	api.POST("/workspaces", createWorkspace)
+	api.GET("/workspaces/:workspaceId", getWorkspace)
+	api.PUT("/workspaces/:workspaceId", updateWorkspace)
+	api.DELETE("/workspaces/:workspaceId", deleteWorkspace)
+	api.GET("/workspaces/:workspaceId/agenda", getWorkspaceAgenda)
+	api.GET("/workspaces/:workspaceId/response-rates", getWorkspaceResponseRates)
+	api.GET("/pools", listPools)
+	api.POST("/pools", createPool)
+	api.DELETE("/pools/:poolId", deletePool)
+	api.POST("/pools/:poolId/claim", claimPoolSlot)
+	api.GET("/webhooks", listWebhooks)
+	api.POST("/webhooks", createWebhook)
+	api.GET("/webhooks/:webhookId", getWebhook)
+	api.DELETE("/webhooks/:webhookId", deleteWebhook)
+	api.POST("/webhooks/:webhookId/secret/rotate", rotateWebhookSecret)
+	api.GET("/webhooks/:webhookId/deliveries", listWebhookDeliveries)
+	api.POST("/webhooks/:webhookId/deliveries/:deliveryId/replay", replayWebhookDelivery)
+	api.GET("/organizations/:orgId/branding", getBranding)
+	api.PUT("/organizations/:orgId/branding", updateBranding)
+	api.GET("/organizations/:orgId/blackouts", listBlackouts)
+	api.POST("/organizations/:orgId/blackouts", createBlackout)
+	api.DELETE("/organizations/:orgId/blackouts/:blackoutId", deleteBlackout)
+	api.PUT("/organizations/:orgId/protected-windows", updateProtectedWindows)
+	api.PUT("/organizations/:orgId/slot-granularity", updateSlotGranularity)
+	api.PUT("/organizations/:orgId/guest-retention", updateGuestRetention)
+	api.GET("/organizations/:orgId/settings", getOrganizationSettings)
+	api.PUT("/organizations/:orgId/flags/:flag", setOrgFlag)
+	api.DELETE("/organizations/:orgId/flags/:flag", clearOrgFlag)
+	api.PUT("/organizations/:orgId/settings", updateOrganizationSettings)
+	api.PUT("/organizations/:orgId/redaction", updateRedactionPolicy)
+	api.PUT("/organizations/:orgId/challenge", updateChallengePolicy)
+	api.PUT("/organizations/:orgId/two-factor", updateTwoFactorPolicy)
+	api.GET("/organizations/:orgId/export", exportOrganization)
+
+	// Several API calls in one request
+	api.POST("/batch", batchHandler(router))
+
+	// Event endpoints
+	api.POST("/events", createEvent)
+	api.POST("/events/import", importEvent)
+	api.GET("/events", listEvents)
+	api.GET("/events/:eventId", getEvent)
+	api.PUT("/events/:eventId", updateEvent)
+	api.DELETE("/events/:eventId", requireTwoFactor, deleteEvent)
+	api.POST("/events/:eventId/finalize", finalizeEvent)
+	api.GET("/events/:eventId/ics", getEventICS)
+	api.GET("/events/:eventId/dependents", listDependents)
+	api.PUT("/events/:eventId/protected-windows-override", overrideProtectedWindows)
+	api.PUT("/events/:eventId/priority", setEventPriority)
+
+	api.GET("/suggestions/duration", suggestDuration)
+
+	// TimeSlot endpoints
+	api.POST("/events/:eventId/timeslots", createTimeSlot)
+	api.POST("/events/:eventId/timeslots/generate", generateTimeSlots)
+	api.POST("/events/:eventId/timeslots/parse", parseTimeSlots)
+	api.POST("/events/:eventId/timeslots/import", importTimeSlots)
+	api.GET("/events/:eventId/timeslots", listTimeSlots)
+	api.PUT("/events/:eventId/timeslots/:timeslotId", updateTimeSlot)
+	api.DELETE("/events/:eventId/timeslots/:timeslotId", deleteTimeSlot)
+
+	// UserAvailability endpoints
+	api.POST("/events/:eventId/users/:userId/availability", requireChallenge, requireGuestConsent, createUserAvailability)
+	api.GET("/events/:eventId/users/:userId/availability", getUserAvailability)
+	api.PUT("/events/:eventId/users/:userId/availability/:timeslotId", requireChallenge, requireGuestConsent, updateUserAvailability)
+	api.DELETE("/events/:eventId/users/:userId/availability/:timeslotId", deleteUserAvailability)
+	api.PUT("/events/:eventId/users/:userId/answers", requireChallenge, requireGuestConsent, answerQuestions)
+	api.GET("/events/:eventId/responses", listResponses)
+	api.GET("/events/:eventId/progress", getEventProgress)
+	api.GET("/events/:eventId/alerts", listQuorumAlerts)
+	api.POST("/events/:eventId/alerts", createQuorumAlert)
+	api.DELETE("/events/:eventId/alerts/:alertId", deleteQuorumAlert)
+	api.GET("/events/:eventId/settings", getEventSettings)
+	api.GET("/events/:eventId/comments", listComments)
+	api.POST("/events/:eventId/comments", createComment)
+	api.POST("/events/:eventId/broadcast", broadcastEvent)
+	api.GET("/events/:eventId/invites", listGuestInvites)
+	api.GET("/events/:eventId/timeline", getEventTimeline)
+	api.GET("/events/:eventId/history", getEventHistory)
+	api.GET("/events/:eventId/presence", getPresence)
+	api.PUT("/events/:eventId/presence", putPresence)
+	api.DELETE("/events/:eventId/presence", deletePresence)
+	api.GET("/events/:eventId/presence/stream", streamPresence)
+	api.GET("/events/:eventId/availability/export", exportAvailability)
+	api.GET("/events/:eventId/export", exportEvent)
+	api.PUT("/events/:eventId/legal-hold", placeLegalHold)
+	api.DELETE("/events/:eventId/legal-hold", releaseLegalHold)
+	api.GET("/events/:eventId/legal-hold/access", listHeldAccess)
+	api.POST("/events/:eventId/users/:userId/availability/sync", syncAvailability)
+
+	// Trash endpoints
+	api.GET("/trash", listTrash)
+	api.POST("/trash/:id/restore", restoreTrashItem)
+
+	// Recommendations endpoints, which shed load when they slow down
+	expensive := shedLoad(loadShedderFromEnv())
+	api.GET("/events/:eventId/recommendations", expensive, getRecommendations)
+	api.POST("/events/:eventId/recommendations/simulate", expensive, simulateRecommendations)
+	api.GET("/events/:eventId/overlap", expensive, getOverlapMatrix)
+
+	// Admin endpoints
+	api.GET("/admin/analytics", getAnalytics)
+	api.GET("/admin/storage", getStoreOccupancy)
+	api.GET("/admin/migrations", getMigrations)
+	api.POST("/admin/migrations", applyMigrations)
+	api.GET("/admin/replication", getReplicationStatus)
+	api.POST("/admin/replication/promote", promoteStandby)
+	api.GET("/admin/impersonations", listImpersonations)
+	api.POST("/admin/impersonations", startImpersonation)
+	api.DELETE("/admin/impersonations/:sessionId", endImpersonation)
+
+	// Server-rendered participant pages
+	router.GET("/poll/:eventId", getPollPage)
+
+	// Change feed for standbys, authenticated by REPLICATION_TOKEN
+	replication := router.Group("/replication", replicationAuth)
+	replication.GET("/changes", listReplicatedChanges)
+
+	// SCIM 2.0 provisioning, authenticated by the organization's SCIM token
+	scim := router.Group("/scim/v2/:orgId", scimAuth)
+	scim.GET("/Users", scimListUsers)
+	scim.POST("/Users", scimCreateUser)
+	scim.GET("/Users/:id", scimGetUser)
+	scim.PUT("/Users/:id", scimReplaceUser)
+	scim.PATCH("/Users/:id", scimPatchUser)
+	scim.DELETE("/Users/:id", scimDeleteUser)
+	scim.GET("/Groups", scimListGroups)
+	scim.POST("/Groups", scimCreateGroup)
+	scim.GET("/Groups/:id", scimGetGroup)
+	scim.PUT("/Groups/:id", scimReplaceGroup)
+	scim.PATCH("/Groups/:id", scimPatchGroup)
+	scim.DELETE("/Groups/:id", scimDeleteGroup)
+}
+
+// respondError maps a service error onto the API's error shape
+func respondError(c *gin.Context, err error) {
+	var validationErr *ValidationError
+	switch {
+	case errors.Is(err, ErrEventNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+	case errors.Is(err, ErrTimeSlotNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Time slot not found"})
+	case errors.Is(err, ErrAvailabilityNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Availability record not found"})
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Message})
+	case errors.Is(err, context.DeadlineExceeded):
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Timed out waiting for storage"})
+	case errors.Is(err, context.Canceled):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Request cancelled"})
+	case errors.Is(err, ErrLegalHold):
+		c.JSON(http.StatusConflict, gin.H{"error": "Event is under legal hold"})
+	case errors.Is(err, ErrStoreFull):
+		c.JSON(http.StatusInsufficientStorage, gin.H{"error": "Storage is full; finalize or cancel events to free space"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": redactErrorReport(err)})
+	}
+}
+
 // Event handlers
 func createEvent(c *gin.Context) {
 	var req CreateEventRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	now := time.Now()
-	event := Event{
-		ID:               uuid.New().String(),
-		Title:            req.Title,
-		Description:      req.Description,
-		OrganizerID:      req.OrganizerID,
-		RequiredDuration: req.RequiredDuration,
-		Status:           "active",
-		CreatedAt:        now,
-		UpdatedAt:        now,
+	event, err := requestScheduler(c).CreateEvent(req)
+	if err != nil {
+		respondError(c, err)
+		return
 	}
-
-	events[event.ID] = event
+	warnLargeEvent(c, event)
 	c.JSON(http.StatusCreated, event)
 }
 
 func listEvents(c *gin.Context) {
-	var eventList []Event
-	for _, event := range events {
-		eventList = append(eventList, event)
+	eventList, err := contextScheduler(c).ListEvents()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if c.Query("pinned") == "true" {
+		user, ok := currentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+		eventList = pinnedOnly(eventList, user)
 	}
 	c.JSON(http.StatusOK, eventList)
 }
 
 func getEvent(c *gin.Context) {
-	eventID := c.Param("eventId")
-	event, exists := events[eventID]
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+	event, err := contextScheduler(c).GetEvent(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, event)
 }
 
 func updateEvent(c *gin.Context) {
+	scheduler := requestScheduler(c)
 	eventID := c.Param("eventId")
-	_, exists := events[eventID]
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+	existing, err := scheduler.GetEvent(eventID)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
 	var req CreateEventRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// Handing the event to another organizer needs a second factor
+	if req.OrganizerID != existing.OrganizerID && !checkTwoFactor(c) {
+		return
+	}
 
-	event := events[eventID]
-	event.Title = req.Title
-	event.Description = req.Description
-	event.OrganizerID = req.OrganizerID
-	event.RequiredDuration = req.RequiredDuration
-	event.UpdatedAt = time.Now()
-	
-	events[eventID] = event
+	event, err := scheduler.UpdateEvent(eventID, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	warnLargeEvent(c, event)
 	c.JSON(http.StatusOK, event)
 }
 
 func deleteEvent(c *gin.Context) {
-	eventID := c.Param("eventId")
-	_, exists := events[eventID]
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+	if err := requestScheduler(c).DeleteEvent(c.Param("eventId")); err != nil {
+		respondError(c, err)
 		return
 	}
-
-	delete(events, eventID)
 	c.JSON(http.StatusNoContent, nil)
 }
 
+func finalizeEvent(c *gin.Context) {
+	var req FinalizeEventRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := requestScheduler(c).FinalizeEvent(c.Param("eventId"), req)
+	if errors.Is(err, ErrBeforePrerequisite) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Time slot starts before a prerequisite event ends"})
+		return
+	}
+	var conflict *FinalizeConflictError
+	if errors.As(err, &conflict) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "Event was already finalized at another time slot",
+			"event":         conflict.Event,
+			"finalTimeslot": conflict.TimeSlot,
+		})
+		return
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, event)
+}
+
 // TimeSlot handlers
 func createTimeSlot(c *gin.Context) {
+	scheduler := requestScheduler(c)
 	eventID := c.Param("eventId")
-	_, exists := events[eventID]
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+	if _, err := scheduler.GetEvent(eventID); err != nil {
+		respondError(c, err)
 		return
 	}
 
 	var req CreateTimeSlotRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Validate time range
-	if req.EndTime.Before(req.StartTime) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "End time must be after start time"})
+	timeSlot, err := scheduler.CreateTimeSlot(eventID, req)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
-
-	now := time.Now()
-	timeSlot := TimeSlot{
-		ID:        uuid.New().String(),
-		EventID:   eventID,
-		StartTime: req.StartTime,
-		EndTime:   req.EndTime,
-		CreatedAt: now,
-		UpdatedAt: now,
-	}
-
-	timeSlots[timeSlot.ID] = timeSlot
 	c.JSON(http.StatusCreated, timeSlot)
 }
 
 func listTimeSlots(c *gin.Context) {
-	eventID := c.Param("eventId")
-	var slotList []TimeSlot
-	for _, slot := range timeSlots {
-		if slot.EventID == eventID {
-			slotList = append(slotList, slot)
-		}
+	slotList, err := contextScheduler(c).ListTimeSlots(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
+		return
 	}
 	c.JSON(http.StatusOK, slotList)
 }
 
 func updateTimeSlot(c *gin.Context) {
+	scheduler := requestScheduler(c)
 	timeslotID := c.Param("timeslotId")
-	slot, exists := timeSlots[timeslotID]
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Time slot not found"})
+	if _, err := scheduler.GetTimeSlot(timeslotID); err != nil {
+		respondError(c, err)
 		return
 	}
 
 	var req CreateTimeSlotRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Validate time range
-	if req.EndTime.Before(req.StartTime) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "End time must be after start time"})
+	slot, err := scheduler.UpdateTimeSlot(timeslotID, req)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
-
-	slot.StartTime = req.StartTime
-	slot.EndTime = req.EndTime
-	slot.UpdatedAt = time.Now()
-	
-	timeSlots[timeslotID] = slot
 	c.JSON(http.StatusOK, slot)
 }
 
 func deleteTimeSlot(c *gin.Context) {
-	timeslotID := c.Param("timeslotId")
-	_, exists := timeSlots[timeslotID]
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Time slot not found"})
+	if err := requestScheduler(c).DeleteTimeSlot(c.Param("timeslotId")); err != nil {
+		respondError(c, err)
 		return
 	}
-
-	delete(timeSlots, timeslotID)
 	c.JSON(http.StatusNoContent, nil)
 }
 
 // UserAvailability handlers
 func createUserAvailability(c *gin.Context) {
+	scheduler := requestScheduler(c)
 	eventID := c.Param("eventId")
-	userID := c.Param("userId")
-	
-	_, eventExists := events[eventID]
-	if !eventExists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+	if _, err := scheduler.GetEvent(eventID); err != nil {
+		respondError(c, err)
 		return
 	}
 
 	var req UserAvailabilityRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	
-	_, slotExists := timeSlots[req.TimeSlotID]
-	if !slotExists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Time slot not found"})
-		return
-	}
 
-	now := time.Now()
-	availability := UserAvailability{
-		ID:         uuid.New().String(),
-		UserID:     userID,
-		EventID:    eventID,
-		TimeSlotID: req.TimeSlotID,
-		Status:     req.Status,
-		CreatedAt:  now,
-		UpdatedAt:  now,
+	availability, err := scheduler.SubmitAvailability(eventID, c.Param("userId"), req)
+	if err != nil {
+		respondError(c, err)
+		return
 	}
-
-	userAvailability[availability.ID] = availability
 	c.JSON(http.StatusCreated, availability)
 }
 
 func getUserAvailability(c *gin.Context) {
-	eventID := c.Param("eventId")
-	userID := c.Param("userId")
-	
-	var availabilityList []UserAvailability
-	for _, avail := range userAvailability {
-		if avail.EventID == eventID && avail.UserID == userID {
-			availabilityList = append(availabilityList, avail)
-		}
+	availabilityList, err := contextScheduler(c).ListUserAvailability(c.Param("eventId"), c.Param("userId"))
+	if err != nil {
+		respondError(c, err)
+		return
 	}
 	c.JSON(http.StatusOK, availabilityList)
 }
 
 func updateUserAvailability(c *gin.Context) {
-	eventID := c.Param("eventId")
-	userID := c.Param("userId")
-	timeslotID := c.Param("timeslotId")
-	
-	// Find the availability record
-	var targetAvail UserAvailability
-	var found bool
-	
-	for _, avail := range userAvailability {
-		if avail.EventID == eventID && avail.UserID == userID && avail.TimeSlotID == timeslotID {
-			targetAvail = avail
-			found = true
-			break
-		}
-	}
-	
-	if !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Availability record not found"})
+	scheduler := requestScheduler(c)
+	eventID, userID, timeslotID := c.Param("eventId"), c.Param("userId"), c.Param("timeslotId")
+	if _, err := scheduler.GetAvailability(eventID, userID, timeslotID); err != nil {
+		respondError(c, err)
 		return
 	}
-	
+
 	var req UserAvailabilityRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	
-	// Update the record
-	targetAvail.Status = req.Status
-	targetAvail.UpdatedAt = time.Now()
-	
-	userAvailability[targetAvail.ID] = targetAvail
-	c.JSON(http.StatusOK, targetAvail)
+
+	avail, err := scheduler.UpdateAvailability(eventID, userID, timeslotID, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, avail)
 }
 
 func deleteUserAvailability(c *gin.Context) {
-	eventID := c.Param("eventId")
-	userID := c.Param("userId")
-	timeslotID := c.Param("timeslotId")
-	
-	// Find the availability record
-	var targetID string
-	var found bool
-	
-	for id, avail := range userAvailability {
-		if avail.EventID == eventID && avail.UserID == userID && avail.TimeSlotID == timeslotID {
-			targetID = id
-			found = true
-			break
-		}
-	}
-	
-	if !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Availability record not found"})
+	if err := requestScheduler(c).DeleteAvailability(c.Param("eventId"), c.Param("userId"), c.Param("timeslotId")); err != nil {
+		respondError(c, err)
 		return
 	}
-	
-	delete(userAvailability, targetID)
 	c.JSON(http.StatusNoContent, nil)
 }
 
 // Recommendation handler
 func getRecommendations(c *gin.Context) {
-	eventID := c.Param("eventId")
-	event, exists := events[eventID]
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
-		return
-	}
-	
-	// Get all time slots for this event
-	var eventSlots []TimeSlot
-	for _, slot := range timeSlots {
-		if slot.EventID == eventID {
-			eventSlots = append(eventSlots, slot)
+	// Seeded orderings and batches, which may hold uncommitted changes,
+	// are always computed afresh
+	_, inBatch := c.Request.Context().Value(batchTxKey{}).(batchTx)
+	if materialized != nil && c.Query("seed") == "" && !inBatch {
+		view, err := materialized.Get(c.Param("eventId"), contextScheduler(c))
+		if err != nil {
+			respondError(c, err)
+			return
 		}
-	}
-	
-	if len(eventSlots) == 0 {
-		c.JSON(http.StatusOK, RecommendationsResponse{Recommendations: []Recommendation{}})
+		c.Header("X-Recommendations-Computed-At", view.ComputedAt.Format(time.RFC3339))
+		c.JSON(http.StatusOK, view.Response)
 		return
 	}
-	
-	// Get all unique users for this event
-	uniqueUsers := make(map[string]bool)
-	for _, avail := range userAvailability {
-		if avail.EventID == eventID {
-			uniqueUsers[avail.UserID] = true
-		}
-	}
-	
-	// If no users have provided availability
-	if len(uniqueUsers) == 0 {
-		c.JSON(http.StatusOK, RecommendationsResponse{Recommendations: []Recommendation{}})
+	recommendations, err := contextScheduler(c).WithTieSeed(c.Query("seed")).Recommendations(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
 		return
 	}
-	
-	// For each time slot, calculate user availability
-	var recommendations []Recommendation
-	for _, slot := range eventSlots {
-		// Check if slot duration is sufficient for the meeting
-		slotDuration := slot.EndTime.Sub(slot.StartTime).Minutes()
-		if slotDuration < float64(event.RequiredDuration) {
-			continue // Skip slots that are too short
-		}
-		
-		var availableUsers []string
-		var unavailableUsers []string
-		
-		// For each user, check if they've indicated availability for this slot
-		for userID := range uniqueUsers {
-			isAvailable := false
-			
-			// Check if user has explicitly marked availability for this slot
-			for _, avail := range userAvailability {
-				if avail.EventID == eventID && avail.UserID == userID && avail.TimeSlotID == slot.ID && avail.Status == "available" {
-					isAvailable = true
-					break
-				}
-			}
-			
-			if isAvailable {
-				availableUsers = append(availableUsers, userID)
-			} else {
-				unavailableUsers = append(unavailableUsers, userID)
-			}
-		}
-		
-		availabilityPercentage := float64(len(availableUsers)) / float64(len(uniqueUsers)) * 100
-		
-		recommendations = append(recommendations, Recommendation{
-			TimeSlot:              slot,
-			AvailableUsers:        availableUsers,
-			UnavailableUsers:      unavailableUsers,
-			AvailabilityPercentage: availabilityPercentage,
-		})
-	}
-	
-	// Sort recommendations by availability percentage (highest first)
-	// In a real implementation, we'd use sort.Slice here
-	// This is a simplified bubble sort
-	for i := 0; i < len(recommendations); i++ {
-		for j := i + 1; j < len(recommendations); j++ {
-			if recommendations[i].AvailabilityPercentage < recommendations[j].AvailabilityPercentage {
-				recommendations[i], recommendations[j] = recommendations[j], recommendations[i]
-			}
-		}
-	}
-	
-	c.JSON(http.StatusOK, RecommendationsResponse{Recommendations: recommendations})
+	c.JSON(http.StatusOK, RecommendationsResponse{Recommendations: recommendations, Analysis: analyzeRecommendations(recommendations)})
 }
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Broadcast audiences
+const (
+	AudienceAll           = "all"
+	AudienceNonResponders = "non-responders"
+)
+
+// MessageBroadcast is the kind of organizer messages to participants
+const MessageBroadcast = "broadcast"
+
+// maxCommentLength keeps comments and broadcasts to a few paragraphs
+const maxCommentLength = 4000
+
+// Comment is an entry in an event's discussion history. Broadcasts are
+// recorded here too, with who they were sent to.
+type Comment struct {
+	ID        string     `json:"id"`
+	EventID   string     `json:"eventId"`
+	AuthorID  string     `json:"authorId"`
+	Body      string     `json:"body"`
+	Broadcast *Broadcast `json:"broadcast,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// Broadcast records an organizer message sent to participants
+type Broadcast struct {
+	Subject    string   `json:"subject"`
+	Audience   string   `json:"audience"`
+	Recipients []string `json:"recipients"`
+}
+
+type CommentRequest struct {
+	Body string `json:"body" binding:"required"`
+}
+
+type BroadcastRequest struct {
+	Subject string `json:"subject"`
+	Message string `json:"message" binding:"required"`
+	// Audience is all participants (the default) or only those who
+	// haven't responded
+	Audience string `json:"audience"`
+}
+
+// commentRegistry is the in-memory comment store, oldest first per event
+type commentRegistry struct {
+	mu       sync.RWMutex
+	comments map[string][]Comment
+}
+
+func newCommentRegistry() *commentRegistry {
+	return &commentRegistry{comments: make(map[string][]Comment)}
+}
+
+func (r *commentRegistry) Add(comment Comment) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.comments[comment.EventID] = append(r.comments[comment.EventID], comment)
+}
+
+func (r *commentRegistry) ForEvent(eventID string) []Comment {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Comment{}, r.comments[eventID]...)
+}
+
+// Forget drops a deleted event's comments
+func (r *commentRegistry) Forget(eventID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.comments, eventID)
+}
+
+var comments = newCommentRegistry()
+
+// broadcastRecipients are the event's participants other than the sender,
+// optionally only those without any response
+func broadcastRecipients(event Event, senderID, audience string) ([]User, error) {
+	participants, err := eventParticipants(event)
+	if err != nil {
+		return nil, err
+	}
+	responded := map[string]bool{}
+	if audience == AudienceNonResponders {
+		availabilityList, err := store.ListAvailability(event.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, avail := range availabilityList {
+			responded[avail.UserID] = true
+		}
+		responded[event.OrganizerID] = true
+	}
+	var recipients []User
+	for _, user := range participants {
+		if user.ID != senderID && !responded[user.ID] {
+			recipients = append(recipients, user)
+		}
+	}
+	return recipients, nil
+}
+
+// Comment handlers
+func listComments(c *gin.Context) {
+	eventID := c.Param("eventId")
+	if _, err := contextScheduler(c).GetEvent(eventID); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, comments.ForEvent(eventID))
+}
+
+func createComment(c *gin.Context) {
+	user, _ := currentUser(c)
+	eventID := c.Param("eventId")
+	if _, err := contextScheduler(c).GetEvent(eventID); err != nil {
+		respondError(c, err)
+		return
+	}
+	var req CommentRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	body := strings.TrimSpace(req.Body)
+	if body == "" || len(body) > maxCommentLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Comment must be between 1 and 4000 characters"})
+		return
+	}
+
+	comment := Comment{ID: uuid.New().String(), EventID: eventID, AuthorID: user.ID, Body: body, CreatedAt: clock.Now()}
+	comments.Add(comment)
+	c.JSON(http.StatusCreated, comment)
+}
+
+// broadcastEvent sends the organizer's message to the event's participants
+// over each one's notification channels and records it in the comments
+func broadcastEvent(c *gin.Context) {
+	user, _ := currentUser(c)
+	event, err := contextScheduler(c).GetEvent(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if user.ID != event.OrganizerID && !roleAtLeast(user.Role, RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the organizer can broadcast to participants"})
+		return
+	}
+	var req BroadcastRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Audience == "" {
+		req.Audience = AudienceAll
+	}
+	if req.Audience != AudienceAll && req.Audience != AudienceNonResponders {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Audience must be all or non-responders"})
+		return
+	}
+	body := strings.TrimSpace(req.Message)
+	if body == "" || len(body) > maxCommentLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Message must be between 1 and 4000 characters"})
+		return
+	}
+	subject := strings.TrimSpace(req.Subject)
+	if subject == "" {
+		subject = "Message about " + event.Title
+	}
+
+	recipients, err := broadcastRecipients(event, user.ID, req.Audience)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	sentTo := []string{}
+	messages := make([]Message, 0, len(recipients))
+	for _, recipient := range recipients {
+		messages = append(messages, Message{
+			To:      recipient,
+			OrgID:   event.OrgID,
+			EventID: event.ID,
+			Kind:    MessageBroadcast,
+			Subject: subject,
+			Body:    body,
+		})
+		sentTo = append(sentTo, recipient.ID)
+	}
+	notifyAll(messages)
+
+	comment := Comment{
+		ID:        uuid.New().String(),
+		EventID:   event.ID,
+		AuthorID:  user.ID,
+		Body:      body,
+		Broadcast: &Broadcast{Subject: subject, Audience: req.Audience, Recipients: sentTo},
+		CreatedAt: clock.Now(),
+	}
+	comments.Add(comment)
+	c.JSON(http.StatusCreated, comment)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroadcastReachesNonRespondersAndIsRecorded(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	recorder := &recordingNotifier{}
+	previous, previousComments := notifier, comments
+	notifier, comments = recorder, newCommentRegistry()
+	t.Cleanup(func() { notifier, comments = previous, previousComments })
+
+	ada := User{ID: "ada", OrgID: "acme", Role: RoleOrganizer}
+	eve := User{ID: "eve", OrgID: "acme", Role: RoleOrganizer}
+	for _, user := range []User{ada, eve, {ID: "bob", OrgID: "acme"}, {ID: "cy", OrgID: "acme"}} {
+		users.Save(user)
+	}
+	scheduler := currentScheduler()
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30, Invitees: []string{"bob", "cy"}})
+	require.NoError(t, err)
+	start := time.Now().Add(24 * time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+
+	send := func(user User, path, body string) *httptest.ResponseRecorder {
+		token, err := issueSessionToken(user)
+		require.NoError(t, err)
+		req, _ := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	path := "/api/v1/events/" + event.ID + "/broadcast"
+	assert.Equal(t, http.StatusForbidden, send(eve, path, `{"message": "Hi"}`).Code, "only the organizer broadcasts")
+	assert.Equal(t, http.StatusBadRequest, send(ada, path, `{"message": "Hi", "audience": "everyone"}`).Code)
+
+	w := send(ada, path, `{"message": "Please respond by Friday.", "audience": "non-responders"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Len(t, recorder.messages, 1)
+	assert.Equal(t, "cy", recorder.messages[0].To.ID)
+	assert.Equal(t, MessageBroadcast, recorder.messages[0].Kind)
+	assert.Equal(t, "Message about Kickoff", recorder.messages[0].Subject)
+
+	w = send(ada, path, `{"subject": "Agenda", "message": "Bring slides."}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Len(t, recorder.messages, 3, "everyone but the sender")
+
+	history := comments.ForEvent(event.ID)
+	require.Len(t, history, 2)
+	assert.Equal(t, &Broadcast{Subject: "Message about Kickoff", Audience: AudienceNonResponders, Recipients: []string{"cy"}}, history[0].Broadcast)
+	assert.Equal(t, []string{"bob", "cy"}, history[1].Broadcast.Recipients)
+
+	require.NoError(t, scheduler.DeleteEvent(event.ID))
+	assert.Empty(t, comments.ForEvent(event.ID))
+}
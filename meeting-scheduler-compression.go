@@ -0,0 +1,221 @@
+package main
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// minCompressSize is the smallest response worth compressing; below it the
+// encoding overhead outweighs the saving
+const minCompressSize = 1024
+
+// streamFlushEvery is how many array items a JSON stream writes between
+// flushes
+const streamFlushEvery = 100
+
+// negotiateEncoding picks gzip or deflate from an Accept-Encoding header,
+// preferring gzip, or "" for neither
+func negotiateEncoding(header string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		accepted[strings.ToLower(name)] = q > 0
+	}
+	switch {
+	case accepted["gzip"] || accepted["*"]:
+		return "gzip"
+	case accepted["deflate"]:
+		return "deflate"
+	}
+	return ""
+}
+
+// compressWriter holds back the first minCompressSize bytes of a response
+// to decide whether to compress it. Event streams and responses a handler
+// has already encoded pass straight through.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	buf      []byte
+	enc      interface {
+		io.WriteCloser
+		Flush() error
+	}
+	passthrough bool
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.enc == nil && !w.passthrough {
+		header := w.Header()
+		if strings.HasPrefix(header.Get("Content-Type"), "text/event-stream") || header.Get("Content-Encoding") != "" {
+			w.start(false)
+		}
+	}
+	switch {
+	case w.passthrough:
+		return w.ResponseWriter.Write(p)
+	case w.enc != nil:
+		return w.enc.Write(p)
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= minCompressSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// start settles whether to compress, writing out what was held back
+func (w *compressWriter) start(compress bool) error {
+	if !compress {
+		w.passthrough = true
+	} else {
+		header := w.Header()
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", w.encoding)
+		if w.encoding == "gzip" {
+			w.enc = gzip.NewWriter(w.ResponseWriter)
+		} else {
+			w.enc, _ = flate.NewWriter(w.ResponseWriter, flate.DefaultCompression)
+		}
+	}
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// Flush means the handler is streaming, so the response is compressed
+// whatever its size so far
+func (w *compressWriter) Flush() {
+	if w.enc == nil && !w.passthrough && len(w.buf) > 0 {
+		w.start(true)
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Close finishes the response, sending short ones uncompressed
+func (w *compressWriter) Close() error {
+	if w.enc == nil && !w.passthrough {
+		return w.start(false)
+	}
+	if w.enc != nil {
+		return w.enc.Close()
+	}
+	return nil
+}
+
+// compressResponses gzips or deflates responses for clients that accept it
+func compressResponses(c *gin.Context) {
+	c.Writer.Header().Add("Vary", "Accept-Encoding")
+	encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+	if encoding == "" || c.Request.Method == http.MethodHead {
+		c.Next()
+		return
+	}
+	w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding}
+	c.Writer = w
+	c.Next()
+	if err := w.Close(); err != nil {
+		c.Error(err)
+	}
+}
+
+// jsonStream writes a JSON object field by field, streaming large arrays
+// item by item and flushing as it goes, so big responses reach the client
+// in chunks rather than one buffered write
+type jsonStream struct {
+	c      *gin.Context
+	fields int
+	err    error
+}
+
+func newJSONStream(c *gin.Context, status int) *jsonStream {
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(status)
+	s := &jsonStream{c: c}
+	s.raw("{")
+	return s
+}
+
+func (s *jsonStream) raw(text string) {
+	if s.err == nil {
+		_, s.err = io.WriteString(s.c.Writer, text)
+	}
+}
+
+func (s *jsonStream) value(v interface{}) {
+	if s.err != nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		s.err = err
+		return
+	}
+	_, s.err = s.c.Writer.Write(data)
+}
+
+func (s *jsonStream) key(name string) {
+	if s.fields > 0 {
+		s.raw(",")
+	}
+	s.fields++
+	s.value(name)
+	s.raw(":")
+}
+
+// Field writes a field whose value is marshalled in one go
+func (s *jsonStream) Field(name string, v interface{}) {
+	s.key(name)
+	s.value(v)
+}
+
+// ArrayField writes an n-item array field, fetching each item as it goes
+func (s *jsonStream) ArrayField(name string, n int, item func(i int) interface{}) {
+	s.key(name)
+	s.raw("[")
+	for i := 0; i < n && s.err == nil; i++ {
+		if i > 0 {
+			s.raw(",")
+		}
+		s.value(item(i))
+		if (i+1)%streamFlushEvery == 0 {
+			s.c.Writer.Flush()
+		}
+	}
+	s.raw("]")
+}
+
+// Close ends the object. An error part-way through can't change the
+// response any more, so it is only recorded on the context.
+func (s *jsonStream) Close() {
+	s.raw("}")
+	if s.err != nil {
+		s.c.Error(s.err)
+	}
+}
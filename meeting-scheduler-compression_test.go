@@ -0,0 +1,109 @@
+package main
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	assert.Equal(t, "gzip", negotiateEncoding("gzip, deflate, br"))
+	assert.Equal(t, "deflate", negotiateEncoding("deflate, gzip;q=0"))
+	assert.Equal(t, "gzip", negotiateEncoding("*"))
+	assert.Equal(t, "", negotiateEncoding("br"))
+	assert.Equal(t, "", negotiateEncoding(""))
+}
+
+func getEncoded(router http.Handler, path, encoding string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	req.Header.Set("Accept-Encoding", encoding)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestLargeResponsesAreCompressed(t *testing.T) {
+	router := newTestRouter(t)
+	scheduler := currentScheduler()
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "All hands", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	start := time.Date(2025, 2, 3, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 250; i++ {
+		slotStart := start.Add(time.Duration(i) * time.Hour)
+		_, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: slotStart, EndTime: slotStart.Add(time.Hour)})
+		require.NoError(t, err)
+	}
+	path := "/api/v1/events/" + event.ID + "/timeslots"
+
+	w := getEncoded(router, path, "gzip")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+	reader, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	var slots []TimeSlot
+	require.NoError(t, json.NewDecoder(reader).Decode(&slots))
+	assert.Len(t, slots, 250)
+
+	w = getEncoded(router, path, "deflate")
+	assert.Equal(t, "deflate", w.Header().Get("Content-Encoding"))
+	body, err := io.ReadAll(flate.NewReader(w.Body))
+	require.NoError(t, err)
+	assert.True(t, json.Valid(body))
+
+	w = getEncoded(router, "/api/v1/events/"+event.ID, "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "small responses are sent as they are")
+	assert.True(t, strings.HasPrefix(w.Body.String(), "{"))
+}
+
+func TestStreamedResponsesMatchTheirTypes(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	scheduler := currentScheduler()
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "All hands", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	start := time.Date(2025, 2, 3, 9, 0, 0, 0, time.UTC)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	for _, user := range []string{"bob", "cy"} {
+		_, err := scheduler.SubmitAvailability(event.ID, user, UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+		require.NoError(t, err)
+	}
+
+	w := getEncoded(router, "/api/v1/events/"+event.ID+"/overlap", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	overlap, err := scheduler.Overlap(event.ID)
+	require.NoError(t, err)
+	expected, _ := json.Marshal(overlap)
+	assert.JSONEq(t, string(expected), w.Body.String())
+
+	ada := User{ID: "ada", Role: RoleOrganizer}
+	users.Save(ada)
+	token, err := issueSessionToken(ada)
+	require.NoError(t, err)
+	req, _ := http.NewRequest("GET", "/api/v1/events/"+event.ID+"/export", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var streamed, fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &streamed))
+	data, _ := json.Marshal(EventExport{})
+	require.NoError(t, json.Unmarshal(data, &fields))
+	for key := range fields {
+		assert.Contains(t, streamed, key, "the stream writes every EventExport field")
+	}
+	assert.Len(t, streamed, len(fields))
+	var export EventExport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &export))
+	assert.Len(t, export.Availability, 2)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// resolveConditions settles a slot's conditional responses, given whom
+// each conditional user depends on. Conditions whose chain loops back on
+// itself can't be settled: the users in the cycle are flagged and counted
+// unavailable. The rest are then dropped, repeatedly, until everyone left
+// available depends only on someone who is available too.
+func resolveConditions(rec *Recommendation, conditions map[string]string) {
+	if len(conditions) == 0 {
+		return
+	}
+	available := map[string]bool{}
+	for _, id := range rec.AvailableUsers {
+		available[id] = true
+	}
+
+	var dropped []string
+	for _, cycle := range conditionCycles(conditions, available) {
+		rec.Warnings = append(rec.Warnings, fmt.Sprintf("Conditional availability cycle: %s -> %s", strings.Join(cycle, " -> "), cycle[0]))
+		for _, id := range cycle {
+			delete(available, id)
+			dropped = append(dropped, id)
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for id, dependsOn := range conditions {
+			if available[id] && !available[dependsOn] {
+				delete(available, id)
+				dropped = append(dropped, id)
+				changed = true
+			}
+		}
+	}
+
+	if len(dropped) > 0 {
+		sort.Strings(dropped)
+		markUnavailable(rec, dropped)
+	}
+	rec.ConditionalUsers = nil
+	for _, id := range rec.AvailableUsers {
+		if _, ok := conditions[id]; ok {
+			rec.ConditionalUsers = append(rec.ConditionalUsers, id)
+		}
+	}
+}
+
+// conditionCycles finds the loops among available users' conditions, each
+// starting from its smallest user ID
+func conditionCycles(conditions map[string]string, available map[string]bool) [][]string {
+	ids := make([]string, 0, len(conditions))
+	for id := range conditions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var cycles [][]string
+	done := map[string]bool{}
+	for _, start := range ids {
+		var path []string
+		onPath := map[string]int{}
+		id := start
+		for available[id] && !done[id] {
+			if i, ok := onPath[id]; ok {
+				cycles = append(cycles, rotateToSmallest(path[i:]))
+				break
+			}
+			onPath[id] = len(path)
+			path = append(path, id)
+			next, conditional := conditions[id]
+			if !conditional {
+				break
+			}
+			id = next
+		}
+		for _, visited := range path {
+			done[visited] = true
+		}
+	}
+	return cycles
+}
+
+func rotateToSmallest(cycle []string) []string {
+	smallest := 0
+	for i, id := range cycle {
+		if id < cycle[smallest] {
+			smallest = i
+		}
+	}
+	return append(append([]string{}, cycle[smallest:]...), cycle[:smallest]...)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveConditions(t *testing.T) {
+	newRec := func(available ...string) *Recommendation {
+		return &Recommendation{AvailableUsers: available, UnavailableUsers: []string{"zed"}}
+	}
+
+	// cy depends on bob, who depends on ann, who is available outright
+	rec := newRec("ann", "bob", "cy")
+	resolveConditions(rec, map[string]string{"bob": "ann", "cy": "bob"})
+	assert.Equal(t, []string{"ann", "bob", "cy"}, rec.AvailableUsers)
+	assert.Equal(t, []string{"bob", "cy"}, rec.ConditionalUsers)
+
+	// Without ann the chain unravels
+	rec = newRec("bob", "cy")
+	resolveConditions(rec, map[string]string{"bob": "ann", "cy": "bob"})
+	assert.Empty(t, rec.AvailableUsers)
+	assert.ElementsMatch(t, []string{"zed", "bob", "cy"}, rec.UnavailableUsers)
+	assert.Equal(t, 0.0, rec.AvailabilityPercentage)
+
+	// A cycle is flagged and counts as unavailable
+	rec = newRec("ann", "bob", "cy")
+	resolveConditions(rec, map[string]string{"bob": "cy", "cy": "bob"})
+	assert.Equal(t, []string{"ann"}, rec.AvailableUsers)
+	assert.Equal(t, []string{"Conditional availability cycle: bob -> cy -> bob"}, rec.Warnings)
+	assert.Equal(t, 25.0, rec.AvailabilityPercentage)
+}
+
+func TestRecommendationsResolveConditionalResponses(t *testing.T) {
+	scheduler, fake := newTestScheduler(t)
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Design review", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	start := fake.Now().Add(24 * time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "unavailable", ConditionalOn: "cy"})
+	assert.Error(t, err, "only available responses can be conditional")
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available", ConditionalOn: "cy"})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "cy", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "unavailable"})
+	require.NoError(t, err)
+
+	recs, err := scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	require.Len(t, recs, 1)
+	assert.Empty(t, recs[0].AvailableUsers)
+
+	_, err = scheduler.UpdateAvailability(event.ID, "cy", slot.ID, UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+	recs, err = scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"bob", "cy"}, recs[0].AvailableUsers)
+	assert.Equal(t, []string{"bob"}, recs[0].ConditionalUsers)
+}
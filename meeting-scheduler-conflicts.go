@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Event priorities. Admins flag important events as high priority so their
+// recommendations may displace participants' lower-priority meetings.
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
+var priorityRank = map[string]int{
+	PriorityLow:    1,
+	PriorityNormal: 2,
+	PriorityHigh:   3,
+}
+
+// eventPriority returns the event's priority rank; unset means normal
+func eventPriority(event Event) int {
+	if rank, ok := priorityRank[event.Priority]; ok {
+		return rank
+	}
+	return priorityRank[PriorityNormal]
+}
+
+// confirmedMeeting is a finalized event a user attends
+type confirmedMeeting struct {
+	Event Event
+	Start time.Time
+	End   time.Time
+}
+
+// dayKey identifies a calendar day (UTC) for per-day meeting counts
+func dayKey(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// confirmedMeetings lists, for each of the given users, the confirmed
+// meetings they attend. A user attends a finalized event they organize or
+// said they were available for at its final time.
+func confirmedMeetings(s Store, userIDs []string) (map[string][]confirmedMeeting, error) {
+	meetings := make(map[string][]confirmedMeeting, len(userIDs))
+	for _, id := range userIDs {
+		meetings[id] = nil
+	}
+
+	eventList, err := s.ListEvents()
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range eventList {
+		if event.Status != "finalized" || event.FinalTimeSlotID == "" {
+			continue
+		}
+		slot, err := s.GetTimeSlot(event.FinalTimeSlotID)
+		if err != nil {
+			continue
+		}
+		meeting := confirmedMeeting{
+			Event: event,
+			Start: slot.StartTime,
+			End:   slot.StartTime.Add(event.duration()),
+		}
+
+		attendees := map[string]bool{event.OrganizerID: true}
+		availabilityList, err := s.ListAvailability(event.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, avail := range availabilityList {
+			if avail.TimeSlotID == slot.ID && avail.Status == "available" {
+				attendees[avail.UserID] = true
+			}
+		}
+		for id := range attendees {
+			if _, ok := meetings[id]; ok {
+				meetings[id] = append(meetings[id], meeting)
+			}
+		}
+	}
+	return meetings, nil
+}
+
+// SetPriority changes how the event ranks against participants' other
+// meetings
+func (s *Scheduler) SetPriority(eventID, priority string) (Event, error) {
+	if _, ok := priorityRank[priority]; !ok {
+		return Event{}, invalid("Priority must be low, normal or high")
+	}
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return Event{}, err
+	}
+
+	event.Priority = priority
+	event.UpdatedAt = s.clock.Now()
+	if err := s.store.UpdateEvent(event); err != nil {
+		return Event{}, notFound(err, ErrEventNotFound)
+	}
+	s.publish(EventUpdated, event.ID, event)
+	return event, nil
+}
+
+type SetPriorityRequest struct {
+	Priority string `json:"priority" binding:"required"`
+}
+
+// setEventPriority lets an admin flag an event in their organization as
+// high (or low) priority
+func setEventPriority(c *gin.Context) {
+	scheduler := contextScheduler(c)
+	event, err := scheduler.GetEvent(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if user, _ := currentUser(c); user.OrgID != event.OrgID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this organization"})
+		return
+	}
+
+	var req SetPriorityRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err = scheduler.SetPriority(event.ID, req.Priority)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, event)
+}
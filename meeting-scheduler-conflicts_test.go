@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDailyMeetingCapMarksSlotsSoftUnavailable(t *testing.T) {
+	resetDirectory(t)
+	scheduler, fake := newTestScheduler(t)
+	users.Save(User{ID: "ada", Role: RoleOrganizer})
+	users.Save(User{ID: "bob", Settings: UserSettings{MaxMeetingsPerDay: 1}})
+
+	day := fake.Now().Add(48 * time.Hour)
+
+	// Bob already has a confirmed meeting that day
+	booked, err := scheduler.CreateEvent(CreateEventRequest{Title: "Standup", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	slot, err := scheduler.CreateTimeSlot(booked.ID, CreateTimeSlotRequest{StartTime: day, EndTime: day.Add(30 * time.Minute)})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(booked.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+	_, err = scheduler.FinalizeEvent(booked.ID, FinalizeEventRequest{TimeSlotID: slot.ID})
+	require.NoError(t, err)
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Review", OrganizerID: "ada", RequiredDuration: 60})
+	require.NoError(t, err)
+	sameDay, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: day.Add(2 * time.Hour), EndTime: day.Add(3 * time.Hour)})
+	require.NoError(t, err)
+	nextDay, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: day.Add(24 * time.Hour), EndTime: day.Add(25 * time.Hour)})
+	require.NoError(t, err)
+	for _, s := range []TimeSlot{sameDay, nextDay} {
+		_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: s.ID, Status: "available"})
+		require.NoError(t, err)
+	}
+
+	recommendations, err := scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	require.Len(t, recommendations, 2)
+	for _, rec := range recommendations {
+		if rec.TimeSlot.ID == sameDay.ID {
+			assert.Equal(t, []string{"bob"}, rec.SoftUnavailableUsers)
+		} else {
+			assert.Empty(t, rec.SoftUnavailableUsers)
+		}
+	}
+}
+
+func TestHighPriorityEventsDisplaceLowerPriorityMeetings(t *testing.T) {
+	resetDirectory(t)
+	scheduler, fake := newTestScheduler(t)
+	day := fake.Now().Add(48 * time.Hour)
+
+	// Bob and Cal are both booked into a normal-priority sync
+	sync, err := scheduler.CreateEvent(CreateEventRequest{Title: "Team sync", OrganizerID: "ada", RequiredDuration: 60})
+	require.NoError(t, err)
+	syncSlot, err := scheduler.CreateTimeSlot(sync.ID, CreateTimeSlotRequest{StartTime: day, EndTime: day.Add(time.Hour)})
+	require.NoError(t, err)
+	for _, id := range []string{"bob", "cal"} {
+		_, err = scheduler.SubmitAvailability(sync.ID, id, UserAvailabilityRequest{TimeSlotID: syncSlot.ID, Status: "available"})
+		require.NoError(t, err)
+	}
+	_, err = scheduler.FinalizeEvent(sync.ID, FinalizeEventRequest{TimeSlotID: syncSlot.ID})
+	require.NoError(t, err)
+
+	incident, err := scheduler.CreateEvent(CreateEventRequest{Title: "Incident review", OrganizerID: "dee", RequiredDuration: 60})
+	require.NoError(t, err)
+	overlapping, err := scheduler.CreateTimeSlot(incident.ID, CreateTimeSlotRequest{StartTime: day.Add(30 * time.Minute), EndTime: day.Add(90 * time.Minute)})
+	require.NoError(t, err)
+	for _, id := range []string{"bob", "cal"} {
+		_, err = scheduler.SubmitAvailability(incident.ID, id, UserAvailabilityRequest{TimeSlotID: overlapping.ID, Status: "available"})
+		require.NoError(t, err)
+	}
+
+	// At normal priority the existing meeting wins
+	recommendations, err := scheduler.Recommendations(incident.ID)
+	require.NoError(t, err)
+	require.Len(t, recommendations, 1)
+	assert.Equal(t, 0.0, recommendations[0].AvailabilityPercentage)
+	assert.ElementsMatch(t, []string{"bob", "cal"}, recommendations[0].UnavailableUsers)
+	assert.Empty(t, recommendations[0].MeetingsToMove)
+
+	_, err = scheduler.SetPriority(incident.ID, "urgent")
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+
+	_, err = scheduler.SetPriority(incident.ID, PriorityHigh)
+	require.NoError(t, err)
+	recommendations, err = scheduler.Recommendations(incident.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, recommendations[0].AvailabilityPercentage)
+	require.Len(t, recommendations[0].MeetingsToMove, 1)
+	assert.Equal(t, sync.ID, recommendations[0].MeetingsToMove[0].EventID)
+	assert.ElementsMatch(t, []string{"bob", "cal"}, recommendations[0].MeetingsToMove[0].UserIDs)
+}
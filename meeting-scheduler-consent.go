@@ -0,0 +1,176 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Consent records a participant agreeing to the privacy policy, kept on
+// their account for as long as the account is
+type Consent struct {
+	PolicyVersion string    `json:"policyVersion"`
+	GivenAt       time.Time `json:"givenAt"`
+	// EventID is the event whose link they were responding through, if any
+	EventID string `json:"eventId,omitempty"`
+}
+
+type ConsentRequest struct {
+	PolicyVersion string `json:"policyVersion" binding:"required"`
+	EventID       string `json:"eventId"`
+}
+
+// privacyPolicyVersion is the version guests must consent to before they
+// respond. PRIVACY_POLICY_VERSION sets it; when empty no consent is asked.
+func privacyPolicyVersion() string {
+	return getenv("PRIVACY_POLICY_VERSION", "")
+}
+
+// consentedTo reports whether the user agreed to this policy version
+func (u User) consentedTo(version string) bool {
+	for _, consent := range u.Consents {
+		if consent.PolicyVersion == version {
+			return true
+		}
+	}
+	return false
+}
+
+// needsConsent reports whether the user must consent before responding:
+// guests must, to the current policy, once there is one
+func needsConsent(user User) bool {
+	version := privacyPolicyVersion()
+	return version != "" && user.Role == RoleGuest && !user.consentedTo(version)
+}
+
+// requireGuestConsent rejects guests' responses until they consent to the
+// current privacy policy
+func requireGuestConsent(c *gin.Context) {
+	user, ok := currentUser(c)
+	if ok && needsConsent(user) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error":         "Consent to the privacy policy is required before responding",
+			"policyVersion": privacyPolicyVersion(),
+		})
+		return
+	}
+	c.Next()
+}
+
+// recordMyConsent records the signed-in user's consent to the current
+// policy. Consenting again to the same version keeps the first record.
+func recordMyConsent(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	var req ConsentRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if version := privacyPolicyVersion(); req.PolicyVersion != version {
+		c.JSON(http.StatusConflict, gin.H{"error": "The privacy policy has changed; review the current version", "policyVersion": version})
+		return
+	}
+
+	user, ok := users.Get(current.ID)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unknown user"})
+		return
+	}
+	if !user.consentedTo(req.PolicyVersion) {
+		now := clock.Now()
+		user.Consents = append(user.Consents, Consent{PolicyVersion: req.PolicyVersion, GivenAt: now, EventID: req.EventID})
+		user.UpdatedAt = now
+		users.Save(user)
+	}
+	c.JSON(http.StatusOK, user.Consents)
+}
+
+// PersonalDataExport is everything held about one person, for data
+// subject access requests
+type PersonalDataExport struct {
+	FormatVersion int       `json:"formatVersion"`
+	ExportedAt    time.Time `json:"exportedAt"`
+	// User is the account, with the consents it has given
+	User         User               `json:"user"`
+	Availability []UserAvailability `json:"availability"`
+	Answers      []IntakeResponse   `json:"answers"`
+	Comments     []Comment          `json:"comments"`
+}
+
+// ExportPersonalData gathers the user's record and everything they have
+// contributed to events, in event creation order
+func (s *Scheduler) ExportPersonalData(user User) (PersonalDataExport, error) {
+	eventList, err := s.ListEvents()
+	if err != nil {
+		return PersonalDataExport{}, err
+	}
+	export := PersonalDataExport{
+		FormatVersion: exportFormatVersion,
+		ExportedAt:    s.clock.Now(),
+		User:          user,
+		Availability:  []UserAvailability{},
+		Answers:       []IntakeResponse{},
+		Comments:      []Comment{},
+	}
+	for _, event := range eventList {
+		availabilityList, err := s.store.ListUserAvailability(event.ID, user.ID)
+		if err != nil {
+			return PersonalDataExport{}, err
+		}
+		sort.Slice(availabilityList, func(i, j int) bool { return availabilityList[i].TimeSlotID < availabilityList[j].TimeSlotID })
+		export.Availability = append(export.Availability, availabilityList...)
+		for _, response := range intakeAnswers.ForEvent(event.ID) {
+			if response.UserID == user.ID {
+				export.Answers = append(export.Answers, response)
+			}
+		}
+		for _, comment := range comments.ForEvent(event.ID) {
+			if comment.AuthorID == user.ID {
+				export.Comments = append(export.Comments, comment)
+			}
+		}
+	}
+	return export, nil
+}
+
+func respondPersonalData(c *gin.Context, user User) {
+	export, err := contextScheduler(c).ExportPersonalData(user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="personal-data-`+user.ID+`.json"`)
+	c.JSON(http.StatusOK, export)
+}
+
+// exportMyData lets anyone signed in, guests included, download their data
+func exportMyData(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	user, ok := users.Get(current.ID)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unknown user"})
+		return
+	}
+	respondPersonalData(c, user)
+}
+
+// exportMemberData answers requests made to an admin, such as from guests
+// whose links have expired
+func exportMemberData(c *gin.Context) {
+	user, ok := users.Get(c.Param("userId"))
+	if !ok || user.OrgID != c.Param("orgId") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	respondPersonalData(c, user)
+}
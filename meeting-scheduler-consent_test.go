@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuestsConsentBeforeResponding(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	t.Setenv("PRIVACY_POLICY_VERSION", "2025-01")
+	users.Save(User{ID: "ada", OrgID: "acme", Role: RoleOrganizer})
+	guest := User{ID: "guest-1", OrgID: "acme", Email: "gil@example.test", Role: RoleGuest}
+	users.Save(guest)
+
+	scheduler := currentScheduler()
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30, Invitees: []string{guest.ID}})
+	require.NoError(t, err)
+	start := time.Now().Add(24 * time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	invite := guestInvites.Issue(event.ID, guest.ID, clock.Now())
+
+	send := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var redeemed struct {
+		Token           string `json:"token"`
+		ConsentRequired bool   `json:"consentRequired"`
+		PolicyVersion   string `json:"policyVersion"`
+	}
+	w := send("", http.MethodGet, "/api/v1/invites/"+invite.Token, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeJSON(t, w, &redeemed)
+	assert.True(t, redeemed.ConsentRequired)
+	assert.Equal(t, "2025-01", redeemed.PolicyVersion)
+
+	respond := `{"timeslotId": "` + slot.ID + `", "status": "available"}`
+	path := "/api/v1/events/" + event.ID + "/users/" + guest.ID + "/availability"
+	assert.Equal(t, http.StatusForbidden, send(redeemed.Token, http.MethodPost, path, respond).Code)
+
+	assert.Equal(t, http.StatusConflict, send(redeemed.Token, http.MethodPost, "/api/v1/users/me/consent", `{"policyVersion": "2024-06"}`).Code)
+	require.Equal(t, http.StatusOK, send(redeemed.Token, http.MethodPost, "/api/v1/users/me/consent", `{"policyVersion": "2025-01", "eventId": "`+event.ID+`"}`).Code)
+	require.Equal(t, http.StatusOK, send(redeemed.Token, http.MethodPost, "/api/v1/users/me/consent", `{"policyVersion": "2025-01"}`).Code)
+	assert.Equal(t, http.StatusCreated, send(redeemed.Token, http.MethodPost, path, respond).Code)
+
+	var export PersonalDataExport
+	w = send(redeemed.Token, http.MethodGet, "/api/v1/users/me/export", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeJSON(t, w, &export)
+	require.Len(t, export.User.Consents, 1, "consenting again keeps the first record")
+	assert.Equal(t, "2025-01", export.User.Consents[0].PolicyVersion)
+	assert.Equal(t, event.ID, export.User.Consents[0].EventID)
+	require.Len(t, export.Availability, 1)
+	assert.Equal(t, slot.ID, export.Availability[0].TimeSlotID)
+
+	// A new policy version asks again
+	t.Setenv("PRIVACY_POLICY_VERSION", "2025-07")
+	assert.Equal(t, http.StatusForbidden, send(redeemed.Token, http.MethodPut, "/api/v1/events/"+event.ID+"/users/"+guest.ID+"/answers", `{"answers": {}}`).Code)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// slotRules evaluates the scheduling rules that apply to one event: its
+// organization's blackouts and protected windows, the notice its
+// participants require, their daily meeting caps and their other meetings
+type slotRules struct {
+	event        Event
+	participants []string
+	now          time.Time
+	notice       time.Duration
+	protected    []ProtectedWindow
+
+	dailyCaps map[string]int
+	meetings  map[string][]confirmedMeeting
+	declared  map[string][]DeclaredInterval
+	shifts    map[string]ShiftPattern
+
+	prerequisites prerequisiteState
+}
+
+// newSlotRules gathers the rules for an event. Participants are the
+// organizer plus everyone who has responded so far.
+func (s *Scheduler) newSlotRules(event Event) (slotRules, error) {
+	availabilityList, err := s.store.ListAvailability(event.ID)
+	if err != nil {
+		return slotRules{}, err
+	}
+	return s.slotRulesFor(event, availabilityList)
+}
+
+// slotRulesFor gathers the rules for an event given its responses
+func (s *Scheduler) slotRulesFor(event Event, availabilityList []UserAvailability) (slotRules, error) {
+	seen := map[string]bool{event.OrganizerID: true}
+	participants := []string{event.OrganizerID}
+	for _, avail := range availabilityList {
+		if !seen[avail.UserID] {
+			seen[avail.UserID] = true
+			participants = append(participants, avail.UserID)
+		}
+	}
+	for _, id := range event.Invitees {
+		if !seen[id] {
+			seen[id] = true
+			participants = append(participants, id)
+		}
+	}
+
+	// The strictest minimum notice among the event and its participants wins
+	notice := event.MinNoticeMinutes
+	dailyCaps := map[string]int{}
+	shifts := map[string]ShiftPattern{}
+	for _, id := range participants {
+		user, ok := users.Get(id)
+		if !ok {
+			continue
+		}
+		if user.Settings.MinNoticeMinutes > notice {
+			notice = user.Settings.MinNoticeMinutes
+		}
+		if user.Settings.MaxMeetingsPerDay > 0 {
+			dailyCaps[id] = user.Settings.MaxMeetingsPerDay
+		}
+		if user.Settings.Shift != nil {
+			shifts[id] = *user.Settings.Shift
+		}
+	}
+
+	rules := slotRules{
+		event:        event,
+		participants: participants,
+		now:          s.clock.Now(),
+		notice:       time.Duration(notice) * time.Minute,
+		dailyCaps:    dailyCaps,
+		declared:     declaredAvailability.ForUsers(participants),
+		shifts:       shifts,
+	}
+	if org, ok := organizations.Get(event.OrgID); ok && !event.AllowProtectedWindows {
+		rules.protected = org.ProtectedWindows
+	}
+	meetings, err := confirmedMeetings(s.store, participants)
+	if err != nil {
+		return slotRules{}, err
+	}
+	rules.meetings = meetings
+	if rules.prerequisites, err = prerequisitesOf(s.store, event); err != nil {
+		return slotRules{}, err
+	}
+	return rules, nil
+}
+
+// fullyBooked returns the participants who already have as many confirmed
+// meetings as they allow on the day the slot starts
+func (r slotRules) fullyBooked(start time.Time) []string {
+	day := dayKey(start)
+	var booked []string
+	for _, id := range r.participants {
+		limit, ok := r.dailyCaps[id]
+		if !ok {
+			continue
+		}
+		count := 0
+		for _, meeting := range r.meetings[id] {
+			if meeting.Event.ID != r.event.ID && dayKey(meeting.Start) == day {
+				count++
+			}
+		}
+		if count >= limit {
+			booked = append(booked, id)
+		}
+	}
+	return booked
+}
+
+// conflicts checks the available users' other confirmed meetings against
+// [start, end), keeping the larger of the two meetings' buffers between
+// them. Overlaps with meetings of lower priority than this event
+// can be moved and are returned as such; any other overlap makes the user
+// conflicted.
+func (r slotRules) conflicts(available []string, start, end time.Time) (conflicted []string, toMove []MeetingToMove) {
+	moving := map[string]int{}
+	for _, id := range available {
+		blocked := false
+		for _, meeting := range r.meetings[id] {
+			buffer := r.event.BufferMinutes
+			if meeting.Event.BufferMinutes > buffer {
+				buffer = meeting.Event.BufferMinutes
+			}
+			pad := time.Duration(buffer) * time.Minute
+			if meeting.Event.ID == r.event.ID || !meeting.Start.Before(end.Add(pad)) || !start.Before(meeting.End.Add(pad)) {
+				continue
+			}
+			if eventPriority(meeting.Event) >= eventPriority(r.event) {
+				blocked = true
+				continue
+			}
+			i, ok := moving[meeting.Event.ID]
+			if !ok {
+				i = len(toMove)
+				moving[meeting.Event.ID] = i
+				toMove = append(toMove, MeetingToMove{EventID: meeting.Event.ID, Title: meeting.Event.Title, Priority: meeting.Event.Priority})
+			}
+			toMove[i].UserIDs = append(toMove[i].UserIDs, id)
+		}
+		if blocked {
+			conflicted = append(conflicted, id)
+		}
+	}
+	return conflicted, toMove
+}
+
+// violations lists the rules a candidate time would break. The slot
+// generator skips candidates with any violation; slots organizers add by
+// hand are kept but carry the violations as recommendation warnings.
+func (r slotRules) violations(start, end time.Time) []string {
+	var violations []string
+	for _, blackout := range blackouts.Overlapping(r.event.OrgID, start, end) {
+		violations = append(violations, fmt.Sprintf("Overlaps blackout %q", blackout.Name))
+	}
+	for _, window := range r.protectedWindows(start, end) {
+		violations = append(violations, fmt.Sprintf("Inside protected window %q", window.Name))
+	}
+	if r.notice > 0 && start.Before(r.now.Add(r.notice)) {
+		violations = append(violations, fmt.Sprintf("Less than %s notice", formatNotice(r.notice)))
+	}
+	if start.Before(r.prerequisites.notBefore) {
+		violations = append(violations, fmt.Sprintf("Before prerequisite %q ends", r.prerequisites.blocking))
+	}
+	return violations
+}
+
+// protectedWindows returns the protected windows the time overlaps, unless
+// the event has been allowed to override them
+func (r slotRules) protectedWindows(start, end time.Time) []ProtectedWindow {
+	var overlapping []ProtectedWindow
+	for _, window := range r.protected {
+		if window.Overlaps(start, end) {
+			overlapping = append(overlapping, window)
+		}
+	}
+	return overlapping
+}
+
+// formatNotice renders a notice period in whole hours when possible
+func formatNotice(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dm", int(d.Minutes()))
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinimumNoticeUsesStrictestSetting(t *testing.T) {
+	resetDirectory(t)
+	resetBlackouts(t)
+	scheduler, fake := newTestScheduler(t)
+	users.Save(User{ID: "ada", Role: RoleOrganizer})
+	users.Save(User{ID: "bob", Settings: UserSettings{MinNoticeMinutes: 48 * 60}})
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 60, MinNoticeMinutes: 24 * 60})
+	require.NoError(t, err)
+
+	now := fake.Now()
+	soon, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: now.Add(30 * time.Hour), EndTime: now.Add(31 * time.Hour)})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "ada", UserAvailabilityRequest{TimeSlotID: soon.ID, Status: "available"})
+	require.NoError(t, err)
+
+	// Only the event's 24h notice applies so far
+	recommendations, err := scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	assert.Empty(t, recommendations[0].Warnings)
+
+	// Bob's 48h notice is stricter once they respond
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: soon.ID, Status: "available"})
+	require.NoError(t, err)
+	recommendations, err = scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Less than 48h notice"}, recommendations[0].Warnings)
+
+	generated, err := scheduler.GenerateTimeSlots(event.ID, GenerateTimeSlotsRequest{From: now, To: now.Add(49 * time.Hour), StepMinutes: 60})
+	require.NoError(t, err)
+	require.Len(t, generated, 1)
+	assert.Equal(t, now.Add(48*time.Hour), generated[0].StartTime)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// storageTimeout bounds each storage call a request makes. STORAGE_TIMEOUT
+// sets it.
+func storageTimeout() time.Duration {
+	return getenvDuration("STORAGE_TIMEOUT", 5*time.Second)
+}
+
+// integrationTimeout bounds each call to an external calendar.
+// INTEGRATION_TIMEOUT sets it.
+func integrationTimeout() time.Duration {
+	return getenvDuration("INTEGRATION_TIMEOUT", 15*time.Second)
+}
+
+// contextBinder is implemented by stores that do I/O, such as a database
+// backend, to run their calls under a context. The in-memory store never
+// blocks on I/O and doesn't need to.
+type contextBinder interface {
+	WithContext(ctx context.Context) Store
+}
+
+// contextStore runs each call against store under ctx and a deadline of
+// timeout. Once ctx is done no more calls are made; stores that implement
+// contextBinder also abandon calls in flight.
+type contextStore struct {
+	store   Store
+	ctx     context.Context
+	timeout time.Duration
+}
+
+// withStoreContext binds store's calls to ctx with the storage timeout
+func withStoreContext(ctx context.Context, store Store) Store {
+	return contextStore{store: store, ctx: ctx, timeout: storageTimeout()}
+}
+
+func (s contextStore) call(op func(store Store) error) error {
+	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	defer cancel()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	target := s.store
+	if binder, ok := target.(contextBinder); ok {
+		target = binder.WithContext(ctx)
+	}
+	return op(target)
+}
+
+func (s contextStore) CreateEvent(event Event) error {
+	return s.call(func(store Store) error { return store.CreateEvent(event) })
+}
+
+func (s contextStore) GetEvent(id string) (event Event, err error) {
+	err = s.call(func(store Store) (err error) {
+		event, err = store.GetEvent(id)
+		return err
+	})
+	return event, err
+}
+
+func (s contextStore) ListEvents() (list []Event, err error) {
+	err = s.call(func(store Store) (err error) {
+		list, err = store.ListEvents()
+		return err
+	})
+	return list, err
+}
+
+func (s contextStore) UpdateEvent(event Event) error {
+	return s.call(func(store Store) error { return store.UpdateEvent(event) })
+}
+
+func (s contextStore) CompareAndSwapEvent(event, expected Event) error {
+	return s.call(func(store Store) error { return store.CompareAndSwapEvent(event, expected) })
+}
+
+func (s contextStore) DeleteEvent(id string) error {
+	return s.call(func(store Store) error { return store.DeleteEvent(id) })
+}
+
+func (s contextStore) CreateTimeSlot(slot TimeSlot) error {
+	return s.call(func(store Store) error { return store.CreateTimeSlot(slot) })
+}
+
+func (s contextStore) GetTimeSlot(id string) (slot TimeSlot, err error) {
+	err = s.call(func(store Store) (err error) {
+		slot, err = store.GetTimeSlot(id)
+		return err
+	})
+	return slot, err
+}
+
+func (s contextStore) ListTimeSlots(eventID string) (list []TimeSlot, err error) {
+	err = s.call(func(store Store) (err error) {
+		list, err = store.ListTimeSlots(eventID)
+		return err
+	})
+	return list, err
+}
+
+func (s contextStore) UpdateTimeSlot(slot TimeSlot) error {
+	return s.call(func(store Store) error { return store.UpdateTimeSlot(slot) })
+}
+
+func (s contextStore) DeleteTimeSlot(id string) error {
+	return s.call(func(store Store) error { return store.DeleteTimeSlot(id) })
+}
+
+func (s contextStore) CreateAvailability(avail UserAvailability) error {
+	return s.call(func(store Store) error { return store.CreateAvailability(avail) })
+}
+
+func (s contextStore) FindAvailability(eventID, userID, timeslotID string) (avail UserAvailability, err error) {
+	err = s.call(func(store Store) (err error) {
+		avail, err = store.FindAvailability(eventID, userID, timeslotID)
+		return err
+	})
+	return avail, err
+}
+
+func (s contextStore) ListAvailability(eventID string) (list []UserAvailability, err error) {
+	err = s.call(func(store Store) (err error) {
+		list, err = store.ListAvailability(eventID)
+		return err
+	})
+	return list, err
+}
+
+func (s contextStore) ListUserAvailability(eventID, userID string) (list []UserAvailability, err error) {
+	err = s.call(func(store Store) (err error) {
+		list, err = store.ListUserAvailability(eventID, userID)
+		return err
+	})
+	return list, err
+}
+
+func (s contextStore) UpdateAvailability(avail UserAvailability) error {
+	return s.call(func(store Store) error { return store.UpdateAvailability(avail) })
+}
+
+func (s contextStore) DeleteAvailability(id string) error {
+	return s.call(func(store Store) error { return store.DeleteAvailability(id) })
+}
+
+// WithTransaction gives each call in the transaction its own deadline, and
+// stops it at the first call made after ctx is done
+func (s contextStore) WithTransaction(fn func(tx Store) error) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	target := s.store
+	if binder, ok := target.(contextBinder); ok {
+		target = binder.WithContext(s.ctx)
+	}
+	return target.WithTransaction(func(tx Store) error {
+		return fn(contextStore{store: tx, ctx: s.ctx, timeout: s.timeout})
+	})
+}
+
+// WithContext returns a Scheduler whose storage calls run under ctx, each
+// with the storage timeout
+func (s *Scheduler) WithContext(ctx context.Context) *Scheduler {
+	bound := *s
+	bound.store = withStoreContext(ctx, s.store)
+	bound.impersonatorID = impersonatorFrom(ctx)
+	return &bound
+}
+
+// contextScheduler is currentScheduler bound to the request's context, so
+// its storage calls give up when the client goes away or the storage
+// timeout passes
+func contextScheduler(c *gin.Context) *Scheduler {
+	return currentScheduler().WithContext(c.Request.Context())
+}
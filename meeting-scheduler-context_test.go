@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hungStore is a Store whose backend never answers reads: bound to a
+// context, GetEvent waits for it to be done
+type hungStore struct {
+	Store
+	ctx context.Context
+}
+
+func (s hungStore) WithContext(ctx context.Context) Store { return hungStore{Store: s.Store, ctx: ctx} }
+
+func (s hungStore) GetEvent(id string) (Event, error) {
+	if s.ctx == nil {
+		return s.Store.GetEvent(id)
+	}
+	<-s.ctx.Done()
+	return Event{}, s.ctx.Err()
+}
+
+// hungCalendar is a calendar whose server never responds
+type hungCalendar struct{}
+
+func (hungCalendar) FreeBusy(ctx context.Context, from, to time.Time) ([]BusyInterval, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestStorageCallsStopWithTheRequest(t *testing.T) {
+	scheduler, _ := newTestScheduler(t)
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bound := scheduler.WithContext(ctx)
+	_, err = bound.GetEvent(event.ID)
+	require.NoError(t, err)
+	cancel()
+	_, err = bound.GetEvent(event.ID)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = bound.CreateEvent(CreateEventRequest{Title: "Retro", OrganizerID: "ada", RequiredDuration: 30})
+	assert.ErrorIs(t, err, context.Canceled)
+	events, err := scheduler.ListEvents()
+	require.NoError(t, err)
+	assert.Len(t, events, 1, "nothing is written once the request is gone")
+}
+
+func TestHungStorageTimesOut(t *testing.T) {
+	router := newTestRouter(t)
+	t.Setenv("STORAGE_TIMEOUT", "20ms")
+	scheduler, _ := newTestScheduler(t)
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	store = hungStore{Store: store}
+
+	started := time.Now()
+	w := doJSON(router, http.MethodGet, "/api/v1/events/"+event.ID, nil)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Less(t, time.Since(started), time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/events/"+event.ID, nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestHungCalendarTimesOut(t *testing.T) {
+	t.Setenv("INTEGRATION_TIMEOUT", "20ms")
+	previous := calendars
+	calendars = newCalendarRegistry()
+	calendarConnectors["hung"] = func(CalendarConnection, string) CalendarConnector { return hungCalendar{} }
+	t.Cleanup(func() {
+		calendars = previous
+		delete(calendarConnectors, "hung")
+	})
+	calendars.Save(CalendarConnection{ID: "cal1", UserID: "ada", Kind: "hung", URL: "https://calendar.example.com"})
+
+	from := time.Date(2025, 1, 13, 9, 0, 0, 0, time.UTC)
+	_, err := userFreeBusy(context.Background(), "ada", from, from.Add(time.Hour))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// Sensitive values (calendar credentials, webhook secrets, email
+// addresses) are sealed with envelope encryption before they are stored:
+// each value gets its own data key, and the data key is wrapped by a key
+// encryption key held by a KeyProvider. Rotating the key encryption key
+// only re-wraps data keys; see rewrapSecret.
+
+// KeyProvider wraps and unwraps data keys
+type KeyProvider interface {
+	// ActiveKeyID names the key new data keys are wrapped with
+	ActiveKeyID() string
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// localKeyring wraps data keys with AES-GCM under locally configured keys.
+// The first key is active; the rest are kept to unwrap older values.
+type localKeyring struct {
+	active string
+	keys   map[string][]byte
+}
+
+// parseKeyring reads "id:base64key,id:base64key", active key first
+func parseKeyring(spec string) (*localKeyring, error) {
+	ring := &localKeyring{keys: make(map[string][]byte)}
+	for _, entry := range strings.Split(spec, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("key entry %q must be id:base64key", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("key %q must be 32 base64-encoded bytes", id)
+		}
+		if ring.active == "" {
+			ring.active = id
+		}
+		ring.keys[id] = key
+	}
+	return ring, nil
+}
+
+func (r *localKeyring) ActiveKeyID() string { return r.active }
+
+func (r *localKeyring) WrapKey(_ context.Context, dataKey []byte) (string, []byte, error) {
+	wrapped, err := aesSeal(r.keys[r.active], dataKey)
+	return r.active, wrapped, err
+}
+
+func (r *localKeyring) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := r.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", keyID)
+	}
+	return aesOpen(key, wrapped)
+}
+
+// kmsKeyProvider wraps data keys with an AWS KMS key. Unwrapped data keys
+// are cached so reads don't each cost a KMS call.
+type kmsKeyProvider struct {
+	client *kms.Client
+	keyID  string
+
+	mu    sync.Mutex
+	cache map[string][]byte
+}
+
+const kmsCacheSize = 1024
+
+func (p *kmsKeyProvider) ActiveKeyID() string { return p.keyID }
+
+func (p *kmsKeyProvider) WrapKey(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	out, err := p.client.Encrypt(ctx, &kms.EncryptInput{KeyId: aws.String(p.keyID), Plaintext: dataKey})
+	if err != nil {
+		return "", nil, err
+	}
+	return p.keyID, out.CiphertextBlob, nil
+}
+
+func (p *kmsKeyProvider) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	p.mu.Lock()
+	if dataKey, ok := p.cache[string(wrapped)]; ok {
+		p.mu.Unlock()
+		return dataKey, nil
+	}
+	p.mu.Unlock()
+
+	out, err := p.client.Decrypt(ctx, &kms.DecryptInput{KeyId: aws.String(keyID), CiphertextBlob: wrapped})
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	if len(p.cache) >= kmsCacheSize {
+		p.cache = make(map[string][]byte)
+	}
+	p.cache[string(wrapped)] = out.Plaintext
+	p.mu.Unlock()
+	return out.Plaintext, nil
+}
+
+// encryptionKeys is the process-wide key provider. ENCRYPTION_KMS_KEY_ID
+// selects AWS KMS; otherwise ENCRYPTION_KEYS holds a local keyring. Without
+// either a random key is generated, so sealed values don't survive a
+// restart.
+var encryptionKeys = loadKeyProvider()
+
+func loadKeyProvider() KeyProvider {
+	if keyID := getenv("ENCRYPTION_KMS_KEY_ID", ""); keyID != "" {
+		cfg, err := awsconfig.LoadDefaultConfig(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to load AWS configuration: %v", err)
+		}
+		return &kmsKeyProvider{client: kms.NewFromConfig(cfg), keyID: keyID, cache: make(map[string][]byte)}
+	}
+	if spec := secrets.Get("ENCRYPTION_KEYS"); spec != "" {
+		ring, err := parseKeyring(spec)
+		if err != nil {
+			log.Fatalf("Invalid ENCRYPTION_KEYS: %v", err)
+		}
+		return ring
+	}
+
+	log.Printf("ENCRYPTION_KEYS not set, using an ephemeral encryption key")
+	return &localKeyring{active: "ephemeral", keys: map[string][]byte{"ephemeral": randomKey()}}
+}
+
+// blindIndexKey keys the HMACs used to look up encrypted values (such as
+// emails) without decrypting them. It comes from ENCRYPTION_INDEX_KEY and
+// must stay stable across key rotations.
+var blindIndexKey = loadBlindIndexKey()
+
+func loadBlindIndexKey() []byte {
+	if encoded := secrets.Get("ENCRYPTION_INDEX_KEY"); encoded != "" {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) < 16 {
+			log.Fatalf("ENCRYPTION_INDEX_KEY must be at least 16 base64-encoded bytes")
+		}
+		return key
+	}
+	return randomKey()
+}
+
+func randomKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("Failed to generate key: %v", err)
+	}
+	return key
+}
+
+// blindIndex returns a deterministic keyed hash of a value for lookups
+func blindIndex(value string) string {
+	mac := hmac.New(sha256.New, blindIndexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func aesSeal(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func aesOpen(key, sealed []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("sealed value too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+}
+
+// envelope is a sealed value: the wrapped data key, the id of the key that
+// wrapped it, and the value encrypted under the data key
+type envelope struct {
+	keyID      string
+	wrappedKey []byte
+	ciphertext []byte
+}
+
+// Encoded envelopes are: version byte, key id length (1 byte), key id,
+// wrapped key length (2 bytes), wrapped key, ciphertext
+const envelopeVersion = 1
+
+func (e envelope) marshal() []byte {
+	out := []byte{envelopeVersion, byte(len(e.keyID))}
+	out = append(out, e.keyID...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(e.wrappedKey)))
+	out = append(out, e.wrappedKey...)
+	return append(out, e.ciphertext...)
+}
+
+func unmarshalEnvelope(data []byte) (envelope, error) {
+	malformed := errors.New("malformed sealed value")
+	if len(data) < 2 || data[0] != envelopeVersion {
+		return envelope{}, malformed
+	}
+	idLen := int(data[1])
+	data = data[2:]
+	if len(data) < idLen+2 {
+		return envelope{}, malformed
+	}
+	e := envelope{keyID: string(data[:idLen])}
+	data = data[idLen:]
+	keyLen := int(binary.BigEndian.Uint16(data))
+	data = data[2:]
+	if len(data) < keyLen {
+		return envelope{}, malformed
+	}
+	e.wrappedKey, e.ciphertext = data[:keyLen], data[keyLen:]
+	return e, nil
+}
+
+// sealSecret encrypts a value under a fresh data key
+func sealSecret(plaintext string) ([]byte, error) {
+	dataKey := randomKey()
+	ciphertext, err := aesSeal(dataKey, []byte(plaintext))
+	if err != nil {
+		return nil, err
+	}
+	keyID, wrapped, err := encryptionKeys.WrapKey(context.Background(), dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("wrapping data key: %w", err)
+	}
+	return envelope{keyID: keyID, wrappedKey: wrapped, ciphertext: ciphertext}.marshal(), nil
+}
+
+// openSecret decrypts a value produced by sealSecret
+func openSecret(sealed []byte) (string, error) {
+	e, err := unmarshalEnvelope(sealed)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := encryptionKeys.UnwrapKey(context.Background(), e.keyID, e.wrappedKey)
+	if err != nil {
+		return "", fmt.Errorf("unwrapping data key: %w", err)
+	}
+	plaintext, err := aesOpen(dataKey, e.ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// rewrapSecret re-wraps a sealed value's data key under the active key,
+// leaving the ciphertext untouched. It reports false when the value is
+// already under the active key.
+func rewrapSecret(sealed []byte) ([]byte, bool, error) {
+	e, err := unmarshalEnvelope(sealed)
+	if err != nil {
+		return nil, false, err
+	}
+	if e.keyID == encryptionKeys.ActiveKeyID() {
+		return sealed, false, nil
+	}
+	dataKey, err := encryptionKeys.UnwrapKey(context.Background(), e.keyID, e.wrappedKey)
+	if err != nil {
+		return nil, false, err
+	}
+	e.keyID, e.wrappedKey, err = encryptionKeys.WrapKey(context.Background(), dataKey)
+	if err != nil {
+		return nil, false, err
+	}
+	return e.marshal(), true, nil
+}
+
+// sealedPrefix marks string fields holding sealed values
+const sealedPrefix = "enc1:"
+
+// sealString seals a value into a form that fits a string field
+func sealString(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	sealed, err := sealSecret(plaintext)
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// openString reverses sealString; unsealed values pass through
+func openString(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, sealedPrefix)
+	if !ok {
+		return value, nil
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	return openSecret(sealed)
+}
+
+// rewrapString is rewrapSecret for sealString values
+func rewrapString(value string) (string, bool, error) {
+	encoded, ok := strings.CutPrefix(value, sealedPrefix)
+	if !ok {
+		return value, false, nil
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", false, err
+	}
+	rewrapped, changed, err := rewrapSecret(sealed)
+	if err != nil || !changed {
+		return value, false, err
+	}
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(rewrapped), true, nil
+}
+
+// rotateEncryptionKeys re-wraps every stored secret still under an old key
+func rotateEncryptionKeys(time.Time) {
+	if n, err := calendars.rewrap(); err != nil {
+		log.Printf("Re-wrapping calendar credentials failed after %d: %v", n, err)
+	} else if n > 0 {
+		log.Printf("Re-wrapped %d calendar credentials", n)
+	}
+	if n, err := webhooks.rewrap(); err != nil {
+		log.Printf("Re-wrapping webhook secrets failed after %d: %v", n, err)
+	} else if n > 0 {
+		log.Printf("Re-wrapped %d webhook secrets", n)
+	}
+	if n, err := users.rewrap(); err != nil {
+		log.Printf("Re-wrapping user emails failed after %d: %v", n, err)
+	} else if n > 0 {
+		log.Printf("Re-wrapped %d user emails", n)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useKeyring(t *testing.T, spec string) {
+	ring, err := parseKeyring(spec)
+	require.NoError(t, err)
+	previous := encryptionKeys
+	encryptionKeys = ring
+	t.Cleanup(func() { encryptionKeys = previous })
+}
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func TestSealSecretRoundTrip(t *testing.T) {
+	sealed, err := sealSecret("app-specific-password")
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "app-specific-password")
+
+	opened, err := openSecret(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "app-specific-password", opened)
+
+	sealed[len(sealed)-1] ^= 1
+	_, err = openSecret(sealed)
+	assert.Error(t, err)
+}
+
+func TestKeyRotationRewrapsSecrets(t *testing.T) {
+	useKeyring(t, "k1:"+testKey('a'))
+	sealed, err := sealSecret("webhook-secret")
+	require.NoError(t, err)
+
+	// k2 becomes active; k1 stays for reading until everything is re-wrapped
+	useKeyring(t, "k2:"+testKey('b')+",k1:"+testKey('a'))
+	opened, err := openSecret(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "webhook-secret", opened)
+
+	rewrapped, changed, err := rewrapSecret(sealed)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	_, changed, err = rewrapSecret(rewrapped)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// Once k1 is retired only re-wrapped values can be read
+	useKeyring(t, "k2:"+testKey('b'))
+	_, err = openSecret(sealed)
+	assert.Error(t, err)
+	opened, err = openSecret(rewrapped)
+	require.NoError(t, err)
+	assert.Equal(t, "webhook-secret", opened)
+
+	_, err = parseKeyring("k1:short")
+	assert.Error(t, err)
+}
+
+func TestUserEmailsAreStoredEncrypted(t *testing.T) {
+	resetDirectory(t)
+	useKeyring(t, "k1:"+testKey('a'))
+	users.Save(User{ID: "ada", OrgID: "acme", Email: "Ada@Acme.test"})
+
+	assert.True(t, strings.HasPrefix(users.users["ada"].Email, sealedPrefix))
+	user, ok := users.FindByEmail("acme", "ada@acme.test")
+	require.True(t, ok)
+	assert.Equal(t, "Ada@Acme.test", user.Email)
+	_, ok = users.FindByEmail("other", "ada@acme.test")
+	assert.False(t, ok)
+
+	useKeyring(t, "k2:"+testKey('b')+",k1:"+testKey('a'))
+	rotateEncryptionKeys(time.Now())
+	useKeyring(t, "k2:"+testKey('b'))
+	user, _ = users.Get("ada")
+	assert.Equal(t, "Ada@Acme.test", user.Email)
+
+	// Changing the address replaces the old index entry
+	user.Email = "ada@new.test"
+	users.Save(user)
+	_, ok = users.FindByEmail("acme", "ada@acme.test")
+	assert.False(t, ok)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxDeliveriesPerWebhook bounds the log; older deliveries are dropped
+const maxDeliveriesPerWebhook = 100
+
+const (
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// WebhookDelivery is one event sent to one webhook, with every attempt
+// including manual replays. Payload is the exact body that was signed.
+type WebhookDelivery struct {
+	ID        string           `json:"id"`
+	WebhookID string           `json:"webhookId"`
+	EventType DomainEventType  `json:"eventType"`
+	EventID   string           `json:"eventId"`
+	Status    string           `json:"status"`
+	Payload   json.RawMessage  `json:"payload"`
+	Attempts  []WebhookAttempt `json:"attempts"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+// WebhookAttempt records how the receiver responded to one POST
+type WebhookAttempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	LatencyMs  int64     `json:"latencyMs"`
+	Error      string    `json:"error,omitempty"`
+	Replay     bool      `json:"replay,omitempty"`
+}
+
+func newWebhookDelivery(webhook Webhook, event DomainEvent) (WebhookDelivery, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+	return WebhookDelivery{
+		ID:        uuid.New().String(),
+		WebhookID: webhook.ID,
+		EventType: event.Type,
+		EventID:   event.EventID,
+		Payload:   body,
+		CreatedAt: clock.Now(),
+	}, nil
+}
+
+// attemptDelivery POSTs the delivery's payload to the webhook's current URL,
+// signed with its current secrets, and records the attempt on the delivery.
+// The X-Scheduler-Delivery header is stable across replays so receivers can
+// deduplicate.
+func attemptDelivery(webhook Webhook, delivery *WebhookDelivery, replay bool) WebhookAttempt {
+	attempt := WebhookAttempt{At: clock.Now(), Replay: replay}
+	started := time.Now()
+	err := postWebhook(webhook, *delivery, attempt.At, &attempt.StatusCode)
+	attempt.LatencyMs = time.Since(started).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		delivery.Status = DeliveryFailed
+	} else {
+		delivery.Status = DeliverySucceeded
+	}
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	return attempt
+}
+
+func postWebhook(webhook Webhook, delivery WebhookDelivery, now time.Time, statusCode *int) error {
+	secretList, err := webhook.signingSecrets(now)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Scheduler-Event", string(delivery.EventType))
+	req.Header.Set("X-Scheduler-Delivery", delivery.ID)
+	req.Header.Set(webhookSignatureHeader, signWebhook(secretList, now, delivery.Payload))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	*statusCode = resp.StatusCode
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("receiver returned %s", resp.Status)
+	}
+	return nil
+}
+
+// deliveryLog keeps each webhook's most recent deliveries in memory
+type deliveryLog struct {
+	mu         sync.RWMutex
+	deliveries map[string][]WebhookDelivery // by webhook ID, oldest first
+}
+
+func newDeliveryLog() *deliveryLog {
+	return &deliveryLog{deliveries: make(map[string][]WebhookDelivery)}
+}
+
+// Save adds or replaces a delivery, dropping the oldest past the limit
+func (l *deliveryLog) Save(delivery WebhookDelivery) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	deliveryList := l.deliveries[delivery.WebhookID]
+	for i := range deliveryList {
+		if deliveryList[i].ID == delivery.ID {
+			deliveryList[i] = delivery
+			return
+		}
+	}
+	deliveryList = append(deliveryList, delivery)
+	if len(deliveryList) > maxDeliveriesPerWebhook {
+		deliveryList = deliveryList[len(deliveryList)-maxDeliveriesPerWebhook:]
+	}
+	l.deliveries[delivery.WebhookID] = deliveryList
+}
+
+func (l *deliveryLog) Get(webhookID, id string) (WebhookDelivery, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, delivery := range l.deliveries[webhookID] {
+		if delivery.ID == id {
+			delivery.Attempts = append([]WebhookAttempt(nil), delivery.Attempts...)
+			return delivery, true
+		}
+	}
+	return WebhookDelivery{}, false
+}
+
+// List returns the webhook's deliveries, newest first
+func (l *deliveryLog) List(webhookID string) []WebhookDelivery {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	stored := l.deliveries[webhookID]
+	deliveryList := make([]WebhookDelivery, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		deliveryList = append(deliveryList, stored[i])
+	}
+	return deliveryList
+}
+
+// Forget drops the log of a deleted webhook
+func (l *deliveryLog) Forget(webhookID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.deliveries, webhookID)
+}
+
+var webhookDeliveries = newDeliveryLog()
+
+// Delivery handlers
+func listWebhookDeliveries(c *gin.Context) {
+	user, _ := currentUser(c)
+	webhook, ok := webhooks.Get(user.OrgID, c.Param("webhookId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	c.JSON(http.StatusOK, webhookDeliveries.List(webhook.ID))
+}
+
+// replayWebhookDelivery resends a logged delivery synchronously, so
+// integrators recovering from an outage see the outcome immediately
+func replayWebhookDelivery(c *gin.Context) {
+	user, _ := currentUser(c)
+	webhook, ok := webhooks.Get(user.OrgID, c.Param("webhookId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	delivery, ok := webhookDeliveries.Get(webhook.ID, c.Param("deliveryId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+
+	attemptDelivery(webhook, &delivery, true)
+	webhookDeliveries.Save(delivery)
+	c.JSON(http.StatusOK, delivery)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrBeforePrerequisite means an event was finalized at a time before one
+// of its prerequisites ends
+var ErrBeforePrerequisite = errors.New("slot starts before a prerequisite ends")
+
+// prerequisiteState is where an event's prerequisites stand: the latest
+// confirmed end among them, and those not scheduled yet
+type prerequisiteState struct {
+	notBefore time.Time
+	blocking  string
+	pending   []Event
+}
+
+// prerequisitesOf looks up the events an event depends on. Prerequisites
+// that have since been deleted no longer constrain it.
+func prerequisitesOf(store Store, event Event) (prerequisiteState, error) {
+	var state prerequisiteState
+	for _, id := range event.DependsOn {
+		prerequisite, err := store.GetEvent(id)
+		if err != nil {
+			continue
+		}
+		if prerequisite.Status != "finalized" || prerequisite.FinalTimeSlotID == "" {
+			state.pending = append(state.pending, prerequisite)
+			continue
+		}
+		slot, err := store.GetTimeSlot(prerequisite.FinalTimeSlotID)
+		if err != nil {
+			return prerequisiteState{}, err
+		}
+		if end := slot.StartTime.Add(prerequisite.duration()); end.After(state.notBefore) {
+			state.notBefore = end
+			state.blocking = prerequisite.Title
+		}
+	}
+	return state, nil
+}
+
+// validateDependencies checks an event's prerequisites exist and that
+// depending on them wouldn't make the event, eventually, its own
+// prerequisite. eventID is empty for events not created yet.
+func (s *Scheduler) validateDependencies(eventID string, dependsOn []string) error {
+	for _, id := range dependsOn {
+		if id == eventID {
+			return invalid("An event cannot depend on itself")
+		}
+		if _, err := s.store.GetEvent(id); err != nil {
+			return invalid(fmt.Sprintf("Prerequisite event %q not found", id))
+		}
+	}
+	if eventID == "" {
+		return nil
+	}
+
+	// Walk the prerequisites' own dependencies looking for a way back
+	seen := map[string]bool{}
+	queue := append([]string{}, dependsOn...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == eventID {
+			return invalid("Dependencies would form a cycle")
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if prerequisite, err := s.store.GetEvent(id); err == nil {
+			queue = append(queue, prerequisite.DependsOn...)
+		}
+	}
+	return nil
+}
+
+// Dependents lists the events that depend on an event
+func (s *Scheduler) Dependents(eventID string) ([]Event, error) {
+	if _, err := s.GetEvent(eventID); err != nil {
+		return nil, err
+	}
+	eventList, err := s.store.ListEvents()
+	if err != nil {
+		return nil, err
+	}
+	dependents := []Event{}
+	for _, event := range eventList {
+		if containsString(event.DependsOn, eventID) {
+			dependents = append(dependents, event)
+		}
+	}
+	return dependents, nil
+}
+
+func listDependents(c *gin.Context) {
+	dependents, err := contextScheduler(c).Dependents(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, dependents)
+}
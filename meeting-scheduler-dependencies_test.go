@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependentEventsFollowPrerequisites(t *testing.T) {
+	scheduler, fake := newTestScheduler(t)
+	review, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sprint review", OrganizerID: "ada", RequiredDuration: 60})
+	require.NoError(t, err)
+	retro, err := scheduler.CreateEvent(CreateEventRequest{Title: "Retro", OrganizerID: "ada", RequiredDuration: 60, DependsOn: []string{review.ID}})
+	require.NoError(t, err)
+
+	_, err = scheduler.CreateEvent(CreateEventRequest{Title: "Planning", OrganizerID: "ada", RequiredDuration: 60, DependsOn: []string{"missing"}})
+	assert.Error(t, err)
+	_, err = scheduler.UpdateEvent(review.ID, CreateEventRequest{Title: "Sprint review", OrganizerID: "ada", RequiredDuration: 60, DependsOn: []string{retro.ID}})
+	assert.Error(t, err, "review can't depend on the retro that depends on it")
+
+	day := fake.Now().Add(24 * time.Hour)
+	reviewSlot, err := scheduler.CreateTimeSlot(review.ID, CreateTimeSlotRequest{StartTime: day.Add(2 * time.Hour), EndTime: day.Add(3 * time.Hour)})
+	require.NoError(t, err)
+	var retroSlots []TimeSlot
+	for _, hour := range []int{1, 3, 5} {
+		start := day.Add(time.Duration(hour) * time.Hour)
+		slot, err := scheduler.CreateTimeSlot(retro.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+		require.NoError(t, err)
+		retroSlots = append(retroSlots, slot)
+		_, err = scheduler.SubmitAvailability(retro.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+		require.NoError(t, err)
+	}
+
+	// Until the review is confirmed every slot is considered, with a warning
+	recs, err := scheduler.Recommendations(retro.ID)
+	require.NoError(t, err)
+	require.Len(t, recs, 3)
+	assert.Contains(t, recs[0].Warnings, `Prerequisite "Sprint review" isn't scheduled yet`)
+
+	_, err = scheduler.FinalizeEvent(review.ID, FinalizeEventRequest{TimeSlotID: reviewSlot.ID})
+	require.NoError(t, err)
+	recs, err = scheduler.Recommendations(retro.ID)
+	require.NoError(t, err)
+	var starts []time.Time
+	for _, rec := range recs {
+		starts = append(starts, rec.TimeSlot.StartTime)
+		assert.Empty(t, rec.Warnings)
+	}
+	assert.ElementsMatch(t, []time.Time{retroSlots[1].StartTime, retroSlots[2].StartTime}, starts)
+
+	_, err = scheduler.FinalizeEvent(retro.ID, FinalizeEventRequest{TimeSlotID: retroSlots[0].ID})
+	assert.ErrorIs(t, err, ErrBeforePrerequisite)
+	_, err = scheduler.FinalizeEvent(retro.ID, FinalizeEventRequest{TimeSlotID: retroSlots[1].ID})
+	assert.NoError(t, err)
+
+	dependents, err := scheduler.Dependents(review.ID)
+	require.NoError(t, err)
+	require.Len(t, dependents, 1)
+	assert.Equal(t, retro.ID, dependents[0].ID)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Response update modes for organizers, set in their user settings
+const (
+	ResponseUpdatesOff       = ""
+	ResponseUpdatesImmediate = "immediate"
+	ResponseUpdatesHourly    = "hourly"
+	ResponseUpdatesDaily     = "daily"
+)
+
+// MessageDigest is the kind of batched response summaries
+const MessageDigest = "digest"
+
+// digestPeriods is how long each digest mode collects updates
+var digestPeriods = map[string]time.Duration{
+	ResponseUpdatesHourly: time.Hour,
+	ResponseUpdatesDaily:  24 * time.Hour,
+}
+
+func validResponseUpdates(mode string) bool {
+	_, digest := digestPeriods[mode]
+	return digest || mode == ResponseUpdatesOff || mode == ResponseUpdatesImmediate
+}
+
+// digestEntry is one availability change waiting for a digest
+type digestEntry struct {
+	EventID    string
+	EventTitle string
+	UserID     string
+	At         time.Time
+}
+
+// digestRegistry collects availability changes per organizer until their
+// digest is due
+type digestRegistry struct {
+	mu      sync.Mutex
+	pending map[string][]digestEntry
+}
+
+func newDigestRegistry() *digestRegistry {
+	return &digestRegistry{pending: make(map[string][]digestEntry)}
+}
+
+func (r *digestRegistry) Add(organizerID string, entry digestEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending[organizerID] = append(r.pending[organizerID], entry)
+}
+
+// Organizers lists the organizers with pending updates
+func (r *digestRegistry) Organizers() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]string, 0, len(r.pending))
+	for id := range r.pending {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// TakeDue removes and returns the organizer's pending updates once the
+// oldest was collected at or before cutoff
+func (r *digestRegistry) TakeDue(organizerID string, cutoff time.Time) []digestEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := r.pending[organizerID]
+	if len(entries) == 0 || entries[0].At.After(cutoff) {
+		return nil
+	}
+	delete(r.pending, organizerID)
+	return entries
+}
+
+var responseDigests = newDigestRegistry()
+
+// notifyResponse tells the organizer about a submitted or changed
+// response, right away or in their next digest as they chose
+func notifyResponse(e DomainEvent) {
+	avail, ok := e.Payload.(UserAvailability)
+	if !ok {
+		return
+	}
+	event, err := store.GetEvent(avail.EventID)
+	if err != nil || avail.UserID == event.OrganizerID {
+		return
+	}
+	organizer, ok := users.Get(event.OrganizerID)
+	if !ok {
+		return
+	}
+
+	switch mode := organizer.Settings.ResponseUpdates; {
+	case mode == ResponseUpdatesImmediate:
+		msg := Message{
+			To:      organizer,
+			OrgID:   event.OrgID,
+			EventID: event.ID,
+			Subject: "New response: " + event.Title,
+			Body:    fmt.Sprintf("%s responded to %s.", displayName(avail.UserID), event.Title),
+		}
+		if err := notifier.Notify(msg); err != nil {
+			log.Printf("Failed to notify %s about %s: %v", organizer.ID, event.ID, err)
+		}
+	case digestPeriods[mode] > 0:
+		responseDigests.Add(organizer.ID, digestEntry{EventID: event.ID, EventTitle: event.Title, UserID: avail.UserID, At: e.OccurredAt})
+	}
+}
+
+// sendResponseDigests is the background job sending organizers the
+// digests that are due
+func sendResponseDigests(now time.Time) {
+	for _, id := range responseDigests.Organizers() {
+		organizer, ok := users.Get(id)
+		if !ok {
+			responseDigests.TakeDue(id, now)
+			continue
+		}
+		period := digestPeriods[organizer.Settings.ResponseUpdates]
+		entries := responseDigests.TakeDue(id, now.Add(-period))
+		if len(entries) == 0 {
+			continue
+		}
+		if err := notifier.Notify(digestMessage(organizer, entries)); err != nil {
+			log.Printf("Failed to send response digest to %s: %v", id, err)
+		}
+	}
+}
+
+// digestMessage summarizes the updates by poll, naming each responder
+// once however many slots they answered
+func digestMessage(organizer User, entries []digestEntry) Message {
+	var order []string
+	titles := map[string]string{}
+	responders := map[string][]string{}
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		if _, ok := titles[entry.EventID]; !ok {
+			order = append(order, entry.EventID)
+			titles[entry.EventID] = entry.EventTitle
+		}
+		if key := entry.EventID + "/" + entry.UserID; !seen[key] {
+			seen[key] = true
+			responders[entry.EventID] = append(responders[entry.EventID], displayName(entry.UserID))
+		}
+	}
+
+	total := len(seen)
+	lines := make([]string, 0, len(order))
+	for _, id := range order {
+		lines = append(lines, fmt.Sprintf("%s: %s", titles[id], strings.Join(responders[id], ", ")))
+	}
+	subject := fmt.Sprintf("%d new responses to your polls", total)
+	if total == 1 {
+		subject = "1 new response to your polls"
+	}
+	msg := Message{
+		To:      organizer,
+		OrgID:   organizer.OrgID,
+		Kind:    MessageDigest,
+		Subject: subject,
+		Body:    "People responded to your polls.\n\n" + strings.Join(lines, "\n\n"),
+	}
+	if len(order) == 1 {
+		msg.EventID = order[0]
+	}
+	return msg
+}
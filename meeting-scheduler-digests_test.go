@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseDigestsBatchUpdatesPerOrganizer(t *testing.T) {
+	resetDirectory(t)
+	scheduler, fake := newTestScheduler(t)
+	recorder := &recordingNotifier{}
+	previous, previousDigests := notifier, responseDigests
+	notifier, responseDigests = recorder, newDigestRegistry()
+	t.Cleanup(func() { notifier, responseDigests = previous, previousDigests })
+	scheduler.bus.Subscribe(AvailabilitySubmitted, notifyResponse)
+	scheduler.bus.Subscribe(AvailabilityUpdated, notifyResponse)
+
+	users.Save(User{ID: "ada", OrgID: "acme", Email: "ada@acme.test", Settings: UserSettings{ResponseUpdates: ResponseUpdatesHourly}})
+	users.Save(User{ID: "bob", OrgID: "acme", Name: "Bob"})
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30, Invitees: []string{"bob", "cy"}})
+	require.NoError(t, err)
+	start := fake.Now().Add(24 * time.Hour)
+	var slots []TimeSlot
+	for i := 0; i < 2; i++ {
+		slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start.Add(time.Duration(i) * time.Hour), EndTime: start.Add(time.Duration(i+1) * time.Hour)})
+		require.NoError(t, err)
+		slots = append(slots, slot)
+	}
+
+	for _, slot := range slots {
+		_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+		require.NoError(t, err)
+	}
+	fake.Advance(30 * time.Minute)
+	_, err = scheduler.SubmitAvailability(event.ID, "cy", UserAvailabilityRequest{TimeSlotID: slots[0].ID, Status: "unavailable"})
+	require.NoError(t, err)
+	sendResponseDigests(fake.Now())
+	assert.Empty(t, recorder.messages, "the digest collects for an hour")
+
+	fake.Advance(30 * time.Minute)
+	sendResponseDigests(fake.Now())
+	require.Len(t, recorder.messages, 1)
+	digest := recorder.messages[0]
+	assert.Equal(t, MessageDigest, digest.Kind)
+	assert.Equal(t, "2 new responses to your polls", digest.Subject)
+	assert.Contains(t, digest.Body, "Kickoff: Bob, cy")
+	sendResponseDigests(fake.Now().Add(2 * time.Hour))
+	assert.Len(t, recorder.messages, 1, "nothing new to send")
+
+	ada, _ := users.Get("ada")
+	ada.Settings.ResponseUpdates = ResponseUpdatesImmediate
+	users.Save(ada)
+	_, err = scheduler.SubmitAvailability(event.ID, "dee", UserAvailabilityRequest{TimeSlotID: slots[1].ID, Status: "available"})
+	require.NoError(t, err)
+	require.Len(t, recorder.messages, 2)
+	assert.Equal(t, "New response: Kickoff", recorder.messages[1].Subject)
+}
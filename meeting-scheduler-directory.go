@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxDirectoryResults bounds an autocomplete response; clients narrow the
+// query rather than page through the directory
+const maxDirectoryResults = 20
+
+// DirectoryEntry is what colleagues see of a user when picking invitees
+type DirectoryEntry struct {
+	ID    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// matchesPrefix reports whether query, already lowercased, starts the
+// user's ID, email, name or any word of their name
+func matchesPrefix(user User, query string) bool {
+	candidates := append([]string{user.ID, user.Email, user.Name}, strings.Fields(user.Name)...)
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToLower(candidate), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// Search returns an organization's active users matching query by prefix,
+// ordered by name, at most limit of them. An empty query matches everyone.
+func (d *userDirectory) Search(orgID, query string, limit int) []User {
+	query = strings.ToLower(strings.TrimSpace(query))
+	var matches []User
+	for _, user := range d.List(orgID) {
+		if !user.Deactivated && matchesPrefix(user, query) {
+			matches = append(matches, user)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := strings.ToLower(matches[i].Name), strings.ToLower(matches[j].Name)
+		if a != b {
+			return a < b
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// searchDirectory autocompletes invitees from the caller's own
+// organization; the route policy turns away everyone else
+func searchDirectory(c *gin.Context) {
+	entries := []DirectoryEntry{}
+	for _, match := range users.Search(c.Param("orgId"), c.Query("query"), maxDirectoryResults) {
+		entries = append(entries, DirectoryEntry{ID: match.ID, Name: match.Name, Email: match.Email})
+	}
+	c.JSON(http.StatusOK, entries)
+}
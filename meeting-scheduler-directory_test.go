@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectoryAutocomplete(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	ada := User{ID: "u1", Name: "Ada Lovelace", Email: "ada@acme.test", OrgID: "acme", Role: RoleMember}
+	for _, user := range []User{
+		ada,
+		{ID: "u2", Name: "Grace Hopper", Email: "grace@acme.test", OrgID: "acme"},
+		{ID: "u3", Name: "Adele Goldberg", Email: "adele@acme.test", OrgID: "acme"},
+		{ID: "u4", Name: "Alan Kay", Email: "kay@acme.test", OrgID: "acme", Deactivated: true},
+		{ID: "u5", Name: "Adam Smith", Email: "adam@other.test", OrgID: "other"},
+	} {
+		users.Save(user)
+	}
+
+	search := func(user User, path string) *httptest.ResponseRecorder {
+		token, err := issueSessionToken(user)
+		require.NoError(t, err)
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	ids := func(path string) []string {
+		w := search(ada, path)
+		require.Equal(t, http.StatusOK, w.Code)
+		var entries []DirectoryEntry
+		decodeJSON(t, w, &entries)
+		found := []string{}
+		for _, entry := range entries {
+			found = append(found, entry.ID)
+		}
+		return found
+	}
+	assert.Equal(t, []string{"u1", "u3"}, ids("/api/v1/organizations/acme/users?query=AD"))
+	assert.Equal(t, []string{"u2"}, ids("/api/v1/organizations/acme/users?query=hop"), "any word of the name")
+	assert.Equal(t, []string{"u2"}, ids("/api/v1/organizations/acme/users?query=grace@"))
+	assert.Empty(t, ids("/api/v1/organizations/acme/users?query=kay"), "deactivated users are hidden")
+	assert.Equal(t, []string{"u1", "u3", "u2"}, ids("/api/v1/organizations/acme/users"))
+
+	assert.Equal(t, http.StatusForbidden, search(ada, "/api/v1/organizations/other/users?query=ad").Code)
+
+	for i := 0; i < maxDirectoryResults+5; i++ {
+		users.Save(User{ID: fmt.Sprintf("bulk-%02d", i), Name: "Bulk", OrgID: "acme"})
+	}
+	assert.Len(t, ids("/api/v1/organizations/acme/users?query=bulk"), maxDirectoryResults)
+}
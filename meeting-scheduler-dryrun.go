@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// dryRunStore reads through to the real store but discards writes. Updates
+// and deletes still report ErrNotFound for missing records, so a dry run
+// fails the same way the real call would.
+type dryRunStore struct {
+	Store
+}
+
+func (d dryRunStore) CreateEvent(event Event) error { return nil }
+
+func (d dryRunStore) UpdateEvent(event Event) error {
+	_, err := d.Store.GetEvent(event.ID)
+	return err
+}
+
+func (d dryRunStore) CompareAndSwapEvent(event, expected Event) error {
+	stored, err := d.Store.GetEvent(event.ID)
+	if err == nil && !sameFinalization(stored, expected) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (d dryRunStore) DeleteEvent(id string) error {
+	_, err := d.Store.GetEvent(id)
+	return err
+}
+
+func (d dryRunStore) CreateTimeSlot(slot TimeSlot) error { return nil }
+
+func (d dryRunStore) UpdateTimeSlot(slot TimeSlot) error {
+	_, err := d.Store.GetTimeSlot(slot.ID)
+	return err
+}
+
+func (d dryRunStore) DeleteTimeSlot(id string) error {
+	_, err := d.Store.GetTimeSlot(id)
+	return err
+}
+
+func (d dryRunStore) CreateAvailability(avail UserAvailability) error { return nil }
+
+func (d dryRunStore) UpdateAvailability(avail UserAvailability) error {
+	_, err := d.Store.FindAvailability(avail.EventID, avail.UserID, avail.TimeSlotID)
+	return err
+}
+
+// DeleteAvailability can't look the record up by ID, so it always succeeds;
+// callers find the record before deleting it
+func (d dryRunStore) DeleteAvailability(id string) error { return nil }
+
+func (d dryRunStore) WithTransaction(fn func(tx Store) error) error {
+	return d.Store.WithTransaction(func(tx Store) error {
+		return fn(dryRunStore{tx})
+	})
+}
+
+// DryRun returns a Scheduler that runs every check a mutation would and
+// returns its result, but persists and announces nothing
+func (s *Scheduler) DryRun() *Scheduler {
+	dry := *s
+	dry.store = dryRunStore{s.store}
+	dry.dryRun = true
+	return &dry
+}
+
+// requestScheduler is contextScheduler, bound to the enclosing atomic
+// batch's transaction if there is one, and switched to a dry run when the
+// request asks for one. Dry-run responses carry an X-Dry-Run header.
+func requestScheduler(c *gin.Context) *Scheduler {
+	scheduler := contextScheduler(c)
+	if tx, ok := c.Request.Context().Value(batchTxKey{}).(batchTx); ok {
+		scheduler = newScheduler(tx.store, clock, tx.bus).WithContext(c.Request.Context())
+	}
+	if c.Query("dryRun") == "true" {
+		c.Header("X-Dry-Run", "true")
+		return scheduler.DryRun()
+	}
+	return scheduler
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRunValidatesWithoutPersisting(t *testing.T) {
+	resetTrash(t)
+	scheduler, fake := newTestScheduler(t)
+	published := 0
+	scheduler.bus.SubscribeAll(func(DomainEvent) { published++ })
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	start := fake.Now().Add(24 * time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	published = 0
+
+	dry := scheduler.DryRun()
+	preview, err := dry.CreateEvent(CreateEventRequest{Title: "Retro", OrganizerID: "ada", RequiredDuration: 45})
+	require.NoError(t, err)
+	assert.Equal(t, "Retro", preview.Title)
+	_, err = scheduler.GetEvent(preview.ID)
+	assert.ErrorIs(t, err, ErrEventNotFound)
+
+	_, err = dry.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "maybe"})
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr, "validation still runs")
+	_, err = dry.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+	_, err = dry.FinalizeEvent(event.ID, FinalizeEventRequest{TimeSlotID: slot.ID})
+	require.NoError(t, err)
+	require.NoError(t, dry.DeleteTimeSlot(slot.ID))
+	require.NoError(t, dry.DeleteEvent(event.ID))
+	assert.ErrorIs(t, dry.DeleteTimeSlot("missing"), ErrTimeSlotNotFound)
+
+	stored, err := scheduler.GetEvent(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "active", stored.Status)
+	slots, err := scheduler.ListTimeSlots(event.ID)
+	require.NoError(t, err)
+	assert.Len(t, slots, 1)
+	responses, err := store.ListAvailability(event.ID)
+	require.NoError(t, err)
+	assert.Empty(t, responses)
+	assert.Empty(t, trash.ForOrg(""))
+	assert.Zero(t, published, "dry runs announce nothing")
+}
+
+func TestDryRunQueryParameter(t *testing.T) {
+	router := newTestRouter(t)
+
+	w := doJSON(router, "POST", "/api/v1/events?dryRun=true", CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 30})
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "true", w.Header().Get("X-Dry-Run"))
+	var preview Event
+	decodeJSON(t, w, &preview)
+	assert.Equal(t, "Sync", preview.Title)
+	stored, err := store.ListEvents()
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+
+	w = doJSON(router, "POST", "/api/v1/events?dryRun=true", CreateEventRequest{Title: "Sync", OrganizerID: "ada"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isoDurationPattern matches the ISO-8601 durations meetings are planned
+// in: days, hours, minutes and seconds, e.g. P1D, PT25M or PT1M30S.
+// Months and years have no fixed length and are not accepted.
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseISODuration parses an ISO-8601 duration such as PT90S or PT50M
+func parseISODuration(value string) (time.Duration, error) {
+	upper := strings.ToUpper(value)
+	match := isoDurationPattern.FindStringSubmatch(upper)
+	if match == nil || upper == "P" || strings.HasSuffix(upper, "T") {
+		return 0, fmt.Errorf("%q is not an ISO-8601 duration such as PT25M or PT90S", value)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute}
+	var total time.Duration
+	for i, unit := range units {
+		if match[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(match[i+1])
+		if err != nil {
+			return 0, fmt.Errorf("%q is out of range", value)
+		}
+		total += time.Duration(n) * unit
+	}
+	if match[5] != "" {
+		seconds, err := strconv.ParseFloat(match[5], 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is out of range", value)
+		}
+		total += time.Duration(seconds * float64(time.Second))
+	}
+	return total, nil
+}
+
+// formatISODuration renders a duration in ISO-8601, to whole seconds
+func formatISODuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d <= 0 {
+		return "PT0S"
+	}
+	var b strings.Builder
+	b.WriteString("P")
+	if days := d / (24 * time.Hour); days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+		d -= days * 24 * time.Hour
+	}
+	if d > 0 {
+		b.WriteString("T")
+		for _, unit := range []struct {
+			size   time.Duration
+			suffix string
+		}{{time.Hour, "H"}, {time.Minute, "M"}, {time.Second, "S"}} {
+			if n := d / unit.size; n > 0 {
+				fmt.Fprintf(&b, "%d%s", n, unit.suffix)
+				d -= n * unit.size
+			}
+		}
+	}
+	return b.String()
+}
+
+// duration is how long the event's meeting runs. Events created before
+// durations could be finer than a minute only have RequiredDuration.
+func (e Event) duration() time.Duration {
+	if e.DurationSeconds > 0 {
+		return time.Duration(e.DurationSeconds) * time.Second
+	}
+	return time.Duration(e.RequiredDuration) * time.Minute
+}
+
+// withDuration resolves an ISO-8601 Duration into the request's duration
+// fields. RequiredDuration is rounded up to whole minutes for clients that
+// only read minutes.
+func withDuration(req CreateEventRequest) (CreateEventRequest, error) {
+	if req.Duration == "" {
+		return req, nil
+	}
+	d, err := parseISODuration(req.Duration)
+	if err != nil {
+		return req, invalid(err.Error())
+	}
+	if d < time.Second {
+		return req, invalid("Duration must be at least a second")
+	}
+	minutes := int((d + time.Minute - 1) / time.Minute)
+	if req.RequiredDuration != 0 && req.RequiredDuration*int(time.Minute) != int(d) {
+		return req, invalid("Duration and required duration disagree; set just one")
+	}
+	req.RequiredDuration = minutes
+	req.DurationSeconds = int(d / time.Second)
+	return req, nil
+}
+
+// durationSeconds is the length of the event a request describes
+func (req CreateEventRequest) durationSeconds() int {
+	if req.DurationSeconds > 0 {
+		return req.DurationSeconds
+	}
+	return req.RequiredDuration * 60
+}
+
+// orgGranularity is the organization's default spacing between generated
+// slots, if it has set one
+func orgGranularity(orgID string) (time.Duration, bool) {
+	org, ok := organizations.Get(orgID)
+	if !ok || org.SlotGranularity == "" {
+		return 0, false
+	}
+	d, err := parseISODuration(org.SlotGranularity)
+	if err != nil || d <= 0 {
+		return 0, false
+	}
+	return d, true
+}
+
+type SlotGranularityRequest struct {
+	// SlotGranularity is an ISO-8601 duration; empty clears it
+	SlotGranularity string `json:"slotGranularity"`
+}
+
+// Slot granularity handlers
+func updateSlotGranularity(c *gin.Context) {
+	org, ok := organizations.Get(c.Param("orgId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	var req SlotGranularityRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.SlotGranularity != "" {
+		d, err := parseISODuration(req.SlotGranularity)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if d < time.Second {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Slot granularity must be at least a second"})
+			return
+		}
+		req.SlotGranularity = formatISODuration(d)
+	}
+
+	org.SlotGranularity = req.SlotGranularity
+	org.UpdatedAt = clock.Now()
+	organizations.Save(org)
+	c.JSON(http.StatusOK, req)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseISODuration(t *testing.T) {
+	for value, want := range map[string]time.Duration{
+		"PT90S":   90 * time.Second,
+		"PT25M":   25 * time.Minute,
+		"PT1H30M": 90 * time.Minute,
+		"pt1m30s": 90 * time.Second,
+		"P1D":     24 * time.Hour,
+		"PT0.5S":  500 * time.Millisecond,
+	} {
+		got, err := parseISODuration(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, want, got, value)
+	}
+	for _, value := range []string{"", "P", "PT", "P1M", "25M", "PT-5M"} {
+		_, err := parseISODuration(value)
+		assert.Error(t, err, value)
+	}
+	assert.Equal(t, "PT1M30S", formatISODuration(90*time.Second))
+	assert.Equal(t, "P1DT2H", formatISODuration(26*time.Hour))
+}
+
+func TestSubMinuteEventsUseOrgGranularity(t *testing.T) {
+	resetDirectory(t)
+	scheduler, fake := newTestScheduler(t)
+	users.Save(User{ID: "ada", OrgID: "acme", Role: RoleOrganizer})
+	organizations.Save(Organization{ID: "acme", SlotGranularity: "PT2M"})
+
+	_, err := scheduler.CreateEvent(CreateEventRequest{Title: "Rotation", OrganizerID: "ada", Duration: "PT90S", RequiredDuration: 1})
+	assert.Error(t, err, "duration and required duration disagree")
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Rotation", OrganizerID: "ada", Duration: "PT90S"})
+	require.NoError(t, err)
+	assert.Equal(t, 90, event.DurationSeconds)
+	assert.Equal(t, 2, event.RequiredDuration)
+
+	from := fake.Now().Add(24 * time.Hour)
+	slots, err := scheduler.GenerateTimeSlots(event.ID, GenerateTimeSlotsRequest{From: from, To: from.Add(6 * time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, slots, 3)
+	assert.Equal(t, from.Add(2*time.Minute), slots[1].StartTime)
+	assert.Equal(t, 90*time.Second, slots[1].EndTime.Sub(slots[1].StartTime))
+
+	slots, err = scheduler.GenerateTimeSlots(event.ID, GenerateTimeSlotsRequest{From: from, To: from.Add(3 * time.Minute), Step: "PT90S"})
+	require.NoError(t, err)
+	assert.Len(t, slots, 2)
+}
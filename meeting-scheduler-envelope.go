@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// envelopeMediaType is the Accept type clients send to get enveloped
+// responses. Plain application/json keeps the bare v1 bodies.
+const envelopeMediaType = "application/vnd.meetingscheduler+json"
+
+// Envelope wraps a response body with paging metadata and links to the
+// resources around it. Failed requests carry Error instead of Data.
+type Envelope struct {
+	Data  json.RawMessage   `json:"data"`
+	Error string            `json:"error,omitempty"`
+	Meta  *PageMeta         `json:"meta,omitempty"`
+	Links map[string]string `json:"links"`
+}
+
+// PageMeta describes the page of a list returned. Limit is 0 when the
+// whole list was returned.
+type PageMeta struct {
+	Total  int `json:"total"`
+	Offset int `json:"offset"`
+	Limit  int `json:"limit,omitempty"`
+}
+
+// pageRequest is the ?offset= and ?limit= of an enveloped list request
+type pageRequest struct {
+	offset int
+	limit  int
+}
+
+func parsePage(c *gin.Context) (pageRequest, error) {
+	var page pageRequest
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"offset", &page.offset}, {"limit", &page.limit}} {
+		raw := c.Query(param.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return pageRequest{}, invalid(param.name + " must be a non-negative integer")
+		}
+		*param.value = n
+	}
+	return page, nil
+}
+
+// apply cuts the page out of list
+func (p pageRequest) apply(list []interface{}) ([]interface{}, *PageMeta) {
+	meta := &PageMeta{Total: len(list), Offset: p.offset, Limit: p.limit}
+	start := min(p.offset, len(list))
+	end := len(list)
+	if p.limit > 0 {
+		end = min(start+p.limit, len(list))
+	}
+	return list[start:end], meta
+}
+
+// pageLink is the request's own URL moved to another offset
+func pageLink(c *gin.Context, offset int) string {
+	u := *c.Request.URL
+	query := u.Query()
+	query.Set("offset", strconv.Itoa(offset))
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
+}
+
+// eventLinks points at an event and what hangs off it. {userId} is left
+// for the client to fill in.
+func eventLinks(links map[string]string, eventID string) {
+	base := "/api/v1/events/" + eventID
+	links["event"] = base
+	links["timeslots"] = base + "/timeslots"
+	links["availability"] = base + "/users/{userId}/availability"
+	links["responses"] = base + "/responses"
+	links["recommendations"] = base + "/recommendations"
+	links["timeline"] = base + "/timeline"
+}
+
+// envelopeLinks builds the links for a response: itself, and for event
+// routes the event's related resources. Event lists link to a template
+// for their items.
+func envelopeLinks(c *gin.Context, document interface{}) map[string]string {
+	links := map[string]string{"self": c.Request.URL.RequestURI()}
+	eventID := c.Param("eventId")
+	if eventID == "" && c.FullPath() == "/api/v1/events" {
+		switch data := document.(type) {
+		case map[string]interface{}:
+			eventID, _ = data["id"].(string)
+		case []interface{}:
+			links["event"] = "/api/v1/events/{id}"
+		}
+	}
+	if eventID != "" {
+		eventLinks(links, eventID)
+		if slotID := c.Param("timeslotId"); slotID != "" {
+			links["timeslot"] = "/api/v1/events/" + eventID + "/timeslots/" + slotID
+		}
+	}
+	return links
+}
+
+// wrapResponse wraps a held-back response body in an Envelope
+func wrapResponse(c *gin.Context, status int, body []byte, page pageRequest) []byte {
+	document, err := decodeDocument(body)
+	if err != nil {
+		return body
+	}
+	wrapped := Envelope{Links: envelopeLinks(c, document)}
+	if status >= http.StatusBadRequest {
+		if data, ok := document.(map[string]interface{}); ok {
+			wrapped.Error, _ = data["error"].(string)
+		}
+	} else {
+		if list, ok := document.([]interface{}); ok {
+			document, wrapped.Meta = page.apply(list)
+			if page.limit > 0 && page.offset+page.limit < wrapped.Meta.Total {
+				wrapped.Links["next"] = pageLink(c, page.offset+page.limit)
+			}
+			if page.offset > 0 {
+				previous := 0
+				if page.limit > 0 {
+					previous = max(page.offset-page.limit, 0)
+				}
+				wrapped.Links["prev"] = pageLink(c, previous)
+			}
+		}
+		if wrapped.Data, err = json.Marshal(document); err != nil {
+			return body
+		}
+	}
+	enveloped, err := json.Marshal(wrapped)
+	if err != nil {
+		return body
+	}
+	return enveloped
+}
+
+// envelopeResponses wraps JSON responses in an Envelope for clients that
+// accept envelopeMediaType, paging list responses by ?offset= and ?limit=
+func envelopeResponses(c *gin.Context) {
+	if !strings.Contains(c.GetHeader("Accept"), envelopeMediaType) {
+		c.Next()
+		return
+	}
+	page, err := parsePage(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, Envelope{Error: err.Error(), Links: map[string]string{"self": c.Request.URL.RequestURI()}})
+		return
+	}
+	w := &jsonHoldWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+	c.Next()
+	w.finish(func(body []byte) []byte { return wrapResponse(c, w.Status(), body, page) })
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getEnveloped(t *testing.T, router http.Handler, path string) (int, Envelope) {
+	t.Helper()
+	req, _ := http.NewRequest("GET", path, nil)
+	req.Header.Set("Accept", envelopeMediaType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var envelope Envelope
+	decodeJSON(t, w, &envelope)
+	return w.Code, envelope
+}
+
+func TestEnvelopeWrapsResponsesWithLinks(t *testing.T) {
+	router := newTestRouter(t)
+	event, err := currentScheduler().CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+
+	code, envelope := getEnveloped(t, router, "/api/v1/events/"+event.ID)
+	require.Equal(t, http.StatusOK, code)
+	var data Event
+	require.NoError(t, json.Unmarshal(envelope.Data, &data))
+	assert.Equal(t, "Kickoff", data.Title)
+	assert.Nil(t, envelope.Meta)
+	assert.Equal(t, "/api/v1/events/"+event.ID, envelope.Links["self"])
+	assert.Equal(t, "/api/v1/events/"+event.ID+"/timeslots", envelope.Links["timeslots"])
+	assert.Equal(t, "/api/v1/events/"+event.ID+"/recommendations", envelope.Links["recommendations"])
+
+	code, envelope = getEnveloped(t, router, "/api/v1/events/missing")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, "Event not found", envelope.Error)
+	assert.Equal(t, "null", string(envelope.Data))
+
+	w := doJSON(router, "GET", "/api/v1/events/"+event.ID, nil)
+	decodeJSON(t, w, &data)
+	assert.Equal(t, event.ID, data.ID, "plain JSON clients get bare bodies")
+}
+
+func TestEnvelopePagesLists(t *testing.T) {
+	router := newTestRouter(t)
+	for _, title := range []string{"One", "Two", "Three"} {
+		_, err := currentScheduler().CreateEvent(CreateEventRequest{Title: title, OrganizerID: "ada", RequiredDuration: 30})
+		require.NoError(t, err)
+	}
+
+	code, envelope := getEnveloped(t, router, "/api/v1/events?limit=2")
+	require.Equal(t, http.StatusOK, code)
+	var page []Event
+	require.NoError(t, json.Unmarshal(envelope.Data, &page))
+	assert.Len(t, page, 2)
+	assert.Equal(t, &PageMeta{Total: 3, Offset: 0, Limit: 2}, envelope.Meta)
+	assert.Equal(t, "/api/v1/events?limit=2&offset=2", envelope.Links["next"])
+	assert.Equal(t, "/api/v1/events/{id}", envelope.Links["event"])
+
+	_, envelope = getEnveloped(t, router, envelope.Links["next"])
+	require.NoError(t, json.Unmarshal(envelope.Data, &page))
+	assert.Len(t, page, 1)
+	assert.Empty(t, envelope.Links["next"])
+	assert.Equal(t, "/api/v1/events?limit=2&offset=0", envelope.Links["prev"])
+
+	code, envelope = getEnveloped(t, router, "/api/v1/events?limit=-1")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.NotEmpty(t, envelope.Error)
+}
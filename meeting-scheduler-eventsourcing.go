@@ -0,0 +1,547 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StoredEventType names a change recorded in the event log
+type StoredEventType string
+
+const (
+	StoredEventCreated      StoredEventType = "EventCreated"
+	StoredEventEdited       StoredEventType = "EventEdited"
+	StoredEventFinalized    StoredEventType = "EventFinalized"
+	StoredEventCancelled    StoredEventType = "EventCancelled"
+	StoredEventRemoved      StoredEventType = "EventRemoved"
+	StoredSlotProposed      StoredEventType = "SlotProposed"
+	StoredSlotMoved         StoredEventType = "SlotMoved"
+	StoredSlotWithdrawn     StoredEventType = "SlotWithdrawn"
+	StoredResponseRecorded  StoredEventType = "ResponseRecorded"
+	StoredResponseChanged   StoredEventType = "ResponseChanged"
+	StoredResponseWithdrawn StoredEventType = "ResponseWithdrawn"
+)
+
+// StoredEvent is one change in the event log. It carries the whole
+// record as it was after the change, or before it for withdrawn
+// responses; removed events and slots carry only their ID.
+type StoredEvent struct {
+	Seq          uint64            `json:"seq"`
+	At           time.Time         `json:"at"`
+	Type         StoredEventType   `json:"type"`
+	EventID      string            `json:"eventId"`
+	ID           string            `json:"id"`
+	Event        *Event            `json:"event,omitempty"`
+	TimeSlot     *TimeSlot         `json:"timeslot,omitempty"`
+	Availability *UserAvailability `json:"availability,omitempty"`
+}
+
+// eventSourcedStore keeps the scheduling domain as an append-only log of
+// StoredEvents, the source of truth, with the current state projected
+// into memory for reads. Each transaction is appended as one line, a JSON
+// array of its events, and synced before it counts as written, so a line
+// torn by a crash is dropped whole on the next start. Opening the store
+// replays the log; every event's history stays available for audits,
+// timelines and point-in-time snapshots.
+type eventSourcedStore struct {
+	projection *memoryStore
+
+	// mu guards the log and the history index; appends already run
+	// under the projection's lock
+	mu      sync.RWMutex
+	file    *os.File
+	seq     uint64
+	history map[string][]StoredEvent
+}
+
+// eventLog is the event-sourced store when EVENT_LOG_FILE is set
+var eventLog *eventSourcedStore
+
+// openEventSourcedStore replays the log at path, creating it if need be,
+// and appends to it from then on
+func openEventSourcedStore(path string) (*eventSourcedStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	// Caps would evict records the log still holds, so the projection
+	// has none
+	s := &eventSourcedStore{
+		projection: &memoryStore{usage: newMemoryUsage()},
+		file:       file,
+		history:    map[string][]StoredEvent{},
+	}
+	if err := s.replay(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("replaying %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// replay applies every complete line of the log to the projection and
+// truncates a torn last line
+func (s *eventSourcedStore) replay() error {
+	reader := bufio.NewReader(s.file)
+	var offset int64
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(data)) > 0 {
+				log.Printf("Event log ends in an incomplete write; dropping it")
+				if err := s.file.Truncate(offset); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		offset += int64(len(data))
+		var stored []StoredEvent
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		err = s.projection.WithTransaction(func(tx Store) error {
+			for _, e := range stored {
+				if err := applyStoredEvent(tx, e); err != nil {
+					return fmt.Errorf("line %d, event %d: %w", line, e.Seq, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		s.index(stored)
+	}
+	_, err := s.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// applyStoredEvent projects one logged change onto tx
+func applyStoredEvent(tx Store, e StoredEvent) error {
+	switch {
+	case e.Type == StoredEventCreated && e.Event != nil:
+		return tx.CreateEvent(*e.Event)
+	case (e.Type == StoredEventEdited || e.Type == StoredEventFinalized || e.Type == StoredEventCancelled) && e.Event != nil:
+		return tx.UpdateEvent(*e.Event)
+	case e.Type == StoredEventRemoved:
+		return tx.DeleteEvent(e.ID)
+	case e.Type == StoredSlotProposed && e.TimeSlot != nil:
+		return tx.CreateTimeSlot(*e.TimeSlot)
+	case e.Type == StoredSlotMoved && e.TimeSlot != nil:
+		return tx.UpdateTimeSlot(*e.TimeSlot)
+	case e.Type == StoredSlotWithdrawn:
+		return tx.DeleteTimeSlot(e.ID)
+	case e.Type == StoredResponseRecorded && e.Availability != nil:
+		return tx.CreateAvailability(*e.Availability)
+	case e.Type == StoredResponseChanged && e.Availability != nil:
+		return tx.UpdateAvailability(*e.Availability)
+	case e.Type == StoredResponseWithdrawn:
+		return tx.DeleteAvailability(e.ID)
+	}
+	return fmt.Errorf("malformed %s event", e.Type)
+}
+
+// append writes one transaction's events to the log, numbering them
+func (s *eventSourcedStore) append(stored []StoredEvent) error {
+	if len(stored) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range stored {
+		stored[i].Seq = s.seq + uint64(i) + 1
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.indexLocked(stored)
+	return nil
+}
+
+func (s *eventSourcedStore) index(stored []StoredEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.indexLocked(stored)
+}
+
+func (s *eventSourcedStore) indexLocked(stored []StoredEvent) {
+	for _, e := range stored {
+		s.history[e.EventID] = append(s.history[e.EventID], e)
+		s.seq = max(s.seq, e.Seq)
+	}
+}
+
+// History returns an event's logged changes, oldest first, including
+// those of an event since removed
+func (s *eventSourcedStore) History(eventID string) []StoredEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]StoredEvent{}, s.history[eventID]...)
+}
+
+// EventSnapshot is an event's state as of a point in its history. Event
+// is nil when the event didn't exist at the time.
+type EventSnapshot struct {
+	At           time.Time          `json:"at"`
+	Seq          uint64             `json:"seq"`
+	Event        *Event             `json:"event"`
+	TimeSlots    []TimeSlot         `json:"timeSlots"`
+	Availability []UserAvailability `json:"availability"`
+}
+
+// SnapshotAt folds an event's history up to and including at into its
+// state at that time, ordered like an export
+func (s *eventSourcedStore) SnapshotAt(eventID string, at time.Time) EventSnapshot {
+	snapshot := EventSnapshot{At: at}
+	slots := map[string]TimeSlot{}
+	responses := map[string]UserAvailability{}
+	for _, e := range s.History(eventID) {
+		if e.At.After(at) {
+			break
+		}
+		snapshot.Seq = e.Seq
+		switch e.Type {
+		case StoredEventCreated, StoredEventEdited, StoredEventFinalized, StoredEventCancelled:
+			snapshot.Event = e.Event
+		case StoredEventRemoved:
+			snapshot.Event = nil
+			slots, responses = map[string]TimeSlot{}, map[string]UserAvailability{}
+		case StoredSlotProposed, StoredSlotMoved:
+			slots[e.ID] = *e.TimeSlot
+		case StoredSlotWithdrawn:
+			delete(slots, e.ID)
+		case StoredResponseRecorded, StoredResponseChanged:
+			responses[e.ID] = *e.Availability
+		case StoredResponseWithdrawn:
+			delete(responses, e.ID)
+		}
+	}
+
+	snapshot.TimeSlots = make([]TimeSlot, 0, len(slots))
+	for _, slot := range slots {
+		snapshot.TimeSlots = append(snapshot.TimeSlots, slot)
+	}
+	sort.Slice(snapshot.TimeSlots, func(i, j int) bool {
+		a, b := snapshot.TimeSlots[i], snapshot.TimeSlots[j]
+		if !a.StartTime.Equal(b.StartTime) {
+			return a.StartTime.Before(b.StartTime)
+		}
+		return a.ID < b.ID
+	})
+	snapshot.Availability = make([]UserAvailability, 0, len(responses))
+	for _, avail := range responses {
+		snapshot.Availability = append(snapshot.Availability, avail)
+	}
+	sort.Slice(snapshot.Availability, func(i, j int) bool {
+		a, b := snapshot.Availability[i], snapshot.Availability[j]
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		return a.TimeSlotID < b.TimeSlotID
+	})
+	return snapshot
+}
+
+// storedDomainEvents maps logged changes to the domain events published
+// when they were made
+var storedDomainEvents = map[StoredEventType]DomainEventType{
+	StoredEventCreated:      EventCreated,
+	StoredEventEdited:       EventUpdated,
+	StoredEventFinalized:    EventFinalized,
+	StoredEventCancelled:    EventCancelled,
+	StoredEventRemoved:      EventDeleted,
+	StoredSlotProposed:      TimeSlotCreated,
+	StoredSlotMoved:         TimeSlotUpdated,
+	StoredSlotWithdrawn:     TimeSlotDeleted,
+	StoredResponseRecorded:  AvailabilitySubmitted,
+	StoredResponseChanged:   AvailabilityUpdated,
+	StoredResponseWithdrawn: AvailabilityDeleted,
+}
+
+// Redeliver hands every logged change, oldest first, to handler as the
+// domain event it was published as. At startup it rebuilds the activity
+// timelines, so they survive restarts like the state does.
+func (s *eventSourcedStore) Redeliver(handler DomainEventHandler) {
+	s.mu.RLock()
+	var all []StoredEvent
+	for _, history := range s.history {
+		all = append(all, history...)
+	}
+	s.mu.RUnlock()
+	sort.Slice(all, func(i, j int) bool { return all[i].Seq < all[j].Seq })
+
+	for _, e := range all {
+		domainEvent := DomainEvent{Type: storedDomainEvents[e.Type], EventID: e.EventID, OccurredAt: e.At}
+		switch {
+		case e.Event != nil:
+			domainEvent.Payload, domainEvent.OrgID = *e.Event, e.Event.OrgID
+		case e.TimeSlot != nil:
+			domainEvent.Payload = *e.TimeSlot
+		case e.Availability != nil:
+			domainEvent.Payload = *e.Availability
+		}
+		deliver(handler, domainEvent)
+	}
+}
+
+func (s *eventSourcedStore) Close() error {
+	return s.file.Close()
+}
+
+// WithTransaction runs fn against the projection, logging what it
+// changes. A failed append rolls the projection back with the rest.
+func (s *eventSourcedStore) WithTransaction(fn func(tx Store) error) error {
+	return s.projection.WithTransaction(func(tx Store) error {
+		// The projection's lock is held, so its map can be read directly
+		response := func(id string) (UserAvailability, bool) {
+			avail, ok := userAvailability[id]
+			return avail, ok
+		}
+		recording := &recordingTx{Store: tx, at: clock.Now(), response: response}
+		if err := fn(recording); err != nil {
+			return err
+		}
+		return s.append(recording.stored)
+	})
+}
+
+func (s *eventSourcedStore) CreateEvent(event Event) error {
+	return s.WithTransaction(func(tx Store) error { return tx.CreateEvent(event) })
+}
+
+func (s *eventSourcedStore) GetEvent(id string) (Event, error) {
+	return s.projection.GetEvent(id)
+}
+
+func (s *eventSourcedStore) ListEvents() ([]Event, error) {
+	return s.projection.ListEvents()
+}
+
+func (s *eventSourcedStore) UpdateEvent(event Event) error {
+	return s.WithTransaction(func(tx Store) error { return tx.UpdateEvent(event) })
+}
+
+func (s *eventSourcedStore) CompareAndSwapEvent(event, expected Event) error {
+	return s.WithTransaction(func(tx Store) error { return tx.CompareAndSwapEvent(event, expected) })
+}
+
+func (s *eventSourcedStore) DeleteEvent(id string) error {
+	return s.WithTransaction(func(tx Store) error { return tx.DeleteEvent(id) })
+}
+
+func (s *eventSourcedStore) CreateTimeSlot(slot TimeSlot) error {
+	return s.WithTransaction(func(tx Store) error { return tx.CreateTimeSlot(slot) })
+}
+
+func (s *eventSourcedStore) GetTimeSlot(id string) (TimeSlot, error) {
+	return s.projection.GetTimeSlot(id)
+}
+
+func (s *eventSourcedStore) ListTimeSlots(eventID string) ([]TimeSlot, error) {
+	return s.projection.ListTimeSlots(eventID)
+}
+
+func (s *eventSourcedStore) UpdateTimeSlot(slot TimeSlot) error {
+	return s.WithTransaction(func(tx Store) error { return tx.UpdateTimeSlot(slot) })
+}
+
+func (s *eventSourcedStore) DeleteTimeSlot(id string) error {
+	return s.WithTransaction(func(tx Store) error { return tx.DeleteTimeSlot(id) })
+}
+
+func (s *eventSourcedStore) CreateAvailability(avail UserAvailability) error {
+	return s.WithTransaction(func(tx Store) error { return tx.CreateAvailability(avail) })
+}
+
+func (s *eventSourcedStore) FindAvailability(eventID, userID, timeslotID string) (UserAvailability, error) {
+	return s.projection.FindAvailability(eventID, userID, timeslotID)
+}
+
+func (s *eventSourcedStore) ListAvailability(eventID string) ([]UserAvailability, error) {
+	return s.projection.ListAvailability(eventID)
+}
+
+func (s *eventSourcedStore) ListUserAvailability(eventID, userID string) ([]UserAvailability, error) {
+	return s.projection.ListUserAvailability(eventID, userID)
+}
+
+func (s *eventSourcedStore) UpdateAvailability(avail UserAvailability) error {
+	return s.WithTransaction(func(tx Store) error { return tx.UpdateAvailability(avail) })
+}
+
+func (s *eventSourcedStore) DeleteAvailability(id string) error {
+	return s.WithTransaction(func(tx Store) error { return tx.DeleteAvailability(id) })
+}
+
+// recordingTx applies writes to the projection and notes each one that
+// succeeds as a StoredEvent
+type recordingTx struct {
+	Store
+	at     time.Time
+	stored []StoredEvent
+	// response finds a response by ID, which the Store interface can't,
+	// so withdrawals can record whose it was. Without it they carry only
+	// the ID.
+	response func(id string) (UserAvailability, bool)
+}
+
+func (tx *recordingTx) record(e StoredEvent) {
+	e.At = tx.at
+	tx.stored = append(tx.stored, e)
+}
+
+func (tx *recordingTx) CreateEvent(event Event) error {
+	if err := tx.Store.CreateEvent(event); err != nil {
+		return err
+	}
+	tx.record(StoredEvent{Type: StoredEventCreated, EventID: event.ID, ID: event.ID, Event: &event})
+	return nil
+}
+
+// eventChange names an update by what it does to the event: finalizing
+// it, moving its final slot included, cancelling it, or editing it
+func eventChange(previous, event Event) StoredEventType {
+	switch {
+	case event.Status == "finalized" && (previous.Status != "finalized" || previous.FinalTimeSlotID != event.FinalTimeSlotID):
+		return StoredEventFinalized
+	case event.Status == "cancelled" && previous.Status != "cancelled":
+		return StoredEventCancelled
+	}
+	return StoredEventEdited
+}
+
+func (tx *recordingTx) UpdateEvent(event Event) error {
+	previous, _ := tx.Store.GetEvent(event.ID)
+	if err := tx.Store.UpdateEvent(event); err != nil {
+		return err
+	}
+	tx.record(StoredEvent{Type: eventChange(previous, event), EventID: event.ID, ID: event.ID, Event: &event})
+	return nil
+}
+
+func (tx *recordingTx) CompareAndSwapEvent(event, expected Event) error {
+	previous, _ := tx.Store.GetEvent(event.ID)
+	if err := tx.Store.CompareAndSwapEvent(event, expected); err != nil {
+		return err
+	}
+	tx.record(StoredEvent{Type: eventChange(previous, event), EventID: event.ID, ID: event.ID, Event: &event})
+	return nil
+}
+
+func (tx *recordingTx) DeleteEvent(id string) error {
+	if err := tx.Store.DeleteEvent(id); err != nil {
+		return err
+	}
+	tx.record(StoredEvent{Type: StoredEventRemoved, EventID: id, ID: id})
+	return nil
+}
+
+func (tx *recordingTx) CreateTimeSlot(slot TimeSlot) error {
+	if err := tx.Store.CreateTimeSlot(slot); err != nil {
+		return err
+	}
+	tx.record(StoredEvent{Type: StoredSlotProposed, EventID: slot.EventID, ID: slot.ID, TimeSlot: &slot})
+	return nil
+}
+
+func (tx *recordingTx) UpdateTimeSlot(slot TimeSlot) error {
+	if err := tx.Store.UpdateTimeSlot(slot); err != nil {
+		return err
+	}
+	tx.record(StoredEvent{Type: StoredSlotMoved, EventID: slot.EventID, ID: slot.ID, TimeSlot: &slot})
+	return nil
+}
+
+func (tx *recordingTx) DeleteTimeSlot(id string) error {
+	slot, err := tx.Store.GetTimeSlot(id)
+	if err != nil {
+		return err
+	}
+	if err := tx.Store.DeleteTimeSlot(id); err != nil {
+		return err
+	}
+	tx.record(StoredEvent{Type: StoredSlotWithdrawn, EventID: slot.EventID, ID: id})
+	return nil
+}
+
+func (tx *recordingTx) CreateAvailability(avail UserAvailability) error {
+	if err := tx.Store.CreateAvailability(avail); err != nil {
+		return err
+	}
+	tx.record(StoredEvent{Type: StoredResponseRecorded, EventID: avail.EventID, ID: avail.ID, Availability: &avail})
+	return nil
+}
+
+func (tx *recordingTx) UpdateAvailability(avail UserAvailability) error {
+	if err := tx.Store.UpdateAvailability(avail); err != nil {
+		return err
+	}
+	tx.record(StoredEvent{Type: StoredResponseChanged, EventID: avail.EventID, ID: avail.ID, Availability: &avail})
+	return nil
+}
+
+func (tx *recordingTx) DeleteAvailability(id string) error {
+	withdrawn := StoredEvent{Type: StoredResponseWithdrawn, ID: id}
+	if tx.response != nil {
+		if avail, ok := tx.response(id); ok {
+			withdrawn.EventID, withdrawn.Availability = avail.EventID, &avail
+		}
+	}
+	if err := tx.Store.DeleteAvailability(id); err != nil {
+		return err
+	}
+	tx.record(withdrawn)
+	return nil
+}
+
+// Nested transactions join the enclosing one
+func (tx *recordingTx) WithTransaction(fn func(tx Store) error) error {
+	return fn(tx)
+}
+
+// getEventHistory lists an event's logged changes, or with ?at= returns
+// its state at that time
+func getEventHistory(c *gin.Context) {
+	if eventLog == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event history needs EVENT_LOG_FILE"})
+		return
+	}
+	eventID := c.Param("eventId")
+	history := eventLog.History(eventID)
+	if len(history) == 0 {
+		respondError(c, ErrEventNotFound)
+		return
+	}
+	if at := c.Query("at"); at != "" {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			respondError(c, invalid("at must be an RFC 3339 time"))
+			return
+		}
+		c.JSON(http.StatusOK, eventLog.SnapshotAt(eventID, t))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"events": history})
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openTestEventLog opens an event-sourced store on a fresh log in a
+// temporary directory, returning it with the log's path
+func openTestEventLog(t *testing.T) (*eventSourcedStore, string) {
+	t.Helper()
+	resetStorage(t)
+	path := filepath.Join(t.TempDir(), "events.log")
+	s, err := openEventSourcedStore(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s, path
+}
+
+// reopenEventLog empties memory and rebuilds it from the log alone
+func reopenEventLog(t *testing.T, s *eventSourcedStore, path string) *eventSourcedStore {
+	t.Helper()
+	require.NoError(t, s.Close())
+	resetStorage(t)
+	reopened, err := openEventSourcedStore(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { reopened.Close() })
+	return reopened
+}
+
+func storedTypes(history []StoredEvent) []StoredEventType {
+	var types []StoredEventType
+	for _, e := range history {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestEventSourcedStoreReplaysLog(t *testing.T) {
+	fake := useFakeClock(t, time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	s, path := openTestEventLog(t)
+	scheduler := newScheduler(s, fake, newEventBus())
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	start := fake.Now().Add(24 * time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	spare, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start.Add(2 * time.Hour), EndTime: start.Add(3 * time.Hour)})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "unavailable"})
+	require.NoError(t, err)
+	_, err = scheduler.UpdateAvailability(event.ID, "bob", slot.ID, UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+	require.NoError(t, scheduler.DeleteTimeSlot(spare.ID))
+	finalized, err := scheduler.FinalizeEvent(event.ID, FinalizeEventRequest{TimeSlotID: slot.ID})
+	require.NoError(t, err)
+	responses, err := s.ListAvailability(event.ID)
+	require.NoError(t, err)
+
+	s = reopenEventLog(t, s, path)
+	replayed, err := s.GetEvent(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, finalized, replayed)
+	slots, err := s.ListTimeSlots(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []TimeSlot{slot}, slots)
+	replayedResponses, err := s.ListAvailability(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, responses, replayedResponses)
+
+	assert.Equal(t, []StoredEventType{StoredEventCreated, StoredSlotProposed, StoredSlotProposed,
+		StoredResponseRecorded, StoredResponseChanged, StoredSlotWithdrawn, StoredEventFinalized},
+		storedTypes(s.History(event.ID)))
+
+	// Writes after a replay carry on the numbering
+	next := newScheduler(s, fake, newEventBus())
+	other, err := next.CreateEvent(CreateEventRequest{Title: "Retro", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(8), s.History(other.ID)[0].Seq)
+}
+
+func TestEventSourcedStoreLogsOnlyCommittedTransactions(t *testing.T) {
+	useFakeClock(t, time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	s, path := openTestEventLog(t)
+
+	err := s.WithTransaction(func(tx Store) error {
+		require.NoError(t, tx.CreateEvent(Event{ID: "e1", Title: "Sync", Status: "active"}))
+		return errors.New("boom")
+	})
+	assert.Error(t, err)
+	_, err = s.GetEvent("e1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Empty(t, s.History("e1"))
+
+	require.NoError(t, s.CreateEvent(Event{ID: "e2", Title: "Sync", Status: "active"}))
+	// A crash part-way through a write leaves a torn last line
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = file.WriteString(`[{"seq":2,"type":"EventCrea`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	s = reopenEventLog(t, s, path)
+	_, err = s.GetEvent("e2")
+	assert.NoError(t, err)
+	require.NoError(t, s.CreateEvent(Event{ID: "e3", Title: "Retro", Status: "active"}))
+	s = reopenEventLog(t, s, path)
+	_, err = s.GetEvent("e3")
+	assert.NoError(t, err, "the torn write was dropped, not appended to")
+}
+
+func TestEventSnapshotAt(t *testing.T) {
+	fake := useFakeClock(t, time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	s, _ := openTestEventLog(t)
+	scheduler := newScheduler(s, fake, newEventBus())
+
+	created := fake.Now()
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	start := created.Add(24 * time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	fake.Advance(time.Hour)
+	responded := fake.Now()
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+	fake.Advance(time.Hour)
+	_, err = scheduler.FinalizeEvent(event.ID, FinalizeEventRequest{TimeSlotID: slot.ID})
+	require.NoError(t, err)
+	fake.Advance(time.Hour)
+	require.NoError(t, scheduler.DeleteEvent(event.ID))
+
+	before := s.SnapshotAt(event.ID, created.Add(-time.Minute))
+	assert.Nil(t, before.Event)
+	assert.Empty(t, before.TimeSlots)
+
+	snapshot := s.SnapshotAt(event.ID, responded)
+	require.NotNil(t, snapshot.Event)
+	assert.Equal(t, "active", snapshot.Event.Status)
+	require.Len(t, snapshot.TimeSlots, 1)
+	assert.Equal(t, slot.ID, snapshot.TimeSlots[0].ID)
+	require.Len(t, snapshot.Availability, 1)
+	assert.Equal(t, "bob", snapshot.Availability[0].UserID)
+
+	finalized := s.SnapshotAt(event.ID, responded.Add(90*time.Minute))
+	assert.Equal(t, "finalized", finalized.Event.Status)
+	assert.Nil(t, s.SnapshotAt(event.ID, fake.Now()).Event, "deleted since")
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ScoreExplanation breaks a recommendation's score into the parts it is
+// made of. Score is BaseAvailability plus the weights and bonuses, less the
+// penalties. Parts the ranking doesn't weigh yet are reported as 0 so the
+// breakdown keeps the same shape as the scorer grows.
+type ScoreExplanation struct {
+	// BaseAvailability is the percentage of participants available,
+	// counting conditional and partial responses and declared free time,
+	// and leaving out those with a clashing meeting
+	BaseAvailability float64 `json:"baseAvailability"`
+	// IfNeededWeight is what "if needed" responses add. There is no such
+	// status yet, so it is always 0.
+	IfNeededWeight float64 `json:"ifNeededWeight"`
+	// WorkingHoursBonus is what falling within participants' working hours
+	// adds. Shift patterns count a participant as available instead, so
+	// it is always 0.
+	WorkingHoursBonus float64 `json:"workingHoursBonus"`
+	// RequiredAttendeePenalty is taken off for required attendees who
+	// can't come. Every invitee counts the same, so it is always 0.
+	RequiredAttendeePenalty float64 `json:"requiredAttendeePenalty"`
+	// ProtectedWindowPenalty is taken off slots inside one of the
+	// organization's protected windows
+	ProtectedWindowPenalty float64 `json:"protectedWindowPenalty"`
+	// Summary puts the breakdown in words for organizers and support
+	Summary string `json:"summary"`
+}
+
+// Total is the score the explanation adds up to
+func (e ScoreExplanation) Total() float64 {
+	return e.BaseAvailability + e.IfNeededWeight + e.WorkingHoursBonus - e.RequiredAttendeePenalty - e.ProtectedWindowPenalty
+}
+
+// explainScore breaks down a recommendation's score once its participants
+// are settled, given the protected windows the slot falls in
+func explainScore(rec Recommendation, protected []ProtectedWindow) ScoreExplanation {
+	explanation := ScoreExplanation{BaseAvailability: rec.AvailabilityPercentage}
+	total := len(rec.AvailableUsers) + len(rec.UnavailableUsers)
+	parts := []string{fmt.Sprintf("%d of %d participants available (%.0f%%)", len(rec.AvailableUsers), total, rec.AvailabilityPercentage)}
+	if len(rec.ConditionalUsers) > 0 {
+		parts = append(parts, fmt.Sprintf("%d of them conditionally", len(rec.ConditionalUsers)))
+	}
+	if len(protected) > 0 {
+		explanation.ProtectedWindowPenalty = protectedWindowPenalty
+		parts = append(parts, fmt.Sprintf("inside protected window %q (-%.0f)", protected[0].Name, protectedWindowPenalty))
+	}
+	explanation.Summary = strings.Join(parts, "; ")
+	return explanation
+}
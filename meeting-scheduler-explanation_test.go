@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecommendationsExplainTheirScore(t *testing.T) {
+	router := newTestRouter(t)
+	resetDirectory(t)
+	scheduler, _ := newTestScheduler(t)
+	organizations.Save(Organization{ID: "acme", ProtectedWindows: []ProtectedWindow{{Name: "Lunch", Start: "12:00", End: "13:00"}}})
+	users.Save(User{ID: "ada", OrgID: "acme", Role: RoleOrganizer})
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 60})
+	require.NoError(t, err)
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	lunch, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: day.Add(12 * time.Hour), EndTime: day.Add(13 * time.Hour)})
+	require.NoError(t, err)
+	afternoon, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: day.Add(14 * time.Hour), EndTime: day.Add(15 * time.Hour)})
+	require.NoError(t, err)
+	for _, userID := range []string{"bob", "cal"} {
+		_, err = scheduler.SubmitAvailability(event.ID, userID, UserAvailabilityRequest{TimeSlotID: lunch.ID, Status: "available"})
+		require.NoError(t, err)
+	}
+	_, err = scheduler.SubmitAvailability(event.ID, "cal", UserAvailabilityRequest{TimeSlotID: afternoon.ID, Status: "available"})
+	require.NoError(t, err)
+
+	recommendations, err := scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	require.Len(t, recommendations, 2)
+	assert.Equal(t, ScoreExplanation{
+		BaseAvailability:       100,
+		ProtectedWindowPenalty: protectedWindowPenalty,
+		Summary:                `2 of 2 participants available (100%); inside protected window "Lunch" (-25)`,
+	}, recommendations[0].Explanation)
+	assert.Equal(t, ScoreExplanation{BaseAvailability: 50, Summary: "1 of 2 participants available (50%)"}, recommendations[1].Explanation)
+	for _, rec := range recommendations {
+		assert.Equal(t, rec.Score, rec.Explanation.Total())
+	}
+
+	w := doJSON(router, http.MethodGet, "/api/v1/events/"+event.ID+"/recommendations", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Recommendations []map[string]interface{} `json:"recommendations"`
+	}
+	decodeJSON(t, w, &body)
+	require.Len(t, body.Recommendations, 2)
+	explanation := body.Recommendations[0]["explanation"].(map[string]interface{})
+	assert.Equal(t, 25.0, explanation["protectedWindowPenalty"])
+	assert.Equal(t, 0.0, explanation["requiredAttendeePenalty"])
+}
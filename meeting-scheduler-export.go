@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportFormatVersion is bumped whenever EventExport changes shape, so
+// imports can tell which documents they understand
+const exportFormatVersion = 1
+
+// EventExport is an event with everything hanging off it, in one
+// document for archiving or moving the event between environments
+type EventExport struct {
+	FormatVersion int                `json:"formatVersion"`
+	ExportedAt    time.Time          `json:"exportedAt"`
+	Event         Event              `json:"event"`
+	TimeSlots     []TimeSlot         `json:"timeSlots"`
+	Availability  []UserAvailability `json:"availability"`
+	Answers       []IntakeResponse   `json:"answers"`
+	Alerts        []QuorumAlert      `json:"alerts"`
+	Comments      []Comment          `json:"comments"`
+	// Audit is the event's recorded activity, oldest first
+	Audit []TimelineEntry `json:"audit"`
+}
+
+// ExportEvent gathers the event's graph. Slots are in start order and
+// responses by user, so exports of an unchanged event compare equal.
+func (s *Scheduler) ExportEvent(eventID string) (EventExport, error) {
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return EventExport{}, err
+	}
+	slots, err := s.store.ListTimeSlots(eventID)
+	if err != nil {
+		return EventExport{}, err
+	}
+	availabilityList, err := s.store.ListAvailability(eventID)
+	if err != nil {
+		return EventExport{}, err
+	}
+	sort.Slice(slots, func(i, j int) bool {
+		if !slots[i].StartTime.Equal(slots[j].StartTime) {
+			return slots[i].StartTime.Before(slots[j].StartTime)
+		}
+		return slots[i].ID < slots[j].ID
+	})
+	sort.Slice(availabilityList, func(i, j int) bool {
+		if availabilityList[i].UserID != availabilityList[j].UserID {
+			return availabilityList[i].UserID < availabilityList[j].UserID
+		}
+		return availabilityList[i].TimeSlotID < availabilityList[j].TimeSlotID
+	})
+
+	return EventExport{
+		FormatVersion: exportFormatVersion,
+		ExportedAt:    s.clock.Now(),
+		Event:         event,
+		TimeSlots:     append([]TimeSlot{}, slots...),
+		Availability:  append([]UserAvailability{}, availabilityList...),
+		Answers:       intakeAnswers.ForEvent(eventID),
+		Alerts:        quorumAlerts.ForEvent(eventID),
+		Comments:      comments.ForEvent(eventID),
+		Audit:         activity.ForEvent(eventID),
+	}, nil
+}
+
+func exportEvent(c *gin.Context) {
+	if format := c.DefaultQuery("format", "json"); format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported export format " + format})
+		return
+	}
+	export, err := contextScheduler(c).ExportEvent(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if !seesPersonalData(c, export.Event) {
+		export = export.redacted(orgRedactor(export.Event.OrgID))
+	}
+	c.Header("Content-Disposition", `attachment; filename="event-`+export.Event.ID+`.json"`)
+	stream := newJSONStream(c, http.StatusOK)
+	stream.Field("formatVersion", export.FormatVersion)
+	stream.Field("exportedAt", export.ExportedAt)
+	stream.Field("event", export.Event)
+	stream.ArrayField("timeSlots", len(export.TimeSlots), func(i int) interface{} { return export.TimeSlots[i] })
+	stream.ArrayField("availability", len(export.Availability), func(i int) interface{} { return export.Availability[i] })
+	stream.Field("answers", export.Answers)
+	stream.Field("alerts", export.Alerts)
+	stream.Field("comments", export.Comments)
+	stream.Field("audit", export.Audit)
+	stream.Close()
+}
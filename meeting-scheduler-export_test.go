@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportEventIncludesChildGraph(t *testing.T) {
+	scheduler, fake := newTestScheduler(t)
+	previousActivity, previousComments := activity, comments
+	activity, comments = newActivityRegistry(), newCommentRegistry()
+	t.Cleanup(func() { activity, comments = previousActivity, previousComments })
+	scheduler.bus.SubscribeAll(recordActivity)
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	start := fake.Now().Add(24 * time.Hour)
+	late, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start.Add(2 * time.Hour), EndTime: start.Add(3 * time.Hour)})
+	require.NoError(t, err)
+	early, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	for _, user := range []string{"cy", "bob"} {
+		_, err = scheduler.SubmitAvailability(event.ID, user, UserAvailabilityRequest{TimeSlotID: early.ID, Status: "available"})
+		require.NoError(t, err)
+	}
+	comments.Add(Comment{ID: "c1", EventID: event.ID, AuthorID: "bob", Body: "Works for me", CreatedAt: fake.Now()})
+
+	export, err := scheduler.ExportEvent(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, exportFormatVersion, export.FormatVersion)
+	assert.Equal(t, event.ID, export.Event.ID)
+	require.Len(t, export.TimeSlots, 2)
+	assert.Equal(t, []string{early.ID, late.ID}, []string{export.TimeSlots[0].ID, export.TimeSlots[1].ID})
+	require.Len(t, export.Availability, 2)
+	assert.Equal(t, "bob", export.Availability[0].UserID)
+	assert.Len(t, export.Comments, 1)
+	assert.Len(t, export.Audit, 5, "creation, two slots and two responses")
+	assert.Empty(t, export.Alerts)
+
+	_, err = scheduler.ExportEvent("missing")
+	assert.ErrorIs(t, err, ErrEventNotFound)
+}
+
+func TestExportEventRejectsUnknownFormats(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	ada := User{ID: "ada", Role: RoleOrganizer}
+	users.Save(ada)
+	event, err := currentScheduler().CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	token, err := issueSessionToken(ada)
+	require.NoError(t, err)
+
+	get := func(path string) int {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, get("/api/v1/events/"+event.ID+"/export"))
+	assert.Equal(t, http.StatusBadRequest, get("/api/v1/events/"+event.ID+"/export?format=xml"))
+}
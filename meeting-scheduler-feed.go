@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxFeedEntries bounds each user's feed; the oldest entries drop off
+const maxFeedEntries = 200
+
+// Notification is an entry in a user's in-app notification feed
+type Notification struct {
+	ID        string          `json:"id"`
+	UserID    string          `json:"userId"`
+	EventID   string          `json:"eventId"`
+	Type      DomainEventType `json:"type"`
+	Subject   string          `json:"subject"`
+	Body      string          `json:"body"`
+	CreatedAt time.Time       `json:"createdAt"`
+	ReadAt    *time.Time      `json:"readAt,omitempty"`
+}
+
+// NotificationFeed is a page of a user's feed with their unread count
+type NotificationFeed struct {
+	Notifications []Notification `json:"notifications"`
+	Unread        int            `json:"unread"`
+}
+
+// feedRegistry is the in-memory notification feed store, newest first
+// per user
+type feedRegistry struct {
+	mu    sync.RWMutex
+	feeds map[string][]Notification
+}
+
+func newFeedRegistry() *feedRegistry {
+	return &feedRegistry{feeds: make(map[string][]Notification)}
+}
+
+func (r *feedRegistry) Add(n Notification) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	feed := append([]Notification{n}, r.feeds[n.UserID]...)
+	if len(feed) > maxFeedEntries {
+		feed = feed[:maxFeedEntries]
+	}
+	r.feeds[n.UserID] = feed
+}
+
+// ForUser returns the user's feed, newest first, optionally unread only
+func (r *feedRegistry) ForUser(userID string, unreadOnly bool) NotificationFeed {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	feed := NotificationFeed{Notifications: []Notification{}}
+	for _, n := range r.feeds[userID] {
+		if n.ReadAt == nil {
+			feed.Unread++
+		} else if unreadOnly {
+			continue
+		}
+		feed.Notifications = append(feed.Notifications, n)
+	}
+	return feed
+}
+
+// MarkRead marks one notification read, reporting whether the user has it
+func (r *feedRegistry) MarkRead(userID, id string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, n := range r.feeds[userID] {
+		if n.ID == id {
+			if n.ReadAt == nil {
+				r.feeds[userID][i].ReadAt = &now
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// MarkAllRead marks every unread notification of the user read
+func (r *feedRegistry) MarkAllRead(userID string, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, n := range r.feeds[userID] {
+		if n.ReadAt == nil {
+			r.feeds[userID][i].ReadAt = &now
+		}
+	}
+}
+
+var notificationFeed = newFeedRegistry()
+
+// recordFeed turns domain events into feed entries for the users they
+// concern: invitees hear about new polls, organizers about responses, and
+// every participant about confirmations and cancellations
+func recordFeed(e DomainEvent) {
+	var recipients []string
+	var event Event
+	var subject, body string
+
+	switch e.Type {
+	case EventCreated, EventFinalized, EventCancelled:
+		var ok bool
+		if event, ok = e.Payload.(Event); !ok {
+			return
+		}
+	case AvailabilitySubmitted:
+		avail, ok := e.Payload.(UserAvailability)
+		if !ok {
+			return
+		}
+		var err error
+		if event, err = store.GetEvent(avail.EventID); err != nil || avail.UserID == event.OrganizerID {
+			return
+		}
+		recipients = []string{event.OrganizerID}
+		subject = "New response: " + event.Title
+		body = fmt.Sprintf("%s responded to %s.", displayName(avail.UserID), event.Title)
+	default:
+		return
+	}
+
+	switch e.Type {
+	case EventCreated:
+		for _, id := range event.Invitees {
+			if id != event.OrganizerID {
+				recipients = append(recipients, id)
+			}
+		}
+		subject = "Invitation: " + event.Title
+		body = fmt.Sprintf("%s would like your availability for %s.", displayName(event.OrganizerID), event.Title)
+	case EventFinalized, EventCancelled:
+		participants, err := eventParticipants(event)
+		if err != nil {
+			log.Printf("Failed to load participants for %s: %v", event.ID, err)
+			return
+		}
+		for _, user := range participants {
+			recipients = append(recipients, user.ID)
+		}
+		subject, body = "Cancelled: "+event.Title, event.Title+" has been cancelled."
+		if e.Type == EventFinalized {
+			subject, body = "Confirmed: "+event.Title, event.Title+" is confirmed."
+			if slot, err := store.GetTimeSlot(event.FinalTimeSlotID); err == nil {
+				body = fmt.Sprintf("%s is confirmed for %s.", event.Title, slot.StartTime.UTC().Format("Mon Jan 2 2006, 15:04 MST"))
+			}
+		}
+	}
+
+	for _, id := range recipients {
+		notificationFeed.Add(Notification{
+			ID:        uuid.New().String(),
+			UserID:    id,
+			EventID:   event.ID,
+			Type:      e.Type,
+			Subject:   subject,
+			Body:      body,
+			CreatedAt: e.OccurredAt,
+		})
+	}
+}
+
+// displayName is the user's name where known, else their ID
+func displayName(userID string) string {
+	if user, ok := users.Get(userID); ok && user.Name != "" {
+		return user.Name
+	}
+	return userID
+}
+
+// Feed handlers only serve the signed-in user's own feed
+func ownFeed(c *gin.Context) (string, bool) {
+	user, _ := currentUser(c)
+	if user.ID != c.Param("userId") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Can only access your own notifications"})
+		return "", false
+	}
+	return user.ID, true
+}
+
+func listNotifications(c *gin.Context) {
+	userID, ok := ownFeed(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, notificationFeed.ForUser(userID, c.Query("unread") == "true"))
+}
+
+func markNotificationRead(c *gin.Context) {
+	userID, ok := ownFeed(c)
+	if !ok {
+		return
+	}
+	if !notificationFeed.MarkRead(userID, c.Param("notificationId"), clock.Now()) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func markAllNotificationsRead(c *gin.Context) {
+	userID, ok := ownFeed(c)
+	if !ok {
+		return
+	}
+	notificationFeed.MarkAllRead(userID, clock.Now())
+	c.JSON(http.StatusNoContent, nil)
+}
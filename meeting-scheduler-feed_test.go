@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetFeed(t *testing.T) {
+	previous := notificationFeed
+	notificationFeed = newFeedRegistry()
+	t.Cleanup(func() { notificationFeed = previous })
+}
+
+func TestNotificationFeedFollowsDomainEvents(t *testing.T) {
+	resetDirectory(t)
+	resetFeed(t)
+	router := newTestRouter(t)
+	fake := newFakeClock(time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	eventBus := newEventBus()
+	registerSubscribers(eventBus)
+	scheduler := newScheduler(store, fake, eventBus)
+	previous := notifier
+	notifier = &recordingNotifier{}
+	t.Cleanup(func() { notifier = previous })
+
+	users.Save(User{ID: "ada", Name: "Ada", Role: RoleOrganizer})
+	bob := User{ID: "bob", Name: "Bob", Role: RoleMember}
+	users.Save(bob)
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30, Invitees: []string{"bob"}})
+	require.NoError(t, err)
+	start := fake.Now().Add(24 * time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+	fake.Advance(time.Minute)
+	_, err = scheduler.FinalizeEvent(event.ID, FinalizeEventRequest{TimeSlotID: slot.ID})
+	require.NoError(t, err)
+
+	organizerFeed := notificationFeed.ForUser("ada", false)
+	require.Len(t, organizerFeed.Notifications, 2)
+	assert.Equal(t, "Confirmed: Kickoff", organizerFeed.Notifications[0].Subject)
+	assert.Equal(t, "Bob responded to Kickoff.", organizerFeed.Notifications[1].Body)
+
+	token, err := issueSessionToken(bob)
+	require.NoError(t, err)
+	send := func(method, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	var feed NotificationFeed
+	decodeJSON(t, send("GET", "/api/v1/users/bob/notifications"), &feed)
+	require.Len(t, feed.Notifications, 2)
+	assert.Equal(t, 2, feed.Unread)
+	assert.Equal(t, EventFinalized, feed.Notifications[0].Type)
+	assert.Equal(t, "Invitation: Kickoff", feed.Notifications[1].Subject)
+
+	assert.Equal(t, http.StatusForbidden, send("GET", "/api/v1/users/ada/notifications").Code)
+	assert.Equal(t, http.StatusNotFound, send("POST", "/api/v1/users/bob/notifications/missing/read").Code)
+	assert.Equal(t, http.StatusNoContent, send("POST", "/api/v1/users/bob/notifications/"+feed.Notifications[1].ID+"/read").Code)
+	decodeJSON(t, send("GET", "/api/v1/users/bob/notifications?unread=true"), &feed)
+	require.Len(t, feed.Notifications, 1)
+	assert.Equal(t, 1, feed.Unread)
+
+	assert.Equal(t, http.StatusNoContent, send("POST", "/api/v1/users/bob/notifications/read").Code)
+	decodeJSON(t, send("GET", "/api/v1/users/bob/notifications"), &feed)
+	assert.Len(t, feed.Notifications, 2)
+	assert.Zero(t, feed.Unread)
+}
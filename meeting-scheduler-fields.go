@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fieldSelection is a parsed ?fields= parameter: each selected key maps to
+// the keys selected within it, or to nil to keep its whole value
+type fieldSelection map[string]fieldSelection
+
+// parseFields reads a comma-separated list of dotted paths, such as
+// "recommendations.timeSlotId,recommendations.score,analysis". Selecting a
+// field in full overrides selecting parts of it.
+func parseFields(param string) fieldSelection {
+	selection := fieldSelection{}
+	for _, path := range strings.Split(param, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		current := selection
+		keys := strings.Split(path, ".")
+		for i, key := range keys {
+			sub, seen := current[key]
+			if i == len(keys)-1 {
+				current[key] = nil
+				break
+			}
+			if seen && sub == nil {
+				break
+			}
+			if sub == nil {
+				sub = fieldSelection{}
+				current[key] = sub
+			}
+			current = sub
+		}
+	}
+	return selection
+}
+
+// apply keeps the selected keys of an object. Arrays have the selection
+// applied to each of their items; other values are kept as they are.
+func (sel fieldSelection) apply(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		selected := make(map[string]interface{}, len(sel))
+		for key, sub := range sel {
+			field, ok := v[key]
+			if !ok {
+				continue
+			}
+			if sub == nil {
+				selected[key] = field
+			} else {
+				selected[key] = sub.apply(field)
+			}
+		}
+		return selected
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = sel.apply(item)
+		}
+		return items
+	}
+	return value
+}
+
+// jsonHoldWriter holds back a JSON response so it can be rewritten once
+// complete. Other responses pass straight through.
+type jsonHoldWriter struct {
+	gin.ResponseWriter
+	buf         bytes.Buffer
+	buffering   bool
+	passthrough bool
+}
+
+func (w *jsonHoldWriter) Write(p []byte) (int, error) {
+	if !w.buffering && !w.passthrough {
+		if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			w.buffering = true
+		} else {
+			w.passthrough = true
+		}
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(p)
+	}
+	return w.buf.Write(p)
+}
+
+func (w *jsonHoldWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush is put off until the whole document is in
+func (w *jsonHoldWriter) Flush() {
+	if w.passthrough {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// finish writes out the held-back response as rewritten by rewrite
+func (w *jsonHoldWriter) finish(rewrite func(body []byte) []byte) {
+	if w.buffering {
+		w.ResponseWriter.Write(rewrite(w.buf.Bytes()))
+	}
+}
+
+// decodeDocument decodes a held-back response, keeping numbers exactly as
+// they were written
+func decodeDocument(body []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var document interface{}
+	err := decoder.Decode(&document)
+	return document, err
+}
+
+// selectFields trims successful GET responses to the attributes named in
+// ?fields=
+func selectFields(c *gin.Context) {
+	selection := parseFields(c.Query("fields"))
+	if c.Request.Method != http.MethodGet || len(selection) == 0 {
+		c.Next()
+		return
+	}
+	w := &jsonHoldWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+	c.Next()
+	w.finish(func(body []byte) []byte {
+		if w.Status() != http.StatusOK {
+			return body
+		}
+		document, err := decodeDocument(body)
+		if err != nil {
+			return body
+		}
+		if trimmed, err := json.Marshal(selection.apply(document)); err == nil {
+			return trimmed
+		}
+		return body
+	})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFields(t *testing.T) {
+	assert.Equal(t, fieldSelection{"a": nil, "b": {"c": nil, "d": nil}},
+		parseFields("a, b.c,b.d,"))
+	assert.Equal(t, fieldSelection{"b": nil}, parseFields("b.c,b"), "a whole field wins")
+	assert.Equal(t, fieldSelection{"b": nil}, parseFields("b,b.c"))
+	assert.Empty(t, parseFields(""))
+}
+
+func TestFieldsTrimsGetResponses(t *testing.T) {
+	router := newTestRouter(t)
+	scheduler := currentScheduler()
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	start := time.Date(2025, 2, 3, 9, 0, 0, 0, time.UTC)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+
+	w := doJSON(router, "GET", "/api/v1/events/"+event.ID+"/recommendations?fields=recommendations.timeslot.id,recommendations.score", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"recommendations":[{"timeslot":{"id":"`+slot.ID+`"},"score":100}]}`, w.Body.String())
+
+	w = doJSON(router, "GET", "/api/v1/events?fields=id,title", nil)
+	assert.JSONEq(t, `[{"id":"`+event.ID+`","title":"Kickoff"}]`, w.Body.String())
+
+	w = doJSON(router, "GET", "/api/v1/events/missing?fields=id", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":"Event not found"}`, w.Body.String(), "errors are left whole")
+}
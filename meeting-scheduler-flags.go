@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Feature flags gating capabilities that are still rolling out
+const (
+	FlagBookingPages = "booking-pages"
+)
+
+// builtinFlags are every flag the code checks, with their defaults.
+// FEATURE_FLAGS_FILE can change any of them.
+var builtinFlags = []FeatureFlag{
+	{Name: FlagBookingPages, Enabled: true},
+}
+
+// FeatureFlag decides who gets a capability. Overrides for a user win
+// over those for their organization, which win over the rollout
+// percentage and then the default.
+type FeatureFlag struct {
+	Name string `json:"name"`
+	// Enabled is the flag's value for everyone no rule below matches
+	Enabled bool `json:"enabled"`
+	// Percentage of users, picked stably by user ID, who get the flag on
+	Percentage int             `json:"percentage,omitempty"`
+	Orgs       map[string]bool `json:"orgs,omitempty"`
+	Users      map[string]bool `json:"users,omitempty"`
+}
+
+// EnabledFor evaluates the flag for a user; the zero User stands for
+// anonymous callers
+func (f FeatureFlag) EnabledFor(user User) bool {
+	if enabled, ok := f.Users[user.ID]; ok && user.ID != "" {
+		return enabled
+	}
+	if enabled, ok := f.Orgs[user.OrgID]; ok && user.OrgID != "" {
+		return enabled
+	}
+	if f.Percentage > 0 && user.ID != "" && rolloutBucket(f.Name, user.ID) < f.Percentage {
+		return true
+	}
+	return f.Enabled
+}
+
+// rolloutBucket places a user in 0-99 for a flag, so raising a flag's
+// percentage only ever adds users
+func rolloutBucket(flag, userID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(flag + ":" + userID))
+	return int(h.Sum32() % 100)
+}
+
+// flagRegistry holds the flags in effect by name
+type flagRegistry struct {
+	mu    sync.RWMutex
+	flags map[string]FeatureFlag
+}
+
+func newFlagRegistry() *flagRegistry {
+	r := &flagRegistry{flags: make(map[string]FeatureFlag)}
+	for _, flag := range builtinFlags {
+		r.flags[flag.Name] = flag
+	}
+	return r
+}
+
+func (r *flagRegistry) Get(name string) (FeatureFlag, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	flag, ok := r.flags[name]
+	return flag, ok
+}
+
+func (r *flagRegistry) Save(flag FeatureFlag) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.flags[flag.Name] = flag
+}
+
+// All returns every flag, ordered by name
+func (r *flagRegistry) All() []FeatureFlag {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	flags := make([]FeatureFlag, 0, len(r.flags))
+	for _, flag := range r.flags {
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags
+}
+
+// SetOrg overrides the flag for one organization; a nil value clears the
+// override
+func (r *flagRegistry) SetOrg(name, orgID string, enabled *bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	flag, ok := r.flags[name]
+	if !ok {
+		return false
+	}
+	orgs := make(map[string]bool, len(flag.Orgs)+1)
+	for id, value := range flag.Orgs {
+		orgs[id] = value
+	}
+	if enabled == nil {
+		delete(orgs, orgID)
+	} else {
+		orgs[orgID] = *enabled
+	}
+	flag.Orgs = orgs
+	r.flags[name] = flag
+	return true
+}
+
+var featureFlags = newFlagRegistry()
+
+// loadFeatureFlags reads flag definitions from a JSON file, replacing the
+// built-in ones of the same name
+func loadFeatureFlags(r *flagRegistry, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var flagList []FeatureFlag
+	if err := json.Unmarshal(data, &flagList); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for _, flag := range flagList {
+		if flag.Name == "" {
+			return fmt.Errorf("parsing %s: flag without name", path)
+		}
+		if flag.Percentage < 0 || flag.Percentage > 100 {
+			return fmt.Errorf("parsing %s: flag %s percentage must be between 0 and 100", path, flag.Name)
+		}
+		r.Save(flag)
+	}
+	return nil
+}
+
+// flagEnabled reports whether the user gets the named flag. Unknown flags
+// are off.
+func flagEnabled(name string, user User) bool {
+	flag, ok := featureFlags.Get(name)
+	return ok && flag.EnabledFor(user)
+}
+
+// requireFeature hides routes from callers who don't have the flag, as if
+// they didn't exist
+func requireFeature(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, _ := currentUser(c)
+		if !flagEnabled(name, user) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Feature flag handlers
+func listMyFlags(c *gin.Context) {
+	user, _ := currentUser(c)
+	flags := map[string]bool{}
+	for _, flag := range featureFlags.All() {
+		flags[flag.Name] = flag.EnabledFor(user)
+	}
+	c.JSON(http.StatusOK, flags)
+}
+
+type OrgFlagRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+func setOrgFlag(c *gin.Context) {
+	var req OrgFlagRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !featureFlags.SetOrg(c.Param("flag"), c.Param("orgId"), req.Enabled) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Feature flag not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"name": c.Param("flag"), "enabled": *req.Enabled})
+}
+
+func clearOrgFlag(c *gin.Context) {
+	if !featureFlags.SetOrg(c.Param("flag"), c.Param("orgId"), nil) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Feature flag not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetFeatureFlags(t *testing.T) {
+	previous := featureFlags
+	featureFlags = newFlagRegistry()
+	t.Cleanup(func() { featureFlags = previous })
+}
+
+func TestFeatureFlagEvaluation(t *testing.T) {
+	flag := FeatureFlag{
+		Name:  "beta",
+		Orgs:  map[string]bool{"acme": true, "initech": false},
+		Users: map[string]bool{"ada": false},
+	}
+	assert.True(t, flag.EnabledFor(User{ID: "bob", OrgID: "acme"}))
+	assert.False(t, flag.EnabledFor(User{ID: "ada", OrgID: "acme"}), "users override their organization")
+	assert.False(t, flag.EnabledFor(User{ID: "cy", OrgID: "globex"}))
+	assert.False(t, flag.EnabledFor(User{}))
+
+	flag.Percentage = 30
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		user := User{ID: fmt.Sprintf("user-%d", i), OrgID: "globex"}
+		if flag.EnabledFor(user) {
+			enabled++
+			flag.Percentage = 60
+			assert.True(t, flag.EnabledFor(user), "raising the percentage keeps users on")
+			flag.Percentage = 30
+		}
+	}
+	assert.InDelta(t, 300, enabled, 60)
+	assert.False(t, flag.EnabledFor(User{ID: "zed", OrgID: "initech"}), "organization overrides beat the rollout")
+}
+
+func TestLoadFeatureFlags(t *testing.T) {
+	resetFeatureFlags(t)
+	path := filepath.Join(t.TempDir(), "flags.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"name": "booking-pages", "enabled": false, "orgs": {"acme": true}},
+		{"name": "beta", "percentage": 10}
+	]`), 0o600))
+	require.NoError(t, loadFeatureFlags(featureFlags, path))
+	assert.True(t, flagEnabled(FlagBookingPages, User{ID: "ada", OrgID: "acme"}))
+	assert.False(t, flagEnabled(FlagBookingPages, User{ID: "cy", OrgID: "globex"}))
+	assert.False(t, flagEnabled("unknown", User{ID: "ada", OrgID: "acme"}))
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"name": "beta", "percentage": 150}]`), 0o600))
+	assert.Error(t, loadFeatureFlags(featureFlags, path))
+}
+
+func TestOrgFlagOverrideGatesBookingPages(t *testing.T) {
+	resetDirectory(t)
+	resetBookingPages(t)
+	resetFeatureFlags(t)
+	router := newTestRouter(t)
+	admin := User{ID: "root", OrgID: "acme", Role: RoleAdmin}
+	host := User{ID: "host", OrgID: "acme", Role: RoleMember}
+	users.Save(admin)
+	users.Save(host)
+	bookingPages.Save(BookingPage{ID: "page", UserID: "host", OrgID: "acme", Token: "tok", Title: "Intro call"})
+	adminToken, err := issueSessionToken(admin)
+	require.NoError(t, err)
+	hostToken, err := issueSessionToken(host)
+	require.NoError(t, err)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/users/me/booking-page", hostToken, "").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/booking/tok", "", "").Code)
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/api/v1/organizations/acme/flags/booking-pages", hostToken, `{"enabled": false}`).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPut, "/api/v1/organizations/acme/flags/nope", adminToken, `{"enabled": false}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPut, "/api/v1/organizations/acme/flags/booking-pages", adminToken, `{"enabled": false}`).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/users/me/booking-page", hostToken, "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/booking/tok", "", "").Code)
+
+	w := do(http.MethodGet, "/api/v1/users/me/flags", hostToken, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var flags map[string]bool
+	decodeJSON(t, w, &flags)
+	assert.Equal(t, map[string]bool{FlagBookingPages: false}, flags)
+
+	require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/v1/organizations/acme/flags/booking-pages", adminToken, "").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/booking/tok", "", "").Code)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxGeneratedSlots bounds how many slots one generate call may create
+const maxGeneratedSlots = 100
+
+// GenerateTimeSlotsRequest asks for candidate slots of the event's duration
+// starting every StepMinutes, or every Step (an ISO-8601 duration), between
+// From and To. Without either, the organization's slot granularity is
+// used, falling back to the event's duration.
+type GenerateTimeSlotsRequest struct {
+	From        time.Time `json:"from" binding:"required"`
+	To          time.Time `json:"to" binding:"required"`
+	StepMinutes int       `json:"stepMinutes"`
+	Step        string    `json:"step"`
+}
+
+// GenerateTimeSlots proposes evenly spaced candidate slots in a window,
+// skipping any that break a scheduling rule
+func (s *Scheduler) GenerateTimeSlots(eventID string, req GenerateTimeSlotsRequest) ([]TimeSlot, error) {
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return nil, err
+	}
+	if !req.To.After(req.From) {
+		return nil, invalid("To must be after from")
+	}
+	step := time.Duration(req.StepMinutes) * time.Minute
+	switch {
+	case req.Step != "":
+		if req.StepMinutes != 0 {
+			return nil, invalid("Set step or stepMinutes, not both")
+		}
+		if step, err = parseISODuration(req.Step); err != nil {
+			return nil, invalid(err.Error())
+		}
+	case req.StepMinutes == 0:
+		var ok bool
+		if step, ok = orgGranularity(event.OrgID); !ok {
+			step = event.duration()
+		}
+	}
+	if step <= 0 {
+		return nil, invalid("Step must be positive")
+	}
+
+	rules, err := s.newSlotRules(event)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := event.duration()
+	generated := []TimeSlot{}
+	for start := req.From; !start.Add(duration).After(req.To); start = start.Add(step) {
+		end := start.Add(duration)
+		if len(rules.violations(start, end)) > 0 {
+			continue
+		}
+		if len(generated) == maxGeneratedSlots {
+			return nil, invalid("Window would generate too many slots; narrow it or increase the step")
+		}
+		generated = append(generated, TimeSlot{StartTime: start, EndTime: end})
+	}
+
+	created := make([]TimeSlot, 0, len(generated))
+	for _, candidate := range generated {
+		slot, err := s.CreateTimeSlot(eventID, CreateTimeSlotRequest{StartTime: candidate.StartTime, EndTime: candidate.EndTime})
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, slot)
+	}
+	return created, nil
+}
+
+func generateTimeSlots(c *gin.Context) {
+	scheduler := contextScheduler(c)
+	eventID := c.Param("eventId")
+	if _, err := scheduler.GetEvent(eventID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	var req GenerateTimeSlotsRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	slots, err := scheduler.GenerateTimeSlots(eventID, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, slots)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// updateGolden rewrites the golden files from the current responses:
+// go test -run TestAPIContract -update
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata/golden")
+
+var uuidPattern = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// contractCase is one request whose response is pinned by
+// testdata/golden/<name>.json. {{id:N}} in the path or body is the Nth ID
+// seen in an earlier response.
+type contractCase struct {
+	name   string
+	method string
+	path   string
+	body   string
+	accept string
+}
+
+// goldenIDs numbers IDs in order of first appearance, so golden files
+// stay stable while still showing which records refer to which
+type goldenIDs struct {
+	ids []string
+}
+
+func (g *goldenIDs) normalize(body []byte) []byte {
+	return uuidPattern.ReplaceAllFunc(body, func(id []byte) []byte {
+		for i, seen := range g.ids {
+			if seen == string(id) {
+				return []byte("{{id:" + strconv.Itoa(i+1) + "}}")
+			}
+		}
+		g.ids = append(g.ids, string(id))
+		return []byte("{{id:" + strconv.Itoa(len(g.ids)) + "}}")
+	})
+}
+
+func (g *goldenIDs) expand(s string) string {
+	for i := len(g.ids); i >= 1; i-- {
+		s = strings.ReplaceAll(s, "{{id:"+strconv.Itoa(i)+"}}", g.ids[i-1])
+	}
+	return s
+}
+
+// goldenResponse renders a response as indented JSON with sorted keys.
+// Empty bodies render as null.
+func goldenResponse(t *testing.T, status int, body []byte) []byte {
+	t.Helper()
+	var document interface{}
+	if len(bytes.TrimSpace(body)) > 0 {
+		require.NoError(t, json.Unmarshal(body, &document), "response %q", body)
+	}
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	require.NoError(t, encoder.Encode(map[string]interface{}{"status": status, "body": document}))
+	return out.Bytes()
+}
+
+// TestAPIContract pins the wire format of the core endpoints, error
+// shapes included, so service changes can't alter it unnoticed. Cases run
+// in order against one store and a fixed clock.
+func TestAPIContract(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	previousClock := clock
+	clock = newFakeClock(time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	t.Cleanup(func() { clock = previousClock })
+
+	cases := []contractCase{
+		{name: "create_event", method: "POST", path: "/api/v1/events",
+			body: `{"title": "Kickoff", "description": "Plan the quarter", "organizerId": "ada", "requiredDuration": 30}`},
+		{name: "create_event_missing_title", method: "POST", path: "/api/v1/events",
+			body: `{"organizerId": "ada", "requiredDuration": 30}`},
+		{name: "create_event_invalid_duration", method: "POST", path: "/api/v1/events",
+			body: `{"title": "Kickoff", "organizerId": "ada", "requiredDuration": -5}`},
+		{name: "get_event", method: "GET", path: "/api/v1/events/{{id:1}}"},
+		{name: "get_event_not_found", method: "GET", path: "/api/v1/events/missing"},
+		{name: "create_timeslot", method: "POST", path: "/api/v1/events/{{id:1}}/timeslots",
+			body: `{"startTime": "2025-01-13T10:00:00Z", "endTime": "2025-01-13T11:00:00Z"}`},
+		{name: "create_timeslot_invalid_time", method: "POST", path: "/api/v1/events/{{id:1}}/timeslots",
+			body: `{"startTime": "tomorrow", "endTime": "2025-01-13T11:00:00Z"}`},
+		{name: "create_timeslot_end_before_start", method: "POST", path: "/api/v1/events/{{id:1}}/timeslots",
+			body: `{"startTime": "2025-01-13T11:00:00Z", "endTime": "2025-01-13T10:00:00Z"}`},
+		{name: "list_timeslots", method: "GET", path: "/api/v1/events/{{id:1}}/timeslots"},
+		{name: "create_availability", method: "POST", path: "/api/v1/events/{{id:1}}/users/bob/availability",
+			body: `{"timeslotId": "{{id:2}}", "status": "available"}`},
+		{name: "create_availability_invalid_status", method: "POST", path: "/api/v1/events/{{id:1}}/users/bob/availability",
+			body: `{"timeslotId": "{{id:2}}", "status": "maybe"}`},
+		{name: "get_user_availability", method: "GET", path: "/api/v1/events/{{id:1}}/users/bob/availability"},
+		{name: "get_event_enveloped", method: "GET", path: "/api/v1/events/{{id:1}}", accept: envelopeMediaType},
+		{name: "get_event_not_found_enveloped", method: "GET", path: "/api/v1/events/missing", accept: envelopeMediaType},
+		{name: "delete_event", method: "DELETE", path: "/api/v1/events/{{id:1}}"},
+	}
+
+	ids := &goldenIDs{}
+	for _, tc := range cases {
+		req, _ := http.NewRequest(tc.method, ids.expand(tc.path), strings.NewReader(ids.expand(tc.body)))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		got := goldenResponse(t, w.Code, ids.normalize(w.Body.Bytes()))
+
+		path := filepath.Join("testdata", "golden", tc.name+".json")
+		if *updateGolden {
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+			require.NoError(t, os.WriteFile(path, got, 0o644))
+			continue
+		}
+		want, err := os.ReadFile(path)
+		require.NoError(t, err, "missing golden file; run go test -run TestAPIContract -update")
+		assert.Equal(t, string(want), string(got), tc.name)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// What happens to a purged guest's responses
+const (
+	GuestResponsesAnonymize = "anonymize"
+	GuestResponsesRetain    = "retain"
+)
+
+// GuestRetentionPolicy controls how long guests are kept once they have
+// nothing left to respond to. Anonymized responses still count towards
+// past events but no longer name the guest; retained ones keep the guest's
+// ID after their account is gone.
+type GuestRetentionPolicy struct {
+	// Days after a guest was last active before they are purged; 0 uses
+	// GUEST_RETENTION_DAYS
+	Days int `json:"days,omitempty"`
+	// Responses is anonymize (the default) or retain
+	Responses string `json:"responses,omitempty"`
+}
+
+func (p GuestRetentionPolicy) retention() time.Duration {
+	days := p.Days
+	if days <= 0 {
+		days = getenvInt("GUEST_RETENTION_DAYS", 90)
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Guests returns every guest account, in every organization
+func (d *userDirectory) Guests() []User {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var guests []User
+	for _, user := range d.users {
+		if user.Role == RoleGuest {
+			guests = append(guests, d.reveal(user))
+		}
+	}
+	return guests
+}
+
+// purgeStaleGuests drops expired guest links and purges guests who have
+// been inactive past their organization's retention
+func purgeStaleGuests(now time.Time) {
+	guestInvites.purgeExpired(now)
+	if err := purgeGuests(currentScheduler(), now); err != nil {
+		log.Printf("Guest cleanup failed, will retry: %v", err)
+	}
+}
+
+// purgeGuests purges each guest who isn't invited to or responding on an
+// active event or one under legal hold, and was last invited, updated or responded longer ago than
+// the retention allows
+func purgeGuests(s *Scheduler, now time.Time) error {
+	guests := users.Guests()
+	if len(guests) == 0 {
+		return nil
+	}
+	lastActive := map[string]time.Time{}
+	for _, guest := range guests {
+		lastActive[guest.ID] = maxTime(guest.UpdatedAt, guestInvites.LastIssued(guest.ID))
+	}
+
+	eventList, err := s.store.ListEvents()
+	if err != nil {
+		return err
+	}
+	busy := map[string]bool{}
+	for _, event := range eventList {
+		availabilityList, err := s.store.ListAvailability(event.ID)
+		if err != nil {
+			return err
+		}
+		for _, avail := range availabilityList {
+			if last, ok := lastActive[avail.UserID]; ok {
+				lastActive[avail.UserID] = maxTime(last, avail.UpdatedAt)
+				busy[avail.UserID] = busy[avail.UserID] || event.Status == "active" || event.LegalHold != nil
+			}
+		}
+		for _, id := range event.Invitees {
+			busy[id] = busy[id] || event.Status == "active" || event.LegalHold != nil
+		}
+	}
+
+	for _, guest := range guests {
+		org, _ := organizations.Get(guest.OrgID)
+		if busy[guest.ID] || now.Sub(lastActive[guest.ID]) < org.GuestRetention.retention() {
+			continue
+		}
+		if err := purgeGuest(s, guest, org.GuestRetention, eventList); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeGuest deletes a guest's account and links, anonymizing their
+// responses unless the policy retains them
+func purgeGuest(s *Scheduler, guest User, policy GuestRetentionPolicy, eventList []Event) error {
+	if policy.Responses != GuestResponsesRetain {
+		anonymous := "anonymous-" + uuid.New().String()
+		for _, event := range eventList {
+			if err := anonymizeGuest(s, event, guest.ID, anonymous); err != nil {
+				return err
+			}
+			intakeAnswers.Remove(event.ID, guest.ID)
+		}
+	}
+	users.Delete(guest.ID)
+	guestInvites.Forget(guest.ID)
+	log.Printf("Purged stale guest %s (responses %s)", guest.ID, policy.responses())
+	return nil
+}
+
+func (p GuestRetentionPolicy) responses() string {
+	if p.Responses == "" {
+		return GuestResponsesAnonymize
+	}
+	return p.Responses
+}
+
+// anonymizeGuest replaces the guest's ID on one event's invitees and
+// responses, including responses conditional on them
+func anonymizeGuest(s *Scheduler, event Event, guestID, anonymous string) error {
+	return s.store.WithTransaction(func(tx Store) error {
+		invited := false
+		invitees := append([]string{}, event.Invitees...)
+		for i, id := range invitees {
+			if id == guestID {
+				invitees[i], invited = anonymous, true
+			}
+		}
+		if invited {
+			event.Invitees = invitees
+			if err := tx.UpdateEvent(event); err != nil {
+				return err
+			}
+		}
+		availabilityList, err := tx.ListAvailability(event.ID)
+		if err != nil {
+			return err
+		}
+		for _, avail := range availabilityList {
+			if avail.UserID != guestID && avail.ConditionalOn != guestID {
+				continue
+			}
+			if avail.UserID == guestID {
+				avail.UserID = anonymous
+			}
+			if avail.ConditionalOn == guestID {
+				avail.ConditionalOn = anonymous
+			}
+			if err := tx.UpdateAvailability(avail); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// Guest retention handlers
+func updateGuestRetention(c *gin.Context) {
+	org, ok := organizations.Get(c.Param("orgId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	var req GuestRetentionPolicy
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Days < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Retention days cannot be negative"})
+		return
+	}
+	if req.Responses != "" && req.Responses != GuestResponsesAnonymize && req.Responses != GuestResponsesRetain {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Responses must be anonymize or retain"})
+		return
+	}
+
+	org.GuestRetention = req
+	org.UpdatedAt = clock.Now()
+	organizations.Save(org)
+	c.JSON(http.StatusOK, req)
+}
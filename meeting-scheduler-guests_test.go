@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuestLinksExpire(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	scheduler, fake := newTestScheduler(t)
+	previousClock, previousInvites := clock, guestInvites
+	clock, guestInvites = fake, newGuestInviteRegistry()
+	t.Cleanup(func() { clock, guestInvites = previousClock, previousInvites })
+	users.Save(User{ID: "ada", OrgID: "acme", Role: RoleOrganizer})
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30, InviteEmails: []string{"zoe@example.com"}})
+	require.NoError(t, err)
+	invite := guestInvites.Issue(event.ID, event.Invitees[0], fake.Now())
+	path := strings.TrimPrefix(invite.URL(), publicURL())
+	assert.Equal(t, http.StatusOK, doJSON(router, http.MethodGet, path, nil).Code)
+
+	fake.Advance(guestInviteExpiry())
+	assert.Equal(t, http.StatusGone, doJSON(router, http.MethodGet, path, nil).Code)
+	renewed := guestInvites.Issue(event.ID, event.Invitees[0], fake.Now())
+	assert.NotEqual(t, invite.Token, renewed.Token, "an expired link is replaced")
+	guestInvites.purgeExpired(fake.Now().Add(guestInviteExpiry()))
+	assert.Equal(t, http.StatusNotFound, doJSON(router, http.MethodGet, path, nil).Code)
+}
+
+func TestStaleGuestsArePurgedPerOrgPolicy(t *testing.T) {
+	resetDirectory(t)
+	scheduler, fake := newTestScheduler(t)
+	previousClock, previousInvites, previousIntake := clock, guestInvites, intakeAnswers
+	clock, guestInvites, intakeAnswers = fake, newGuestInviteRegistry(), newIntakeRegistry()
+	t.Cleanup(func() { clock, guestInvites, intakeAnswers = previousClock, previousInvites, previousIntake })
+	t.Setenv("GUEST_RETENTION_DAYS", "30")
+	organizations.Save(Organization{ID: "acme"})
+	organizations.Save(Organization{ID: "keep", GuestRetention: GuestRetentionPolicy{Responses: GuestResponsesRetain}})
+	users.Save(User{ID: "ada", OrgID: "acme", Role: RoleOrganizer})
+	users.Save(User{ID: "kim", OrgID: "keep", Role: RoleOrganizer})
+
+	// respond invites the guest at email to a poll and has them answer it
+	respond := func(organizerID, email string, finalize bool) (Event, string) {
+		event, err := scheduler.CreateEvent(CreateEventRequest{
+			Title: "Interview", OrganizerID: organizerID, RequiredDuration: 30, InviteEmails: []string{email},
+			Questions: []Question{{ID: "q1", Prompt: "Phone number?", Kind: QuestionText}},
+		})
+		require.NoError(t, err)
+		start := fake.Now().Add(24 * time.Hour)
+		slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+		require.NoError(t, err)
+		guestID := event.Invitees[0]
+		_, err = scheduler.SubmitAvailability(event.ID, guestID, UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+		require.NoError(t, err)
+		_, err = scheduler.AnswerQuestions(event.ID, guestID, map[string]string{"q1": "555-0100"})
+		require.NoError(t, err)
+		if finalize {
+			_, err = scheduler.FinalizeEvent(event.ID, FinalizeEventRequest{TimeSlotID: slot.ID})
+			require.NoError(t, err)
+		}
+		return event, guestID
+	}
+	done, zoe := respond("ada", "zoe@example.com", true)
+	kept, yan := respond("kim", "yan@example.com", true)
+	_, ann := respond("ada", "ann@example.com", false)
+
+	fake.Advance(29 * 24 * time.Hour)
+	purgeStaleGuests(fake.Now())
+	_, ok := users.Get(zoe)
+	assert.True(t, ok, "not stale yet")
+
+	fake.Advance(2 * 24 * time.Hour)
+	purgeStaleGuests(fake.Now())
+	_, ok = users.Get(zoe)
+	assert.False(t, ok)
+	_, ok = users.Get(yan)
+	assert.False(t, ok)
+	_, ok = users.Get(ann)
+	assert.True(t, ok, "guests on active polls stay")
+
+	event, err := scheduler.GetEvent(done.ID)
+	require.NoError(t, err)
+	require.Len(t, event.Invitees, 1)
+	assert.True(t, strings.HasPrefix(event.Invitees[0], "anonymous-"))
+	availabilityList, err := store.ListAvailability(done.ID)
+	require.NoError(t, err)
+	require.Len(t, availabilityList, 1)
+	assert.Equal(t, event.Invitees[0], availabilityList[0].UserID, "anonymized responses still count")
+	assert.Empty(t, intakeAnswers.ForEvent(done.ID))
+
+	availabilityList, err = store.ListAvailability(kept.ID)
+	require.NoError(t, err)
+	require.Len(t, availabilityList, 1)
+	assert.Equal(t, yan, availabilityList[0].UserID, "the keep org retains responses")
+	assert.Len(t, intakeAnswers.ForEvent(kept.ID), 1)
+}
+
+func TestGuestRetentionEndpoint(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	admin := User{ID: "root", OrgID: "acme", Role: RoleAdmin}
+	users.Save(admin)
+	organizations.Save(Organization{ID: "acme"})
+	token, err := issueSessionToken(admin)
+	require.NoError(t, err)
+
+	put := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPut, "/api/v1/organizations/acme/guest-retention", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusBadRequest, put(`{"responses": "shred"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, put(`{"days": -1}`).Code)
+	require.Equal(t, http.StatusOK, put(`{"days": 7, "responses": "retain"}`).Code)
+	org, _ := organizations.Get("acme")
+	assert.Equal(t, GuestRetentionPolicy{Days: 7, Responses: GuestResponsesRetain}, org.GuestRetention)
+}
@@ -0,0 +1,137 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/mongodb"
+	tcmysql "github.com/testcontainers/testcontainers-go/modules/mysql"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TestMain runs the whole suite, HTTP API tests included, against the
+// database TEST_STORAGE names: mysql, postgres or mongo, started in a
+// throwaway container, or sqlite, in a temporary file. TEST_STORAGE_IMAGE
+// swaps the image, e.g. for mariadb:11. Without TEST_STORAGE the suite
+// runs in memory as usual.
+func TestMain(m *testing.M) {
+	kind := getenv("TEST_STORAGE", "")
+	if kind == "" {
+		os.Exit(m.Run())
+	}
+
+	ctx := context.Background()
+	container, database, err := startTestDatabase(ctx, kind)
+	if err != nil {
+		log.Fatalf("Starting the %s test database: %v", kind, err)
+	}
+	testDatabase = database
+	code := m.Run()
+	if container == nil {
+		os.Exit(code)
+	}
+	if err := testcontainers.TerminateContainer(container); err != nil {
+		log.Printf("Stopping the %s test database: %v", kind, err)
+	}
+	os.Exit(code)
+}
+
+func startTestDatabase(ctx context.Context, kind string) (testcontainers.Container, *ephemeralDatabase, error) {
+	switch kind {
+	case "mysql":
+		container, err := tcmysql.Run(ctx, getenv("TEST_STORAGE_IMAGE", "mysql:8.0"),
+			tcmysql.WithDatabase("scheduler"), tcmysql.WithUsername("root"), tcmysql.WithPassword("test"))
+		if err != nil {
+			return nil, nil, err
+		}
+		dsn, err := container.ConnectionString(ctx)
+		if err != nil {
+			return container, nil, err
+		}
+		databaseURL := "mysql://" + dsn
+		if _, err := (&migrator{url: databaseURL, dialect: "mysql"}).Up(); err != nil {
+			return container, nil, fmt.Errorf("migrating: %w", err)
+		}
+		s, err := openSQLStore(mysqlDialect, databaseURL)
+		if err != nil {
+			return container, nil, err
+		}
+		return container, clearedSQLDatabase(s), nil
+
+	case "postgres":
+		container, err := tcpostgres.Run(ctx, getenv("TEST_STORAGE_IMAGE", "postgres:16"),
+			tcpostgres.WithDatabase("scheduler"), tcpostgres.WithUsername("postgres"), tcpostgres.WithPassword("test"),
+			tcpostgres.BasicWaitStrategies())
+		if err != nil {
+			return nil, nil, err
+		}
+		databaseURL, err := container.ConnectionString(ctx, "sslmode=disable")
+		if err != nil {
+			return container, nil, err
+		}
+		if _, err := (&migrator{url: databaseURL, dialect: "postgres"}).Up(); err != nil {
+			return container, nil, fmt.Errorf("migrating: %w", err)
+		}
+		s, err := openSQLStore(postgresDialect, databaseURL)
+		if err != nil {
+			return container, nil, err
+		}
+		return container, clearedSQLDatabase(s), nil
+
+	case "sqlite":
+		dir, err := os.MkdirTemp("", "scheduler-sqlite")
+		if err != nil {
+			return nil, nil, err
+		}
+		databaseURL := "sqlite://" + filepath.Join(dir, "scheduler.db")
+		if _, err := (&migrator{url: databaseURL, dialect: "sqlite"}).Up(); err != nil {
+			return nil, nil, fmt.Errorf("migrating: %w", err)
+		}
+		s, err := openSQLStore(sqliteDialect, databaseURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, clearedSQLDatabase(s), nil
+
+	case "mongo":
+		// Transactions need a replica set
+		container, err := mongodb.Run(ctx, getenv("TEST_STORAGE_IMAGE", "mongo:7"), mongodb.WithReplicaSet("rs0"))
+		if err != nil {
+			return nil, nil, err
+		}
+		conn, err := container.ConnectionString(ctx)
+		if err != nil {
+			return container, nil, err
+		}
+		s, err := openMongoStore(strings.TrimSuffix(conn, "/") + "/scheduler_test?directConnection=true")
+		if err != nil {
+			return container, nil, err
+		}
+		return container, &ephemeralDatabase{store: s, clear: func() error {
+			_, err := s.events.DeleteMany(context.Background(), bson.M{})
+			return err
+		}}, nil
+	}
+	return nil, nil, fmt.Errorf("unknown TEST_STORAGE %q; use mysql, postgres, sqlite or mongo", kind)
+}
+
+// clearedSQLDatabase empties a SQL store's tables between tests
+func clearedSQLDatabase(s *sqlStore) *ephemeralDatabase {
+	return &ephemeralDatabase{store: s, clear: func() error {
+		for _, table := range []string{"user_availability", "time_slots", "events"} {
+			if _, err := s.exec("DELETE FROM " + table); err != nil {
+				return err
+			}
+		}
+		return nil
+	}}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const icsTimeFormat = "20060102T150405Z"
+
+// icsEscape escapes TEXT values per RFC 5545 section 3.3.11
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// icsFold splits content lines longer than 75 octets
+func icsFold(line string) string {
+	var b strings.Builder
+	for len(line) > 75 {
+		cut := 75
+		// Don't split a multi-byte UTF-8 sequence
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+// buildICS renders a single-event calendar for a confirmed meeting, carrying
+// the organization's name, reply-to address, logo and footer
+func buildICS(event Event, slot TimeSlot, now time.Time) string {
+	branding, orgName := brandingForOrg(event.OrgID)
+
+	summary := event.Title
+	if orgName != "" {
+		summary = orgName + ": " + event.Title
+	}
+	description := event.Description
+	if branding.Footer != "" {
+		if description != "" {
+			description += "\n\n"
+		}
+		description += branding.Footer
+	}
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Meeting Scheduler//EN",
+		"METHOD:PUBLISH",
+	}
+	if orgName != "" {
+		lines = append(lines, "X-WR-CALNAME:"+icsEscape(orgName))
+	}
+	lines = append(lines,
+		"BEGIN:VEVENT",
+		"UID:"+event.ID+"@meeting-scheduler",
+		"DTSTAMP:"+now.UTC().Format(icsTimeFormat),
+		"DTSTART:"+slot.StartTime.UTC().Format(icsTimeFormat),
+		"DTEND:"+slot.StartTime.UTC().Add(event.duration()).Format(icsTimeFormat),
+		"SUMMARY:"+icsEscape(summary),
+	)
+	if description != "" {
+		lines = append(lines, "DESCRIPTION:"+icsEscape(description))
+	}
+	if event.Location != "" {
+		lines = append(lines, "LOCATION:"+icsEscape(event.Location))
+	}
+	if branding.ReplyTo != "" {
+		lines = append(lines, fmt.Sprintf("ORGANIZER;CN=%s:mailto:%s", icsEscape(orgName), branding.ReplyTo))
+	}
+	if branding.LogoURL != "" {
+		lines = append(lines, "IMAGE;VALUE=URI;DISPLAY=BADGE:"+branding.LogoURL)
+	}
+	lines = append(lines, "END:VEVENT", "END:VCALENDAR")
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(icsFold(line))
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
+
+// getEventICS serves the confirmed meeting as an .ics file
+func getEventICS(c *gin.Context) {
+	scheduler := contextScheduler(c)
+	event, err := scheduler.GetEvent(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if event.FinalTimeSlotID == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Event has not been finalized"})
+		return
+	}
+	slot, err := scheduler.GetTimeSlot(event.FinalTimeSlotID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+event.ID+`.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(buildICS(event, slot, clock.Now())))
+}
+
+// Limits for calendar uploads
+const (
+	maxICSUploadBytes = 1 << 20
+	maxImportedSlots  = 200
+)
+
+// icsUnfold reads content lines, joining folded continuation lines
+func icsUnfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxICSUploadBytes)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// icsProperty splits "NAME;PARAM=1:value" into its parts
+func icsProperty(line string) (name string, params map[string]string, value string) {
+	head, value, _ := strings.Cut(line, ":")
+	parts := strings.Split(head, ";")
+	params = make(map[string]string, len(parts)-1)
+	for _, part := range parts[1:] {
+		if key, val, ok := strings.Cut(part, "="); ok {
+			params[strings.ToUpper(key)] = strings.Trim(val, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, value
+}
+
+// icsParseTime parses a DATE-TIME or DATE value. Floating times use the
+// fallback location.
+func icsParseTime(params map[string]string, value string, fallback *time.Location) (time.Time, error) {
+	loc := fallback
+	if tzid := params["TZID"]; tzid != "" {
+		var err error
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return time.Time{}, fmt.Errorf("unknown TZID %q", tzid)
+		}
+	}
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		return time.ParseInLocation("20060102", value, loc)
+	}
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(icsTimeFormat, value)
+	}
+	return time.ParseInLocation("20060102T150405", value, loc)
+}
+
+var icsDurationPattern = regexp.MustCompile(`^P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// icsParseDuration parses a positive RFC 5545 DURATION value like PT1H30M
+func icsParseDuration(value string) (time.Duration, error) {
+	m := icsDurationPattern.FindStringSubmatch(strings.TrimPrefix(value, "+"))
+	if m == nil || value == "P" || value == "PT" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+1] != "" {
+			n, err := strconv.ParseInt(m[i+1], 10, 64)
+			if err != nil || time.Duration(n) > (math.MaxInt64-d)/unit {
+				return 0, fmt.Errorf("duration %q is too long", value)
+			}
+			d += time.Duration(n) * unit
+		}
+	}
+	return d, nil
+}
+
+// parseICSBlocks reads the VEVENTs of a calendar as candidate slots.
+// Cancelled events are skipped; all-day events span their whole day(s).
+func parseICSBlocks(r io.Reader, fallback *time.Location) ([]TimeSlot, error) {
+	lines, err := icsUnfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var slots []TimeSlot
+	var inEvent, cancelled, allDay bool
+	var start, end time.Time
+	var duration time.Duration
+	for n, line := range lines {
+		name, params, value := icsProperty(line)
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			inEvent, cancelled = true, false
+			start, end, duration = time.Time{}, time.Time{}, 0
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			inEvent = false
+			if cancelled {
+				continue
+			}
+			if start.IsZero() {
+				return nil, fmt.Errorf("line %d: event without DTSTART", n+1)
+			}
+			if end.IsZero() {
+				switch {
+				case duration > 0:
+					end = start.Add(duration)
+				case allDay:
+					end = start.AddDate(0, 0, 1)
+				default:
+					return nil, fmt.Errorf("line %d: event without DTEND or DURATION", n+1)
+				}
+			}
+			if !end.After(start) {
+				return nil, fmt.Errorf("line %d: event ends before it starts", n+1)
+			}
+			slots = append(slots, TimeSlot{StartTime: start.UTC(), EndTime: end.UTC()})
+		case !inEvent:
+		case name == "DTSTART":
+			allDay = params["VALUE"] == "DATE" || len(value) == 8
+			if start, err = icsParseTime(params, value, fallback); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+		case name == "DTEND":
+			if end, err = icsParseTime(params, value, fallback); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+		case name == "DURATION":
+			if duration, err = icsParseDuration(value); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+		case name == "STATUS":
+			cancelled = strings.EqualFold(value, "CANCELLED")
+		}
+	}
+	return slots, nil
+}
+
+// ImportTimeSlots turns each event of an uploaded calendar into a time
+// slot for the event
+func (s *Scheduler) ImportTimeSlots(eventID string, calendar io.Reader, zone string) ([]TimeSlot, error) {
+	if _, err := s.GetEvent(eventID); err != nil {
+		return nil, err
+	}
+	if zone == "" {
+		zone = "UTC"
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, invalid("Invalid time zone " + zone)
+	}
+
+	blocks, err := parseICSBlocks(calendar, loc)
+	if err != nil {
+		return nil, invalid("Invalid calendar: " + err.Error())
+	}
+	if len(blocks) == 0 {
+		return nil, invalid("Calendar contains no events")
+	}
+	if len(blocks) > maxImportedSlots {
+		return nil, invalid(fmt.Sprintf("Calendar has more than %d events", maxImportedSlots))
+	}
+
+	created := make([]TimeSlot, 0, len(blocks))
+	for _, block := range blocks {
+		slot, err := s.CreateTimeSlot(eventID, CreateTimeSlotRequest{StartTime: block.StartTime, EndTime: block.EndTime})
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, slot)
+	}
+	return created, nil
+}
+
+// importTimeSlots accepts a calendar as a raw text/calendar body or as the
+// "file" field of a multipart upload. The timeZone query parameter applies
+// to floating times.
+func importTimeSlots(c *gin.Context) {
+	scheduler := contextScheduler(c)
+	eventID := c.Param("eventId")
+	if _, err := scheduler.GetEvent(eventID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxICSUploadBytes)
+	var calendar io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing calendar file"})
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer file.Close()
+		calendar = file
+	}
+
+	slots, err := scheduler.ImportTimeSlots(eventID, calendar, c.Query("timeZone"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, slots)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const holdsCalendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Hold\r\n" +
+	"DTSTART:20250115T140000Z\r\n" +
+	"DTEND:20250115T160000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Hold with a long description that the exporter folded onto a\r\n" +
+	"  second line\r\n" +
+	"DTSTART;TZID=America/New_York:20250116T090000\r\n" +
+	"DURATION:PT1H30M\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Dropped hold\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"DTSTART:20250117T090000Z\r\n" +
+	"DTEND:20250117T100000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20250120\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICSBlocks(t *testing.T) {
+	slots, err := parseICSBlocks(strings.NewReader(holdsCalendar), time.UTC)
+	require.NoError(t, err)
+	require.Len(t, slots, 3)
+
+	assert.Equal(t, time.Date(2025, 1, 15, 14, 0, 0, 0, time.UTC), slots[0].StartTime)
+	assert.Equal(t, time.Date(2025, 1, 15, 16, 0, 0, 0, time.UTC), slots[0].EndTime)
+	assert.Equal(t, time.Date(2025, 1, 16, 14, 0, 0, 0, time.UTC), slots[1].StartTime)
+	assert.Equal(t, time.Date(2025, 1, 16, 15, 30, 0, 0, time.UTC), slots[1].EndTime)
+	assert.Equal(t, time.Date(2025, 1, 21, 0, 0, 0, 0, time.UTC), slots[2].EndTime)
+
+	_, err = parseICSBlocks(strings.NewReader("BEGIN:VEVENT\r\nDTSTART:20250115T140000Z\r\nEND:VEVENT\r\n"), time.UTC)
+	assert.Error(t, err)
+}
+
+// FuzzParseICSBlocks feeds arbitrary calendars to the importer, which
+// must reject or return slots that end after they start
+func FuzzParseICSBlocks(f *testing.F) {
+	f.Add(holdsCalendar)
+	f.Add("BEGIN:VEVENT\r\nDTSTART;TZID=Europe/Berlin:20250330T013000\r\nDURATION:PT1H\r\nEND:VEVENT\r\n")
+	f.Add("BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20250115\r\nEND:VEVENT\r\n")
+	f.Add("BEGIN:VEVENT\r\nDTSTART:20250115T140000Z\r\nDURATION:P99999999999W\r\nEND:VEVENT\r\n")
+	f.Fuzz(func(t *testing.T, calendar string) {
+		slots, err := parseICSBlocks(strings.NewReader(calendar), time.UTC)
+		if err != nil {
+			return
+		}
+		for i, slot := range slots {
+			if !slot.EndTime.After(slot.StartTime) {
+				t.Fatalf("slot %d is %s-%s", i, slot.StartTime, slot.EndTime)
+			}
+		}
+	})
+}
+
+func FuzzICSParseDuration(f *testing.F) {
+	for _, seed := range []string{"PT1H30M", "P1W", "P2DT12H", "+PT15M", "P9223372036854775807W", "PT"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		if d, err := icsParseDuration(value); err == nil && d < 0 {
+			t.Fatalf("icsParseDuration(%q) = %s", value, d)
+		}
+	})
+}
+
+func TestImportTimeSlotsFromUpload(t *testing.T) {
+	router := newTestRouter(t)
+	w := doJSON(router, "POST", "/api/v1/events", CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 60})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var event Event
+	decodeJSON(t, w, &event)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "holds.ics")
+	part.Write([]byte(holdsCalendar))
+	form.Close()
+
+	req, _ := http.NewRequest("POST", "/api/v1/events/"+event.ID+"/timeslots/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	slots, err := store.ListTimeSlots(event.ID)
+	require.NoError(t, err)
+	assert.Len(t, slots, 3)
+
+	req, _ = http.NewRequest("POST", "/api/v1/events/"+event.ID+"/timeslots/import", strings.NewReader("not a calendar"))
+	req.Header.Set("Content-Type", "text/calendar")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// impersonatedByHeader is set on every response to an impersonated
+// request so clients can show who is really acting
+const impersonatedByHeader = "X-Impersonated-By"
+
+// maxImpersonationTTL caps how long one impersonation may be asked for
+const maxImpersonationTTL = time.Hour
+
+// maxImpersonationPage caps how many log entries one listing returns
+const maxImpersonationPage = 1000
+
+// Impersonation audit actions
+const (
+	ImpersonationStarted = "started"
+	ImpersonationRequest = "request"
+	ImpersonationEnded   = "ended"
+)
+
+type StartImpersonationRequest struct {
+	UserID string `json:"userId" binding:"required"`
+	// Reason is kept in the audit log, such as a support ticket
+	Reason  string `json:"reason" binding:"required"`
+	Minutes int    `json:"minutes"`
+}
+
+// ImpersonationEntry is one line of the impersonation audit log
+type ImpersonationEntry struct {
+	// ID is assigned by the store, in the order entries were added
+	ID             string    `json:"id"`
+	SessionID      string    `json:"sessionId"`
+	ImpersonatorID string    `json:"impersonatorId"`
+	UserID         string    `json:"userId"`
+	Action         string    `json:"action"`
+	Reason         string    `json:"reason,omitempty"`
+	ClientIP       string    `json:"clientIp,omitempty"`
+	Method         string    `json:"method,omitempty"`
+	Path           string    `json:"path,omitempty"`
+	Status         int       `json:"status,omitempty"`
+	At             time.Time `json:"at"`
+}
+
+// ImpersonationStore keeps the impersonation audit log where every
+// replica writes it, so it outlives restarts
+type ImpersonationStore interface {
+	// AddImpersonationEntry appends the entry, giving it the next ID
+	AddImpersonationEntry(entry ImpersonationEntry) error
+	// ImpersonationEntries lists up to limit entries, oldest first, from
+	// the one after the entry with ID after, or from the start when after
+	// is empty. Only sessionID's are listed when it is set. An after that
+	// isn't an ID from this store matches nothing.
+	ImpersonationEntries(sessionID, after string, limit int) ([]ImpersonationEntry, error)
+	// PruneImpersonationEntries forgets entries from before cutoff
+	PruneImpersonationEntries(cutoff time.Time) error
+}
+
+// impersonationStoreFor is the database underneath the store when it can
+// keep the log. Otherwise it is kept in memory, per instance.
+func impersonationStoreFor(s Store) ImpersonationStore {
+	if impersonationStore, ok := underlyingStore(s).(ImpersonationStore); ok {
+		return impersonationStore
+	}
+	return newMemoryImpersonations()
+}
+
+// impersonationLogRetention is how long entries are kept.
+// IMPERSONATION_LOG_RETENTION_DAYS sets it.
+func impersonationLogRetention() time.Duration {
+	return time.Duration(getenvInt("IMPERSONATION_LOG_RETENTION_DAYS", 365)) * 24 * time.Hour
+}
+
+// impersonationLog is the audit log of impersonations, kept in an
+// ImpersonationStore
+type impersonationLog struct {
+	store ImpersonationStore
+}
+
+// newImpersonationLog returns a log kept in memory
+func newImpersonationLog() *impersonationLog {
+	return &impersonationLog{store: newMemoryImpersonations()}
+}
+
+func (l *impersonationLog) Add(entry ImpersonationEntry) error {
+	return l.store.AddImpersonationEntry(entry)
+}
+
+// List returns a page of the log, only one session's entries when
+// sessionID is set
+func (l *impersonationLog) List(sessionID, after string, limit int) ([]ImpersonationEntry, error) {
+	list, err := l.store.ImpersonationEntries(sessionID, after, limit)
+	if list == nil {
+		list = []ImpersonationEntry{}
+	}
+	return list, err
+}
+
+var impersonations = newImpersonationLog()
+
+// pruneImpersonations drops log entries older than the retention
+func pruneImpersonations(now time.Time) {
+	if err := impersonations.store.PruneImpersonationEntries(now.Add(-impersonationLogRetention())); err != nil {
+		log.Printf("Pruning the impersonation log failed, will retry: %v", err)
+	}
+}
+
+// memoryImpersonation is a log entry with its position in memory
+type memoryImpersonation struct {
+	seq   uint64
+	entry ImpersonationEntry
+}
+
+// memoryImpersonations is the ImpersonationStore for single-instance
+// deployments
+type memoryImpersonations struct {
+	mu      sync.Mutex
+	entries []memoryImpersonation
+	seq     uint64
+}
+
+func newMemoryImpersonations() *memoryImpersonations {
+	return &memoryImpersonations{}
+}
+
+func (m *memoryImpersonations) AddImpersonationEntry(entry ImpersonationEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	entry.ID = strconv.FormatUint(m.seq, 10)
+	m.entries = append(m.entries, memoryImpersonation{seq: m.seq, entry: entry})
+	return nil
+}
+
+func (m *memoryImpersonations) ImpersonationEntries(sessionID, after string, limit int) ([]ImpersonationEntry, error) {
+	var afterSeq uint64
+	if after != "" {
+		var err error
+		if afterSeq, err = strconv.ParseUint(after, 10, 64); err != nil {
+			return nil, nil
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []ImpersonationEntry
+	for _, e := range m.entries {
+		if len(list) == limit {
+			break
+		}
+		if e.seq > afterSeq && (sessionID == "" || e.entry.SessionID == sessionID) {
+			list = append(list, e.entry)
+		}
+	}
+	return list, nil
+}
+
+func (m *memoryImpersonations) PruneImpersonationEntries(cutoff time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.entries[:0]
+	for _, e := range m.entries {
+		if !e.entry.At.Before(cutoff) {
+			kept = append(kept, e)
+		}
+	}
+	m.entries = kept
+	return nil
+}
+
+// impersonationTTL is how long impersonations last unless asked for less.
+// IMPERSONATION_TTL sets it.
+func impersonationTTL() time.Duration {
+	return min(getenvDuration("IMPERSONATION_TTL", 30*time.Minute), maxImpersonationTTL)
+}
+
+type impersonatorKey struct{}
+
+// withImpersonator marks a request's context as acting under impersonation
+func withImpersonator(ctx context.Context, impersonatorID string) context.Context {
+	return context.WithValue(ctx, impersonatorKey{}, impersonatorID)
+}
+
+// impersonatorFrom returns who is impersonating the request's user, if
+// anyone
+func impersonatorFrom(ctx context.Context) string {
+	id, _ := ctx.Value(impersonatorKey{}).(string)
+	return id
+}
+
+// forbidImpersonation keeps impersonation sessions off the routes that
+// change how the user signs in or is reached, so an operator can't lock
+// them out or sign them out
+func forbidImpersonation(c *gin.Context) {
+	if impersonatorFrom(c.Request.Context()) != "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Not allowed while impersonating"})
+		return
+	}
+	c.Next()
+}
+
+// recordImpersonation logs every request made in an impersonation
+// session, whatever its outcome
+func recordImpersonation(c *gin.Context) {
+	c.Next()
+	impersonatorID := impersonatorFrom(c.Request.Context())
+	if impersonatorID == "" {
+		return
+	}
+	user, _ := currentUser(c)
+	sessionID, _ := c.Get(currentSessionKey)
+	id, _ := sessionID.(string)
+	err := impersonations.Add(ImpersonationEntry{
+		SessionID:      id,
+		ImpersonatorID: impersonatorID,
+		UserID:         user.ID,
+		Action:         ImpersonationRequest,
+		ClientIP:       c.ClientIP(),
+		Method:         c.Request.Method,
+		Path:           c.Request.URL.RequestURI(),
+		Status:         c.Writer.Status(),
+		At:             clock.Now(),
+	})
+	if err != nil {
+		log.Printf("Recording impersonated request to %s: %v", c.Request.URL.Path, err)
+	}
+}
+
+// startImpersonation gives an operator a bearer token acting as the user
+// for a limited time. Impersonation sessions can't be refreshed and show
+// up in the user's own session list.
+func startImpersonation(c *gin.Context) {
+	admin, _ := currentUser(c)
+	if impersonatorFrom(c.Request.Context()) != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Impersonation sessions can't impersonate"})
+		return
+	}
+	var req StartImpersonationRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ttl := impersonationTTL()
+	if req.Minutes < 0 || time.Duration(req.Minutes)*time.Minute > ttl {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "minutes must be between 1 and " + ttl.String()})
+		return
+	}
+	if req.Minutes > 0 {
+		ttl = time.Duration(req.Minutes) * time.Minute
+	}
+	user, ok := users.Get(req.UserID)
+	if !ok || user.Deactivated {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if isOperator(user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Operators can't be impersonated"})
+		return
+	}
+
+	now := clock.Now()
+	session, err := sessions.StartImpersonation(user.ID, admin.ID, c.ClientIP(), now, now.Add(ttl))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	token, err := signAccessToken(user, session.ID, now, session.ExpiresAt)
+	if err != nil {
+		sessions.Revoke(user.ID, session.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// An impersonation that can't be audited doesn't start
+	err = impersonations.Add(ImpersonationEntry{
+		SessionID:      session.ID,
+		ImpersonatorID: admin.ID,
+		UserID:         user.ID,
+		Action:         ImpersonationStarted,
+		Reason:         req.Reason,
+		ClientIP:       c.ClientIP(),
+		At:             now,
+	})
+	if err != nil {
+		sessions.Revoke(user.ID, session.ID)
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"sessionId": session.ID, "token": token, "user": user, "expiresAt": session.ExpiresAt})
+}
+
+// endImpersonation revokes an impersonation session before it expires
+func endImpersonation(c *gin.Context) {
+	admin, _ := currentUser(c)
+	session, err := sessions.Active(c.Param("sessionId"), clock.Now())
+	if errors.Is(err, ErrNotFound) || err == nil && session.ImpersonatorID == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Impersonation not found"})
+		return
+	}
+	if err == nil {
+		err = sessions.Revoke(session.UserID, session.ID)
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	err = impersonations.Add(ImpersonationEntry{
+		SessionID:      session.ID,
+		ImpersonatorID: session.ImpersonatorID,
+		UserID:         session.UserID,
+		Action:         ImpersonationEnded,
+		Reason:         "ended by " + admin.ID,
+		ClientIP:       c.ClientIP(),
+		At:             clock.Now(),
+	})
+	if err != nil {
+		log.Printf("Recording the end of impersonation %s: %v", session.ID, err)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// listImpersonations pages through the log oldest first: up to limit
+// entries after the one whose ID is after
+func listImpersonations(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+		return
+	}
+	entries, err := impersonations.List(c.Query("sessionId"), c.Query("after"), min(limit, maxImpersonationPage))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperatorsCanImpersonateUsersForALimitedTime(t *testing.T) {
+	resetDirectory(t)
+	t.Setenv("OPERATOR_ORG", "ops")
+	router := newTestRouter(t)
+	fake := useFakeClock(t, time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	previousSessions, previousLog, previousBus := sessions, impersonations, bus
+	sessions, impersonations, bus = newSessionRegistry(), newImpersonationLog(), newEventBus()
+	t.Cleanup(func() { sessions, impersonations, bus = previousSessions, previousLog, previousBus })
+	bus.SubscribeAll(recordActivity)
+	operator := User{ID: "sre", OrgID: "ops", Role: RoleAdmin}
+	ada := User{ID: "ada", OrgID: "acme", Role: RoleOrganizer}
+	users.Save(operator)
+	users.Save(ada)
+	operatorToken, err := issueSessionToken(operator)
+	require.NoError(t, err)
+	adaToken, err := issueSessionToken(ada)
+	require.NoError(t, err)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/v1/admin/impersonations", adaToken, `{"userId": "sre", "reason": "T-1"}`).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/v1/admin/impersonations", operatorToken, `{"userId": "sre", "reason": "T-1"}`).Code, "operators can't be impersonated")
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/admin/impersonations", operatorToken, `{"userId": "ada", "reason": "T-1", "minutes": 600}`).Code)
+
+	w := do(http.MethodPost, "/api/v1/admin/impersonations", operatorToken, `{"userId": "ada", "reason": "T-1", "minutes": 10}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var started struct {
+		SessionID string    `json:"sessionId"`
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+	decodeJSON(t, w, &started)
+	assert.Equal(t, fake.Now().Add(10*time.Minute), started.ExpiresAt)
+
+	w = do(http.MethodGet, "/api/v1/users/me", started.Token, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"ada"`)
+	assert.Equal(t, "sre", w.Header().Get(impersonatedByHeader))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/v1/admin/impersonations", started.Token, `{"userId": "ada", "reason": "T-1"}`).Code)
+
+	w = do(http.MethodPost, "/api/v1/events", started.Token, `{"title": "Repro", "organizerId": "ada", "requiredDuration": 30}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var event Event
+	decodeJSON(t, w, &event)
+	timeline := activity.ForEvent(event.ID)
+	require.NotEmpty(t, timeline)
+	assert.Equal(t, "sre", timeline[0].ImpersonatorID, "changes are marked in the audit trail")
+
+	w = do(http.MethodGet, "/api/v1/users/me/sessions", adaToken, "")
+	assert.Contains(t, w.Body.String(), `"impersonatorId":"sre"`, "users can see who acted as them")
+
+	entries, err := impersonations.List(started.SessionID, "", maxImpersonationPage)
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	assert.Equal(t, ImpersonationStarted, entries[0].Action)
+	assert.Equal(t, "T-1", entries[0].Reason)
+	for _, entry := range entries[1:] {
+		assert.Equal(t, ImpersonationRequest, entry.Action)
+		assert.Equal(t, "sre", entry.ImpersonatorID)
+		assert.Equal(t, "ada", entry.UserID)
+	}
+	assert.Equal(t, "/api/v1/events", entries[3].Path)
+
+	// The log pages through the endpoint
+	var page []ImpersonationEntry
+	decodeJSON(t, do(http.MethodGet, "/api/v1/admin/impersonations?limit=3", operatorToken, ""), &page)
+	assert.Equal(t, entries[:3], page)
+	decodeJSON(t, do(http.MethodGet, "/api/v1/admin/impersonations?limit=3&after="+page[2].ID, operatorToken, ""), &page)
+	assert.Equal(t, entries[3:], page)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/v1/admin/impersonations?limit=0", operatorToken, "").Code)
+
+	// Nothing that changes how the user signs in or is reached
+	var adaSessions []Session
+	decodeJSON(t, do(http.MethodGet, "/api/v1/users/me/sessions", adaToken, ""), &adaSessions)
+	var ownSessionID string
+	for _, session := range adaSessions {
+		if session.ImpersonatorID == "" {
+			ownSessionID = session.ID
+		}
+	}
+	require.NotEmpty(t, ownSessionID)
+	for _, route := range [][2]string{
+		{http.MethodPost, "/api/v1/users/me/two-factor"},
+		{http.MethodPost, "/api/v1/auth/two-factor"},
+		{http.MethodDelete, "/api/v1/users/me/two-factor"},
+		{http.MethodDelete, "/api/v1/users/me/sessions/" + ownSessionID},
+		{http.MethodPut, "/api/v1/users/me/phone"},
+		{http.MethodDelete, "/api/v1/users/me/phone"},
+		{http.MethodPost, "/api/v1/users/me/devices"},
+	} {
+		assert.Equal(t, http.StatusForbidden, do(route[0], route[1], started.Token, `{}`).Code, route[1])
+	}
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/users/me", adaToken, "").Code, "the user's own session is untouched")
+
+	fake.Advance(10 * time.Minute)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/v1/users/me", started.Token, "").Code, "impersonation expires")
+
+	w = do(http.MethodPost, "/api/v1/admin/impersonations", operatorToken, `{"userId": "ada", "reason": "T-2"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	decodeJSON(t, w, &started)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/v1/admin/impersonations/"+started.SessionID, operatorToken, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/v1/users/me", started.Token, "").Code)
+	ended, err := impersonations.List(started.SessionID, "", maxImpersonationPage)
+	require.NoError(t, err)
+	assert.Equal(t, ImpersonationEnded, ended[len(ended)-1].Action)
+}
+
+func TestImpersonationLogPrunesOldEntries(t *testing.T) {
+	fake := useFakeClock(t, time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	t.Setenv("IMPERSONATION_LOG_RETENTION_DAYS", "30")
+	previous := impersonations
+	impersonations = newImpersonationLog()
+	t.Cleanup(func() { impersonations = previous })
+
+	require.NoError(t, impersonations.Add(ImpersonationEntry{SessionID: "s1", Action: ImpersonationStarted, At: fake.Now()}))
+	fake.Advance(20 * 24 * time.Hour)
+	require.NoError(t, impersonations.Add(ImpersonationEntry{SessionID: "s2", Action: ImpersonationStarted, At: fake.Now()}))
+
+	pruneImpersonations(fake.Now().Add(15 * 24 * time.Hour))
+	entries, err := impersonations.List("", "", maxImpersonationPage)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "s2", entries[0].SessionID)
+}
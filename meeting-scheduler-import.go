@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// EventImport is the result of importing an exported event. Mapping
+// takes each ID in the document to the ID it was recreated with.
+type EventImport struct {
+	Event    Event             `json:"event"`
+	Mapping  map[string]string `json:"mapping,omitempty"`
+	Warnings []string          `json:"warnings"`
+}
+
+// validateExport checks that the document hangs together before anything
+// is written
+func validateExport(doc EventExport) error {
+	if doc.FormatVersion != exportFormatVersion {
+		return invalid(fmt.Sprintf("Unsupported export format version %d", doc.FormatVersion))
+	}
+	if doc.Event.ID == "" || doc.Event.Title == "" || doc.Event.OrganizerID == "" {
+		return invalid("Export must contain an event with an ID, title and organizer")
+	}
+	slotIDs := map[string]bool{}
+	for _, slot := range doc.TimeSlots {
+		if slot.ID == "" || slotIDs[slot.ID] {
+			return invalid("Export time slots must have unique IDs")
+		}
+		if !slot.EndTime.After(slot.StartTime) {
+			return invalid(fmt.Sprintf("Time slot %s must end after it starts", slot.ID))
+		}
+		slotIDs[slot.ID] = true
+	}
+	if doc.Event.FinalTimeSlotID != "" && !slotIDs[doc.Event.FinalTimeSlotID] {
+		return invalid("Export's final time slot is missing from its time slots")
+	}
+	responded := map[string]bool{}
+	for _, avail := range doc.Availability {
+		if !slotIDs[avail.TimeSlotID] {
+			return invalid(fmt.Sprintf("Availability %s refers to unknown time slot %s", avail.ID, avail.TimeSlotID))
+		}
+		key := avail.UserID + "/" + avail.TimeSlotID
+		if responded[key] {
+			return invalid(fmt.Sprintf("Export has two responses from %s for time slot %s", avail.UserID, avail.TimeSlotID))
+		}
+		responded[key] = true
+		if err := validateAvailabilityStatus(avail.Status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportEvent recreates an exported event graph under fresh IDs in orgID.
+// References that don't resolve here, such as prerequisites, meeting
+// types and workspaces from another environment, are dropped with a
+// warning. Nothing is announced to participants.
+func (s *Scheduler) ImportEvent(doc EventExport, orgID string) (EventImport, error) {
+	if err := validateExport(doc); err != nil {
+		return EventImport{}, err
+	}
+	result := EventImport{Mapping: map[string]string{}, Warnings: []string{}}
+	fresh := func(id string) string {
+		result.Mapping[id] = uuid.New().String()
+		return result.Mapping[id]
+	}
+
+	now := s.clock.Now()
+	event := doc.Event
+	event.ID = fresh(doc.Event.ID)
+	event.OrgID = orgID
+	event.UpdatedAt = now
+	// A copy isn't evidence; the original stays under its hold
+	event.LegalHold = nil
+	event.DependsOn = nil
+	for _, id := range doc.Event.DependsOn {
+		if prerequisite, err := s.store.GetEvent(id); err == nil && prerequisite.OrgID == orgID {
+			event.DependsOn = append(event.DependsOn, id)
+		} else {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Dropped unknown prerequisite %s", id))
+		}
+	}
+	if event.MeetingTypeID != "" {
+		if _, ok := meetingTypes.Get(orgID, event.MeetingTypeID); !ok {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Dropped unknown meeting type %s", event.MeetingTypeID))
+			event.MeetingTypeID = ""
+		}
+	}
+	if event.WorkspaceID != "" {
+		if _, ok := workspaces.Get(orgID, event.WorkspaceID); !ok {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Dropped unknown workspace %s", event.WorkspaceID))
+			event.WorkspaceID = ""
+		}
+	}
+
+	slots := make([]TimeSlot, 0, len(doc.TimeSlots))
+	for _, slot := range doc.TimeSlots {
+		slot.ID = fresh(slot.ID)
+		slot.EventID = event.ID
+		slots = append(slots, slot)
+	}
+	if event.FinalTimeSlotID != "" {
+		event.FinalTimeSlotID = result.Mapping[event.FinalTimeSlotID]
+	}
+	availabilityList := make([]UserAvailability, 0, len(doc.Availability))
+	for _, avail := range doc.Availability {
+		avail.ID = fresh(avail.ID)
+		avail.EventID = event.ID
+		avail.TimeSlotID = result.Mapping[avail.TimeSlotID]
+		availabilityList = append(availabilityList, avail)
+	}
+
+	err := s.store.WithTransaction(func(tx Store) error {
+		if err := tx.CreateEvent(event); err != nil {
+			return err
+		}
+		for _, slot := range slots {
+			if err := tx.CreateTimeSlot(slot); err != nil {
+				return err
+			}
+		}
+		for _, avail := range availabilityList {
+			if err := tx.CreateAvailability(avail); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return EventImport{}, err
+	}
+
+	for _, answers := range doc.Answers {
+		answers.EventID = event.ID
+		intakeAnswers.Save(answers)
+	}
+	for _, alert := range doc.Alerts {
+		alert.ID = fresh(alert.ID)
+		alert.EventID = event.ID
+		if alert.FiredSlotID != "" {
+			alert.FiredSlotID = result.Mapping[alert.FiredSlotID]
+		}
+		quorumAlerts.Save(alert)
+	}
+	for _, comment := range doc.Comments {
+		comment.ID = fresh(comment.ID)
+		comment.EventID = event.ID
+		comments.Add(comment)
+	}
+	for _, entry := range doc.Audit {
+		activity.Add(event.ID, entry)
+	}
+	s.publish(EventImported, event.ID, event)
+
+	result.Event = event
+	return result, nil
+}
+
+// importEvent takes an export document into the caller's organization.
+// ?includeMapping=true returns the old-to-new ID mapping.
+func importEvent(c *gin.Context) {
+	user, _ := currentUser(c)
+	var doc EventExport
+	if err := bindJSON(c, &doc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	result, err := contextScheduler(c).ImportEvent(doc, user.OrgID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if c.Query("includeMapping") != "true" {
+		result.Mapping = nil
+	}
+	c.JSON(http.StatusCreated, result)
+}
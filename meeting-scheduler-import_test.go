@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportRecreatesExportUnderFreshIDs(t *testing.T) {
+	scheduler, fake := newTestScheduler(t)
+	previousComments := comments
+	comments = newCommentRegistry()
+	t.Cleanup(func() { comments = previousComments })
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30, DependsOn: nil})
+	require.NoError(t, err)
+	start := fake.Now().Add(24 * time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+	_, err = scheduler.FinalizeEvent(event.ID, FinalizeEventRequest{TimeSlotID: slot.ID})
+	require.NoError(t, err)
+	comments.Add(Comment{ID: "c1", EventID: event.ID, AuthorID: "bob", Body: "See you there", CreatedAt: fake.Now()})
+
+	doc, err := scheduler.ExportEvent(event.ID)
+	require.NoError(t, err)
+	doc.Event.DependsOn = []string{"elsewhere"}
+	imported, err := scheduler.ImportEvent(doc, "acme")
+	require.NoError(t, err)
+
+	copied := imported.Event
+	assert.NotEqual(t, event.ID, copied.ID)
+	assert.Equal(t, imported.Mapping[event.ID], copied.ID)
+	assert.Equal(t, "acme", copied.OrgID)
+	assert.Equal(t, "finalized", copied.Status)
+	assert.Equal(t, imported.Mapping[slot.ID], copied.FinalTimeSlotID)
+	assert.Empty(t, copied.DependsOn)
+	assert.Equal(t, []string{"Dropped unknown prerequisite elsewhere"}, imported.Warnings)
+
+	slots, err := scheduler.ListTimeSlots(copied.ID)
+	require.NoError(t, err)
+	require.Len(t, slots, 1)
+	assert.Equal(t, copied.FinalTimeSlotID, slots[0].ID)
+	responses, err := store.ListAvailability(copied.ID)
+	require.NoError(t, err)
+	require.Len(t, responses, 1)
+	assert.Equal(t, slots[0].ID, responses[0].TimeSlotID)
+	require.Len(t, comments.ForEvent(copied.ID), 1)
+	assert.Len(t, comments.ForEvent(event.ID), 1, "the original is untouched")
+
+	doc.Availability = append(doc.Availability, doc.Availability[0])
+	_, err = scheduler.ImportEvent(doc, "acme")
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	doc.FormatVersion = 99
+	_, err = scheduler.ImportEvent(doc, "acme")
+	assert.ErrorAs(t, err, &validationErr)
+}
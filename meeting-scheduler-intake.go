@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IntakeResponse is one participant's answers to an event's questions
+type IntakeResponse struct {
+	EventID   string            `json:"eventId"`
+	UserID    string            `json:"userId"`
+	Answers   map[string]string `json:"answers"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+type IntakeAnswersRequest struct {
+	Answers map[string]string `json:"answers" binding:"required"`
+}
+
+// ParticipantResponses is what the organizer dashboard shows for one
+// participant: their availability and their answers
+type ParticipantResponses struct {
+	UserID       string             `json:"userId"`
+	Availability []UserAvailability `json:"availability"`
+	Answers      map[string]string  `json:"answers,omitempty"`
+}
+
+// intakeRegistry is the in-memory store of intake answers
+type intakeRegistry struct {
+	mu        sync.RWMutex
+	responses map[string]map[string]IntakeResponse // event ID -> user ID
+}
+
+func newIntakeRegistry() *intakeRegistry {
+	return &intakeRegistry{responses: make(map[string]map[string]IntakeResponse)}
+}
+
+func (r *intakeRegistry) Get(eventID, userID string) (IntakeResponse, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	response, ok := r.responses[eventID][userID]
+	return response, ok
+}
+
+func (r *intakeRegistry) Save(response IntakeResponse) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.responses[response.EventID] == nil {
+		r.responses[response.EventID] = map[string]IntakeResponse{}
+	}
+	r.responses[response.EventID][response.UserID] = response
+}
+
+// ForEvent returns an event's responses by user ID
+func (r *intakeRegistry) ForEvent(eventID string) []IntakeResponse {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	responseList := []IntakeResponse{}
+	for _, response := range r.responses[eventID] {
+		responseList = append(responseList, response)
+	}
+	sort.Slice(responseList, func(i, j int) bool { return responseList[i].UserID < responseList[j].UserID })
+	return responseList
+}
+
+// Forget drops a deleted event's answers
+func (r *intakeRegistry) Forget(eventID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.responses, eventID)
+}
+
+// Remove drops one participant's answers to an event
+func (r *intakeRegistry) Remove(eventID, userID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.responses[eventID], userID)
+}
+
+var intakeAnswers = newIntakeRegistry()
+
+// recordAnswers merges a participant's answers into those they gave before
+// and checks the result, so required questions must be answered by the
+// time they first respond but can be revised one at a time afterwards
+func (s *Scheduler) recordAnswers(event Event, userID string, answers map[string]string) error {
+	if len(event.Questions) == 0 && len(answers) == 0 {
+		return nil
+	}
+	merged := map[string]string{}
+	if previous, ok := intakeAnswers.Get(event.ID, userID); ok {
+		for id, answer := range previous.Answers {
+			merged[id] = answer
+		}
+	}
+	for id, answer := range answers {
+		merged[id] = answer
+	}
+	if err := checkAnswers(event.Questions, merged); err != nil {
+		return err
+	}
+	if s.dryRun {
+		return nil
+	}
+	intakeAnswers.Save(IntakeResponse{EventID: event.ID, UserID: userID, Answers: merged, UpdatedAt: s.clock.Now()})
+	return nil
+}
+
+// AnswerQuestions records a participant's answers on their own
+func (s *Scheduler) AnswerQuestions(eventID, userID string, answers map[string]string) (IntakeResponse, error) {
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return IntakeResponse{}, err
+	}
+	if err := s.recordAnswers(event, userID, answers); err != nil {
+		return IntakeResponse{}, err
+	}
+	response, _ := intakeAnswers.Get(eventID, userID)
+	return response, nil
+}
+
+// Responses gathers every participant's availability and answers, ordered
+// by user ID
+func (s *Scheduler) Responses(eventID string) ([]ParticipantResponses, error) {
+	if _, err := s.GetEvent(eventID); err != nil {
+		return nil, err
+	}
+	availabilityList, err := s.store.ListAvailability(eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	byUser := map[string]*ParticipantResponses{}
+	participant := func(userID string) *ParticipantResponses {
+		if byUser[userID] == nil {
+			byUser[userID] = &ParticipantResponses{UserID: userID, Availability: []UserAvailability{}}
+		}
+		return byUser[userID]
+	}
+	for _, avail := range availabilityList {
+		p := participant(avail.UserID)
+		p.Availability = append(p.Availability, avail)
+	}
+	for _, response := range intakeAnswers.ForEvent(eventID) {
+		participant(response.UserID).Answers = response.Answers
+	}
+
+	responses := make([]ParticipantResponses, 0, len(byUser))
+	for _, p := range byUser {
+		responses = append(responses, *p)
+	}
+	sort.Slice(responses, func(i, j int) bool { return responses[i].UserID < responses[j].UserID })
+	return responses, nil
+}
+
+func answerQuestions(c *gin.Context) {
+	var req IntakeAnswersRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	response, err := contextScheduler(c).AnswerQuestions(c.Param("eventId"), c.Param("userId"), req.Answers)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func listResponses(c *gin.Context) {
+	responses, err := contextScheduler(c).Responses(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+// exportAvailability writes a CSV with a row per participant: their status
+// for each time slot, in start order, then their answer to each question
+func exportAvailability(c *gin.Context) {
+	scheduler := contextScheduler(c)
+	event, err := scheduler.GetEvent(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	slots, err := scheduler.ListTimeSlots(event.ID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	responses, err := scheduler.Responses(event.ID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].StartTime.Before(slots[j].StartTime) })
+	// Participants other than the organizer see each other masked
+	redact := !seesPersonalData(c, event)
+	var r redactor
+	if redact {
+		r = orgRedactor(event.OrgID)
+	}
+	textQuestions := map[string]bool{}
+	for _, q := range event.Questions {
+		textQuestions[q.ID] = q.Kind == QuestionText
+	}
+
+	header := []string{"user_id"}
+	for _, slot := range slots {
+		header = append(header, slot.StartTime.UTC().Format(time.RFC3339))
+	}
+	for _, q := range event.Questions {
+		header = append(header, csvSafe(q.Prompt))
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="availability-`+event.ID+`.csv"`)
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write(header)
+	for _, p := range responses {
+		status := map[string]string{}
+		for _, avail := range p.Availability {
+			status[avail.TimeSlotID] = avail.Status
+		}
+		userID, answers := p.UserID, p.Answers
+		if redact {
+			userID, answers = r.String(userID), redactAnswers(r, answers, textQuestions)
+		}
+		row := []string{csvSafe(userID)}
+		for _, slot := range slots {
+			row = append(row, status[slot.ID])
+		}
+		for _, q := range event.Questions {
+			row = append(row, csvSafe(answers[q.ID]))
+		}
+		_ = w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		c.Error(err)
+	}
+}
+
+// csvSafe keeps spreadsheet apps from evaluating a participant's text as a
+// formula
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetIntake(t *testing.T) {
+	previous := intakeAnswers
+	intakeAnswers = newIntakeRegistry()
+	t.Cleanup(func() { intakeAnswers = previous })
+}
+
+func TestIntakeQuestionsAnsweredWithAvailability(t *testing.T) {
+	resetIntake(t)
+	resetDirectory(t)
+	users.Save(User{ID: "ada", Role: RoleOrganizer})
+	router := newTestRouter(t)
+	scheduler := currentScheduler()
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{
+		Title:            "Offsite",
+		OrganizerID:      "ada",
+		RequiredDuration: 60,
+		Questions: []Question{
+			{ID: "diet", Prompt: "Dietary needs", Kind: QuestionText},
+			{ID: "travel", Prompt: "Travelling by", Kind: QuestionChoice, Choices: []string{"train", "car"}, Required: true},
+		},
+	})
+	require.NoError(t, err)
+	start := time.Date(2025, 2, 3, 9, 0, 0, 0, time.UTC)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+
+	path := "/api/v1/events/" + event.ID + "/users/bob/availability"
+	w := doJSON(router, "POST", path, UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	assert.Equal(t, http.StatusBadRequest, w.Code, "the required question is unanswered")
+
+	w = doJSON(router, "POST", path, UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available", Answers: map[string]string{"travel": "train"}})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	// Later answers are merged into earlier ones
+	w = doJSON(router, "PUT", "/api/v1/events/"+event.ID+"/users/bob/answers", IntakeAnswersRequest{Answers: map[string]string{"diet": "=vegan"}})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var responses []ParticipantResponses
+	w = doJSON(router, "GET", "/api/v1/events/"+event.ID+"/responses", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeJSON(t, w, &responses)
+	require.Len(t, responses, 1)
+	assert.Equal(t, map[string]string{"travel": "train", "diet": "=vegan"}, responses[0].Answers)
+	assert.Len(t, responses[0].Availability, 1)
+
+	// The organizer's export is complete; anyone else's masks text answers
+	token, err := issueSessionToken(User{ID: "ada", Role: RoleOrganizer})
+	require.NoError(t, err)
+	req, _ := http.NewRequest("GET", "/api/v1/events/"+event.ID+"/availability/export", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "user_id,2025-02-03T09:00:00Z,Dietary needs,Travelling by\nbob,available,'=vegan,train\n", w.Body.String())
+
+	w = doJSON(router, "GET", "/api/v1/events/"+event.ID+"/availability/export", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "user_id,2025-02-03T09:00:00Z,Dietary needs,Travelling by\nbob,available,[redacted],train\n", w.Body.String())
+
+	require.NoError(t, scheduler.DeleteEvent(event.ID))
+	assert.Empty(t, intakeAnswers.ForEvent(event.ID))
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxIntegrationBodyBytes bounds a single availability push
+	maxIntegrationBodyBytes = 1 << 20
+	// integrationSignatureTolerance is how far a push's signed timestamp may
+	// be from our clock, limiting replays of captured requests
+	integrationSignatureTolerance = 5 * time.Minute
+
+	DeclaredBusy = "busy"
+	DeclaredFree = "free"
+)
+
+// DeclaredInterval is busy or free time for a user pushed by an external
+// system, such as an HR leave calendar or a shift planner
+type DeclaredInterval struct {
+	OrgID  string    `json:"orgId"`
+	UserID string    `json:"userId"`
+	Source string    `json:"source"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Status string    `json:"status"`
+}
+
+// PushAvailabilityRequest is a source's complete picture of its
+// organization between From and To: it replaces everything the source
+// pushed before for that window. Users are identified by ID or by email.
+type PushAvailabilityRequest struct {
+	Source    string                  `json:"source"`
+	From      time.Time               `json:"from"`
+	To        time.Time               `json:"to"`
+	Intervals []PushedIntervalRequest `json:"intervals"`
+}
+
+type PushedIntervalRequest struct {
+	UserID string    `json:"userId"`
+	Email  string    `json:"email"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Status string    `json:"status"`
+}
+
+type PushAvailabilityResponse struct {
+	Accepted     int      `json:"accepted"`
+	UnknownUsers []string `json:"unknownUsers,omitempty"`
+}
+
+// declaredRegistry holds pushed intervals in memory, by user
+type declaredRegistry struct {
+	mu        sync.RWMutex
+	intervals map[string][]DeclaredInterval
+}
+
+func newDeclaredRegistry() *declaredRegistry {
+	return &declaredRegistry{intervals: make(map[string][]DeclaredInterval)}
+}
+
+// Replace drops the organization's intervals from source that overlap
+// [from, to) and stores the new ones
+func (r *declaredRegistry) Replace(orgID, source string, from, to time.Time, intervals []DeclaredInterval) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for userID, existing := range r.intervals {
+		var kept []DeclaredInterval
+		for _, interval := range existing {
+			if interval.OrgID == orgID && interval.Source == source && interval.Start.Before(to) && from.Before(interval.End) {
+				continue
+			}
+			kept = append(kept, interval)
+		}
+		r.intervals[userID] = kept
+	}
+	for _, interval := range intervals {
+		r.intervals[interval.UserID] = append(r.intervals[interval.UserID], interval)
+	}
+	for userID, userIntervals := range r.intervals {
+		sort.Slice(userIntervals, func(i, j int) bool { return userIntervals[i].Start.Before(userIntervals[j].Start) })
+		if len(userIntervals) == 0 {
+			delete(r.intervals, userID)
+		}
+	}
+}
+
+// ForUsers returns each user's intervals in start order
+func (r *declaredRegistry) ForUsers(userIDs []string) map[string][]DeclaredInterval {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	byUser := map[string][]DeclaredInterval{}
+	for _, id := range userIDs {
+		if intervals := r.intervals[id]; len(intervals) > 0 {
+			byUser[id] = append([]DeclaredInterval(nil), intervals...)
+		}
+	}
+	return byUser
+}
+
+var declaredAvailability = newDeclaredRegistry()
+
+// declaredStatus is DeclaredBusy if any busy interval overlaps [start, end),
+// DeclaredFree if free intervals cover all of it, and "" otherwise
+func declaredStatus(intervals []DeclaredInterval, start, end time.Time) string {
+	covered := start
+	for _, interval := range intervals {
+		if !interval.Start.Before(end) || !start.Before(interval.End) {
+			continue
+		}
+		if interval.Status == DeclaredBusy {
+			return DeclaredBusy
+		}
+		if !interval.Start.After(covered) && interval.End.After(covered) {
+			covered = interval.End
+		}
+	}
+	if !covered.Before(end) {
+		return DeclaredFree
+	}
+	return ""
+}
+
+// applyDeclared folds declared availability into a recommendation. Busy
+// time overrides an "available" response, while free time, pushed or from
+// a shift pattern, counts for participants who haven't answered this slot.
+func (r slotRules) applyDeclared(rec *Recommendation, answered map[string]bool, start, end time.Time) {
+	var busy []string
+	for _, id := range rec.AvailableUsers {
+		if declaredStatus(r.declared[id], start, end) == DeclaredBusy {
+			busy = append(busy, id)
+		}
+	}
+
+	var unavailable []string
+	for _, id := range rec.UnavailableUsers {
+		if !answered[id] && r.declaredFree(id, start, end) {
+			rec.AvailableUsers = append(rec.AvailableUsers, id)
+		} else {
+			unavailable = append(unavailable, id)
+		}
+	}
+	rec.UnavailableUsers = unavailable
+	markUnavailable(rec, busy)
+}
+
+// declaredFree reports whether pushed intervals or the user's shift pattern
+// say they are free for all of [start, end)
+func (r slotRules) declaredFree(userID string, start, end time.Time) bool {
+	switch declaredStatus(r.declared[userID], start, end) {
+	case DeclaredFree:
+		return true
+	case DeclaredBusy:
+		return false
+	}
+	shift, ok := r.shifts[userID]
+	return ok && shift.Covers(start, end)
+}
+
+// pushAvailability is the inbound webhook for external availability
+// sources. Requests carry X-Scheduler-Org and an X-Scheduler-Signature made
+// with the organization's integration secret, in the same format as our
+// outgoing webhooks.
+func pushAvailability(c *gin.Context) {
+	org, ok := organizations.Get(c.GetHeader("X-Scheduler-Org"))
+	if !ok || org.IntegrationSecret == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unknown organization or integrations disabled"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxIntegrationBodyBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(body) > maxIntegrationBodyBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+		return
+	}
+	if !verifyWebhookSignature(c.GetHeader(webhookSignatureHeader), org.IntegrationSecret, body, clock.Now(), integrationSignatureTolerance) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		return
+	}
+
+	// The signature covers the raw body; decoding reads it again
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	var req PushAvailabilityRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Source == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Source is required"})
+		return
+	}
+	if !req.To.After(req.From) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "To must be after from"})
+		return
+	}
+
+	var intervals []DeclaredInterval
+	var unknown []string
+	for _, pushed := range req.Intervals {
+		if pushed.Status != DeclaredBusy && pushed.Status != DeclaredFree {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be busy or free"})
+			return
+		}
+		if !pushed.End.After(pushed.Start) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Interval end must be after start"})
+			return
+		}
+
+		user, ok := users.Get(pushed.UserID)
+		if pushed.UserID == "" {
+			user, ok = users.FindByEmail(org.ID, pushed.Email)
+		}
+		if !ok || user.OrgID != org.ID {
+			unknown = append(unknown, pushed.UserID+pushed.Email)
+			continue
+		}
+
+		// Clip to the snapshot window so a push never touches time outside it
+		start, end := pushed.Start, pushed.End
+		if start.Before(req.From) {
+			start = req.From
+		}
+		if end.After(req.To) {
+			end = req.To
+		}
+		if !end.After(start) {
+			continue
+		}
+		intervals = append(intervals, DeclaredInterval{
+			OrgID: org.ID, UserID: user.ID, Source: req.Source, Start: start.UTC(), End: end.UTC(), Status: pushed.Status,
+		})
+	}
+
+	declaredAvailability.Replace(org.ID, req.Source, req.From, req.To, intervals)
+	c.JSON(http.StatusOK, PushAvailabilityResponse{Accepted: len(intervals), UnknownUsers: unknown})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetDeclared(t *testing.T) {
+	previous := declaredAvailability
+	declaredAvailability = newDeclaredRegistry()
+	t.Cleanup(func() { declaredAvailability = previous })
+}
+
+func pushRequest(router http.Handler, orgID, secret string, signedAt time.Time, req PushAvailabilityRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(req)
+	httpReq, _ := http.NewRequest("POST", "/api/v1/integrations/availability", strings.NewReader(string(body)))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Scheduler-Org", orgID)
+	httpReq.Header.Set(webhookSignatureHeader, signWebhook([]string{secret}, signedAt, body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httpReq)
+	return w
+}
+
+func TestPushedAvailabilityMergesIntoRecommendations(t *testing.T) {
+	resetDirectory(t)
+	resetDeclared(t)
+	router := newTestRouter(t)
+	now := clock.Now()
+	organizations.Save(Organization{ID: "acme", IntegrationSecret: "hr-secret"})
+	users.Save(User{ID: "ada", OrgID: "acme", Email: "ada@acme.test"})
+	users.Save(User{ID: "bob", OrgID: "acme", Email: "bob@acme.test"})
+	users.Save(User{ID: "eve", OrgID: "evil", Email: "eve@evil.test"})
+
+	day := now.Truncate(24 * time.Hour).Add(48 * time.Hour)
+	scheduler := currentScheduler()
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 60})
+	require.NoError(t, err)
+	morning, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: day.Add(9 * time.Hour), EndTime: day.Add(10 * time.Hour)})
+	require.NoError(t, err)
+	afternoon, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: day.Add(14 * time.Hour), EndTime: day.Add(15 * time.Hour)})
+	require.NoError(t, err)
+	for _, userID := range []string{"ada", "bob"} {
+		_, err = scheduler.SubmitAvailability(event.ID, userID, UserAvailabilityRequest{TimeSlotID: morning.ID, Status: "available"})
+		require.NoError(t, err)
+	}
+
+	push := PushAvailabilityRequest{
+		Source: "hr",
+		From:   day,
+		To:     day.Add(24 * time.Hour),
+		Intervals: []PushedIntervalRequest{
+			{Email: "bob@acme.test", Start: day.Add(8 * time.Hour), End: day.Add(12 * time.Hour), Status: DeclaredBusy},
+			{UserID: "ada", Start: day.Add(13 * time.Hour), End: day.Add(17 * time.Hour), Status: DeclaredFree},
+			{UserID: "eve", Start: day, End: day.Add(time.Hour), Status: DeclaredBusy},
+		},
+	}
+	assert.Equal(t, http.StatusUnauthorized, pushRequest(router, "acme", "wrong", now, push).Code)
+	assert.Equal(t, http.StatusUnauthorized, pushRequest(router, "acme", "hr-secret", now.Add(-time.Hour), push).Code)
+	w := pushRequest(router, "acme", "hr-secret", now, push)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp PushAvailabilityResponse
+	decodeJSON(t, w, &resp)
+	assert.Equal(t, 2, resp.Accepted)
+	assert.Equal(t, []string{"eve"}, resp.UnknownUsers)
+
+	recommendations, err := scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	require.Len(t, recommendations, 2)
+	bySlot := map[string]Recommendation{}
+	for _, rec := range recommendations {
+		bySlot[rec.TimeSlot.ID] = rec
+	}
+	// Bob's pushed leave overrides his response; Ada's declared free time
+	// stands in for the slot she didn't answer
+	assert.Equal(t, []string{"ada"}, bySlot[morning.ID].AvailableUsers)
+	assert.Equal(t, []string{"ada"}, bySlot[afternoon.ID].AvailableUsers)
+
+	// A later push from the same source replaces the earlier one
+	push.Intervals = nil
+	require.Equal(t, http.StatusOK, pushRequest(router, "acme", "hr-secret", now, push).Code)
+	recommendations, err = scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, morning.ID, recommendations[0].TimeSlot.ID)
+	assert.Equal(t, 100.0, recommendations[0].AvailabilityPercentage)
+}
+
+func TestDeclaredStatus(t *testing.T) {
+	base := time.Date(2025, 1, 13, 9, 0, 0, 0, time.UTC)
+	intervals := []DeclaredInterval{
+		{Start: base, End: base.Add(time.Hour), Status: DeclaredFree},
+		{Start: base.Add(time.Hour), End: base.Add(2 * time.Hour), Status: DeclaredFree},
+		{Start: base.Add(3 * time.Hour), End: base.Add(4 * time.Hour), Status: DeclaredBusy},
+	}
+	assert.Equal(t, DeclaredFree, declaredStatus(intervals, base.Add(30*time.Minute), base.Add(90*time.Minute)))
+	assert.Equal(t, "", declaredStatus(intervals, base.Add(90*time.Minute), base.Add(150*time.Minute)))
+	assert.Equal(t, DeclaredBusy, declaredStatus(intervals, base.Add(150*time.Minute), base.Add(210*time.Minute)))
+}
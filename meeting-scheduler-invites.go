@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// publicURL is where links in notifications point. PUBLIC_URL sets it.
+func publicURL() string {
+	return strings.TrimSuffix(getenv("PUBLIC_URL", "http://localhost:8080"), "/")
+}
+
+// guestInviteExpiry is how long a guest's link works.
+// GUEST_INVITE_EXPIRY_DAYS sets it.
+func guestInviteExpiry() time.Duration {
+	return time.Duration(getenvInt("GUEST_INVITE_EXPIRY_DAYS", 14)) * 24 * time.Hour
+}
+
+// GuestInvite is a guest's link to one event. Until it expires, the token
+// signs them in as their guest account, unless they have since signed in
+// through SSO.
+type GuestInvite struct {
+	Token     string    `json:"-"`
+	EventID   string    `json:"eventId"`
+	UserID    string    `json:"userId"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// URL is the link sent to the guest
+func (i GuestInvite) URL() string {
+	return publicURL() + "/api/v1/invites/" + i.Token
+}
+
+// guestInviteRegistry holds guests' invite links by token
+type guestInviteRegistry struct {
+	mu      sync.RWMutex
+	invites map[string]GuestInvite
+}
+
+func newGuestInviteRegistry() *guestInviteRegistry {
+	return &guestInviteRegistry{invites: make(map[string]GuestInvite)}
+}
+
+// Issue returns the guest's link to the event, creating a new one the
+// first time and once the old one has expired
+func (r *guestInviteRegistry) Issue(eventID, userID string, now time.Time) GuestInvite {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for token, invite := range r.invites {
+		if invite.EventID == eventID && invite.UserID == userID {
+			if now.Before(invite.ExpiresAt) {
+				return invite
+			}
+			delete(r.invites, token)
+		}
+	}
+	invite := GuestInvite{Token: randomToken(), EventID: eventID, UserID: userID, CreatedAt: now, ExpiresAt: now.Add(guestInviteExpiry())}
+	r.invites[invite.Token] = invite
+	return invite
+}
+
+func (r *guestInviteRegistry) Get(token string) (GuestInvite, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	invite, ok := r.invites[token]
+	return invite, ok
+}
+
+// LastIssued is when the user's most recent link was created
+func (r *guestInviteRegistry) LastIssued(userID string) time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var last time.Time
+	for _, invite := range r.invites {
+		if invite.UserID == userID && invite.CreatedAt.After(last) {
+			last = invite.CreatedAt
+		}
+	}
+	return last
+}
+
+// Forget drops a purged guest's links
+func (r *guestInviteRegistry) Forget(userID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for token, invite := range r.invites {
+		if invite.UserID == userID {
+			delete(r.invites, token)
+		}
+	}
+}
+
+func (r *guestInviteRegistry) purgeExpired(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for token, invite := range r.invites {
+		if !now.Before(invite.ExpiresAt) {
+			delete(r.invites, token)
+		}
+	}
+}
+
+var guestInvites = newGuestInviteRegistry()
+
+// withInviteEmails adds the people invited by email to the invitees. Known
+// addresses in the organizer's organization resolve to their accounts; the
+// rest get guest accounts, returned for saving once the event is.
+func (s *Scheduler) withInviteEmails(req CreateEventRequest) (CreateEventRequest, []User, error) {
+	if len(req.InviteEmails) == 0 {
+		return req, nil, nil
+	}
+	organizer, _ := users.Get(req.OrganizerID)
+	invitees := append([]string{}, req.Invitees...)
+	seen := map[string]bool{}
+	for _, id := range invitees {
+		seen[id] = true
+	}
+	var guests []User
+	pending := map[string]string{} // lowercased address -> new guest ID
+	now := s.clock.Now()
+	for _, raw := range req.InviteEmails {
+		address, err := mail.ParseAddress(raw)
+		if err != nil {
+			return req, nil, invalid(fmt.Sprintf("Invalid email address %q", raw))
+		}
+		key := strings.ToLower(address.Address)
+		id, ok := pending[key]
+		if !ok {
+			if user, found := users.FindByEmail(organizer.OrgID, address.Address); found {
+				id = user.ID
+			} else {
+				guest := User{
+					ID:        uuid.New().String(),
+					Name:      address.Name,
+					Email:     address.Address,
+					OrgID:     organizer.OrgID,
+					Role:      RoleGuest,
+					CreatedAt: now,
+					UpdatedAt: now,
+				}
+				guests = append(guests, guest)
+				id = guest.ID
+			}
+			pending[key] = id
+		}
+		if !seen[id] {
+			seen[id] = true
+			invitees = append(invitees, id)
+		}
+	}
+	req.Invitees = invitees
+	req.InviteEmails = nil
+	return req, guests, nil
+}
+
+// saveGuests stores guest accounts created for an event
+func (s *Scheduler) saveGuests(guests []User) {
+	if s.dryRun {
+		return
+	}
+	for _, guest := range guests {
+		users.Save(guest)
+	}
+}
+
+// GuestInviteLink is a guest invitee's link, for organizers to share
+// themselves
+type GuestInviteLink struct {
+	UserID string `json:"userId"`
+	Email  string `json:"email"`
+	URL    string `json:"url"`
+}
+
+// listGuestInvites gives the organizer the links of the event's guests
+func listGuestInvites(c *gin.Context) {
+	user, _ := currentUser(c)
+	event, err := contextScheduler(c).GetEvent(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if user.ID != event.OrganizerID && !roleAtLeast(user.Role, RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the organizer can see guest invitations"})
+		return
+	}
+	links := []GuestInviteLink{}
+	for _, id := range event.Invitees {
+		guest, ok := users.Get(id)
+		if !ok || guest.Role != RoleGuest {
+			continue
+		}
+		invite := guestInvites.Issue(event.ID, guest.ID, clock.Now())
+		links = append(links, GuestInviteLink{UserID: guest.ID, Email: guest.Email, URL: invite.URL()})
+	}
+	c.JSON(http.StatusOK, links)
+}
+
+// redeemGuestInvite signs a guest in from their link; the token is the
+// only credential. Once the guest has signed in through SSO their account
+// is a full one, and the link no longer works.
+func redeemGuestInvite(c *gin.Context) {
+	invite, ok := guestInvites.Get(c.Param("token"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+	if !clock.Now().Before(invite.ExpiresAt) {
+		c.JSON(http.StatusGone, gin.H{"error": "Invitation has expired; ask the organizer for a new link"})
+		return
+	}
+	guest, ok := users.Get(invite.UserID)
+	if !ok || guest.Deactivated {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+	if guest.Role != RoleGuest {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This account signs in through single sign-on"})
+		return
+	}
+	event, err := contextScheduler(c).GetEvent(invite.EventID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	tokens, err := startSession(c, guest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"token":        tokens.Token,
+		"refreshToken": tokens.RefreshToken,
+		"user":         guest,
+		"event":        event,
+		// The guest's client asks for consent to this version before
+		// letting them respond
+		"consentRequired": needsConsent(guest),
+		"policyVersion":   privacyPolicyVersion(),
+	})
+}
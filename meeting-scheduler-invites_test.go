@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInviteByEmailCreatesGuests(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	t.Setenv("PUBLIC_URL", "https://meet.acme.test/")
+	recorder := &recordingNotifier{}
+	previous, previousInvites := notifier, guestInvites
+	notifier, guestInvites = recorder, newGuestInviteRegistry()
+	t.Cleanup(func() { notifier, guestInvites = previous, previousInvites })
+	scheduler, _ := newTestScheduler(t)
+	scheduler.bus.Subscribe(EventCreated, notifyPollOpened)
+
+	org := Organization{ID: "acme", SSO: &SSOConfig{}}
+	organizations.Save(org)
+	ada := User{ID: "ada", OrgID: "acme", Role: RoleOrganizer}
+	users.Save(ada)
+	users.Save(User{ID: "bob", Email: "bob@acme.test", OrgID: "acme", Role: RoleMember})
+
+	_, err := scheduler.DryRun().CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30, InviteEmails: []string{"zoe@example.com"}})
+	require.NoError(t, err)
+	_, found := users.FindByEmail("acme", "zoe@example.com")
+	assert.False(t, found, "dry runs don't create guests")
+	_, err = scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30, InviteEmails: []string{"not an address"}})
+	var validation *ValidationError
+	assert.ErrorAs(t, err, &validation)
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{
+		Title:            "Kickoff",
+		OrganizerID:      "ada",
+		RequiredDuration: 30,
+		Invitees:         []string{"bob"},
+		InviteEmails:     []string{"Zoe Z <zoe@example.com>", "BOB@acme.test", "ZOE@example.com"},
+	})
+	require.NoError(t, err)
+	zoe, found := users.FindByEmail("acme", "zoe@example.com")
+	require.True(t, found)
+	assert.Equal(t, RoleGuest, zoe.Role)
+	assert.Equal(t, "Zoe Z", zoe.Name)
+	assert.Equal(t, []string{"bob", zoe.ID}, event.Invitees, "known addresses resolve and repeats collapse")
+
+	require.Len(t, recorder.messages, 2)
+	var link string
+	for _, msg := range recorder.messages {
+		if msg.To.ID == zoe.ID {
+			_, link, _ = strings.Cut(msg.Body, "Respond here: ")
+		} else {
+			assert.NotContains(t, msg.Body, "Respond here", "members sign in as usual")
+		}
+	}
+	require.True(t, strings.HasPrefix(link, "https://meet.acme.test/api/v1/invites/"), link)
+	path := strings.TrimPrefix(link, "https://meet.acme.test")
+
+	token, err := issueSessionToken(ada)
+	require.NoError(t, err)
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/events/"+event.ID+"/invites", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var links []GuestInviteLink
+	decodeJSON(t, w, &links)
+	assert.Equal(t, []GuestInviteLink{{UserID: zoe.ID, Email: "zoe@example.com", URL: link}}, links)
+
+	w = doJSON(router, http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var redeemed struct {
+		Token string `json:"token"`
+		User  User   `json:"user"`
+		Event Event  `json:"event"`
+	}
+	decodeJSON(t, w, &redeemed)
+	assert.Equal(t, zoe.ID, redeemed.User.ID)
+	assert.Equal(t, event.ID, redeemed.Event.ID)
+	claims, err := parseSessionToken(redeemed.Token)
+	require.NoError(t, err)
+	assert.Equal(t, RoleGuest, claims.Role)
+	assert.Equal(t, http.StatusNotFound, doJSON(router, http.MethodGet, "/api/v1/invites/nope", nil).Code)
+
+	converted := provisionSSOUser(org, "idp-zoe", map[string]interface{}{"email": "zoe@example.com", "name": "Zoe Zhang"})
+	assert.Equal(t, zoe.ID, converted.ID, "signing in through SSO converts the guest")
+	assert.Equal(t, RoleMember, converted.Role)
+	assert.Equal(t, http.StatusForbidden, doJSON(router, http.MethodGet, path, nil).Code)
+}
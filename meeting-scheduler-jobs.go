@@ -0,0 +1,115 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Job is a unit of background work run periodically by the JobScheduler
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(now time.Time)
+
+	nextRun time.Time
+}
+
+// JobScheduler runs registered jobs on their interval. All timing goes
+// through the injected Clock, so tests can advance a fake clock and call
+// RunDue instead of sleeping.
+type JobScheduler struct {
+	clock Clock
+	tick  time.Duration
+
+	mu   sync.Mutex
+	jobs []*Job
+}
+
+func newJobScheduler(c Clock, tick time.Duration) *JobScheduler {
+	return &JobScheduler{clock: c, tick: tick}
+}
+
+// Register adds a job whose first run is one interval from now
+func (s *JobScheduler) Register(name string, interval time.Duration, run func(now time.Time)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs = append(s.jobs, &Job{
+		Name:     name,
+		Interval: interval,
+		Run:      run,
+		nextRun:  s.clock.Now().Add(interval),
+	})
+}
+
+// RunDue runs every job whose next run time has been reached and returns the
+// names of the jobs that ran. Jobs wait out maintenance mode, then run.
+func (s *JobScheduler) RunDue() []string {
+	if maintenance.ReadOnly() {
+		return nil
+	}
+	now := s.clock.Now()
+
+	s.mu.Lock()
+	var due []*Job
+	for _, job := range s.jobs {
+		if !now.Before(job.nextRun) {
+			due = append(due, job)
+			job.nextRun = now.Add(job.Interval)
+		}
+	}
+	s.mu.Unlock()
+
+	var ran []string
+	for _, job := range due {
+		s.runJob(job, now)
+		ran = append(ran, job.Name)
+	}
+	return ran
+}
+
+func (s *JobScheduler) runJob(job *Job, now time.Time) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %s panicked: %v", job.Name, r)
+		}
+	}()
+	job.Run(now)
+}
+
+// Start polls for due jobs every tick until stop is closed
+func (s *JobScheduler) Start(stop <-chan struct{}) {
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-s.clock.After(s.tick):
+				s.RunDue()
+			}
+		}
+	}()
+}
+
+// jobs is the process-wide scheduler started from main
+var jobs = newJobScheduler(clock, time.Minute)
+
+// registerJobs schedules the built-in background jobs
+func registerJobs(s *JobScheduler) {
+	s.Register("sso-login-cleanup", ssoLoginTTL, ssoLogins.purgeExpired)
+	s.Register("deprovision-cleanup", 5*time.Minute, cleanupDeprovisionedUsers)
+	s.Register("encryption-key-rotation", time.Hour, rotateEncryptionKeys)
+	s.Register("secret-refresh", getenvDuration("SECRETS_REFRESH_INTERVAL", 5*time.Minute), refreshSecrets)
+	s.Register("quorum-alerts", time.Minute, evaluateQuorumAlerts)
+	s.Register("meeting-reminders", time.Minute, sendMeetingReminders)
+	s.Register("response-digests", 5*time.Minute, sendResponseDigests)
+	s.Register("deferred-notifications", time.Minute, deliverDeferredNotifications)
+	s.Register("presence-expiry", time.Minute, expirePresence)
+	s.Register("trash-purge", time.Hour, purgeTrash)
+	s.Register("guest-cleanup", time.Hour, purgeStaleGuests)
+	s.Register("availability-archive", time.Hour, archiveAvailability)
+	s.Register("throttle-prune", time.Hour, pruneThrottles)
+	s.Register("session-cleanup", time.Hour, pruneSessions)
+	s.Register("impersonation-log-cleanup", time.Hour, pruneImpersonations)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrLegalHold is returned when a legal hold blocks deleting an event or
+// any of its slots or responses
+var ErrLegalHold = errors.New("event is under legal hold")
+
+// LegalHold keeps an event, with its slots and responses, from being
+// deleted or purged until an admin releases it
+type LegalHold struct {
+	Reason   string    `json:"reason"`
+	PlacedBy string    `json:"placedBy"`
+	PlacedAt time.Time `json:"placedAt"`
+}
+
+type LegalHoldRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// HoldAccess records one request touching an event under legal hold
+type HoldAccess struct {
+	EventID string `json:"eventId"`
+	UserID  string `json:"userId"`
+	// ImpersonatorID is set when an operator was impersonating UserID
+	ImpersonatorID string    `json:"impersonatorId,omitempty"`
+	ClientIP       string    `json:"clientIp"`
+	Method         string    `json:"method"`
+	Path           string    `json:"path"`
+	Status         int       `json:"status"`
+	At             time.Time `json:"at"`
+}
+
+// holdAccessRegistry is the in-memory access log of held events, oldest
+// first per event. Unlike the activity timeline it is never trimmed.
+type holdAccessRegistry struct {
+	mu      sync.RWMutex
+	entries map[string][]HoldAccess
+}
+
+func newHoldAccessRegistry() *holdAccessRegistry {
+	return &holdAccessRegistry{entries: make(map[string][]HoldAccess)}
+}
+
+func (r *holdAccessRegistry) Add(access HoldAccess) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[access.EventID] = append(r.entries[access.EventID], access)
+}
+
+func (r *holdAccessRegistry) ForEvent(eventID string) []HoldAccess {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]HoldAccess{}, r.entries[eventID]...)
+}
+
+var holdAccess = newHoldAccessRegistry()
+
+// PlaceLegalHold puts the event under hold, replacing the reason of any
+// hold already in place
+func (s *Scheduler) PlaceLegalHold(eventID, adminID, reason string) (Event, error) {
+	return s.setLegalHold(eventID, &LegalHold{Reason: reason, PlacedBy: adminID, PlacedAt: s.clock.Now()})
+}
+
+// ReleaseLegalHold lifts the event's hold, if it has one
+func (s *Scheduler) ReleaseLegalHold(eventID string) (Event, error) {
+	return s.setLegalHold(eventID, nil)
+}
+
+func (s *Scheduler) setLegalHold(eventID string, hold *LegalHold) (Event, error) {
+	var event Event
+	err := s.store.WithTransaction(func(tx Store) error {
+		var err error
+		if event, err = tx.GetEvent(eventID); err != nil {
+			return err
+		}
+		event.LegalHold = hold
+		event.UpdatedAt = s.clock.Now()
+		return tx.UpdateEvent(event)
+	})
+	if err != nil {
+		return Event{}, notFound(err, ErrEventNotFound)
+	}
+	return event, nil
+}
+
+// recordHeldAccess logs every request to an event's endpoints while the
+// event is under legal hold, including the ones placing and lifting it.
+// Inside an atomic batch the event is read through the batch's
+// transaction, which holds the store.
+func recordHeldAccess(c *gin.Context) {
+	c.Next()
+	eventID := c.Param("eventId")
+	if eventID == "" {
+		return
+	}
+	event, err := requestScheduler(c).store.GetEvent(eventID)
+	held := err == nil && event.LegalHold != nil
+	if !held && c.FullPath() != "/api/v1/events/:eventId/legal-hold" {
+		return
+	}
+	user, _ := currentUser(c)
+	holdAccess.Add(HoldAccess{
+		EventID:        eventID,
+		UserID:         user.ID,
+		ImpersonatorID: impersonatorFrom(c.Request.Context()),
+		ClientIP:       c.ClientIP(),
+		Method:         c.Request.Method,
+		Path:           c.Request.URL.RequestURI(),
+		Status:         c.Writer.Status(),
+		At:             clock.Now(),
+	})
+}
+
+// heldEventForAdmin loads the event, answering for the caller when it is
+// missing or belongs to another organization
+func heldEventForAdmin(c *gin.Context) (User, bool) {
+	user, _ := currentUser(c)
+	event, err := contextScheduler(c).GetEvent(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
+		return User{}, false
+	}
+	if event.OrgID != user.OrgID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this organization"})
+		return User{}, false
+	}
+	return user, true
+}
+
+// Legal hold handlers
+func placeLegalHold(c *gin.Context) {
+	var req LegalHoldRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := heldEventForAdmin(c)
+	if !ok {
+		return
+	}
+	event, err := requestScheduler(c).PlaceLegalHold(c.Param("eventId"), user.ID, req.Reason)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, event)
+}
+
+func releaseLegalHold(c *gin.Context) {
+	if _, ok := heldEventForAdmin(c); !ok {
+		return
+	}
+	event, err := requestScheduler(c).ReleaseLegalHold(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, event)
+}
+
+func listHeldAccess(c *gin.Context) {
+	if _, ok := heldEventForAdmin(c); !ok {
+		return
+	}
+	c.JSON(http.StatusOK, holdAccess.ForEvent(c.Param("eventId")))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegalHoldBlocksDeletionAndRecordsAccess(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	previous := holdAccess
+	holdAccess = newHoldAccessRegistry()
+	t.Cleanup(func() { holdAccess = previous })
+
+	admin := User{ID: "ada", OrgID: "acme", Role: RoleAdmin}
+	users.Save(admin)
+	users.Save(User{ID: "eve", OrgID: "other", Role: RoleAdmin})
+	event, err := currentScheduler().CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+
+	send := func(user User, method, path string, body string) int {
+		token, err := issueSessionToken(user)
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	holdPath := "/api/v1/events/" + event.ID + "/legal-hold"
+
+	assert.Equal(t, http.StatusForbidden, send(User{ID: "eve", OrgID: "other", Role: RoleAdmin}, http.MethodPut, holdPath, `{"reason": "Case 42"}`))
+	require.Equal(t, http.StatusOK, send(admin, http.MethodPut, holdPath, `{"reason": "Case 42"}`))
+	held, err := store.GetEvent(event.ID)
+	require.NoError(t, err)
+	require.NotNil(t, held.LegalHold)
+	assert.Equal(t, "ada", held.LegalHold.PlacedBy)
+
+	assert.Equal(t, http.StatusOK, send(admin, http.MethodGet, "/api/v1/events/"+event.ID, ""))
+	assert.Equal(t, http.StatusConflict, send(admin, http.MethodDelete, "/api/v1/events/"+event.ID, ""))
+	assert.ErrorIs(t, currentScheduler().DeleteEvent(event.ID), ErrLegalHold)
+	// Atomic batches are recorded too, read through the batch's transaction
+	assert.Equal(t, http.StatusOK, send(admin, http.MethodPost, "/api/v1/batch", `{"atomic": true, "operations": [{"method": "POST",
+		"path": "/api/v1/events/`+event.ID+`/timeslots", "body": {"startTime": "2025-02-03T09:00:00Z", "endTime": "2025-02-03T10:00:00Z"}}]}`))
+
+	require.Equal(t, http.StatusOK, send(admin, http.MethodDelete, holdPath, ""))
+	assert.Equal(t, http.StatusOK, send(admin, http.MethodGet, "/api/v1/events/"+event.ID, ""))
+
+	var methods []string
+	for _, access := range holdAccess.ForEvent(event.ID) {
+		methods = append(methods, access.Method)
+	}
+	assert.Equal(t, []string{http.MethodPut, http.MethodPut, http.MethodGet, http.MethodDelete, http.MethodPost, http.MethodDelete}, methods,
+		"the refused and successful placements, the read, the refused deletion, the batch and the release, but not the read after")
+
+	assert.Equal(t, http.StatusNoContent, send(admin, http.MethodDelete, "/api/v1/events/"+event.ID, ""))
+}
+
+func TestLegalHoldBlocksSlotAndResponseDeletion(t *testing.T) {
+	scheduler, fake := newTestScheduler(t)
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: fake.Now().Add(time.Hour), EndTime: fake.Now().Add(2 * time.Hour)})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+	_, err = scheduler.PlaceLegalHold(event.ID, "ada", "Case 42")
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, scheduler.DeleteAvailability(event.ID, "bob", slot.ID), ErrLegalHold)
+	assert.ErrorIs(t, scheduler.DeleteTimeSlot(slot.ID), ErrLegalHold)
+	_, err = scheduler.GetAvailability(event.ID, "bob", slot.ID)
+	assert.NoError(t, err, "the response is still there")
+	_, err = scheduler.GetTimeSlot(slot.ID)
+	assert.NoError(t, err, "and so is its slot")
+
+	_, err = scheduler.ReleaseLegalHold(event.ID)
+	require.NoError(t, err)
+	assert.NoError(t, scheduler.DeleteAvailability(event.ID, "bob", slot.ID))
+	assert.NoError(t, scheduler.DeleteTimeSlot(slot.ID))
+}
+
+func TestGuestPurgeSparesHeldEvents(t *testing.T) {
+	resetDirectory(t)
+	scheduler, fake := newTestScheduler(t)
+	previousInvites := guestInvites
+	guestInvites = newGuestInviteRegistry()
+	t.Cleanup(func() { guestInvites = previousInvites })
+	users.Save(User{ID: "ada", OrgID: "acme", Role: RoleOrganizer})
+	users.Save(User{ID: "guest-1", OrgID: "acme", Role: RoleGuest, UpdatedAt: fake.Now()})
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: fake.Now().Add(time.Hour), EndTime: fake.Now().Add(2 * time.Hour)})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "guest-1", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+	_, err = scheduler.CancelEvent(event.ID)
+	require.NoError(t, err)
+	_, err = scheduler.PlaceLegalHold(event.ID, "ada", "Case 42")
+	require.NoError(t, err)
+
+	later := fake.Now().Add(365 * 24 * time.Hour)
+	require.NoError(t, purgeGuests(scheduler, later))
+	_, ok := users.Get("guest-1")
+	assert.True(t, ok, "the guest's responses are evidence")
+
+	_, err = scheduler.ReleaseLegalHold(event.ID)
+	require.NoError(t, err)
+	require.NoError(t, purgeGuests(scheduler, later))
+	_, ok = users.Get("guest-1")
+	assert.False(t, ok)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loadShedder bounds how many expensive requests run at once. The limit
+// adapts to latency: each request finishing within the target raises it a
+// little (by 1/limit), each one over it cuts it by a tenth, so a slowing
+// store or CPU pulls concurrency down before requests pile up. Requests
+// over the limit wait in a short FIFO queue; when that is full, or the
+// wait runs out, they are shed with 503 so cheap endpoints keep working.
+type loadShedder struct {
+	mu           sync.Mutex
+	limit        float64
+	maxLimit     float64
+	inFlight     int
+	waiters      []chan struct{}
+	queueDepth   int
+	queueTimeout time.Duration
+	target       time.Duration
+}
+
+// minShedLimit keeps at least one request running however slow they are
+const minShedLimit = 1.0
+
+func newLoadShedder(maxConcurrency, queueDepth int, queueTimeout, target time.Duration) *loadShedder {
+	return &loadShedder{
+		limit:        float64(maxConcurrency),
+		maxLimit:     float64(maxConcurrency),
+		queueDepth:   queueDepth,
+		queueTimeout: queueTimeout,
+		target:       target,
+	}
+}
+
+// loadShedderFromEnv returns nil, shedding nothing, when
+// SHED_MAX_CONCURRENCY is 0
+func loadShedderFromEnv() *loadShedder {
+	maxConcurrency := getenvInt("SHED_MAX_CONCURRENCY", 32)
+	if maxConcurrency <= 0 {
+		return nil
+	}
+	return newLoadShedder(maxConcurrency,
+		getenvInt("SHED_QUEUE_DEPTH", 64),
+		getenvDuration("SHED_QUEUE_TIMEOUT", 2*time.Second),
+		getenvDuration("SHED_TARGET_LATENCY", time.Second))
+}
+
+// acquire admits a request, queueing it while the limit is reached. It
+// reports false when the request is shed instead.
+func (l *loadShedder) acquire(ctx context.Context) bool {
+	l.mu.Lock()
+	if l.inFlight < int(l.limit) && len(l.waiters) == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return true
+	}
+	if len(l.waiters) >= l.queueDepth {
+		l.mu.Unlock()
+		return false
+	}
+	ready := make(chan struct{})
+	l.waiters = append(l.waiters, ready)
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return true
+	case <-clock.After(l.queueTimeout):
+	case <-ctx.Done():
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, waiter := range l.waiters {
+		if waiter == ready {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return false
+		}
+	}
+	// A finishing request handed its place over as the wait ran out
+	return true
+}
+
+// release adjusts the limit by how long the request took and hands its
+// place to the longest waiter, if the limit still allows
+func (l *loadShedder) release(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if latency > l.target {
+		l.limit = max(minShedLimit, l.limit*0.9)
+	} else {
+		l.limit = min(l.maxLimit, l.limit+1/l.limit)
+	}
+	if len(l.waiters) > 0 && l.inFlight <= int(l.limit) {
+		close(l.waiters[0])
+		l.waiters = l.waiters[1:]
+		return
+	}
+	l.inFlight--
+}
+
+// shedLoad is middleware running the rest of the chain under l. A nil
+// shedder lets everything through.
+func shedLoad(l *loadShedder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l == nil {
+			c.Next()
+			return
+		}
+		if !l.acquire(c.Request.Context()) {
+			c.Header("Retry-After", strconv.Itoa(max(1, int(l.queueTimeout/time.Second))))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Too many requests for this endpoint; try again shortly"})
+			return
+		}
+		start := clock.Now()
+		defer func() { l.release(clock.Now().Sub(start)) }()
+		c.Next()
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queueAcquire starts an acquire that must queue and waits until it is
+// timing its wait. Timers left behind by admitted waiters are not counted.
+func queueAcquire(t *testing.T, l *loadShedder, fake *fakeClock) <-chan bool {
+	t.Helper()
+	fake.mu.Lock()
+	timers := len(fake.waiters)
+	fake.mu.Unlock()
+	l.mu.Lock()
+	queued := len(l.waiters)
+	l.mu.Unlock()
+
+	admitted := make(chan bool, 1)
+	go func() { admitted <- l.acquire(context.Background()) }()
+	require.Eventually(t, func() bool {
+		l.mu.Lock()
+		waiting := len(l.waiters)
+		l.mu.Unlock()
+		fake.mu.Lock()
+		timing := len(fake.waiters)
+		fake.mu.Unlock()
+		return waiting == queued+1 && timing == timers+1
+	}, time.Second, time.Millisecond)
+	return admitted
+}
+
+func TestLoadShedderQueuesThenSheds(t *testing.T) {
+	fake := useFakeClock(t, time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	l := newLoadShedder(1, 1, time.Second, time.Second)
+	ctx := context.Background()
+	require.True(t, l.acquire(ctx))
+
+	admitted := queueAcquire(t, l, fake)
+	assert.False(t, l.acquire(ctx), "the queue is full")
+
+	// Finishing hands the place to the waiter
+	l.release(0)
+	assert.True(t, <-admitted)
+
+	// A waiter whose time runs out is shed
+	admitted = queueAcquire(t, l, fake)
+	fake.Advance(time.Second)
+	assert.False(t, <-admitted)
+	assert.Empty(t, l.waiters)
+}
+
+func TestLoadShedderAdaptsToLatency(t *testing.T) {
+	l := newLoadShedder(10, 0, time.Second, 100*time.Millisecond)
+	ctx := context.Background()
+
+	for i := 0; i < 50; i++ {
+		require.True(t, l.acquire(ctx))
+		l.release(time.Second)
+	}
+	assert.Equal(t, minShedLimit, l.limit)
+	require.True(t, l.acquire(ctx))
+	assert.False(t, l.acquire(ctx), "slow requests have brought the limit down to one")
+	l.release(10 * time.Millisecond)
+
+	for i := 0; i < 200; i++ {
+		require.True(t, l.acquire(ctx))
+		l.release(10 * time.Millisecond)
+	}
+	assert.Equal(t, 10.0, l.limit, "fast requests raise it back, up to the maximum")
+}
+
+func TestShedLoadRespondsServiceUnavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	started, release := make(chan struct{}), make(chan struct{})
+	router.GET("/slow", shedLoad(newLoadShedder(1, 0, 2*time.Second, time.Second)), func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		router.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		close(done)
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "try again shortly")
+
+	close(release)
+	<-done
+	assert.Equal(t, http.StatusOK, first.Code)
+}
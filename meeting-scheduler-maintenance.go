@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultMaintenanceMessage = "The scheduler is in read-only maintenance; changes are paused. Please try again shortly."
+
+// MaintenanceStatus is whether the API is read-only, and what callers are
+// told while it is
+type MaintenanceStatus struct {
+	ReadOnly bool       `json:"readOnly"`
+	Message  string     `json:"message,omitempty"`
+	Since    *time.Time `json:"since,omitempty"`
+	// RetryAfterSeconds is sent as Retry-After on rejected changes
+	RetryAfterSeconds int `json:"retryAfterSeconds,omitempty"`
+}
+
+// maintenanceMode holds the process-wide read-only toggle
+type maintenanceMode struct {
+	mu     sync.RWMutex
+	status MaintenanceStatus
+}
+
+func (m *maintenanceMode) Status() MaintenanceStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+func (m *maintenanceMode) ReadOnly() bool {
+	return m.Status().ReadOnly
+}
+
+// Set switches read-only mode on or off. Turning it on keeps the time it
+// started if it already was.
+func (m *maintenanceMode) Set(status MaintenanceStatus, now time.Time) MaintenanceStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !status.ReadOnly {
+		m.status = MaintenanceStatus{}
+		return m.status
+	}
+	if status.Message == "" {
+		status.Message = defaultMaintenanceMessage
+	}
+	status.Since = m.status.Since
+	if status.Since == nil {
+		status.Since = &now
+	}
+	m.status = status
+	return m.status
+}
+
+// maintenanceFromEnv starts the process read-only when MAINTENANCE_MODE is
+// set, with MAINTENANCE_MESSAGE for callers
+func maintenanceFromEnv() *maintenanceMode {
+	m := &maintenanceMode{}
+	if getenvBool("MAINTENANCE_MODE", false) {
+		m.Set(MaintenanceStatus{ReadOnly: true, Message: getenv("MAINTENANCE_MESSAGE", "")}, clock.Now())
+	}
+	return m
+}
+
+var maintenance = &maintenanceMode{}
+
+// readOnlyMethod reports whether requests with this method never change
+// anything
+func readOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// enforceMaintenance rejects changes while the API is read-only. Batches
+// still run, since each of their operations passes through here. The
+// toggle itself stays reachable so maintenance can be ended, and so do
+// migrations, which are what maintenance is usually for, and promoting a
+// standby, which ends it.
+func enforceMaintenance(c *gin.Context) {
+	status := maintenance.Status()
+	if !status.ReadOnly || readOnlyMethod(c.Request.Method) {
+		c.Next()
+		return
+	}
+	switch c.FullPath() {
+	case "/api/v1/maintenance", "/api/v1/batch", "/api/v1/admin/migrations", "/api/v1/admin/replication/promote":
+		c.Next()
+		return
+	}
+	if status.RetryAfterSeconds > 0 {
+		c.Header("Retry-After", strconv.Itoa(status.RetryAfterSeconds))
+	}
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": status.Message, "maintenance": status})
+}
+
+// isOperator reports whether the user is an admin of OPERATOR_ORG, the
+// organization that runs the deployment. Without one, deployment-wide
+// settings can only be changed through the environment.
+func isOperator(user User) bool {
+	operatorOrg := getenv("OPERATOR_ORG", "")
+	return operatorOrg != "" && user.OrgID == operatorOrg && roleAtLeast(user.Role, RoleAdmin)
+}
+
+// Maintenance handlers
+func getMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, maintenance.Status())
+}
+
+func putMaintenance(c *gin.Context) {
+	var req MaintenanceStatus
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.RetryAfterSeconds < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Retry after cannot be negative"})
+		return
+	}
+	c.JSON(http.StatusOK, maintenance.Set(req, clock.Now()))
+}
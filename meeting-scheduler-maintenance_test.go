@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetMaintenance(t *testing.T) {
+	previous := maintenance
+	maintenance = &maintenanceMode{}
+	t.Cleanup(func() { maintenance = previous })
+}
+
+func TestMaintenanceModeRejectsChanges(t *testing.T) {
+	resetDirectory(t)
+	resetMaintenance(t)
+	t.Setenv("OPERATOR_ORG", "ops")
+	router := newTestRouter(t)
+	operator := User{ID: "sre", OrgID: "ops", Role: RoleAdmin}
+	admin := User{ID: "root", OrgID: "acme", Role: RoleAdmin}
+	users.Save(operator)
+	users.Save(admin)
+	operatorToken, err := issueSessionToken(operator)
+	require.NoError(t, err)
+	adminToken, err := issueSessionToken(admin)
+	require.NoError(t, err)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/api/v1/maintenance", adminToken, `{"readOnly": true}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPut, "/api/v1/maintenance", operatorToken, `{"readOnly": true, "retryAfterSeconds": 120}`).Code)
+
+	w := do(http.MethodPost, "/api/v1/events", adminToken, `{"name": "Standup"}`)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "120", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), defaultMaintenanceMessage)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/maintenance", "", "").Code)
+
+	require.Equal(t, http.StatusOK, do(http.MethodPut, "/api/v1/maintenance", operatorToken, `{"readOnly": false}`).Code)
+	assert.NotEqual(t, http.StatusServiceUnavailable, do(http.MethodPost, "/api/v1/events", adminToken, `{"name": "Standup"}`).Code)
+}
+
+func TestMaintenanceSetKeepsStartTime(t *testing.T) {
+	m := &maintenanceMode{}
+	start := clock.Now()
+	status := m.Set(MaintenanceStatus{ReadOnly: true}, start)
+	assert.Equal(t, defaultMaintenanceMessage, status.Message)
+	status = m.Set(MaintenanceStatus{ReadOnly: true, Message: "Migrating"}, start.Add(time.Minute))
+	assert.Equal(t, "Migrating", status.Message)
+	assert.Equal(t, start, *status.Since)
+	assert.Equal(t, MaintenanceStatus{}, m.Set(MaintenanceStatus{}, start))
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// MaterializedRecommendations is an event's recommendations response as
+// last computed
+type MaterializedRecommendations struct {
+	Response   RecommendationsResponse
+	ComputedAt time.Time
+
+	// What the response was computed from, so one changed response can be
+	// rescored without the rest: the scorer, the slots by ID, each slot's
+	// responses by ID, and how many responses each user has
+	scorer        slotScorer
+	slots         map[string]TimeSlot
+	responses     map[string]map[string]UserAvailability
+	userResponses map[string]int
+}
+
+// recommendationViews keeps each event's recommendations precomputed, so
+// GET /recommendations is a lookup however large the event. A changed
+// response rescores just its slot, at once. Other changes to an event's
+// slots or settings recompute all of it in the background once they have
+// settled for the debounce interval; until then the previous result is
+// served. Inputs from outside the event, such as connected calendars and
+// organization rules, are picked up when a view reaches maxAge, after
+// which the next read recomputes it.
+type recommendationViews struct {
+	mu    sync.Mutex
+	views map[string]MaterializedRecommendations
+	// pending holds the recomputation scheduled for each event; one that
+	// finds another in its place has been superseded and stops
+	pending   map[string]uint64
+	scheduled uint64
+	debounce  time.Duration
+	maxAge    time.Duration
+	// versions count changes to each event, and epoch changes that touch
+	// every event, so a recomputation that raced a change is discarded
+	versions map[string]uint64
+	epoch    uint64
+}
+
+func newRecommendationViews(debounce, maxAge time.Duration) *recommendationViews {
+	return &recommendationViews{
+		views:    map[string]MaterializedRecommendations{},
+		pending:  map[string]uint64{},
+		versions: map[string]uint64{},
+		debounce: debounce,
+		maxAge:   maxAge,
+	}
+}
+
+// recommendationViewsFromEnv returns nil, computing recommendations on
+// every read, unless MATERIALIZE_RECOMMENDATIONS is set
+func recommendationViewsFromEnv() *recommendationViews {
+	if !getenvBool("MATERIALIZE_RECOMMENDATIONS", false) {
+		return nil
+	}
+	return newRecommendationViews(
+		getenvDuration("RECOMMENDATION_DEBOUNCE", 2*time.Second),
+		getenvDuration("RECOMMENDATION_MAX_AGE", 5*time.Minute))
+}
+
+// materialized holds the precomputed recommendations, when enabled
+var materialized *recommendationViews
+
+// Observe is the bus subscriber keeping views up to date with changes
+func (v *recommendationViews) Observe(e DomainEvent) {
+	v.mu.Lock()
+	v.versions[e.EventID]++
+	switch e.Type {
+	case EventFinalized, EventCancelled, EventDeleted, EventRestored:
+		// Confirmed meetings are conflicts for other events' participants
+		v.epoch++
+		v.views = map[string]MaterializedRecommendations{}
+	}
+	if e.Type == EventDeleted {
+		delete(v.versions, e.EventID)
+		v.mu.Unlock()
+		return
+	}
+	// A recomputation under way or due will include the change anyway
+	_, pending := v.pending[e.EventID]
+	if view, ok := v.views[e.EventID]; ok && !pending && view.rescore(e) {
+		v.views[e.EventID] = view
+		v.mu.Unlock()
+		return
+	}
+	v.mu.Unlock()
+	v.schedule(e.EventID)
+}
+
+// rescore applies a changed response to the view, rescoring only its
+// slot. It reports false when the change needs a full recomputation: it
+// isn't a response, its slot is unknown, or it changes who percentages
+// are out of, which affects every slot.
+func (view *MaterializedRecommendations) rescore(e DomainEvent) bool {
+	avail, ok := e.Payload.(UserAvailability)
+	if !ok || view.slots == nil {
+		return false
+	}
+	slot, ok := view.slots[avail.TimeSlotID]
+	if !ok {
+		return false
+	}
+	_, existed := view.responses[slot.ID][avail.ID]
+	switch e.Type {
+	case AvailabilitySubmitted, AvailabilityUpdated:
+		if !view.scorer.respondents[avail.UserID] {
+			return false
+		}
+		if view.responses[slot.ID] == nil {
+			view.responses[slot.ID] = map[string]UserAvailability{}
+		}
+		if !existed {
+			view.userResponses[avail.UserID]++
+		}
+		view.responses[slot.ID][avail.ID] = avail
+	case AvailabilityDeleted:
+		if !existed {
+			return false
+		}
+		if view.userResponses[avail.UserID] == 1 && !view.scorer.invited(avail.UserID) {
+			return false
+		}
+		view.userResponses[avail.UserID]--
+		delete(view.responses[slot.ID], avail.ID)
+	default:
+		return false
+	}
+
+	slotAvailability := make([]UserAvailability, 0, len(view.responses[slot.ID]))
+	for _, response := range view.responses[slot.ID] {
+		slotAvailability = append(slotAvailability, response)
+	}
+	// A new slice, since responses already served may still be being written
+	previous := view.Response.Recommendations
+	recommendations := make([]Recommendation, 0, len(previous)+1)
+	for _, rec := range previous {
+		if rec.TimeSlot.ID != slot.ID {
+			recommendations = append(recommendations, rec)
+		}
+	}
+	if rec, ok := view.scorer.score(slot, slotAvailability); ok {
+		sortUsers(&rec)
+		recommendations = append(recommendations, rec)
+	}
+	orderRecommendations(recommendations, "")
+	view.Response = RecommendationsResponse{Recommendations: recommendations, Analysis: analyzeRecommendations(recommendations)}
+	return true
+}
+
+func (sc slotScorer) invited(userID string) bool {
+	for _, id := range sc.event.Invitees {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// schedule recomputes an event's view after the debounce interval, once
+// however many changes arrive meanwhile, and again if one arrived while
+// it was being recomputed
+func (v *recommendationViews) schedule(eventID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, ok := v.pending[eventID]; ok {
+		return
+	}
+	v.scheduled++
+	token := v.scheduled
+	v.pending[eventID] = token
+	go func() {
+		for {
+			<-clock.After(v.debounce)
+			v.mu.Lock()
+			current := v.pending[eventID] == token
+			v.mu.Unlock()
+			if !current {
+				return
+			}
+			_, stored, err := v.compute(eventID, currentScheduler())
+			if err != nil && !errors.Is(err, ErrEventNotFound) {
+				log.Printf("Recomputing recommendations for event %s: %v", eventID, err)
+			}
+			if stored {
+				return
+			}
+			if err != nil {
+				v.mu.Lock()
+				if v.pending[eventID] == token {
+					delete(v.pending, eventID)
+				}
+				v.mu.Unlock()
+				return
+			}
+		}
+	}()
+}
+
+// compute works out an event's recommendations and stores them, unless
+// the event changed while they were being computed, reporting which. A
+// stored view is up to date, so any recomputation scheduled is dropped.
+func (v *recommendationViews) compute(eventID string, scheduler *Scheduler) (MaterializedRecommendations, bool, error) {
+	v.mu.Lock()
+	version, epoch := v.versions[eventID], v.epoch
+	v.mu.Unlock()
+
+	event, err := scheduler.GetEvent(eventID)
+	if err != nil {
+		return MaterializedRecommendations{}, false, err
+	}
+	eventSlots, err := scheduler.store.ListTimeSlots(eventID)
+	if err != nil {
+		return MaterializedRecommendations{}, false, err
+	}
+	eventAvailability, err := scheduler.store.ListAvailability(eventID)
+	if err != nil {
+		return MaterializedRecommendations{}, false, err
+	}
+	scorer, err := scheduler.slotScorer(event, eventAvailability)
+	if err != nil {
+		return MaterializedRecommendations{}, false, err
+	}
+
+	view := MaterializedRecommendations{
+		ComputedAt:    scheduler.clock.Now(),
+		scorer:        scorer,
+		slots:         make(map[string]TimeSlot, len(eventSlots)),
+		responses:     map[string]map[string]UserAvailability{},
+		userResponses: map[string]int{},
+	}
+	bySlot := map[string][]UserAvailability{}
+	for _, avail := range eventAvailability {
+		if view.responses[avail.TimeSlotID] == nil {
+			view.responses[avail.TimeSlotID] = map[string]UserAvailability{}
+		}
+		view.responses[avail.TimeSlotID][avail.ID] = avail
+		view.userResponses[avail.UserID]++
+		bySlot[avail.TimeSlotID] = append(bySlot[avail.TimeSlotID], avail)
+	}
+	recommendations := []Recommendation{}
+	for _, slot := range eventSlots {
+		view.slots[slot.ID] = slot
+		if rec, ok := scorer.score(slot, bySlot[slot.ID]); ok {
+			recommendations = append(recommendations, rec)
+		}
+	}
+	rankRecommendations(recommendations, "")
+	view.Response = RecommendationsResponse{Recommendations: recommendations, Analysis: analyzeRecommendations(recommendations)}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.versions[eventID] != version || v.epoch != epoch {
+		return view, false, nil
+	}
+	v.views[eventID] = view
+	delete(v.pending, eventID)
+	return view, true, nil
+}
+
+// Get returns an event's view, computing it with scheduler when there is
+// none yet or it is older than maxAge
+func (v *recommendationViews) Get(eventID string, scheduler *Scheduler) (MaterializedRecommendations, error) {
+	v.mu.Lock()
+	view, ok := v.views[eventID]
+	v.mu.Unlock()
+	if ok && clock.Now().Sub(view.ComputedAt) <= v.maxAge {
+		return view, nil
+	}
+	view, _, err := v.compute(eventID, scheduler)
+	return view, err
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaterializedRecommendationsRecomputeAfterChanges(t *testing.T) {
+	fake := useFakeClock(t, time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	router := newTestRouter(t)
+	views := newRecommendationViews(time.Second, time.Hour)
+	previousViews, previousBus := materialized, bus
+	materialized, bus = views, newEventBus()
+	bus.SubscribeAll(views.Observe)
+	t.Cleanup(func() { materialized, bus = previousViews, previousBus })
+
+	scheduler := currentScheduler()
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 30, Invitees: []string{"cy"}})
+	require.NoError(t, err)
+	start := fake.Now().Add(24 * time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+
+	availableUsers := func() []string {
+		w := doJSON(router, http.MethodGet, "/api/v1/events/"+event.ID+"/recommendations", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, w.Header().Get("X-Recommendations-Computed-At"))
+		var response RecommendationsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Recommendations, 1)
+		return response.Recommendations[0].AvailableUsers
+	}
+	// The first read computes the view
+	assert.Empty(t, availableUsers())
+
+	// Bob isn't invited, so his response changes whom percentages are out
+	// of; until the debounce interval passes, the previous view is served
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+	assert.Empty(t, availableUsers())
+	require.Eventually(t, func() bool {
+		fake.Advance(time.Second)
+		return len(availableUsers()) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"bob"}, availableUsers())
+
+	// Deleting the event drops its view at once
+	require.NoError(t, scheduler.DeleteEvent(event.ID))
+	w := doJSON(router, http.MethodGet, "/api/v1/events/"+event.ID+"/recommendations", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestMaterializedRecommendationsExpire(t *testing.T) {
+	fake := useFakeClock(t, time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	resetStorage(t)
+	views := newRecommendationViews(time.Second, time.Minute)
+	scheduler := newScheduler(store, fake, newEventBus())
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	first, err := views.Get(event.ID, scheduler)
+	require.NoError(t, err)
+
+	fake.Advance(30 * time.Second)
+	cached, err := views.Get(event.ID, scheduler)
+	require.NoError(t, err)
+	assert.Equal(t, first.ComputedAt, cached.ComputedAt)
+
+	fake.Advance(time.Minute)
+	recomputed, err := views.Get(event.ID, scheduler)
+	require.NoError(t, err)
+	assert.True(t, recomputed.ComputedAt.After(first.ComputedAt))
+}
+
+func TestMaterializedRecommendationsRescoreMatchesFullRecompute(t *testing.T) {
+	fake := useFakeClock(t, time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	resetStorage(t)
+	views := newRecommendationViews(time.Hour, time.Hour)
+	b := newEventBus()
+	b.SubscribeAll(views.Observe)
+	scheduler := newScheduler(store, fake, b)
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 30, Invitees: []string{"bob", "cy"}})
+	require.NoError(t, err)
+	var slots []TimeSlot
+	for i := 0; i < 3; i++ {
+		start := fake.Now().Add(time.Duration(24+i) * time.Hour)
+		slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+		require.NoError(t, err)
+		slots = append(slots, slot)
+	}
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slots[0].ID, Status: "available"})
+	require.NoError(t, err)
+	_, err = views.Get(event.ID, scheduler)
+	require.NoError(t, err)
+
+	from := slots[1].StartTime.Add(15 * time.Minute)
+	changes := []struct {
+		name  string
+		apply func() error
+	}{
+		{"submit", func() error {
+			_, err := scheduler.SubmitAvailability(event.ID, "cy", UserAvailabilityRequest{TimeSlotID: slots[1].ID, Status: "available", AvailableFrom: &from})
+			return err
+		}},
+		{"conditional submit", func() error {
+			_, err := scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slots[1].ID, Status: "available", ConditionalOn: "cy"})
+			return err
+		}},
+		{"update", func() error {
+			_, err := scheduler.UpdateAvailability(event.ID, "bob", slots[0].ID, UserAvailabilityRequest{TimeSlotID: slots[0].ID, Status: "unavailable"})
+			return err
+		}},
+		{"delete", func() error { return scheduler.DeleteAvailability(event.ID, "bob", slots[0].ID) }},
+		{"invitee's last response deleted", func() error { return scheduler.DeleteAvailability(event.ID, "cy", slots[1].ID) }},
+	}
+	for _, change := range changes {
+		require.NoError(t, change.apply(), change.name)
+		views.mu.Lock()
+		view := views.views[event.ID]
+		_, pending := views.pending[event.ID]
+		views.mu.Unlock()
+		require.False(t, pending, "%s should be rescored in place", change.name)
+
+		recommendations, err := scheduler.Recommendations(event.ID)
+		require.NoError(t, err)
+		assert.Equal(t, RecommendationsResponse{Recommendations: recommendations, Analysis: analyzeRecommendations(recommendations)}, view.Response, change.name)
+	}
+
+	// Someone new changes every slot's percentages, so waits for a recompute
+	before := views.views[event.ID].Response
+	_, err = scheduler.SubmitAvailability(event.ID, "dan", UserAvailabilityRequest{TimeSlotID: slots[2].ID, Status: "available"})
+	require.NoError(t, err)
+	views.mu.Lock()
+	assert.Contains(t, views.pending, event.ID)
+	assert.Equal(t, before, views.views[event.ID].Response)
+	views.mu.Unlock()
+}
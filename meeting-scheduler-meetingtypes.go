@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Location modes
+const (
+	LocationVideo    = "video"
+	LocationPhone    = "phone"
+	LocationInPerson = "in_person"
+)
+
+const maxMeetingBufferMinutes = 240
+
+// Question kinds
+const (
+	QuestionText   = "text"
+	QuestionChoice = "choice"
+)
+
+// Question is something asked of whoever books or joins a meeting. Choice
+// questions must be answered with one of Choices.
+type Question struct {
+	ID       string   `json:"id"`
+	Prompt   string   `json:"prompt"`
+	Kind     string   `json:"kind"`
+	Choices  []string `json:"choices,omitempty"`
+	Required bool     `json:"required,omitempty"`
+}
+
+func (q Question) validate() error {
+	if q.ID == "" || q.Prompt == "" {
+		return fmt.Errorf("questions need an id and a prompt")
+	}
+	switch q.Kind {
+	case QuestionText:
+		if len(q.Choices) > 0 {
+			return fmt.Errorf("text question %q cannot have choices", q.ID)
+		}
+	case QuestionChoice:
+		if len(q.Choices) < 2 {
+			return fmt.Errorf("choice question %q needs at least two choices", q.ID)
+		}
+	default:
+		return fmt.Errorf("question kind must be text or choice")
+	}
+	return nil
+}
+
+func validateQuestions(questions []Question) error {
+	seen := map[string]bool{}
+	for _, q := range questions {
+		if err := q.validate(); err != nil {
+			return err
+		}
+		if seen[q.ID] {
+			return fmt.Errorf("duplicate question id %q", q.ID)
+		}
+		seen[q.ID] = true
+	}
+	return nil
+}
+
+// checkAnswers validates answers, keyed by question ID, against the
+// questions asked
+func checkAnswers(questions []Question, answers map[string]string) error {
+	byID := map[string]Question{}
+	for _, q := range questions {
+		byID[q.ID] = q
+		if q.Required && strings.TrimSpace(answers[q.ID]) == "" {
+			return invalid(fmt.Sprintf("Question %q must be answered", q.ID))
+		}
+	}
+	for id, answer := range answers {
+		q, ok := byID[id]
+		if !ok {
+			return invalid(fmt.Sprintf("Unknown question %q", id))
+		}
+		if q.Kind == QuestionChoice && answer != "" && !containsString(q.Choices, answer) {
+			return invalid(fmt.Sprintf("Answer to %q must be one of its choices", id))
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// MeetingType is a reusable meeting configuration, such as "30 minute intro
+// call over video", that events and booking pages refer to by ID
+type MeetingType struct {
+	ID              string `json:"id"`
+	OrgID           string `json:"orgId"`
+	Name            string `json:"name"`
+	DurationMinutes int    `json:"durationMinutes"`
+	// BufferMinutes keeps other meetings this far from either end
+	BufferMinutes int    `json:"bufferMinutes,omitempty"`
+	LocationMode  string `json:"locationMode,omitempty"`
+	// Location is the address, dial-in or video link, as the mode suggests
+	Location string `json:"location,omitempty"`
+	// Questions are asked of people booking through a booking page
+	Questions []Question `json:"questions,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+type MeetingTypeRequest struct {
+	Name            string     `json:"name" binding:"required"`
+	DurationMinutes int        `json:"durationMinutes" binding:"required"`
+	BufferMinutes   int        `json:"bufferMinutes"`
+	LocationMode    string     `json:"locationMode"`
+	Location        string     `json:"location"`
+	Questions       []Question `json:"questions"`
+}
+
+func validLocationMode(mode string) bool {
+	switch mode {
+	case "", LocationVideo, LocationPhone, LocationInPerson:
+		return true
+	}
+	return false
+}
+
+func validateBuffer(minutes int) error {
+	if minutes < 0 || minutes > maxMeetingBufferMinutes {
+		return invalid(fmt.Sprintf("Buffer must be between 0 and %d minutes", maxMeetingBufferMinutes))
+	}
+	return nil
+}
+
+func (req MeetingTypeRequest) validate() error {
+	if req.DurationMinutes <= 0 {
+		return invalid("Duration must be positive")
+	}
+	if err := validateBuffer(req.BufferMinutes); err != nil {
+		return err
+	}
+	if !validLocationMode(req.LocationMode) {
+		return invalid("Location mode must be video, phone or in_person")
+	}
+	if err := validateQuestions(req.Questions); err != nil {
+		return invalid(err.Error())
+	}
+	return nil
+}
+
+// meetingTypeRegistry is the in-memory meeting type store
+type meetingTypeRegistry struct {
+	mu    sync.RWMutex
+	types map[string]MeetingType
+}
+
+func newMeetingTypeRegistry() *meetingTypeRegistry {
+	return &meetingTypeRegistry{types: make(map[string]MeetingType)}
+}
+
+// Get returns the meeting type if it belongs to the organization
+func (r *meetingTypeRegistry) Get(orgID, id string) (MeetingType, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	meetingType, ok := r.types[id]
+	if !ok || meetingType.OrgID != orgID {
+		return MeetingType{}, false
+	}
+	return meetingType, true
+}
+
+func (r *meetingTypeRegistry) Save(meetingType MeetingType) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types[meetingType.ID] = meetingType
+}
+
+func (r *meetingTypeRegistry) Delete(orgID, id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	meetingType, ok := r.types[id]
+	if !ok || meetingType.OrgID != orgID {
+		return false
+	}
+	delete(r.types, id)
+	return true
+}
+
+// List returns the organization's meeting types by name
+func (r *meetingTypeRegistry) List(orgID string) []MeetingType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	typeList := []MeetingType{}
+	for _, meetingType := range r.types {
+		if meetingType.OrgID == orgID {
+			typeList = append(typeList, meetingType)
+		}
+	}
+	sort.Slice(typeList, func(i, j int) bool { return typeList[i].Name < typeList[j].Name })
+	return typeList
+}
+
+var meetingTypes = newMeetingTypeRegistry()
+
+// withMeetingType fills the fields an event request leaves empty from its
+// meeting type, which must belong to the organizer's organization
+func withMeetingType(req CreateEventRequest) (CreateEventRequest, error) {
+	if req.MeetingTypeID == "" {
+		return req, nil
+	}
+	organizer, _ := users.Get(req.OrganizerID)
+	meetingType, ok := meetingTypes.Get(organizer.OrgID, req.MeetingTypeID)
+	if !ok {
+		return req, invalid(fmt.Sprintf("Unknown meeting type %q", req.MeetingTypeID))
+	}
+	if req.RequiredDuration == 0 {
+		req.RequiredDuration = meetingType.DurationMinutes
+	}
+	if req.BufferMinutes == 0 {
+		req.BufferMinutes = meetingType.BufferMinutes
+	}
+	if req.LocationMode == "" {
+		req.LocationMode = meetingType.LocationMode
+		if req.Location == "" {
+			req.Location = meetingType.Location
+		}
+	}
+	return req, nil
+}
+
+// Meeting type handlers are scoped to the caller's organization; members
+// can see them and organizers manage them
+func listMeetingTypes(c *gin.Context) {
+	user, _ := currentUser(c)
+	c.JSON(http.StatusOK, meetingTypes.List(user.OrgID))
+}
+
+func getMeetingType(c *gin.Context) {
+	user, _ := currentUser(c)
+	meetingType, ok := meetingTypes.Get(user.OrgID, c.Param("typeId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Meeting type not found"})
+		return
+	}
+	c.JSON(http.StatusOK, meetingType)
+}
+
+func createMeetingType(c *gin.Context) {
+	user, _ := currentUser(c)
+	var req MeetingTypeRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	now := clock.Now()
+	meetingType := MeetingType{ID: uuid.New().String(), OrgID: user.OrgID, CreatedAt: now}
+	meetingType.apply(req, now)
+	meetingTypes.Save(meetingType)
+	c.JSON(http.StatusCreated, meetingType)
+}
+
+func updateMeetingType(c *gin.Context) {
+	user, _ := currentUser(c)
+	meetingType, ok := meetingTypes.Get(user.OrgID, c.Param("typeId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Meeting type not found"})
+		return
+	}
+	var req MeetingTypeRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	meetingType.apply(req, clock.Now())
+	meetingTypes.Save(meetingType)
+	c.JSON(http.StatusOK, meetingType)
+}
+
+func (t *MeetingType) apply(req MeetingTypeRequest, now time.Time) {
+	t.Name = req.Name
+	t.DurationMinutes = req.DurationMinutes
+	t.BufferMinutes = req.BufferMinutes
+	t.LocationMode = req.LocationMode
+	t.Location = req.Location
+	t.Questions = req.Questions
+	t.UpdatedAt = now
+}
+
+func deleteMeetingType(c *gin.Context) {
+	user, _ := currentUser(c)
+	if !meetingTypes.Delete(user.OrgID, c.Param("typeId")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Meeting type not found"})
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
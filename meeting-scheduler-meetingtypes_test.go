@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetMeetingTypes(t *testing.T) {
+	previous := meetingTypes
+	meetingTypes = newMeetingTypeRegistry()
+	t.Cleanup(func() { meetingTypes = previous })
+}
+
+func TestEventsTakeMeetingTypeDefaults(t *testing.T) {
+	resetDirectory(t)
+	resetMeetingTypes(t)
+	scheduler, _ := newTestScheduler(t)
+	users.Save(User{ID: "org", OrgID: "acme"})
+	users.Save(User{ID: "outsider", OrgID: "globex"})
+	meetingTypes.Save(MeetingType{ID: "standup", OrgID: "acme", Name: "Standup", DurationMinutes: 15, BufferMinutes: 5, LocationMode: LocationPhone, Location: "+1 555 0100"})
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Daily", OrganizerID: "org", MeetingTypeID: "standup"})
+	require.NoError(t, err)
+	assert.Equal(t, 15, event.RequiredDuration)
+	assert.Equal(t, 5, event.BufferMinutes)
+	assert.Equal(t, LocationPhone, event.LocationMode)
+	assert.Equal(t, "+1 555 0100", event.Location)
+
+	// Explicit values win over the type's
+	event, err = scheduler.CreateEvent(CreateEventRequest{Title: "Long", OrganizerID: "org", MeetingTypeID: "standup", RequiredDuration: 45})
+	require.NoError(t, err)
+	assert.Equal(t, 45, event.RequiredDuration)
+
+	_, err = scheduler.CreateEvent(CreateEventRequest{Title: "Daily", OrganizerID: "outsider", MeetingTypeID: "standup"})
+	assert.Error(t, err, "meeting types are scoped to their organization")
+}
+
+func TestCheckAnswers(t *testing.T) {
+	questions := []Question{
+		{ID: "topic", Prompt: "Topic", Kind: QuestionText, Required: true},
+		{ID: "size", Prompt: "Team size", Kind: QuestionChoice, Choices: []string{"1-10", "11+"}},
+	}
+	assert.NoError(t, checkAnswers(questions, map[string]string{"topic": "Pricing", "size": "11+"}))
+	assert.Error(t, checkAnswers(questions, map[string]string{"size": "11+"}))
+	assert.Error(t, checkAnswers(questions, map[string]string{"topic": "Pricing", "size": "huge"}))
+	assert.Error(t, checkAnswers(questions, map[string]string{"topic": "Pricing", "budget": "?"}))
+	assert.Error(t, validateQuestions([]Question{{ID: "a", Prompt: "A", Kind: QuestionChoice, Choices: []string{"only"}}}))
+}
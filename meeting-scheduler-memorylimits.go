@@ -0,0 +1,167 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memoryLimits caps what the in-memory store holds, so a deployment open
+// to anyone can't be run out of memory through the API. Zero means no cap.
+type memoryLimits struct {
+	maxEvents       int
+	maxAvailability int
+}
+
+func memoryLimitsFromEnv() memoryLimits {
+	return memoryLimits{
+		maxEvents:       getenvInt("MEMORY_MAX_EVENTS", 0),
+		maxAvailability: getenvInt("MEMORY_MAX_AVAILABILITY", 0),
+	}
+}
+
+// memoryUsage records when each event was last used, for evicting the
+// least recently used first. It has its own lock because reads, which
+// only hold the store's read lock, count as use.
+type memoryUsage struct {
+	mu       sync.Mutex
+	tick     uint64
+	lastUsed map[string]uint64
+	evicted  int
+}
+
+func newMemoryUsage() *memoryUsage {
+	return &memoryUsage{lastUsed: map[string]uint64{}}
+}
+
+func (u *memoryUsage) touch(eventID string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.tick++
+	u.lastUsed[eventID] = u.tick
+}
+
+func (u *memoryUsage) forget(eventID string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.lastUsed, eventID)
+	u.evicted++
+}
+
+// archivedEvent reports whether an event is done with, and so may be
+// evicted: finalized or cancelled
+func archivedEvent(event Event) bool {
+	return event.Status == "finalized" || event.Status == "cancelled"
+}
+
+// evictionCandidate is the least recently used archived event that keep
+// allows, if any. Events under legal hold are never evicted.
+func (tx memoryTx) evictionCandidate(keep func(Event) bool) (Event, bool) {
+	tx.s.usage.mu.Lock()
+	defer tx.s.usage.mu.Unlock()
+	var oldest Event
+	var oldestUse uint64
+	found := false
+	for _, event := range events {
+		if !archivedEvent(event) || event.LegalHold != nil || !keep(event) {
+			continue
+		}
+		if use := tx.s.usage.lastUsed[event.ID]; !found || use < oldestUse || (use == oldestUse && event.ID < oldest.ID) {
+			oldest, oldestUse, found = event, use, true
+		}
+	}
+	return oldest, found
+}
+
+// evict removes an event with its slots and availability
+func (tx memoryTx) evict(event Event) {
+	for id, slot := range timeSlots {
+		if slot.EventID == event.ID {
+			delete(timeSlots, id)
+		}
+	}
+	for id, avail := range userAvailability {
+		if avail.EventID == event.ID {
+			delete(userAvailability, id)
+		}
+	}
+	delete(events, event.ID)
+	tx.s.usage.forget(event.ID)
+	log.Printf("Memory store full: evicted archived event %s", event.ID)
+}
+
+// makeRoomForEvent evicts an archived event if storing a new one would go
+// past the cap on events
+func (tx memoryTx) makeRoomForEvent(eventID string) error {
+	limit := tx.s.limits.maxEvents
+	if _, exists := events[eventID]; exists || limit <= 0 || len(events) < limit {
+		return nil
+	}
+	candidate, ok := tx.evictionCandidate(func(Event) bool { return true })
+	if !ok {
+		return ErrStoreFull
+	}
+	tx.evict(candidate)
+	return nil
+}
+
+// makeRoomForAvailability evicts archived events holding availability
+// until a new row fits under the cap. The row's own event is never evicted.
+func (tx memoryTx) makeRoomForAvailability(avail UserAvailability) error {
+	limit := tx.s.limits.maxAvailability
+	if _, exists := userAvailability[avail.ID]; exists || limit <= 0 {
+		return nil
+	}
+	for len(userAvailability) >= limit {
+		holding := map[string]bool{}
+		for _, row := range userAvailability {
+			holding[row.EventID] = true
+		}
+		candidate, ok := tx.evictionCandidate(func(event Event) bool {
+			return event.ID != avail.EventID && holding[event.ID]
+		})
+		if !ok {
+			return ErrStoreFull
+		}
+		tx.evict(candidate)
+	}
+	return nil
+}
+
+// StoreOccupancy reports how full the in-memory store is. Max values of 0
+// mean no cap.
+type StoreOccupancy struct {
+	Events          int `json:"events"`
+	MaxEvents       int `json:"maxEvents"`
+	TimeSlots       int `json:"timeSlots"`
+	Availability    int `json:"availability"`
+	MaxAvailability int `json:"maxAvailability"`
+	// Evicted counts the archived events evicted to make room
+	Evicted int `json:"evicted"`
+}
+
+func (s *memoryStore) Occupancy() StoreOccupancy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.usage.mu.Lock()
+	defer s.usage.mu.Unlock()
+	return StoreOccupancy{
+		Events:          len(events),
+		MaxEvents:       s.limits.maxEvents,
+		TimeSlots:       len(timeSlots),
+		Availability:    len(userAvailability),
+		MaxAvailability: s.limits.maxAvailability,
+		Evicted:         s.usage.evicted,
+	}
+}
+
+func getStoreOccupancy(c *gin.Context) {
+	memory, ok := store.(*memoryStore)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Occupancy is only tracked for the in-memory store"})
+		return
+	}
+	c.JSON(http.StatusOK, memory.Occupancy())
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// boundMemoryStore installs a fresh memory store with the given caps
+func boundMemoryStore(t *testing.T, limits memoryLimits) *memoryStore {
+	resetStorage(t)
+	memory := newMemoryStore()
+	memory.limits = limits
+	store = memory
+	return memory
+}
+
+func TestMemoryStoreEvictsLeastRecentlyUsedArchivedEvents(t *testing.T) {
+	scheduler, fake := newTestScheduler(t)
+	memory := boundMemoryStore(t, memoryLimits{maxEvents: 2, maxAvailability: 3})
+	scheduler = newScheduler(store, fake, newEventBus())
+
+	create := func(title string) (Event, error) {
+		return scheduler.CreateEvent(CreateEventRequest{Title: title, OrganizerID: "ada", RequiredDuration: 30})
+	}
+	first, err := create("First")
+	require.NoError(t, err)
+	second, err := create("Second")
+	require.NoError(t, err)
+	_, err = create("Third")
+	assert.ErrorIs(t, err, ErrStoreFull, "open events aren't evicted")
+
+	start := fake.Now().Add(24 * time.Hour)
+	slot, err := scheduler.CreateTimeSlot(first.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	for _, userID := range []string{"bob", "cal"} {
+		_, err = scheduler.SubmitAvailability(first.ID, userID, UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+		require.NoError(t, err)
+	}
+	_, err = scheduler.FinalizeEvent(first.ID, FinalizeEventRequest{TimeSlotID: slot.ID})
+	require.NoError(t, err)
+	_, err = scheduler.CancelEvent(second.ID)
+	require.NoError(t, err)
+	_, err = scheduler.GetEvent(first.ID)
+	require.NoError(t, err)
+
+	third, err := create("Third")
+	require.NoError(t, err)
+	_, err = scheduler.GetEvent(second.ID)
+	assert.ErrorIs(t, err, ErrEventNotFound, "the least recently used archived event goes first")
+	_, err = scheduler.GetEvent(first.ID)
+	assert.NoError(t, err)
+
+	slot, err = scheduler.CreateTimeSlot(third.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	for _, userID := range []string{"bob", "cal"} {
+		_, err = scheduler.SubmitAvailability(third.ID, userID, UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+		require.NoError(t, err)
+	}
+	_, err = scheduler.GetEvent(first.ID)
+	assert.ErrorIs(t, err, ErrEventNotFound, "evicted to fit the availability cap")
+	_, err = scheduler.SubmitAvailability(third.ID, "dan", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(third.ID, "eve", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	assert.ErrorIs(t, err, ErrStoreFull, "an event's own rows never make room for it")
+
+	assert.Equal(t, StoreOccupancy{Events: 1, MaxEvents: 2, TimeSlots: 1, Availability: 3, MaxAvailability: 3, Evicted: 2}, memory.Occupancy())
+}
+
+func TestMemoryStoreKeepsHeldEvents(t *testing.T) {
+	scheduler, fake := newTestScheduler(t)
+	boundMemoryStore(t, memoryLimits{maxEvents: 1})
+	scheduler = newScheduler(store, fake, newEventBus())
+
+	create := func(title string) (Event, error) {
+		return scheduler.CreateEvent(CreateEventRequest{Title: title, OrganizerID: "ada", RequiredDuration: 30})
+	}
+	held, err := create("Held")
+	require.NoError(t, err)
+	_, err = scheduler.CancelEvent(held.ID)
+	require.NoError(t, err)
+	_, err = scheduler.PlaceLegalHold(held.ID, "ada", "Case 42")
+	require.NoError(t, err)
+
+	_, err = create("Next")
+	assert.ErrorIs(t, err, ErrStoreFull, "a held event isn't evicted even when archived")
+	_, err = scheduler.GetEvent(held.ID)
+	require.NoError(t, err)
+
+	_, err = scheduler.ReleaseLegalHold(held.ID)
+	require.NoError(t, err)
+	_, err = create("Next")
+	require.NoError(t, err)
+	_, err = scheduler.GetEvent(held.ID)
+	assert.ErrorIs(t, err, ErrEventNotFound)
+}
+
+func TestStoreOccupancyEndpoint(t *testing.T) {
+	router := newTestRouter(t)
+	resetDirectory(t)
+	boundMemoryStore(t, memoryLimits{maxEvents: 1})
+	admin := User{ID: "root", Role: RoleAdmin}
+	users.Save(admin)
+
+	w := doJSON(router, http.MethodPost, "/api/v1/events", CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 30})
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = doJSON(router, http.MethodPost, "/api/v1/events", CreateEventRequest{Title: "Retro", OrganizerID: "ada", RequiredDuration: 30})
+	assert.Equal(t, http.StatusInsufficientStorage, w.Code)
+
+	token, err := issueSessionToken(admin)
+	require.NoError(t, err)
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/admin/storage", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var occupancy StoreOccupancy
+	decodeJSON(t, w, &occupancy)
+	assert.Equal(t, StoreOccupancy{Events: 1, MaxEvents: 1}, occupancy)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// migrationFiles holds one directory of numbered up/down SQL files per
+// database dialect, compiled into the binary so a deploy is one artifact
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationDialects maps DATABASE_URL schemes to their migrations directory
+var migrationDialects = map[string]string{
+	"postgres":   "postgres",
+	"postgresql": "postgres",
+	"mysql":      "mysql",
+	"sqlite":     "sqlite",
+}
+
+// MigrationStatus is where a database is in the embedded migration set
+type MigrationStatus struct {
+	Version uint `json:"version"`
+	Latest  uint `json:"latest"`
+	// Dirty means a migration failed part-way and must be fixed by hand
+	Dirty   bool   `json:"dirty"`
+	Pending []uint `json:"pending"`
+}
+
+// migrator applies the embedded migrations to DATABASE_URL. golang-migrate
+// takes a database lock around every run, so replicas starting together
+// migrate once and the rest wait for it.
+type migrator struct {
+	url     string
+	dialect string
+}
+
+// migrations is nil when no database is configured
+var migrations *migrator
+
+// migratorFromEnv returns the migrator for DATABASE_URL, or nil when it is
+// unset or names a database without a schema
+func migratorFromEnv() (*migrator, error) {
+	databaseURL := getenv("DATABASE_URL", "")
+	if databaseURL == "" {
+		return nil, nil
+	}
+	// MySQL URLs such as mysql://user@tcp(host:3306)/db aren't valid
+	// for url.Parse, so only the scheme is picked out
+	scheme, _, ok := strings.Cut(databaseURL, "://")
+	if !ok {
+		return nil, fmt.Errorf("DATABASE_URL must start with a scheme such as postgres://")
+	}
+	if mongoSchemes[scheme] {
+		return nil, nil
+	}
+	dialect, ok := migrationDialects[scheme]
+	if !ok {
+		return nil, fmt.Errorf("no migrations for %q databases", scheme)
+	}
+	return &migrator{url: databaseURL, dialect: dialect}, nil
+}
+
+func (m *migrator) open() (*migrate.Migrate, error) {
+	source, err := iofs.New(migrationFiles, path.Join("migrations", m.dialect))
+	if err != nil {
+		return nil, err
+	}
+	run, err := migrate.NewWithSourceInstance("iofs", source, m.url)
+	if err != nil {
+		return nil, err
+	}
+	run.LockTimeout = getenvDuration("MIGRATION_LOCK_TIMEOUT", migrate.DefaultLockTimeout)
+	return run, nil
+}
+
+// Up applies every pending migration
+func (m *migrator) Up() (MigrationStatus, error) {
+	run, err := m.open()
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	defer run.Close()
+	if err := run.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return MigrationStatus{}, err
+	}
+	return m.status(run)
+}
+
+func (m *migrator) Status() (MigrationStatus, error) {
+	run, err := m.open()
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	defer run.Close()
+	return m.status(run)
+}
+
+func (m *migrator) status(run *migrate.Migrate) (MigrationStatus, error) {
+	versions, err := migrationVersions(m.dialect)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	version, dirty, err := run.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return MigrationStatus{}, err
+	}
+	status := MigrationStatus{Version: version, Dirty: dirty, Pending: []uint{}}
+	for _, v := range versions {
+		if v > version {
+			status.Pending = append(status.Pending, v)
+		}
+	}
+	if len(versions) > 0 {
+		status.Latest = versions[len(versions)-1]
+	}
+	return status, nil
+}
+
+// migrationVersions lists the dialect's embedded migration versions in
+// order. Each must have both an up and a down file.
+func migrationVersions(dialect string) ([]uint, error) {
+	entries, err := fs.ReadDir(migrationFiles, path.Join("migrations", dialect))
+	if err != nil {
+		return nil, err
+	}
+	directions := make(map[uint][]string)
+	for _, entry := range entries {
+		prefix, _, _ := strings.Cut(entry.Name(), "_")
+		v, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: name must start with its version", entry.Name())
+		}
+		switch {
+		case strings.HasSuffix(entry.Name(), ".up.sql"):
+			directions[uint(v)] = append(directions[uint(v)], "up")
+		case strings.HasSuffix(entry.Name(), ".down.sql"):
+			directions[uint(v)] = append(directions[uint(v)], "down")
+		default:
+			return nil, fmt.Errorf("migration %s: must end in .up.sql or .down.sql", entry.Name())
+		}
+	}
+	var versions []uint
+	for v, found := range directions {
+		if len(found) != 2 {
+			return nil, fmt.Errorf("migration %d: needs exactly one up and one down file", v)
+		}
+		versions = append(versions, v)
+	}
+	slices.Sort(versions)
+	return versions, nil
+}
+
+// Migration handlers
+func getMigrations(c *gin.Context) {
+	if migrations == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No database is configured"})
+		return
+	}
+	status, err := migrations.Status()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read migration status: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func applyMigrations(c *gin.Context) {
+	if migrations == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No database is configured"})
+		return
+	}
+	status, err := migrations.Up()
+	var dirty migrate.ErrDirty
+	switch {
+	case errors.As(err, &dirty):
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Migration %d failed part-way; fix the database and force its version", dirty.Version)})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply migrations: " + err.Error()})
+	default:
+		c.JSON(http.StatusOK, status)
+	}
+}
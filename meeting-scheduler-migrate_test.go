@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedMigrationsArePaired(t *testing.T) {
+	for _, dialect := range migrationDialects {
+		versions, err := migrationVersions(dialect)
+		require.NoError(t, err, dialect)
+		require.NotEmpty(t, versions, dialect)
+		for i, v := range versions {
+			assert.Equal(t, uint(i+1), v, "%s migrations are numbered without gaps", dialect)
+		}
+	}
+}
+
+func TestMigratorFromEnv(t *testing.T) {
+	t.Setenv("DATABASE_URL", "")
+	m, err := migratorFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, m)
+
+	t.Setenv("DATABASE_URL", "postgresql://scheduler@db:5432/scheduler?sslmode=disable")
+	m, err = migratorFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "postgres", m.dialect)
+
+	t.Setenv("DATABASE_URL", "oracle://db/scheduler")
+	_, err = migratorFromEnv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,631 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// mongoSchemes are the DATABASE_URL schemes served by mongoStore. Documents
+// need no schema, so these have no migrations.
+var mongoSchemes = map[string]bool{
+	"mongodb":     true,
+	"mongodb+srv": true,
+}
+
+// mongoStore keeps each event aggregate in one document of the events
+// collection: the event itself with its slots and responses embedded.
+//
+//	{"_id": "<event id>", "event": {...}, "slots": [{...}], "responses": [{...}]}
+//
+// Records are stored under their JSON field names, so the documents read
+// the same as the API. Writes to one aggregate are atomic on their own;
+// WithTransaction needs a replica set.
+type mongoStore struct {
+	client *mongo.Client
+	events *mongo.Collection
+	// throttles holds failed attempts and lockouts by key
+	throttles *mongo.Collection
+	// sessions holds sign-in sessions by ID
+	sessions *mongo.Collection
+	// twoFactors holds TOTP enrollments by user ID
+	twoFactors *mongo.Collection
+	// impersonations holds the impersonation audit log
+	impersonations *mongo.Collection
+	ctx            context.Context
+	// session is set inside WithTransaction
+	session mongo.Session
+}
+
+func openMongoStore(databaseURL string) (*mongoStore, error) {
+	cs, err := connstring.ParseAndValidate(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing DATABASE_URL: %w", err)
+	}
+	database := cs.Database
+	if database == "" {
+		database = "scheduler"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout())
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(databaseURL))
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("connecting to mongodb: %w", err)
+	}
+
+	// Slots and responses are also looked up by their own IDs
+	events := client.Database(database).Collection("events")
+	_, err = events.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "slots.id", Value: 1}}},
+		{Keys: bson.D{{Key: "responses.id", Value: 1}}},
+	})
+	if err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("creating mongodb indexes: %w", err)
+	}
+	throttles := client.Database(database).Collection("throttles")
+	sessions := client.Database(database).Collection("sessions")
+	_, err = sessions.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}}})
+	if err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("creating mongodb indexes: %w", err)
+	}
+	twoFactors := client.Database(database).Collection("two_factors")
+	impersonations := client.Database(database).Collection("impersonations")
+	_, err = impersonations.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "sessionId", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "at", Value: 1}}},
+	})
+	if err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("creating mongodb indexes: %w", err)
+	}
+	return &mongoStore{client: client, events: events, throttles: throttles, sessions: sessions, twoFactors: twoFactors,
+		impersonations: impersonations, ctx: context.Background()}, nil
+}
+
+// WithContext runs the store's calls under ctx, staying in the
+// transaction if there is one
+func (s *mongoStore) WithContext(ctx context.Context) Store {
+	bound := *s
+	bound.ctx = ctx
+	if s.session != nil {
+		bound.ctx = mongo.NewSessionContext(ctx, s.session)
+	}
+	return &bound
+}
+
+func (s *mongoStore) Close() error {
+	return s.client.Disconnect(context.Background())
+}
+
+// toDocument converts a record to BSON through its JSON form
+func toDocument(record any) (bson.M, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	if err := bson.UnmarshalExtJSON(data, false, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// fromDocument is the reverse of toDocument
+func fromDocument(doc bson.Raw, record any) error {
+	data, err := bson.MarshalExtJSON(doc, false, false)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, record)
+}
+
+func fromDocuments[T any](docs []bson.Raw) ([]T, error) {
+	var list []T
+	for _, doc := range docs {
+		var record T
+		if err := fromDocument(doc, &record); err != nil {
+			return nil, err
+		}
+		list = append(list, record)
+	}
+	return list, nil
+}
+
+// mongoAggregate is the part of an event document a query projected
+type mongoAggregate struct {
+	Event     bson.Raw   `bson:"event"`
+	Slots     []bson.Raw `bson:"slots"`
+	Responses []bson.Raw `bson:"responses"`
+}
+
+func (s *mongoStore) findOne(filter, projection bson.M) (mongoAggregate, error) {
+	var aggregate mongoAggregate
+	err := s.events.FindOne(s.ctx, filter, options.FindOne().SetProjection(projection)).Decode(&aggregate)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return aggregate, ErrNotFound
+	}
+	return aggregate, err
+}
+
+// update applies change to the document filter matches, or returns
+// ErrNotFound when it matches none
+func (s *mongoStore) update(filter, change bson.M) error {
+	result, err := s.events.UpdateOne(s.ctx, filter, change)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *mongoStore) CreateEvent(event Event) error {
+	doc, err := toDocument(event)
+	if err != nil {
+		return err
+	}
+	_, err = s.events.InsertOne(s.ctx, bson.M{"_id": event.ID, "event": doc, "slots": bson.A{}, "responses": bson.A{}})
+	return err
+}
+
+func (s *mongoStore) GetEvent(id string) (Event, error) {
+	var event Event
+	aggregate, err := s.findOne(bson.M{"_id": id}, bson.M{"event": 1})
+	if err != nil {
+		return event, err
+	}
+	err = fromDocument(aggregate.Event, &event)
+	return event, err
+}
+
+func (s *mongoStore) ListEvents() ([]Event, error) {
+	cursor, err := s.events.Find(s.ctx, bson.M{}, options.Find().SetProjection(bson.M{"event": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var aggregates []mongoAggregate
+	if err := cursor.All(s.ctx, &aggregates); err != nil {
+		return nil, err
+	}
+	docs := make([]bson.Raw, len(aggregates))
+	for i, aggregate := range aggregates {
+		docs[i] = aggregate.Event
+	}
+	return fromDocuments[Event](docs)
+}
+
+func (s *mongoStore) UpdateEvent(event Event) error {
+	doc, err := toDocument(event)
+	if err != nil {
+		return err
+	}
+	return s.update(bson.M{"_id": event.ID}, bson.M{"$set": bson.M{"event": doc}})
+}
+
+// CompareAndSwapEvent matches the stored finalization in the update's
+// filter, so the check and the write are one operation
+func (s *mongoStore) CompareAndSwapEvent(event, expected Event) error {
+	doc, err := toDocument(event)
+	if err != nil {
+		return err
+	}
+	// An empty final slot ID is left out of the stored JSON
+	var finalSlot any = expected.FinalTimeSlotID
+	if expected.FinalTimeSlotID == "" {
+		finalSlot = bson.M{"$in": bson.A{nil, ""}}
+	}
+	filter := bson.M{"_id": event.ID, "event.status": expected.Status, "event.finalTimeslotId": finalSlot}
+	err = s.update(filter, bson.M{"$set": bson.M{"event": doc}})
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if _, err := s.GetEvent(event.ID); err != nil {
+		return err
+	}
+	return ErrConflict
+}
+
+// DeleteEvent removes the whole aggregate, slots and responses included
+func (s *mongoStore) DeleteEvent(id string) error {
+	result, err := s.events.DeleteOne(s.ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *mongoStore) CreateTimeSlot(slot TimeSlot) error {
+	doc, err := toDocument(slot)
+	if err != nil {
+		return err
+	}
+	return s.update(bson.M{"_id": slot.EventID}, bson.M{"$push": bson.M{"slots": doc}})
+}
+
+func (s *mongoStore) GetTimeSlot(id string) (TimeSlot, error) {
+	var slot TimeSlot
+	aggregate, err := s.findOne(bson.M{"slots.id": id}, bson.M{"slots.$": 1})
+	if err != nil {
+		return slot, err
+	}
+	if len(aggregate.Slots) == 0 {
+		return slot, ErrNotFound
+	}
+	err = fromDocument(aggregate.Slots[0], &slot)
+	return slot, err
+}
+
+func (s *mongoStore) ListTimeSlots(eventID string) ([]TimeSlot, error) {
+	aggregate, err := s.findOne(bson.M{"_id": eventID}, bson.M{"slots": 1})
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fromDocuments[TimeSlot](aggregate.Slots)
+}
+
+func (s *mongoStore) UpdateTimeSlot(slot TimeSlot) error {
+	doc, err := toDocument(slot)
+	if err != nil {
+		return err
+	}
+	return s.update(bson.M{"slots.id": slot.ID}, bson.M{"$set": bson.M{"slots.$": doc}})
+}
+
+func (s *mongoStore) DeleteTimeSlot(id string) error {
+	return s.update(bson.M{"slots.id": id}, bson.M{"$pull": bson.M{"slots": bson.M{"id": id}}})
+}
+
+func (s *mongoStore) CreateAvailability(avail UserAvailability) error {
+	doc, err := toDocument(avail)
+	if err != nil {
+		return err
+	}
+	return s.update(bson.M{"_id": avail.EventID}, bson.M{"$push": bson.M{"responses": doc}})
+}
+
+func (s *mongoStore) FindAvailability(eventID, userID, timeslotID string) (UserAvailability, error) {
+	var avail UserAvailability
+	match := bson.M{"userId": userID, "timeslotId": timeslotID}
+	aggregate, err := s.findOne(bson.M{"_id": eventID, "responses": bson.M{"$elemMatch": match}},
+		bson.M{"responses": bson.M{"$elemMatch": match}})
+	if err != nil {
+		return avail, err
+	}
+	if len(aggregate.Responses) == 0 {
+		return avail, ErrNotFound
+	}
+	err = fromDocument(aggregate.Responses[0], &avail)
+	return avail, err
+}
+
+func (s *mongoStore) ListAvailability(eventID string) ([]UserAvailability, error) {
+	aggregate, err := s.findOne(bson.M{"_id": eventID}, bson.M{"responses": 1})
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fromDocuments[UserAvailability](aggregate.Responses)
+}
+
+func (s *mongoStore) ListUserAvailability(eventID, userID string) ([]UserAvailability, error) {
+	all, err := s.ListAvailability(eventID)
+	if err != nil {
+		return nil, err
+	}
+	var mine []UserAvailability
+	for _, avail := range all {
+		if avail.UserID == userID {
+			mine = append(mine, avail)
+		}
+	}
+	return mine, nil
+}
+
+func (s *mongoStore) UpdateAvailability(avail UserAvailability) error {
+	doc, err := toDocument(avail)
+	if err != nil {
+		return err
+	}
+	return s.update(bson.M{"responses.id": avail.ID}, bson.M{"$set": bson.M{"responses.$": doc}})
+}
+
+func (s *mongoStore) DeleteAvailability(id string) error {
+	return s.update(bson.M{"responses.id": id}, bson.M{"$pull": bson.M{"responses": bson.M{"id": id}}})
+}
+
+// RecordFailure counts in one update, so replicas counting at once don't
+// lose failures
+func (s *mongoStore) RecordFailure(key string, now, since time.Time) (int, error) {
+	current := bson.D{{Key: "$gte", Value: bson.A{"$windowStart", since}}}
+	count := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"failures":    bson.M{"$cond": bson.A{current, bson.M{"$add": bson.A{"$failures", 1}}, 1}},
+		"windowStart": bson.M{"$cond": bson.A{current, "$windowStart", now}},
+	}}}}
+	var state struct {
+		Failures int `bson:"failures"`
+	}
+	err := s.throttles.FindOneAndUpdate(s.ctx, bson.M{"_id": key}, count,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&state)
+	return state.Failures, err
+}
+
+func (s *mongoStore) Lock(key string, until time.Time) error {
+	_, err := s.throttles.UpdateOne(s.ctx, bson.M{"_id": key}, bson.M{"$set": bson.M{"failures": 0, "lockedUntil": until}})
+	return err
+}
+
+func (s *mongoStore) LockedUntil(key string) (time.Time, error) {
+	var state struct {
+		LockedUntil time.Time `bson:"lockedUntil"`
+	}
+	err := s.throttles.FindOne(s.ctx, bson.M{"_id": key}).Decode(&state)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return time.Time{}, nil
+	}
+	return state.LockedUntil, err
+}
+
+func (s *mongoStore) Prune(now, since time.Time) error {
+	_, err := s.throttles.DeleteMany(s.ctx, bson.M{
+		"windowStart": bson.M{"$lt": since},
+		"$or":         bson.A{bson.M{"lockedUntil": bson.M{"$exists": false}}, bson.M{"lockedUntil": bson.M{"$lte": now}}},
+	})
+	return err
+}
+
+// mongoSession is a session's document, refresh token hashes included
+type mongoSession struct {
+	ID                string    `bson:"_id"`
+	UserID            string    `bson:"userId"`
+	UserAgent         string    `bson:"userAgent"`
+	ClientIP          string    `bson:"clientIp"`
+	ImpersonatorID    string    `bson:"impersonatorId"`
+	TwoFactorVerified bool      `bson:"twoFactorVerified"`
+	RefreshHash       string    `bson:"refreshHash"`
+	PreviousHash      string    `bson:"previousHash"`
+	CreatedAt         time.Time `bson:"createdAt"`
+	LastUsedAt        time.Time `bson:"lastUsedAt"`
+	ExpiresAt         time.Time `bson:"expiresAt"`
+}
+
+func (d mongoSession) session() Session {
+	return Session{ID: d.ID, UserID: d.UserID, UserAgent: d.UserAgent, ClientIP: d.ClientIP, ImpersonatorID: d.ImpersonatorID,
+		TwoFactorVerified: d.TwoFactorVerified, refreshHash: d.RefreshHash, previousHash: d.PreviousHash,
+		CreatedAt: d.CreatedAt, LastUsedAt: d.LastUsedAt, ExpiresAt: d.ExpiresAt}
+}
+
+func (s *mongoStore) CreateSession(session Session) error {
+	_, err := s.sessions.InsertOne(s.ctx, mongoSession{ID: session.ID, UserID: session.UserID, UserAgent: session.UserAgent,
+		ClientIP: session.ClientIP, ImpersonatorID: session.ImpersonatorID, TwoFactorVerified: session.TwoFactorVerified,
+		RefreshHash: session.refreshHash, PreviousHash: session.previousHash,
+		CreatedAt: session.CreatedAt, LastUsedAt: session.LastUsedAt, ExpiresAt: session.ExpiresAt})
+	return err
+}
+
+func (s *mongoStore) GetSession(id string) (Session, error) {
+	var doc mongoSession
+	err := s.sessions.FindOne(s.ctx, bson.M{"_id": id}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Session{}, ErrNotFound
+	}
+	return doc.session(), err
+}
+
+func (s *mongoStore) TouchSession(id string, now time.Time) error {
+	_, err := s.sessions.UpdateOne(s.ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"lastUsedAt": now}})
+	return err
+}
+
+func (s *mongoStore) VerifySessionTwoFactor(id string) error {
+	_, err := s.sessions.UpdateOne(s.ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"twoFactorVerified": true}})
+	return err
+}
+
+// RotateRefreshToken only matches the document while presented is
+// current, so replicas refreshing at once can't both succeed
+func (s *mongoStore) RotateRefreshToken(id, presented, next, clientIP string, now, expiresAt time.Time) error {
+	result, err := s.sessions.UpdateOne(s.ctx, bson.M{"_id": id, "refreshHash": presented}, bson.M{"$set": bson.M{
+		"previousHash": presented, "refreshHash": next, "clientIp": clientIP, "lastUsedAt": now, "expiresAt": expiresAt,
+	}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *mongoStore) SessionsForUser(userID string) ([]Session, error) {
+	cursor, err := s.sessions.Find(s.ctx, bson.M{"userId": userID})
+	if err != nil {
+		return nil, err
+	}
+	var docs []mongoSession
+	if err := cursor.All(s.ctx, &docs); err != nil {
+		return nil, err
+	}
+	list := make([]Session, 0, len(docs))
+	for _, doc := range docs {
+		list = append(list, doc.session())
+	}
+	return list, nil
+}
+
+func (s *mongoStore) DeleteSession(id string) error {
+	_, err := s.sessions.DeleteOne(s.ctx, bson.M{"_id": id})
+	return err
+}
+
+func (s *mongoStore) PruneSessions(now time.Time) error {
+	_, err := s.sessions.DeleteMany(s.ctx, bson.M{"expiresAt": bson.M{"$lte": now}})
+	return err
+}
+
+// mongoTwoFactor is a TOTP enrollment's document
+type mongoTwoFactor struct {
+	UserID      string    `bson:"_id"`
+	Secret      string    `bson:"secret"`
+	Enabled     bool      `bson:"enabled"`
+	EnabledAt   time.Time `bson:"enabledAt,omitempty"`
+	LastCounter int64     `bson:"lastCounter"`
+}
+
+func (s *mongoStore) SaveTwoFactor(enrollment TwoFactor) error {
+	_, err := s.twoFactors.ReplaceOne(s.ctx, bson.M{"_id": enrollment.UserID}, mongoTwoFactor{
+		UserID: enrollment.UserID, Secret: enrollment.Secret, Enabled: enrollment.Enabled,
+		EnabledAt: enrollment.EnabledAt, LastCounter: int64(enrollment.lastCounter),
+	}, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *mongoStore) GetTwoFactor(userID string) (TwoFactor, error) {
+	var doc mongoTwoFactor
+	err := s.twoFactors.FindOne(s.ctx, bson.M{"_id": userID}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return TwoFactor{}, ErrNotFound
+	}
+	return TwoFactor{UserID: doc.UserID, Secret: doc.Secret, Enabled: doc.Enabled, EnabledAt: doc.EnabledAt,
+		lastCounter: uint64(doc.LastCounter)}, err
+}
+
+// AcceptTwoFactorCode only matches the document while the step is newer
+// than the last one used, so a code replayed on another replica is
+// refused
+func (s *mongoStore) AcceptTwoFactorCode(userID string, counter uint64, now time.Time) error {
+	accept := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"lastCounter": int64(counter),
+		"enabled":     true,
+		"enabledAt":   bson.M{"$ifNull": bson.A{"$enabledAt", now}},
+	}}}}
+	result, err := s.twoFactors.UpdateOne(s.ctx, bson.M{"_id": userID, "lastCounter": bson.M{"$lt": int64(counter)}}, accept)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *mongoStore) DeleteTwoFactor(userID string) error {
+	_, err := s.twoFactors.DeleteOne(s.ctx, bson.M{"_id": userID})
+	return err
+}
+
+// mongoImpersonation is an impersonation log entry's document. Its
+// ObjectID orders the log; entries written by different replicas within
+// the same second may interleave.
+type mongoImpersonation struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	SessionID      string             `bson:"sessionId"`
+	ImpersonatorID string             `bson:"impersonatorId"`
+	UserID         string             `bson:"userId"`
+	Action         string             `bson:"action"`
+	Reason         string             `bson:"reason,omitempty"`
+	ClientIP       string             `bson:"clientIp,omitempty"`
+	Method         string             `bson:"method,omitempty"`
+	Path           string             `bson:"path,omitempty"`
+	Status         int                `bson:"status,omitempty"`
+	At             time.Time          `bson:"at"`
+}
+
+func (s *mongoStore) AddImpersonationEntry(entry ImpersonationEntry) error {
+	_, err := s.impersonations.InsertOne(s.ctx, mongoImpersonation{SessionID: entry.SessionID, ImpersonatorID: entry.ImpersonatorID,
+		UserID: entry.UserID, Action: entry.Action, Reason: entry.Reason, ClientIP: entry.ClientIP,
+		Method: entry.Method, Path: entry.Path, Status: entry.Status, At: entry.At})
+	return err
+}
+
+func (s *mongoStore) ImpersonationEntries(sessionID, after string, limit int) ([]ImpersonationEntry, error) {
+	filter := bson.M{}
+	if after != "" {
+		afterID, err := primitive.ObjectIDFromHex(after)
+		if err != nil {
+			return nil, nil
+		}
+		filter["_id"] = bson.M{"$gt": afterID}
+	}
+	if sessionID != "" {
+		filter["sessionId"] = sessionID
+	}
+	cursor, err := s.impersonations.Find(s.ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	var docs []mongoImpersonation
+	if err := cursor.All(s.ctx, &docs); err != nil {
+		return nil, err
+	}
+	list := make([]ImpersonationEntry, 0, len(docs))
+	for _, doc := range docs {
+		list = append(list, ImpersonationEntry{ID: doc.ID.Hex(), SessionID: doc.SessionID, ImpersonatorID: doc.ImpersonatorID,
+			UserID: doc.UserID, Action: doc.Action, Reason: doc.Reason, ClientIP: doc.ClientIP,
+			Method: doc.Method, Path: doc.Path, Status: doc.Status, At: doc.At})
+	}
+	return list, nil
+}
+
+func (s *mongoStore) PruneImpersonationEntries(cutoff time.Time) error {
+	_, err := s.impersonations.DeleteMany(s.ctx, bson.M{"at": bson.M{"$lt": cutoff}})
+	return err
+}
+
+// WithTransaction runs fn in a multi-document transaction, aborted if fn
+// returns an error or panics. Nested transactions join the enclosing one.
+func (s *mongoStore) WithTransaction(fn func(tx Store) error) error {
+	if s.session != nil {
+		return fn(s)
+	}
+	session, err := s.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	return mongo.WithSession(s.ctx, session, func(sc mongo.SessionContext) (err error) {
+		if err := session.StartTransaction(); err != nil {
+			return err
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				session.AbortTransaction(context.Background())
+				panic(r)
+			}
+			if err != nil {
+				session.AbortTransaction(context.Background())
+				return
+			}
+			err = session.CommitTransaction(sc)
+		}()
+
+		inner := *s
+		inner.ctx = sc
+		inner.session = session
+		return fn(&inner)
+	})
+}
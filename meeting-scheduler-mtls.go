@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serviceUserPrefix marks the users service callers act as
+const serviceUserPrefix = "service:"
+
+// ServiceIdentity maps an internal caller's client certificate to the
+// organization and role it acts with
+type ServiceIdentity struct {
+	Name string `json:"name"`
+	// Subject is matched against the certificate's URI SANs (such as a
+	// SPIFFE ID), DNS SANs and common name
+	Subject string `json:"subject"`
+	OrgID   string `json:"orgId"`
+	Role    string `json:"role"`
+}
+
+// user is the caller as the authorization layer sees it
+func (s ServiceIdentity) user() User {
+	return User{ID: serviceUserPrefix + s.Name, Name: s.Name, OrgID: s.OrgID, Role: s.Role}
+}
+
+// serviceRegistry holds service identities by certificate subject
+type serviceRegistry struct {
+	mu       sync.RWMutex
+	services map[string]ServiceIdentity
+}
+
+func newServiceRegistry() *serviceRegistry {
+	return &serviceRegistry{services: make(map[string]ServiceIdentity)}
+}
+
+func (r *serviceRegistry) Save(service ServiceIdentity) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.services[service.Subject] = service
+}
+
+// ForCertificate finds the service a verified client certificate names
+func (r *serviceRegistry) ForCertificate(cert *x509.Certificate) (ServiceIdentity, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var subjects []string
+	for _, uri := range cert.URIs {
+		subjects = append(subjects, uri.String())
+	}
+	subjects = append(subjects, cert.DNSNames...)
+	subjects = append(subjects, cert.Subject.CommonName)
+	for _, subject := range subjects {
+		if service, ok := r.services[subject]; ok && subject != "" {
+			return service, true
+		}
+	}
+	return ServiceIdentity{}, false
+}
+
+var serviceIdentities = newServiceRegistry()
+
+// loadServiceIdentities reads a JSON array of service identities
+func loadServiceIdentities(r *serviceRegistry, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var serviceList []ServiceIdentity
+	if err := json.Unmarshal(data, &serviceList); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for _, service := range serviceList {
+		if service.Name == "" || service.Subject == "" {
+			return fmt.Errorf("parsing %s: service without name or subject", path)
+		}
+		if _, ok := roleRank[service.Role]; !ok {
+			return fmt.Errorf("parsing %s: service %s has unknown role %q", path, service.Name, service.Role)
+		}
+		r.Save(service)
+	}
+	return nil
+}
+
+// clientCAsFromEnv reads the CAs client certificates must chain to from
+// TLS_CLIENT_CA_FILE. It returns nil when mutual TLS is off.
+func clientCAsFromEnv() (*x509.CertPool, error) {
+	path := getenv("TLS_CLIENT_CA_FILE", "")
+	if path == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in %s", path)
+	}
+	return pool, nil
+}
+
+// requestClientCertificates asks for client certificates without
+// requiring them, so browsers and token callers connect as before
+func requestClientCertificates(config *tls.Config, clientCAs *x509.CertPool) {
+	config.ClientCAs = clientCAs
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+}
+
+// authenticateService signs in a caller presenting a verified client
+// certificate as the service it names. It reports false, having answered,
+// when the certificate names no service.
+func authenticateService(c *gin.Context) bool {
+	state := c.Request.TLS
+	if state == nil || len(state.VerifiedChains) == 0 {
+		return true
+	}
+	service, ok := serviceIdentities.ForCertificate(state.VerifiedChains[0][0])
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Client certificate isn't mapped to a service"})
+		return false
+	}
+	c.Set(currentUserKey, service.user())
+	return true
+}
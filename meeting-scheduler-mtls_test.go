@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientCertificatesSignInAsServices(t *testing.T) {
+	router := newTestRouter(t)
+	previous := serviceIdentities
+	serviceIdentities = newServiceRegistry()
+	t.Cleanup(func() { serviceIdentities = previous })
+	serviceIdentities.Save(ServiceIdentity{Name: "booking-api", Subject: "spiffe://acme.test/booking", OrgID: "acme", Role: RoleOrganizer})
+
+	me := func(cert *x509.Certificate) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/me", nil)
+		if cert != nil {
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	spiffeID, _ := url.Parse("spiffe://acme.test/booking")
+	w := me(&x509.Certificate{Subject: pkix.Name{CommonName: "booking"}, URIs: []*url.URL{spiffeID}})
+	require.Equal(t, http.StatusOK, w.Code)
+	var user User
+	decodeJSON(t, w, &user)
+	assert.Equal(t, User{ID: "service:booking-api", Name: "booking-api", OrgID: "acme", Role: RoleOrganizer}, user)
+
+	assert.Equal(t, http.StatusUnauthorized, me(&x509.Certificate{Subject: pkix.Name{CommonName: "stranger"}}).Code)
+	assert.Equal(t, http.StatusUnauthorized, me(nil).Code, "no certificate is anonymous")
+}
+
+func TestLoadServiceIdentitiesRejectsUnknownRoles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"name": "hooks", "subject": "hooks.internal", "orgId": "acme", "role": "root"}]`), 0o600))
+	assert.ErrorContains(t, loadServiceIdentities(newServiceRegistry(), path), "unknown role")
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"name": "hooks", "subject": "hooks.internal", "orgId": "acme", "role": "member"}]`), 0o600))
+	r := newServiceRegistry()
+	require.NoError(t, loadServiceIdentities(r, path))
+	service, ok := r.ForCertificate(&x509.Certificate{DNSNames: []string{"hooks.internal"}})
+	require.True(t, ok)
+	assert.Equal(t, "hooks", service.Name)
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParseTimeSlotsRequest is free text such as "next Tue and Wed 2-4pm ET".
+// Without Confirm the parsed slots are only previewed.
+type ParseTimeSlotsRequest struct {
+	Text     string `json:"text" binding:"required"`
+	TimeZone string `json:"timeZone"`
+	Confirm  bool   `json:"confirm"`
+}
+
+type ParseTimeSlotsResponse struct {
+	TimeSlots []TimeSlot `json:"timeslots"`
+	Confirmed bool       `json:"confirmed"`
+}
+
+var (
+	timeRangePattern = regexp.MustCompile(`(\d{1,2})(?::(\d{2}))?\s*(am|pm)?\s*(?:-|–|to|until)\s*(\d{1,2})(?::(\d{2}))?\s*(am|pm)?`)
+	isoDatePattern   = regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`)
+	monthDayPattern  = regexp.MustCompile(`\b(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?\s+(\d{1,2})(?:st|nd|rd|th)?\b`)
+)
+
+// zoneAbbreviations maps common spoken zone names to locations
+var zoneAbbreviations = map[string]string{
+	"utc": "UTC", "gmt": "UTC", "z": "UTC",
+	"et": "America/New_York", "est": "America/New_York", "edt": "America/New_York",
+	"ct": "America/Chicago", "cst": "America/Chicago", "cdt": "America/Chicago",
+	"mt": "America/Denver", "mst": "America/Denver", "mdt": "America/Denver",
+	"pt": "America/Los_Angeles", "pst": "America/Los_Angeles", "pdt": "America/Los_Angeles",
+	"bst": "Europe/London", "cet": "Europe/Paris", "cest": "Europe/Paris",
+	"ist": "Asia/Kolkata", "jst": "Asia/Tokyo", "aest": "Australia/Sydney",
+}
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+var monthNames = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+	"may": time.May, "jun": time.June, "jul": time.July, "aug": time.August,
+	"sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+}
+
+// parseSlotText turns free text into time slots: one per mentioned day,
+// each spanning the mentioned time range. Weekdays (with or without
+// "next") mean their next occurrence after today. Times without am/pm use
+// the 24-hour clock unless the other end of the range has one. Words that
+// aren't days, dates, times or zones are ignored.
+func parseSlotText(text string, now time.Time, defaultZone string) ([]TimeSlot, error) {
+	lower := strings.ToLower(text)
+
+	loc, err := time.LoadLocation(defaultZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q", defaultZone)
+	}
+	// Location names are case-sensitive, so look for them in the original
+	for _, word := range strings.Fields(text) {
+		if strings.Contains(word, "/") {
+			if zoneLoc, err := time.LoadLocation(strings.Trim(word, ",.()")); err == nil {
+				loc = zoneLoc
+			}
+		}
+	}
+
+	// Explicit dates first, so their digits aren't read as times
+	var dates []time.Time
+	for _, m := range isoDatePattern.FindAllStringSubmatch(lower, -1) {
+		year, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		day, _ := strconv.Atoi(m[3])
+		date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+		if date.Month() != time.Month(month) || date.Day() != day {
+			return nil, fmt.Errorf("no such date %s", m[0])
+		}
+		dates = append(dates, date)
+	}
+	rest := isoDatePattern.ReplaceAllString(lower, " ")
+	for _, m := range monthDayPattern.FindAllStringSubmatch(rest, -1) {
+		day, _ := strconv.Atoi(m[2])
+		// Year 0 is a leap year, so Feb 29 gets through
+		date := time.Date(0, monthNames[m[1]], day, 0, 0, 0, 0, time.UTC)
+		if date.Month() != monthNames[m[1]] || date.Day() != day {
+			return nil, fmt.Errorf("no such date %s", strings.TrimSpace(m[0]))
+		}
+		dates = append(dates, date)
+	}
+	rest = monthDayPattern.ReplaceAllString(rest, " ")
+
+	ranges := timeRangePattern.FindStringSubmatch(rest)
+	if ranges == nil {
+		return nil, fmt.Errorf("no time range such as 2-4pm found")
+	}
+	startOffset, endOffset, err := parseTimeRange(ranges)
+	if err != nil {
+		return nil, err
+	}
+	rest = strings.Replace(rest, ranges[0], " ", 1)
+
+	var weekdayRefs []time.Weekday
+	relative := map[string]int{}
+	for _, word := range strings.FieldsFunc(rest, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '\t' || r == '\n'
+	}) {
+		if day, ok := dayNames[strings.TrimSuffix(word, ".")]; ok {
+			weekdayRefs = append(weekdayRefs, day)
+			continue
+		}
+		switch word {
+		case "today":
+			relative[word] = 0
+		case "tomorrow":
+			relative[word] = 1
+		default:
+			if zone, ok := zoneAbbreviations[word]; ok {
+				loc, _ = time.LoadLocation(zone)
+			}
+		}
+	}
+
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	var days []time.Time
+	for _, offset := range relative {
+		days = append(days, today.AddDate(0, 0, offset))
+	}
+	for _, weekday := range weekdayRefs {
+		ahead := (int(weekday) - int(today.Weekday()) + 7) % 7
+		if ahead == 0 {
+			ahead = 7
+		}
+		days = append(days, today.AddDate(0, 0, ahead))
+	}
+	for _, date := range dates {
+		year := date.Year()
+		if year == 0 {
+			// Month-day without a year means the next one on or after today
+			year = today.Year()
+			if time.Date(year, date.Month(), date.Day(), 0, 0, 0, 0, loc).Before(today) {
+				year++
+			}
+			// Feb 29 waits for a leap year
+			for time.Date(year, date.Month(), date.Day(), 0, 0, 0, 0, loc).Day() != date.Day() {
+				year++
+			}
+		}
+		days = append(days, time.Date(year, date.Month(), date.Day(), 0, 0, 0, 0, loc))
+	}
+	if len(days) == 0 {
+		return nil, fmt.Errorf("no day such as \"tomorrow\", \"Tue\" or \"Jan 5\" found")
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	slots := make([]TimeSlot, 0, len(days))
+	seen := map[time.Time]bool{}
+	for _, day := range days {
+		if seen[day] {
+			continue
+		}
+		seen[day] = true
+		start, end := atClock(day, startOffset), atClock(day, endOffset)
+		if !end.After(start) {
+			return nil, fmt.Errorf("time range %q doesn't exist on %s, when the clocks change", strings.TrimSpace(ranges[0]), day.Format("2006-01-02"))
+		}
+		slots = append(slots, TimeSlot{StartTime: start.UTC(), EndTime: end.UTC()})
+	}
+	return slots, nil
+}
+
+// parseTimeRange converts a timeRangePattern match into offsets from
+// midnight
+func parseTimeRange(m []string) (time.Duration, time.Duration, error) {
+	startMeridiem, endMeridiem := m[3], m[6]
+	if startMeridiem == "" && endMeridiem != "" {
+		// "2-4pm" is 2pm-4pm, but "11-1pm" is 11am-1pm
+		startMeridiem = endMeridiem
+		startHour, _ := strconv.Atoi(m[1])
+		endHour, _ := strconv.Atoi(m[4])
+		if endMeridiem == "pm" && startHour != 12 && (startHour > endHour || endHour == 12) {
+			startMeridiem = "am"
+		}
+	}
+
+	start, err := clockOffset(m[1], m[2], startMeridiem)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := clockOffset(m[4], m[5], endMeridiem)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end <= start {
+		return 0, 0, fmt.Errorf("time range %q ends before it starts", strings.TrimSpace(m[0]))
+	}
+	return start, end, nil
+}
+
+func clockOffset(hourText, minuteText, meridiem string) (time.Duration, error) {
+	hour, _ := strconv.Atoi(hourText)
+	minute := 0
+	if minuteText != "" {
+		minute, _ = strconv.Atoi(minuteText)
+	}
+	switch meridiem {
+	case "am":
+		if hour == 12 {
+			hour = 0
+		}
+	case "pm":
+		if hour != 12 {
+			hour += 12
+		}
+	}
+	if hour > 24 || minute > 59 || (meridiem != "" && hour > 23) {
+		return 0, fmt.Errorf("invalid time %s:%02d", hourText, minute)
+	}
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, nil
+}
+
+// ParseTimeSlots previews the slots described by free text, creating them
+// when the request is confirmed
+func (s *Scheduler) ParseTimeSlots(eventID string, req ParseTimeSlotsRequest) (ParseTimeSlotsResponse, error) {
+	if _, err := s.GetEvent(eventID); err != nil {
+		return ParseTimeSlotsResponse{}, err
+	}
+	zone := req.TimeZone
+	if zone == "" {
+		zone = "UTC"
+	}
+
+	parsed, err := parseSlotText(req.Text, s.clock.Now(), zone)
+	if err != nil {
+		return ParseTimeSlotsResponse{}, invalid("Could not understand slots: " + err.Error())
+	}
+	if !req.Confirm {
+		return ParseTimeSlotsResponse{TimeSlots: parsed}, nil
+	}
+
+	created := make([]TimeSlot, 0, len(parsed))
+	for _, candidate := range parsed {
+		slot, err := s.CreateTimeSlot(eventID, CreateTimeSlotRequest{StartTime: candidate.StartTime, EndTime: candidate.EndTime})
+		if err != nil {
+			return ParseTimeSlotsResponse{}, err
+		}
+		created = append(created, slot)
+	}
+	return ParseTimeSlotsResponse{TimeSlots: created, Confirmed: true}, nil
+}
+
+func parseTimeSlots(c *gin.Context) {
+	scheduler := contextScheduler(c)
+	eventID := c.Param("eventId")
+	if _, err := scheduler.GetEvent(eventID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	var req ParseTimeSlotsRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := scheduler.ParseTimeSlots(eventID, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	status := http.StatusOK
+	if resp.Confirmed {
+		status = http.StatusCreated
+	}
+	c.JSON(status, resp)
+}
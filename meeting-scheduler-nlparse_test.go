@@ -0,0 +1,138 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSlotText(t *testing.T) {
+	// Sunday 12 January 2025, 09:00 UTC
+	now := time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC)
+	newYork, _ := time.LoadLocation("America/New_York")
+
+	tests := []struct {
+		text  string
+		zone  string
+		want  [][2]time.Time
+		error bool
+	}{
+		{
+			text: "next Tue and Wed 2-4pm ET",
+			zone: "UTC",
+			want: [][2]time.Time{
+				{time.Date(2025, 1, 14, 14, 0, 0, 0, newYork), time.Date(2025, 1, 14, 16, 0, 0, 0, newYork)},
+				{time.Date(2025, 1, 15, 14, 0, 0, 0, newYork), time.Date(2025, 1, 15, 16, 0, 0, 0, newYork)},
+			},
+		},
+		{
+			text: "tomorrow 11-1pm",
+			zone: "UTC",
+			want: [][2]time.Time{{time.Date(2025, 1, 13, 11, 0, 0, 0, time.UTC), time.Date(2025, 1, 13, 13, 0, 0, 0, time.UTC)}},
+		},
+		{
+			text: "Jan 20, 2025-02-03 09:30 to 11:00",
+			zone: "America/New_York",
+			want: [][2]time.Time{
+				{time.Date(2025, 1, 20, 9, 30, 0, 0, newYork), time.Date(2025, 1, 20, 11, 0, 0, 0, newYork)},
+				{time.Date(2025, 2, 3, 9, 30, 0, 0, newYork), time.Date(2025, 2, 3, 11, 0, 0, 0, newYork)},
+			},
+		},
+		{
+			// Sunday itself means a week from today
+			text: "Sunday 10am-12pm Europe/Berlin",
+			zone: "UTC",
+			want: [][2]time.Time{{time.Date(2025, 1, 19, 9, 0, 0, 0, time.UTC), time.Date(2025, 1, 19, 11, 0, 0, 0, time.UTC)}},
+		},
+		{
+			// Wall-clock times hold on the day the clocks go forward
+			text: "2025-03-09 2-4pm",
+			zone: "America/New_York",
+			want: [][2]time.Time{{time.Date(2025, 3, 9, 14, 0, 0, 0, newYork), time.Date(2025, 3, 9, 16, 0, 0, 0, newYork)}},
+		},
+		{
+			text: "Feb 29 9-10",
+			zone: "UTC",
+			want: [][2]time.Time{{time.Date(2028, 2, 29, 9, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 10, 0, 0, 0, time.UTC)}},
+		},
+		{text: "sometime next week", zone: "UTC", error: true},
+		{text: "Feb 30 9-10", zone: "UTC", error: true},
+		{text: "2025-13-01 9-10", zone: "UTC", error: true},
+		{text: "2025-03-09 1-2am", zone: "America/New_York", error: true},
+		{text: "2-4pm", zone: "UTC", error: true},
+		{text: "Mon 16:00-14:00", zone: "UTC", error: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			slots, err := parseSlotText(tt.text, now, tt.zone)
+			if tt.error {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, slots, len(tt.want))
+			for i, want := range tt.want {
+				assert.True(t, want[0].Equal(slots[i].StartTime), "start %d: got %s", i, slots[i].StartTime)
+				assert.True(t, want[1].Equal(slots[i].EndTime), "end %d: got %s", i, slots[i].EndTime)
+			}
+		})
+	}
+}
+
+// FuzzParseSlotText checks that whatever text parses gives distinct,
+// ordered slots no longer than a day
+func FuzzParseSlotText(f *testing.F) {
+	for _, seed := range []string{
+		"next Tue and Wed 2-4pm ET",
+		"tomorrow 11-1pm",
+		"Jan 20, 2025-02-03 09:30 to 11:00",
+		"Sunday 10am-12pm Europe/Berlin",
+		"2025-03-09 2-3am",
+		"today 23:00-24:00 Australia/Lord_Howe",
+	} {
+		f.Add(seed, "America/New_York")
+	}
+	now := time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC)
+	f.Fuzz(func(t *testing.T, text, zone string) {
+		slots, err := parseSlotText(text, now, zone)
+		if err != nil {
+			return
+		}
+		if len(slots) == 0 {
+			t.Fatalf("%q parsed to no slots", text)
+		}
+		for i, slot := range slots {
+			if !slot.EndTime.After(slot.StartTime) || slot.EndTime.Sub(slot.StartTime) > 25*time.Hour {
+				t.Fatalf("%q: slot %d is %s-%s", text, i, slot.StartTime, slot.EndTime)
+			}
+			if i > 0 && !slots[i-1].StartTime.Before(slot.StartTime) {
+				t.Fatalf("%q: slot %d starts before slot %d", text, i, i-1)
+			}
+		}
+	})
+}
+
+func TestParseTimeSlotsPreviewThenConfirm(t *testing.T) {
+	scheduler, _ := newTestScheduler(t)
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 60})
+	require.NoError(t, err)
+
+	preview, err := scheduler.ParseTimeSlots(event.ID, ParseTimeSlotsRequest{Text: "Mon and Tue 9-11"})
+	require.NoError(t, err)
+	assert.False(t, preview.Confirmed)
+	assert.Len(t, preview.TimeSlots, 2)
+	slots, _ := scheduler.ListTimeSlots(event.ID)
+	assert.Empty(t, slots)
+
+	confirmed, err := scheduler.ParseTimeSlots(event.ID, ParseTimeSlotsRequest{Text: "Mon and Tue 9-11", Confirm: true})
+	require.NoError(t, err)
+	assert.True(t, confirmed.Confirmed)
+	slots, _ = scheduler.ListTimeSlots(event.ID)
+	assert.Len(t, slots, 2)
+
+	_, err = scheduler.ParseTimeSlots(event.ID, ParseTimeSlotsRequest{Text: "whenever"})
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
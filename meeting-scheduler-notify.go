@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is a notification for one recipient. Channels render it in their
+// own format; Calendar optionally carries an ICS invite. Kind tells
+// channels such as SMS which messages are time-sensitive.
+type Message struct {
+	To       User
+	OrgID    string
+	EventID  string
+	Kind     string
+	Subject  string
+	Body     string
+	Calendar string
+}
+
+// Notifier delivers messages over one channel
+type Notifier interface {
+	Notify(msg Message) error
+}
+
+// logNotifier is used when no delivery channel is configured
+type logNotifier struct{}
+
+func (logNotifier) Notify(msg Message) error {
+	log.Printf("Notification for %s: %s", msg.To.ID, msg.Subject)
+	return nil
+}
+
+// emailNotifier sends branded HTML email over SMTP. The password is read
+// from the secret store on every send, so rotations apply immediately.
+type emailNotifier struct {
+	addr     string
+	from     string
+	username string
+	send     func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func (n *emailNotifier) Notify(msg Message) error {
+	if msg.To.Email == "" {
+		return nil
+	}
+	body, err := renderEmail(msg, n.from)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if n.username != "" {
+		host := strings.Split(n.addr, ":")[0]
+		auth = smtp.PlainAuth("", n.username, secrets.Get("SMTP_PASSWORD"), host)
+	}
+	return n.send(n.addr, auth, n.from, []string{msg.To.Email}, body)
+}
+
+// notifierFromEnv configures SMTP delivery from SMTP_ADDR (host:port),
+// SMTP_USERNAME and SMTP_FROM, with the SMTP_PASSWORD secret, plus SMS
+// and push when their providers are configured. Every channel respects
+// the recipient's quiet hours and the event's notification channels, and
+// what was sent shows on event timelines.
+func notifierFromEnv() Notifier {
+	var channel Notifier = logNotifier{}
+	if addr := getenv("SMTP_ADDR", ""); addr != "" {
+		channel = &emailNotifier{
+			addr:     addr,
+			from:     getenv("SMTP_FROM", "scheduler@localhost"),
+			username: getenv("SMTP_USERNAME", ""),
+			send:     smtp.SendMail,
+		}
+	}
+	channel = channelNotifier{channel: ChannelEmail, next: channel}
+	channels := multiNotifier{channel}
+	if smsSender != nil {
+		channels = append(channels, channelNotifier{channel: ChannelSMS, next: smsNotifier{}})
+	}
+	if len(pushSenders) > 0 {
+		channels = append(channels, channelNotifier{channel: ChannelPush, next: pushNotifier{}})
+	}
+	if len(channels) == 1 {
+		return quietNotifier{next: timelineNotifier{next: channel}}
+	}
+	return quietNotifier{next: timelineNotifier{next: channels}}
+}
+
+// notifier is the active delivery channel
+var notifier Notifier = logNotifier{}
+
+var emailTemplate = htmltemplate.Must(htmltemplate.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif; margin: 0; padding: 0;">
+  <div style="border-top: 4px solid {{.Branding.PrimaryColor}}; padding: 24px;">
+    {{if .Branding.LogoURL}}<img src="{{.Branding.LogoURL}}" alt="{{.OrgName}}" style="max-height: 48px;">{{end}}
+    <h2 style="color: {{.Branding.PrimaryColor}};">{{.Subject}}</h2>
+    {{range .Paragraphs}}<p>{{.}}</p>{{end}}
+    <hr style="border: none; border-top: 1px solid #ddd;">
+    <p style="color: #777; font-size: 12px;">{{.Branding.Footer}}</p>
+  </div>
+</body>
+</html>
+`))
+
+// renderEmail builds a MIME message with plain-text, branded HTML and
+// (when present) calendar alternatives
+func renderEmail(msg Message, from string) ([]byte, error) {
+	branding, orgName := brandingForOrg(msg.OrgID)
+
+	var html bytes.Buffer
+	err := emailTemplate.Execute(&html, struct {
+		Branding   Branding
+		OrgName    string
+		Subject    string
+		Paragraphs []string
+	}{branding, orgName, msg.Subject, strings.Split(msg.Body, "\n\n")})
+	if err != nil {
+		return nil, err
+	}
+
+	var parts bytes.Buffer
+	writer := multipart.NewWriter(&parts)
+	addPart := func(contentType, content string) error {
+		part, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {contentType}})
+		if err != nil {
+			return err
+		}
+		_, err = part.Write([]byte(content))
+		return err
+	}
+	if err := addPart("text/plain; charset=utf-8", msg.Body+"\n\n-- \n"+branding.Footer); err != nil {
+		return nil, err
+	}
+	if err := addPart("text/html; charset=utf-8", html.String()); err != nil {
+		return nil, err
+	}
+	if msg.Calendar != "" {
+		if err := addPart("text/calendar; charset=utf-8; method=PUBLISH", msg.Calendar); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	fromHeader := from
+	if orgName != "" {
+		fromHeader = fmt.Sprintf("%s <%s>", mime.QEncoding.Encode("utf-8", orgName), from)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "From: %s\r\n", fromHeader)
+	fmt.Fprintf(&out, "To: %s\r\n", msg.To.Email)
+	if branding.ReplyTo != "" {
+		fmt.Fprintf(&out, "Reply-To: %s\r\n", branding.ReplyTo)
+	}
+	fmt.Fprintf(&out, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&out, "Date: %s\r\n", clock.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&out, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&out, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+	out.Write(parts.Bytes())
+	return out.Bytes(), nil
+}
+
+// eventParticipants returns the organizer and everyone who responded, as
+// known users
+func eventParticipants(event Event) ([]User, error) {
+	availabilityList, err := store.ListAvailability(event.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var participants []User
+	add := func(id string) {
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		if user, ok := users.Get(id); ok {
+			participants = append(participants, user)
+		}
+	}
+	add(event.OrganizerID)
+	for _, avail := range availabilityList {
+		add(avail.UserID)
+	}
+	for _, id := range event.Invitees {
+		add(id)
+	}
+	return participants, nil
+}
+
+// notifyEventFinalized tells every participant the meeting time, with an
+// invite attached
+func notifyEventFinalized(e DomainEvent) {
+	event, ok := e.Payload.(Event)
+	if !ok {
+		return
+	}
+	slot, err := store.GetTimeSlot(event.FinalTimeSlotID)
+	if err != nil {
+		log.Printf("Finalized event %s has no slot %s: %v", event.ID, event.FinalTimeSlotID, err)
+		return
+	}
+	participants, err := eventParticipants(event)
+	if err != nil {
+		log.Printf("Failed to load participants for %s: %v", event.ID, err)
+		return
+	}
+
+	calendar := buildICS(event, slot, e.OccurredAt)
+	messages := make([]Message, 0, len(participants))
+	for _, user := range participants {
+		messages = append(messages, Message{
+			To:      user,
+			OrgID:   event.OrgID,
+			EventID: event.ID,
+			Kind:    MessageFinalized,
+			Subject: "Confirmed: " + event.Title,
+			Body: fmt.Sprintf("%s is confirmed for %s (%d minutes).\n\nThe calendar invite is attached.",
+				event.Title, slot.StartTime.UTC().Format("Mon Jan 2 2006, 15:04 MST"), event.RequiredDuration),
+			Calendar: calendar,
+		})
+	}
+	notifyAll(messages)
+}
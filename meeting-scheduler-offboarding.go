@@ -0,0 +1,211 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ArchiveFile describes one file of an organization export
+type ArchiveFile struct {
+	Name    string `json:"name"`
+	Records int    `json:"records"`
+	Bytes   int64  `json:"bytes"`
+	SHA256  string `json:"sha256"`
+}
+
+// ArchiveManifest is the export's manifest.json, written last, listing
+// the files before it so a recipient can check the archive is complete
+type ArchiveManifest struct {
+	FormatVersion  int           `json:"formatVersion"`
+	OrganizationID string        `json:"organizationId"`
+	ExportedAt     time.Time     `json:"exportedAt"`
+	Files          []ArchiveFile `json:"files"`
+	// Omitted names what is deliberately left out
+	Omitted []string `json:"omitted"`
+}
+
+// archiveOmissions are credentials, which a departing organization must
+// re-issue rather than carry over
+var archiveOmissions = []string{
+	"SCIM token, integration secret and SSO client secret",
+	"webhook signing secrets and calendar credentials",
+	"push notification device tokens",
+}
+
+// orgArchive writes an organization's data into a zip of NDJSON files,
+// one record per line, recording each file for the manifest
+type orgArchive struct {
+	zip   *zip.Writer
+	files []ArchiveFile
+}
+
+// ndjson writes records as one file. Each is marshalled as it's written,
+// so nothing larger than a record is held in memory.
+func (a *orgArchive) ndjson(name string, count int, record func(i int) interface{}) error {
+	w, err := a.zip.Create(name)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	counted := &countingWriter{w: io.MultiWriter(w, hash)}
+	encoder := json.NewEncoder(counted)
+	for i := 0; i < count; i++ {
+		if err := encoder.Encode(record(i)); err != nil {
+			return err
+		}
+	}
+	a.files = append(a.files, ArchiveFile{Name: name, Records: count, Bytes: counted.n, SHA256: hex.EncodeToString(hash.Sum(nil))})
+	return nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ExportOrganization writes everything the organization owns to w: its
+// profile, members, events with their whole graphs and audit trails,
+// deleted events awaiting purge, and its other configuration
+func (s *Scheduler) ExportOrganization(org Organization, w io.Writer) error {
+	eventList, err := s.ListEvents()
+	if err != nil {
+		return err
+	}
+	var eventIDs []string
+	for _, event := range eventList {
+		if event.OrgID == org.ID {
+			eventIDs = append(eventIDs, event.ID)
+		}
+	}
+	members := users.List(org.ID)
+	var connections []CalendarConnection
+	for _, member := range members {
+		connections = append(connections, calendars.ListForUser(member.ID)...)
+	}
+	hooks := webhooks.List(org.ID)
+	var deliveries []WebhookDelivery
+	for _, hook := range hooks {
+		deliveries = append(deliveries, webhookDeliveries.List(hook.ID)...)
+	}
+
+	archive := &orgArchive{zip: zip.NewWriter(w)}
+	steps := []func() error{
+		func() error {
+			return archive.ndjson("organization.ndjson", 1, func(int) interface{} { return org.public() })
+		},
+		func() error {
+			return archive.ndjson("users.ndjson", len(members), func(i int) interface{} { return members[i] })
+		},
+		func() error {
+			// Each line is the event's full export, as importing one expects
+			var exportErr error
+			err := archive.ndjson("events.ndjson", len(eventIDs), func(i int) interface{} {
+				export, err := s.ExportEvent(eventIDs[i])
+				if err != nil && exportErr == nil {
+					exportErr = err
+				}
+				return export
+			})
+			if err != nil {
+				return err
+			}
+			return exportErr
+		},
+		func() error {
+			if eventLog == nil {
+				return nil
+			}
+			var history []StoredEvent
+			for _, id := range eventIDs {
+				history = append(history, eventLog.History(id)...)
+			}
+			return archive.ndjson("event-history.ndjson", len(history), func(i int) interface{} { return history[i] })
+		},
+		func() error {
+			deleted := trash.ForOrg(org.ID)
+			return archive.ndjson("trash.ndjson", len(deleted), func(i int) interface{} { return deleted[i] })
+		},
+		func() error {
+			list := groups.List(org.ID)
+			return archive.ndjson("groups.ndjson", len(list), func(i int) interface{} { return list[i] })
+		},
+		func() error {
+			list := workspaces.List(org.ID)
+			return archive.ndjson("workspaces.ndjson", len(list), func(i int) interface{} { return list[i] })
+		},
+		func() error {
+			list := meetingTypes.List(org.ID)
+			return archive.ndjson("meeting-types.ndjson", len(list), func(i int) interface{} { return list[i] })
+		},
+		func() error {
+			list := pools.List(org.ID)
+			return archive.ndjson("pools.ndjson", len(list), func(i int) interface{} { return list[i] })
+		},
+		func() error {
+			list := blackouts.List(org.ID)
+			return archive.ndjson("blackouts.ndjson", len(list), func(i int) interface{} { return list[i] })
+		},
+		func() error {
+			return archive.ndjson("calendars.ndjson", len(connections), func(i int) interface{} { return connections[i] })
+		},
+		func() error {
+			return archive.ndjson("webhooks.ndjson", len(hooks), func(i int) interface{} { return hooks[i] })
+		},
+		func() error {
+			return archive.ndjson("webhook-deliveries.ndjson", len(deliveries), func(i int) interface{} { return deliveries[i] })
+		},
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+
+	manifest, err := archive.zip.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(manifest)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(ArchiveManifest{
+		FormatVersion:  exportFormatVersion,
+		OrganizationID: org.ID,
+		ExportedAt:     s.clock.Now(),
+		Files:          archive.files,
+		Omitted:        archiveOmissions,
+	})
+	if err != nil {
+		return err
+	}
+	return archive.zip.Close()
+}
+
+// exportOrganization streams the organization's archive. Once streaming
+// has begun a failure can only cut the archive short; its missing
+// manifest tells the recipient so.
+func exportOrganization(c *gin.Context) {
+	org, ok := organizations.Get(c.Param("orgId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="organization-`+org.ID+`.zip"`)
+	c.Status(http.StatusOK)
+	if err := contextScheduler(c).ExportOrganization(org, c.Writer); err != nil {
+		c.Error(err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportOrganizationArchive(t *testing.T) {
+	resetDirectory(t)
+	resetBlackouts(t)
+	router := newTestRouter(t)
+	organizations.Save(Organization{ID: "acme", Name: "Acme", SCIMToken: "scim-secret"})
+	admin := User{ID: "ada", OrgID: "acme", Role: RoleAdmin}
+	users.Save(admin)
+	users.Save(User{ID: "bob", OrgID: "acme", Role: RoleMember})
+	users.Save(User{ID: "eve", OrgID: "other", Role: RoleOrganizer})
+	blackouts.Save(Blackout{ID: "b1", OrgID: "acme", Name: "Freeze"})
+
+	scheduler := currentScheduler()
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	start := time.Now().Add(24 * time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+	_, err = scheduler.CreateEvent(CreateEventRequest{Title: "Elsewhere", OrganizerID: "eve", RequiredDuration: 30})
+	require.NoError(t, err)
+
+	token, err := issueSessionToken(admin)
+	require.NoError(t, err)
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/organizations/acme/export", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	require.NoError(t, err)
+	lines := map[string][]string{}
+	var manifest ArchiveManifest
+	for _, file := range archive.File {
+		r, err := file.Open()
+		require.NoError(t, err)
+		if file.Name == "manifest.json" {
+			require.NoError(t, json.NewDecoder(r).Decode(&manifest))
+		} else {
+			scanner := bufio.NewScanner(r)
+			scanner.Buffer(nil, 1<<20)
+			lines[file.Name] = []string{}
+			for scanner.Scan() {
+				lines[file.Name] = append(lines[file.Name], scanner.Text())
+			}
+		}
+		r.Close()
+	}
+
+	assert.Equal(t, "acme", manifest.OrganizationID)
+	require.Len(t, manifest.Files, len(lines))
+	for _, file := range manifest.Files {
+		assert.Len(t, lines[file.Name], file.Records, file.Name)
+	}
+	assert.Len(t, lines["users.ndjson"], 2)
+	assert.Len(t, lines["blackouts.ndjson"], 1)
+	assert.NotContains(t, lines["organization.ndjson"][0], "scim-secret")
+
+	require.Len(t, lines["events.ndjson"], 1)
+	var export EventExport
+	require.NoError(t, json.Unmarshal([]byte(lines["events.ndjson"][0]), &export))
+	assert.Equal(t, event.ID, export.Event.ID)
+	assert.Len(t, export.TimeSlots, 1)
+	assert.Len(t, export.Availability, 1)
+
+	other, err := issueSessionToken(User{ID: "eve", OrgID: "other", Role: RoleAdmin})
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+other)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
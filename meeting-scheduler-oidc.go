@@ -0,0 +1,239 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+const ssoLoginTTL = 10 * time.Minute
+
+type pendingLogin struct {
+	orgID   string
+	nonce   string
+	expires time.Time
+}
+
+// ssoState tracks in-flight OIDC logins by their state parameter and caches
+// discovered providers per organization
+type ssoState struct {
+	mu        sync.Mutex
+	pending   map[string]pendingLogin
+	providers map[string]*oidc.Provider
+}
+
+func newSSOState() *ssoState {
+	return &ssoState{
+		pending:   make(map[string]pendingLogin),
+		providers: make(map[string]*oidc.Provider),
+	}
+}
+
+func (s *ssoState) add(state string, login pendingLogin) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[state] = login
+}
+
+// take removes and returns a pending login; each state is single-use
+func (s *ssoState) take(state string) (pendingLogin, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	login, ok := s.pending[state]
+	delete(s.pending, state)
+	return login, ok
+}
+
+// purgeExpired drops logins that were never completed
+func (s *ssoState) purgeExpired(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for state, login := range s.pending {
+		if !now.Before(login.expires) {
+			delete(s.pending, state)
+		}
+	}
+}
+
+func (s *ssoState) provider(c *gin.Context, org Organization) (*oidc.Provider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if provider, ok := s.providers[org.ID]; ok {
+		return provider, nil
+	}
+	provider, err := oidc.NewProvider(c.Request.Context(), org.SSO.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	s.providers[org.ID] = provider
+	return provider, nil
+}
+
+var ssoLogins = newSSOState()
+
+func oauth2Config(org Organization, provider *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     org.SSO.ClientID,
+		ClientSecret: org.SSO.clientSecret(),
+		RedirectURL:  org.SSO.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+	}
+}
+
+// ssoOrganization loads the organization for the request and checks that it
+// has SSO configured
+func ssoOrganization(c *gin.Context) (Organization, bool) {
+	org, ok := organizations.Get(c.Param("orgId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return Organization{}, false
+	}
+	if org.SSO == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SSO is not configured for this organization"})
+		return Organization{}, false
+	}
+	return org, true
+}
+
+// oidcLogin redirects the browser to the organization's identity provider
+func oidcLogin(c *gin.Context) {
+	org, ok := ssoOrganization(c)
+	if !ok {
+		return
+	}
+
+	provider, err := ssoLogins.provider(c, org)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable: " + err.Error()})
+		return
+	}
+
+	state, nonce := randomToken(), randomToken()
+	ssoLogins.add(state, pendingLogin{orgID: org.ID, nonce: nonce, expires: clock.Now().Add(ssoLoginTTL)})
+
+	c.Redirect(http.StatusFound, oauth2Config(org, provider).AuthCodeURL(state, oidc.Nonce(nonce)))
+}
+
+// oidcCallback completes the login, provisions the user and returns a
+// session token
+func oidcCallback(c *gin.Context) {
+	org, ok := ssoOrganization(c)
+	if !ok {
+		return
+	}
+
+	login, ok := ssoLogins.take(c.Query("state"))
+	if !ok || login.orgID != org.ID || !clock.Now().Before(login.expires) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+		return
+	}
+	if idpErr := c.Query("error"); idpErr != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed: " + idpErr})
+		return
+	}
+
+	provider, err := ssoLogins.provider(c, org)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable: " + err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	oauthToken, err := oauth2Config(org, provider).Exchange(ctx, c.Query("code"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Code exchange failed"})
+		return
+	}
+	rawIDToken, ok := oauthToken.Extra("id_token").(string)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Identity provider returned no ID token"})
+		return
+	}
+
+	verifier := provider.Verifier(&oidc.Config{ClientID: org.SSO.ClientID, Now: clock.Now})
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil || idToken.Nonce != login.nonce {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid ID token"})
+		return
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid ID token claims"})
+		return
+	}
+
+	user := provisionSSOUser(org, idToken.Subject, claims)
+	if user.Deactivated {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account has been deprovisioned"})
+		return
+	}
+	tokens, err := startSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"token": tokens.Token, "refreshToken": tokens.RefreshToken, "user": user})
+}
+
+// provisionSSOUser creates or refreshes the local account for an IdP
+// identity. Existing users with a matching email are linked rather than
+// duplicated, and the IdP stays authoritative for name, email and role.
+func provisionSSOUser(org Organization, subject string, claims map[string]interface{}) User {
+	email, _ := claims["email"].(string)
+	name, _ := claims["name"].(string)
+	role := roleForGroups(org.SSO, claimStrings(claims, org.SSO.GroupsClaim))
+
+	user, found := users.FindExternal(org.ID, subject)
+	if !found && email != "" {
+		user, found = users.FindByEmail(org.ID, email)
+	}
+
+	now := clock.Now()
+	if !found {
+		user = User{ID: uuid.New().String(), OrgID: org.ID, CreatedAt: now}
+	}
+	user.ExternalID = subject
+	user.Email = email
+	user.Name = name
+	user.Role = role
+	user.UpdatedAt = now
+
+	users.Save(user)
+	return user
+}
+
+// roleForGroups picks the most privileged role mapped from the user's groups
+func roleForGroups(sso *SSOConfig, groups []string) string {
+	role := sso.DefaultRole
+	if role == "" {
+		role = RoleMember
+	}
+	for _, group := range groups {
+		if mapped, ok := sso.GroupRoles[group]; ok && roleRank[mapped] > roleRank[role] {
+			role = mapped
+		}
+	}
+	return role
+}
+
+// claimStrings reads a string-array claim, defaulting to "groups"
+func claimStrings(claims map[string]interface{}, name string) []string {
+	if name == "" {
+		name = "groups"
+	}
+	values, _ := claims[name].([]interface{})
+	var out []string
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
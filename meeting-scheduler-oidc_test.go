@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetDirectory(t *testing.T) {
+	previousUsers, previousOrgs := users, organizations
+	users, organizations = newUserDirectory(), newOrgRegistry()
+	t.Cleanup(func() { users, organizations = previousUsers, previousOrgs })
+}
+
+func TestProvisionSSOUserMapsGroupsToRoles(t *testing.T) {
+	resetDirectory(t)
+	org := Organization{ID: "acme", SSO: &SSOConfig{
+		GroupRoles: map[string]string{"eng-leads": RoleOrganizer, "it-admins": RoleAdmin},
+	}}
+
+	user := provisionSSOUser(org, "sub-1", map[string]interface{}{
+		"email":  "ada@acme.test",
+		"name":   "Ada",
+		"groups": []interface{}{"eng-leads", "everyone"},
+	})
+	assert.Equal(t, RoleOrganizer, user.Role)
+	assert.Equal(t, "acme", user.OrgID)
+
+	// A second login refreshes the same account from the IdP
+	again := provisionSSOUser(org, "sub-1", map[string]interface{}{
+		"email":  "ada@acme.test",
+		"name":   "Ada Lovelace",
+		"groups": []interface{}{"it-admins"},
+	})
+	assert.Equal(t, user.ID, again.ID)
+	assert.Equal(t, RoleAdmin, again.Role)
+	assert.Equal(t, "Ada Lovelace", again.Name)
+
+	// Users without mapped groups get the default role
+	other := provisionSSOUser(org, "sub-2", map[string]interface{}{"email": "bob@acme.test"})
+	assert.Equal(t, RoleMember, other.Role)
+	assert.NotEqual(t, user.ID, other.ID)
+}
+
+func TestSessionTokenAuthenticatesRequests(t *testing.T) {
+	resetDirectory(t)
+	fake := useFakeClock(t, time.Now())
+	router := newTestRouter(t)
+
+	organizations.Save(Organization{ID: "acme", Name: "Acme", SSO: &SSOConfig{ClientSecret: "s3cret"}})
+	user := User{ID: "u1", OrgID: "acme", Role: RoleMember}
+	users.Save(user)
+	token, err := issueSessionToken(user)
+	require.NoError(t, err)
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, get("/api/v1/organizations/acme", "").Code)
+
+	w := get("/api/v1/organizations/acme", token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "s3cret")
+
+	assert.Equal(t, http.StatusForbidden, get("/api/v1/organizations/other", token).Code)
+
+	fake.Advance(accessTokenTTL + time.Minute)
+	assert.Equal(t, http.StatusUnauthorized, get("/api/v1/users/me", token).Code)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"hash/fnv"
+	"sort"
+)
+
+// WithTieSeed returns a Scheduler that breaks ties between equally scored
+// recommendations by a hash of seed and the slot rather than by start
+// time. The order is shuffled, so the earliest slot isn't always favoured,
+// but stays the same for as long as the seed does.
+func (s *Scheduler) WithTieSeed(seed string) *Scheduler {
+	seeded := *s
+	seeded.tieSeed = seed
+	return &seeded
+}
+
+// tieRank orders a slot among those with the same score under seed
+func tieRank(seed, slotID string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(seed))
+	h.Write([]byte{0})
+	h.Write([]byte(slotID))
+	return h.Sum64()
+}
+
+// rankRecommendations sorts recommendations by score, highest first. Ties
+// go to the earlier slot, or are shuffled by seed when one is given, and
+// finally to the lower slot ID, so the order never depends on map
+// iteration. The users listed on each are sorted too.
+func rankRecommendations(recommendations []Recommendation, seed string) {
+	for i := range recommendations {
+		sortUsers(&recommendations[i])
+	}
+	orderRecommendations(recommendations, seed)
+}
+
+func sortUsers(rec *Recommendation) {
+	sort.Strings(rec.AvailableUsers)
+	sort.Strings(rec.UnavailableUsers)
+	sort.Strings(rec.SoftUnavailableUsers)
+	sort.Strings(rec.ConditionalUsers)
+}
+
+// orderRecommendations is rankRecommendations for recommendations whose
+// users are already sorted
+func orderRecommendations(recommendations []Recommendation, seed string) {
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		a, b := recommendations[i], recommendations[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if seed != "" {
+			if ra, rb := tieRank(seed, a.TimeSlot.ID), tieRank(seed, b.TimeSlot.ID); ra != rb {
+				return ra < rb
+			}
+		} else if !a.TimeSlot.StartTime.Equal(b.TimeSlot.StartTime) {
+			return a.TimeSlot.StartTime.Before(b.TimeSlot.StartTime)
+		}
+		return a.TimeSlot.ID < b.TimeSlot.ID
+	})
+}
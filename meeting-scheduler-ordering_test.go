@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func slotOrder(recommendations []Recommendation) []string {
+	ids := make([]string, len(recommendations))
+	for i, rec := range recommendations {
+		ids[i] = rec.TimeSlot.ID
+	}
+	return ids
+}
+
+func TestTiedRecommendationsHaveAStableOrder(t *testing.T) {
+	scheduler, fake := newTestScheduler(t)
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+
+	// Five slots everyone can make, created latest first
+	start := fake.Now().Add(24 * time.Hour)
+	var byStart []string
+	for i := 4; i >= 0; i-- {
+		slotStart := start.Add(time.Duration(i) * time.Hour)
+		slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: slotStart, EndTime: slotStart.Add(time.Hour)})
+		require.NoError(t, err)
+		byStart = append([]string{slot.ID}, byStart...)
+		for _, userID := range []string{"eve", "bob", "dan", "cal"} {
+			_, err = scheduler.SubmitAvailability(event.ID, userID, UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+			require.NoError(t, err)
+		}
+	}
+
+	recommendations, err := scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, byStart, slotOrder(recommendations), "ties go to the earliest slot")
+	assert.Equal(t, []string{"bob", "cal", "dan", "eve"}, recommendations[0].AvailableUsers)
+
+	seeded, err := scheduler.WithTieSeed("42").Recommendations(event.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, byStart, slotOrder(seeded))
+	for i := 0; i < 10; i++ {
+		again, err := scheduler.WithTieSeed("42").Recommendations(event.ID)
+		require.NoError(t, err)
+		assert.Equal(t, slotOrder(seeded), slotOrder(again), "the same seed gives the same order")
+	}
+
+	differs := false
+	for _, seed := range []string{"1", "2", "3", "4", "5"} {
+		other, err := scheduler.WithTieSeed(seed).Recommendations(event.ID)
+		require.NoError(t, err)
+		differs = differs || slotOrder(other)[0] != slotOrder(seeded)[0] || slotOrder(other)[0] != byStart[0]
+	}
+	assert.True(t, differs, "seeds shuffle the ties")
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Organization struct {
+	ID   string     `json:"id"`
+	Name string     `json:"name"`
+	SSO  *SSOConfig `json:"sso,omitempty"`
+	// SCIMToken authenticates the IdP's SCIM provisioning client
+	SCIMToken string `json:"scimToken,omitempty"`
+	// IntegrationSecret signs availability pushed by HR and shift systems
+	IntegrationSecret string `json:"integrationSecret,omitempty"`
+	// Deprovisioning decides what happens to polls owned by removed users
+	Deprovisioning DeprovisionPolicy `json:"deprovisioning"`
+	// Settings are the organization's defaults for its events
+	Settings *EventSettings `json:"settings,omitempty"`
+	// GuestRetention decides how long guests are kept and what happens to
+	// their responses afterwards
+	GuestRetention GuestRetentionPolicy `json:"guestRetention"`
+	Branding       Branding             `json:"branding"`
+	// ProtectedWindows are recurring lunch and focus-time periods
+	ProtectedWindows []ProtectedWindow `json:"protectedWindows,omitempty"`
+	// SlotGranularity is the ISO-8601 spacing the slot generator uses
+	// when a request doesn't give one
+	SlotGranularity string `json:"slotGranularity,omitempty"`
+	// Redaction adds the organization's patterns to what is masked in
+	// logs and exports
+	Redaction RedactionPolicy `json:"redaction"`
+	// Challenge is the CAPTCHA anonymous respondents must pass
+	Challenge ChallengePolicy `json:"challenge"`
+	// TwoFactor decides whether organizers and admins need a second factor
+	TwoFactor TwoFactorPolicy `json:"twoFactor"`
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+// DeprovisionPolicy controls cleanup after a user is deprovisioned. Their
+// responses on active polls are always declined; polls they organize are
+// handed to ReassignTo, or cancelled when it is empty.
+type DeprovisionPolicy struct {
+	ReassignTo string `json:"reassignTo,omitempty"`
+}
+
+// SSOConfig describes an organization's OIDC identity provider
+type SSOConfig struct {
+	Issuer       string `json:"issuer"`
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret,omitempty"`
+	// ClientSecretRef names a secret holding the client secret instead
+	ClientSecretRef string `json:"clientSecretRef,omitempty"`
+	RedirectURL     string `json:"redirectUrl"`
+	// GroupsClaim names the ID token claim listing the user's groups
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+	// GroupRoles maps IdP group names to roles; the most privileged match wins
+	GroupRoles  map[string]string `json:"groupRoles,omitempty"`
+	DefaultRole string            `json:"defaultRole,omitempty"`
+}
+
+// clientSecret resolves the client secret, preferring the secret store so
+// rotated values are picked up
+func (s SSOConfig) clientSecret() string {
+	if s.ClientSecretRef != "" {
+		return secrets.Get(s.ClientSecretRef)
+	}
+	return s.ClientSecret
+}
+
+// public returns a copy safe to include in API responses
+func (o Organization) public() Organization {
+	o.SCIMToken = ""
+	o.IntegrationSecret = ""
+	if o.SSO != nil {
+		sso := *o.SSO
+		sso.ClientSecret = ""
+		o.SSO = &sso
+	}
+	return o
+}
+
+// orgRegistry is the in-memory organization registry
+type orgRegistry struct {
+	mu   sync.RWMutex
+	orgs map[string]Organization
+}
+
+func newOrgRegistry() *orgRegistry {
+	return &orgRegistry{orgs: make(map[string]Organization)}
+}
+
+func (r *orgRegistry) Get(id string) (Organization, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	org, ok := r.orgs[id]
+	return org, ok
+}
+
+func (r *orgRegistry) Save(org Organization) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.orgs[org.ID] = org
+}
+
+func (r *orgRegistry) List() []Organization {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var orgList []Organization
+	for _, org := range r.orgs {
+		orgList = append(orgList, org)
+	}
+	return orgList
+}
+
+var organizations = newOrgRegistry()
+
+// loadOrganizations seeds the registry from a JSON array of organizations,
+// which is where enterprise SSO settings (including client secrets) live
+func loadOrganizations(r *orgRegistry, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var orgList []Organization
+	if err := json.Unmarshal(data, &orgList); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	now := clock.Now()
+	for _, org := range orgList {
+		if org.ID == "" {
+			return fmt.Errorf("parsing %s: organization without id", path)
+		}
+		if err := org.Redaction.validate(); err != nil {
+			return fmt.Errorf("parsing %s: organization %s: %w", path, org.ID, err)
+		}
+		if err := org.Challenge.validate(); err != nil {
+			return fmt.Errorf("parsing %s: organization %s: %w", path, org.ID, err)
+		}
+		org.CreatedAt = now
+		org.UpdatedAt = now
+		r.Save(org)
+	}
+	return nil
+}
+
+// Organization handlers
+func getOrganization(c *gin.Context) {
+	org, ok := organizations.Get(c.Param("orgId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+	c.JSON(http.StatusOK, org.public())
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OverlapMatrix counts, for each pair of participants, the candidate slots
+// both are available for. Matrix rows and columns follow Participants; the
+// diagonal is each participant's own count.
+type OverlapMatrix struct {
+	TotalSlots   int      `json:"totalSlots"`
+	Participants []string `json:"participants"`
+	Matrix       [][]int  `json:"matrix"`
+}
+
+// Overlap builds the matrix from the event's recommendations, so it
+// reflects calendars, conflicts and other rules as well as responses. A
+// low row total marks the participant who is hardest to schedule around.
+func (s *Scheduler) Overlap(eventID string) (OverlapMatrix, error) {
+	recommendations, err := s.Recommendations(eventID)
+	if err != nil {
+		return OverlapMatrix{}, err
+	}
+
+	seen := map[string]bool{}
+	participants := []string{}
+	for _, rec := range recommendations {
+		for _, list := range [][]string{rec.AvailableUsers, rec.UnavailableUsers} {
+			for _, id := range list {
+				if !seen[id] {
+					seen[id] = true
+					participants = append(participants, id)
+				}
+			}
+		}
+	}
+	sort.Strings(participants)
+	index := make(map[string]int, len(participants))
+	matrix := make([][]int, len(participants))
+	for i, id := range participants {
+		index[id] = i
+		matrix[i] = make([]int, len(participants))
+	}
+
+	for _, rec := range recommendations {
+		for _, a := range rec.AvailableUsers {
+			for _, b := range rec.AvailableUsers {
+				matrix[index[a]][index[b]]++
+			}
+		}
+	}
+	return OverlapMatrix{TotalSlots: len(recommendations), Participants: participants, Matrix: matrix}, nil
+}
+
+func getOverlapMatrix(c *gin.Context) {
+	overlap, err := contextScheduler(c).Overlap(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	// Large polls make for large matrices, so they go out row by row
+	stream := newJSONStream(c, http.StatusOK)
+	stream.Field("totalSlots", overlap.TotalSlots)
+	stream.Field("participants", overlap.Participants)
+	stream.ArrayField("matrix", len(overlap.Matrix), func(i int) interface{} { return overlap.Matrix[i] })
+	stream.Close()
+}
+
+// bottleneckTopSlots is how many of the best slots bottleneck analysis
+// looks at
+const bottleneckTopSlots = 3
+
+// RecommendationAnalysis explains what holds the best slots back
+type RecommendationAnalysis struct {
+	// Bottlenecks are ranked by how much attendance their unavailability
+	// costs the top slots
+	Bottlenecks []Bottleneck `json:"bottlenecks"`
+}
+
+// Bottleneck is a participant unavailable for some of the top slots.
+// ImpactPercentage is the attendance those slots would gain, summed, if
+// the participant could make them.
+type Bottleneck struct {
+	UserID           string  `json:"userId"`
+	BlockedTopSlots  int     `json:"blockedTopSlots"`
+	ImpactPercentage float64 `json:"impactPercentage"`
+}
+
+// analyzeRecommendations finds the bottleneck participants among ranked
+// recommendations
+func analyzeRecommendations(recommendations []Recommendation) RecommendationAnalysis {
+	top := recommendations
+	if len(top) > bottleneckTopSlots {
+		top = top[:bottleneckTopSlots]
+	}
+
+	byUser := map[string]*Bottleneck{}
+	for _, rec := range top {
+		total := len(rec.AvailableUsers) + len(rec.UnavailableUsers)
+		for _, id := range rec.UnavailableUsers {
+			if byUser[id] == nil {
+				byUser[id] = &Bottleneck{UserID: id}
+			}
+			byUser[id].BlockedTopSlots++
+			byUser[id].ImpactPercentage += 100 / float64(total)
+		}
+	}
+
+	analysis := RecommendationAnalysis{Bottlenecks: []Bottleneck{}}
+	for _, b := range byUser {
+		analysis.Bottlenecks = append(analysis.Bottlenecks, *b)
+	}
+	sort.Slice(analysis.Bottlenecks, func(i, j int) bool {
+		a, b := analysis.Bottlenecks[i], analysis.Bottlenecks[j]
+		if a.ImpactPercentage != b.ImpactPercentage {
+			return a.ImpactPercentage > b.ImpactPercentage
+		}
+		return a.UserID < b.UserID
+	})
+	return analysis
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlapMatrix(t *testing.T) {
+	scheduler, fake := newTestScheduler(t)
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	var slots []TimeSlot
+	for i := 0; i < 3; i++ {
+		start := fake.Now().Add(time.Duration(24+i) * time.Hour)
+		slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+		require.NoError(t, err)
+		slots = append(slots, slot)
+	}
+	answers := map[string][]int{"ann": {0, 1, 2}, "bob": {0, 1}, "cy": {2}}
+	for user, indexes := range answers {
+		for _, i := range indexes {
+			_, err = scheduler.SubmitAvailability(event.ID, user, UserAvailabilityRequest{TimeSlotID: slots[i].ID, Status: "available"})
+			require.NoError(t, err)
+		}
+	}
+
+	overlap, err := scheduler.Overlap(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, overlap.TotalSlots)
+	assert.Equal(t, []string{"ann", "bob", "cy"}, overlap.Participants)
+	assert.Equal(t, [][]int{
+		{3, 2, 1},
+		{2, 2, 0},
+		{1, 0, 1},
+	}, overlap.Matrix)
+}
+
+func TestAnalyzeRecommendationsRanksBottlenecks(t *testing.T) {
+	recs := []Recommendation{
+		{AvailableUsers: []string{"ann", "bob"}, UnavailableUsers: []string{"cy", "dee"}},
+		{AvailableUsers: []string{"ann", "bob", "dee"}, UnavailableUsers: []string{"cy"}},
+		{AvailableUsers: []string{"bob", "eve"}, UnavailableUsers: []string{"ann"}},
+		// Below the top slots, so ignored
+		{UnavailableUsers: []string{"bob"}},
+	}
+	analysis := analyzeRecommendations(recs)
+	require.Len(t, analysis.Bottlenecks, 3)
+	assert.Equal(t, "cy", analysis.Bottlenecks[0].UserID)
+	assert.Equal(t, 2, analysis.Bottlenecks[0].BlockedTopSlots)
+	assert.InDelta(t, 50.0, analysis.Bottlenecks[0].ImpactPercentage, 0.001)
+	assert.Equal(t, "ann", analysis.Bottlenecks[1].UserID)
+	assert.Equal(t, "dee", analysis.Bottlenecks[2].UserID)
+}
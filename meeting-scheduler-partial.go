@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AttendanceWindow is the best contiguous stretch of a slot for the
+// meeting when some participants can only make part of it
+type AttendanceWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// partialWindow is the part of a slot a user can attend
+type partialWindow struct {
+	from, until time.Time
+}
+
+// validatePartial checks a partial response lies within its slot
+func validatePartial(req UserAvailabilityRequest, slot TimeSlot) error {
+	if req.AvailableFrom == nil && req.AvailableUntil == nil {
+		return nil
+	}
+	if req.Status != "available" {
+		return invalid("Only available responses can be partial")
+	}
+	from, until := slot.StartTime, slot.EndTime
+	if req.AvailableFrom != nil {
+		from = *req.AvailableFrom
+	}
+	if req.AvailableUntil != nil {
+		until = *req.AvailableUntil
+	}
+	if from.Before(slot.StartTime) || until.After(slot.EndTime) || !until.After(from) {
+		return invalid(fmt.Sprintf("Partial availability must be a window within %s-%s",
+			slot.StartTime.UTC().Format(time.RFC3339), slot.EndTime.UTC().Format(time.RFC3339)))
+	}
+	return nil
+}
+
+// partialOf returns the window of a partial response, clipped to its slot
+func partialOf(avail UserAvailability, slot TimeSlot) (partialWindow, bool) {
+	if avail.AvailableFrom == nil && avail.AvailableUntil == nil {
+		return partialWindow{}, false
+	}
+	w := partialWindow{from: slot.StartTime, until: slot.EndTime}
+	if avail.AvailableFrom != nil && avail.AvailableFrom.After(w.from) {
+		w.from = *avail.AvailableFrom
+	}
+	if avail.AvailableUntil != nil && avail.AvailableUntil.Before(w.until) {
+		w.until = *avail.AvailableUntil
+	}
+	return w, true
+}
+
+// applyPartial picks the start within the slot where the most available
+// users, partial ones included, can stay for the whole meeting, earliest
+// first on ties. Partial users who can't make that window become
+// unavailable. PeakAttendance is the most people present at once, even if
+// not for the whole meeting.
+func applyPartial(rec *Recommendation, partial map[string]partialWindow, duration time.Duration) {
+	slot := rec.TimeSlot
+	latest := slot.EndTime.Add(-duration)
+	if len(partial) == 0 || latest.Before(slot.StartTime) {
+		return
+	}
+
+	candidates := []time.Time{slot.StartTime, latest}
+	instants := []time.Time{slot.StartTime}
+	for _, w := range partial {
+		candidates = append(candidates, w.from, w.until.Add(-duration))
+		instants = append(instants, w.from)
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+
+	attending := func(start, end time.Time) []string {
+		var ids []string
+		for _, id := range rec.AvailableUsers {
+			w, isPartial := partial[id]
+			if !isPartial || (!w.from.After(start) && !w.until.Before(end)) {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+
+	var best []string
+	bestStart := slot.StartTime
+	found := false
+	for _, start := range candidates {
+		if start.Before(slot.StartTime) || start.After(latest) {
+			continue
+		}
+		if ids := attending(start, start.Add(duration)); !found || len(ids) > len(best) {
+			best, bestStart, found = ids, start, true
+		}
+	}
+
+	peak := 0
+	for _, instant := range instants {
+		count := 0
+		for _, id := range rec.AvailableUsers {
+			w, isPartial := partial[id]
+			if !isPartial || (!w.from.After(instant) && w.until.After(instant)) {
+				count++
+			}
+		}
+		if count > peak {
+			peak = count
+		}
+	}
+	rec.PeakAttendance = peak
+
+	inBest := map[string]bool{}
+	for _, id := range best {
+		inBest[id] = true
+	}
+	var missing []string
+	for _, id := range rec.AvailableUsers {
+		if !inBest[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		markUnavailable(rec, missing)
+	}
+	rec.Window = &AttendanceWindow{Start: bestStart, End: bestStart.Add(duration)}
+}
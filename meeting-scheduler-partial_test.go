@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecommendationsUsePartialAvailability(t *testing.T) {
+	scheduler, fake := newTestScheduler(t)
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Design review", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	start := fake.Now().Add(24 * time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	at := func(minutes int) *time.Time {
+		t := start.Add(time.Duration(minutes) * time.Minute)
+		return &t
+	}
+
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available", AvailableFrom: at(-10)})
+	assert.Error(t, err, "window must lie within the slot")
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "unavailable", AvailableUntil: at(40)})
+	assert.Error(t, err, "only available responses can be partial")
+
+	// ann can make the whole slot, bob leaves at 2:40 and cy joins at 2:20
+	_, err = scheduler.SubmitAvailability(event.ID, "ann", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available", AvailableUntil: at(40)})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "cy", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available", AvailableFrom: at(20)})
+	require.NoError(t, err)
+
+	recs, err := scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	require.Len(t, recs, 1)
+	assert.Equal(t, &AttendanceWindow{Start: *at(0), End: *at(30)}, recs[0].Window)
+	assert.ElementsMatch(t, []string{"ann", "bob"}, recs[0].AvailableUsers)
+	assert.Equal(t, []string{"cy"}, recs[0].UnavailableUsers)
+	assert.Equal(t, 3, recs[0].PeakAttendance)
+
+	// Once bob stays until 2:50 there is a half hour everyone can make
+	_, err = scheduler.UpdateAvailability(event.ID, "bob", slot.ID, UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available", AvailableUntil: at(50)})
+	require.NoError(t, err)
+	recs, err = scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &AttendanceWindow{Start: *at(20), End: *at(50)}, recs[0].Window)
+	assert.ElementsMatch(t, []string{"ann", "bob", "cy"}, recs[0].AvailableUsers)
+	assert.Equal(t, 100.0, recs[0].AvailabilityPercentage)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// availabilityArchiver is implemented by stores that keep finished events'
+// responses apart from live ones, such as the SQL store's archive
+// partition. Archived responses read and write as before.
+type availabilityArchiver interface {
+	// ArchiveAvailability archives the responses of events finalized or
+	// cancelled before cutoff, returning how many it moved
+	ArchiveAvailability(cutoff time.Time) (int64, error)
+}
+
+// availabilityArchiveAfter is how long after an event is finalized or
+// cancelled its responses are archived. AVAILABILITY_ARCHIVE_AFTER sets
+// it; 0 turns archiving off.
+func availabilityArchiveAfter() time.Duration {
+	return getenvDuration("AVAILABILITY_ARCHIVE_AFTER", 30*24*time.Hour)
+}
+
+// archiveAvailability is the job moving finished events' responses to
+// the archive, for stores that have one
+func archiveAvailability(now time.Time) {
+	after := availabilityArchiveAfter()
+	archiver, ok := store.(availabilityArchiver)
+	if !ok || after <= 0 {
+		return
+	}
+	archived, err := archiver.ArchiveAvailability(now.Add(-after))
+	if err != nil {
+		log.Printf("Archiving availability failed, will retry: %v", err)
+		return
+	}
+	if archived > 0 {
+		log.Printf("Archived %d responses of finished events", archived)
+	}
+}
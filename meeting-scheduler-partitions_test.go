@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// archivingStore records the cutoffs it is asked to archive before
+type archivingStore struct {
+	Store
+	cutoffs []time.Time
+}
+
+func (s *archivingStore) ArchiveAvailability(cutoff time.Time) (int64, error) {
+	s.cutoffs = append(s.cutoffs, cutoff)
+	return 0, nil
+}
+
+func TestArchiveAvailabilityJob(t *testing.T) {
+	resetStorage(t)
+	primary := &archivingStore{Store: store}
+	store = newReplicatedStore(primary, nil)
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	archiveAvailability(now)
+	assert.Equal(t, []time.Time{now.Add(-30 * 24 * time.Hour)}, primary.cutoffs, "through the primary")
+
+	t.Setenv("AVAILABILITY_ARCHIVE_AFTER", "0")
+	archiveAvailability(now)
+	assert.Len(t, primary.cutoffs, 1, "archiving is off")
+}
+
+func TestArchivedAvailabilityStaysReadable(t *testing.T) {
+	resetStorage(t)
+	archiver, ok := store.(availabilityArchiver)
+	if !ok {
+		t.Skip("the store keeps no archive; run with TEST_STORAGE=mysql")
+	}
+	fake := newFakeClock(time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	scheduler := newScheduler(store, fake, newEventBus())
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	start := fake.Now().Add(24 * time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+
+	archived, err := archiver.ArchiveAvailability(fake.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, archived, "the event is still open")
+
+	_, err = scheduler.FinalizeEvent(event.ID, FinalizeEventRequest{TimeSlotID: slot.ID})
+	require.NoError(t, err)
+	archived, err = archiver.ArchiveAvailability(fake.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), archived)
+
+	list, err := scheduler.store.ListAvailability(event.ID)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "bob", list[0].UserID)
+	list[0].Status = "unavailable"
+	require.NoError(t, scheduler.store.UpdateAvailability(list[0]))
+	require.NoError(t, scheduler.DeleteEvent(event.ID))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPinnedEvents bounds how many events a user can pin to their dashboard
+const maxPinnedEvents = 50
+
+// pinnedOnly narrows an event listing to the ones the user has pinned
+func pinnedOnly(eventList []Event, user User) []Event {
+	pinned := []Event{}
+	for _, event := range eventList {
+		if containsString(user.PinnedEvents, event.ID) {
+			pinned = append(pinned, event)
+		}
+	}
+	return pinned
+}
+
+// Pin handlers act on the signed-in user's own pins
+func pinEvent(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	eventID := c.Param("eventId")
+	if _, err := contextScheduler(c).GetEvent(eventID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if !containsString(user.PinnedEvents, eventID) {
+		if len(user.PinnedEvents) >= maxPinnedEvents {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Too many pinned events; unpin one first"})
+			return
+		}
+		user.PinnedEvents = append(user.PinnedEvents, eventID)
+		user.UpdatedAt = clock.Now()
+		users.Save(user)
+	}
+	c.JSON(http.StatusOK, user.PinnedEvents)
+}
+
+func unpinEvent(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	eventID := c.Param("eventId")
+
+	pins := []string{}
+	for _, id := range user.PinnedEvents {
+		if id != eventID {
+			pins = append(pins, id)
+		}
+	}
+	if len(pins) != len(user.PinnedEvents) {
+		user.PinnedEvents = pins
+		user.UpdatedAt = clock.Now()
+		users.Save(user)
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinnedEventsFilterListing(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	member := User{ID: "bob", OrgID: "acme", Role: RoleMember}
+	users.Save(member)
+	token, err := issueSessionToken(member)
+	require.NoError(t, err)
+	for _, id := range []string{"evt1", "evt2"} {
+		require.NoError(t, store.CreateEvent(Event{ID: id, Title: id, OrganizerID: "ada", RequiredDuration: 30}))
+	}
+
+	send := func(method, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusOK, send("PUT", "/api/v1/users/me/pins/evt2").Code)
+	assert.Equal(t, http.StatusOK, send("PUT", "/api/v1/users/me/pins/evt2").Code, "pinning is idempotent")
+	assert.Equal(t, http.StatusNotFound, send("PUT", "/api/v1/users/me/pins/missing").Code)
+
+	var pinned []Event
+	decodeJSON(t, send("GET", "/api/v1/events?pinned=true"), &pinned)
+	require.Len(t, pinned, 1)
+	assert.Equal(t, "evt2", pinned[0].ID)
+	stored, _ := users.Get("bob")
+	assert.Equal(t, []string{"evt2"}, stored.PinnedEvents)
+
+	assert.Equal(t, http.StatusUnauthorized, doJSON(router, "GET", "/api/v1/events?pinned=true", nil).Code)
+
+	assert.Equal(t, http.StatusNoContent, send("DELETE", "/api/v1/users/me/pins/evt2").Code)
+	decodeJSON(t, send("GET", "/api/v1/events?pinned=true"), &pinned)
+	assert.Empty(t, pinned)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxPlanningCandidates bounds how many start times one search checks
+	maxPlanningCandidates = 5000
+	defaultPlanningStep   = 30
+	defaultPlanningLimit  = 20
+)
+
+// FindWindowsRequest asks when a group can meet for DurationMinutes between
+// From and To, with candidate starts every StepMinutes
+type FindWindowsRequest struct {
+	Participants    []string  `json:"participants" binding:"required"`
+	DurationMinutes int       `json:"durationMinutes" binding:"required"`
+	From            time.Time `json:"from" binding:"required"`
+	To              time.Time `json:"to" binding:"required"`
+	StepMinutes     int       `json:"stepMinutes"`
+	Limit           int       `json:"limit"`
+}
+
+// FeasibleWindow is a run of consecutive start times with the same
+// attendees: the meeting can start anywhere from EarliestStart to
+// LatestStart
+type FeasibleWindow struct {
+	EarliestStart          time.Time `json:"earliestStart"`
+	LatestStart            time.Time `json:"latestStart"`
+	AvailableUsers         []string  `json:"availableUsers"`
+	UnavailableUsers       []string  `json:"unavailableUsers"`
+	AvailabilityPercentage float64   `json:"availabilityPercentage"`
+}
+
+type FindWindowsResponse struct {
+	Windows []FeasibleWindow `json:"windows"`
+	// Warnings name calendars that could not be read; their owners are
+	// treated as free
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// planner answers "is this user free?" for ad-hoc times, combining
+// connected calendars with the same rules recommendations use
+type planner struct {
+	rules    slotRules
+	calendar map[string][]BusyInterval
+}
+
+func (p planner) available(userID string, start, end time.Time) bool {
+	for _, busy := range p.calendar[userID] {
+		if busy.Start.Before(end) && start.Before(busy.End) {
+			return false
+		}
+	}
+	switch declaredStatus(p.rules.declared[userID], start, end) {
+	case DeclaredBusy:
+		return false
+	case DeclaredFree:
+		return true
+	}
+	if shift, ok := p.rules.shifts[userID]; ok && !shift.Covers(start, end) {
+		return false
+	}
+	if conflicted, _ := p.rules.conflicts([]string{userID}, start, end); len(conflicted) > 0 {
+		return false
+	}
+	for _, id := range p.rules.fullyBooked(start) {
+		if id == userID {
+			return false
+		}
+	}
+	return true
+}
+
+// newPlanner loads the rules and calendars for a prospective event's
+// invitees, who must belong to its organization. Unlike polls, users are
+// free unless a calendar, a pushed interval, a shift pattern or another
+// meeting says otherwise.
+func (s *Scheduler) newPlanner(ctx context.Context, event Event, from, to time.Time) (planner, []string, error) {
+	for _, id := range event.Invitees {
+		if user, ok := users.Get(id); !ok || user.OrgID != event.OrgID {
+			return planner{}, nil, invalid(fmt.Sprintf("Unknown participant %q", id))
+		}
+	}
+
+	rules, err := s.newSlotRules(event)
+	if err != nil {
+		return planner{}, nil, err
+	}
+
+	p := planner{rules: rules, calendar: map[string][]BusyInterval{}}
+	var warnings []string
+	for _, id := range event.Invitees {
+		busy, err := userFreeBusy(ctx, id, from, to)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Could not read calendar for %s: %v", id, err))
+			continue
+		}
+		p.calendar[id] = busy
+	}
+	return p, warnings, nil
+}
+
+// FindWindows searches the horizon for every start time that doesn't break
+// a scheduling rule, merges consecutive ones with the same attendees, and
+// ranks the windows by attendance, then by how soon they start
+func (s *Scheduler) FindWindows(ctx context.Context, organizer User, req FindWindowsRequest) (FindWindowsResponse, error) {
+	if len(req.Participants) == 0 {
+		return FindWindowsResponse{}, invalid("At least one participant is required")
+	}
+	if req.DurationMinutes <= 0 {
+		return FindWindowsResponse{}, invalid("Duration must be positive")
+	}
+	if !req.To.After(req.From) {
+		return FindWindowsResponse{}, invalid("To must be after from")
+	}
+	stepMinutes := req.StepMinutes
+	if stepMinutes == 0 {
+		stepMinutes = defaultPlanningStep
+	}
+	if stepMinutes < 0 {
+		return FindWindowsResponse{}, invalid("Step must be positive")
+	}
+	step := time.Duration(stepMinutes) * time.Minute
+	duration := time.Duration(req.DurationMinutes) * time.Minute
+	if req.To.Sub(req.From)/step > maxPlanningCandidates {
+		return FindWindowsResponse{}, invalid("Horizon has too many start times; shorten it or increase the step")
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultPlanningLimit
+	}
+
+	p, warnings, err := s.newPlanner(ctx, Event{OrganizerID: organizer.ID, OrgID: organizer.OrgID, Invitees: req.Participants}, req.From, req.To)
+	if err != nil {
+		return FindWindowsResponse{}, err
+	}
+
+	windows := []FeasibleWindow{}
+	var current *FeasibleWindow
+	for start := req.From; !start.Add(duration).After(req.To); start = start.Add(step) {
+		end := start.Add(duration)
+		if len(p.rules.violations(start, end)) > 0 {
+			current = nil
+			continue
+		}
+
+		var available, unavailable []string
+		for _, id := range req.Participants {
+			if p.available(id, start, end) {
+				available = append(available, id)
+			} else {
+				unavailable = append(unavailable, id)
+			}
+		}
+		if len(available) == 0 {
+			current = nil
+			continue
+		}
+
+		if current != nil && sameUsers(current.AvailableUsers, available) && current.LatestStart.Add(step).Equal(start) {
+			current.LatestStart = start
+			continue
+		}
+		windows = append(windows, FeasibleWindow{
+			EarliestStart:          start,
+			LatestStart:            start,
+			AvailableUsers:         available,
+			UnavailableUsers:       unavailable,
+			AvailabilityPercentage: float64(len(available)) / float64(len(req.Participants)) * 100,
+		})
+		current = &windows[len(windows)-1]
+	}
+
+	sort.SliceStable(windows, func(i, j int) bool {
+		return windows[i].AvailabilityPercentage > windows[j].AvailabilityPercentage
+	})
+	if len(windows) > limit {
+		windows = windows[:limit]
+	}
+	return FindWindowsResponse{Windows: windows, Warnings: warnings}, nil
+}
+
+// sameUsers compares two lists built in the same participant order
+func sameUsers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func findWindows(c *gin.Context) {
+	user, _ := currentUser(c)
+	var req FindWindowsRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := contextScheduler(c).FindWindows(c.Request.Context(), user, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindWindows(t *testing.T) {
+	resetDirectory(t)
+	resetDeclared(t)
+	resetBlackouts(t)
+	scheduler, fake := newTestScheduler(t)
+	organizer := User{ID: "ada", OrgID: "acme", Role: RoleOrganizer}
+	users.Save(organizer)
+	users.Save(User{ID: "bob", OrgID: "acme"})
+	users.Save(User{ID: "cy", OrgID: "acme"})
+	users.Save(User{ID: "eve", OrgID: "other"})
+
+	monday := fake.Now().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	// Bob is out 10:00-11:00 and the org blacks out 12:00-13:00
+	declaredAvailability.Replace("acme", "hr", monday, monday.Add(24*time.Hour), []DeclaredInterval{
+		{OrgID: "acme", UserID: "bob", Source: "hr", Start: monday.Add(10 * time.Hour), End: monday.Add(11 * time.Hour), Status: DeclaredBusy},
+	})
+	blackouts.Save(Blackout{ID: "b1", OrgID: "acme", Name: "Lunch", StartTime: monday.Add(12 * time.Hour), EndTime: monday.Add(13 * time.Hour)})
+
+	req := FindWindowsRequest{
+		Participants:    []string{"bob", "cy"},
+		DurationMinutes: 60,
+		From:            monday.Add(9 * time.Hour),
+		To:              monday.Add(14 * time.Hour),
+	}
+	resp, err := scheduler.FindWindows(context.Background(), organizer, req)
+	require.NoError(t, err)
+	require.Len(t, resp.Windows, 4)
+
+	// Everyone: 09:00 only (09:30 would run into Bob's absence), 11:00
+	// (not 11:30, which overlaps lunch) and 13:00; then Cy alone around Bob
+	assert.Equal(t, monday.Add(9*time.Hour), resp.Windows[0].EarliestStart)
+	assert.Equal(t, monday.Add(9*time.Hour), resp.Windows[0].LatestStart)
+	assert.Equal(t, 100.0, resp.Windows[0].AvailabilityPercentage)
+	assert.Equal(t, monday.Add(11*time.Hour), resp.Windows[1].EarliestStart)
+	assert.Equal(t, monday.Add(11*time.Hour), resp.Windows[1].LatestStart)
+	assert.Equal(t, monday.Add(13*time.Hour), resp.Windows[2].EarliestStart)
+	assert.Equal(t, []string{"cy"}, resp.Windows[3].AvailableUsers)
+	assert.Equal(t, monday.Add(9*time.Hour+30*time.Minute), resp.Windows[3].EarliestStart)
+	assert.Equal(t, monday.Add(10*time.Hour+30*time.Minute), resp.Windows[3].LatestStart)
+
+	req.Participants = []string{"eve"}
+	_, err = scheduler.FindWindows(context.Background(), organizer, req)
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
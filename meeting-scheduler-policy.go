@@ -0,0 +1,117 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultPolicy says who may call each API route. It is kept apart from
+// the handlers so reviewers can read and change it in one place.
+//
+//go:embed policy.json
+var defaultPolicy []byte
+
+// What a policy rule allows besides a minimum role
+const (
+	AllowAnyone   = "anyone"
+	AllowOperator = "operator"
+)
+
+// PolicyRule allows callers onto one route
+type PolicyRule struct {
+	// Route is the method and the path as registered, such as
+	// "GET /api/v1/events/:eventId"
+	Route string `json:"route"`
+	// Allow is anyone, signed in or not; the least role callers need, for
+	// which on routes with an :orgId they must belong to that
+	// organization; or operator, admins of OPERATOR_ORG
+	Allow string `json:"allow"`
+}
+
+// routePolicy maps each route to who it allows
+type routePolicy map[string]string
+
+func parsePolicy(data []byte) (routePolicy, error) {
+	var rules []PolicyRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	policy := make(routePolicy, len(rules))
+	for _, rule := range rules {
+		if _, ok := roleRank[rule.Allow]; !ok && rule.Allow != AllowAnyone && rule.Allow != AllowOperator {
+			return nil, fmt.Errorf("route %q allows unknown %q", rule.Route, rule.Allow)
+		}
+		if _, ok := policy[rule.Route]; ok {
+			return nil, fmt.Errorf("route %q has more than one rule", rule.Route)
+		}
+		policy[rule.Route] = rule.Allow
+	}
+	return policy, nil
+}
+
+// loadPolicy replaces the built-in policy with the file at path
+func loadPolicy(path string) (routePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	loaded, err := parsePolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return loaded, nil
+}
+
+// policy is the active route policy, the built-in one unless POLICY_FILE
+// names another
+var policy = mustParsePolicy(defaultPolicy)
+
+func mustParsePolicy(data []byte) routePolicy {
+	parsed, err := parsePolicy(data)
+	if err != nil {
+		panic(fmt.Sprintf("built-in policy: %v", err))
+	}
+	return parsed
+}
+
+// authorize lets a request through only if the policy allows its caller
+// onto the route. Routes the policy doesn't mention are closed.
+func authorize(c *gin.Context) {
+	allow, ok := policy[c.Request.Method+" "+c.FullPath()]
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "No authorization policy covers this route"})
+		return
+	}
+	if allow == AllowAnyone {
+		c.Next()
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	if allow == AllowOperator {
+		if !isOperator(user) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Only operators can change deployment settings"})
+			return
+		}
+		c.Next()
+		return
+	}
+	if !roleAtLeast(user.Role, allow) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient role"})
+		return
+	}
+	if orgID := c.Param("orgId"); orgID != "" && orgID != user.OrgID {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Not a member of this organization"})
+		return
+	}
+	c.Next()
+}
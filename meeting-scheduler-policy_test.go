@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyCoversEveryAPIRoute(t *testing.T) {
+	router := newTestRouter(t)
+	registered := make(map[string]bool)
+	for _, route := range router.Routes() {
+		if strings.HasPrefix(route.Path, "/api/v1/") {
+			registered[route.Method+" "+route.Path] = true
+		}
+	}
+	for route := range registered {
+		assert.Contains(t, policy, route, "every API route needs a policy rule")
+	}
+	for route := range policy {
+		assert.True(t, registered[route], "policy rule for %q matches no route", route)
+	}
+}
+
+func TestPolicyFileReplacesTheBuiltInRules(t *testing.T) {
+	router := newTestRouter(t)
+	previous := policy
+	t.Cleanup(func() { policy = previous })
+
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"route": "GET /api/v1/events", "allow": "admin"}]`), 0o600))
+	loaded, err := loadPolicy(path)
+	require.NoError(t, err)
+	policy = loaded
+
+	assert.Equal(t, http.StatusUnauthorized, doJSON(router, http.MethodGet, "/api/v1/events", nil).Code)
+	assert.Equal(t, http.StatusForbidden, doJSON(router, http.MethodGet, "/api/v1/maintenance", nil).Code, "unlisted routes are closed")
+}
+
+func TestParsePolicyRejectsUnknownAllowances(t *testing.T) {
+	_, err := parsePolicy([]byte(`[{"route": "GET /api/v1/events", "allow": "superuser"}]`))
+	assert.Error(t, err)
+	_, err = parsePolicy([]byte(`[{"route": "GET /api/v1/events", "allow": "anyone"}, {"route": "GET /api/v1/events", "allow": "admin"}]`))
+	assert.Error(t, err)
+}
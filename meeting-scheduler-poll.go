@@ -0,0 +1,75 @@
+package main
+
+import (
+	htmltemplate "html/template"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+var pollTemplate = htmltemplate.Must(htmltemplate.New("poll").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>{{.Event.Title}}</title>
+  <style>
+    body { font-family: sans-serif; margin: 0; }
+    header { border-top: 6px solid {{.Branding.PrimaryColor}}; padding: 16px 24px; }
+    header img { max-height: 48px; }
+    main { padding: 0 24px; }
+    h1 { color: {{.Branding.PrimaryColor}}; }
+    li { margin: 6px 0; }
+    footer { color: #777; font-size: 12px; padding: 24px; }
+  </style>
+</head>
+<body>
+  <header>{{if .Branding.LogoURL}}<img src="{{.Branding.LogoURL}}" alt="{{.OrgName}}">{{else}}{{.OrgName}}{{end}}</header>
+  <main>
+    <h1>{{.Event.Title}}</h1>
+    {{if .Event.Description}}<p>{{.Event.Description}}</p>{{end}}
+    <p>{{.Event.RequiredDuration}} minutes. Candidate times (UTC):</p>
+    <ul>
+      {{range .Slots}}<li>{{.StartTime.UTC.Format "Mon Jan 2, 15:04"}} &ndash; {{.EndTime.UTC.Format "15:04"}}</li>
+      {{else}}<li>No times proposed yet.</li>{{end}}
+    </ul>
+    {{if .Event.Questions}}<p>Please also answer:</p>
+    <ol>
+      {{range .Event.Questions}}<li>{{.Prompt}}{{if .Required}} (required){{end}}{{if .Choices}}: {{range $i, $choice := .Choices}}{{if $i}} / {{end}}{{$choice}}{{end}}{{end}}</li>
+      {{end}}
+    </ol>{{end}}
+  </main>
+  <footer>{{.Branding.Footer}}</footer>
+</body>
+</html>
+`))
+
+// getPollPage renders the participant-facing poll page with the event's
+// organization branding
+func getPollPage(c *gin.Context) {
+	scheduler := contextScheduler(c)
+	event, err := scheduler.GetEvent(c.Param("eventId"))
+	if err != nil {
+		c.String(http.StatusNotFound, "Event not found")
+		return
+	}
+	slots, err := scheduler.ListTimeSlots(event.ID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].StartTime.Before(slots[j].StartTime) })
+
+	branding, orgName := brandingForOrg(event.OrgID)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	err = pollTemplate.Execute(c.Writer, struct {
+		Event    Event
+		Slots    []TimeSlot
+		Branding Branding
+		OrgName  string
+	}{event, slots, branding, orgName})
+	if err != nil {
+		c.Error(err)
+	}
+}
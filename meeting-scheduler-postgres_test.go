@@ -0,0 +1,27 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresRebindNumbersPlaceholders(t *testing.T) {
+	assert.Equal(t, "SELECT details FROM events WHERE id = $1", postgresRebind("SELECT details FROM events WHERE id = ?"))
+	assert.Equal(t, "UPDATE t SET a = $1, b = $2 WHERE id = $3 AND status = $4", postgresRebind("UPDATE t SET a = ?, b = ? WHERE id = ? AND status = ?"))
+	assert.Equal(t, "DELETE FROM events", postgresRebind("DELETE FROM events"))
+}
+
+func TestPostgresMalformedKeysAreNotFound(t *testing.T) {
+	s := &sqlStore{dialect: postgresDialect}
+	malformed := fmt.Errorf("querying: %w", &pgconn.PgError{Code: "22P02"})
+	assert.ErrorIs(t, s.lookupErr(malformed), ErrNotFound)
+
+	other := &pgconn.PgError{Code: "23505"}
+	assert.Equal(t, error(other), s.lookupErr(other))
+	assert.NoError(t, s.lookupErr(nil))
+	assert.False(t, mysqlDialect.malformedKey(errors.New("anything")))
+}
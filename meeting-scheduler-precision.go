@@ -0,0 +1,68 @@
+package main
+
+import "time"
+
+// timestampPrecision is the precision of every recorded timestamp, such as
+// CreatedAt and UpdatedAt. Records written in the same millisecond compare
+// equal however the store or the JSON round trip treats the rest.
+const timestampPrecision = time.Millisecond
+
+// precisionClock reads the time to timestampPrecision
+type precisionClock struct {
+	Clock
+}
+
+func (c precisionClock) Now() time.Time { return c.Clock.Now().Truncate(timestampPrecision) }
+
+// withPrecision wraps c in a precisionClock unless it already is one
+func withPrecision(c Clock) Clock {
+	if _, ok := c.(precisionClock); ok {
+		return c
+	}
+	return precisionClock{c}
+}
+
+// configuredSlotPrecision is the granularity slot boundaries and partial availability
+// windows are truncated to, so seconds of noise from a client don't stop
+// two slots from matching or leave gaps between back-to-back ones.
+// SLOT_PRECISION sets it; it is never finer than timestampPrecision.
+func configuredSlotPrecision() time.Duration {
+	return max(getenvDuration("SLOT_PRECISION", time.Minute), timestampPrecision)
+}
+
+// slotPrecision is the precision for the event's slots: the configured
+// one, or a second or timestampPrecision when that wouldn't divide the event's
+// duration and its organization's slot granularity
+func (e Event) slotPrecision() time.Duration {
+	granularity, _ := orgGranularity(e.OrgID)
+	precision := configuredSlotPrecision()
+	for _, finer := range []time.Duration{time.Second, timestampPrecision} {
+		if e.duration()%precision == 0 && granularity%precision == 0 {
+			break
+		}
+		precision = min(precision, finer)
+	}
+	return precision
+}
+
+// normalizeSlotRequest truncates a slot request's boundaries to precision,
+// counted from the zero time in UTC
+func normalizeSlotRequest(req CreateTimeSlotRequest, precision time.Duration) CreateTimeSlotRequest {
+	req.StartTime = req.StartTime.Truncate(precision)
+	req.EndTime = req.EndTime.Truncate(precision)
+	return req
+}
+
+// normalizePartialRequest truncates a partial response's window to
+// precision, leaving the caller's times untouched
+func normalizePartialRequest(req UserAvailabilityRequest, precision time.Duration) UserAvailabilityRequest {
+	if req.AvailableFrom != nil {
+		from := req.AvailableFrom.Truncate(precision)
+		req.AvailableFrom = &from
+	}
+	if req.AvailableUntil != nil {
+		until := req.AvailableUntil.Truncate(precision)
+		req.AvailableUntil = &until
+	}
+	return req
+}
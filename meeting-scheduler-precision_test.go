@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestampsAndSlotBoundariesAreTruncated(t *testing.T) {
+	scheduler, fake := newTestScheduler(t)
+	fake.Advance(1234567 * time.Nanosecond)
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 12, 9, 0, 0, int(time.Millisecond), time.UTC), event.CreatedAt)
+
+	start := time.Date(2025, 1, 13, 10, 0, 42, 500, time.UTC)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 13, 10, 0, 0, 0, time.UTC), slot.StartTime)
+	assert.Equal(t, time.Date(2025, 1, 13, 11, 0, 0, 0, time.UTC), slot.EndTime)
+
+	from := slot.StartTime.Add(15*time.Minute + 59*time.Second)
+	avail, err := scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available", AvailableFrom: &from})
+	require.NoError(t, err)
+	assert.Equal(t, slot.StartTime.Add(15*time.Minute), *avail.AvailableFrom)
+	assert.Equal(t, slot.StartTime.Add(15*time.Minute+59*time.Second), from, "the caller's time is left alone")
+
+	next, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: slot.EndTime.Add(30 * time.Second), EndTime: slot.EndTime.Add(time.Hour)})
+	require.NoError(t, err)
+	assert.True(t, next.StartTime.Equal(slot.EndTime), "seconds of noise don't leave a gap between back-to-back slots")
+
+	t.Setenv("SLOT_PRECISION", "15m")
+	slot, err = scheduler.UpdateTimeSlot(slot.ID, CreateTimeSlotRequest{StartTime: start.Add(7 * time.Minute), EndTime: start.Add(68 * time.Minute)})
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 13, 10, 0, 0, 0, time.UTC), slot.StartTime)
+	assert.Equal(t, time.Date(2025, 1, 13, 11, 0, 0, 0, time.UTC), slot.EndTime)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// presenceTTL is how long a viewer counts as present after their last
+// heartbeat
+const presenceTTL = 30 * time.Second
+
+// Viewer is someone with an event open
+type Viewer struct {
+	UserID   string    `json:"userId"`
+	Name     string    `json:"name"`
+	Editing  bool      `json:"editing"`
+	Since    time.Time `json:"since"`
+	LastSeen time.Time `json:"lastSeen"`
+	// BaseVersion is the event's updatedAt when the viewer loaded it
+	BaseVersion *time.Time `json:"baseVersion,omitempty"`
+}
+
+// PresenceState is who else has the event open, with warnings for the
+// caller about edits that would collide
+type PresenceState struct {
+	EventID  string   `json:"eventId"`
+	Viewers  []Viewer `json:"viewers"`
+	Warnings []string `json:"warnings"`
+}
+
+type PresenceRequest struct {
+	Editing     bool       `json:"editing"`
+	BaseVersion *time.Time `json:"baseVersion"`
+}
+
+// PresenceUpdate is sent down presence streams: the current viewers, or
+// notice that the event changed
+type PresenceUpdate struct {
+	Type    string    `json:"type"`
+	Viewers []Viewer  `json:"viewers,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// presenceRegistry tracks viewers per event and fans updates out to the
+// event's open streams
+type presenceRegistry struct {
+	mu      sync.Mutex
+	viewers map[string]map[string]Viewer
+	streams map[string]map[chan PresenceUpdate]bool
+}
+
+func newPresenceRegistry() *presenceRegistry {
+	return &presenceRegistry{
+		viewers: make(map[string]map[string]Viewer),
+		streams: make(map[string]map[chan PresenceUpdate]bool),
+	}
+}
+
+// Touch records a heartbeat, keeping when the viewer first arrived
+func (r *presenceRegistry) Touch(eventID string, viewer Viewer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	viewers := r.viewers[eventID]
+	if viewers == nil {
+		viewers = make(map[string]Viewer)
+		r.viewers[eventID] = viewers
+	}
+	if existing, ok := viewers[viewer.UserID]; ok {
+		viewer.Since = existing.Since
+	}
+	viewers[viewer.UserID] = viewer
+	r.notify(eventID, PresenceUpdate{Type: "presence", Viewers: r.list(eventID), At: viewer.LastSeen})
+}
+
+func (r *presenceRegistry) Leave(eventID, userID string, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.viewers[eventID][userID]; !ok {
+		return
+	}
+	delete(r.viewers[eventID], userID)
+	r.notify(eventID, PresenceUpdate{Type: "presence", Viewers: r.list(eventID), At: now})
+}
+
+// ForEvent returns the event's current viewers, longest present first
+func (r *presenceRegistry) ForEvent(eventID string) []Viewer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.list(eventID)
+}
+
+func (r *presenceRegistry) list(eventID string) []Viewer {
+	viewers := []Viewer{}
+	for _, viewer := range r.viewers[eventID] {
+		viewers = append(viewers, viewer)
+	}
+	sort.Slice(viewers, func(i, j int) bool {
+		if !viewers[i].Since.Equal(viewers[j].Since) {
+			return viewers[i].Since.Before(viewers[j].Since)
+		}
+		return viewers[i].UserID < viewers[j].UserID
+	})
+	return viewers
+}
+
+// Expire drops viewers whose heartbeats stopped, telling their streams
+func (r *presenceRegistry) Expire(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for eventID, viewers := range r.viewers {
+		changed := false
+		for id, viewer := range viewers {
+			if now.Sub(viewer.LastSeen) > presenceTTL {
+				delete(viewers, id)
+				changed = true
+			}
+		}
+		if len(viewers) == 0 {
+			delete(r.viewers, eventID)
+		}
+		if changed {
+			r.notify(eventID, PresenceUpdate{Type: "presence", Viewers: r.list(eventID), At: now})
+		}
+	}
+}
+
+// Subscribe opens a stream of the event's presence updates
+func (r *presenceRegistry) Subscribe(eventID string) chan PresenceUpdate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ch := make(chan PresenceUpdate, 8)
+	if r.streams[eventID] == nil {
+		r.streams[eventID] = make(map[chan PresenceUpdate]bool)
+	}
+	r.streams[eventID][ch] = true
+	return ch
+}
+
+func (r *presenceRegistry) Unsubscribe(eventID string, ch chan PresenceUpdate) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.streams[eventID], ch)
+	if len(r.streams[eventID]) == 0 {
+		delete(r.streams, eventID)
+	}
+}
+
+// Changed tells the event's streams it was modified, so open editors can
+// reload before saving over it
+func (r *presenceRegistry) Changed(eventID string, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notify(eventID, PresenceUpdate{Type: "changed", At: at})
+}
+
+// notify sends without blocking; a stream that has fallen behind misses
+// updates and catches up on the next one
+func (r *presenceRegistry) notify(eventID string, update PresenceUpdate) {
+	for ch := range r.streams[eventID] {
+		select {
+		case ch <- update:
+		default:
+		}
+	}
+}
+
+var presence = newPresenceRegistry()
+
+// expirePresence is the background job dropping viewers who went away
+func expirePresence(now time.Time) {
+	presence.Expire(now)
+}
+
+// publishChanges forwards modifications of an event to its presence streams
+func publishChanges(e DomainEvent) {
+	switch e.Type {
+	case EventUpdated, EventFinalized, EventCancelled, EventDeleted, TimeSlotCreated, TimeSlotUpdated, TimeSlotDeleted:
+		presence.Changed(e.EventID, e.OccurredAt)
+	}
+}
+
+// presenceFor builds the caller's view: the other viewers, plus warnings
+// when someone else is editing or the event changed after the caller
+// loaded it. Viewers not yet expired by the job but past their TTL are
+// left out.
+func presenceFor(event Event, userID string) PresenceState {
+	state := PresenceState{EventID: event.ID, Viewers: []Viewer{}, Warnings: []string{}}
+	now := clock.Now()
+	for _, viewer := range presence.ForEvent(event.ID) {
+		if now.Sub(viewer.LastSeen) > presenceTTL {
+			continue
+		}
+		if viewer.UserID == userID {
+			if viewer.Editing && viewer.BaseVersion != nil && event.UpdatedAt.After(*viewer.BaseVersion) {
+				state.Warnings = append(state.Warnings, "This event changed since you opened it; reload before saving")
+			}
+			continue
+		}
+		state.Viewers = append(state.Viewers, viewer)
+		if viewer.Editing {
+			state.Warnings = append(state.Warnings, viewer.Name+" is also editing this event")
+		}
+	}
+	return state
+}
+
+// Presence handlers. Clients heartbeat with PUT while the event is open,
+// at least every 30 seconds, and follow the stream for live updates.
+func putPresence(c *gin.Context) {
+	user, _ := currentUser(c)
+	event, err := contextScheduler(c).GetEvent(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	var req PresenceRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	now := clock.Now()
+	presence.Touch(event.ID, Viewer{
+		UserID:      user.ID,
+		Name:        displayName(user.ID),
+		Editing:     req.Editing,
+		Since:       now,
+		LastSeen:    now,
+		BaseVersion: req.BaseVersion,
+	})
+	c.JSON(http.StatusOK, presenceFor(event, user.ID))
+}
+
+func getPresence(c *gin.Context) {
+	user, _ := currentUser(c)
+	event, err := contextScheduler(c).GetEvent(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, presenceFor(event, user.ID))
+}
+
+func deletePresence(c *gin.Context) {
+	user, _ := currentUser(c)
+	presence.Leave(c.Param("eventId"), user.ID, clock.Now())
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// streamPresence sends the event's presence updates as server-sent events,
+// starting with the current viewers
+func streamPresence(c *gin.Context) {
+	event, err := contextScheduler(c).GetEvent(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	updates := presence.Subscribe(event.ID)
+	defer presence.Unsubscribe(event.ID, updates)
+
+	c.Header("Cache-Control", "no-cache")
+	started := false
+	c.Stream(func(w io.Writer) bool {
+		if !started {
+			started = true
+			c.SSEvent("presence", PresenceUpdate{Type: "presence", Viewers: presence.ForEvent(event.ID), At: clock.Now()})
+			return true
+		}
+		select {
+		case update := <-updates:
+			c.SSEvent(update.Type, update)
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
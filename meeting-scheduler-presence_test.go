@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresenceWarnsBeforeEditsCollide(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	fake := newFakeClock(time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	previousClock, previousPresence := clock, presence
+	clock, presence = fake, newPresenceRegistry()
+	t.Cleanup(func() { clock, presence = previousClock, previousPresence })
+
+	ada := User{ID: "ada", Name: "Ada", Role: RoleOrganizer}
+	bob := User{ID: "bob", Name: "Bob", Role: RoleOrganizer}
+	users.Save(ada)
+	users.Save(bob)
+	event, err := currentScheduler().CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	stream := presence.Subscribe(event.ID)
+	defer presence.Unsubscribe(event.ID, stream)
+
+	heartbeat := func(user User, body string) PresenceState {
+		token, err := issueSessionToken(user)
+		require.NoError(t, err)
+		req, _ := http.NewRequest(http.MethodPut, "/api/v1/events/"+event.ID+"/presence", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var state PresenceState
+		decodeJSON(t, w, &state)
+		return state
+	}
+	base := event.UpdatedAt.Format(time.RFC3339Nano)
+
+	state := heartbeat(ada, `{"editing": true, "baseVersion": "`+base+`"}`)
+	assert.Empty(t, state.Viewers)
+	assert.Empty(t, state.Warnings)
+	state = heartbeat(bob, `{"editing": true, "baseVersion": "`+base+`"}`)
+	require.Len(t, state.Viewers, 1)
+	assert.Equal(t, "ada", state.Viewers[0].UserID)
+	assert.Equal(t, []string{"Ada is also editing this event"}, state.Warnings)
+	update := <-stream
+	assert.Equal(t, "presence", update.Type)
+	update = <-stream
+	assert.Len(t, update.Viewers, 2)
+
+	fake.Advance(time.Second)
+	_, err = currentScheduler().SetProtectedWindowOverride(event.ID, true)
+	require.NoError(t, err)
+	presence.Changed(event.ID, fake.Now())
+	assert.Equal(t, "changed", (<-stream).Type)
+	state = heartbeat(ada, `{"editing": true, "baseVersion": "`+base+`"}`)
+	assert.Equal(t, []string{"This event changed since you opened it; reload before saving", "Bob is also editing this event"}, state.Warnings)
+	<-stream
+
+	fake.Advance(presenceTTL + time.Second)
+	presence.Expire(fake.Now())
+	assert.Empty(t, presence.ForEvent(event.ID))
+	assert.Empty(t, (<-stream).Viewers)
+}
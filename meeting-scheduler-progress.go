@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response completion states
+const (
+	ProgressComplete = "complete"
+	ProgressPartial  = "partial"
+	ProgressNone     = "none"
+)
+
+// ParticipantProgress is how far one participant is through the poll
+type ParticipantProgress struct {
+	UserID        string `json:"userId"`
+	Status        string `json:"status"`
+	AnsweredSlots int    `json:"answeredSlots"`
+	// MissingQuestions are required intake questions still unanswered
+	MissingQuestions []string `json:"missingQuestions,omitempty"`
+}
+
+// EventProgress summarizes responses to an event's poll.
+// CompletionPercentage is the share of participant/slot pairs answered.
+type EventProgress struct {
+	EventID              string                `json:"eventId"`
+	TotalSlots           int                   `json:"totalSlots"`
+	Participants         []ParticipantProgress `json:"participants"`
+	Complete             int                   `json:"complete"`
+	Partial              int                   `json:"partial"`
+	None                 int                   `json:"none"`
+	CompletionPercentage float64               `json:"completionPercentage"`
+}
+
+// Progress reports per-participant completion for an event. Participants
+// are its invitees plus anyone who has responded, as for recommendations;
+// invitees who haven't started are the ones to nudge.
+func (s *Scheduler) Progress(eventID string) (EventProgress, error) {
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return EventProgress{}, err
+	}
+	slots, err := s.store.ListTimeSlots(eventID)
+	if err != nil {
+		return EventProgress{}, err
+	}
+	availabilityList, err := s.store.ListAvailability(eventID)
+	if err != nil {
+		return EventProgress{}, err
+	}
+
+	slotIDs := map[string]bool{}
+	for _, slot := range slots {
+		slotIDs[slot.ID] = true
+	}
+	answered := map[string]map[string]bool{}
+	for _, id := range event.Invitees {
+		answered[id] = map[string]bool{}
+	}
+	for _, avail := range availabilityList {
+		if answered[avail.UserID] == nil {
+			answered[avail.UserID] = map[string]bool{}
+		}
+		if slotIDs[avail.TimeSlotID] {
+			answered[avail.UserID][avail.TimeSlotID] = true
+		}
+	}
+
+	progress := EventProgress{EventID: eventID, TotalSlots: len(slots), Participants: []ParticipantProgress{}}
+	totalAnswered := 0
+	for userID, userSlots := range answered {
+		p := ParticipantProgress{UserID: userID, AnsweredSlots: len(userSlots)}
+		response, _ := intakeAnswers.Get(eventID, userID)
+		for _, q := range event.Questions {
+			if q.Required && response.Answers[q.ID] == "" {
+				p.MissingQuestions = append(p.MissingQuestions, q.ID)
+			}
+		}
+		switch {
+		case p.AnsweredSlots == 0:
+			p.Status = ProgressNone
+			progress.None++
+		case p.AnsweredSlots == len(slots) && len(p.MissingQuestions) == 0:
+			p.Status = ProgressComplete
+			progress.Complete++
+		default:
+			p.Status = ProgressPartial
+			progress.Partial++
+		}
+		totalAnswered += p.AnsweredSlots
+		progress.Participants = append(progress.Participants, p)
+	}
+	sort.Slice(progress.Participants, func(i, j int) bool {
+		return progress.Participants[i].UserID < progress.Participants[j].UserID
+	})
+	if pairs := len(slots) * len(answered); pairs > 0 {
+		progress.CompletionPercentage = float64(totalAnswered) / float64(pairs) * 100
+	}
+	return progress, nil
+}
+
+func getEventProgress(c *gin.Context) {
+	progress, err := contextScheduler(c).Progress(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, progress)
+}
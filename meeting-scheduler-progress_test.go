@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressTracksCompletion(t *testing.T) {
+	resetIntake(t)
+	scheduler, fake := newTestScheduler(t)
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Retro", OrganizerID: "ada", RequiredDuration: 30, Invitees: []string{"bob", "cy", "dee"}})
+	require.NoError(t, err)
+	var slots []TimeSlot
+	for i := 0; i < 2; i++ {
+		start := fake.Now().Add(time.Duration(24+i) * time.Hour)
+		slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+		require.NoError(t, err)
+		slots = append(slots, slot)
+	}
+	for _, slot := range slots {
+		_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+		require.NoError(t, err)
+	}
+	_, err = scheduler.SubmitAvailability(event.ID, "cy", UserAvailabilityRequest{TimeSlotID: slots[0].ID, Status: "unavailable"})
+	require.NoError(t, err)
+
+	progress, err := scheduler.Progress(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, progress.TotalSlots)
+	assert.Equal(t, []ParticipantProgress{
+		{UserID: "bob", Status: ProgressComplete, AnsweredSlots: 2},
+		{UserID: "cy", Status: ProgressPartial, AnsweredSlots: 1},
+		{UserID: "dee", Status: ProgressNone},
+	}, progress.Participants)
+	assert.Equal(t, 1, progress.Complete)
+	assert.Equal(t, 1, progress.Partial)
+	assert.Equal(t, 1, progress.None)
+	assert.InDelta(t, 50.0, progress.CompletionPercentage, 0.001)
+}
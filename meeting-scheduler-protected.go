@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// protectedWindowPenalty is subtracted from a recommendation's score for
+// slots that fall in a protected window
+const protectedWindowPenalty = 25.0
+
+// ProtectedWindow is a recurring organization-wide period kept free of
+// meetings, such as lunch ("12:00"-"13:00" every day) or focus time
+// (Fridays "09:00"-"12:00")
+type ProtectedWindow struct {
+	Name string `json:"name"`
+	// Days are lowercase three-letter weekdays ("mon"); empty means every day
+	Days     []string `json:"days,omitempty"`
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	TimeZone string   `json:"timeZone,omitempty"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseClock parses an "HH:MM" time of day into an offset from midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// atClock is the wall-clock time offset from midnight on day, in day's
+// location. Unlike day.Add(offset) it keeps "14:00" at 14:00 on days when
+// the clocks change.
+func atClock(day time.Time, offset time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, int(offset), day.Location())
+}
+
+func (w ProtectedWindow) validate() error {
+	if w.Name == "" {
+		return fmt.Errorf("protected window name is required")
+	}
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return err
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return err
+	}
+	if end <= start {
+		return fmt.Errorf("protected window %q must end after it starts", w.Name)
+	}
+	for _, day := range w.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("invalid weekday %q", day)
+		}
+	}
+	if _, err := time.LoadLocation(w.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone %q", w.TimeZone)
+	}
+	return nil
+}
+
+func (w ProtectedWindow) appliesOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Overlaps reports whether [start, end) intersects any occurrence of the
+// window. Windows are validated when saved, so parse errors mean no match.
+func (w ProtectedWindow) Overlaps(start, end time.Time) bool {
+	loc, err := time.LoadLocation(w.TimeZone)
+	if err != nil {
+		return false
+	}
+	from, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	to, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+
+	local := start.In(loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, -1)
+	for !day.After(end) {
+		if w.appliesOn(day.Weekday()) {
+			windowStart, windowEnd := atClock(day, from), atClock(day, to)
+			if windowStart.Before(end) && start.Before(windowEnd) {
+				return true
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return false
+}
+
+// Protected window handlers
+func updateProtectedWindows(c *gin.Context) {
+	org, ok := organizations.Get(c.Param("orgId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	var req []ProtectedWindow
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, window := range req {
+		if err := window.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	org.ProtectedWindows = req
+	org.UpdatedAt = clock.Now()
+	organizations.Save(org)
+	c.JSON(http.StatusOK, org.ProtectedWindows)
+}
+
+type ProtectedWindowOverrideRequest struct {
+	Allow bool `json:"allow"`
+}
+
+// overrideProtectedWindows lets an admin allow an event to be scheduled
+// inside their organization's protected windows
+func overrideProtectedWindows(c *gin.Context) {
+	scheduler := contextScheduler(c)
+	event, err := scheduler.GetEvent(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if user, _ := currentUser(c); user.OrgID != event.OrgID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this organization"})
+		return
+	}
+
+	var req ProtectedWindowOverrideRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err = scheduler.SetProtectedWindowOverride(event.ID, req.Allow)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, event)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtectedWindowOverlaps(t *testing.T) {
+	lunch := ProtectedWindow{Name: "Lunch", Start: "12:00", End: "13:00", TimeZone: "America/New_York"}
+	require.NoError(t, lunch.validate())
+
+	// 12:30 in New York is 17:30 UTC in January
+	noonET := time.Date(2025, 1, 15, 17, 30, 0, 0, time.UTC)
+	assert.True(t, lunch.Overlaps(noonET, noonET.Add(time.Hour)))
+	assert.False(t, lunch.Overlaps(noonET.Add(-3*time.Hour), noonET.Add(-90*time.Minute)))
+	// Lunch stays at noon on the day the clocks go forward
+	dstNoon := time.Date(2025, 3, 9, 16, 30, 0, 0, time.UTC)
+	assert.True(t, lunch.Overlaps(dstNoon, dstNoon.Add(15*time.Minute)))
+
+	focus := ProtectedWindow{Name: "Focus", Days: []string{"fri"}, Start: "09:00", End: "12:00"}
+	friday := time.Date(2025, 1, 17, 10, 0, 0, 0, time.UTC)
+	assert.True(t, focus.Overlaps(friday, friday.Add(time.Hour)))
+	assert.False(t, focus.Overlaps(friday.AddDate(0, 0, -1), friday.AddDate(0, 0, -1).Add(time.Hour)))
+
+	assert.Error(t, ProtectedWindow{Name: "Bad", Start: "13:00", End: "12:00"}.validate())
+	assert.Error(t, ProtectedWindow{Name: "Bad", Days: []string{"someday"}, Start: "09:00", End: "10:00"}.validate())
+}
+
+func TestProtectedWindowsPenalizeRecommendations(t *testing.T) {
+	resetDirectory(t)
+	scheduler, _ := newTestScheduler(t)
+	organizations.Save(Organization{ID: "acme", ProtectedWindows: []ProtectedWindow{{Name: "Lunch", Start: "12:00", End: "13:00"}}})
+	users.Save(User{ID: "ada", OrgID: "acme", Role: RoleOrganizer})
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 60})
+	require.NoError(t, err)
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	lunch, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: day.Add(12 * time.Hour), EndTime: day.Add(13 * time.Hour)})
+	require.NoError(t, err)
+	afternoon, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: day.Add(14 * time.Hour), EndTime: day.Add(15 * time.Hour)})
+	require.NoError(t, err)
+
+	// Everyone can make lunch; only one of two can make the afternoon
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: lunch.ID, Status: "available"})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "cal", UserAvailabilityRequest{TimeSlotID: lunch.ID, Status: "available"})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "cal", UserAvailabilityRequest{TimeSlotID: afternoon.ID, Status: "available"})
+	require.NoError(t, err)
+
+	recommendations, err := scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	require.Len(t, recommendations, 2)
+	assert.Equal(t, lunch.ID, recommendations[0].TimeSlot.ID)
+	assert.Equal(t, 75.0, recommendations[0].Score)
+	assert.Equal(t, []string{`Inside protected window "Lunch"`}, recommendations[0].Warnings)
+
+	generated, err := scheduler.GenerateTimeSlots(event.ID, GenerateTimeSlotsRequest{From: day.Add(11 * time.Hour), To: day.Add(14 * time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, generated, 2)
+	assert.Equal(t, day.Add(11*time.Hour), generated[0].StartTime)
+	assert.Equal(t, day.Add(13*time.Hour), generated[1].StartTime)
+
+	_, err = scheduler.SetProtectedWindowOverride(event.ID, true)
+	require.NoError(t, err)
+	recommendations, err = scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, recommendations[0].Score)
+	assert.Empty(t, recommendations[0].Warnings)
+}
+
+// FuzzProtectedWindowOverlaps checks that widening a slot never loses an
+// overlap and, for UTC windows, which have no clock changes to blur the
+// answer, Overlaps against the slot's minutes one at a time
+func FuzzProtectedWindowOverlaps(f *testing.F) {
+	f.Add("12:00", "13:00", uint8(0), "America/New_York", int64(29000000), uint16(60))
+	f.Add("09:00", "12:00", uint8(1<<5), "", int64(29000000), uint16(600))
+	f.Add("01:00", "03:00", uint8(1), "America/New_York", int64(29012700), uint16(240))
+	f.Fuzz(func(t *testing.T, start, end string, dayMask uint8, zone string, startMinute int64, minutes uint16) {
+		w := ProtectedWindow{Name: "Fuzz", Start: start, End: end, TimeZone: zone}
+		for name, day := range weekdays {
+			if dayMask&(1<<day) != 0 {
+				w.Days = append(w.Days, name)
+			}
+		}
+		if w.validate() != nil {
+			return
+		}
+		// Whole minutes between 1970 and 2070, for up to three days
+		const span = 100 * 365 * 24 * 60
+		slotStart := time.Unix((startMinute%span+span)%span*60, 0).UTC()
+		slotEnd := slotStart.Add(time.Duration(minutes%(3*24*60)+1) * time.Minute)
+
+		got := w.Overlaps(slotStart, slotEnd)
+		if got && !w.Overlaps(slotStart.Add(-time.Hour), slotEnd.Add(time.Hour)) {
+			t.Fatalf("widening %s-%s lost the overlap", slotStart, slotEnd)
+		}
+		if zone != "" && zone != "UTC" {
+			return
+		}
+		from, _ := parseClock(start)
+		to, _ := parseClock(end)
+		want := false
+		for at := slotStart; at.Before(slotEnd) && !want; at = at.Add(time.Minute) {
+			clock := time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute
+			want = w.appliesOn(at.Weekday()) && clock >= from && clock < to
+		}
+		if got != want {
+			t.Fatalf("Overlaps(%s, %s) = %v, want %v", slotStart, slotEnd, got, want)
+		}
+	})
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Push platforms
+const (
+	PlatformWebPush = "webpush"
+	PlatformFCM     = "fcm"
+)
+
+// pushKinds are the messages worth interrupting someone for
+var pushKinds = map[string]bool{MessagePollOpened: true, MessageFinalized: true, MessageReminder: true, MessageBroadcast: true}
+
+// maxDevicesPerUser bounds registrations; the oldest device drops off
+const maxDevicesPerUser = 10
+
+// ErrDeviceGone means the push service no longer knows the device, so its
+// registration should be dropped
+var ErrDeviceGone = errors.New("push device is no longer registered")
+
+// Device is a browser or app registered for push notifications. Token is
+// the FCM registration token, or the Web Push subscription endpoint.
+type Device struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Platform  string    `json:"platform"`
+	Token     string    `json:"token"`
+	Name      string    `json:"name,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type RegisterDeviceRequest struct {
+	Platform string `json:"platform" binding:"required"`
+	Token    string `json:"token" binding:"required"`
+	Name     string `json:"name"`
+}
+
+// deviceRegistry is the in-memory push device store, oldest first per user
+type deviceRegistry struct {
+	mu      sync.RWMutex
+	devices map[string][]Device
+}
+
+func newDeviceRegistry() *deviceRegistry {
+	return &deviceRegistry{devices: make(map[string][]Device)}
+}
+
+// Register adds the device, or returns the existing registration when the
+// user already registered its token
+func (r *deviceRegistry) Register(device Device) Device {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := r.devices[device.UserID]
+	for _, existing := range list {
+		if existing.Platform == device.Platform && existing.Token == device.Token {
+			return existing
+		}
+	}
+	list = append(list, device)
+	if len(list) > maxDevicesPerUser {
+		list = list[len(list)-maxDevicesPerUser:]
+	}
+	r.devices[device.UserID] = list
+	return device
+}
+
+func (r *deviceRegistry) ForUser(userID string) []Device {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Device{}, r.devices[userID]...)
+}
+
+// Delete removes one of the user's devices, reporting whether it existed
+func (r *deviceRegistry) Delete(userID, id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := r.devices[userID]
+	for i, device := range list {
+		if device.ID == id {
+			r.devices[userID] = append(list[:i:i], list[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+var devices = newDeviceRegistry()
+
+// PushSender delivers a message to one device
+type PushSender interface {
+	Push(device Device, msg Message) error
+}
+
+// webPushSender sends payload-less Web Push messages authenticated with
+// VAPID (RFC 8292). The service worker fetches the notification feed when
+// woken, so nothing has to be encrypted for the browser. The private key
+// is read from the VAPID_PRIVATE_KEY secret on every send.
+type webPushSender struct {
+	subject string
+	client  *http.Client
+}
+
+func (s *webPushSender) Push(device Device, msg Message) error {
+	key, err := vapidKey()
+	if err != nil {
+		return err
+	}
+	endpoint, err := url.Parse(device.Token)
+	if err != nil {
+		return err
+	}
+	now := clock.Now()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": endpoint.Scheme + "://" + endpoint.Host,
+		"exp": now.Add(12 * time.Hour).Unix(),
+		"sub": s.subject,
+	}).SignedString(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, device.Token, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("vapid t=%s, k=%s", token, vapidPublicKey(key)))
+	req.Header.Set("TTL", "86400")
+	urgency := "normal"
+	if msg.Kind != MessagePollOpened {
+		urgency = "high"
+	}
+	req.Header.Set("Urgency", urgency)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return pushStatus("web push", resp)
+}
+
+// vapidKey decodes the VAPID_PRIVATE_KEY secret, a base64url P-256 scalar
+// as generated by the usual web-push tooling
+func vapidKey() (*ecdsa.PrivateKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(secrets.Get("VAPID_PRIVATE_KEY"), "="))
+	if err != nil {
+		return nil, fmt.Errorf("VAPID_PRIVATE_KEY: %w", err)
+	}
+	private, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("VAPID_PRIVATE_KEY: %w", err)
+	}
+	public := private.PublicKey().Bytes()
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(public[1:33]),
+			Y:     new(big.Int).SetBytes(public[33:]),
+		},
+		D: new(big.Int).SetBytes(raw),
+	}, nil
+}
+
+// vapidPublicKey is the uncompressed public key, base64url encoded, which
+// browsers take as the applicationServerKey when subscribing
+func vapidPublicKey(key *ecdsa.PrivateKey) string {
+	public := make([]byte, 65)
+	public[0] = 4
+	key.X.FillBytes(public[1:33])
+	key.Y.FillBytes(public[33:])
+	return base64.RawURLEncoding.EncodeToString(public)
+}
+
+// fcmSender sends notifications through the FCM HTTP v1 API. The OAuth
+// access token is read from the FCM_ACCESS_TOKEN secret on every send, so
+// whatever refreshes it only has to update the secret.
+type fcmSender struct {
+	baseURL   string
+	projectID string
+	client    *http.Client
+}
+
+func (s *fcmSender) Push(device Device, msg Message) error {
+	first, _, _ := strings.Cut(msg.Body, "\n\n")
+	payload, err := json.Marshal(gin.H{"message": gin.H{
+		"token":        device.Token,
+		"notification": gin.H{"title": msg.Subject, "body": first},
+		"data":         gin.H{"eventId": msg.EventID, "kind": msg.Kind},
+	}})
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/v1/projects/%s/messages:send", s.baseURL, url.PathEscape(s.projectID))
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+secrets.Get("FCM_ACCESS_TOKEN"))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return pushStatus("fcm", resp)
+}
+
+// pushStatus turns a push service response into an error. Both services
+// answer 404 or 410 for expired registrations.
+func pushStatus(service string, resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrDeviceGone
+	case resp.StatusCode >= 300:
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s: %s", service, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// pushSendersFromEnv configures Web Push when VAPID_SUBJECT is set, with
+// the VAPID_PRIVATE_KEY secret, and FCM when FCM_PROJECT_ID is set, with
+// the FCM_ACCESS_TOKEN secret
+func pushSendersFromEnv() map[string]PushSender {
+	senders := map[string]PushSender{}
+	client := &http.Client{Timeout: 10 * time.Second}
+	if subject := getenv("VAPID_SUBJECT", ""); subject != "" {
+		senders[PlatformWebPush] = &webPushSender{subject: subject, client: client}
+	}
+	if project := getenv("FCM_PROJECT_ID", ""); project != "" {
+		senders[PlatformFCM] = &fcmSender{
+			baseURL:   getenv("FCM_API_URL", "https://fcm.googleapis.com"),
+			projectID: project,
+			client:    client,
+		}
+	}
+	return senders
+}
+
+// pushSenders are the configured push platforms
+var pushSenders = map[string]PushSender{}
+
+// pushNotifier pushes new polls, confirmations and reminders to every
+// device the recipient registered, dropping devices the push service has
+// forgotten
+type pushNotifier struct{}
+
+func (pushNotifier) Notify(msg Message) error {
+	if !pushKinds[msg.Kind] {
+		return nil
+	}
+	var errs []error
+	for _, device := range devices.ForUser(msg.To.ID) {
+		sender, ok := pushSenders[device.Platform]
+		if !ok {
+			continue
+		}
+		err := sender.Push(device, msg)
+		if errors.Is(err, ErrDeviceGone) {
+			log.Printf("Dropping expired %s device %s of user %s", device.Platform, device.ID, device.UserID)
+			devices.Delete(device.UserID, device.ID)
+		} else if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// notifyPollOpened asks the invitees of a new event for their availability
+func notifyPollOpened(e DomainEvent) {
+	event, ok := e.Payload.(Event)
+	if !ok {
+		return
+	}
+	var messages []Message
+	for _, id := range event.Invitees {
+		user, ok := users.Get(id)
+		if !ok || id == event.OrganizerID {
+			continue
+		}
+		body := fmt.Sprintf("%s would like your availability for %s.", displayName(event.OrganizerID), event.Title)
+		if user.Role == RoleGuest {
+			body += "\n\nRespond here: " + guestInvites.Issue(event.ID, user.ID, clock.Now()).URL()
+		}
+		messages = append(messages, Message{
+			To:      user,
+			OrgID:   event.OrgID,
+			EventID: event.ID,
+			Kind:    MessagePollOpened,
+			Subject: "Invitation: " + event.Title,
+			Body:    body,
+		})
+	}
+	notifyAll(messages)
+}
+
+// Device handlers act on the signed-in user's registrations
+func listMyDevices(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	c.JSON(http.StatusOK, devices.ForUser(user.ID))
+}
+
+func registerMyDevice(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	var req RegisterDeviceRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Platform != PlatformWebPush && req.Platform != PlatformFCM {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Platform must be webpush or fcm"})
+		return
+	}
+	if _, ok := pushSenders[req.Platform]; !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Push is not configured for " + req.Platform})
+		return
+	}
+	if req.Platform == PlatformWebPush {
+		if endpoint, err := url.Parse(req.Token); err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Web Push token must be the subscription's https endpoint"})
+			return
+		}
+	}
+
+	device := devices.Register(Device{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		Platform:  req.Platform,
+		Token:     req.Token,
+		Name:      req.Name,
+		CreatedAt: clock.Now(),
+	})
+	c.JSON(http.StatusCreated, device)
+}
+
+func deleteMyDevice(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	if !devices.Delete(user.ID, c.Param("deviceId")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// getVAPIDPublicKey gives web clients the key to subscribe with
+func getVAPIDPublicKey(c *gin.Context) {
+	if _, ok := pushSenders[PlatformWebPush]; !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Web Push is not configured"})
+		return
+	}
+	key, err := vapidKey()
+	if err != nil {
+		log.Printf("Invalid VAPID key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Web Push key is misconfigured"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"publicKey": vapidPublicKey(key)})
+}
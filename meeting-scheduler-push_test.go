@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingPushSender struct {
+	pushed map[string][]Message
+	gone   map[string]bool
+}
+
+func (s *recordingPushSender) Push(device Device, msg Message) error {
+	if s.gone[device.Token] {
+		return ErrDeviceGone
+	}
+	s.pushed[device.Token] = append(s.pushed[device.Token], msg)
+	return nil
+}
+
+func resetDevices(t *testing.T) {
+	previous := devices
+	devices = newDeviceRegistry()
+	t.Cleanup(func() { devices = previous })
+}
+
+func TestWebPushSenderSignsWithVAPID(t *testing.T) {
+	private, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	t.Setenv("VAPID_PRIVATE_KEY", base64.RawURLEncoding.EncodeToString(private.Bytes()))
+	publicKey := base64.RawURLEncoding.EncodeToString(private.PublicKey().Bytes())
+
+	status := http.StatusCreated
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		require.True(t, strings.HasPrefix(auth, "vapid t="))
+		token, k, ok := strings.Cut(strings.TrimPrefix(auth, "vapid t="), ", k=")
+		require.True(t, ok)
+		assert.Equal(t, publicKey, k)
+
+		key, err := vapidKey()
+		require.NoError(t, err)
+		claims := jwt.MapClaims{}
+		_, err = jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) { return &key.PublicKey, nil },
+			jwt.WithValidMethods([]string{"ES256"}))
+		require.NoError(t, err)
+		assert.Equal(t, "mailto:ops@acme.test", claims["sub"])
+		assert.Equal(t, "86400", r.Header.Get("TTL"))
+		assert.Equal(t, "high", r.Header.Get("Urgency"))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sender := &webPushSender{subject: "mailto:ops@acme.test", client: server.Client()}
+	device := Device{Platform: PlatformWebPush, Token: server.URL + "/push/abc"}
+	require.NoError(t, sender.Push(device, Message{Kind: MessageFinalized}))
+	status = http.StatusGone
+	assert.ErrorIs(t, sender.Push(device, Message{Kind: MessageFinalized}), ErrDeviceGone)
+}
+
+func TestDeviceRegistrationAndPushDelivery(t *testing.T) {
+	resetDirectory(t)
+	resetDevices(t)
+	router := newTestRouter(t)
+	sender := &recordingPushSender{pushed: map[string][]Message{}, gone: map[string]bool{}}
+	previous := pushSenders
+	pushSenders = map[string]PushSender{PlatformFCM: sender}
+	t.Cleanup(func() { pushSenders = previous })
+
+	bob := User{ID: "bob", Role: RoleMember}
+	users.Save(bob)
+	token, err := issueSessionToken(bob)
+	require.NoError(t, err)
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send(http.MethodPost, "/api/v1/users/me/devices", `{"platform": "webpush", "token": "https://push.example/abc"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "web push isn't configured")
+	w = send(http.MethodPost, "/api/v1/users/me/devices", `{"platform": "fcm", "token": "phone-token", "name": "Pixel"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var phone Device
+	decodeJSON(t, w, &phone)
+	w = send(http.MethodPost, "/api/v1/users/me/devices", `{"platform": "fcm", "token": "phone-token"}`)
+	var again Device
+	decodeJSON(t, w, &again)
+	assert.Equal(t, phone.ID, again.ID, "re-registering a token is idempotent")
+	send(http.MethodPost, "/api/v1/users/me/devices", `{"platform": "fcm", "token": "tablet-token"}`)
+
+	require.NoError(t, pushNotifier{}.Notify(Message{To: bob, Kind: MessagePollOpened, Subject: "Invitation: Kickoff"}))
+	require.NoError(t, pushNotifier{}.Notify(Message{To: bob, Subject: "Quorum reached"}))
+	assert.Len(t, sender.pushed["phone-token"], 1)
+	assert.Len(t, sender.pushed["tablet-token"], 1)
+
+	sender.gone["tablet-token"] = true
+	require.NoError(t, pushNotifier{}.Notify(Message{To: bob, Kind: MessageFinalized}))
+	var listed []Device
+	decodeJSON(t, send(http.MethodGet, "/api/v1/users/me/devices", ""), &listed)
+	require.Len(t, listed, 1, "expired devices are dropped")
+	assert.Equal(t, "Pixel", listed[0].Name)
+
+	assert.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/api/v1/users/me/devices/"+phone.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodDelete, "/api/v1/users/me/devices/"+phone.ID, "").Code)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// urgentKinds are delivered even during quiet hours
+var urgentKinds = map[string]bool{MessageReminder: true}
+
+// QuietHours is a daily window, in the user's time zone, during which
+// non-urgent notifications wait. End before Start spans midnight, e.g.
+// 22:00 to 07:00.
+type QuietHours struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+func (q QuietHours) validate() error {
+	start, err := parseClock(q.Start)
+	if err != nil {
+		return err
+	}
+	end, err := parseClock(q.End)
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return fmt.Errorf("quiet hours must not start and end at the same time")
+	}
+	if _, err := time.LoadLocation(q.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone %q", q.TimeZone)
+	}
+	return nil
+}
+
+// Until reports whether now falls in quiet hours and, if so, when they
+// end. Quiet hours are validated when saved, so parse errors mean none.
+func (q QuietHours) Until(now time.Time) (time.Time, bool) {
+	loc, err := time.LoadLocation(q.TimeZone)
+	if err != nil {
+		return time.Time{}, false
+	}
+	startOffset, err := parseClock(q.Start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	endOffset, err := parseClock(q.End)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if endOffset <= startOffset {
+		endOffset += 24 * time.Hour
+	}
+
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	// Yesterday's window may still be running past midnight
+	for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+		start, end := day.Add(startOffset), day.Add(endOffset)
+		if !now.Before(start) && now.Before(end) {
+			return end.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+type deferredMessage struct {
+	msg       Message
+	deliverAt time.Time
+}
+
+// deferredRegistry holds messages waiting for their recipient's quiet
+// hours to end
+type deferredRegistry struct {
+	mu       sync.Mutex
+	messages []deferredMessage
+}
+
+func newDeferredRegistry() *deferredRegistry {
+	return &deferredRegistry{}
+}
+
+func (r *deferredRegistry) Add(msg Message, deliverAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, deferredMessage{msg: msg, deliverAt: deliverAt})
+}
+
+// TakeDue removes and returns the messages due by now, in queue order
+func (r *deferredRegistry) TakeDue(now time.Time) []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var due []Message
+	kept := r.messages[:0]
+	for _, deferred := range r.messages {
+		if deferred.deliverAt.After(now) {
+			kept = append(kept, deferred)
+		} else {
+			due = append(due, deferred.msg)
+		}
+	}
+	r.messages = kept
+	return due
+}
+
+var deferredNotifications = newDeferredRegistry()
+
+// quietNotifier holds back non-urgent messages to users in their quiet
+// hours, queueing them for the deferred-notifications job
+type quietNotifier struct {
+	next Notifier
+}
+
+func (q quietNotifier) Notify(msg Message) error {
+	if quiet := msg.To.Settings.QuietHours; quiet != nil && !urgentKinds[msg.Kind] {
+		if until, ok := quiet.Until(clock.Now()); ok {
+			deferredNotifications.Add(msg, until)
+			return nil
+		}
+	}
+	return q.next.Notify(msg)
+}
+
+// deliverDeferredNotifications is the background job sending messages
+// whose recipients' quiet hours are over. Recipients are looked up again,
+// so messages follow changed settings and contact details.
+func deliverDeferredNotifications(now time.Time) {
+	for _, msg := range deferredNotifications.TakeDue(now) {
+		user, ok := users.Get(msg.To.ID)
+		if !ok {
+			continue
+		}
+		msg.To = user
+		if err := notifier.Notify(msg); err != nil {
+			log.Printf("Failed to deliver deferred notification to %s: %v", user.ID, err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuietHoursSpanMidnightInUserTimeZone(t *testing.T) {
+	quiet := QuietHours{Start: "22:00", End: "07:00", TimeZone: "Europe/Berlin"}
+	require.NoError(t, quiet.validate())
+
+	// 23:30 in Berlin, an hour ahead of UTC in January
+	until, ok := quiet.Until(time.Date(2025, 1, 12, 22, 30, 0, 0, time.UTC))
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2025, 1, 13, 6, 0, 0, 0, time.UTC), until)
+	until, ok = quiet.Until(time.Date(2025, 1, 13, 5, 0, 0, 0, time.UTC))
+	assert.True(t, ok, "after midnight, yesterday's window is still running")
+	assert.Equal(t, time.Date(2025, 1, 13, 6, 0, 0, 0, time.UTC), until)
+	_, ok = quiet.Until(time.Date(2025, 1, 13, 6, 0, 0, 0, time.UTC))
+	assert.False(t, ok)
+
+	assert.Error(t, QuietHours{Start: "22:00", End: "22:00"}.validate())
+	assert.Error(t, QuietHours{Start: "22:00", End: "07:00", TimeZone: "Mars/Olympus"}.validate())
+}
+
+func TestQuietNotifierDefersNonUrgentMessages(t *testing.T) {
+	resetDirectory(t)
+	fake := newFakeClock(time.Date(2025, 1, 12, 23, 0, 0, 0, time.UTC))
+	recorder := &recordingNotifier{}
+	previousClock, previousNotifier, previousDeferred := clock, notifier, deferredNotifications
+	clock, deferredNotifications = fake, newDeferredRegistry()
+	notifier = quietNotifier{next: recorder}
+	t.Cleanup(func() { clock, notifier, deferredNotifications = previousClock, previousNotifier, previousDeferred })
+
+	bob := User{ID: "bob", Email: "bob@acme.test", Settings: UserSettings{QuietHours: &QuietHours{Start: "22:00", End: "07:00"}}}
+	users.Save(bob)
+	require.NoError(t, notifier.Notify(Message{To: bob, Kind: MessageFinalized, Subject: "Confirmed: Kickoff"}))
+	require.NoError(t, notifier.Notify(Message{To: bob, Kind: MessageReminder, Subject: "Starting soon: Standup"}))
+	require.Len(t, recorder.messages, 1, "reminders are urgent")
+	assert.Equal(t, "Starting soon: Standup", recorder.messages[0].Subject)
+
+	bob.Email = "robert@acme.test"
+	users.Save(bob)
+	fake.Advance(7*time.Hour + 59*time.Minute)
+	deliverDeferredNotifications(fake.Now())
+	assert.Len(t, recorder.messages, 1)
+	fake.Advance(time.Minute)
+	deliverDeferredNotifications(fake.Now())
+	require.Len(t, recorder.messages, 2)
+	assert.Equal(t, "Confirmed: Kickoff", recorder.messages[1].Subject)
+	assert.Equal(t, "robert@acme.test", recorder.messages[1].To.Email, "recipients are looked up again on delivery")
+}
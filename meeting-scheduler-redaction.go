@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Masks substituted for personal data
+const (
+	redactedEmail = "[email]"
+	redactedName  = "[name]"
+	redactedText  = "[redacted]"
+)
+
+// maxRedactionPatterns keeps per-write redaction cheap
+const maxRedactionPatterns = 50
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// RedactionPolicy adds an organization's own patterns, such as employee
+// or case numbers, to the emails, names and free text always masked
+type RedactionPolicy struct {
+	// Patterns are regular expressions (RE2 syntax) masked wherever the
+	// organization's data is redacted, and in logs
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+func (p RedactionPolicy) validate() error {
+	if len(p.Patterns) > maxRedactionPatterns {
+		return fmt.Errorf("at most %d redaction patterns are allowed", maxRedactionPatterns)
+	}
+	for _, pattern := range p.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid redaction pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// compiledPatterns caches patterns by source, since logs redact every line
+var compiledPatterns sync.Map
+
+func compilePattern(pattern string) *regexp.Regexp {
+	if re, ok := compiledPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		// Validated when saved; one loaded from a file may still be bad
+		return nil
+	}
+	compiledPatterns.Store(pattern, re)
+	return re
+}
+
+// redactor masks emails, the given names and the given patterns
+type redactor struct {
+	patterns []*regexp.Regexp
+	// names are masked longest first, so full names go before first names
+	names []string
+}
+
+func newRedactor(names []string, orgs ...Organization) redactor {
+	var r redactor
+	for _, org := range orgs {
+		for _, pattern := range org.Redaction.Patterns {
+			if re := compilePattern(pattern); re != nil {
+				r.patterns = append(r.patterns, re)
+			}
+		}
+	}
+	for _, name := range names {
+		if strings.TrimSpace(name) != "" {
+			r.names = append(r.names, name)
+		}
+	}
+	sort.Slice(r.names, func(i, j int) bool { return len(r.names[i]) > len(r.names[j]) })
+	return r
+}
+
+// orgRedactor redacts the organization's data for callers who may not see
+// it: its members' names are masked along with its patterns
+func orgRedactor(orgID string) redactor {
+	var names []string
+	for _, user := range users.List(orgID) {
+		names = append(names, user.Name)
+	}
+	org, _ := organizations.Get(orgID)
+	return newRedactor(names, org)
+}
+
+// logRedactor masks every organization's patterns, since a log line isn't
+// tied to one. Names are left to the patterns: nothing logs them.
+func logRedactor() redactor {
+	return newRedactor(nil, organizations.List()...)
+}
+
+func (r redactor) String(s string) string {
+	s = emailPattern.ReplaceAllString(s, redactedEmail)
+	for _, name := range r.names {
+		s = strings.ReplaceAll(s, name, redactedName)
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, redactedText)
+	}
+	return s
+}
+
+// Text masks free text entirely, since anything could be in it
+func (r redactor) Text(s string) string {
+	if s == "" {
+		return s
+	}
+	return redactedText
+}
+
+// redactingWriter redacts log output on its way to w
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (rw redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, logRedactor().String(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// redactErrorReport masks an error's message before it's sent to a client
+func redactErrorReport(err error) string {
+	return logRedactor().String(err.Error())
+}
+
+// seesPersonalData reports whether the caller may export the event's
+// personal data unredacted: its organizer and admins may
+func seesPersonalData(c *gin.Context, event Event) bool {
+	user, ok := currentUser(c)
+	return ok && (user.ID == event.OrganizerID || roleAtLeast(user.Role, RoleAdmin))
+}
+
+// redacted masks an event export for a caller who isn't privileged:
+// comments and text answers entirely, and personal data elsewhere
+func (e EventExport) redacted(r redactor) EventExport {
+	textQuestions := map[string]bool{}
+	for _, q := range e.Event.Questions {
+		textQuestions[q.ID] = q.Kind == QuestionText
+	}
+	e.Event.Description = r.String(e.Event.Description)
+
+	answers := make([]IntakeResponse, len(e.Answers))
+	for i, response := range e.Answers {
+		response.Answers = redactAnswers(r, response.Answers, textQuestions)
+		answers[i] = response
+	}
+	e.Answers = answers
+
+	commentList := make([]Comment, len(e.Comments))
+	for i, comment := range e.Comments {
+		comment.Body = r.Text(comment.Body)
+		if comment.Broadcast != nil {
+			broadcast := *comment.Broadcast
+			broadcast.Subject = r.Text(broadcast.Subject)
+			comment.Broadcast = &broadcast
+		}
+		commentList[i] = comment
+	}
+	e.Comments = commentList
+
+	audit := make([]TimelineEntry, len(e.Audit))
+	for i, entry := range e.Audit {
+		entry.Summary = r.String(entry.Summary)
+		audit[i] = entry
+	}
+	e.Audit = audit
+	return e
+}
+
+// redactAnswers masks text answers; choice answers are one of the
+// organizer's own options, so they stay
+func redactAnswers(r redactor, answers map[string]string, textQuestions map[string]bool) map[string]string {
+	redacted := make(map[string]string, len(answers))
+	for id, answer := range answers {
+		if textQuestions[id] {
+			answer = r.Text(answer)
+		}
+		redacted[id] = answer
+	}
+	return redacted
+}
+
+func updateRedactionPolicy(c *gin.Context) {
+	org, ok := organizations.Get(c.Param("orgId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+	var req RedactionPolicy
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org.Redaction = req
+	org.UpdatedAt = clock.Now()
+	organizations.Save(org)
+	c.JSON(http.StatusOK, req)
+}
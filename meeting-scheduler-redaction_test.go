@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactorMasksEmailsNamesAndOrgPatterns(t *testing.T) {
+	resetDirectory(t)
+	organizations.Save(Organization{ID: "acme", Redaction: RedactionPolicy{Patterns: []string{`EMP-\d+`}}})
+	users.Save(User{ID: "ada", OrgID: "acme", Name: "Ada Lovelace", Role: RoleOrganizer})
+	users.Save(User{ID: "bob", OrgID: "acme", Name: "Ada", Role: RoleMember})
+
+	r := orgRedactor("acme")
+	assert.Equal(t, "[name] ([email]) and [name] raised [redacted]",
+		r.String("Ada Lovelace (ada@acme.test) and Ada raised EMP-1234"))
+	assert.Equal(t, "[redacted]", r.Text("Allergic to nuts"))
+	assert.Equal(t, "", r.Text(""))
+
+	assert.Error(t, RedactionPolicy{Patterns: []string{"("}}.validate())
+	assert.NoError(t, RedactionPolicy{Patterns: []string{`EMP-\d+`}}.validate())
+}
+
+func TestLogsAndErrorReportsAreRedacted(t *testing.T) {
+	resetDirectory(t)
+	organizations.Save(Organization{ID: "acme", Redaction: RedactionPolicy{Patterns: []string{`EMP-\d+`}}})
+
+	var buf bytes.Buffer
+	logger := log.New(redactingWriter{w: &buf}, "", 0)
+	logger.Printf("Failed to send alert to ada@acme.test for EMP-77: %v", errors.New("mailbox full"))
+	assert.Equal(t, "Failed to send alert to [email] for [redacted]: mailbox full\n", buf.String())
+
+	assert.Equal(t, "smtp: 550 [email] unknown", redactErrorReport(errors.New("smtp: 550 bob@acme.test unknown")))
+}
+
+func TestEventExportRedactedForOtherOrganizers(t *testing.T) {
+	resetDirectory(t)
+	users.Save(User{ID: "bob", OrgID: "acme", Name: "Bob Stone", Role: RoleMember})
+	export := EventExport{
+		Event:    Event{ID: "e1", OrgID: "acme", Description: "Ask Bob Stone", Questions: []Question{{ID: "diet", Kind: QuestionText}, {ID: "room", Kind: QuestionChoice}}},
+		Answers:  []IntakeResponse{{UserID: "bob", Answers: map[string]string{"diet": "Vegan", "room": "A"}}},
+		Comments: []Comment{{ID: "c1", Body: "Call me on 555", Broadcast: &Broadcast{Subject: "Venue"}}},
+		Audit:    []TimelineEntry{{Summary: "bob@acme.test responded"}},
+	}
+
+	redacted := export.redacted(orgRedactor("acme"))
+	assert.Equal(t, "Ask [name]", redacted.Event.Description)
+	assert.Equal(t, map[string]string{"diet": "[redacted]", "room": "A"}, redacted.Answers[0].Answers)
+	assert.Equal(t, "[redacted]", redacted.Comments[0].Body)
+	assert.Equal(t, "[redacted]", redacted.Comments[0].Broadcast.Subject)
+	assert.Equal(t, "[email] responded", redacted.Audit[0].Summary)
+	assert.Equal(t, "Call me on 555", export.Comments[0].Body, "the original is untouched")
+	assert.Equal(t, "Venue", export.Comments[0].Broadcast.Subject)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// meetingReminderLead is how long before a confirmed meeting its
+// participants are reminded
+const meetingReminderLead = 15 * time.Minute
+
+// reminderRegistry remembers which meetings have been reminded about, by
+// event and final slot, so a rescheduled meeting is reminded again
+type reminderRegistry struct {
+	mu   sync.Mutex
+	sent map[string]bool
+}
+
+func newReminderRegistry() *reminderRegistry {
+	return &reminderRegistry{sent: make(map[string]bool)}
+}
+
+// Claim reports whether the reminder still needs sending, marking it sent
+func (r *reminderRegistry) Claim(eventID, slotID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := eventID + "/" + slotID
+	if r.sent[key] {
+		return false
+	}
+	r.sent[key] = true
+	return true
+}
+
+var meetingReminders = newReminderRegistry()
+
+// sendMeetingReminders is the background job reminding participants of
+// meetings about to start
+func sendMeetingReminders(now time.Time) {
+	if err := currentScheduler().remindUpcoming(now); err != nil {
+		log.Printf("Meeting reminders failed, will retry: %v", err)
+	}
+}
+
+// remindUpcoming notifies the participants of every confirmed meeting
+// starting within the reminder lead
+func (s *Scheduler) remindUpcoming(now time.Time) error {
+	eventList, err := s.store.ListEvents()
+	if err != nil {
+		return err
+	}
+	for _, event := range eventList {
+		if event.Status != "finalized" || event.FinalTimeSlotID == "" {
+			continue
+		}
+		slot, err := s.store.GetTimeSlot(event.FinalTimeSlotID)
+		if err != nil {
+			continue
+		}
+		if !slot.StartTime.After(now) || slot.StartTime.After(now.Add(meetingReminderLead)) {
+			continue
+		}
+		if !meetingReminders.Claim(event.ID, slot.ID) {
+			continue
+		}
+		s.remind(event, slot, now)
+	}
+	return nil
+}
+
+func (s *Scheduler) remind(event Event, slot TimeSlot, now time.Time) {
+	participants, err := eventParticipants(event)
+	if err != nil {
+		log.Printf("Failed to load participants for %s: %v", event.ID, err)
+		return
+	}
+	minutes := int(slot.StartTime.Sub(now).Round(time.Minute) / time.Minute)
+	messages := make([]Message, 0, len(participants))
+	for _, user := range participants {
+		msg := Message{
+			To:      user,
+			OrgID:   event.OrgID,
+			EventID: event.ID,
+			Kind:    MessageReminder,
+			Subject: "Starting soon: " + event.Title,
+			Body: fmt.Sprintf("%s starts in %d minutes, at %s.",
+				event.Title, minutes, slot.StartTime.UTC().Format("15:04 MST")),
+		}
+		if event.Location != "" {
+			msg.Body += " Location: " + event.Location
+		}
+		messages = append(messages, msg)
+	}
+	notifyAll(messages)
+}
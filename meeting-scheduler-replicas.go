@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// replicatedStore sends writes and transactions to the primary and spreads
+// reads across read replicas in turn. When a replica fails a read, or
+// hasn't got the record yet, the primary answers instead. Lists can still
+// lag the primary, so reads that must see a write just made belong in the
+// same transaction, which stays on the primary.
+type replicatedStore struct {
+	primary  Store
+	replicas []Store
+	next     *atomic.Uint64
+}
+
+func newReplicatedStore(primary Store, replicas []Store) *replicatedStore {
+	return &replicatedStore{primary: primary, replicas: replicas, next: &atomic.Uint64{}}
+}
+
+// WithContext binds the primary and every replica that does I/O to ctx
+func (s *replicatedStore) WithContext(ctx context.Context) Store {
+	bind := func(target Store) Store {
+		if binder, ok := target.(contextBinder); ok {
+			return binder.WithContext(ctx)
+		}
+		return target
+	}
+	bound := &replicatedStore{primary: bind(s.primary), replicas: make([]Store, len(s.replicas)), next: s.next}
+	for i, replica := range s.replicas {
+		bound.replicas[i] = bind(replica)
+	}
+	return bound
+}
+
+// read runs op on the next replica, falling back to the primary unless it
+// succeeds or the request itself has given up
+func (s *replicatedStore) read(op func(store Store) error) error {
+	if len(s.replicas) == 0 {
+		return op(s.primary)
+	}
+	replica := s.replicas[s.next.Add(1)%uint64(len(s.replicas))]
+	err := op(replica)
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if !errors.Is(err, ErrNotFound) {
+		log.Printf("Read replica failed, reading from the primary: %v", err)
+	}
+	return op(s.primary)
+}
+
+func (s *replicatedStore) CreateEvent(event Event) error {
+	return s.primary.CreateEvent(event)
+}
+
+func (s *replicatedStore) GetEvent(id string) (event Event, err error) {
+	err = s.read(func(store Store) (err error) {
+		event, err = store.GetEvent(id)
+		return err
+	})
+	return event, err
+}
+
+func (s *replicatedStore) ListEvents() (list []Event, err error) {
+	err = s.read(func(store Store) (err error) {
+		list, err = store.ListEvents()
+		return err
+	})
+	return list, err
+}
+
+func (s *replicatedStore) UpdateEvent(event Event) error {
+	return s.primary.UpdateEvent(event)
+}
+
+func (s *replicatedStore) CompareAndSwapEvent(event, expected Event) error {
+	return s.primary.CompareAndSwapEvent(event, expected)
+}
+
+func (s *replicatedStore) DeleteEvent(id string) error {
+	return s.primary.DeleteEvent(id)
+}
+
+func (s *replicatedStore) CreateTimeSlot(slot TimeSlot) error {
+	return s.primary.CreateTimeSlot(slot)
+}
+
+func (s *replicatedStore) GetTimeSlot(id string) (slot TimeSlot, err error) {
+	err = s.read(func(store Store) (err error) {
+		slot, err = store.GetTimeSlot(id)
+		return err
+	})
+	return slot, err
+}
+
+func (s *replicatedStore) ListTimeSlots(eventID string) (list []TimeSlot, err error) {
+	err = s.read(func(store Store) (err error) {
+		list, err = store.ListTimeSlots(eventID)
+		return err
+	})
+	return list, err
+}
+
+func (s *replicatedStore) UpdateTimeSlot(slot TimeSlot) error {
+	return s.primary.UpdateTimeSlot(slot)
+}
+
+func (s *replicatedStore) DeleteTimeSlot(id string) error {
+	return s.primary.DeleteTimeSlot(id)
+}
+
+func (s *replicatedStore) CreateAvailability(avail UserAvailability) error {
+	return s.primary.CreateAvailability(avail)
+}
+
+func (s *replicatedStore) FindAvailability(eventID, userID, timeslotID string) (avail UserAvailability, err error) {
+	err = s.read(func(store Store) (err error) {
+		avail, err = store.FindAvailability(eventID, userID, timeslotID)
+		return err
+	})
+	return avail, err
+}
+
+func (s *replicatedStore) ListAvailability(eventID string) (list []UserAvailability, err error) {
+	err = s.read(func(store Store) (err error) {
+		list, err = store.ListAvailability(eventID)
+		return err
+	})
+	return list, err
+}
+
+func (s *replicatedStore) ListUserAvailability(eventID, userID string) (list []UserAvailability, err error) {
+	err = s.read(func(store Store) (err error) {
+		list, err = store.ListUserAvailability(eventID, userID)
+		return err
+	})
+	return list, err
+}
+
+func (s *replicatedStore) UpdateAvailability(avail UserAvailability) error {
+	return s.primary.UpdateAvailability(avail)
+}
+
+func (s *replicatedStore) DeleteAvailability(id string) error {
+	return s.primary.DeleteAvailability(id)
+}
+
+// WithTransaction runs entirely on the primary, reads included
+func (s *replicatedStore) WithTransaction(fn func(tx Store) error) error {
+	return s.primary.WithTransaction(fn)
+}
+
+// ArchiveAvailability archives on the primary, if it keeps an archive
+func (s *replicatedStore) ArchiveAvailability(cutoff time.Time) (int64, error) {
+	if archiver, ok := s.primary.(availabilityArchiver); ok {
+		return archiver.ArchiveAvailability(cutoff)
+	}
+	return 0, nil
+}
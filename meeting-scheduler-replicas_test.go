@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStore counts the reads and writes it passes on, failing reads
+// with err when it is set
+type countingStore struct {
+	Store
+	reads, writes int
+	err           error
+}
+
+func (s *countingStore) GetEvent(id string) (Event, error) {
+	s.reads++
+	if s.err != nil {
+		return Event{}, s.err
+	}
+	return s.Store.GetEvent(id)
+}
+
+func (s *countingStore) CreateEvent(event Event) error {
+	s.writes++
+	return s.Store.CreateEvent(event)
+}
+
+func TestReplicatedStoreRoutesReads(t *testing.T) {
+	resetStorage(t)
+	primary := &countingStore{Store: store}
+	// Both replicas see the same maps, as if fully caught up
+	replicas := []*countingStore{{Store: store}, {Store: store}}
+	s := newReplicatedStore(primary, []Store{replicas[0], replicas[1]})
+
+	require.NoError(t, s.CreateEvent(Event{ID: "e1", Title: "Sync"}))
+	assert.Equal(t, 1, primary.writes)
+	for i := 0; i < 4; i++ {
+		_, err := s.GetEvent("e1")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 0, primary.reads)
+	assert.Equal(t, 2, replicas[0].reads, "reads take turns")
+	assert.Equal(t, 2, replicas[1].reads)
+
+	replicas[0].err, replicas[1].err = errors.New("connection refused"), ErrNotFound
+	for i := 0; i < 2; i++ {
+		event, err := s.GetEvent("e1")
+		require.NoError(t, err)
+		assert.Equal(t, "Sync", event.Title)
+	}
+	assert.Equal(t, 2, primary.reads, "failed and lagging reads go to the primary")
+
+	replicaReads := replicas[0].reads + replicas[1].reads
+	err := s.WithTransaction(func(tx Store) error {
+		_, err := tx.GetEvent("e1")
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, replicaReads, replicas[0].reads+replicas[1].reads, "transactions stay on the primary")
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReplicatedChange is one committed transaction in the change feed
+type ReplicatedChange struct {
+	Seq     uint64        `json:"seq"`
+	At      time.Time     `json:"at"`
+	Changes []StoredEvent `json:"changes"`
+}
+
+// ChangeFeedPage is what a standby reads from the feed. Epoch changes
+// whenever the primary restarts, which starts the numbering over.
+type ChangeFeedPage struct {
+	Epoch   string             `json:"epoch"`
+	Latest  uint64             `json:"latest"`
+	Changes []ReplicatedChange `json:"changes"`
+}
+
+// changeFeed keeps the most recent committed transactions in memory, in
+// commit order, for a warm standby to pull
+type changeFeed struct {
+	mu      sync.RWMutex
+	epoch   string
+	latest  uint64
+	size    int
+	entries []ReplicatedChange
+}
+
+func newChangeFeed(size int) *changeFeed {
+	return &changeFeed{epoch: randomToken(), size: size}
+}
+
+// changes is the primary's change feed, set when REPLICATION_FEED_SIZE is
+var changes *changeFeed
+
+// changeFeedFromEnv returns nil, keeping no feed, unless
+// REPLICATION_FEED_SIZE says how many transactions to keep
+func changeFeedFromEnv() *changeFeed {
+	size := getenvInt("REPLICATION_FEED_SIZE", 0)
+	if size <= 0 {
+		return nil
+	}
+	return newChangeFeed(size)
+}
+
+// Append adds a committed transaction, dropping the oldest beyond size
+func (f *changeFeed) Append(at time.Time, stored []StoredEvent) {
+	if len(stored) == 0 {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latest++
+	f.entries = append(f.entries, ReplicatedChange{Seq: f.latest, At: at, Changes: stored})
+	if len(f.entries) > f.size {
+		f.entries = f.entries[len(f.entries)-f.size:]
+	}
+}
+
+// Head returns the feed's epoch and the seq of its newest transaction
+func (f *changeFeed) Head() (string, uint64) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.epoch, f.latest
+}
+
+// Since returns up to limit transactions after seq after. It reports
+// false when some of those have already been dropped.
+func (f *changeFeed) Since(after uint64, limit int) (ChangeFeedPage, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	page := ChangeFeedPage{Epoch: f.epoch, Latest: f.latest, Changes: []ReplicatedChange{}}
+	if after >= f.latest {
+		return page, after == f.latest
+	}
+	oldest := f.entries[0].Seq
+	if after+1 < oldest {
+		return page, false
+	}
+	start := int(after + 1 - oldest)
+	end := min(len(f.entries), start+limit)
+	page.Changes = append(page.Changes, f.entries[start:end]...)
+	return page, true
+}
+
+// feedStore adds every transaction committed through it to a change
+// feed. Reads pass straight through. Writes are serialized, so the feed's
+// order is the order they were committed in.
+type feedStore struct {
+	Store
+	feed *changeFeed
+	mu   *sync.Mutex
+}
+
+func newFeedStore(inner Store, feed *changeFeed) *feedStore {
+	return &feedStore{Store: inner, feed: feed, mu: &sync.Mutex{}}
+}
+
+func (s *feedStore) WithContext(ctx context.Context) Store {
+	binder, ok := s.Store.(contextBinder)
+	if !ok {
+		return s
+	}
+	return &feedStore{Store: binder.WithContext(ctx), feed: s.feed, mu: s.mu}
+}
+
+func (s *feedStore) WithTransaction(fn func(tx Store) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var recording *recordingTx
+	err := s.Store.WithTransaction(func(tx Store) error {
+		recording = &recordingTx{Store: tx, at: clock.Now()}
+		return fn(recording)
+	})
+	if err != nil {
+		return err
+	}
+	s.feed.Append(recording.at, recording.stored)
+	return nil
+}
+
+// ArchiveAvailability archives in the store underneath; archiving isn't
+// replicated, since the standby archives on its own once promoted
+func (s *feedStore) ArchiveAvailability(cutoff time.Time) (int64, error) {
+	if archiver, ok := s.Store.(availabilityArchiver); ok {
+		return archiver.ArchiveAvailability(cutoff)
+	}
+	return 0, nil
+}
+
+func (s *feedStore) CreateEvent(event Event) error {
+	return s.WithTransaction(func(tx Store) error { return tx.CreateEvent(event) })
+}
+
+func (s *feedStore) UpdateEvent(event Event) error {
+	return s.WithTransaction(func(tx Store) error { return tx.UpdateEvent(event) })
+}
+
+func (s *feedStore) CompareAndSwapEvent(event, expected Event) error {
+	return s.WithTransaction(func(tx Store) error { return tx.CompareAndSwapEvent(event, expected) })
+}
+
+func (s *feedStore) DeleteEvent(id string) error {
+	return s.WithTransaction(func(tx Store) error { return tx.DeleteEvent(id) })
+}
+
+func (s *feedStore) CreateTimeSlot(slot TimeSlot) error {
+	return s.WithTransaction(func(tx Store) error { return tx.CreateTimeSlot(slot) })
+}
+
+func (s *feedStore) UpdateTimeSlot(slot TimeSlot) error {
+	return s.WithTransaction(func(tx Store) error { return tx.UpdateTimeSlot(slot) })
+}
+
+func (s *feedStore) DeleteTimeSlot(id string) error {
+	return s.WithTransaction(func(tx Store) error { return tx.DeleteTimeSlot(id) })
+}
+
+func (s *feedStore) CreateAvailability(avail UserAvailability) error {
+	return s.WithTransaction(func(tx Store) error { return tx.CreateAvailability(avail) })
+}
+
+func (s *feedStore) UpdateAvailability(avail UserAvailability) error {
+	return s.WithTransaction(func(tx Store) error { return tx.UpdateAvailability(avail) })
+}
+
+func (s *feedStore) DeleteAvailability(id string) error {
+	return s.WithTransaction(func(tx Store) error { return tx.DeleteAvailability(id) })
+}
+
+// replicateChange applies one change from the primary. Changes may be
+// applied twice, after a standby restarts, so creating something that
+// exists overwrites it and removing something missing succeeds.
+func replicateChange(tx Store, e StoredEvent) error {
+	switch {
+	case e.Type == StoredEventCreated && e.Event != nil:
+		if _, err := tx.GetEvent(e.ID); err == nil {
+			return tx.UpdateEvent(*e.Event)
+		}
+	case e.Type == StoredSlotProposed && e.TimeSlot != nil:
+		if _, err := tx.GetTimeSlot(e.ID); err == nil {
+			return tx.UpdateTimeSlot(*e.TimeSlot)
+		}
+	case e.Type == StoredResponseRecorded && e.Availability != nil:
+		avail := *e.Availability
+		if _, err := tx.FindAvailability(avail.EventID, avail.UserID, avail.TimeSlotID); err == nil {
+			return tx.UpdateAvailability(avail)
+		}
+	}
+	err := applyStoredEvent(tx, e)
+	removal := e.Type == StoredEventRemoved || e.Type == StoredSlotWithdrawn || e.Type == StoredResponseWithdrawn
+	if removal && errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// ReplicationStatus is this instance's part in replication. A standby's
+// AppliedChangeAt is when the newest change it has was made on the
+// primary: failing over now would lose at most what came after it.
+type ReplicationStatus struct {
+	// Role is primary, standby or promoted
+	Role            string     `json:"role"`
+	Primary         string     `json:"primary,omitempty"`
+	Epoch           string     `json:"epoch,omitempty"`
+	Applied         uint64     `json:"applied"`
+	Latest          uint64     `json:"latest"`
+	AppliedChangeAt *time.Time `json:"appliedChangeAt,omitempty"`
+	LastSyncAt      *time.Time `json:"lastSyncAt,omitempty"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// standbyReplicator pulls the primary's change feed into the local store
+// until it is promoted
+type standbyReplicator struct {
+	primary  string
+	token    string
+	client   *http.Client
+	interval time.Duration
+	batch    int
+
+	mu     sync.Mutex
+	status ReplicationStatus
+	stop   chan struct{}
+}
+
+// standby is set when this instance replicates from REPLICATE_FROM
+var standby *standbyReplicator
+
+// standbyFromEnv returns nil unless REPLICATE_FROM names the primary's
+// base URL
+func standbyFromEnv() *standbyReplicator {
+	primary := strings.TrimSuffix(getenv("REPLICATE_FROM", ""), "/")
+	if primary == "" {
+		return nil
+	}
+	return newStandbyReplicator(primary, secrets.Get("REPLICATION_TOKEN"), getenvDuration("REPLICATION_INTERVAL", time.Second))
+}
+
+func newStandbyReplicator(primary, token string, interval time.Duration) *standbyReplicator {
+	return &standbyReplicator{
+		primary:  primary,
+		token:    token,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		interval: interval,
+		batch:    500,
+		status:   ReplicationStatus{Role: "standby", Primary: primary},
+		stop:     make(chan struct{}),
+	}
+}
+
+// Run pulls changes every interval until the standby is promoted
+func (r *standbyReplicator) Run() {
+	for {
+		if err := r.Sync(); err != nil {
+			log.Printf("Replicating from %s: %v", r.primary, err)
+		}
+		select {
+		case <-r.stop:
+			return
+		case <-clock.After(r.interval):
+		}
+	}
+}
+
+// errFeedGap means the primary has dropped changes the standby lacks
+var errFeedGap = errors.New("the primary no longer has every change this standby is missing; re-seed it from a copy of the primary's data")
+
+// Sync applies every change the primary has that the standby doesn't
+func (r *standbyReplicator) Sync() error {
+	for {
+		r.mu.Lock()
+		applied, epoch, role := r.status.Applied, r.status.Epoch, r.status.Role
+		r.mu.Unlock()
+		if role != "standby" {
+			return nil
+		}
+
+		page, err := r.fetch(applied)
+		if epoch != "" && page.Epoch != "" && page.Epoch != epoch {
+			// The primary restarted and numbers its feed afresh; all it
+			// committed before was pulled already or is lost with it
+			log.Printf("Primary %s restarted; following its new change feed", r.primary)
+			r.mu.Lock()
+			r.status.Applied = 0
+			r.mu.Unlock()
+			page, err = r.fetch(0)
+		}
+		if err != nil {
+			r.recordError(err)
+			return err
+		}
+
+		for _, change := range page.Changes {
+			err := store.WithTransaction(func(tx Store) error {
+				for _, e := range change.Changes {
+					if err := replicateChange(tx, e); err != nil {
+						return fmt.Errorf("change %d: %w", change.Seq, err)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				r.recordError(err)
+				return err
+			}
+			at := change.At
+			r.mu.Lock()
+			r.status.Applied, r.status.AppliedChangeAt = change.Seq, &at
+			r.mu.Unlock()
+		}
+
+		now := clock.Now()
+		r.mu.Lock()
+		r.status.Epoch, r.status.Latest, r.status.LastSyncAt, r.status.Error = page.Epoch, page.Latest, &now, ""
+		caughtUp := r.status.Applied >= page.Latest
+		r.mu.Unlock()
+		if caughtUp || len(page.Changes) == 0 {
+			return nil
+		}
+	}
+}
+
+func (r *standbyReplicator) fetch(after uint64) (ChangeFeedPage, error) {
+	query := url.Values{"after": {strconv.FormatUint(after, 10)}, "limit": {strconv.Itoa(r.batch)}}
+	req, err := http.NewRequest(http.MethodGet, r.primary+"/replication/changes?"+query.Encode(), nil)
+	if err != nil {
+		return ChangeFeedPage{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+r.token)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return ChangeFeedPage{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusGone {
+		return ChangeFeedPage{}, fmt.Errorf("change feed answered %s", resp.Status)
+	}
+	var page ChangeFeedPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return ChangeFeedPage{}, err
+	}
+	if resp.StatusCode == http.StatusGone {
+		// Still carries the epoch, since a restarted primary answers so too
+		return page, errFeedGap
+	}
+	return page, nil
+}
+
+func (r *standbyReplicator) recordError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Error = err.Error()
+}
+
+func (r *standbyReplicator) Status() ReplicationStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Promote stops replicating and makes this instance writable. It reports
+// false if it was promoted already.
+func (r *standbyReplicator) Promote() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status.Role != "standby" {
+		return false
+	}
+	r.status.Role = "promoted"
+	close(r.stop)
+	return true
+}
+
+// standbyMaintenance is what a standby tells callers trying to change
+// anything
+const standbyMaintenance = "This is a standby replica and is read-only; changes go to the primary region."
+
+// replicationAuth checks the REPLICATION_TOKEN bearer token standbys
+// send. Without a token set the feed isn't served.
+func replicationAuth(c *gin.Context) {
+	expected := secrets.Get("REPLICATION_TOKEN")
+	token, hasBearer := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if changes == nil || expected == "" {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "This instance keeps no change feed"})
+		return
+	}
+	if !hasBearer || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid replication token"})
+		return
+	}
+	c.Next()
+}
+
+// Replication handlers
+func listReplicatedChanges(c *gin.Context) {
+	after, err := strconv.ParseUint(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "after must be a sequence number"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "500"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+		return
+	}
+	page, ok := changes.Since(after, min(limit, 5000))
+	if !ok {
+		c.JSON(http.StatusGone, gin.H{"error": "Changes after " + strconv.FormatUint(after, 10) + " are no longer kept", "epoch": page.Epoch, "latest": page.Latest})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func getReplicationStatus(c *gin.Context) {
+	switch {
+	case standby != nil:
+		c.JSON(http.StatusOK, standby.Status())
+	case changes != nil:
+		epoch, latest := changes.Head()
+		c.JSON(http.StatusOK, ReplicationStatus{Role: "primary", Epoch: epoch, Latest: latest, Applied: latest})
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Replication isn't configured"})
+	}
+}
+
+func promoteStandby(c *gin.Context) {
+	if standby == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This instance isn't a standby"})
+		return
+	}
+	if !standby.Promote() {
+		c.JSON(http.StatusConflict, gin.H{"error": "This standby has been promoted already"})
+		return
+	}
+	maintenance.Set(MaintenanceStatus{}, clock.Now())
+	log.Printf("Promoted to primary; stopped replicating from %s", standby.primary)
+	c.JSON(http.StatusOK, standby.Status())
+}
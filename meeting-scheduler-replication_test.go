@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeFeedSinceAndTrim(t *testing.T) {
+	feed := newChangeFeed(2)
+	at := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	for _, id := range []string{"e1", "e2", "e3"} {
+		feed.Append(at, []StoredEvent{{Type: StoredEventRemoved, EventID: id, ID: id}})
+	}
+	feed.Append(at, nil)
+
+	page, ok := feed.Since(1, 10)
+	require.True(t, ok)
+	assert.Equal(t, uint64(3), page.Latest)
+	require.Len(t, page.Changes, 2)
+	assert.Equal(t, uint64(2), page.Changes[0].Seq)
+	assert.Equal(t, "e3", page.Changes[1].Changes[0].ID)
+
+	page, ok = feed.Since(2, 1)
+	require.True(t, ok)
+	require.Len(t, page.Changes, 1)
+	assert.Equal(t, uint64(3), page.Changes[0].Seq)
+
+	page, ok = feed.Since(3, 10)
+	assert.True(t, ok, "a caught-up standby has nothing to read")
+	assert.Empty(t, page.Changes)
+
+	_, ok = feed.Since(0, 10)
+	assert.False(t, ok, "the first change has been dropped")
+	_, ok = feed.Since(7, 10)
+	assert.False(t, ok, "a standby ahead of the feed followed another epoch")
+}
+
+func TestFeedStoreRecordsCommittedTransactionsOnly(t *testing.T) {
+	resetStorage(t)
+	feed := newChangeFeed(10)
+	s := newFeedStore(store, feed)
+
+	require.NoError(t, s.CreateEvent(Event{ID: "e1", Title: "Kickoff", OrganizerID: "u1", RequiredDuration: 30}))
+	err := s.WithTransaction(func(tx Store) error {
+		if err := tx.CreateEvent(Event{ID: "e2", Title: "Rolled back", OrganizerID: "u1", RequiredDuration: 30}); err != nil {
+			return err
+		}
+		return errors.New("abandoned")
+	})
+	require.Error(t, err)
+	require.NoError(t, s.WithTransaction(func(tx Store) error {
+		if err := tx.CreateTimeSlot(TimeSlot{ID: "s1", EventID: "e1"}); err != nil {
+			return err
+		}
+		return tx.CreateAvailability(UserAvailability{ID: "a1", EventID: "e1", UserID: "u2", TimeSlotID: "s1"})
+	}))
+
+	page, ok := feed.Since(0, 10)
+	require.True(t, ok)
+	require.Len(t, page.Changes, 2)
+	assert.Equal(t, []StoredEventType{StoredEventCreated}, storedTypes(page.Changes[0].Changes))
+	assert.Equal(t, []StoredEventType{StoredSlotProposed, StoredResponseRecorded}, storedTypes(page.Changes[1].Changes))
+}
+
+func TestStandbyAppliesPrimaryChanges(t *testing.T) {
+	resetStorage(t)
+	t.Setenv("REPLICATION_TOKEN", "standby-secret")
+	feed := newChangeFeed(10)
+	previous := changes
+	changes = feed
+	t.Cleanup(func() { changes = previous })
+
+	primary := newFeedStore(store, feed)
+	require.NoError(t, primary.CreateEvent(Event{ID: "e1", Title: "Kickoff", OrganizerID: "u1", RequiredDuration: 30}))
+	require.NoError(t, primary.CreateTimeSlot(TimeSlot{ID: "s1", EventID: "e1"}))
+	require.NoError(t, primary.CreateAvailability(UserAvailability{ID: "a1", EventID: "e1", UserID: "u2", TimeSlotID: "s1"}))
+	require.NoError(t, primary.DeleteAvailability("a1"))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	registerRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// The standby starts empty and pulls everything, twice over
+	resetStorage(t)
+	replicator := newStandbyReplicator(server.URL, "standby-secret", time.Second)
+	require.NoError(t, replicator.Sync())
+	replicator.mu.Lock()
+	replicator.status.Applied = 0
+	replicator.mu.Unlock()
+	require.NoError(t, replicator.Sync())
+
+	event, err := store.GetEvent("e1")
+	require.NoError(t, err)
+	assert.Equal(t, "Kickoff", event.Title)
+	_, err = store.GetTimeSlot("s1")
+	assert.NoError(t, err)
+	_, err = store.FindAvailability("e1", "u2", "s1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	status := replicator.Status()
+	assert.Equal(t, uint64(4), status.Applied)
+	assert.Equal(t, uint64(4), status.Latest)
+	assert.Empty(t, status.Error)
+
+	assert.True(t, replicator.Promote())
+	assert.False(t, replicator.Promote())
+	assert.Equal(t, "promoted", replicator.Status().Role)
+
+	wrongToken := newStandbyReplicator(server.URL, "guess", time.Second)
+	assert.Error(t, wrongToken.Sync())
+	assert.Contains(t, wrongToken.Status().Error, "401")
+}
+
+func TestChangeFeedEndpointAnswersGone(t *testing.T) {
+	router := newTestRouter(t)
+	t.Setenv("REPLICATION_TOKEN", "standby-secret")
+	previous := changes
+	changes = newChangeFeed(1)
+	t.Cleanup(func() { changes = previous })
+	for _, id := range []string{"e1", "e2"} {
+		changes.Append(time.Now(), []StoredEvent{{Type: StoredEventRemoved, EventID: id, ID: id}})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/replication/changes?after=0", nil)
+	req.Header.Set("Authorization", "Bearer standby-secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusGone, w.Code)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ErrNoPoolMemberFree means every member of a pool is busy at the claimed time
+var ErrNoPoolMemberFree = errors.New("no pool member free")
+
+// Pool is a group of interchangeable hosts, such as support engineers.
+// Requesters claim a time and the pool assigns one member to host it.
+type Pool struct {
+	ID              string    `json:"id"`
+	OrgID           string    `json:"orgId"`
+	Name            string    `json:"name"`
+	Members         []string  `json:"members"`
+	DurationMinutes int       `json:"durationMinutes"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+	// LastBookedAt records when each member was last assigned, driving the
+	// round-robin
+	LastBookedAt map[string]time.Time `json:"lastBookedAt,omitempty"`
+}
+
+type CreatePoolRequest struct {
+	Name            string   `json:"name" binding:"required"`
+	Members         []string `json:"members" binding:"required"`
+	DurationMinutes int      `json:"durationMinutes" binding:"required"`
+}
+
+type ClaimPoolSlotRequest struct {
+	StartTime time.Time `json:"startTime" binding:"required"`
+	Title     string    `json:"title"`
+}
+
+type PoolClaimResponse struct {
+	Event    Event    `json:"event"`
+	TimeSlot TimeSlot `json:"timeslot"`
+	HostID   string   `json:"hostId"`
+}
+
+// poolRegistry is the in-memory pool store. claimMu serializes claims so
+// two requesters can't be given the same member for the same time.
+type poolRegistry struct {
+	mu      sync.RWMutex
+	claimMu sync.Mutex
+	pools   map[string]Pool
+}
+
+func newPoolRegistry() *poolRegistry {
+	return &poolRegistry{pools: make(map[string]Pool)}
+}
+
+// Get returns the pool if it belongs to the organization
+func (r *poolRegistry) Get(orgID, id string) (Pool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	pool, ok := r.pools[id]
+	if !ok || pool.OrgID != orgID {
+		return Pool{}, false
+	}
+	return pool, true
+}
+
+func (r *poolRegistry) Save(pool Pool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pools[pool.ID] = pool
+}
+
+func (r *poolRegistry) Delete(orgID, id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pool, ok := r.pools[id]
+	if !ok || pool.OrgID != orgID {
+		return false
+	}
+	delete(r.pools, id)
+	return true
+}
+
+// List returns the organization's pools by name
+func (r *poolRegistry) List(orgID string) []Pool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	poolList := []Pool{}
+	for _, pool := range r.pools {
+		if pool.OrgID == orgID {
+			poolList = append(poolList, pool)
+		}
+	}
+	sort.Slice(poolList, func(i, j int) bool { return poolList[i].Name < poolList[j].Name })
+	return poolList
+}
+
+var pools = newPoolRegistry()
+
+// nextHost returns the eligible member booked least recently; members never
+// booked come first, in pool order
+func (p Pool) nextHost(eligible []string) string {
+	best := ""
+	for _, id := range eligible {
+		if best == "" || p.LastBookedAt[id].Before(p.LastBookedAt[best]) {
+			best = id
+		}
+	}
+	return best
+}
+
+// ClaimPoolSlot assigns the requester's meeting at start to the pool member
+// who is free then and was booked least recently, and books it
+func (s *Scheduler) ClaimPoolSlot(ctx context.Context, requester User, poolID string, req ClaimPoolSlotRequest) (PoolClaimResponse, error) {
+	pools.claimMu.Lock()
+	defer pools.claimMu.Unlock()
+
+	pool, ok := pools.Get(requester.OrgID, poolID)
+	if !ok {
+		return PoolClaimResponse{}, invalid("Pool not found")
+	}
+	start := req.StartTime
+	end := start.Add(time.Duration(pool.DurationMinutes) * time.Minute)
+
+	p, _, err := s.newPlanner(ctx, Event{OrganizerID: requester.ID, OrgID: requester.OrgID, Invitees: pool.Members}, start, end)
+	if err != nil {
+		return PoolClaimResponse{}, err
+	}
+	if violations := p.rules.violations(start, end); len(violations) > 0 {
+		return PoolClaimResponse{}, invalid("Slot not bookable: " + violations[0])
+	}
+	var eligible []string
+	for _, id := range pool.Members {
+		if id != requester.ID && p.available(id, start, end) {
+			eligible = append(eligible, id)
+		}
+	}
+	if len(eligible) == 0 {
+		return PoolClaimResponse{}, ErrNoPoolMemberFree
+	}
+	host := pool.nextHost(eligible)
+
+	title := req.Title
+	if title == "" {
+		name := requester.Name
+		if name == "" {
+			name = requester.ID
+		}
+		title = fmt.Sprintf("%s with %s", pool.Name, name)
+	}
+	event, slot, err := s.bookMeeting(CreateEventRequest{
+		Title:            title,
+		OrganizerID:      host,
+		RequiredDuration: pool.DurationMinutes,
+		Invitees:         []string{requester.ID},
+	}, start)
+	if err != nil {
+		return PoolClaimResponse{}, err
+	}
+
+	if pool.LastBookedAt == nil {
+		pool.LastBookedAt = map[string]time.Time{}
+	}
+	pool.LastBookedAt[host] = s.clock.Now()
+	pools.Save(pool)
+	return PoolClaimResponse{Event: event, TimeSlot: slot, HostID: host}, nil
+}
+
+// Pool handlers are scoped to the caller's organization; admins manage
+// pools and any member can claim
+func listPools(c *gin.Context) {
+	user, _ := currentUser(c)
+	c.JSON(http.StatusOK, pools.List(user.OrgID))
+}
+
+func createPool(c *gin.Context) {
+	user, _ := currentUser(c)
+	var req CreatePoolRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.DurationMinutes <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Duration must be positive"})
+		return
+	}
+	for _, id := range req.Members {
+		if member, ok := users.Get(id); !ok || member.OrgID != user.OrgID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown member %q", id)})
+			return
+		}
+	}
+
+	now := clock.Now()
+	pool := Pool{
+		ID:              uuid.New().String(),
+		OrgID:           user.OrgID,
+		Name:            req.Name,
+		Members:         req.Members,
+		DurationMinutes: req.DurationMinutes,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	pools.Save(pool)
+	c.JSON(http.StatusCreated, pool)
+}
+
+func deletePool(c *gin.Context) {
+	user, _ := currentUser(c)
+	if !pools.Delete(user.OrgID, c.Param("poolId")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pool not found"})
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func claimPoolSlot(c *gin.Context) {
+	user, _ := currentUser(c)
+	if _, ok := pools.Get(user.OrgID, c.Param("poolId")); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pool not found"})
+		return
+	}
+
+	var req ClaimPoolSlotRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := contextScheduler(c).ClaimPoolSlot(c.Request.Context(), user, c.Param("poolId"), req)
+	if errors.Is(err, ErrNoPoolMemberFree) {
+		c.JSON(http.StatusConflict, gin.H{"error": "No pool member is free at that time"})
+		return
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
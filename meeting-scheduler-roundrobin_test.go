@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetPools(t *testing.T) {
+	previous := pools
+	pools = newPoolRegistry()
+	t.Cleanup(func() { pools = previous })
+}
+
+func TestPoolClaimsRotateHosts(t *testing.T) {
+	resetDirectory(t)
+	resetDeclared(t)
+	resetPools(t)
+	scheduler, fake := newTestScheduler(t)
+	for _, id := range []string{"ann", "ben", "cat"} {
+		users.Save(User{ID: id, OrgID: "acme"})
+	}
+	requester := User{ID: "rex", Name: "Rex", OrgID: "acme"}
+	users.Save(requester)
+	pools.Save(Pool{ID: "support", OrgID: "acme", Name: "Support", Members: []string{"ann", "ben", "cat"}, DurationMinutes: 30})
+
+	monday := fake.Now().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	claim := func(at time.Duration) (PoolClaimResponse, error) {
+		fake.Advance(time.Minute)
+		return scheduler.ClaimPoolSlot(context.Background(), requester, "support", ClaimPoolSlotRequest{StartTime: monday.Add(at)})
+	}
+
+	var hosts []string
+	for _, at := range []time.Duration{9 * time.Hour, 10 * time.Hour, 11 * time.Hour, 12 * time.Hour} {
+		resp, err := claim(at)
+		require.NoError(t, err)
+		hosts = append(hosts, resp.HostID)
+		assert.Equal(t, "finalized", resp.Event.Status)
+		assert.Equal(t, "Support with Rex", resp.Event.Title)
+	}
+	assert.Equal(t, []string{"ann", "ben", "cat", "ann"}, hosts)
+
+	// Ben is next, but already hosting at 10:00, so Cat takes it
+	resp, err := claim(10 * time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, "cat", resp.HostID)
+
+	_, err = claim(10 * time.Hour)
+	require.NoError(t, err)
+	_, err = claim(10 * time.Hour)
+	assert.ErrorIs(t, err, ErrNoPoolMemberFree)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrNoFeasibleSlot means no start time in the horizon had enough of the
+// participants free
+var ErrNoFeasibleSlot = errors.New("no feasible slot")
+
+// ScheduleRequest books a meeting in one call: the best start time between
+// From and To becomes a finalized event, and participants are invited
+type ScheduleRequest struct {
+	Title           string    `json:"title" binding:"required"`
+	Description     string    `json:"description"`
+	Participants    []string  `json:"participants" binding:"required"`
+	DurationMinutes int       `json:"durationMinutes" binding:"required"`
+	From            time.Time `json:"from" binding:"required"`
+	To              time.Time `json:"to" binding:"required"`
+	StepMinutes     int       `json:"stepMinutes"`
+	// MinAttendance is the lowest acceptable share of participants, as a
+	// percentage; 0 means everyone must be free
+	MinAttendance float64 `json:"minAttendance"`
+}
+
+type ScheduleResponse struct {
+	Event            Event    `json:"event"`
+	TimeSlot         TimeSlot `json:"timeslot"`
+	AvailableUsers   []string `json:"availableUsers"`
+	UnavailableUsers []string `json:"unavailableUsers,omitempty"`
+	Warnings         []string `json:"warnings,omitempty"`
+}
+
+// Schedule picks the best-attended, earliest start time for the group and
+// books it: it creates the event and its single slot, then finalizes it,
+// which sends invites to the organizer and participants
+func (s *Scheduler) Schedule(ctx context.Context, organizer User, req ScheduleRequest) (ScheduleResponse, error) {
+	minAttendance := req.MinAttendance
+	if minAttendance == 0 {
+		minAttendance = 100
+	}
+	if minAttendance < 0 || minAttendance > 100 {
+		return ScheduleResponse{}, invalid("Minimum attendance must be between 0 and 100")
+	}
+
+	found, err := s.FindWindows(ctx, organizer, FindWindowsRequest{
+		Participants:    req.Participants,
+		DurationMinutes: req.DurationMinutes,
+		From:            req.From,
+		To:              req.To,
+		StepMinutes:     req.StepMinutes,
+		Limit:           1,
+	})
+	if err != nil {
+		return ScheduleResponse{}, err
+	}
+	if len(found.Windows) == 0 || found.Windows[0].AvailabilityPercentage < minAttendance {
+		return ScheduleResponse{}, ErrNoFeasibleSlot
+	}
+	best := found.Windows[0]
+
+	event, slot, err := s.bookMeeting(CreateEventRequest{
+		Title:            req.Title,
+		Description:      req.Description,
+		OrganizerID:      organizer.ID,
+		RequiredDuration: req.DurationMinutes,
+		Invitees:         req.Participants,
+	}, best.EarliestStart)
+	if err != nil {
+		return ScheduleResponse{}, err
+	}
+
+	return ScheduleResponse{
+		Event:            event,
+		TimeSlot:         slot,
+		AvailableUsers:   best.AvailableUsers,
+		UnavailableUsers: best.UnavailableUsers,
+		Warnings:         found.Warnings,
+	}, nil
+}
+
+// bookMeeting creates an event with a single slot at start and finalizes
+// it, which sends invites. A failure part way deletes the event again.
+func (s *Scheduler) bookMeeting(req CreateEventRequest, start time.Time) (Event, TimeSlot, error) {
+	event, err := s.CreateEvent(req)
+	if err != nil {
+		return Event{}, TimeSlot{}, err
+	}
+	slot, err := s.CreateTimeSlot(event.ID, CreateTimeSlotRequest{
+		StartTime: start,
+		EndTime:   start.Add(event.duration()),
+	})
+	var finalized Event
+	if err == nil {
+		finalized, err = s.FinalizeEvent(event.ID, FinalizeEventRequest{TimeSlotID: slot.ID})
+	}
+	if err != nil {
+		// Don't leave a half-booked poll behind
+		s.DeleteEvent(event.ID)
+		return Event{}, TimeSlot{}, err
+	}
+	return finalized, slot, nil
+}
+
+func scheduleMeeting(c *gin.Context) {
+	user, _ := currentUser(c)
+	var req ScheduleRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := contextScheduler(c).Schedule(c.Request.Context(), user, req)
+	if errors.Is(err, ErrNoFeasibleSlot) {
+		c.JSON(http.StatusConflict, gin.H{"error": "No time in the window where enough participants are free"})
+		return
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
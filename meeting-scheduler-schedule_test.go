@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleBooksBestSlotAndInvites(t *testing.T) {
+	resetDirectory(t)
+	resetDeclared(t)
+	scheduler, fake := newTestScheduler(t)
+	recorder := &recordingNotifier{}
+	previous := notifier
+	notifier = recorder
+	t.Cleanup(func() { notifier = previous })
+	scheduler.bus.Subscribe(EventFinalized, notifyEventFinalized)
+
+	bot := User{ID: "bot", OrgID: "acme", Role: RoleOrganizer}
+	users.Save(bot)
+	users.Save(User{ID: "bob", OrgID: "acme", Email: "bob@acme.test"})
+	users.Save(User{ID: "cy", OrgID: "acme", Email: "cy@acme.test"})
+
+	monday := fake.Now().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	declaredAvailability.Replace("acme", "hr", monday, monday.Add(24*time.Hour), []DeclaredInterval{
+		{OrgID: "acme", UserID: "cy", Source: "hr", Start: monday.Add(9 * time.Hour), End: monday.Add(11 * time.Hour), Status: DeclaredBusy},
+	})
+
+	req := ScheduleRequest{
+		Title:           "Incident review",
+		Participants:    []string{"bob", "cy"},
+		DurationMinutes: 60,
+		From:            monday.Add(9 * time.Hour),
+		To:              monday.Add(17 * time.Hour),
+	}
+	resp, err := scheduler.Schedule(context.Background(), bot, req)
+	require.NoError(t, err)
+	assert.Equal(t, "finalized", resp.Event.Status)
+	assert.Equal(t, resp.TimeSlot.ID, resp.Event.FinalTimeSlotID)
+	assert.Equal(t, monday.Add(11*time.Hour), resp.TimeSlot.StartTime)
+	assert.Equal(t, []string{"bob", "cy"}, resp.AvailableUsers)
+
+	var recipients []string
+	for _, msg := range recorder.messages {
+		recipients = append(recipients, msg.To.ID)
+		assert.NotEmpty(t, msg.Calendar)
+	}
+	assert.ElementsMatch(t, []string{"bot", "bob", "cy"}, recipients)
+
+	// During Cy's absence only Bob is free
+	req.To = monday.Add(11 * time.Hour)
+	_, err = scheduler.Schedule(context.Background(), bot, req)
+	assert.ErrorIs(t, err, ErrNoFeasibleSlot)
+	req.MinAttendance = 50
+	resp, err = scheduler.Schedule(context.Background(), bot, req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cy"}, resp.UnavailableUsers)
+}
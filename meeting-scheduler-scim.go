@@ -0,0 +1,717 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SCIM 2.0 (RFC 7643/7644) provisioning for users and groups. Each
+// organization's IdP authenticates with the organization's SCIMToken.
+
+const (
+	scimUserSchema  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimListSchema  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimPatchSchema = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	scimErrorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+type scimMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+type scimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type scimEmail struct {
+	Value   string `json:"value"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type scimUser struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	UserName    string      `json:"userName"`
+	Name        scimName    `json:"name,omitempty"`
+	DisplayName string      `json:"displayName,omitempty"`
+	Emails      []scimEmail `json:"emails,omitempty"`
+	Active      *bool       `json:"active,omitempty"`
+	Meta        *scimMeta   `json:"meta,omitempty"`
+}
+
+type scimMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+type scimGroup struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []scimMember `json:"members,omitempty"`
+	Meta        *scimMeta    `json:"meta,omitempty"`
+}
+
+type scimPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+type scimPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []scimPatchOperation `json:"Operations"`
+}
+
+// Group is an IdP-managed set of users. When the organization has SSO
+// group-to-role mappings, group membership drives member roles.
+type Group struct {
+	ID          string
+	OrgID       string
+	ExternalID  string
+	DisplayName string
+	Members     []string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// groupRegistry is the in-memory group store
+type groupRegistry struct {
+	mu     sync.RWMutex
+	groups map[string]Group
+}
+
+func newGroupRegistry() *groupRegistry {
+	return &groupRegistry{groups: make(map[string]Group)}
+}
+
+func (r *groupRegistry) Get(orgID, id string) (Group, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	group, ok := r.groups[id]
+	if !ok || group.OrgID != orgID {
+		return Group{}, false
+	}
+	return group, true
+}
+
+func (r *groupRegistry) Save(group Group) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.groups[group.ID] = group
+}
+
+func (r *groupRegistry) Delete(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.groups, id)
+}
+
+func (r *groupRegistry) List(orgID string) []Group {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var groupList []Group
+	for _, group := range r.groups {
+		if group.OrgID == orgID {
+			groupList = append(groupList, group)
+		}
+	}
+	return groupList
+}
+
+// GroupNames returns the display names of the groups a user belongs to
+func (r *groupRegistry) GroupNames(orgID, userID string) []string {
+	var names []string
+	for _, group := range r.List(orgID) {
+		for _, member := range group.Members {
+			if member == userID {
+				names = append(names, group.DisplayName)
+				break
+			}
+		}
+	}
+	return names
+}
+
+var groups = newGroupRegistry()
+
+// deprovisionQueue holds users awaiting poll cleanup by the background job
+type deprovisionQueue struct {
+	mu      sync.Mutex
+	pending map[string]bool
+}
+
+func (q *deprovisionQueue) Add(userID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending[userID] = true
+}
+
+func (q *deprovisionQueue) Drain() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var ids []string
+	for id := range q.pending {
+		ids = append(ids, id)
+	}
+	q.pending = make(map[string]bool)
+	return ids
+}
+
+var deprovisioned = &deprovisionQueue{pending: make(map[string]bool)}
+
+// scimAuth checks the organization's SCIM bearer token
+func scimAuth(c *gin.Context) {
+	org, ok := organizations.Get(c.Param("orgId"))
+	token, hasBearer := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || org.SCIMToken == "" || !hasBearer ||
+		subtle.ConstantTimeCompare([]byte(token), []byte(org.SCIMToken)) != 1 {
+		scimError(c, http.StatusUnauthorized, "Invalid SCIM credentials")
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+func scimError(c *gin.Context, status int, detail string) {
+	c.Header("Content-Type", "application/scim+json")
+	c.JSON(status, gin.H{
+		"schemas": []string{scimErrorSchema},
+		"status":  strconv.Itoa(status),
+		"detail":  detail,
+	})
+}
+
+func scimJSON(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", "application/scim+json")
+	c.JSON(status, body)
+}
+
+func toSCIMUser(user User) scimUser {
+	active := !user.Deactivated
+	return scimUser{
+		Schemas:     []string{scimUserSchema},
+		ID:          user.ID,
+		ExternalID:  user.ExternalID,
+		UserName:    user.Email,
+		Name:        scimName{Formatted: user.Name},
+		DisplayName: user.Name,
+		Emails:      []scimEmail{{Value: user.Email, Primary: true}},
+		Active:      &active,
+		Meta:        &scimMeta{ResourceType: "User", Created: user.CreatedAt, LastModified: user.UpdatedAt},
+	}
+}
+
+func toSCIMGroup(group Group) scimGroup {
+	members := make([]scimMember, 0, len(group.Members))
+	for _, id := range group.Members {
+		members = append(members, scimMember{Value: id})
+	}
+	return scimGroup{
+		Schemas:     []string{scimGroupSchema},
+		ID:          group.ID,
+		ExternalID:  group.ExternalID,
+		DisplayName: group.DisplayName,
+		Members:     members,
+		Meta:        &scimMeta{ResourceType: "Group", Created: group.CreatedAt, LastModified: group.UpdatedAt},
+	}
+}
+
+// applySCIMUser copies the SCIM representation onto the local user
+func applySCIMUser(user *User, req scimUser) {
+	user.ExternalID = req.ExternalID
+	user.Email = req.UserName
+	for _, email := range req.Emails {
+		if email.Primary {
+			user.Email = email.Value
+		}
+	}
+	user.Name = req.DisplayName
+	if user.Name == "" {
+		user.Name = req.Name.Formatted
+	}
+	if user.Name == "" {
+		user.Name = strings.TrimSpace(req.Name.GivenName + " " + req.Name.FamilyName)
+	}
+	if req.Active != nil {
+		setActive(user, *req.Active)
+	}
+}
+
+// setActive flips a user's active state, queueing poll cleanup on deactivation
+func setActive(user *User, active bool) {
+	if !active && !user.Deactivated {
+		deprovisioned.Add(user.ID)
+	}
+	user.Deactivated = !active
+}
+
+// parseSCIMFilter supports the single-clause `attr eq "value"` filters IdPs
+// send when checking whether a resource already exists
+func parseSCIMFilter(filter string) (attr, value string, ok bool) {
+	parts := strings.SplitN(strings.TrimSpace(filter), " ", 3)
+	if len(parts) != 3 || !strings.EqualFold(parts[1], "eq") {
+		return "", "", false
+	}
+	return parts[0], strings.Trim(parts[2], `"`), true
+}
+
+// scimPage applies startIndex/count pagination (startIndex is 1-based)
+func scimPage(c *gin.Context, total int) (start, end int) {
+	startIndex, err := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", "100"))
+	if err != nil || count < 0 {
+		count = 100
+	}
+	start = min(startIndex-1, total)
+	end = min(start+count, total)
+	return start, end
+}
+
+// User endpoints
+func scimListUsers(c *gin.Context) {
+	orgID := c.Param("orgId")
+	candidates := users.List(orgID)
+
+	if filter := c.Query("filter"); filter != "" {
+		attr, value, ok := parseSCIMFilter(filter)
+		if !ok {
+			scimError(c, http.StatusBadRequest, "Unsupported filter")
+			return
+		}
+		var matched []User
+		for _, user := range candidates {
+			switch {
+			case strings.EqualFold(attr, "userName") && strings.EqualFold(user.Email, value),
+				strings.EqualFold(attr, "externalId") && user.ExternalID == value:
+				matched = append(matched, user)
+			}
+		}
+		candidates = matched
+	}
+
+	start, end := scimPage(c, len(candidates))
+	resources := make([]scimUser, 0, end-start)
+	for _, user := range candidates[start:end] {
+		resources = append(resources, toSCIMUser(user))
+	}
+	scimJSON(c, http.StatusOK, gin.H{
+		"schemas":      []string{scimListSchema},
+		"totalResults": len(candidates),
+		"startIndex":   start + 1,
+		"itemsPerPage": len(resources),
+		"Resources":    resources,
+	})
+}
+
+func scimUserFromPath(c *gin.Context) (User, bool) {
+	user, ok := users.Get(c.Param("id"))
+	if !ok || user.OrgID != c.Param("orgId") {
+		scimError(c, http.StatusNotFound, "User not found")
+		return User{}, false
+	}
+	return user, true
+}
+
+func scimGetUser(c *gin.Context) {
+	user, ok := scimUserFromPath(c)
+	if !ok {
+		return
+	}
+	scimJSON(c, http.StatusOK, toSCIMUser(user))
+}
+
+func scimCreateUser(c *gin.Context) {
+	orgID := c.Param("orgId")
+	var req scimUser
+	if err := bindJSON(c, &req); err != nil {
+		scimError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.UserName == "" {
+		scimError(c, http.StatusBadRequest, "userName is required")
+		return
+	}
+	if _, exists := users.FindByEmail(orgID, req.UserName); exists {
+		scimError(c, http.StatusConflict, "User already exists")
+		return
+	}
+
+	now := clock.Now()
+	user := User{ID: uuid.New().String(), OrgID: orgID, Role: RoleMember, CreatedAt: now, UpdatedAt: now}
+	applySCIMUser(&user, req)
+	users.Save(user)
+	scimJSON(c, http.StatusCreated, toSCIMUser(user))
+}
+
+func scimReplaceUser(c *gin.Context) {
+	user, ok := scimUserFromPath(c)
+	if !ok {
+		return
+	}
+	var req scimUser
+	if err := bindJSON(c, &req); err != nil {
+		scimError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.UserName == "" {
+		scimError(c, http.StatusBadRequest, "userName is required")
+		return
+	}
+
+	applySCIMUser(&user, req)
+	user.UpdatedAt = clock.Now()
+	users.Save(user)
+	scimJSON(c, http.StatusOK, toSCIMUser(user))
+}
+
+func scimPatchUser(c *gin.Context) {
+	user, ok := scimUserFromPath(c)
+	if !ok {
+		return
+	}
+	var req scimPatchRequest
+	if err := bindJSON(c, &req); err != nil {
+		scimError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for _, op := range req.Operations {
+		if !strings.EqualFold(op.Op, "replace") && !strings.EqualFold(op.Op, "add") {
+			scimError(c, http.StatusBadRequest, fmt.Sprintf("Unsupported operation %q", op.Op))
+			return
+		}
+		// Both {"path": "active", "value": false} and the path-less
+		// {"value": {"active": false}} forms are in common use
+		values := map[string]interface{}{op.Path: op.Value}
+		if op.Path == "" {
+			values, _ = op.Value.(map[string]interface{})
+		}
+		for attr, value := range values {
+			switch strings.ToLower(attr) {
+			case "active":
+				if active, ok := value.(bool); ok {
+					setActive(&user, active)
+				}
+			case "displayname", "name.formatted":
+				if name, ok := value.(string); ok {
+					user.Name = name
+				}
+			case "username":
+				if email, ok := value.(string); ok {
+					user.Email = email
+				}
+			case "externalid":
+				if externalID, ok := value.(string); ok {
+					user.ExternalID = externalID
+				}
+			}
+		}
+	}
+
+	user.UpdatedAt = clock.Now()
+	users.Save(user)
+	scimJSON(c, http.StatusOK, toSCIMUser(user))
+}
+
+// scimDeleteUser deprovisions the user. The account is kept, deactivated,
+// so their history stays attributable; the cleanup job handles their polls.
+func scimDeleteUser(c *gin.Context) {
+	user, ok := scimUserFromPath(c)
+	if !ok {
+		return
+	}
+	setActive(&user, false)
+	user.UpdatedAt = clock.Now()
+	users.Save(user)
+	c.Status(http.StatusNoContent)
+}
+
+// Group endpoints
+func scimListGroups(c *gin.Context) {
+	candidates := groups.List(c.Param("orgId"))
+
+	if filter := c.Query("filter"); filter != "" {
+		attr, value, ok := parseSCIMFilter(filter)
+		if !ok {
+			scimError(c, http.StatusBadRequest, "Unsupported filter")
+			return
+		}
+		var matched []Group
+		for _, group := range candidates {
+			switch {
+			case strings.EqualFold(attr, "displayName") && group.DisplayName == value,
+				strings.EqualFold(attr, "externalId") && group.ExternalID == value:
+				matched = append(matched, group)
+			}
+		}
+		candidates = matched
+	}
+
+	start, end := scimPage(c, len(candidates))
+	resources := make([]scimGroup, 0, end-start)
+	for _, group := range candidates[start:end] {
+		resources = append(resources, toSCIMGroup(group))
+	}
+	scimJSON(c, http.StatusOK, gin.H{
+		"schemas":      []string{scimListSchema},
+		"totalResults": len(candidates),
+		"startIndex":   start + 1,
+		"itemsPerPage": len(resources),
+		"Resources":    resources,
+	})
+}
+
+func scimGroupFromPath(c *gin.Context) (Group, bool) {
+	group, ok := groups.Get(c.Param("orgId"), c.Param("id"))
+	if !ok {
+		scimError(c, http.StatusNotFound, "Group not found")
+		return Group{}, false
+	}
+	return group, true
+}
+
+func scimGetGroup(c *gin.Context) {
+	group, ok := scimGroupFromPath(c)
+	if !ok {
+		return
+	}
+	scimJSON(c, http.StatusOK, toSCIMGroup(group))
+}
+
+func memberIDs(members []scimMember) []string {
+	ids := make([]string, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.Value)
+	}
+	return ids
+}
+
+func scimCreateGroup(c *gin.Context) {
+	var req scimGroup
+	if err := bindJSON(c, &req); err != nil {
+		scimError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.DisplayName == "" {
+		scimError(c, http.StatusBadRequest, "displayName is required")
+		return
+	}
+
+	now := clock.Now()
+	group := Group{
+		ID:          uuid.New().String(),
+		OrgID:       c.Param("orgId"),
+		ExternalID:  req.ExternalID,
+		DisplayName: req.DisplayName,
+		Members:     memberIDs(req.Members),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	groups.Save(group)
+	syncGroupRoles(group.OrgID, group.Members)
+	scimJSON(c, http.StatusCreated, toSCIMGroup(group))
+}
+
+func scimReplaceGroup(c *gin.Context) {
+	group, ok := scimGroupFromPath(c)
+	if !ok {
+		return
+	}
+	var req scimGroup
+	if err := bindJSON(c, &req); err != nil {
+		scimError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.DisplayName == "" {
+		scimError(c, http.StatusBadRequest, "displayName is required")
+		return
+	}
+
+	affected := append([]string(nil), group.Members...)
+	group.ExternalID = req.ExternalID
+	group.DisplayName = req.DisplayName
+	group.Members = memberIDs(req.Members)
+	group.UpdatedAt = clock.Now()
+	groups.Save(group)
+	syncGroupRoles(group.OrgID, append(affected, group.Members...))
+	scimJSON(c, http.StatusOK, toSCIMGroup(group))
+}
+
+func scimPatchGroup(c *gin.Context) {
+	group, ok := scimGroupFromPath(c)
+	if !ok {
+		return
+	}
+	var req scimPatchRequest
+	if err := bindJSON(c, &req); err != nil {
+		scimError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	affected := append([]string(nil), group.Members...)
+	for _, op := range req.Operations {
+		path := strings.ToLower(op.Path)
+		switch {
+		case strings.EqualFold(op.Op, "add") && path == "members":
+			group.Members = append(group.Members, patchMemberIDs(op.Value)...)
+		case strings.EqualFold(op.Op, "replace") && path == "members":
+			group.Members = patchMemberIDs(op.Value)
+		case strings.EqualFold(op.Op, "remove") && strings.HasPrefix(path, "members"):
+			// Either `members[value eq "id"]` or `members` with a value list
+			remove := patchMemberIDs(op.Value)
+			if _, value, ok := parseSCIMFilter(strings.TrimSuffix(strings.TrimPrefix(op.Path[len("members"):], "["), "]")); ok {
+				remove = append(remove, value)
+			}
+			group.Members = withoutMembers(group.Members, remove)
+		case strings.EqualFold(op.Op, "replace") && path == "displayname":
+			if name, ok := op.Value.(string); ok {
+				group.DisplayName = name
+			}
+		default:
+			scimError(c, http.StatusBadRequest, fmt.Sprintf("Unsupported operation %q on %q", op.Op, op.Path))
+			return
+		}
+	}
+
+	group.UpdatedAt = clock.Now()
+	groups.Save(group)
+	syncGroupRoles(group.OrgID, append(affected, group.Members...))
+	scimJSON(c, http.StatusOK, toSCIMGroup(group))
+}
+
+func scimDeleteGroup(c *gin.Context) {
+	group, ok := scimGroupFromPath(c)
+	if !ok {
+		return
+	}
+	groups.Delete(group.ID)
+	syncGroupRoles(group.OrgID, group.Members)
+	c.Status(http.StatusNoContent)
+}
+
+// patchMemberIDs reads member IDs from a PATCH value of [{"value": id}]
+func patchMemberIDs(value interface{}) []string {
+	items, _ := value.([]interface{})
+	var ids []string
+	for _, item := range items {
+		if member, ok := item.(map[string]interface{}); ok {
+			if id, ok := member["value"].(string); ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+func withoutMembers(members, remove []string) []string {
+	drop := make(map[string]bool, len(remove))
+	for _, id := range remove {
+		drop[id] = true
+	}
+	var kept []string
+	for _, id := range members {
+		if !drop[id] {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
+
+// syncGroupRoles recomputes roles from group membership for organizations
+// with SSO group mappings
+func syncGroupRoles(orgID string, userIDs []string) {
+	org, ok := organizations.Get(orgID)
+	if !ok || org.SSO == nil || len(org.SSO.GroupRoles) == 0 {
+		return
+	}
+	for _, id := range userIDs {
+		user, ok := users.Get(id)
+		if !ok || user.OrgID != orgID {
+			continue
+		}
+		role := roleForGroups(org.SSO, groups.GroupNames(orgID, id))
+		if role != user.Role {
+			user.Role = role
+			user.UpdatedAt = clock.Now()
+			users.Save(user)
+		}
+	}
+}
+
+// cleanupDeprovisionedUsers declines outstanding responses for each
+// deprovisioned user and hands off (or cancels) the active polls they
+// organize, according to their organization's policy
+func cleanupDeprovisionedUsers(time.Time) {
+	for _, userID := range deprovisioned.Drain() {
+		if err := cleanupDeprovisionedUser(currentScheduler(), userID); err != nil {
+			log.Printf("Deprovision cleanup for user %s failed, will retry: %v", userID, err)
+			deprovisioned.Add(userID)
+		}
+	}
+}
+
+func cleanupDeprovisionedUser(scheduler *Scheduler, userID string) error {
+	user, ok := users.Get(userID)
+	if !ok || !user.Deactivated {
+		return nil
+	}
+	org, _ := organizations.Get(user.OrgID)
+	reassignTo := org.Deprovisioning.ReassignTo
+
+	eventList, err := scheduler.ListEvents()
+	if err != nil {
+		return err
+	}
+	for _, event := range eventList {
+		if event.Status != "active" {
+			continue
+		}
+
+		if event.OrganizerID == userID {
+			if reassignTo != "" {
+				_, err = scheduler.ReassignEvent(event.ID, reassignTo)
+			} else {
+				_, err = scheduler.CancelEvent(event.ID)
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		responses, err := scheduler.ListUserAvailability(event.ID, userID)
+		if err != nil {
+			return err
+		}
+		for _, avail := range responses {
+			if avail.Status == "unavailable" {
+				continue
+			}
+			decline := UserAvailabilityRequest{TimeSlotID: avail.TimeSlotID, Status: "unavailable"}
+			if _, err := scheduler.UpdateAvailability(event.ID, userID, avail.TimeSlotID, decline); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
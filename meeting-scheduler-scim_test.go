@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scimRequest(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/scim+json")
+	req.Header.Set("Authorization", "Bearer scim-secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestSCIMProvisioningAndDeprovisioning(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	previousGroups := groups
+	groups = newGroupRegistry()
+	t.Cleanup(func() { groups = previousGroups })
+
+	organizations.Save(Organization{
+		ID:        "acme",
+		SCIMToken: "scim-secret",
+		SSO:       &SSOConfig{GroupRoles: map[string]string{"Schedulers": RoleOrganizer}},
+	})
+
+	w := scimRequest(router, "POST", "/scim/v2/acme/Users", `{"schemas":["`+scimUserSchema+`"],"userName":"ada@acme.test","displayName":"Ada","active":true}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created scimUser
+	decodeJSON(t, w, &created)
+
+	w = scimRequest(router, "GET", `/scim/v2/acme/Users?filter=userName+eq+"ada@acme.test"`, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"totalResults":1`)
+
+	// Group membership maps onto roles
+	w = scimRequest(router, "POST", "/scim/v2/acme/Groups", `{"displayName":"Schedulers","members":[{"value":"`+created.ID+`"}]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	user, _ := users.Get(created.ID)
+	assert.Equal(t, RoleOrganizer, user.Role)
+
+	// Wrong token is rejected
+	req, _ := http.NewRequest("GET", "/scim/v2/acme/Users", nil)
+	req.Header.Set("Authorization", "Bearer nope")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// Set up a poll the user organizes and one they responded to
+	scheduler := currentScheduler()
+	owned, err := scheduler.CreateEvent(CreateEventRequest{Title: "Owned", OrganizerID: created.ID, RequiredDuration: 30})
+	require.NoError(t, err)
+	other, err := scheduler.CreateEvent(CreateEventRequest{Title: "Other", OrganizerID: "someone", RequiredDuration: 30})
+	require.NoError(t, err)
+	start := time.Now().Add(time.Hour)
+	slot, err := scheduler.CreateTimeSlot(other.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(other.ID, created.ID, UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+
+	w = scimRequest(router, "PATCH", "/scim/v2/acme/Users/"+created.ID, `{"schemas":["`+scimPatchSchema+`"],"Operations":[{"op":"replace","value":{"active":false}}]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	user, _ = users.Get(created.ID)
+	assert.True(t, user.Deactivated)
+
+	cleanupDeprovisionedUsers(time.Now())
+
+	owned, _ = scheduler.GetEvent(owned.ID)
+	assert.Equal(t, "cancelled", owned.Status)
+	avail, err := scheduler.GetAvailability(other.ID, created.ID, slot.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "unavailable", avail.Status)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// SecretBackend fetches the current values of all secrets from an external
+// secret manager
+type SecretBackend interface {
+	Fetch(ctx context.Context) (map[string]string, error)
+}
+
+// vaultBackend reads a HashiCorp Vault KV version 2 secret, whose keys are
+// secret names (JWT_SIGNING_KEY, SMTP_PASSWORD, ...)
+type vaultBackend struct {
+	addr   string
+	token  string
+	path   string
+	client *http.Client
+}
+
+func (v *vaultBackend) Fetch(ctx context.Context) (map[string]string, error) {
+	mount, secretPath, ok := strings.Cut(strings.Trim(v.path, "/"), "/")
+	if !ok {
+		return nil, fmt.Errorf("vault path %q must be mount/secret", v.path)
+	}
+	url := strings.TrimRight(v.addr, "/") + "/v1/" + mount + "/data/" + secretPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned %s", resp.Status)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("parsing vault response: %w", err)
+	}
+	return body.Data.Data, nil
+}
+
+// awsSecretsBackend reads a JSON object of secrets from AWS Secrets Manager
+type awsSecretsBackend struct {
+	client   *secretsmanager.Client
+	secretID string
+}
+
+func (a *awsSecretsBackend) Fetch(ctx context.Context) (map[string]string, error) {
+	out, err := a.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(a.secretID)})
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	if err := json.Unmarshal([]byte(aws.ToString(out.SecretString)), &values); err != nil {
+		return nil, fmt.Errorf("secret %s is not a JSON object of strings: %w", a.secretID, err)
+	}
+	return values, nil
+}
+
+// secretBackendFromEnv picks the backend named by SECRETS_BACKEND (vault or
+// aws). It returns nil when secrets come only from the environment.
+func secretBackendFromEnv() SecretBackend {
+	switch kind := getenv("SECRETS_BACKEND", ""); kind {
+	case "":
+		return nil
+	case "vault":
+		return &vaultBackend{
+			addr:   getenv("VAULT_ADDR", "http://127.0.0.1:8200"),
+			token:  getenv("VAULT_TOKEN", ""),
+			path:   getenv("VAULT_SECRET_PATH", "secret/meeting-scheduler"),
+			client: &http.Client{Timeout: 10 * time.Second},
+		}
+	case "aws":
+		cfg, err := awsconfig.LoadDefaultConfig(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to load AWS configuration: %v", err)
+		}
+		return &awsSecretsBackend{
+			client:   secretsmanager.NewFromConfig(cfg),
+			secretID: getenv("AWS_SECRET_ID", "meeting-scheduler"),
+		}
+	default:
+		log.Fatalf("Unknown SECRETS_BACKEND %q", kind)
+		return nil
+	}
+}
+
+// secretStore caches secrets from the backend, falling back to environment
+// variables of the same name. Refresh picks up rotated values and tells
+// subscribers which ones changed.
+type secretStore struct {
+	backend SecretBackend
+
+	mu       sync.RWMutex
+	values   map[string]string
+	onRotate []func(name, value string)
+}
+
+func newSecretStore(backend SecretBackend) *secretStore {
+	return &secretStore{backend: backend, values: make(map[string]string)}
+}
+
+// Get returns the secret's current value
+func (s *secretStore) Get(name string) string {
+	s.mu.RLock()
+	value, ok := s.values[name]
+	s.mu.RUnlock()
+	if ok && value != "" {
+		return value
+	}
+	return getenv(name, "")
+}
+
+// OnRotate registers a callback run after a refresh changes a secret
+func (s *secretStore) OnRotate(fn func(name, value string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onRotate = append(s.onRotate, fn)
+}
+
+// Refresh reloads every secret from the backend
+func (s *secretStore) Refresh(ctx context.Context) error {
+	if s.backend == nil {
+		return nil
+	}
+	values, err := s.backend.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	var changed []string
+	for name, value := range values {
+		if previous, ok := s.values[name]; ok && previous != value {
+			changed = append(changed, name)
+		}
+	}
+	s.values = values
+	callbacks := append([]func(string, string){}, s.onRotate...)
+	s.mu.Unlock()
+
+	for _, name := range changed {
+		log.Printf("Secret %s rotated", name)
+		for _, fn := range callbacks {
+			fn(name, values[name])
+		}
+	}
+	return nil
+}
+
+// secrets is the process-wide secret store, loaded once at startup
+var secrets = loadSecrets()
+
+func loadSecrets() *secretStore {
+	s := newSecretStore(secretBackendFromEnv())
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.Refresh(ctx); err != nil {
+		log.Fatalf("Failed to load secrets: %v", err)
+	}
+	return s
+}
+
+// refreshSecrets is the secret-refresh job
+func refreshSecrets(time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := secrets.Refresh(ctx); err != nil {
+		log.Printf("Failed to refresh secrets: %v", err)
+	}
+}
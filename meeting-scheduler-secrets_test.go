@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fakeVault(t *testing.T, values *map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/secret/data/meeting-scheduler" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": *values}})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSecretStoreRefreshesFromVault(t *testing.T) {
+	values := map[string]string{"SMTP_PASSWORD": "first", "okta-acme": "client-secret"}
+	server := fakeVault(t, &values)
+	store := newSecretStore(&vaultBackend{addr: server.URL, token: "root", path: "secret/meeting-scheduler", client: server.Client()})
+
+	var rotated []string
+	store.OnRotate(func(name, value string) { rotated = append(rotated, name+"="+value) })
+	require.NoError(t, store.Refresh(context.Background()))
+	assert.Equal(t, "first", store.Get("SMTP_PASSWORD"))
+	assert.Empty(t, rotated)
+
+	values["SMTP_PASSWORD"] = "second"
+	require.NoError(t, store.Refresh(context.Background()))
+	assert.Equal(t, "second", store.Get("SMTP_PASSWORD"))
+	assert.Equal(t, []string{"SMTP_PASSWORD=second"}, rotated)
+
+	bad := newSecretStore(&vaultBackend{addr: server.URL, token: "wrong", path: "secret/meeting-scheduler", client: server.Client()})
+	assert.Error(t, bad.Refresh(context.Background()))
+}
+
+func TestRotatedSigningKeyStillVerifiesOldTokens(t *testing.T) {
+	previous := jwtKeys
+	jwtKeys = &signingKeys{current: []byte("old-key")}
+	t.Cleanup(func() { jwtKeys = previous })
+
+	user := User{ID: "ada", Role: RoleMember}
+	oldToken, err := issueSessionToken(user)
+	require.NoError(t, err)
+
+	jwtKeys.Rotate([]byte("new-key"))
+	newToken, err := issueSessionToken(user)
+	require.NoError(t, err)
+
+	for _, token := range []string{oldToken, newToken} {
+		claims, err := parseSessionToken(token)
+		require.NoError(t, err)
+		assert.Equal(t, "ada", claims.Subject)
+	}
+
+	// A second rotation retires the oldest key
+	jwtKeys.Rotate([]byte("newest-key"))
+	_, err = parseSessionToken(oldToken)
+	assert.Error(t, err)
+}
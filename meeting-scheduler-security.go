@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// apiContentSecurityPolicy lets API responses load nothing and be framed
+// nowhere; pageContentSecurityPolicy allows what the poll pages need
+const (
+	apiContentSecurityPolicy  = "default-src 'none'; frame-ancestors 'none'"
+	pageContentSecurityPolicy = "default-src 'none'; style-src 'unsafe-inline'; img-src https: data:; frame-ancestors 'none'"
+)
+
+// hstsMaxAge is how long browsers keep to HTTPS once they've seen it
+const hstsMaxAge = "max-age=63072000; includeSubDomains"
+
+// setSecurityHeaders sends the standard hardening headers on every
+// response. HSTS is only sent when the deployment is served over HTTPS,
+// directly or behind a proxy.
+func setSecurityHeaders(c *gin.Context) {
+	h := c.Writer.Header()
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("X-Frame-Options", "DENY")
+	h.Set("Referrer-Policy", "no-referrer")
+	h.Set("Cross-Origin-Opener-Policy", "same-origin")
+	if strings.HasPrefix(c.Request.URL.Path, "/poll/") {
+		h.Set("Content-Security-Policy", pageContentSecurityPolicy)
+	} else {
+		h.Set("Content-Security-Policy", apiContentSecurityPolicy)
+	}
+	if c.Request.TLS != nil || strings.HasPrefix(publicURL(), "https://") {
+		h.Set("Strict-Transport-Security", hstsMaxAge)
+	}
+	c.Next()
+}
+
+// configureProxies trusts X-Forwarded-For only from TRUSTED_PROXIES, a
+// comma-separated list of IPs and CIDR ranges, so throttling and audit
+// logs see the real client behind a load balancer. Unset, no proxy is
+// trusted and the connection's address is used. TRUSTED_PLATFORM_HEADER
+// instead reads the client IP from a header a CDN sets, such as
+// CF-Connecting-IP.
+func configureProxies(router *gin.Engine) error {
+	var proxies []string
+	for _, proxy := range strings.Split(getenv("TRUSTED_PROXIES", ""), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		return err
+	}
+	router.TrustedPlatform = getenv("TRUSTED_PLATFORM_HEADER", "")
+	return nil
+}
+
+// serve runs the server until it fails. With TLS_CERT_FILE and
+// TLS_KEY_FILE it terminates TLS itself; with TLS_AUTOCERT_DOMAINS it gets
+// certificates for those domains from Let's Encrypt, answering the HTTP
+// challenge on :80. Otherwise it serves plain HTTP for a proxy in front
+// to terminate TLS. LISTEN_ADDR overrides the port each mode listens on.
+func serve(router *gin.Engine) error {
+	certFile, keyFile := getenv("TLS_CERT_FILE", ""), getenv("TLS_KEY_FILE", "")
+	domains := getenv("TLS_AUTOCERT_DOMAINS", "")
+	switch {
+	case certFile != "" && domains != "":
+		return errors.New("TLS_CERT_FILE and TLS_AUTOCERT_DOMAINS can't both be set")
+	case (certFile == "") != (keyFile == ""):
+		return errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	clientCAs, err := clientCAsFromEnv()
+	if err != nil {
+		return fmt.Errorf("loading TLS_CLIENT_CA_FILE: %w", err)
+	}
+	if clientCAs != nil && certFile == "" && domains == "" {
+		return errors.New("TLS_CLIENT_CA_FILE needs the server to terminate TLS")
+	}
+
+	server := &http.Server{
+		Handler:           router,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+	switch {
+	case certFile != "":
+		if clientCAs != nil {
+			requestClientCertificates(server.TLSConfig, clientCAs)
+		}
+		server.Addr = getenv("LISTEN_ADDR", ":8443")
+		return server.ListenAndServeTLS(certFile, keyFile)
+	case domains != "":
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(strings.Split(domains, ",")...),
+			Cache:      autocert.DirCache(getenv("TLS_AUTOCERT_CACHE", "autocert-cache")),
+		}
+		server.Addr = getenv("LISTEN_ADDR", ":443")
+		server.TLSConfig = manager.TLSConfig()
+		server.TLSConfig.MinVersion = tls.VersionTLS12
+		if clientCAs != nil {
+			requestClientCertificates(server.TLSConfig, clientCAs)
+		}
+		go func() {
+			// Plain HTTP only answers ACME challenges and redirects to HTTPS
+			challenges := &http.Server{Addr: ":80", Handler: manager.HTTPHandler(nil), ReadHeaderTimeout: 10 * time.Second}
+			if err := challenges.ListenAndServe(); err != nil {
+				log.Printf("ACME challenge listener stopped: %v", err)
+			}
+		}()
+		return server.ListenAndServeTLS("", "")
+	default:
+		server.Addr = getenv("LISTEN_ADDR", ":8080")
+		return server.ListenAndServe()
+	}
+}
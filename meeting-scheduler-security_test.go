@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	router := newTestRouter(t)
+
+	w := doJSON(router, http.MethodGet, "/api/v1/events", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	assert.Equal(t, apiContentSecurityPolicy, w.Header().Get("Content-Security-Policy"))
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"), "not served over HTTPS")
+
+	t.Setenv("PUBLIC_URL", "https://scheduler.example.test")
+	w = doJSON(router, http.MethodGet, "/poll/missing", nil)
+	assert.Equal(t, pageContentSecurityPolicy, w.Header().Get("Content-Security-Policy"))
+	assert.Equal(t, hstsMaxAge, w.Header().Get("Strict-Transport-Security"))
+}
+
+func TestClientIPComesFromTrustedProxiesOnly(t *testing.T) {
+	clientIP := func() string {
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		require.NoError(t, configureProxies(router))
+		router.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+
+		req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		req.RemoteAddr = "10.0.0.5:41000"
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	assert.Equal(t, "10.0.0.5", clientIP(), "forwarded addresses are ignored by default")
+
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8")
+	assert.Equal(t, "203.0.113.7", clientIP())
+
+	t.Setenv("TRUSTED_PROXIES", "192.168.0.0/16")
+	assert.Equal(t, "10.0.0.5", clientIP())
+}
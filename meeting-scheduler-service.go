@@ -0,0 +1,885 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// Service errors. Handlers translate these into HTTP responses; other
+// callers (jobs, CLI tools) can match on them with errors.Is.
+var (
+	ErrEventNotFound        = errors.New("event not found")
+	ErrTimeSlotNotFound     = errors.New("time slot not found")
+	ErrAvailabilityNotFound = errors.New("availability record not found")
+)
+
+// ValidationError reports input that breaks a business rule
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string { return e.Message }
+
+func invalid(message string) error {
+	return &ValidationError{Message: message}
+}
+
+// Scheduler holds the scheduling business logic independent of the HTTP
+// layer. It is cheap to construct, so handlers build one per request from
+// the active store, clock and event bus.
+type Scheduler struct {
+	store Store
+	clock Clock
+	bus   *EventBus
+	// dryRun suppresses persistence and announcements; see DryRun
+	dryRun bool
+	// tieSeed shuffles equally scored recommendations; see WithTieSeed
+	tieSeed string
+	// impersonatorID marks the changes made while an operator impersonates
+	// the requesting user
+	impersonatorID string
+}
+
+func newScheduler(s Store, c Clock, b *EventBus) *Scheduler {
+	return &Scheduler{store: s, clock: withPrecision(c), bus: b}
+}
+
+// currentScheduler returns a Scheduler over the process-wide dependencies
+func currentScheduler() *Scheduler {
+	return newScheduler(store, clock, bus)
+}
+
+// publish announces a persisted change on the bus
+func (s *Scheduler) publish(eventType DomainEventType, eventID string, payload interface{}) {
+	orgID := ""
+	if event, ok := payload.(Event); ok {
+		orgID = event.OrgID
+	} else if event, err := s.store.GetEvent(eventID); err == nil {
+		orgID = event.OrgID
+	}
+	s.publishForOrg(eventType, eventID, orgID, payload)
+}
+
+// publishForOrg is publish for changes after which the event can no longer
+// be looked up
+func (s *Scheduler) publishForOrg(eventType DomainEventType, eventID, orgID string, payload interface{}) {
+	if s.dryRun {
+		return
+	}
+	s.bus.Publish(DomainEvent{
+		Type:           eventType,
+		EventID:        eventID,
+		OrgID:          orgID,
+		OccurredAt:     s.clock.Now(),
+		Payload:        payload,
+		ImpersonatorID: s.impersonatorID,
+	})
+}
+
+// notFound swaps a storage ErrNotFound for the given service error
+func notFound(err, replacement error) error {
+	if errors.Is(err, ErrNotFound) {
+		return replacement
+	}
+	return err
+}
+
+func validateEventRequest(req CreateEventRequest) error {
+	if req.Title == "" {
+		return invalid("Title is required")
+	}
+	if req.OrganizerID == "" {
+		return invalid("Organizer ID is required")
+	}
+	if req.RequiredDuration <= 0 {
+		return invalid("Required duration must be positive")
+	}
+	if req.MinNoticeMinutes < 0 {
+		return invalid("Minimum notice cannot be negative")
+	}
+	if err := validateBuffer(req.BufferMinutes); err != nil {
+		return err
+	}
+	if !validLocationMode(req.LocationMode) {
+		return invalid("Location mode must be video, phone or in_person")
+	}
+	if err := validateQuestions(req.Questions); err != nil {
+		return invalid(err.Error())
+	}
+	if req.Settings != nil {
+		if err := req.Settings.validate(); err != nil {
+			return invalid(err.Error())
+		}
+	}
+	return validateInviteeCapacity(req)
+}
+
+func validateTimeSlotRequest(req CreateTimeSlotRequest) error {
+	if req.EndTime.Before(req.StartTime) {
+		return invalid("End time must be after start time")
+	}
+	return nil
+}
+
+// validateCondition checks a conditional response: it must be an
+// available one, depending on someone else
+func validateCondition(userID string, req UserAvailabilityRequest) error {
+	if req.ConditionalOn == "" {
+		return nil
+	}
+	if req.Status != "available" {
+		return invalid("Only available responses can be conditional")
+	}
+	if req.ConditionalOn == userID {
+		return invalid("A response cannot be conditional on its own user")
+	}
+	return nil
+}
+
+func validateAvailabilityStatus(status string) error {
+	if status != "available" && status != "unavailable" {
+		return invalid("Status must be available or unavailable")
+	}
+	return nil
+}
+
+// Events
+
+func (s *Scheduler) CreateEvent(req CreateEventRequest) (Event, error) {
+	req, err := withDuration(req)
+	if err != nil {
+		return Event{}, err
+	}
+	if req, err = withWorkspace(req); err != nil {
+		return Event{}, err
+	}
+	if req, err = withMeetingType(req); err != nil {
+		return Event{}, err
+	}
+	req = withDefaultDuration(req)
+	req, guests, err := s.withInviteEmails(req)
+	if err != nil {
+		return Event{}, err
+	}
+	if err := validateEventRequest(req); err != nil {
+		return Event{}, err
+	}
+	if err := s.validateDependencies("", req.DependsOn); err != nil {
+		return Event{}, err
+	}
+	s.saveGuests(guests)
+
+	now := s.clock.Now()
+	event := Event{
+		ID:               uuid.New().String(),
+		Title:            req.Title,
+		Description:      req.Description,
+		OrganizerID:      req.OrganizerID,
+		RequiredDuration: req.RequiredDuration,
+		DurationSeconds:  req.durationSeconds(),
+		MinNoticeMinutes: req.MinNoticeMinutes,
+		Invitees:         req.Invitees,
+		MeetingTypeID:    req.MeetingTypeID,
+		BufferMinutes:    req.BufferMinutes,
+		LocationMode:     req.LocationMode,
+		Location:         req.Location,
+		Questions:        req.Questions,
+		DependsOn:        req.DependsOn,
+		WorkspaceID:      req.WorkspaceID,
+		Settings:         req.Settings,
+		Status:           "active",
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	// Events belong to their organizer's organization, when known
+	if organizer, ok := users.Get(req.OrganizerID); ok {
+		event.OrgID = organizer.OrgID
+	}
+
+	if err := s.store.CreateEvent(event); err != nil {
+		return Event{}, err
+	}
+	s.addDefaultQuorumAlert(event)
+	s.publish(EventCreated, event.ID, event)
+	return event, nil
+}
+
+// ListEvents returns events oldest first, so pages of the list are stable
+func (s *Scheduler) ListEvents() ([]Event, error) {
+	eventList, err := s.store.ListEvents()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(eventList, func(i, j int) bool {
+		if !eventList[i].CreatedAt.Equal(eventList[j].CreatedAt) {
+			return eventList[i].CreatedAt.Before(eventList[j].CreatedAt)
+		}
+		return eventList[i].ID < eventList[j].ID
+	})
+	return eventList, nil
+}
+
+func (s *Scheduler) GetEvent(eventID string) (Event, error) {
+	event, err := s.store.GetEvent(eventID)
+	if err != nil {
+		return Event{}, notFound(err, ErrEventNotFound)
+	}
+	return event, nil
+}
+
+func (s *Scheduler) UpdateEvent(eventID string, req CreateEventRequest) (Event, error) {
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return Event{}, err
+	}
+	if req, err = withDuration(req); err != nil {
+		return Event{}, err
+	}
+	if req, err = withWorkspace(req); err != nil {
+		return Event{}, err
+	}
+	if req, err = withMeetingType(req); err != nil {
+		return Event{}, err
+	}
+	req = withDefaultDuration(req)
+	req, guests, err := s.withInviteEmails(req)
+	if err != nil {
+		return Event{}, err
+	}
+	if err := validateEventRequest(req); err != nil {
+		return Event{}, err
+	}
+	if err := s.validateDependencies(eventID, req.DependsOn); err != nil {
+		return Event{}, err
+	}
+	s.saveGuests(guests)
+
+	event.Title = req.Title
+	event.Description = req.Description
+	event.OrganizerID = req.OrganizerID
+	event.RequiredDuration = req.RequiredDuration
+	event.DurationSeconds = req.durationSeconds()
+	event.MinNoticeMinutes = req.MinNoticeMinutes
+	event.Invitees = req.Invitees
+	event.MeetingTypeID = req.MeetingTypeID
+	event.BufferMinutes = req.BufferMinutes
+	event.LocationMode = req.LocationMode
+	event.Location = req.Location
+	event.Questions = req.Questions
+	event.DependsOn = req.DependsOn
+	event.WorkspaceID = req.WorkspaceID
+	event.Settings = req.Settings
+	event.UpdatedAt = s.clock.Now()
+
+	if err := s.store.UpdateEvent(event); err != nil {
+		return Event{}, notFound(err, ErrEventNotFound)
+	}
+	s.publish(EventUpdated, event.ID, event)
+	return event, nil
+}
+
+// DeleteEvent removes the event together with its time slots and the
+// availability collected for them, moving them all to the trash
+func (s *Scheduler) DeleteEvent(eventID string) error {
+	var event Event
+	var availabilityList []UserAvailability
+	var slotList []TimeSlot
+	err := s.store.WithTransaction(func(tx Store) error {
+		var err error
+		if event, err = tx.GetEvent(eventID); err != nil {
+			return err
+		}
+		if event.LegalHold != nil {
+			return ErrLegalHold
+		}
+
+		// Children go first: databases that cascade deletes would hide
+		// them from the trash once the event is gone
+		availabilityList, err = tx.ListAvailability(eventID)
+		if err != nil {
+			return err
+		}
+		for _, avail := range availabilityList {
+			if err := tx.DeleteAvailability(avail.ID); err != nil {
+				return err
+			}
+		}
+
+		slotList, err = tx.ListTimeSlots(eventID)
+		if err != nil {
+			return err
+		}
+		for _, slot := range slotList {
+			if err := tx.DeleteTimeSlot(slot.ID); err != nil {
+				return err
+			}
+		}
+		return tx.DeleteEvent(eventID)
+	})
+	if err != nil {
+		return notFound(err, ErrEventNotFound)
+	}
+	if s.dryRun {
+		return nil
+	}
+	now := s.clock.Now()
+	trash.Add(TrashItem{
+		ID:           eventID,
+		Kind:         TrashEvent,
+		OrgID:        event.OrgID,
+		EventID:      eventID,
+		Title:        event.Title,
+		DeletedAt:    now,
+		ExpiresAt:    now.Add(trashRetention()),
+		Event:        &event,
+		TimeSlots:    slotList,
+		Availability: availabilityList,
+		Comments:     comments.ForEvent(eventID),
+	})
+	intakeAnswers.Forget(eventID)
+	quorumAlerts.Forget(eventID)
+	comments.Forget(eventID)
+	s.publishForOrg(EventDeleted, eventID, event.OrgID, nil)
+	return nil
+}
+
+// FinalizeConflictError reports a finalize that lost to another one. Event
+// is the event as the winner left it, with TimeSlot its final slot.
+type FinalizeConflictError struct {
+	Event    Event
+	TimeSlot *TimeSlot
+}
+
+func (e *FinalizeConflictError) Error() string {
+	return "event was finalized by someone else"
+}
+
+func (e *FinalizeConflictError) Is(target error) bool { return target == ErrConflict }
+
+// sameFinalization reports whether two versions of an event agree on
+// whether, and at which slot, it is finalized
+func sameFinalization(a, b Event) bool {
+	return a.Status == b.Status && a.FinalTimeSlotID == b.FinalTimeSlotID
+}
+
+// finalizeConflict describes the stored event a finalize lost to
+func finalizeConflict(tx Store, eventID string) error {
+	event, err := tx.GetEvent(eventID)
+	if err != nil {
+		return notFound(err, ErrEventNotFound)
+	}
+	conflict := &FinalizeConflictError{Event: event}
+	if slot, err := tx.GetTimeSlot(event.FinalTimeSlotID); err == nil {
+		conflict.TimeSlot = &slot
+	}
+	return conflict
+}
+
+// FinalizeEvent confirms one of the event's time slots as the meeting time.
+// Finalizing at the slot already chosen changes nothing. Moving a finalized
+// event to another slot needs req.ReplaceTimeSlotID to name the current
+// one; otherwise, as when two organizers finalize at once, the later call
+// fails with a FinalizeConflictError rather than overwriting the first.
+func (s *Scheduler) FinalizeEvent(eventID string, req FinalizeEventRequest) (Event, error) {
+	var event Event
+	unchanged := false
+	err := s.store.WithTransaction(func(tx Store) error {
+		var err error
+		event, err = tx.GetEvent(eventID)
+		if err != nil {
+			return notFound(err, ErrEventNotFound)
+		}
+
+		slot, err := tx.GetTimeSlot(req.TimeSlotID)
+		if err != nil {
+			return notFound(err, ErrTimeSlotNotFound)
+		}
+		if slot.EventID != eventID {
+			return ErrTimeSlotNotFound
+		}
+		if event.Status == "finalized" {
+			if event.FinalTimeSlotID == slot.ID {
+				unchanged = true
+				return nil
+			}
+			if event.FinalTimeSlotID != req.ReplaceTimeSlotID {
+				return finalizeConflict(tx, eventID)
+			}
+		}
+		prerequisites, err := prerequisitesOf(tx, event)
+		if err != nil {
+			return err
+		}
+		if slot.StartTime.Before(prerequisites.notBefore) {
+			return ErrBeforePrerequisite
+		}
+
+		expected := event
+		event.Status = "finalized"
+		event.FinalTimeSlotID = slot.ID
+		event.UpdatedAt = s.clock.Now()
+		err = tx.CompareAndSwapEvent(event, expected)
+		if errors.Is(err, ErrConflict) {
+			return finalizeConflict(tx, eventID)
+		}
+		return notFound(err, ErrEventNotFound)
+	})
+	if err != nil {
+		return Event{}, err
+	}
+	if !unchanged {
+		s.publish(EventFinalized, event.ID, event)
+	}
+	return event, nil
+}
+
+// CancelEvent marks an event as cancelled, keeping its slots and responses
+func (s *Scheduler) CancelEvent(eventID string) (Event, error) {
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return Event{}, err
+	}
+
+	event.Status = "cancelled"
+	event.UpdatedAt = s.clock.Now()
+	if err := s.store.UpdateEvent(event); err != nil {
+		return Event{}, notFound(err, ErrEventNotFound)
+	}
+	s.publish(EventCancelled, event.ID, event)
+	return event, nil
+}
+
+// ReassignEvent hands an event to a new organizer
+func (s *Scheduler) ReassignEvent(eventID, organizerID string) (Event, error) {
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return Event{}, err
+	}
+
+	event.OrganizerID = organizerID
+	event.UpdatedAt = s.clock.Now()
+	if err := s.store.UpdateEvent(event); err != nil {
+		return Event{}, notFound(err, ErrEventNotFound)
+	}
+	s.publish(EventUpdated, event.ID, event)
+	return event, nil
+}
+
+// SetProtectedWindowOverride allows or forbids scheduling the event inside
+// its organization's protected windows
+func (s *Scheduler) SetProtectedWindowOverride(eventID string, allow bool) (Event, error) {
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return Event{}, err
+	}
+
+	event.AllowProtectedWindows = allow
+	event.UpdatedAt = s.clock.Now()
+	if err := s.store.UpdateEvent(event); err != nil {
+		return Event{}, notFound(err, ErrEventNotFound)
+	}
+	s.publish(EventUpdated, event.ID, event)
+	return event, nil
+}
+
+// Time slots
+
+func (s *Scheduler) CreateTimeSlot(eventID string, req CreateTimeSlotRequest) (TimeSlot, error) {
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return TimeSlot{}, err
+	}
+	req = normalizeSlotRequest(req, event.slotPrecision())
+	if err := validateTimeSlotRequest(req); err != nil {
+		return TimeSlot{}, err
+	}
+
+	now := s.clock.Now()
+	timeSlot := TimeSlot{
+		ID:        uuid.New().String(),
+		EventID:   eventID,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := s.store.CreateTimeSlot(timeSlot); err != nil {
+		return TimeSlot{}, err
+	}
+	s.publish(TimeSlotCreated, eventID, timeSlot)
+	return timeSlot, nil
+}
+
+func (s *Scheduler) ListTimeSlots(eventID string) ([]TimeSlot, error) {
+	return s.store.ListTimeSlots(eventID)
+}
+
+func (s *Scheduler) GetTimeSlot(timeslotID string) (TimeSlot, error) {
+	slot, err := s.store.GetTimeSlot(timeslotID)
+	if err != nil {
+		return TimeSlot{}, notFound(err, ErrTimeSlotNotFound)
+	}
+	return slot, nil
+}
+
+func (s *Scheduler) UpdateTimeSlot(timeslotID string, req CreateTimeSlotRequest) (TimeSlot, error) {
+	slot, err := s.GetTimeSlot(timeslotID)
+	if err != nil {
+		return TimeSlot{}, err
+	}
+	event, err := s.GetEvent(slot.EventID)
+	if err != nil {
+		return TimeSlot{}, err
+	}
+	req = normalizeSlotRequest(req, event.slotPrecision())
+	if err := validateTimeSlotRequest(req); err != nil {
+		return TimeSlot{}, err
+	}
+
+	slot.StartTime = req.StartTime
+	slot.EndTime = req.EndTime
+	slot.UpdatedAt = s.clock.Now()
+
+	if err := s.store.UpdateTimeSlot(slot); err != nil {
+		return TimeSlot{}, notFound(err, ErrTimeSlotNotFound)
+	}
+	s.publish(TimeSlotUpdated, slot.EventID, slot)
+	return slot, nil
+}
+
+// DeleteTimeSlot removes the slot and any availability recorded against
+// it, moving them to the trash
+func (s *Scheduler) DeleteTimeSlot(timeslotID string) error {
+	var event Event
+	var slot TimeSlot
+	var removed []UserAvailability
+	err := s.store.WithTransaction(func(tx Store) error {
+		var err error
+		if slot, err = tx.GetTimeSlot(timeslotID); err != nil {
+			return err
+		}
+		event, _ = tx.GetEvent(slot.EventID)
+		if event.LegalHold != nil {
+			return ErrLegalHold
+		}
+
+		availabilityList, err := tx.ListAvailability(slot.EventID)
+		if err != nil {
+			return err
+		}
+		for _, avail := range availabilityList {
+			if avail.TimeSlotID != timeslotID {
+				continue
+			}
+			if err := tx.DeleteAvailability(avail.ID); err != nil {
+				return err
+			}
+			removed = append(removed, avail)
+		}
+		return tx.DeleteTimeSlot(timeslotID)
+	})
+	if err != nil {
+		return notFound(err, ErrTimeSlotNotFound)
+	}
+	if s.dryRun {
+		return nil
+	}
+	now := s.clock.Now()
+	trash.Add(TrashItem{
+		ID:           timeslotID,
+		Kind:         TrashTimeSlot,
+		OrgID:        event.OrgID,
+		EventID:      slot.EventID,
+		Title:        event.Title,
+		DeletedAt:    now,
+		ExpiresAt:    now.Add(trashRetention()),
+		TimeSlots:    []TimeSlot{slot},
+		Availability: removed,
+	})
+	s.publish(TimeSlotDeleted, slot.EventID, nil)
+	return nil
+}
+
+// Availability
+
+func (s *Scheduler) SubmitAvailability(eventID, userID string, req UserAvailabilityRequest) (UserAvailability, error) {
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return UserAvailability{}, err
+	}
+	if err := validateAvailabilityStatus(req.Status); err != nil {
+		return UserAvailability{}, err
+	}
+	slot, err := s.store.GetTimeSlot(req.TimeSlotID)
+	if err != nil {
+		return UserAvailability{}, notFound(err, ErrTimeSlotNotFound)
+	}
+	if err := validateCondition(userID, req); err != nil {
+		return UserAvailability{}, err
+	}
+	req = normalizePartialRequest(req, event.slotPrecision())
+	if err := validatePartial(req, slot); err != nil {
+		return UserAvailability{}, err
+	}
+	if err := s.recordAnswers(event, userID, req.Answers); err != nil {
+		return UserAvailability{}, err
+	}
+
+	now := s.clock.Now()
+	availability := UserAvailability{
+		ID:             uuid.New().String(),
+		UserID:         userID,
+		EventID:        eventID,
+		TimeSlotID:     req.TimeSlotID,
+		Status:         req.Status,
+		ConditionalOn:  req.ConditionalOn,
+		AvailableFrom:  req.AvailableFrom,
+		AvailableUntil: req.AvailableUntil,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	if err := s.store.CreateAvailability(availability); err != nil {
+		return UserAvailability{}, err
+	}
+	s.publish(AvailabilitySubmitted, eventID, availability)
+	return availability, nil
+}
+
+func (s *Scheduler) ListUserAvailability(eventID, userID string) ([]UserAvailability, error) {
+	return s.store.ListUserAvailability(eventID, userID)
+}
+
+func (s *Scheduler) GetAvailability(eventID, userID, timeslotID string) (UserAvailability, error) {
+	avail, err := s.store.FindAvailability(eventID, userID, timeslotID)
+	if err != nil {
+		return UserAvailability{}, notFound(err, ErrAvailabilityNotFound)
+	}
+	return avail, nil
+}
+
+func (s *Scheduler) UpdateAvailability(eventID, userID, timeslotID string, req UserAvailabilityRequest) (UserAvailability, error) {
+	avail, err := s.GetAvailability(eventID, userID, timeslotID)
+	if err != nil {
+		return UserAvailability{}, err
+	}
+	if err := validateAvailabilityStatus(req.Status); err != nil {
+		return UserAvailability{}, err
+	}
+	if err := validateCondition(userID, req); err != nil {
+		return UserAvailability{}, err
+	}
+	slot, err := s.store.GetTimeSlot(timeslotID)
+	if err != nil {
+		return UserAvailability{}, notFound(err, ErrTimeSlotNotFound)
+	}
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return UserAvailability{}, err
+	}
+	req = normalizePartialRequest(req, event.slotPrecision())
+	if err := validatePartial(req, slot); err != nil {
+		return UserAvailability{}, err
+	}
+	if len(req.Answers) > 0 {
+		if err := s.recordAnswers(event, userID, req.Answers); err != nil {
+			return UserAvailability{}, err
+		}
+	}
+
+	avail.Status = req.Status
+	avail.ConditionalOn = req.ConditionalOn
+	avail.AvailableFrom = req.AvailableFrom
+	avail.AvailableUntil = req.AvailableUntil
+	avail.UpdatedAt = s.clock.Now()
+
+	if err := s.store.UpdateAvailability(avail); err != nil {
+		return UserAvailability{}, notFound(err, ErrAvailabilityNotFound)
+	}
+	s.publish(AvailabilityUpdated, eventID, avail)
+	return avail, nil
+}
+
+func (s *Scheduler) DeleteAvailability(eventID, userID, timeslotID string) error {
+	avail, err := s.GetAvailability(eventID, userID, timeslotID)
+	if err != nil {
+		return err
+	}
+	err = s.store.WithTransaction(func(tx Store) error {
+		event, err := tx.GetEvent(eventID)
+		if err != nil {
+			return err
+		}
+		if event.LegalHold != nil {
+			return ErrLegalHold
+		}
+		return tx.DeleteAvailability(avail.ID)
+	})
+	if err != nil {
+		return notFound(err, ErrAvailabilityNotFound)
+	}
+	s.publish(AvailabilityDeleted, eventID, avail)
+	return nil
+}
+
+// Recommendations
+
+// Recommendations ranks the event's time slots by how many respondents are
+// available for them
+func (s *Scheduler) Recommendations(eventID string) ([]Recommendation, error) {
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	eventSlots, err := s.store.ListTimeSlots(eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	eventAvailability, err := s.store.ListAvailability(eventID)
+	if err != nil {
+		return nil, err
+	}
+	return s.recommend(event, eventSlots, eventAvailability)
+}
+
+// recommend ranks slots given the event's responses, which needn't be the
+// stored ones
+func (s *Scheduler) recommend(event Event, eventSlots []TimeSlot, eventAvailability []UserAvailability) ([]Recommendation, error) {
+	scorer, err := s.slotScorer(event, eventAvailability)
+	if err != nil {
+		return nil, err
+	}
+	bySlot := map[string][]UserAvailability{}
+	for _, avail := range eventAvailability {
+		bySlot[avail.TimeSlotID] = append(bySlot[avail.TimeSlotID], avail)
+	}
+
+	recommendations := []Recommendation{}
+	for _, slot := range eventSlots {
+		if rec, ok := scorer.score(slot, bySlot[slot.ID]); ok {
+			recommendations = append(recommendations, rec)
+		}
+	}
+	rankRecommendations(recommendations, s.tieSeed)
+	return recommendations, nil
+}
+
+// slotScorer scores an event's slots one at a time. What the slots share,
+// the rules and whom percentages are out of, is worked out once, so a
+// slot can be rescored on its own when only its responses change.
+type slotScorer struct {
+	event Event
+	rules slotRules
+	// respondents are everyone who responded for any slot, and invitees
+	respondents map[string]bool
+}
+
+func (s *Scheduler) slotScorer(event Event, eventAvailability []UserAvailability) (slotScorer, error) {
+	rules, err := s.slotRulesFor(event, eventAvailability)
+	if err != nil {
+		return slotScorer{}, err
+	}
+	respondents := map[string]bool{}
+	for _, avail := range eventAvailability {
+		respondents[avail.UserID] = true
+	}
+	for _, id := range event.Invitees {
+		respondents[id] = true
+	}
+	return slotScorer{event: event, rules: rules, respondents: respondents}, nil
+}
+
+// score rates a slot by the share of respondents available for it, given
+// the slot's own responses. It reports false for slots that aren't
+// recommended at all: those too short for the event or before a
+// prerequisite's confirmed end, and every slot while nobody has been
+// asked.
+func (sc slotScorer) score(slot TimeSlot, slotAvailability []UserAvailability) (Recommendation, bool) {
+	duration := sc.event.duration()
+	if len(sc.respondents) == 0 || slot.StartTime.Before(sc.rules.prerequisites.notBefore) || slot.EndTime.Sub(slot.StartTime) < duration {
+		return Recommendation{}, false
+	}
+
+	// conditions holds whom each conditional response depends on and
+	// partial the part of the slot partial responders can make
+	answered := map[string]bool{}
+	available := map[string]bool{}
+	conditions := map[string]string{}
+	partial := map[string]partialWindow{}
+	for _, avail := range slotAvailability {
+		answered[avail.UserID] = true
+		if avail.Status != "available" {
+			continue
+		}
+		available[avail.UserID] = true
+		if w, ok := partialOf(avail, slot); ok {
+			partial[avail.UserID] = w
+		}
+		if avail.ConditionalOn != "" {
+			conditions[avail.UserID] = avail.ConditionalOn
+		}
+	}
+
+	rec := Recommendation{TimeSlot: slot}
+	for userID := range sc.respondents {
+		if available[userID] {
+			rec.AvailableUsers = append(rec.AvailableUsers, userID)
+		} else {
+			rec.UnavailableUsers = append(rec.UnavailableUsers, userID)
+		}
+	}
+	rec.AvailabilityPercentage = float64(len(rec.AvailableUsers)) / float64(len(sc.respondents)) * 100
+	rec.Score = rec.AvailabilityPercentage
+
+	applyPartial(&rec, partial, duration)
+	meetingStart := slot.StartTime
+	if rec.Window != nil {
+		meetingStart = rec.Window.Start
+	}
+	meetingEnd := meetingStart.Add(duration)
+	sc.rules.applyDeclared(&rec, answered, meetingStart, meetingEnd)
+	conflicted, toMove := sc.rules.conflicts(rec.AvailableUsers, meetingStart, meetingEnd)
+	if len(conflicted) > 0 {
+		markUnavailable(&rec, conflicted)
+	}
+	rec.MeetingsToMove = toMove
+
+	rec.Warnings = sc.rules.violations(slot.StartTime, slot.EndTime)
+	for _, prerequisite := range sc.rules.prerequisites.pending {
+		rec.Warnings = append(rec.Warnings, fmt.Sprintf("Prerequisite %q isn't scheduled yet", prerequisite.Title))
+	}
+	resolveConditions(&rec, conditions)
+	rec.SoftUnavailableUsers = sc.rules.fullyBooked(slot.StartTime)
+	rec.Explanation = explainScore(rec, sc.rules.protectedWindows(slot.StartTime, slot.EndTime))
+	rec.Score = rec.Explanation.Total()
+	return rec, true
+}
+
+// markUnavailable moves users who already have a meeting at the slot's time
+// from available to unavailable and recomputes the slot's scores
+func markUnavailable(rec *Recommendation, userIDs []string) {
+	blocked := make(map[string]bool, len(userIDs))
+	for _, id := range userIDs {
+		blocked[id] = true
+	}
+
+	var available []string
+	for _, id := range rec.AvailableUsers {
+		if blocked[id] {
+			rec.UnavailableUsers = append(rec.UnavailableUsers, id)
+		} else {
+			available = append(available, id)
+		}
+	}
+	rec.AvailableUsers = available
+
+	total := len(rec.AvailableUsers) + len(rec.UnavailableUsers)
+	rec.AvailabilityPercentage = float64(len(rec.AvailableUsers)) / float64(total) * 100
+	rec.Score = rec.AvailabilityPercentage
+}
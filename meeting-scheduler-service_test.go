@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestScheduler(t testing.TB) (*Scheduler, *fakeClock) {
+	resetStorage(t)
+	fake := newFakeClock(time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	return newScheduler(store, fake, newEventBus()), fake
+}
+
+func TestSchedulerValidatesEvents(t *testing.T) {
+	scheduler, _ := newTestScheduler(t)
+
+	_, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "user1", RequiredDuration: -5})
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+
+	_, err = scheduler.GetEvent("missing")
+	assert.ErrorIs(t, err, ErrEventNotFound)
+}
+
+func TestSchedulerRecommendations(t *testing.T) {
+	scheduler, fake := newTestScheduler(t)
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "user1", RequiredDuration: 60})
+	require.NoError(t, err)
+
+	start := fake.Now().Add(24 * time.Hour)
+	short, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(30 * time.Minute)})
+	require.NoError(t, err)
+	first, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start.Add(2 * time.Hour), EndTime: start.Add(3 * time.Hour)})
+	require.NoError(t, err)
+	second, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start.Add(4 * time.Hour), EndTime: start.Add(5 * time.Hour)})
+	require.NoError(t, err)
+
+	submit := func(userID, slotID, status string) {
+		_, err := scheduler.SubmitAvailability(event.ID, userID, UserAvailabilityRequest{TimeSlotID: slotID, Status: status})
+		require.NoError(t, err)
+	}
+	submit("alice", first.ID, "available")
+	submit("bob", first.ID, "unavailable")
+	submit("alice", second.ID, "available")
+	submit("bob", second.ID, "available")
+	submit("bob", short.ID, "available")
+
+	recommendations, err := scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	require.Len(t, recommendations, 2)
+	assert.Equal(t, second.ID, recommendations[0].TimeSlot.ID)
+	assert.Equal(t, 100.0, recommendations[0].AvailabilityPercentage)
+	assert.Equal(t, first.ID, recommendations[1].TimeSlot.ID)
+	assert.Equal(t, 50.0, recommendations[1].AvailabilityPercentage)
+}
+
+func TestSchedulerDeleteTimeSlotCascades(t *testing.T) {
+	scheduler, fake := newTestScheduler(t)
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "user1", RequiredDuration: 30})
+	require.NoError(t, err)
+	start := fake.Now().Add(time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "alice", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+
+	require.NoError(t, scheduler.DeleteTimeSlot(slot.ID))
+
+	_, err = scheduler.GetAvailability(event.ID, "alice", slot.ID)
+	assert.ErrorIs(t, err, ErrAvailabilityNotFound)
+	assert.ErrorIs(t, scheduler.DeleteTimeSlot(slot.ID), ErrTimeSlotNotFound)
+}
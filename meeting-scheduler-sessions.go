@@ -0,0 +1,441 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// accessTokenTTL is how long a bearer token works before the client
+// trades its refresh token for another
+const accessTokenTTL = 15 * time.Minute
+
+// Session is one signed-in device. Its refresh token keeps it signed in
+// until the session expires or is revoked, which also stops its access
+// tokens at once.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"userId"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	ClientIP   string    `json:"clientIp,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	// TwoFactorVerified is set once a two-factor code is entered in the
+	// session
+	TwoFactorVerified bool `json:"twoFactorVerified,omitempty"`
+	// ImpersonatorID is the operator acting as the user in this session
+	ImpersonatorID string `json:"impersonatorId,omitempty"`
+	// Current marks the session a listing was requested from
+	Current bool `json:"current,omitempty"`
+
+	// refreshHash is the hex SHA-256 of the current refresh token, and
+	// previousHash of the one it replaced, to spot a stolen token replayed
+	refreshHash  string
+	previousHash string
+}
+
+// SessionTokens are what a client keeps after signing in or refreshing
+type SessionTokens struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refreshToken"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// sessionTTL is how long a session lasts without being refreshed.
+// SESSION_TTL sets it.
+func sessionTTL() time.Duration {
+	return getenvDuration("SESSION_TTL", 30*24*time.Hour)
+}
+
+// SessionStore keeps sessions where every replica sees them, so a token
+// issued by one replica works on the others and survives restarts
+type SessionStore interface {
+	CreateSession(session Session) error
+	// GetSession returns ErrNotFound for a session that was revoked or
+	// never existed. Expired sessions are returned for the caller to judge.
+	GetSession(id string) (Session, error)
+	// TouchSession notes the session was used at now
+	TouchSession(id string, now time.Time) error
+	// VerifySessionTwoFactor marks the session as having passed two-factor
+	// authentication
+	VerifySessionTwoFactor(id string) error
+	// RotateRefreshToken replaces the session's refresh token hash with
+	// next, keeping presented as the previous one, and extends the session
+	// to expiresAt. It returns ErrNotFound unless presented is still the
+	// current hash, so of two refreshes racing only one wins.
+	RotateRefreshToken(id, presented, next, clientIP string, now, expiresAt time.Time) error
+	// SessionsForUser lists the user's sessions, expired ones included
+	SessionsForUser(userID string) ([]Session, error)
+	DeleteSession(id string) error
+	// PruneSessions forgets sessions that expired by now
+	PruneSessions(now time.Time) error
+}
+
+// sessionStoreFor is the database underneath the store when it can keep
+// sessions. Otherwise they are kept in memory, so they end with the
+// process and each replica knows only its own.
+func sessionStoreFor(s Store) SessionStore {
+	if sessionStore, ok := underlyingStore(s).(SessionStore); ok {
+		return sessionStore
+	}
+	return newMemorySessions()
+}
+
+// hashRefreshSecret is how refresh tokens are kept, so the store never
+// holds a usable one
+func hashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// sessionRegistry starts, checks and ends sessions kept in a SessionStore
+type sessionRegistry struct {
+	store SessionStore
+}
+
+// newSessionRegistry returns a registry keeping sessions in memory
+func newSessionRegistry() *sessionRegistry {
+	return &sessionRegistry{store: newMemorySessions()}
+}
+
+// Start opens a session and returns its first refresh token, which is the
+// session ID and a secret
+func (r *sessionRegistry) Start(userID, userAgent, clientIP string, now time.Time) (Session, string, error) {
+	secret := randomToken()
+	session := Session{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		UserAgent:   userAgent,
+		ClientIP:    clientIP,
+		CreatedAt:   now,
+		LastUsedAt:  now,
+		ExpiresAt:   now.Add(sessionTTL()),
+		refreshHash: hashRefreshSecret(secret),
+	}
+	if err := r.store.CreateSession(session); err != nil {
+		return Session{}, "", err
+	}
+	return session, session.ID + "." + secret, nil
+}
+
+// StartImpersonation opens a session for an operator acting as the user.
+// It has no refresh token, so it ends at expiresAt.
+func (r *sessionRegistry) StartImpersonation(userID, impersonatorID, clientIP string, now, expiresAt time.Time) (Session, error) {
+	session := Session{
+		ID:             uuid.New().String(),
+		UserID:         userID,
+		ClientIP:       clientIP,
+		CreatedAt:      now,
+		LastUsedAt:     now,
+		ExpiresAt:      expiresAt,
+		ImpersonatorID: impersonatorID,
+	}
+	if err := r.store.CreateSession(session); err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+// Active returns the session if it hasn't expired or been revoked, and
+// notes it was used. Sessions that have ended are ErrNotFound.
+func (r *sessionRegistry) Active(id string, now time.Time) (Session, error) {
+	session, err := r.store.GetSession(id)
+	if err != nil {
+		return Session{}, err
+	}
+	if !now.Before(session.ExpiresAt) {
+		return Session{}, ErrNotFound
+	}
+	if err := r.store.TouchSession(id, now); err != nil {
+		return Session{}, err
+	}
+	session.LastUsedAt = now
+	return session, nil
+}
+
+// VerifyTwoFactor records that the session passed two-factor
+// authentication
+func (r *sessionRegistry) VerifyTwoFactor(id string) error {
+	return r.store.VerifySessionTwoFactor(id)
+}
+
+// TwoFactorVerified reports whether the session passed two-factor
+// authentication. One that can't be read hasn't.
+func (r *sessionRegistry) TwoFactorVerified(id string) bool {
+	session, err := r.store.GetSession(id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("Reading session %s: %v", id, err)
+	}
+	return err == nil && session.TwoFactorVerified
+}
+
+// Refresh swaps a refresh token for a new one and extends the session.
+// Presenting the token it replaced means one of the two was stolen, so
+// the session is revoked. Unknown, expired and replayed tokens are
+// ErrNotFound.
+func (r *sessionRegistry) Refresh(refreshToken, clientIP string, now time.Time) (Session, string, error) {
+	id, secret, _ := strings.Cut(refreshToken, ".")
+	hash := hashRefreshSecret(secret)
+
+	session, err := r.store.GetSession(id)
+	if err != nil {
+		return Session{}, "", err
+	}
+	if !now.Before(session.ExpiresAt) {
+		return Session{}, "", ErrNotFound
+	}
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(session.previousHash)) == 1 {
+		if err := r.store.DeleteSession(id); err != nil {
+			return Session{}, "", err
+		}
+		return Session{}, "", ErrNotFound
+	}
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(session.refreshHash)) != 1 {
+		return Session{}, "", ErrNotFound
+	}
+	next := randomToken()
+	expiresAt := now.Add(sessionTTL())
+	if err := r.store.RotateRefreshToken(id, hash, hashRefreshSecret(next), clientIP, now, expiresAt); err != nil {
+		return Session{}, "", err
+	}
+	session.previousHash, session.refreshHash = hash, hashRefreshSecret(next)
+	session.ClientIP, session.LastUsedAt, session.ExpiresAt = clientIP, now, expiresAt
+	return session, id + "." + next, nil
+}
+
+// ForUser lists the user's live sessions, most recently used first
+func (r *sessionRegistry) ForUser(userID string, now time.Time) ([]Session, error) {
+	all, err := r.store.SessionsForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	list := []Session{}
+	for _, session := range all {
+		if now.Before(session.ExpiresAt) {
+			list = append(list, session)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].LastUsedAt.After(list[j].LastUsedAt) })
+	return list, nil
+}
+
+// Revoke ends one of the user's sessions. Other users' sessions are
+// ErrNotFound.
+func (r *sessionRegistry) Revoke(userID, id string) error {
+	session, err := r.store.GetSession(id)
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID {
+		return ErrNotFound
+	}
+	return r.store.DeleteSession(id)
+}
+
+func (r *sessionRegistry) purgeExpired(now time.Time) {
+	if err := r.store.PruneSessions(now); err != nil {
+		log.Printf("Pruning sessions failed, will retry: %v", err)
+	}
+}
+
+var sessions = newSessionRegistry()
+
+// pruneSessions drops expired sessions from the active registry
+func pruneSessions(now time.Time) {
+	sessions.purgeExpired(now)
+}
+
+// memorySessions is the SessionStore for single-instance deployments
+type memorySessions struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+func newMemorySessions() *memorySessions {
+	return &memorySessions{sessions: make(map[string]Session)}
+}
+
+func (m *memorySessions) CreateSession(session Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[session.ID] = session
+	return nil
+}
+
+func (m *memorySessions) GetSession(id string) (Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok {
+		return Session{}, ErrNotFound
+	}
+	return session, nil
+}
+
+func (m *memorySessions) TouchSession(id string, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	session.LastUsedAt = now
+	m.sessions[id] = session
+	return nil
+}
+
+func (m *memorySessions) VerifySessionTwoFactor(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	session.TwoFactorVerified = true
+	m.sessions[id] = session
+	return nil
+}
+
+func (m *memorySessions) RotateRefreshToken(id, presented, next, clientIP string, now, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok || session.refreshHash != presented {
+		return ErrNotFound
+	}
+	session.previousHash, session.refreshHash = presented, next
+	session.ClientIP, session.LastUsedAt, session.ExpiresAt = clientIP, now, expiresAt
+	m.sessions[id] = session
+	return nil
+}
+
+func (m *memorySessions) SessionsForUser(userID string) ([]Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []Session
+	for _, session := range m.sessions {
+		if session.UserID == userID {
+			list = append(list, session)
+		}
+	}
+	return list, nil
+}
+
+func (m *memorySessions) DeleteSession(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *memorySessions) PruneSessions(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, session := range m.sessions {
+		if !now.Before(session.ExpiresAt) {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
+
+// startSession signs the user in on the requesting device
+func startSession(c *gin.Context, user User) (SessionTokens, error) {
+	now := clock.Now()
+	session, refreshToken, err := sessions.Start(user.ID, c.Request.UserAgent(), c.ClientIP(), now)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	token, err := signAccessToken(user, session.ID, now, now.Add(accessTokenTTL))
+	if err != nil {
+		sessions.Revoke(user.ID, session.ID)
+		return SessionTokens{}, err
+	}
+	return SessionTokens{Token: token, RefreshToken: refreshToken, ExpiresAt: now.Add(accessTokenTTL)}, nil
+}
+
+// refreshSession trades a refresh token for a new access token and
+// refresh token
+func refreshSession(c *gin.Context) {
+	var req RefreshRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	now := clock.Now()
+	session, refreshToken, err := sessions.Refresh(req.RefreshToken, c.ClientIP(), now)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	user, ok := users.Get(session.UserID)
+	if !ok || user.Deactivated {
+		sessions.Revoke(session.UserID, session.ID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unknown user"})
+		return
+	}
+	token, err := signAccessToken(user, session.ID, now, now.Add(accessTokenTTL))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, SessionTokens{Token: token, RefreshToken: refreshToken, ExpiresAt: now.Add(accessTokenTTL)})
+}
+
+// Session handlers act on the signed-in user's devices
+func listMySessions(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	current, _ := c.Get(currentSessionKey)
+	list, err := sessions.ForUser(user.ID, clock.Now())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	for i := range list {
+		list[i].Current = list[i].ID == current
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// revokeMySession signs one of the user's devices out; revoking the
+// current session logs out
+func revokeMySession(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	err := sessions.Revoke(user.ID, c.Param("sessionId"))
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionsCanBeListedAndRevoked(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	previous := sessions
+	sessions = newSessionRegistry()
+	t.Cleanup(func() { sessions = previous })
+	ada := User{ID: "ada", OrgID: "acme", Role: RoleMember}
+	bob := User{ID: "bob", OrgID: "acme", Role: RoleMember}
+	users.Save(ada)
+	users.Save(bob)
+
+	send := func(method, path, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	laptop, err := issueSessionToken(ada)
+	require.NoError(t, err)
+	phone, err := issueSessionToken(ada)
+	require.NoError(t, err)
+	bobs, err := issueSessionToken(bob)
+	require.NoError(t, err)
+
+	w := send(http.MethodGet, "/api/v1/users/me/sessions", laptop)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list []Session
+	decodeJSON(t, w, &list)
+	require.Len(t, list, 2)
+	assert.True(t, list[0].Current, "the session asking was used last")
+	assert.False(t, list[1].Current)
+	phoneID := list[1].ID
+
+	assert.Equal(t, http.StatusNotFound, send(http.MethodDelete, "/api/v1/users/me/sessions/"+phoneID, bobs).Code, "only the owner can revoke")
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/v1/users/me", phone).Code)
+	assert.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/api/v1/users/me/sessions/"+phoneID, laptop).Code)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/api/v1/users/me", phone).Code, "the lost device is signed out")
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/v1/users/me", laptop).Code)
+}
+
+func TestRefreshTokensRotate(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	fake := useFakeClock(t, time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	previous := sessions
+	sessions = newSessionRegistry()
+	t.Cleanup(func() { sessions = previous })
+	users.Save(User{ID: "ada", OrgID: "acme", Role: RoleMember})
+
+	session, first, err := sessions.Start("ada", "test", "192.0.2.1", fake.Now())
+	require.NoError(t, err)
+	refresh := func(token string) *httptest.ResponseRecorder {
+		return doJSON(router, http.MethodPost, "/api/v1/auth/refresh", RefreshRequest{RefreshToken: token})
+	}
+
+	fake.Advance(accessTokenTTL + time.Minute)
+	w := refresh(first)
+	require.Equal(t, http.StatusOK, w.Code)
+	var tokens SessionTokens
+	decodeJSON(t, w, &tokens)
+	assert.NotEqual(t, first, tokens.RefreshToken)
+	assert.Equal(t, fake.Now().Add(accessTokenTTL), tokens.ExpiresAt)
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/users/me", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.Token)
+	me := httptest.NewRecorder()
+	router.ServeHTTP(me, req)
+	assert.Equal(t, http.StatusOK, me.Code)
+
+	assert.Equal(t, http.StatusUnauthorized, refresh(first).Code, "a replaced token is refused")
+	assert.Equal(t, http.StatusUnauthorized, refresh(tokens.RefreshToken).Code, "and its reuse ended the session")
+	live, err := sessions.ForUser("ada", fake.Now())
+	require.NoError(t, err)
+	assert.Empty(t, live)
+
+	_, expiring, err := sessions.Start("ada", "test", "192.0.2.1", fake.Now())
+	require.NoError(t, err)
+	fake.Advance(sessionTTL())
+	assert.Equal(t, http.StatusUnauthorized, refresh(expiring).Code)
+	sessions.purgeExpired(fake.Now())
+	_, err = sessions.Active(session.ID, fake.Now())
+	assert.ErrorIs(t, err, ErrNotFound)
+}
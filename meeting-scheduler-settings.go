@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Settings levels, broadest first. Each level overrides the ones before.
+const (
+	SettingsSystem       = "system"
+	SettingsOrganization = "organization"
+	SettingsOrganizer    = "organizer"
+	SettingsEvent        = "event"
+)
+
+// Notification channels an event's messages can be limited to
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+	ChannelPush  = "push"
+)
+
+var validChannels = map[string]bool{ChannelEmail: true, ChannelSMS: true, ChannelPush: true}
+
+// WorkingHours is the part of the day meetings are expected in
+type WorkingHours struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+func (w WorkingHours) validate() error {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return err
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return err
+	}
+	if end <= start {
+		return fmt.Errorf("working hours must end after they start")
+	}
+	if _, err := time.LoadLocation(w.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone %q", w.TimeZone)
+	}
+	return nil
+}
+
+// EventSettings are defaults that cascade from the system to
+// organizations, organizers and single events. Unset fields inherit from
+// the level above.
+type EventSettings struct {
+	// DurationMinutes is used when an event is created without a duration
+	DurationMinutes int           `json:"durationMinutes,omitempty"`
+	WorkingHours    *WorkingHours `json:"workingHours,omitempty"`
+	// NotificationChannels are the channels the event's messages may use
+	NotificationChannels []string `json:"notificationChannels,omitempty"`
+	// QuorumPercentage sets up a quorum alert on new events
+	QuorumPercentage float64 `json:"quorumPercentage,omitempty"`
+}
+
+func (s EventSettings) validate() error {
+	if s.DurationMinutes < 0 {
+		return fmt.Errorf("default duration cannot be negative")
+	}
+	if s.WorkingHours != nil {
+		if err := s.WorkingHours.validate(); err != nil {
+			return err
+		}
+	}
+	for _, channel := range s.NotificationChannels {
+		if !validChannels[channel] {
+			return fmt.Errorf("notification channels must be email, sms or push")
+		}
+	}
+	if s.QuorumPercentage < 0 || s.QuorumPercentage > 100 {
+		return fmt.Errorf("quorum must be between 0 and 100")
+	}
+	return nil
+}
+
+// systemSettings are the deployment's defaults, from the DEFAULT_*
+// variables
+func systemSettings() EventSettings {
+	var channels []string
+	for _, channel := range strings.Split(getenv("DEFAULT_NOTIFICATION_CHANNELS", "email,sms,push"), ",") {
+		if channel = strings.TrimSpace(channel); channel != "" {
+			channels = append(channels, channel)
+		}
+	}
+	return EventSettings{
+		DurationMinutes: getenvInt("DEFAULT_DURATION_MINUTES", 0),
+		WorkingHours: &WorkingHours{
+			Start:    getenv("DEFAULT_WORKING_HOURS_START", "09:00"),
+			End:      getenv("DEFAULT_WORKING_HOURS_END", "17:00"),
+			TimeZone: getenv("DEFAULT_TIME_ZONE", "UTC"),
+		},
+		NotificationChannels: channels,
+		QuorumPercentage:     float64(getenvInt("DEFAULT_QUORUM_PERCENTAGE", 0)),
+	}
+}
+
+// EffectiveSettings are an event's settings once every level is applied.
+// Sources names the level each setting came from.
+type EffectiveSettings struct {
+	EventSettings
+	Sources map[string]string `json:"sources"`
+}
+
+type settingsLevel struct {
+	source   string
+	settings *EventSettings
+}
+
+// cascadeSettings applies each level's set fields over the ones before
+func cascadeSettings(levels []settingsLevel) EffectiveSettings {
+	effective := EffectiveSettings{Sources: map[string]string{}}
+	for _, level := range levels {
+		s := level.settings
+		if s == nil {
+			continue
+		}
+		if s.DurationMinutes > 0 {
+			effective.DurationMinutes = s.DurationMinutes
+			effective.Sources["durationMinutes"] = level.source
+		}
+		if s.WorkingHours != nil {
+			hours := *s.WorkingHours
+			effective.WorkingHours = &hours
+			effective.Sources["workingHours"] = level.source
+		}
+		if len(s.NotificationChannels) > 0 {
+			effective.NotificationChannels = append([]string{}, s.NotificationChannels...)
+			effective.Sources["notificationChannels"] = level.source
+		}
+		if s.QuorumPercentage > 0 {
+			effective.QuorumPercentage = s.QuorumPercentage
+			effective.Sources["quorumPercentage"] = level.source
+		}
+	}
+	return effective
+}
+
+// resolveSettings cascades the system defaults, the organizer's
+// organization, the organizer and the event's own settings
+func resolveSettings(organizerID string, event *EventSettings) EffectiveSettings {
+	system := systemSettings()
+	levels := []settingsLevel{{SettingsSystem, &system}}
+	if organizer, ok := users.Get(organizerID); ok {
+		if org, ok := organizations.Get(organizer.OrgID); ok {
+			levels = append(levels, settingsLevel{SettingsOrganization, org.Settings})
+		}
+		levels = append(levels, settingsLevel{SettingsOrganizer, organizer.Settings.EventDefaults})
+	}
+	levels = append(levels, settingsLevel{SettingsEvent, event})
+	return cascadeSettings(levels)
+}
+
+func (e Event) effectiveSettings() EffectiveSettings {
+	return resolveSettings(e.OrganizerID, e.Settings)
+}
+
+// allowsChannel reports whether the event's messages may go out over
+// channel
+func (s EffectiveSettings) allowsChannel(channel string) bool {
+	for _, allowed := range s.NotificationChannels {
+		if allowed == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// withDefaultDuration gives a request with no duration, even from its
+// meeting type or workspace, the cascaded default
+func withDefaultDuration(req CreateEventRequest) CreateEventRequest {
+	if req.RequiredDuration == 0 && req.DurationSeconds == 0 {
+		req.RequiredDuration = resolveSettings(req.OrganizerID, req.Settings).DurationMinutes
+	}
+	return req
+}
+
+// addDefaultQuorumAlert sets up the quorum alert a new event's settings
+// ask for
+func (s *Scheduler) addDefaultQuorumAlert(event Event) {
+	quorum := event.effectiveSettings().QuorumPercentage
+	if s.dryRun || quorum <= 0 {
+		return
+	}
+	quorumAlerts.Save(QuorumAlert{
+		ID:                  uuid.New().String(),
+		EventID:             event.ID,
+		ThresholdPercentage: quorum,
+		CreatedAt:           s.clock.Now(),
+	})
+}
+
+// channelNotifier delivers over one channel only for events whose settings
+// allow it. Messages that aren't about an event always go through.
+type channelNotifier struct {
+	channel string
+	next    Notifier
+}
+
+func (n channelNotifier) Notify(msg Message) error {
+	if msg.EventID != "" {
+		if event, err := store.GetEvent(msg.EventID); err == nil && !event.effectiveSettings().allowsChannel(n.channel) {
+			return nil
+		}
+	}
+	return n.next.Notify(msg)
+}
+
+// Settings handlers
+func getEventSettings(c *gin.Context) {
+	event, err := contextScheduler(c).GetEvent(c.Param("eventId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, event.effectiveSettings())
+}
+
+func getOrganizationSettings(c *gin.Context) {
+	org, ok := organizations.Get(c.Param("orgId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+	settings := EventSettings{}
+	if org.Settings != nil {
+		settings = *org.Settings
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+func updateOrganizationSettings(c *gin.Context) {
+	org, ok := organizations.Get(c.Param("orgId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	var req EventSettings
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org.Settings = &req
+	org.UpdatedAt = clock.Now()
+	organizations.Save(org)
+	c.JSON(http.StatusOK, req)
+}
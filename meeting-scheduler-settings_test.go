@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettingsCascadeToEvents(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	previousAlerts := quorumAlerts
+	quorumAlerts = newAlertRegistry()
+	t.Cleanup(func() { quorumAlerts = previousAlerts })
+	t.Setenv("DEFAULT_DURATION_MINUTES", "30")
+	scheduler, _ := newTestScheduler(t)
+
+	organizations.Save(Organization{ID: "acme", Settings: &EventSettings{
+		DurationMinutes:      45,
+		WorkingHours:         &WorkingHours{Start: "08:00", End: "16:00", TimeZone: "Europe/Berlin"},
+		NotificationChannels: []string{ChannelEmail},
+	}})
+	users.Save(User{ID: "ada", OrgID: "acme", Role: RoleOrganizer, Settings: UserSettings{EventDefaults: &EventSettings{QuorumPercentage: 60}}})
+	users.Save(User{ID: "sol", OrgID: "solo", Role: RoleOrganizer})
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada"})
+	require.NoError(t, err)
+	assert.Equal(t, 45, event.RequiredDuration, "the organization's default duration")
+	alerts := quorumAlerts.ForEvent(event.ID)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, 60.0, alerts[0].ThresholdPercentage, "the organizer's default quorum")
+
+	own, err := scheduler.CreateEvent(CreateEventRequest{Title: "Standup", OrganizerID: "ada", RequiredDuration: 15, Settings: &EventSettings{NotificationChannels: []string{ChannelSMS, ChannelPush}}})
+	require.NoError(t, err)
+	assert.Equal(t, 15, own.RequiredDuration)
+
+	solo, err := scheduler.CreateEvent(CreateEventRequest{Title: "Focus", OrganizerID: "sol"})
+	require.NoError(t, err)
+	assert.Equal(t, 30, solo.RequiredDuration, "the system default duration")
+	assert.Empty(t, quorumAlerts.ForEvent(solo.ID))
+
+	_, err = scheduler.CreateEvent(CreateEventRequest{Title: "Bad", OrganizerID: "ada", RequiredDuration: 30, Settings: &EventSettings{NotificationChannels: []string{"fax"}}})
+	var validation *ValidationError
+	assert.ErrorAs(t, err, &validation)
+
+	w := doJSON(router, http.MethodGet, "/api/v1/events/"+own.ID+"/settings", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var effective EffectiveSettings
+	decodeJSON(t, w, &effective)
+	assert.Equal(t, 45, effective.DurationMinutes)
+	assert.Equal(t, &WorkingHours{Start: "08:00", End: "16:00", TimeZone: "Europe/Berlin"}, effective.WorkingHours)
+	assert.Equal(t, []string{ChannelSMS, ChannelPush}, effective.NotificationChannels)
+	assert.Equal(t, 60.0, effective.QuorumPercentage)
+	assert.Equal(t, map[string]string{
+		"durationMinutes":      SettingsOrganization,
+		"workingHours":         SettingsOrganization,
+		"notificationChannels": SettingsEvent,
+		"quorumPercentage":     SettingsOrganizer,
+	}, effective.Sources)
+
+	w = doJSON(router, http.MethodGet, "/api/v1/events/"+solo.ID+"/settings", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeJSON(t, w, &effective)
+	assert.Equal(t, []string{ChannelEmail, ChannelSMS, ChannelPush}, effective.NotificationChannels)
+	assert.Equal(t, SettingsSystem, effective.Sources["workingHours"])
+}
+
+func TestChannelNotifierFollowsEventSettings(t *testing.T) {
+	resetDirectory(t)
+	newTestRouter(t)
+	scheduler, _ := newTestScheduler(t)
+	organizations.Save(Organization{ID: "acme", Settings: &EventSettings{NotificationChannels: []string{ChannelEmail}}})
+	users.Save(User{ID: "ada", OrgID: "acme", Role: RoleOrganizer})
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+
+	email, sms := &recordingNotifier{}, &recordingNotifier{}
+	channels := multiNotifier{channelNotifier{channel: ChannelEmail, next: email}, channelNotifier{channel: ChannelSMS, next: sms}}
+	require.NoError(t, channels.Notify(Message{EventID: event.ID, Subject: "Invitation: Kickoff"}))
+	require.NoError(t, channels.Notify(Message{Subject: "Welcome"}))
+	assert.Len(t, email.messages, 2)
+	require.Len(t, sms.messages, 1, "only messages outside an event use SMS")
+	assert.Equal(t, "Welcome", sms.messages[0].Subject)
+}
+
+func TestOrganizationSettingsEndpoint(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	admin := User{ID: "root", OrgID: "acme", Role: RoleAdmin}
+	users.Save(admin)
+	organizations.Save(Organization{ID: "acme"})
+	token, err := issueSessionToken(admin)
+	require.NoError(t, err)
+
+	put := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPut, "/api/v1/organizations/acme/settings", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusBadRequest, put(`{"workingHours": {"start": "17:00", "end": "09:00"}}`).Code)
+	assert.Equal(t, http.StatusBadRequest, put(`{"quorumPercentage": 120}`).Code)
+	require.Equal(t, http.StatusOK, put(`{"durationMinutes": 50, "notificationChannels": ["push"]}`).Code)
+	org, _ := organizations.Get("acme")
+	assert.Equal(t, &EventSettings{DurationMinutes: 50, NotificationChannels: []string{ChannelPush}}, org.Settings)
+}
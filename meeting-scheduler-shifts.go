@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxShiftCycleDays bounds OnDays+OffDays
+const maxShiftCycleDays = 56
+
+// ShiftPattern is a rotating shift rule such as 4-on-4-off: starting on
+// Anchor, the user works OnDays consecutive days from Start to End, then
+// has OffDays off, repeating. An End before Start is an overnight shift.
+type ShiftPattern struct {
+	// Anchor is the first day of an "on" run, as YYYY-MM-DD
+	Anchor   string `json:"anchor"`
+	OnDays   int    `json:"onDays"`
+	OffDays  int    `json:"offDays"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+func (p ShiftPattern) validate() error {
+	if _, err := time.Parse("2006-01-02", p.Anchor); err != nil {
+		return fmt.Errorf("shift anchor must be a date such as 2025-01-06")
+	}
+	if p.OnDays < 1 || p.OffDays < 0 || p.OnDays > maxShiftCycleDays || p.OffDays > maxShiftCycleDays-p.OnDays {
+		return fmt.Errorf("shift must have at least one on day and a cycle of at most %d days", maxShiftCycleDays)
+	}
+	start, err := parseClock(p.Start)
+	if err != nil {
+		return err
+	}
+	end, err := parseClock(p.End)
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return fmt.Errorf("shift must not start and end at the same time")
+	}
+	if _, err := time.LoadLocation(p.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone %q", p.TimeZone)
+	}
+	return nil
+}
+
+// onShift reports whether a shift starts on the given local day
+func (p ShiftPattern) onShift(anchor, day time.Time) bool {
+	days := int(time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC).Sub(anchor).Hours() / 24)
+	cycle := p.OnDays + p.OffDays
+	return ((days%cycle)+cycle)%cycle < p.OnDays
+}
+
+// Occurrences expands the pattern into the shifts intersecting [from, to),
+// as free intervals. Patterns are validated when saved, so parse errors
+// mean no shifts.
+func (p ShiftPattern) Occurrences(from, to time.Time) []DeclaredInterval {
+	loc, err := time.LoadLocation(p.TimeZone)
+	if err != nil {
+		return nil
+	}
+	anchor, err := time.Parse("2006-01-02", p.Anchor)
+	if err != nil || p.OnDays+p.OffDays == 0 {
+		return nil
+	}
+	startOffset, err := parseClock(p.Start)
+	if err != nil {
+		return nil
+	}
+	endOffset, err := parseClock(p.End)
+	if err != nil {
+		return nil
+	}
+	if endOffset <= startOffset {
+		endOffset += 24 * time.Hour
+	}
+
+	var shifts []DeclaredInterval
+	local := from.In(loc)
+	// Start a day early to catch an overnight shift running into from
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, -1)
+	for day.Before(to) {
+		if p.onShift(anchor, day) {
+			shiftStart, shiftEnd := atClock(day, startOffset), atClock(day, endOffset)
+			// A shift ending in a spring-forward gap can come out empty
+			if shiftStart.Before(shiftEnd) && shiftStart.Before(to) && from.Before(shiftEnd) {
+				shifts = append(shifts, DeclaredInterval{Source: "shift", Start: shiftStart.UTC(), End: shiftEnd.UTC(), Status: DeclaredFree})
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return shifts
+}
+
+// Covers reports whether [start, end) falls entirely within one shift
+func (p ShiftPattern) Covers(start, end time.Time) bool {
+	for _, shift := range p.Occurrences(start, end) {
+		if !start.Before(shift.Start) && !end.After(shift.End) {
+			return true
+		}
+	}
+	return false
+}
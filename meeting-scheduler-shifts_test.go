@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShiftPatternOccurrences(t *testing.T) {
+	nights := ShiftPattern{Anchor: "2025-01-06", OnDays: 4, OffDays: 4, Start: "19:00", End: "07:00", TimeZone: "UTC"}
+	require.NoError(t, nights.validate())
+
+	monday := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	shifts := nights.Occurrences(monday, monday.AddDate(0, 0, 16))
+	var starts []int
+	for _, shift := range shifts {
+		starts = append(starts, shift.Start.Day())
+		assert.Equal(t, 12*time.Hour, shift.End.Sub(shift.Start))
+	}
+	assert.Equal(t, []int{6, 7, 8, 9, 14, 15, 16, 17}, starts)
+
+	assert.True(t, nights.Covers(monday.Add(30*time.Hour), monday.Add(31*time.Hour)))
+	assert.False(t, nights.Covers(monday.Add(12*time.Hour), monday.Add(13*time.Hour)))
+	// Before the anchor the cycle runs backwards: Jan 2-5 are off
+	assert.False(t, nights.Covers(monday.Add(-24*time.Hour+20*time.Hour), monday.Add(-24*time.Hour+21*time.Hour)))
+
+	for _, bad := range []ShiftPattern{
+		{Anchor: "monday", OnDays: 4, OffDays: 4, Start: "07:00", End: "19:00"},
+		{Anchor: "2025-01-06", OnDays: 0, OffDays: 4, Start: "07:00", End: "19:00"},
+		{Anchor: "2025-01-06", OnDays: 4, OffDays: 4, Start: "07:00", End: "07:00"},
+	} {
+		assert.Error(t, bad.validate())
+	}
+}
+
+func TestShiftWorkersCountWithoutResponding(t *testing.T) {
+	resetDirectory(t)
+	resetDeclared(t)
+	scheduler, fake := newTestScheduler(t)
+	users.Save(User{ID: "ada", OrgID: "acme"})
+	users.Save(User{ID: "sam", OrgID: "acme", Settings: UserSettings{
+		Shift: &ShiftPattern{Anchor: "2025-01-13", OnDays: 2, OffDays: 2, Start: "07:00", End: "19:00", TimeZone: "UTC"},
+	}})
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Handover", OrganizerID: "ada", RequiredDuration: 60, Invitees: []string{"sam"}})
+	require.NoError(t, err)
+	monday := fake.Now().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	onShift, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: monday.Add(9 * time.Hour), EndTime: monday.Add(10 * time.Hour)})
+	require.NoError(t, err)
+	offShift, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: monday.AddDate(0, 0, 2).Add(9 * time.Hour), EndTime: monday.AddDate(0, 0, 2).Add(10 * time.Hour)})
+	require.NoError(t, err)
+
+	recommendations, err := scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	require.Len(t, recommendations, 2)
+	assert.Equal(t, onShift.ID, recommendations[0].TimeSlot.ID)
+	assert.Equal(t, []string{"sam"}, recommendations[0].AvailableUsers)
+	assert.Equal(t, offShift.ID, recommendations[1].TimeSlot.ID)
+	assert.Equal(t, []string{"sam"}, recommendations[1].UnavailableUsers)
+
+	// An explicit response still wins over the pattern
+	_, err = scheduler.SubmitAvailability(event.ID, "sam", UserAvailabilityRequest{TimeSlotID: onShift.ID, Status: "unavailable"})
+	require.NoError(t, err)
+	recommendations, err = scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	for _, rec := range recommendations {
+		assert.Empty(t, rec.AvailableUsers)
+	}
+}
+
+// FuzzShiftPatternOccurrences expands arbitrary valid patterns, checking
+// each shift is non-empty, inside the range asked for, in order and
+// covered by the pattern
+func FuzzShiftPatternOccurrences(f *testing.F) {
+	f.Add("2025-01-06", 4, 4, "19:00", "07:00", "UTC", int64(1736121600), uint16(16*24))
+	f.Add("2025-03-01", 1, 0, "01:00", "02:00", "America/New_York", int64(1741392000), uint16(72))
+	f.Add("2024-10-27", 2, 5, "00:30", "23:30", "Europe/London", int64(1729987200), uint16(200))
+	f.Fuzz(func(t *testing.T, anchor string, onDays, offDays int, start, end, zone string, fromUnix int64, hours uint16) {
+		p := ShiftPattern{Anchor: anchor, OnDays: onDays, OffDays: offDays, Start: start, End: end, TimeZone: zone}
+		if p.validate() != nil {
+			return
+		}
+		// 1900 to 2100, and at most 60 days
+		const span = 200 * 365 * 24 * 3600
+		from := time.Unix(-2208988800+(fromUnix%span+span)%span, 0).UTC()
+		to := from.Add(time.Duration(hours%(60*24)) * time.Hour)
+
+		shifts := p.Occurrences(from, to)
+		for i, shift := range shifts {
+			if !shift.End.After(shift.Start) {
+				t.Fatalf("shift %d is empty: %s-%s", i, shift.Start, shift.End)
+			}
+			if shift.End.Sub(shift.Start) > 25*time.Hour {
+				t.Fatalf("shift %d lasts %s", i, shift.End.Sub(shift.Start))
+			}
+			if !shift.Start.Before(to) || !from.Before(shift.End) {
+				t.Fatalf("shift %d, %s-%s, is outside %s-%s", i, shift.Start, shift.End, from, to)
+			}
+			if i > 0 && !shifts[i-1].Start.Before(shift.Start) {
+				t.Fatalf("shift %d starts before shift %d", i, i-1)
+			}
+			if !p.Covers(shift.Start, shift.End) {
+				t.Fatalf("shift %s-%s isn't covered by its own pattern", shift.Start, shift.End)
+			}
+		}
+	})
+}
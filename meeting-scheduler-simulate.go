@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SimulateRequest describes hypothetical changes to an event. Nothing is
+// persisted; the recommendations are computed as if the changes had been
+// made.
+type SimulateRequest struct {
+	// DropParticipants are left out, responses and all
+	DropParticipants []string `json:"dropParticipants"`
+	// SlotChanges move or extend existing time slots
+	SlotChanges []SimulatedSlotChange `json:"slotChanges"`
+	// DurationMinutes overrides the event's required duration
+	DurationMinutes int `json:"durationMinutes"`
+	// MinAvailabilityPercentage is the quorum: slots below it are left out
+	MinAvailabilityPercentage float64 `json:"minAvailabilityPercentage"`
+}
+
+// SimulatedSlotChange replaces a slot's start and/or end time
+type SimulatedSlotChange struct {
+	TimeSlotID string     `json:"timeslotId" binding:"required"`
+	StartTime  *time.Time `json:"startTime"`
+	EndTime    *time.Time `json:"endTime"`
+}
+
+// Simulate returns the event's recommendations under hypothetical changes
+func (s *Scheduler) Simulate(eventID string, req SimulateRequest) ([]Recommendation, error) {
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return nil, err
+	}
+	if req.DurationMinutes < 0 {
+		return nil, invalid("Duration must be positive")
+	}
+	if req.MinAvailabilityPercentage < 0 || req.MinAvailabilityPercentage > 100 {
+		return nil, invalid("Minimum availability must be between 0 and 100")
+	}
+	eventSlots, err := s.store.ListTimeSlots(eventID)
+	if err != nil {
+		return nil, err
+	}
+	eventAvailability, err := s.store.ListAvailability(eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DurationMinutes > 0 {
+		event.RequiredDuration = req.DurationMinutes
+		event.DurationSeconds = req.DurationMinutes * 60
+	}
+
+	slotIndex := map[string]int{}
+	for i, slot := range eventSlots {
+		slotIndex[slot.ID] = i
+	}
+	for _, change := range req.SlotChanges {
+		i, ok := slotIndex[change.TimeSlotID]
+		if !ok {
+			return nil, invalid(fmt.Sprintf("Unknown time slot %q", change.TimeSlotID))
+		}
+		if change.StartTime != nil {
+			eventSlots[i].StartTime = *change.StartTime
+		}
+		if change.EndTime != nil {
+			eventSlots[i].EndTime = *change.EndTime
+		}
+		if !eventSlots[i].EndTime.After(eventSlots[i].StartTime) {
+			return nil, invalid(fmt.Sprintf("Time slot %q would end before it starts", change.TimeSlotID))
+		}
+	}
+
+	if len(req.DropParticipants) > 0 {
+		dropped := map[string]bool{}
+		for _, id := range req.DropParticipants {
+			dropped[id] = true
+		}
+		var kept []UserAvailability
+		for _, avail := range eventAvailability {
+			if !dropped[avail.UserID] {
+				kept = append(kept, avail)
+			}
+		}
+		eventAvailability = kept
+		var invitees []string
+		for _, id := range event.Invitees {
+			if !dropped[id] {
+				invitees = append(invitees, id)
+			}
+		}
+		event.Invitees = invitees
+	}
+
+	recommendations, err := s.recommend(event, eventSlots, eventAvailability)
+	if err != nil {
+		return nil, err
+	}
+	quorate := []Recommendation{}
+	for _, rec := range recommendations {
+		if rec.AvailabilityPercentage >= req.MinAvailabilityPercentage {
+			quorate = append(quorate, rec)
+		}
+	}
+	return quorate, nil
+}
+
+func simulateRecommendations(c *gin.Context) {
+	var req SimulateRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	recommendations, err := contextScheduler(c).WithTieSeed(c.Query("seed")).Simulate(c.Param("eventId"), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, RecommendationsResponse{Recommendations: recommendations, Analysis: analyzeRecommendations(recommendations)})
+}
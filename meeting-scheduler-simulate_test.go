@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulateDoesNotPersist(t *testing.T) {
+	scheduler, fake := newTestScheduler(t)
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Planning", OrganizerID: "ada", RequiredDuration: 60})
+	require.NoError(t, err)
+	start := fake.Now().Add(24 * time.Hour)
+	short, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(30 * time.Minute)})
+	require.NoError(t, err)
+	long, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start.Add(2 * time.Hour), EndTime: start.Add(3 * time.Hour)})
+	require.NoError(t, err)
+	for _, answer := range []struct{ user, slot string }{{"bob", short.ID}, {"bob", long.ID}, {"cy", short.ID}} {
+		_, err = scheduler.SubmitAvailability(event.ID, answer.user, UserAvailabilityRequest{TimeSlotID: answer.slot, Status: "available"})
+		require.NoError(t, err)
+	}
+
+	// The short slot is too short, so only the long one is recommended
+	recs, err := scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	require.Len(t, recs, 1)
+	assert.Equal(t, 50.0, recs[0].AvailabilityPercentage)
+
+	end := start.Add(time.Hour)
+	recs, err = scheduler.Simulate(event.ID, SimulateRequest{SlotChanges: []SimulatedSlotChange{{TimeSlotID: short.ID, EndTime: &end}}})
+	require.NoError(t, err)
+	require.Len(t, recs, 2)
+	assert.Equal(t, short.ID, recs[0].TimeSlot.ID)
+	assert.Equal(t, 100.0, recs[0].AvailabilityPercentage)
+
+	recs, err = scheduler.Simulate(event.ID, SimulateRequest{DropParticipants: []string{"cy"}})
+	require.NoError(t, err)
+	require.Len(t, recs, 1)
+	assert.Equal(t, 100.0, recs[0].AvailabilityPercentage)
+
+	recs, err = scheduler.Simulate(event.ID, SimulateRequest{MinAvailabilityPercentage: 75})
+	require.NoError(t, err)
+	assert.Empty(t, recs)
+
+	_, err = scheduler.Simulate(event.ID, SimulateRequest{SlotChanges: []SimulatedSlotChange{{TimeSlotID: "nope"}}})
+	assert.Error(t, err)
+
+	// Nothing was saved
+	stored, err := scheduler.store.GetTimeSlot(short.ID)
+	require.NoError(t, err)
+	assert.Equal(t, short.EndTime, stored.EndTime)
+	recs, err = scheduler.Recommendations(event.ID)
+	require.NoError(t, err)
+	assert.Len(t, recs, 1)
+}
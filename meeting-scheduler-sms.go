@@ -0,0 +1,269 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Message kinds. SMS is reserved for the time-sensitive ones.
+const (
+	MessagePollOpened = "poll-opened"
+	MessageFinalized  = "finalized"
+	MessageReminder   = "reminder"
+)
+
+var smsKinds = map[string]bool{MessageFinalized: true, MessageReminder: true, MessageBroadcast: true}
+
+// maxSMSLength keeps texts to two segments
+const maxSMSLength = 320
+
+// SMSSender delivers a text message to an E.164 phone number
+type SMSSender interface {
+	SendSMS(to, body string) error
+}
+
+// twilioSender sends texts through Twilio's Messages API. The auth token
+// is read from the secret store on every send.
+type twilioSender struct {
+	baseURL    string
+	accountSID string
+	from       string
+	client     *http.Client
+}
+
+func (s *twilioSender) SendSMS(to, body string) error {
+	form := url.Values{"To": {to}, "From": {s.from}, "Body": {body}}
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", s.baseURL, url.PathEscape(s.accountSID))
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(s.accountSID, secrets.Get("TWILIO_AUTH_TOKEN"))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("twilio: %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// smsSenderFromEnv configures Twilio from TWILIO_ACCOUNT_SID and
+// TWILIO_FROM, with the TWILIO_AUTH_TOKEN secret. It returns nil when SMS
+// isn't configured.
+func smsSenderFromEnv() SMSSender {
+	sid := getenv("TWILIO_ACCOUNT_SID", "")
+	if sid == "" {
+		return nil
+	}
+	return &twilioSender{
+		baseURL:    getenv("TWILIO_API_URL", "https://api.twilio.com"),
+		accountSID: sid,
+		from:       getenv("TWILIO_FROM", ""),
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// smsSender is the active SMS provider, nil when SMS isn't configured
+var smsSender SMSSender
+
+// smsNotifier texts finalizations, reminders and broadcasts to users who
+// have verified their phone number and opted in
+type smsNotifier struct{}
+
+func (smsNotifier) Notify(msg Message) error {
+	if smsSender == nil || !smsKinds[msg.Kind] {
+		return nil
+	}
+	if msg.To.Phone == "" || !msg.To.PhoneVerified || !msg.To.Settings.SMSOptIn {
+		return nil
+	}
+	return smsSender.SendSMS(msg.To.Phone, smsText(msg))
+}
+
+// smsText is the subject and first paragraph of a message, shortened to
+// fit a text
+func smsText(msg Message) string {
+	first, _, _ := strings.Cut(msg.Body, "\n\n")
+	text := msg.Subject + "\n" + first
+	if runes := []rune(text); len(runes) > maxSMSLength {
+		text = string(runes[:maxSMSLength-1]) + "…"
+	}
+	return text
+}
+
+// multiNotifier delivers each message over every channel. A failing
+// channel doesn't stop the others.
+type multiNotifier []Notifier
+
+func (m multiNotifier) Notify(msg Message) error {
+	var errs []error
+	for _, channel := range m {
+		if err := channel.Notify(msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Phone verification
+
+const (
+	phoneCodeTTL         = 10 * time.Minute
+	maxPhoneCodeAttempts = 5
+)
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9]\d{6,14}$`)
+
+type phoneChallenge struct {
+	phone    string
+	codeHash string
+	expires  time.Time
+	attempts int
+}
+
+// phoneVerificationRegistry holds outstanding verification codes by user,
+// only as blind-index hashes
+type phoneVerificationRegistry struct {
+	mu         sync.Mutex
+	challenges map[string]phoneChallenge
+}
+
+func newPhoneVerificationRegistry() *phoneVerificationRegistry {
+	return &phoneVerificationRegistry{challenges: make(map[string]phoneChallenge)}
+}
+
+// Start issues a new code for the user's phone, replacing any earlier one
+func (r *phoneVerificationRegistry) Start(userID, phone string, now time.Time) string {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		panic(err)
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.challenges[userID] = phoneChallenge{phone: phone, codeHash: blindIndex(code), expires: now.Add(phoneCodeTTL)}
+	return code
+}
+
+// Check reports whether code verifies phone for the user. Codes are
+// single-use and stop working after too many wrong guesses.
+func (r *phoneVerificationRegistry) Check(userID, phone, code string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	challenge, ok := r.challenges[userID]
+	if !ok || challenge.phone != phone || now.After(challenge.expires) {
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(challenge.codeHash), []byte(blindIndex(code))) != 1 {
+		challenge.attempts++
+		if challenge.attempts >= maxPhoneCodeAttempts {
+			delete(r.challenges, userID)
+		} else {
+			r.challenges[userID] = challenge
+		}
+		return false
+	}
+	delete(r.challenges, userID)
+	return true
+}
+
+var phoneVerifications = newPhoneVerificationRegistry()
+
+type PhoneRequest struct {
+	Phone string `json:"phone" binding:"required"`
+}
+
+type VerifyPhoneRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// Phone handlers act on the signed-in user. Setting a number texts it a
+// code; the number isn't used for notifications until verified.
+func setMyPhone(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	if smsSender == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "SMS is not configured"})
+		return
+	}
+	var req PhoneRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !e164Pattern.MatchString(req.Phone) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Phone must be in E.164 format, e.g. +14155550100"})
+		return
+	}
+
+	now := clock.Now()
+	code := phoneVerifications.Start(user.ID, req.Phone, now)
+	if err := smsSender.SendSMS(req.Phone, fmt.Sprintf("Your meeting scheduler verification code is %s", code)); err != nil {
+		log.Printf("Failed to text verification code to user %s: %v", user.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send verification code"})
+		return
+	}
+	user.Phone = req.Phone
+	user.PhoneVerified = false
+	user.UpdatedAt = now
+	users.Save(user)
+	c.JSON(http.StatusAccepted, user)
+}
+
+func verifyMyPhone(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	var req VerifyPhoneRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	now := clock.Now()
+	if user.Phone == "" || !phoneVerifications.Check(user.ID, user.Phone, req.Code, now) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification code"})
+		return
+	}
+	user.PhoneVerified = true
+	user.UpdatedAt = now
+	users.Save(user)
+	c.JSON(http.StatusOK, user)
+}
+
+func deleteMyPhone(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	user.Phone = ""
+	user.PhoneVerified = false
+	user.UpdatedAt = clock.Now()
+	users.Save(user)
+	c.JSON(http.StatusNoContent, nil)
+}
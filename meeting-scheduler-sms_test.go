@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSMSSender struct {
+	texts map[string][]string
+}
+
+func (s *recordingSMSSender) SendSMS(to, body string) error {
+	s.texts[to] = append(s.texts[to], body)
+	return nil
+}
+
+func TestTwilioSenderPostsMessages(t *testing.T) {
+	var form map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/2010-04-01/Accounts/AC123/Messages.json", r.URL.Path)
+		user, _, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "AC123", user)
+		require.NoError(t, r.ParseForm())
+		form = map[string]string{"To": r.Form.Get("To"), "From": r.Form.Get("From"), "Body": r.Form.Get("Body")}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	sender := &twilioSender{baseURL: server.URL, accountSID: "AC123", from: "+15550001111", client: server.Client()}
+	require.NoError(t, sender.SendSMS("+15552223333", "Hello"))
+	assert.Equal(t, map[string]string{"To": "+15552223333", "From": "+15550001111", "Body": "Hello"}, form)
+}
+
+func TestPhoneVerificationGatesSMSNotifications(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	sender := &recordingSMSSender{texts: map[string][]string{}}
+	previous := smsSender
+	smsSender = sender
+	t.Cleanup(func() { smsSender = previous })
+
+	bob := User{ID: "bob", Role: RoleMember, Settings: UserSettings{SMSOptIn: true}}
+	users.Save(bob)
+	token, err := issueSessionToken(bob)
+	require.NoError(t, err)
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	finalized := Message{Kind: MessageFinalized, Subject: "Confirmed: Kickoff", Body: "Kickoff is confirmed.\n\nThe calendar invite is attached."}
+
+	assert.Equal(t, http.StatusBadRequest, send("PUT", "/api/v1/users/me/phone", `{"phone":"555-0100"}`).Code)
+	assert.Equal(t, http.StatusAccepted, send("PUT", "/api/v1/users/me/phone", `{"phone":"+14155550100"}`).Code)
+	require.Len(t, sender.texts["+14155550100"], 1)
+	code := regexp.MustCompile(`\d{6}`).FindString(sender.texts["+14155550100"][0])
+
+	// Unverified numbers aren't texted
+	finalized.To, _ = users.Get("bob")
+	require.NoError(t, smsNotifier{}.Notify(finalized))
+	assert.Len(t, sender.texts["+14155550100"], 1)
+
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/api/v1/users/me/phone/verify", `{"code":"not-it"}`).Code)
+	assert.Equal(t, http.StatusOK, send("POST", "/api/v1/users/me/phone/verify", `{"code":"`+code+`"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/api/v1/users/me/phone/verify", `{"code":"`+code+`"}`).Code, "codes are single-use")
+
+	finalized.To, _ = users.Get("bob")
+	assert.True(t, finalized.To.PhoneVerified)
+	require.NoError(t, smsNotifier{}.Notify(finalized))
+	require.Len(t, sender.texts["+14155550100"], 2)
+	assert.Equal(t, "Confirmed: Kickoff\nKickoff is confirmed.", sender.texts["+14155550100"][1])
+
+	// Other kinds of message, and users who opt out, stay off SMS
+	require.NoError(t, smsNotifier{}.Notify(Message{To: finalized.To, Subject: "New response"}))
+	finalized.To.Settings.SMSOptIn = false
+	require.NoError(t, smsNotifier{}.Notify(finalized))
+	assert.Len(t, sender.texts["+14155550100"], 2)
+}
+
+func TestMeetingRemindersAreSentOnce(t *testing.T) {
+	resetDirectory(t)
+	scheduler, fake := newTestScheduler(t)
+	recorder := &recordingNotifier{}
+	previousNotifier, previousReminders := notifier, meetingReminders
+	notifier, meetingReminders = recorder, newReminderRegistry()
+	t.Cleanup(func() { notifier, meetingReminders = previousNotifier, previousReminders })
+	users.Save(User{ID: "ada"})
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	start := fake.Now().Add(time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = scheduler.FinalizeEvent(event.ID, FinalizeEventRequest{TimeSlotID: slot.ID})
+	require.NoError(t, err)
+
+	require.NoError(t, scheduler.remindUpcoming(fake.Now()))
+	assert.Empty(t, recorder.messages, "too early")
+
+	fake.Advance(50 * time.Minute)
+	require.NoError(t, scheduler.remindUpcoming(fake.Now()))
+	require.Len(t, recorder.messages, 1)
+	assert.Equal(t, MessageReminder, recorder.messages[0].Kind)
+	assert.Equal(t, "Kickoff starts in 10 minutes, at 10:00 UTC.", recorder.messages[0].Body)
+
+	fake.Advance(time.Minute)
+	require.NoError(t, scheduler.remindUpcoming(fake.Now()))
+	assert.Len(t, recorder.messages, 1)
+}
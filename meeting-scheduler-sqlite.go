@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteDialect runs the SQL store on an embedded SQLite file, for
+// single-node deployments without a database server. DATABASE_URL takes
+// the form sqlite:///var/lib/scheduler/scheduler.db. The driver is pure
+// Go, so the binary still builds with CGO_ENABLED=0.
+var sqliteDialect = sqlDialect{
+	driver: "sqlite",
+	dsn:    sqliteDSN,
+	rebind: func(query string) string { return query },
+	// SQLite keeps IDs in plain TEXT columns
+	malformedKey: func(error) bool { return false },
+	// Transactions take the write lock when they begin (see sqliteDSN), so
+	// rows they read can't change under them
+	forUpdate: "",
+}
+
+// sqliteDSN turns sqlite://path into the driver's file name. Foreign keys
+// are switched on so deletes cascade, the write-ahead log lets requests
+// read while another writes, writers wait their turn for up to 5s rather
+// than fail, and timestamps are stored in a form that sorts by time.
+func sqliteDSN(databaseURL string) (string, error) {
+	path, rawQuery, _ := strings.Cut(strings.TrimPrefix(databaseURL, "sqlite://"), "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", err
+	}
+	for _, pragma := range []string{"foreign_keys(1)", "journal_mode(WAL)", "busy_timeout(5000)"} {
+		query.Add("_pragma", pragma)
+	}
+	query.Set("_txlock", "immediate")
+	query.Set("_time_format", "sqlite")
+	return "file:" + path + "?" + query.Encode(), nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/base32"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStoreKeepsDataInAFile(t *testing.T) {
+	databaseURL := "sqlite://" + filepath.Join(t.TempDir(), "scheduler.db")
+	_, err := (&migrator{url: databaseURL, dialect: "sqlite"}).Up()
+	require.NoError(t, err)
+	s, err := openSQLStore(sqliteDialect, databaseURL)
+	require.NoError(t, err)
+
+	now := time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC)
+	require.NoError(t, s.CreateEvent(Event{ID: "e1", Title: "Sync", OrganizerID: "ada", Status: "open", CreatedAt: now, UpdatedAt: now}))
+	require.NoError(t, s.CreateTimeSlot(TimeSlot{ID: "s1", EventID: "e1", StartTime: now, EndTime: now.Add(time.Hour), CreatedAt: now, UpdatedAt: now}))
+	require.NoError(t, s.CreateAvailability(UserAvailability{ID: "a1", UserID: "bob", EventID: "e1", TimeSlotID: "s1", Status: "available", CreatedAt: now, UpdatedAt: now}))
+	require.NoError(t, s.Close())
+
+	// Reopened, as after a restart
+	s, err = openSQLStore(sqliteDialect, databaseURL)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	event, err := s.GetEvent("e1")
+	require.NoError(t, err)
+	assert.Equal(t, "Sync", event.Title)
+	avail, err := s.FindAvailability("e1", "bob", "s1")
+	require.NoError(t, err)
+	assert.Equal(t, "available", avail.Status)
+
+	failures, err := s.RecordFailure("ip:192.0.2.1", now, now.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, failures)
+
+	require.NoError(t, s.DeleteEvent("e1"))
+	_, err = s.GetTimeSlot("s1")
+	assert.ErrorIs(t, err, ErrNotFound, "deletes cascade")
+	list, err := s.ListAvailability("e1")
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	assert.ErrorIs(t, s.DeleteEvent("e1"), ErrNotFound)
+}
+
+func TestSQLiteStoreKeepsSessions(t *testing.T) {
+	databaseURL := "sqlite://" + filepath.Join(t.TempDir(), "scheduler.db")
+	_, err := (&migrator{url: databaseURL, dialect: "sqlite"}).Up()
+	require.NoError(t, err)
+	s, err := openSQLStore(sqliteDialect, databaseURL)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	registry := &sessionRegistry{store: s}
+
+	now := time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC)
+	session, first, err := registry.Start("ada", "test", "192.0.2.1", now)
+	require.NoError(t, err)
+	require.NoError(t, registry.VerifyTwoFactor(session.ID))
+	assert.True(t, registry.TwoFactorVerified(session.ID))
+
+	// Another replica sees the session and its refresh token
+	replica := &sessionRegistry{store: s}
+	_, second, err := replica.Refresh(first, "192.0.2.2", now.Add(time.Hour))
+	require.NoError(t, err)
+	active, err := registry.Active(session.ID, now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.2", active.ClientIP)
+	assert.Equal(t, now.Add(time.Hour).Add(sessionTTL()), active.ExpiresAt)
+
+	_, _, err = registry.Refresh(first, "192.0.2.1", now.Add(3*time.Hour))
+	assert.ErrorIs(t, err, ErrNotFound, "a replaced token is refused")
+	_, _, err = registry.Refresh(second, "192.0.2.2", now.Add(3*time.Hour))
+	assert.ErrorIs(t, err, ErrNotFound, "and its reuse ended the session")
+	_, err = registry.Active(session.ID, now.Add(3*time.Hour))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	other, _, err := registry.Start("ada", "test", "192.0.2.1", now)
+	require.NoError(t, err)
+	assert.ErrorIs(t, registry.Revoke("bob", other.ID), ErrNotFound)
+	registry.purgeExpired(now.Add(sessionTTL()))
+	list, err := registry.ForUser("ada", now)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestSQLiteStoreKeepsTwoFactorEnrollments(t *testing.T) {
+	databaseURL := "sqlite://" + filepath.Join(t.TempDir(), "scheduler.db")
+	_, err := (&migrator{url: databaseURL, dialect: "sqlite"}).Up()
+	require.NoError(t, err)
+	s, err := openSQLStore(sqliteDialect, databaseURL)
+	require.NoError(t, err)
+	registry := &twoFactorRegistry{store: s}
+
+	now := time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC)
+	secret, err := registry.Enroll("ada")
+	require.NoError(t, err)
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	require.NoError(t, err)
+	code := totpCode(key, uint64(now.Unix())/30)
+	verified, err := registry.Verify("ada", code, now)
+	require.NoError(t, err)
+	assert.True(t, verified)
+	require.NoError(t, s.Close())
+
+	// Reopened, as after a restart
+	s, err = openSQLStore(sqliteDialect, databaseURL)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	registry = &twoFactorRegistry{store: s}
+	enabled, err := registry.Enabled("ada")
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	verified, err = registry.Verify("ada", code, now)
+	require.NoError(t, err)
+	assert.False(t, verified, "codes can't be replayed")
+
+	require.NoError(t, registry.Delete("ada"))
+	enabled, err = registry.Enabled("ada")
+	require.NoError(t, err)
+	assert.False(t, enabled)
+}
+
+func TestSQLiteStoreKeepsTheImpersonationLog(t *testing.T) {
+	databaseURL := "sqlite://" + filepath.Join(t.TempDir(), "scheduler.db")
+	_, err := (&migrator{url: databaseURL, dialect: "sqlite"}).Up()
+	require.NoError(t, err)
+	s, err := openSQLStore(sqliteDialect, databaseURL)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	auditLog := &impersonationLog{store: s}
+
+	now := time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC)
+	for i, sessionID := range []string{"s1", "s2", "s1", "s1"} {
+		require.NoError(t, auditLog.Add(ImpersonationEntry{SessionID: sessionID, ImpersonatorID: "sre", UserID: "ada",
+			Action: ImpersonationRequest, Path: "/api/v1/users/me", Status: http.StatusOK, At: now.Add(time.Duration(i) * time.Hour)}))
+	}
+
+	page, err := auditLog.List("s1", "", 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, now, page[0].At)
+	assert.Equal(t, now.Add(2*time.Hour), page[1].At)
+	page, err = auditLog.List("s1", page[1].ID, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, now.Add(3*time.Hour), page[0].At)
+	assert.Equal(t, "/api/v1/users/me", page[0].Path)
+	page, err = auditLog.List("", "not-an-id", 2)
+	require.NoError(t, err)
+	assert.Empty(t, page)
+
+	require.NoError(t, s.PruneImpersonationEntries(now.Add(2*time.Hour)))
+	page, err = auditLog.List("", "", maxImpersonationPage)
+	require.NoError(t, err)
+	assert.Len(t, page, 2)
+}
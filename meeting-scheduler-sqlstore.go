@@ -0,0 +1,668 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqlDialect is what sqlStore needs to know about a database
+type sqlDialect struct {
+	// driver is the database/sql driver name
+	driver string
+	// dsn turns DATABASE_URL into the driver's connection string
+	dsn func(databaseURL string) (string, error)
+	// rebind rewrites a query's ? placeholders into the dialect's own
+	rebind func(query string) string
+	// malformedKey reports whether a query failed because a key isn't in
+	// the form its column takes, so no row could match it
+	malformedKey func(err error) bool
+	// forUpdate is the clause locking the rows a transaction reads
+	forUpdate string
+}
+
+// sqlDialects are the databases a SQL store can run on, keyed like
+// migrationDialects' directories
+var sqlDialects = map[string]sqlDialect{
+	"mysql":    mysqlDialect,
+	"postgres": postgresDialect,
+	"sqlite":   sqliteDialect,
+}
+
+// storeFromEnv opens the database store for DATABASE_URL, or returns the
+// in-memory store when it is unset. Reads go to DATABASE_REPLICA_URLS, a
+// comma-separated list of replicas of the same kind, when it is set.
+// EVENT_LOG_FILE instead keeps state in memory, sourced from an event log.
+func storeFromEnv() (Store, error) {
+	databaseURL := getenv("DATABASE_URL", "")
+	if path := getenv("EVENT_LOG_FILE", ""); path != "" {
+		if databaseURL != "" {
+			return nil, errors.New("EVENT_LOG_FILE and DATABASE_URL can't both be set")
+		}
+		logged, err := openEventSourcedStore(path)
+		if err != nil {
+			return nil, err
+		}
+		return logged, nil
+	}
+	if databaseURL == "" {
+		return newMemoryStore(), nil
+	}
+	primary, err := openStore(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	var replicas []Store
+	for _, replicaURL := range strings.Split(getenv("DATABASE_REPLICA_URLS", ""), ",") {
+		if replicaURL = strings.TrimSpace(replicaURL); replicaURL == "" {
+			continue
+		}
+		replica, err := openStore(replicaURL)
+		if err != nil {
+			return nil, fmt.Errorf("opening read replica: %w", err)
+		}
+		replicas = append(replicas, replica)
+	}
+	if len(replicas) == 0 {
+		return primary, nil
+	}
+	return newReplicatedStore(primary, replicas), nil
+}
+
+// openStore opens the store for one database URL
+func openStore(databaseURL string) (Store, error) {
+	scheme, _, _ := strings.Cut(databaseURL, "://")
+	if mongoSchemes[scheme] {
+		return openMongoStore(databaseURL)
+	}
+	dialect, ok := sqlDialects[migrationDialects[scheme]]
+	if !ok {
+		return nil, fmt.Errorf("no storage backend for %q databases", scheme)
+	}
+	return openSQLStore(dialect, databaseURL)
+}
+
+func openSQLStore(dialect sqlDialect, databaseURL string) (*sqlStore, error) {
+	dsn, err := dialect.dsn(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(dialect.driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	configurePool(db)
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout())
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connecting to %s: %w", dialect.driver, err)
+	}
+	return &sqlStore{db: db, dialect: dialect, ctx: context.Background()}, nil
+}
+
+// configurePool sizes the connection pool each replica keeps to the
+// database: DATABASE_MAX_OPEN_CONNS bounds the connections in use,
+// DATABASE_MAX_IDLE_CONNS how many are kept open between requests, and
+// DATABASE_CONN_MAX_LIFETIME when connections are recycled, so they move
+// to new servers after a failover
+func configurePool(db *sql.DB) {
+	db.SetMaxOpenConns(getenvInt("DATABASE_MAX_OPEN_CONNS", 25))
+	db.SetMaxIdleConns(getenvInt("DATABASE_MAX_IDLE_CONNS", 5))
+	db.SetConnMaxLifetime(getenvDuration("DATABASE_CONN_MAX_LIFETIME", 30*time.Minute))
+}
+
+// sqlStore keeps events, time slots and availability in a SQL database
+// migrated by the embedded migrations. Each row's details column holds
+// the whole record as JSON, so fields can be added without a migration;
+// the other columns are copies kept for querying.
+type sqlStore struct {
+	db      *sql.DB
+	dialect sqlDialect
+	ctx     context.Context
+	// tx is set inside WithTransaction
+	tx *sql.Tx
+}
+
+// WithContext runs the store's calls under ctx, abandoning queries in
+// flight once it is done
+func (s *sqlStore) WithContext(ctx context.Context) Store {
+	bound := *s
+	bound.ctx = ctx
+	return &bound
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (s *sqlStore) querier() sqlQuerier {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+// lookupErr turns a failure to look up a malformed key into ErrNotFound
+func (s *sqlStore) lookupErr(err error) error {
+	if err != nil && s.dialect.malformedKey(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// exec runs a write and returns how many rows it matched
+func (s *sqlStore) exec(query string, args ...any) (int64, error) {
+	result, err := s.querier().ExecContext(s.ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// get decodes the details of the one row query finds into record
+func (s *sqlStore) get(record any, query string, args ...any) error {
+	var details []byte
+	err := s.querier().QueryRowContext(s.ctx, s.dialect.rebind(query), args...).Scan(&details)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return s.lookupErr(err)
+	}
+	return json.Unmarshal(details, record)
+}
+
+// sqlList decodes the details of every row query finds
+func sqlList[T any](s *sqlStore, query string, args ...any) ([]T, error) {
+	rows, err := s.querier().QueryContext(s.ctx, s.dialect.rebind(query), args...)
+	if errors.Is(s.lookupErr(err), ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []T
+	for rows.Next() {
+		var details []byte
+		if err := rows.Scan(&details); err != nil {
+			return nil, err
+		}
+		var record T
+		if err := json.Unmarshal(details, &record); err != nil {
+			return nil, err
+		}
+		list = append(list, record)
+	}
+	return list, rows.Err()
+}
+
+// written turns a write that matched no rows into ErrNotFound. Some
+// databases don't count rows an update left unchanged, so the row is
+// looked up before deciding it is missing.
+func (s *sqlStore) written(matched int64, table, id string) error {
+	if matched > 0 {
+		return nil
+	}
+	var found int
+	err := s.querier().QueryRowContext(s.ctx, s.dialect.rebind("SELECT 1 FROM "+table+" WHERE id = ?"), id).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return s.lookupErr(err)
+}
+
+func sqlTime(t time.Time) time.Time {
+	return t.UTC()
+}
+
+const eventColumns = "title, description, organizer_id, org_id, required_duration, status, final_time_slot_id, details, created_at, updated_at"
+
+func eventValues(event Event) ([]any, error) {
+	details, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return []any{event.Title, event.Description, event.OrganizerID, event.OrgID, event.RequiredDuration,
+		event.Status, event.FinalTimeSlotID, details, sqlTime(event.CreatedAt), sqlTime(event.UpdatedAt)}, nil
+}
+
+const eventAssignments = "title = ?, description = ?, organizer_id = ?, org_id = ?, required_duration = ?, status = ?, final_time_slot_id = ?, details = ?, created_at = ?, updated_at = ?"
+
+func (s *sqlStore) CreateEvent(event Event) error {
+	values, err := eventValues(event)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec("INSERT INTO events (id, "+eventColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", append([]any{event.ID}, values...)...)
+	return err
+}
+
+func (s *sqlStore) GetEvent(id string) (Event, error) {
+	var event Event
+	err := s.get(&event, "SELECT details FROM events WHERE id = ?", id)
+	return event, err
+}
+
+func (s *sqlStore) ListEvents() ([]Event, error) {
+	return sqlList[Event](s, "SELECT details FROM events ORDER BY created_at, id")
+}
+
+func (s *sqlStore) UpdateEvent(event Event) error {
+	values, err := eventValues(event)
+	if err != nil {
+		return err
+	}
+	matched, err := s.exec("UPDATE events SET "+eventAssignments+" WHERE id = ?", append(values, event.ID)...)
+	if err != nil {
+		return s.lookupErr(err)
+	}
+	return s.written(matched, "events", event.ID)
+}
+
+// CompareAndSwapEvent checks the stored finalization in the UPDATE's WHERE
+// clause, so the check and the write are one statement
+func (s *sqlStore) CompareAndSwapEvent(event, expected Event) error {
+	values, err := eventValues(event)
+	if err != nil {
+		return err
+	}
+	matched, err := s.exec("UPDATE events SET "+eventAssignments+" WHERE id = ? AND status = ? AND final_time_slot_id = ?",
+		append(values, event.ID, expected.Status, expected.FinalTimeSlotID)...)
+	if err != nil || matched > 0 {
+		return err
+	}
+	stored, err := s.GetEvent(event.ID)
+	if err != nil {
+		return err
+	}
+	if !sameFinalization(stored, expected) {
+		return ErrConflict
+	}
+	// The row matched but the write changed nothing
+	return nil
+}
+
+func (s *sqlStore) DeleteEvent(id string) error {
+	matched, err := s.exec("DELETE FROM events WHERE id = ?", id)
+	if err != nil {
+		return s.lookupErr(err)
+	}
+	return s.written(matched, "events", id)
+}
+
+func slotValues(slot TimeSlot) ([]any, error) {
+	details, err := json.Marshal(slot)
+	if err != nil {
+		return nil, err
+	}
+	return []any{slot.EventID, sqlTime(slot.StartTime), sqlTime(slot.EndTime), details, sqlTime(slot.CreatedAt), sqlTime(slot.UpdatedAt)}, nil
+}
+
+func (s *sqlStore) CreateTimeSlot(slot TimeSlot) error {
+	values, err := slotValues(slot)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec("INSERT INTO time_slots (id, event_id, start_time, end_time, details, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		append([]any{slot.ID}, values...)...)
+	return err
+}
+
+func (s *sqlStore) GetTimeSlot(id string) (TimeSlot, error) {
+	var slot TimeSlot
+	err := s.get(&slot, "SELECT details FROM time_slots WHERE id = ?", id)
+	return slot, err
+}
+
+func (s *sqlStore) ListTimeSlots(eventID string) ([]TimeSlot, error) {
+	return sqlList[TimeSlot](s, "SELECT details FROM time_slots WHERE event_id = ? ORDER BY start_time, id", eventID)
+}
+
+func (s *sqlStore) UpdateTimeSlot(slot TimeSlot) error {
+	values, err := slotValues(slot)
+	if err != nil {
+		return err
+	}
+	matched, err := s.exec("UPDATE time_slots SET event_id = ?, start_time = ?, end_time = ?, details = ?, created_at = ?, updated_at = ? WHERE id = ?",
+		append(values, slot.ID)...)
+	if err != nil {
+		return s.lookupErr(err)
+	}
+	return s.written(matched, "time_slots", slot.ID)
+}
+
+func (s *sqlStore) DeleteTimeSlot(id string) error {
+	matched, err := s.exec("DELETE FROM time_slots WHERE id = ?", id)
+	if err != nil {
+		return s.lookupErr(err)
+	}
+	return s.written(matched, "time_slots", id)
+}
+
+func availabilityValues(avail UserAvailability) ([]any, error) {
+	details, err := json.Marshal(avail)
+	if err != nil {
+		return nil, err
+	}
+	return []any{avail.UserID, avail.EventID, avail.TimeSlotID, avail.Status, details, sqlTime(avail.CreatedAt), sqlTime(avail.UpdatedAt)}, nil
+}
+
+func (s *sqlStore) CreateAvailability(avail UserAvailability) error {
+	values, err := availabilityValues(avail)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec("INSERT INTO user_availability (id, user_id, event_id, time_slot_id, status, details, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		append([]any{avail.ID}, values...)...)
+	return err
+}
+
+func (s *sqlStore) FindAvailability(eventID, userID, timeslotID string) (UserAvailability, error) {
+	var avail UserAvailability
+	err := s.get(&avail, "SELECT details FROM user_availability WHERE event_id = ? AND user_id = ? AND time_slot_id = ?", eventID, userID, timeslotID)
+	return avail, err
+}
+
+func (s *sqlStore) ListAvailability(eventID string) ([]UserAvailability, error) {
+	return sqlList[UserAvailability](s, "SELECT details FROM user_availability WHERE event_id = ? ORDER BY created_at, id", eventID)
+}
+
+func (s *sqlStore) ListUserAvailability(eventID, userID string) ([]UserAvailability, error) {
+	return sqlList[UserAvailability](s, "SELECT details FROM user_availability WHERE event_id = ? AND user_id = ? ORDER BY created_at, id", eventID, userID)
+}
+
+func (s *sqlStore) UpdateAvailability(avail UserAvailability) error {
+	values, err := availabilityValues(avail)
+	if err != nil {
+		return err
+	}
+	matched, err := s.exec("UPDATE user_availability SET user_id = ?, event_id = ?, time_slot_id = ?, status = ?, details = ?, created_at = ?, updated_at = ? WHERE id = ?",
+		append(values, avail.ID)...)
+	if err != nil {
+		return s.lookupErr(err)
+	}
+	return s.written(matched, "user_availability", avail.ID)
+}
+
+func (s *sqlStore) DeleteAvailability(id string) error {
+	matched, err := s.exec("DELETE FROM user_availability WHERE id = ?", id)
+	if err != nil {
+		return s.lookupErr(err)
+	}
+	return s.written(matched, "user_availability", id)
+}
+
+// ArchiveAvailability flags finished events' responses archived, which
+// moves them to the archive partition
+func (s *sqlStore) ArchiveAvailability(cutoff time.Time) (int64, error) {
+	return s.exec("UPDATE user_availability SET archived = TRUE WHERE archived = FALSE AND event_id IN (SELECT id FROM events WHERE status IN ('finalized', 'cancelled') AND updated_at < ?)",
+		sqlTime(cutoff))
+}
+
+// RecordFailure locks the key's row while it counts, so replicas counting
+// at once don't lose failures
+func (s *sqlStore) RecordFailure(key string, now, since time.Time) (int, error) {
+	var failures int
+	err := s.WithTransaction(func(tx Store) error {
+		t := tx.(*sqlStore)
+		var windowStart time.Time
+		err := t.querier().QueryRowContext(t.ctx, t.dialect.rebind("SELECT failures, window_start FROM throttles WHERE throttle_key = ?"+t.dialect.forUpdate), key).
+			Scan(&failures, &windowStart)
+		if errors.Is(err, sql.ErrNoRows) {
+			failures = 1
+			_, err = t.exec("INSERT INTO throttles (throttle_key, failures, window_start) VALUES (?, ?, ?)", key, failures, sqlTime(now))
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if windowStart.Before(since) {
+			failures, windowStart = 0, now
+		}
+		failures++
+		_, err = t.exec("UPDATE throttles SET failures = ?, window_start = ? WHERE throttle_key = ?", failures, sqlTime(windowStart), key)
+		return err
+	})
+	return failures, err
+}
+
+// Lock assumes RecordFailure made the key's row
+func (s *sqlStore) Lock(key string, until time.Time) error {
+	_, err := s.exec("UPDATE throttles SET failures = 0, locked_until = ? WHERE throttle_key = ?", sqlTime(until), key)
+	return err
+}
+
+func (s *sqlStore) LockedUntil(key string) (time.Time, error) {
+	var until sql.NullTime
+	err := s.querier().QueryRowContext(s.ctx, s.dialect.rebind("SELECT locked_until FROM throttles WHERE throttle_key = ?"), key).Scan(&until)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return until.Time, err
+}
+
+func (s *sqlStore) Prune(now, since time.Time) error {
+	_, err := s.exec("DELETE FROM throttles WHERE window_start < ? AND (locked_until IS NULL OR locked_until <= ?)", sqlTime(since), sqlTime(now))
+	return err
+}
+
+const sessionColumns = "id, user_id, user_agent, client_ip, impersonator_id, two_factor_verified, refresh_hash, previous_hash, created_at, last_used_at, expires_at"
+
+func (s *sqlStore) CreateSession(session Session) error {
+	_, err := s.exec("INSERT INTO sessions ("+sessionColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		session.ID, session.UserID, session.UserAgent, session.ClientIP, session.ImpersonatorID, session.TwoFactorVerified,
+		session.refreshHash, session.previousHash, sqlTime(session.CreatedAt), sqlTime(session.LastUsedAt), sqlTime(session.ExpiresAt))
+	return err
+}
+
+// querySessions returns the sessions matching a WHERE clause
+func (s *sqlStore) querySessions(query string, args ...any) ([]Session, error) {
+	rows, err := s.querier().QueryContext(s.ctx, s.dialect.rebind("SELECT "+sessionColumns+" FROM sessions "+query), args...)
+	if err != nil {
+		return nil, s.lookupErr(err)
+	}
+	defer rows.Close()
+	var list []Session
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.ClientIP, &session.ImpersonatorID, &session.TwoFactorVerified,
+			&session.refreshHash, &session.previousHash, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		// Drivers such as SQLite's hand times back in local time
+		session.CreatedAt, session.LastUsedAt, session.ExpiresAt = session.CreatedAt.UTC(), session.LastUsedAt.UTC(), session.ExpiresAt.UTC()
+		list = append(list, session)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqlStore) GetSession(id string) (Session, error) {
+	list, err := s.querySessions("WHERE id = ?", id)
+	if err != nil {
+		return Session{}, err
+	}
+	if len(list) == 0 {
+		return Session{}, ErrNotFound
+	}
+	return list[0], nil
+}
+
+func (s *sqlStore) TouchSession(id string, now time.Time) error {
+	_, err := s.exec("UPDATE sessions SET last_used_at = ? WHERE id = ?", sqlTime(now), id)
+	return err
+}
+
+func (s *sqlStore) VerifySessionTwoFactor(id string) error {
+	_, err := s.exec("UPDATE sessions SET two_factor_verified = TRUE WHERE id = ?", id)
+	return err
+}
+
+// RotateRefreshToken only matches the row while presented is current, so
+// replicas refreshing at once can't both succeed
+func (s *sqlStore) RotateRefreshToken(id, presented, next, clientIP string, now, expiresAt time.Time) error {
+	n, err := s.exec("UPDATE sessions SET previous_hash = refresh_hash, refresh_hash = ?, client_ip = ?, last_used_at = ?, expires_at = ? WHERE id = ? AND refresh_hash = ?",
+		next, clientIP, sqlTime(now), sqlTime(expiresAt), id, presented)
+	if err != nil {
+		return s.lookupErr(err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *sqlStore) SessionsForUser(userID string) ([]Session, error) {
+	return s.querySessions("WHERE user_id = ?", userID)
+}
+
+func (s *sqlStore) DeleteSession(id string) error {
+	_, err := s.exec("DELETE FROM sessions WHERE id = ?", id)
+	return err
+}
+
+func (s *sqlStore) PruneSessions(now time.Time) error {
+	_, err := s.exec("DELETE FROM sessions WHERE expires_at <= ?", sqlTime(now))
+	return err
+}
+
+// SaveTwoFactor replaces the user's row, so a new enrollment starts over
+func (s *sqlStore) SaveTwoFactor(enrollment TwoFactor) error {
+	return s.WithTransaction(func(tx Store) error {
+		t := tx.(*sqlStore)
+		if _, err := t.exec("DELETE FROM two_factors WHERE user_id = ?", enrollment.UserID); err != nil {
+			return err
+		}
+		var enabledAt sql.NullTime
+		if enrollment.Enabled {
+			enabledAt = sql.NullTime{Time: sqlTime(enrollment.EnabledAt), Valid: true}
+		}
+		_, err := t.exec("INSERT INTO two_factors (user_id, secret, enabled, enabled_at, last_counter) VALUES (?, ?, ?, ?, ?)",
+			enrollment.UserID, enrollment.Secret, enrollment.Enabled, enabledAt, int64(enrollment.lastCounter))
+		return err
+	})
+}
+
+func (s *sqlStore) GetTwoFactor(userID string) (TwoFactor, error) {
+	enrollment := TwoFactor{UserID: userID}
+	var enabledAt sql.NullTime
+	var lastCounter int64
+	err := s.querier().QueryRowContext(s.ctx, s.dialect.rebind("SELECT secret, enabled, enabled_at, last_counter FROM two_factors WHERE user_id = ?"), userID).
+		Scan(&enrollment.Secret, &enrollment.Enabled, &enabledAt, &lastCounter)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TwoFactor{}, ErrNotFound
+	}
+	if err != nil {
+		return TwoFactor{}, err
+	}
+	enrollment.EnabledAt, enrollment.lastCounter = enabledAt.Time.UTC(), uint64(lastCounter)
+	return enrollment, nil
+}
+
+// AcceptTwoFactorCode only matches the row while the step is newer than
+// the last one used, so a code replayed on another replica is refused
+func (s *sqlStore) AcceptTwoFactorCode(userID string, counter uint64, now time.Time) error {
+	n, err := s.exec("UPDATE two_factors SET last_counter = ?, enabled = TRUE, enabled_at = COALESCE(enabled_at, ?) WHERE user_id = ? AND last_counter < ?",
+		int64(counter), sqlTime(now), userID, int64(counter))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *sqlStore) DeleteTwoFactor(userID string) error {
+	_, err := s.exec("DELETE FROM two_factors WHERE user_id = ?", userID)
+	return err
+}
+
+const impersonationColumns = "session_id, impersonator_id, user_id, action, reason, client_ip, method, path, status, created_at"
+
+func (s *sqlStore) AddImpersonationEntry(entry ImpersonationEntry) error {
+	_, err := s.exec("INSERT INTO impersonations ("+impersonationColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		entry.SessionID, entry.ImpersonatorID, entry.UserID, entry.Action, entry.Reason, entry.ClientIP,
+		entry.Method, entry.Path, entry.Status, sqlTime(entry.At))
+	return err
+}
+
+// ImpersonationEntries pages by the table's auto-incremented ID
+func (s *sqlStore) ImpersonationEntries(sessionID, after string, limit int) ([]ImpersonationEntry, error) {
+	var afterID int64
+	if after != "" {
+		var err error
+		if afterID, err = strconv.ParseInt(after, 10, 64); err != nil {
+			return nil, nil
+		}
+	}
+	query := "SELECT id, " + impersonationColumns + " FROM impersonations WHERE id > ?"
+	args := []any{afterID}
+	if sessionID != "" {
+		query += " AND session_id = ?"
+		args = append(args, sessionID)
+	}
+	rows, err := s.querier().QueryContext(s.ctx, s.dialect.rebind(query+" ORDER BY id LIMIT ?"), append(args, limit)...)
+	if err != nil {
+		return nil, s.lookupErr(err)
+	}
+	defer rows.Close()
+	var list []ImpersonationEntry
+	for rows.Next() {
+		var entry ImpersonationEntry
+		var id int64
+		err := rows.Scan(&id, &entry.SessionID, &entry.ImpersonatorID, &entry.UserID, &entry.Action, &entry.Reason, &entry.ClientIP,
+			&entry.Method, &entry.Path, &entry.Status, &entry.At)
+		if err != nil {
+			return nil, err
+		}
+		entry.ID, entry.At = strconv.FormatInt(id, 10), entry.At.UTC()
+		list = append(list, entry)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqlStore) PruneImpersonationEntries(cutoff time.Time) error {
+	_, err := s.exec("DELETE FROM impersonations WHERE created_at < ?", sqlTime(cutoff))
+	return err
+}
+
+// WithTransaction runs fn in a database transaction, rolled back if fn
+// returns an error or panics. Nested transactions join the enclosing one.
+func (s *sqlStore) WithTransaction(fn func(tx Store) error) (err error) {
+	if s.tx != nil {
+		return fn(s)
+	}
+	tx, err := s.db.BeginTx(s.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	inner := *s
+	inner.tx = tx
+	return fn(&inner)
+}
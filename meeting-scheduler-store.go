@@ -0,0 +1,380 @@
+package main
+
+import (
+	"errors"
+	"maps"
+	"sync"
+)
+
+// ErrNotFound is returned by Store lookups for records that don't exist
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is returned by compare-and-swap writes when the stored
+// record changed since it was read
+var ErrConflict = errors.New("conflict")
+
+// ErrStoreFull is returned by creates that would take a bounded store past
+// its caps when nothing can be evicted to make room
+var ErrStoreFull = errors.New("store is full")
+
+// EventRepository persists events
+type EventRepository interface {
+	CreateEvent(event Event) error
+	GetEvent(id string) (Event, error)
+	ListEvents() ([]Event, error)
+	UpdateEvent(event Event) error
+	// CompareAndSwapEvent writes event only if the stored event's Status
+	// and FinalTimeSlotID still match expected's, returning ErrConflict
+	// otherwise. Backends must check and write in one atomic step.
+	CompareAndSwapEvent(event, expected Event) error
+	DeleteEvent(id string) error
+}
+
+// TimeSlotRepository persists the time slots proposed for events
+type TimeSlotRepository interface {
+	CreateTimeSlot(slot TimeSlot) error
+	GetTimeSlot(id string) (TimeSlot, error)
+	ListTimeSlots(eventID string) ([]TimeSlot, error)
+	UpdateTimeSlot(slot TimeSlot) error
+	DeleteTimeSlot(id string) error
+}
+
+// AvailabilityRepository persists users' answers for time slots
+type AvailabilityRepository interface {
+	CreateAvailability(avail UserAvailability) error
+	FindAvailability(eventID, userID, timeslotID string) (UserAvailability, error)
+	ListAvailability(eventID string) ([]UserAvailability, error)
+	ListUserAvailability(eventID, userID string) ([]UserAvailability, error)
+	UpdateAvailability(avail UserAvailability) error
+	DeleteAvailability(id string) error
+}
+
+// Store is the persistence boundary for events, time slots and availability.
+// A backend implements all three repositories over one database so that
+// operations spanning several entities can run inside WithTransaction,
+// where a failure part-way through leaves no partial writes behind.
+// Handlers reach it through the request's Scheduler and never touch a
+// backend's storage directly.
+type Store interface {
+	EventRepository
+	TimeSlotRepository
+	AvailabilityRepository
+
+	// WithTransaction runs fn against a transactional view of the store.
+	// If fn returns an error (or panics) none of its writes are kept.
+	WithTransaction(fn func(tx Store) error) error
+}
+
+// store is the active storage backend used by the handlers
+var store Store = newMemoryStore()
+
+// memoryStore is the default Store, backed by the package-level maps. A
+// single mutex serializes access; transactions hold it for their duration
+// and restore a snapshot of the maps on failure. Caps on events and
+// availability rows bound its memory; see memoryLimits.
+type memoryStore struct {
+	mu     sync.RWMutex
+	limits memoryLimits
+	usage  *memoryUsage
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{limits: memoryLimitsFromEnv(), usage: newMemoryUsage()}
+}
+
+func (s *memoryStore) CreateEvent(event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return memoryTx{s}.CreateEvent(event)
+}
+
+func (s *memoryStore) GetEvent(id string) (Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return memoryTx{s}.GetEvent(id)
+}
+
+func (s *memoryStore) ListEvents() ([]Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return memoryTx{s}.ListEvents()
+}
+
+func (s *memoryStore) UpdateEvent(event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return memoryTx{s}.UpdateEvent(event)
+}
+
+func (s *memoryStore) CompareAndSwapEvent(event, expected Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return memoryTx{s}.CompareAndSwapEvent(event, expected)
+}
+
+func (s *memoryStore) DeleteEvent(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return memoryTx{s}.DeleteEvent(id)
+}
+
+func (s *memoryStore) CreateTimeSlot(slot TimeSlot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return memoryTx{s}.CreateTimeSlot(slot)
+}
+
+func (s *memoryStore) GetTimeSlot(id string) (TimeSlot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return memoryTx{s}.GetTimeSlot(id)
+}
+
+func (s *memoryStore) ListTimeSlots(eventID string) ([]TimeSlot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return memoryTx{s}.ListTimeSlots(eventID)
+}
+
+func (s *memoryStore) UpdateTimeSlot(slot TimeSlot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return memoryTx{s}.UpdateTimeSlot(slot)
+}
+
+func (s *memoryStore) DeleteTimeSlot(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return memoryTx{s}.DeleteTimeSlot(id)
+}
+
+func (s *memoryStore) CreateAvailability(avail UserAvailability) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return memoryTx{s}.CreateAvailability(avail)
+}
+
+func (s *memoryStore) FindAvailability(eventID, userID, timeslotID string) (UserAvailability, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return memoryTx{s}.FindAvailability(eventID, userID, timeslotID)
+}
+
+func (s *memoryStore) ListAvailability(eventID string) ([]UserAvailability, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return memoryTx{s}.ListAvailability(eventID)
+}
+
+func (s *memoryStore) ListUserAvailability(eventID, userID string) ([]UserAvailability, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return memoryTx{s}.ListUserAvailability(eventID, userID)
+}
+
+func (s *memoryStore) UpdateAvailability(avail UserAvailability) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return memoryTx{s}.UpdateAvailability(avail)
+}
+
+func (s *memoryStore) DeleteAvailability(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return memoryTx{s}.DeleteAvailability(id)
+}
+
+func (s *memoryStore) WithTransaction(fn func(tx Store) error) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := snapshotMemoryMaps()
+	defer func() {
+		if r := recover(); r != nil {
+			snapshot.restore()
+			panic(r)
+		}
+		if err != nil {
+			snapshot.restore()
+		}
+	}()
+
+	return fn(memoryTx{s})
+}
+
+// memoryTx operates on the maps directly and assumes the caller holds the
+// memoryStore lock
+type memoryTx struct {
+	s *memoryStore
+}
+
+func (tx memoryTx) CreateEvent(event Event) error {
+	if err := tx.makeRoomForEvent(event.ID); err != nil {
+		return err
+	}
+	events[event.ID] = event
+	tx.s.usage.touch(event.ID)
+	return nil
+}
+
+func (tx memoryTx) GetEvent(id string) (Event, error) {
+	event, exists := events[id]
+	if !exists {
+		return Event{}, ErrNotFound
+	}
+	tx.s.usage.touch(id)
+	return event, nil
+}
+
+func (memoryTx) ListEvents() ([]Event, error) {
+	var eventList []Event
+	for _, event := range events {
+		eventList = append(eventList, event)
+	}
+	return eventList, nil
+}
+
+func (memoryTx) UpdateEvent(event Event) error {
+	if _, exists := events[event.ID]; !exists {
+		return ErrNotFound
+	}
+	events[event.ID] = event
+	return nil
+}
+
+func (memoryTx) CompareAndSwapEvent(event, expected Event) error {
+	stored, exists := events[event.ID]
+	if !exists {
+		return ErrNotFound
+	}
+	if !sameFinalization(stored, expected) {
+		return ErrConflict
+	}
+	events[event.ID] = event
+	return nil
+}
+
+func (memoryTx) DeleteEvent(id string) error {
+	if _, exists := events[id]; !exists {
+		return ErrNotFound
+	}
+	delete(events, id)
+	return nil
+}
+
+func (memoryTx) CreateTimeSlot(slot TimeSlot) error {
+	timeSlots[slot.ID] = slot
+	return nil
+}
+
+func (memoryTx) GetTimeSlot(id string) (TimeSlot, error) {
+	slot, exists := timeSlots[id]
+	if !exists {
+		return TimeSlot{}, ErrNotFound
+	}
+	return slot, nil
+}
+
+func (memoryTx) ListTimeSlots(eventID string) ([]TimeSlot, error) {
+	var slotList []TimeSlot
+	for _, slot := range timeSlots {
+		if slot.EventID == eventID {
+			slotList = append(slotList, slot)
+		}
+	}
+	return slotList, nil
+}
+
+func (memoryTx) UpdateTimeSlot(slot TimeSlot) error {
+	if _, exists := timeSlots[slot.ID]; !exists {
+		return ErrNotFound
+	}
+	timeSlots[slot.ID] = slot
+	return nil
+}
+
+func (memoryTx) DeleteTimeSlot(id string) error {
+	if _, exists := timeSlots[id]; !exists {
+		return ErrNotFound
+	}
+	delete(timeSlots, id)
+	return nil
+}
+
+func (tx memoryTx) CreateAvailability(avail UserAvailability) error {
+	if err := tx.makeRoomForAvailability(avail); err != nil {
+		return err
+	}
+	userAvailability[avail.ID] = avail
+	return nil
+}
+
+func (memoryTx) FindAvailability(eventID, userID, timeslotID string) (UserAvailability, error) {
+	for _, avail := range userAvailability {
+		if avail.EventID == eventID && avail.UserID == userID && avail.TimeSlotID == timeslotID {
+			return avail, nil
+		}
+	}
+	return UserAvailability{}, ErrNotFound
+}
+
+func (memoryTx) ListAvailability(eventID string) ([]UserAvailability, error) {
+	var availabilityList []UserAvailability
+	for _, avail := range userAvailability {
+		if avail.EventID == eventID {
+			availabilityList = append(availabilityList, avail)
+		}
+	}
+	return availabilityList, nil
+}
+
+func (memoryTx) ListUserAvailability(eventID, userID string) ([]UserAvailability, error) {
+	var availabilityList []UserAvailability
+	for _, avail := range userAvailability {
+		if avail.EventID == eventID && avail.UserID == userID {
+			availabilityList = append(availabilityList, avail)
+		}
+	}
+	return availabilityList, nil
+}
+
+func (memoryTx) UpdateAvailability(avail UserAvailability) error {
+	if _, exists := userAvailability[avail.ID]; !exists {
+		return ErrNotFound
+	}
+	userAvailability[avail.ID] = avail
+	return nil
+}
+
+func (memoryTx) DeleteAvailability(id string) error {
+	if _, exists := userAvailability[id]; !exists {
+		return ErrNotFound
+	}
+	delete(userAvailability, id)
+	return nil
+}
+
+// Nested transactions join the enclosing one
+func (tx memoryTx) WithTransaction(fn func(tx Store) error) error {
+	return fn(tx)
+}
+
+type memorySnapshot struct {
+	events           map[string]Event
+	timeSlots        map[string]TimeSlot
+	userAvailability map[string]UserAvailability
+}
+
+func snapshotMemoryMaps() memorySnapshot {
+	return memorySnapshot{
+		events:           maps.Clone(events),
+		timeSlots:        maps.Clone(timeSlots),
+		userAvailability: maps.Clone(userAvailability),
+	}
+}
+
+func (s memorySnapshot) restore() {
+	events = s.events
+	timeSlots = s.timeSlots
+	userAvailability = s.userAvailability
+}
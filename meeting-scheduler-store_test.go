@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTransactionRollsBackOnError(t *testing.T) {
+	resetStorage(t)
+
+	assert.NoError(t, store.CreateEvent(Event{ID: "e1", Title: "Kept"}))
+
+	failure := errors.New("boom")
+	err := store.WithTransaction(func(tx Store) error {
+		if err := tx.CreateEvent(Event{ID: "e2", Title: "Discarded"}); err != nil {
+			return err
+		}
+		if err := tx.DeleteEvent("e1"); err != nil {
+			return err
+		}
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+
+	_, err = store.GetEvent("e1")
+	assert.NoError(t, err)
+	_, err = store.GetEvent("e2")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestWithTransactionRollsBackOnPanic(t *testing.T) {
+	resetStorage(t)
+
+	assert.Panics(t, func() {
+		_ = store.WithTransaction(func(tx Store) error {
+			_ = tx.CreateEvent(Event{ID: "e1"})
+			panic("boom")
+		})
+	})
+
+	_, err := store.GetEvent("e1")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestDeleteEventCascades(t *testing.T) {
+	router := newTestRouter(t)
+
+	w := doJSON(router, "POST", "/api/v1/events", CreateEventRequest{Title: "Sync", OrganizerID: "user1", RequiredDuration: 30})
+	var event Event
+	decodeJSON(t, w, &event)
+
+	start := time.Now().Add(24 * time.Hour)
+	w = doJSON(router, "POST", "/api/v1/events/"+event.ID+"/timeslots", CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	var slot TimeSlot
+	decodeJSON(t, w, &slot)
+
+	w = doJSON(router, "POST", "/api/v1/events/"+event.ID+"/users/user2/availability", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = doJSON(router, "DELETE", "/api/v1/events/"+event.ID, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	_, err := store.GetEvent(event.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.GetTimeSlot(slot.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.FindAvailability(event.ID, "user2", slot.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFinalizeEvent(t *testing.T) {
+	router := newTestRouter(t)
+
+	w := doJSON(router, "POST", "/api/v1/events", CreateEventRequest{Title: "Sync", OrganizerID: "user1", RequiredDuration: 30})
+	var event Event
+	decodeJSON(t, w, &event)
+
+	start := time.Now().Add(24 * time.Hour)
+	w = doJSON(router, "POST", "/api/v1/events/"+event.ID+"/timeslots", CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	var slot TimeSlot
+	decodeJSON(t, w, &slot)
+
+	w = doJSON(router, "POST", "/api/v1/events/"+event.ID+"/finalize", FinalizeEventRequest{TimeSlotID: "missing"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doJSON(router, "POST", "/api/v1/events/"+event.ID+"/finalize", FinalizeEventRequest{TimeSlotID: slot.ID})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var finalized Event
+	decodeJSON(t, w, &finalized)
+	assert.Equal(t, "finalized", finalized.Status)
+	assert.Equal(t, slot.ID, finalized.FinalTimeSlotID)
+}
+
+func TestCompareAndSwapEvent(t *testing.T) {
+	resetStorage(t)
+	assert.NoError(t, store.CreateEvent(Event{ID: "e1", Status: "active"}))
+
+	read, _ := store.GetEvent("e1")
+	first := read
+	first.Status, first.FinalTimeSlotID = "finalized", "s1"
+	second := read
+	second.Status, second.FinalTimeSlotID = "finalized", "s2"
+	assert.NoError(t, store.CompareAndSwapEvent(first, read))
+	assert.ErrorIs(t, store.CompareAndSwapEvent(second, read), ErrConflict)
+	assert.ErrorIs(t, store.CompareAndSwapEvent(Event{ID: "missing"}, Event{}), ErrNotFound)
+
+	stored, _ := store.GetEvent("e1")
+	assert.Equal(t, "s1", stored.FinalTimeSlotID)
+}
+
+func TestConcurrentFinalizeConflicts(t *testing.T) {
+	router := newTestRouter(t)
+
+	w := doJSON(router, "POST", "/api/v1/events", CreateEventRequest{Title: "Sync", OrganizerID: "user1", RequiredDuration: 30})
+	var event Event
+	decodeJSON(t, w, &event)
+	start := time.Now().Add(24 * time.Hour)
+	var slots []TimeSlot
+	for i := 0; i < 8; i++ {
+		slotStart := start.Add(time.Duration(i) * time.Hour)
+		w = doJSON(router, "POST", "/api/v1/events/"+event.ID+"/timeslots", CreateTimeSlotRequest{StartTime: slotStart, EndTime: slotStart.Add(time.Hour)})
+		var slot TimeSlot
+		decodeJSON(t, w, &slot)
+		slots = append(slots, slot)
+	}
+
+	var wg sync.WaitGroup
+	responses := make([]*httptest.ResponseRecorder, len(slots))
+	for i, slot := range slots {
+		wg.Add(1)
+		go func(i int, slotID string) {
+			defer wg.Done()
+			responses[i] = doJSON(router, "POST", "/api/v1/events/"+event.ID+"/finalize", FinalizeEventRequest{TimeSlotID: slotID})
+		}(i, slot.ID)
+	}
+	wg.Wait()
+
+	winner := ""
+	for i, w := range responses {
+		if w.Code == http.StatusOK {
+			assert.Empty(t, winner, "only one finalize wins")
+			winner = slots[i].ID
+		}
+	}
+	require.NotEmpty(t, winner)
+	for _, w := range responses {
+		if w.Code == http.StatusOK {
+			continue
+		}
+		require.Equal(t, http.StatusConflict, w.Code)
+		var conflict struct {
+			Event         Event    `json:"event"`
+			FinalTimeSlot TimeSlot `json:"finalTimeslot"`
+		}
+		decodeJSON(t, w, &conflict)
+		assert.Equal(t, winner, conflict.Event.FinalTimeSlotID)
+		assert.Equal(t, winner, conflict.FinalTimeSlot.ID)
+	}
+
+	w = doJSON(router, "POST", "/api/v1/events/"+event.ID+"/finalize", FinalizeEventRequest{TimeSlotID: winner})
+	assert.Equal(t, http.StatusOK, w.Code, "finalizing at the chosen slot again is a no-op")
+
+	other := slots[0].ID
+	if other == winner {
+		other = slots[1].ID
+	}
+	w = doJSON(router, "POST", "/api/v1/events/"+event.ID+"/finalize", FinalizeEventRequest{TimeSlotID: other, ReplaceTimeSlotID: winner})
+	require.Equal(t, http.StatusOK, w.Code)
+	var moved Event
+	decodeJSON(t, w, &moved)
+	assert.Equal(t, other, moved.FinalTimeSlotID)
+	w = doJSON(router, "POST", "/api/v1/events/"+event.ID+"/finalize", FinalizeEventRequest{TimeSlotID: winner, ReplaceTimeSlotID: winner})
+	assert.Equal(t, http.StatusConflict, w.Code, "the slot being replaced must still be the final one")
+}
+
+// testStoreConformance exercises the Store contract against s, which must
+// start out empty. The integration harness runs it on every backend.
+func testStoreConformance(t *testing.T, s Store) {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	event := Event{ID: uuid.New().String(), Title: "Sync", OrganizerID: "ada", OrgID: "acme", RequiredDuration: 30,
+		Status: "active", Invitees: []string{"bob"}, CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, s.CreateEvent(event))
+	stored, err := s.GetEvent(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, event, stored)
+	_, err = s.GetEvent(uuid.New().String())
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	event.Title = "Weekly sync"
+	require.NoError(t, s.UpdateEvent(event))
+	require.NoError(t, s.UpdateEvent(event), "unchanged updates still find the row")
+	assert.ErrorIs(t, s.UpdateEvent(Event{ID: uuid.New().String()}), ErrNotFound)
+	list, err := s.ListEvents()
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "Weekly sync", list[0].Title)
+
+	slot := TimeSlot{ID: uuid.New().String(), EventID: event.ID, StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour), CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, s.CreateTimeSlot(slot))
+	slots, err := s.ListTimeSlots(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []TimeSlot{slot}, slots)
+	slot.EndTime = now.Add(3 * time.Hour)
+	require.NoError(t, s.UpdateTimeSlot(slot))
+	storedSlot, err := s.GetTimeSlot(slot.ID)
+	require.NoError(t, err)
+	assert.Equal(t, slot, storedSlot)
+
+	finalized := event
+	finalized.Status, finalized.FinalTimeSlotID = "finalized", slot.ID
+	require.NoError(t, s.CompareAndSwapEvent(finalized, event))
+	assert.ErrorIs(t, s.CompareAndSwapEvent(finalized, event), ErrConflict)
+	assert.NoError(t, s.CompareAndSwapEvent(finalized, finalized), "an unchanged swap succeeds")
+
+	from := slot.StartTime.Add(30 * time.Minute)
+	avail := UserAvailability{ID: uuid.New().String(), UserID: "bob", EventID: event.ID, TimeSlotID: slot.ID,
+		Status: "available", AvailableFrom: &from, CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, s.CreateAvailability(avail))
+	found, err := s.FindAvailability(event.ID, "bob", slot.ID)
+	require.NoError(t, err)
+	assert.Equal(t, avail, found)
+	_, err = s.FindAvailability(event.ID, "cy", slot.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	avail.Status = "unavailable"
+	require.NoError(t, s.UpdateAvailability(avail))
+	mine, err := s.ListUserAvailability(event.ID, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, []UserAvailability{avail}, mine)
+
+	failure := errors.New("boom")
+	err = s.WithTransaction(func(tx Store) error {
+		require.NoError(t, tx.DeleteAvailability(avail.ID))
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	all, err := s.ListAvailability(event.ID)
+	require.NoError(t, err)
+	assert.Len(t, all, 1, "rolled back deletes are kept")
+
+	require.NoError(t, s.DeleteAvailability(avail.ID))
+	assert.ErrorIs(t, s.DeleteAvailability(avail.ID), ErrNotFound)
+	require.NoError(t, s.DeleteTimeSlot(slot.ID))
+	assert.ErrorIs(t, s.DeleteTimeSlot(slot.ID), ErrNotFound)
+	require.NoError(t, s.DeleteEvent(event.ID))
+	assert.ErrorIs(t, s.DeleteEvent(event.ID), ErrNotFound)
+}
+
+func TestStoreConformance(t *testing.T) {
+	resetStorage(t)
+	testStoreConformance(t, store)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// strictRequest reports whether the request body must not carry fields
+// the endpoint doesn't know. STRICT_JSON turns it on for every request;
+// clients can ask for it with "Prefer: handling=strict" (RFC 7240). It will
+// be the default in v2.
+func strictRequest(c *gin.Context) bool {
+	if getenvBool("STRICT_JSON", false) {
+		return true
+	}
+	for _, preference := range strings.Split(c.GetHeader("Prefer"), ",") {
+		if strings.TrimSpace(preference) == "handling=strict" {
+			return true
+		}
+	}
+	return false
+}
+
+// bindJSON is ShouldBindJSON, accepting the time formats normalizeTimes
+// does and, on strict requests, rejecting unknown fields and trailing data
+func bindJSON(c *gin.Context, obj interface{}) error {
+	if c.Request.Body == nil {
+		return errors.New("invalid request")
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	if body, err = normalizeTimes(body, reflect.TypeOf(obj)); err != nil {
+		return err
+	}
+	if !strictRequest(c) {
+		return binding.JSON.BindBody(body, obj)
+	}
+	c.Header("Preference-Applied", "handling=strict")
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return unknownFieldError(obj, strings.Trim(field, `"`))
+		}
+		return err
+	}
+	if decoder.More() {
+		return errors.New("Request body must hold a single JSON value")
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
+
+// unknownFieldError names the unexpected key, suggesting the closest field
+// the request type does have
+func unknownFieldError(obj interface{}, field string) error {
+	best, bestDistance := "", len(field)/3+1
+	for _, name := range jsonFieldNames(reflect.TypeOf(obj), map[reflect.Type]bool{}) {
+		if d := editDistance(strings.ToLower(field), strings.ToLower(name)); d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+	if best != "" {
+		return fmt.Errorf("Unknown field %q; did you mean %q?", field, best)
+	}
+	return fmt.Errorf("Unknown field %q", field)
+}
+
+// jsonFieldNames lists the JSON names of a type's fields, including those
+// of nested structs
+func jsonFieldNames(t reflect.Type, seen map[reflect.Type]bool) []string {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return nil
+	}
+	seen[t] = true
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			names = append(names, jsonFieldNames(field.Type, seen)...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names = append(names, name)
+		names = append(names, jsonFieldNames(field.Type, seen)...)
+	}
+	return names
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func postStrict(router http.Handler, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Prefer", "handling=strict")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestStrictRequestsRejectUnknownFields(t *testing.T) {
+	router := newTestRouter(t)
+
+	w := postStrict(router, "/api/v1/events", `{"title":"Sync","organizerId":"ada","requiredDuratoin":30}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"Unknown field \"requiredDuratoin\"; did you mean \"requiredDuration\"?"}`, w.Body.String())
+	assert.Equal(t, "handling=strict", w.Header().Get("Preference-Applied"))
+
+	w = postStrict(router, "/api/v1/events", `{"title":"Sync","organizerId":"ada","requiredDuration":30,"colour":"red"}`)
+	assert.JSONEq(t, `{"error":"Unknown field \"colour\""}`, w.Body.String())
+
+	w = postStrict(router, "/api/v1/events", `{"title":"Sync","organizerId":"ada","requiredDuration":30} {}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "trailing data is rejected")
+
+	w = postStrict(router, "/api/v1/events", `{"title":"Sync","organizerId":"ada","requiredDuration":30}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = doJSON(router, "POST", "/api/v1/events", map[string]interface{}{"title": "Sync", "organizerId": "ada", "requiredDuration": 30, "colour": "red"})
+	assert.Equal(t, http.StatusCreated, w.Code, "lenient unless asked")
+
+	t.Setenv("STRICT_JSON", "true")
+	w = doJSON(router, "POST", "/api/v1/events", map[string]interface{}{"title": "Sync", "organizerId": "ada", "requiredDuration": 30, "colour": "red"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestStrictDecodingCoversSCIMAndPushedAvailability(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	t.Setenv("STRICT_JSON", "true")
+	organizations.Save(Organization{ID: "acme", SCIMToken: "scim-secret", IntegrationSecret: "hr-secret"})
+
+	w := scimRequest(router, "POST", "/scim/v2/acme/Users", `{"userName":"ada@acme.test","userNmae":"ada"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `Unknown field \"userNmae\"`)
+
+	body := []byte(`{"source":"hr","intervals":[],"sauce":"hr"}`)
+	req, _ := http.NewRequest("POST", "/api/v1/integrations/availability", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Scheduler-Org", "acme")
+	req.Header.Set(webhookSignatureHeader, signWebhook([]string{"hr-secret"}, clock.Now(), body))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `Unknown field \"sauce\"`)
+}
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance("title", "title"))
+	assert.Equal(t, 2, editDistance("requiredDuratoin", "requiredDuration"))
+	assert.Equal(t, 3, editDistance("kitten", "sitting"))
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// minHistory is how many past events of a kind are needed before their
+// durations override the built-in defaults
+const minHistory = 3
+
+// meetingKinds maps title keywords to a meeting kind and its usual length
+// in minutes. The first keyword found in a title wins.
+var meetingKinds = []struct {
+	Keyword  string
+	Duration int
+}{
+	{"standup", 15},
+	{"stand-up", 15},
+	{"check-in", 15},
+	{"1:1", 30},
+	{"one-on-one", 30},
+	{"sync", 30},
+	{"interview", 60},
+	{"review", 45},
+	{"retro", 60},
+	{"planning", 60},
+	{"kickoff", 60},
+	{"demo", 30},
+	{"training", 90},
+	{"workshop", 120},
+	{"offsite", 240},
+}
+
+const defaultSuggestedDuration = 30
+
+// DurationSuggestion is the suggested shape for a new event
+type DurationSuggestion struct {
+	RequiredDuration int `json:"requiredDuration"`
+	// SlotGranularity is the suggested spacing between candidate slots
+	SlotGranularity int    `json:"slotGranularity"`
+	Kind            string `json:"kind,omitempty"`
+	// Source is "history", "keyword" or "default"
+	Source string `json:"source"`
+	// Basis is how many past events the suggestion was derived from
+	Basis int `json:"basis,omitempty"`
+}
+
+// meetingKind returns the keyword recognised in a title, if any
+func meetingKind(title string) (string, int) {
+	lower := strings.ToLower(title)
+	for _, kind := range meetingKinds {
+		if strings.Contains(lower, kind.Keyword) {
+			return kind.Keyword, kind.Duration
+		}
+	}
+	return "", 0
+}
+
+// slotGranularity picks a slot spacing suited to the meeting length
+func slotGranularity(duration int) int {
+	switch {
+	case duration <= 30:
+		return 15
+	case duration <= 60:
+		return 30
+	default:
+		return 60
+	}
+}
+
+// SuggestDuration proposes a duration for an event with the given title.
+// Past events of the same kind take precedence over the keyword defaults,
+// using their median duration rounded to 15 minutes.
+func (s *Scheduler) SuggestDuration(title string) (DurationSuggestion, error) {
+	kind, duration := meetingKind(title)
+	if kind == "" {
+		return DurationSuggestion{
+			RequiredDuration: defaultSuggestedDuration,
+			SlotGranularity:  slotGranularity(defaultSuggestedDuration),
+			Source:           "default",
+		}, nil
+	}
+
+	eventList, err := s.store.ListEvents()
+	if err != nil {
+		return DurationSuggestion{}, err
+	}
+	var history []int
+	for _, event := range eventList {
+		if pastKind, _ := meetingKind(event.Title); pastKind == kind {
+			history = append(history, event.RequiredDuration)
+		}
+	}
+
+	suggestion := DurationSuggestion{RequiredDuration: duration, Kind: kind, Source: "keyword"}
+	if len(history) >= minHistory {
+		sort.Ints(history)
+		median := history[len(history)/2]
+		rounded := (median + 7) / 15 * 15
+		if rounded == 0 {
+			rounded = 15
+		}
+		suggestion.RequiredDuration = rounded
+		suggestion.Source = "history"
+		suggestion.Basis = len(history)
+	}
+	suggestion.SlotGranularity = slotGranularity(suggestion.RequiredDuration)
+	return suggestion, nil
+}
+
+func suggestDuration(c *gin.Context) {
+	title := c.Query("title")
+	if title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Title is required"})
+		return
+	}
+
+	suggestion, err := contextScheduler(c).SuggestDuration(title)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, suggestion)
+}
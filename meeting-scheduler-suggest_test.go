@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggestDuration(t *testing.T) {
+	scheduler, _ := newTestScheduler(t)
+
+	suggestion, err := scheduler.SuggestDuration("Daily Standup")
+	require.NoError(t, err)
+	assert.Equal(t, DurationSuggestion{RequiredDuration: 15, SlotGranularity: 15, Kind: "standup", Source: "keyword"}, suggestion)
+
+	suggestion, err = scheduler.SuggestDuration("Coffee")
+	require.NoError(t, err)
+	assert.Equal(t, "default", suggestion.Source)
+	assert.Equal(t, 30, suggestion.RequiredDuration)
+
+	// This team's interviews run long
+	for _, minutes := range []int{90, 90, 75} {
+		_, err := scheduler.CreateEvent(CreateEventRequest{Title: "Interview: backend", OrganizerID: "ada", RequiredDuration: minutes})
+		require.NoError(t, err)
+	}
+	suggestion, err = scheduler.SuggestDuration("Onsite interview")
+	require.NoError(t, err)
+	assert.Equal(t, DurationSuggestion{RequiredDuration: 90, SlotGranularity: 60, Kind: "interview", Source: "history", Basis: 3}, suggestion)
+}
+
+func TestSuggestDurationRequiresTitle(t *testing.T) {
+	router := newTestRouter(t)
+	w := doJSON(router, "GET", "/api/v1/suggestions/duration", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doJSON(router, "GET", "/api/v1/suggestions/duration?title=Sprint+planning", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"requiredDuration":60`)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter returns a router with every API endpoint registered on a
+// fresh in-memory store
+func newTestRouter(t testing.TB) *gin.Engine {
+	resetStorage(t)
+	throttles = newMemoryThrottles()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	registerRoutes(router)
+	return router
+}
+
+// testDatabase is the database the integration harness started, if any.
+// Without one, tests run against the in-memory store.
+var testDatabase *ephemeralDatabase
+
+// ephemeralDatabase is a throwaway database store and how to empty it
+type ephemeralDatabase struct {
+	store Store
+	clear func() error
+}
+
+// resetStorage installs an empty store: a fresh in-memory one, or the
+// emptied test database under the integration harness
+func resetStorage(t testing.TB) {
+	events = make(map[string]Event)
+	timeSlots = make(map[string]TimeSlot)
+	userAvailability = make(map[string]UserAvailability)
+
+	previous := store
+	store = newMemoryStore()
+	if testDatabase != nil {
+		if err := testDatabase.clear(); err != nil {
+			t.Fatalf("emptying the test database: %v", err)
+		}
+		store = testDatabase.store
+	}
+	t.Cleanup(func() { store = previous })
+}
+
+// issueSessionToken starts a session for the user and returns its bearer
+// token, leaving out the refresh token tests don't need
+func issueSessionToken(user User) (string, error) {
+	now := clock.Now()
+	session, _, err := sessions.Start(user.ID, "", "", now)
+	if err != nil {
+		return "", err
+	}
+	return signAccessToken(user, session.ID, now, now.Add(accessTokenTTL))
+}
+
+// doJSON sends body (marshalled unless nil) and returns the recorder
+func doJSON(router *gin.Engine, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var reqBody []byte
+	if body != nil {
+		reqBody, _ = json.Marshal(body)
+	}
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// decodeJSON unmarshals a recorder body into v, failing the test on error
+func decodeJSON(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding response %q: %v", w.Body.String(), err)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ThrottleStore keeps failed attempts and lockouts where every replica
+// sees them. Keys name what is being throttled, a client IP or a token.
+type ThrottleStore interface {
+	// RecordFailure counts a failed attempt against key and returns the
+	// count. A count started before since is begun again.
+	RecordFailure(key string, now, since time.Time) (int, error)
+	// Lock turns key away until the given time and clears its count
+	Lock(key string, until time.Time) error
+	// LockedUntil is when key's lockout ends, zero if it has none
+	LockedUntil(key string) (time.Time, error)
+	// Prune forgets keys whose count began before since and whose
+	// lockout, if any, ended by now
+	Prune(now, since time.Time) error
+}
+
+// throttleLimits is how many failures are allowed within the window
+// before a key is locked out, and for how long
+type throttleLimits struct {
+	maxFailures int
+	window      time.Duration
+	lockout     time.Duration
+}
+
+// throttleLimitsFromEnv reads THROTTLE_MAX_FAILURES, THROTTLE_WINDOW and
+// THROTTLE_LOCKOUT
+func throttleLimitsFromEnv() throttleLimits {
+	return throttleLimits{
+		maxFailures: getenvInt("THROTTLE_MAX_FAILURES", 10),
+		window:      getenvDuration("THROTTLE_WINDOW", 15*time.Minute),
+		lockout:     getenvDuration("THROTTLE_LOCKOUT", 15*time.Minute),
+	}
+}
+
+// throttledFailure reports whether a response status is a failed guess at
+// a token, link or login, rather than a refusal for some other reason
+func throttledFailure(status int) bool {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusGone:
+		return true
+	}
+	return false
+}
+
+// throttleKeys are what a request is throttled by: the client's IP, the
+// signed-in account, so guessing codes from many addresses still adds up,
+// and on signed-link routes the token. Tokens are hashed so the store
+// never holds a usable link.
+func throttleKeys(c *gin.Context) []string {
+	keys := []string{"ip:" + c.ClientIP()}
+	if user, ok := currentUser(c); ok {
+		keys = append(keys, "user:"+user.ID)
+	}
+	if token := c.Param("token"); token != "" {
+		sum := sha256.Sum256([]byte(token))
+		keys = append(keys, "token:"+hex.EncodeToString(sum[:16]))
+	}
+	return keys
+}
+
+// throttle turns away clients and tokens with too many recent failures,
+// and counts this request's failure against them. When the store can't be
+// reached requests are let through rather than locking everyone out.
+func throttle(limits throttleLimits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys := throttleKeys(c)
+		now := clock.Now()
+		for _, key := range keys {
+			until, err := throttles.LockedUntil(key)
+			if err != nil {
+				log.Printf("Checking lockout for %s: %v", key, err)
+				continue
+			}
+			if until.After(now) {
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(until.Sub(now).Seconds()))))
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts; try again later"})
+				return
+			}
+		}
+
+		c.Next()
+
+		if !throttledFailure(c.Writer.Status()) {
+			return
+		}
+		for _, key := range keys {
+			failures, err := throttles.RecordFailure(key, now, now.Add(-limits.window))
+			if err != nil {
+				log.Printf("Recording failure for %s: %v", key, err)
+				continue
+			}
+			if failures < limits.maxFailures {
+				continue
+			}
+			log.Printf("Locking out %s for %s after %d failures", key, limits.lockout, failures)
+			if err := throttles.Lock(key, now.Add(limits.lockout)); err != nil {
+				log.Printf("Locking out %s: %v", key, err)
+			}
+		}
+	}
+}
+
+// pruneThrottles drops lapsed failure counts and lockouts
+func pruneThrottles(now time.Time) {
+	if err := throttles.Prune(now, now.Add(-throttleLimitsFromEnv().window)); err != nil {
+		log.Printf("Pruning throttles failed, will retry: %v", err)
+	}
+}
+
+// underlyingStore is the database store beneath a change feed or read
+// replicas
+func underlyingStore(s Store) Store {
+	switch s := s.(type) {
+	case *feedStore:
+		return underlyingStore(s.Store)
+	case *replicatedStore:
+		return underlyingStore(s.primary)
+	}
+	return s
+}
+
+// throttleStoreFor is the database underneath the store when it can keep
+// lockouts, so they hold across replicas. Otherwise they are kept in
+// memory, per instance.
+func throttleStoreFor(s Store) ThrottleStore {
+	if throttleStore, ok := underlyingStore(s).(ThrottleStore); ok {
+		return throttleStore
+	}
+	return newMemoryThrottles()
+}
+
+// throttles is the active lockout store
+var throttles ThrottleStore = newMemoryThrottles()
+
+type throttleState struct {
+	failures    int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+// memoryThrottles is the ThrottleStore for single-instance deployments
+type memoryThrottles struct {
+	mu    sync.Mutex
+	state map[string]throttleState
+}
+
+func newMemoryThrottles() *memoryThrottles {
+	return &memoryThrottles{state: make(map[string]throttleState)}
+}
+
+func (m *memoryThrottles) RecordFailure(key string, now, since time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state := m.state[key]
+	if state.windowStart.Before(since) {
+		state.failures, state.windowStart = 0, now
+	}
+	state.failures++
+	m.state[key] = state
+	return state.failures, nil
+}
+
+func (m *memoryThrottles) Lock(key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state[key] = throttleState{lockedUntil: until}
+	return nil
+}
+
+func (m *memoryThrottles) LockedUntil(key string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state[key].lockedUntil, nil
+}
+
+func (m *memoryThrottles) Prune(now, since time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, state := range m.state {
+		if state.windowStart.Before(since) && !state.lockedUntil.After(now) {
+			delete(m.state, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailedLinkGuessesLockOutTheClient(t *testing.T) {
+	t.Setenv("THROTTLE_MAX_FAILURES", "3")
+	fake := useFakeClock(t, time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	router := newTestRouter(t)
+
+	redeem := func(ip, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/invites/"+token, nil)
+		req.Header.Set("X-Forwarded-For", ip)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, token := range []string{"guess-1", "guess-2", "guess-3"} {
+		require.Equal(t, http.StatusNotFound, redeem("203.0.113.7", token).Code)
+	}
+	w := redeem("203.0.113.7", "guess-4")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "900", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusNotFound, redeem("198.51.100.2", "guess-4").Code, "other clients aren't locked out")
+
+	fake.Advance(15 * time.Minute)
+	assert.Equal(t, http.StatusNotFound, redeem("203.0.113.7", "guess-5").Code)
+}
+
+func TestFailuresOnOneTokenLockItOutEverywhere(t *testing.T) {
+	t.Setenv("THROTTLE_MAX_FAILURES", "3")
+	useFakeClock(t, time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	router := newTestRouter(t)
+
+	for _, ip := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3", "203.0.113.4"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/booking/leaked-token", nil)
+		req.Header.Set("X-Forwarded-For", ip)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if ip == "203.0.113.4" {
+			assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		} else {
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		}
+	}
+}
+
+func TestMemoryThrottlesPrune(t *testing.T) {
+	m := newMemoryThrottles()
+	now := time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC)
+	_, err := m.RecordFailure("ip:stale", now.Add(-time.Hour), now.Add(-2*time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, m.Lock("ip:locked", now.Add(time.Minute)))
+	_, err = m.RecordFailure("ip:recent", now, now.Add(-15*time.Minute))
+	require.NoError(t, err)
+
+	require.NoError(t, m.Prune(now, now.Add(-15*time.Minute)))
+	assert.NotContains(t, m.state, "ip:stale")
+	assert.Contains(t, m.state, "ip:locked")
+	assert.Contains(t, m.state, "ip:recent")
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeline entry types besides the domain event types
+const (
+	TimelineComment      = "comment"
+	TimelineBroadcast    = "broadcast"
+	TimelineNotification = "notification.sent"
+)
+
+// maxActivityEntries bounds each event's recorded activity; the oldest
+// entries drop off
+const maxActivityEntries = 1000
+
+// TimelineEntry is one item of an event's activity timeline
+type TimelineEntry struct {
+	At      time.Time `json:"at"`
+	Type    string    `json:"type"`
+	Summary string    `json:"summary"`
+	// ActorID is who acted, where known: the responder or comment author
+	ActorID string `json:"actorId,omitempty"`
+	// RecipientID is who a notification went to
+	RecipientID string `json:"recipientId,omitempty"`
+	// ImpersonatorID is the operator who acted while impersonating a user
+	ImpersonatorID string        `json:"impersonatorId,omitempty"`
+	Status         *StatusChange `json:"status,omitempty"`
+}
+
+// StatusChange marks an entry that moved the event to a new status
+type StatusChange struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to"`
+}
+
+// activityRegistry records what happened to each event, oldest first,
+// along with the status it was last seen in
+type activityRegistry struct {
+	mu       sync.RWMutex
+	entries  map[string][]TimelineEntry
+	statuses map[string]string
+}
+
+func newActivityRegistry() *activityRegistry {
+	return &activityRegistry{entries: make(map[string][]TimelineEntry), statuses: make(map[string]string)}
+}
+
+func (r *activityRegistry) Add(eventID string, entry TimelineEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.add(eventID, entry)
+}
+
+func (r *activityRegistry) add(eventID string, entry TimelineEntry) {
+	list := append(r.entries[eventID], entry)
+	if len(list) > maxActivityEntries {
+		list = list[len(list)-maxActivityEntries:]
+	}
+	r.entries[eventID] = list
+}
+
+// AddEventChange records a change to the event itself, noting a status
+// transition when its status differs from the last one seen
+func (r *activityRegistry) AddEventChange(event Event, entry TimelineEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if previous, seen := r.statuses[event.ID]; !seen || previous != event.Status {
+		entry.Status = &StatusChange{From: previous, To: event.Status}
+		r.statuses[event.ID] = event.Status
+	}
+	r.add(event.ID, entry)
+}
+
+func (r *activityRegistry) ForEvent(eventID string) []TimelineEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]TimelineEntry{}, r.entries[eventID]...)
+}
+
+// Forget drops a deleted event's activity
+func (r *activityRegistry) Forget(eventID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, eventID)
+	delete(r.statuses, eventID)
+}
+
+var activity = newActivityRegistry()
+
+// recordActivity adds domain events to their event's timeline
+func recordActivity(e DomainEvent) {
+	if e.EventID == "" {
+		return
+	}
+	entry := TimelineEntry{At: e.OccurredAt, Type: string(e.Type), ImpersonatorID: e.ImpersonatorID}
+	switch payload := e.Payload.(type) {
+	case Event:
+		switch e.Type {
+		case EventCreated:
+			entry.Summary = fmt.Sprintf("%s created %s", displayName(payload.OrganizerID), payload.Title)
+		case EventFinalized:
+			entry.Summary = "Meeting confirmed"
+			if slot, err := store.GetTimeSlot(payload.FinalTimeSlotID); err == nil {
+				entry.Summary = "Meeting confirmed for " + slot.StartTime.UTC().Format("Mon Jan 2 2006, 15:04 MST")
+			}
+		case EventCancelled:
+			entry.Summary = "Event cancelled"
+		case EventRestored:
+			entry.Summary = "Event restored from the trash"
+		case EventImported:
+			entry.Summary = "Event imported from an export"
+		default:
+			entry.Summary = "Event edited"
+		}
+		activity.AddEventChange(payload, entry)
+		return
+	case TimeSlot:
+		when := payload.StartTime.UTC().Format("Mon Jan 2 2006, 15:04 MST")
+		entry.Summary = "Time slot added for " + when
+		if e.Type == TimeSlotUpdated {
+			entry.Summary = "Time slot moved to " + when
+		}
+	case UserAvailability:
+		entry.ActorID = payload.UserID
+		name := displayName(payload.UserID)
+		switch e.Type {
+		case AvailabilitySubmitted:
+			entry.Summary = fmt.Sprintf("%s responded %s", name, payload.Status)
+		case AvailabilityUpdated:
+			entry.Summary = fmt.Sprintf("%s changed their response to %s", name, payload.Status)
+		default:
+			entry.Summary = name + " withdrew a response"
+		}
+	default:
+		switch e.Type {
+		case EventDeleted:
+			activity.Forget(e.EventID)
+			return
+		case TimeSlotDeleted:
+			entry.Summary = "Time slot removed"
+		default:
+			return
+		}
+	}
+	activity.Add(e.EventID, entry)
+}
+
+// timelineNotifier records each message about an event on its timeline
+// once the channels have taken it
+type timelineNotifier struct {
+	next Notifier
+}
+
+func (t timelineNotifier) Notify(msg Message) error {
+	if err := t.next.Notify(msg); err != nil {
+		return err
+	}
+	if msg.EventID != "" {
+		activity.Add(msg.EventID, TimelineEntry{
+			At:          clock.Now(),
+			Type:        TimelineNotification,
+			Summary:     fmt.Sprintf("Sent %q to %s", msg.Subject, displayName(msg.To.ID)),
+			RecipientID: msg.To.ID,
+		})
+	}
+	return nil
+}
+
+// eventTimeline merges the event's recorded activity with its comments
+// and broadcasts, oldest first
+func eventTimeline(eventID string) []TimelineEntry {
+	timeline := activity.ForEvent(eventID)
+	for _, comment := range comments.ForEvent(eventID) {
+		entry := TimelineEntry{At: comment.CreatedAt, Type: TimelineComment, ActorID: comment.AuthorID,
+			Summary: displayName(comment.AuthorID) + " commented"}
+		if comment.Broadcast != nil {
+			entry.Type = TimelineBroadcast
+			entry.Summary = fmt.Sprintf("%s broadcast %q to %s",
+				displayName(comment.AuthorID), comment.Broadcast.Subject, plural(len(comment.Broadcast.Recipients), "participant"))
+		}
+		timeline = append(timeline, entry)
+	}
+	sort.SliceStable(timeline, func(i, j int) bool { return timeline[i].At.Before(timeline[j].At) })
+	return timeline
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func getEventTimeline(c *gin.Context) {
+	eventID := c.Param("eventId")
+	if _, err := contextScheduler(c).GetEvent(eventID); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, eventTimeline(eventID))
+}
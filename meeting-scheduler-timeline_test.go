@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventTimelineMergesActivityInOrder(t *testing.T) {
+	resetDirectory(t)
+	scheduler, fake := newTestScheduler(t)
+	recorder := &recordingNotifier{}
+	previousClock, previousNotifier, previousActivity, previousComments := clock, notifier, activity, comments
+	clock, notifier, activity, comments = fake, timelineNotifier{next: recorder}, newActivityRegistry(), newCommentRegistry()
+	t.Cleanup(func() {
+		clock, notifier, activity, comments = previousClock, previousNotifier, previousActivity, previousComments
+	})
+	scheduler.bus.SubscribeAll(recordActivity)
+	users.Save(User{ID: "ada", Name: "Ada"})
+	users.Save(User{ID: "bob", Name: "Bob"})
+
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30, Invitees: []string{"bob"}})
+	require.NoError(t, err)
+	fake.Advance(time.Minute)
+	start := time.Date(2025, 1, 13, 10, 0, 0, 0, time.UTC)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	fake.Advance(time.Minute)
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+	fake.Advance(time.Minute)
+	comments.Add(Comment{ID: "c1", EventID: event.ID, AuthorID: "bob", Body: "Works for me", CreatedAt: fake.Now()})
+	fake.Advance(time.Minute)
+	_, err = scheduler.FinalizeEvent(event.ID, FinalizeEventRequest{TimeSlotID: slot.ID})
+	require.NoError(t, err)
+	bob, _ := users.Get("bob")
+	require.NoError(t, notifier.Notify(Message{To: bob, EventID: event.ID, Subject: "Confirmed: Kickoff"}))
+
+	timeline := eventTimeline(event.ID)
+	var types, summaries []string
+	for _, entry := range timeline {
+		types = append(types, entry.Type)
+		summaries = append(summaries, entry.Summary)
+	}
+	assert.Equal(t, []string{string(EventCreated), string(TimeSlotCreated), string(AvailabilitySubmitted), TimelineComment,
+		string(EventFinalized), TimelineNotification}, types)
+	assert.Equal(t, "Ada created Kickoff", summaries[0])
+	assert.Equal(t, "Bob responded available", summaries[2])
+	assert.Equal(t, "Meeting confirmed for Mon Jan 13 2025, 10:00 UTC", summaries[4])
+	assert.Equal(t, `Sent "Confirmed: Kickoff" to Bob`, summaries[5])
+	assert.Equal(t, &StatusChange{To: "active"}, timeline[0].Status)
+	assert.Equal(t, &StatusChange{From: "active", To: "finalized"}, timeline[4].Status)
+	assert.Equal(t, "bob", timeline[5].RecipientID)
+
+	require.NoError(t, scheduler.DeleteEvent(event.ID))
+	assert.Empty(t, activity.ForEvent(event.ID))
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// offsetLayouts are the request time formats that carry their own offset,
+// RFC 3339 first
+var offsetLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04Z07:00",
+}
+
+// localLayouts are the formats without an offset, read in the request's
+// timeZone
+var localLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// parseRequestTime reads a request time in any accepted format. Times
+// without an offset need loc; field names the value in errors.
+func parseRequestTime(field, value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range offsetLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range localLayouts {
+		t, err := time.ParseInLocation(layout, value, time.UTC)
+		if err != nil {
+			continue
+		}
+		if loc == nil {
+			return time.Time{}, invalid(fmt.Sprintf("%s: %q has no UTC offset; add one, as in %q, or send a timeZone",
+				field, value, t.Format("2006-01-02T15:04:05")+"+01:00"))
+		}
+		return time.ParseInLocation(layout, value, loc)
+	}
+	return time.Time{}, invalid(fmt.Sprintf("%s: %q is not a date and time; use RFC 3339, as in \"2025-01-12T09:00:00Z\"", field, value))
+}
+
+// hasTimeFields reports whether decoding into t involves any time.Time
+func hasTimeFields(t reflect.Type, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return true
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return false
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() && hasTimeFields(t.Field(i).Type, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeTimes rewrites the times in a request body bound for obj's type
+// as RFC 3339, so the decoder accepts every format parseRequestTime does.
+// A timeZone key gives the zone for times without an offset, in its own
+// object and those nested in it; it is dropped where the type has no such
+// field. Bodies that aren't JSON are left for the decoder to reject.
+func normalizeTimes(body []byte, t reflect.Type) ([]byte, error) {
+	if !hasTimeFields(t, map[reflect.Type]bool{}) {
+		return body, nil
+	}
+	document, err := decodeDocument(body)
+	if err != nil {
+		return body, nil
+	}
+	normalized, err := normalizeValue(document, t, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(normalized)
+}
+
+func normalizeValue(value interface{}, t reflect.Type, path string, loc *time.Location) (interface{}, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if value == nil {
+		return nil, nil
+	}
+	field := strings.TrimPrefix(path, ".")
+	switch {
+	case t == timeType:
+		s, ok := value.(string)
+		if !ok {
+			return nil, invalid(fmt.Sprintf("%s must be a date and time string, as in \"2025-01-12T09:00:00Z\"", field))
+		}
+		parsed, err := parseRequestTime(field, s, loc)
+		if err != nil {
+			return nil, err
+		}
+		return parsed.Format(time.RFC3339Nano), nil
+	case t.Kind() == reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+		return normalizeObject(object, t, path, loc)
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return value, nil
+		}
+		for i, item := range items {
+			normalized, err := normalizeValue(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), loc)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = normalized
+		}
+	case t.Kind() == reflect.Map:
+		entries, ok := value.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+		for _, key := range objectKeys(entries) {
+			normalized, err := normalizeValue(entries[key], t.Elem(), path+"."+key, loc)
+			if err != nil {
+				return nil, err
+			}
+			entries[key] = normalized
+		}
+	}
+	return value, nil
+}
+
+// structFields maps the lowercased JSON names of t's fields, including
+// those promoted from embedded structs, to the fields
+func structFields(t reflect.Type, fields map[string]reflect.StructField) map[string]reflect.StructField {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			structFields(field.Type, fields)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[strings.ToLower(name)] = field
+	}
+	return fields
+}
+
+func normalizeObject(object map[string]interface{}, t reflect.Type, path string, loc *time.Location) (interface{}, error) {
+	fields := structFields(t, map[string]reflect.StructField{})
+
+	for key, value := range object {
+		if !strings.EqualFold(key, "timeZone") {
+			continue
+		}
+		if name, ok := value.(string); ok && name != "" {
+			zone, err := time.LoadLocation(name)
+			if err != nil {
+				return nil, invalid(fmt.Sprintf("%s: unknown time zone %q", strings.TrimPrefix(path+"."+key, "."), name))
+			}
+			loc = zone
+		}
+		if _, declared := fields["timezone"]; !declared {
+			delete(object, key)
+		}
+	}
+
+	keys := objectKeys(object)
+	sort.SliceStable(keys, func(i, j int) bool {
+		return fieldBefore(fields[strings.ToLower(keys[i])], fields[strings.ToLower(keys[j])])
+	})
+	for _, key := range keys {
+		field, ok := fields[strings.ToLower(key)]
+		if !ok {
+			continue
+		}
+		normalized, err := normalizeValue(object[key], field.Type, path+"."+key, loc)
+		if err != nil {
+			return nil, err
+		}
+		object[key] = normalized
+	}
+	return object, nil
+}
+
+// objectKeys returns an object's keys sorted, so the first invalid time
+// reported is the same on every request
+func objectKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fieldBefore orders struct fields as they are declared, with keys that
+// match no field last
+func fieldBefore(a, b reflect.StructField) bool {
+	if a.Index == nil || b.Index == nil {
+		return a.Index != nil
+	}
+	for i := 0; i < len(a.Index) && i < len(b.Index); i++ {
+		if a.Index[i] != b.Index[i] {
+			return a.Index[i] < b.Index[i]
+		}
+	}
+	return len(a.Index) < len(b.Index)
+}
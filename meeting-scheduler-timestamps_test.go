@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRequestTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	want := time.Date(2025, 1, 12, 8, 0, 0, 0, time.UTC)
+
+	for _, value := range []string{"2025-01-12T09:00:00+01:00", "2025-01-12T09:00+01:00", "2025-01-12 08:00:00Z", "2025-01-12 09:00+01:00"} {
+		parsed, err := parseRequestTime("startTime", value, nil)
+		require.NoError(t, err, value)
+		assert.True(t, want.Equal(parsed), value)
+	}
+	parsed, err := parseRequestTime("startTime", "2025-01-12T09:00", berlin)
+	require.NoError(t, err)
+	assert.True(t, want.Equal(parsed), "local times are read in the time zone")
+
+	_, err = parseRequestTime("startTime", "2025-01-12T09:00", nil)
+	assert.EqualError(t, err, `startTime: "2025-01-12T09:00" has no UTC offset; add one, as in "2025-01-12T09:00:00+01:00", or send a timeZone`)
+	_, err = parseRequestTime("startTime", "tomorrow", nil)
+	assert.EqualError(t, err, `startTime: "tomorrow" is not a date and time; use RFC 3339, as in "2025-01-12T09:00:00Z"`)
+}
+
+func TestTimeSlotRequestsAcceptLocalTimesWithAZone(t *testing.T) {
+	router := newTestRouter(t)
+	event, err := currentScheduler().CreateEvent(CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	post := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/events/"+event.ID+"/timeslots", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Prefer", "handling=strict")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(`{"startTime":"2025-01-13T09:00","endTime":"2025-01-13T10:00","timeZone":"America/New_York"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var slot TimeSlot
+	decodeJSON(t, w, &slot)
+	assert.True(t, time.Date(2025, 1, 13, 14, 0, 0, 0, time.UTC).Equal(slot.StartTime))
+
+	w = post(`{"startTime":"2025-01-13T09:00","endTime":"2025-01-13T10:00"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "startTime")
+	assert.Contains(t, w.Body.String(), "has no UTC offset")
+
+	w = post(`{"startTime":"2025-01-13T09:00","endTime":"2025-01-13T10:00","timeZone":"Mars/Olympus"}`)
+	assert.JSONEq(t, `{"error":"timeZone: unknown time zone \"Mars/Olympus\""}`, w.Body.String())
+
+	w = post(`{"startTime":1736758800,"endTime":"2025-01-13T10:00:00Z"}`)
+	assert.JSONEq(t, `{"error":"startTime must be a date and time string, as in \"2025-01-12T09:00:00Z\""}`, w.Body.String())
+}
+
+func TestRequestTimeErrorsFollowFieldOrder(t *testing.T) {
+	body := []byte(`{"endTime":"later","startTime":"soon"}`)
+	// Objects decode into maps, so a bug here would only show on some runs
+	for i := 0; i < 50; i++ {
+		_, err := normalizeTimes(body, reflect.TypeOf(CreateTimeSlotRequest{}))
+		assert.EqualError(t, err, `startTime: "soon" is not a date and time; use RFC 3339, as in "2025-01-12T09:00:00Z"`)
+	}
+}
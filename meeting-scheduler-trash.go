@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Trash item kinds
+const (
+	TrashEvent    = "event"
+	TrashTimeSlot = "timeslot"
+)
+
+var (
+	ErrTrashItemNotFound = errors.New("trash item not found")
+	// ErrRestoreConflict means the item can't go back: its event is gone
+	// or its ID has been reused
+	ErrRestoreConflict = errors.New("trash item cannot be restored")
+)
+
+// TrashItem is a deleted event or slot, kept with the records deleted
+// along with it until it expires. Its ID is the deleted entity's ID.
+type TrashItem struct {
+	ID           string             `json:"id"`
+	Kind         string             `json:"kind"`
+	OrgID        string             `json:"orgId,omitempty"`
+	EventID      string             `json:"eventId"`
+	Title        string             `json:"title"`
+	DeletedAt    time.Time          `json:"deletedAt"`
+	ExpiresAt    time.Time          `json:"expiresAt"`
+	Event        *Event             `json:"event,omitempty"`
+	TimeSlots    []TimeSlot         `json:"timeSlots"`
+	Availability []UserAvailability `json:"availability"`
+	Comments     []Comment          `json:"comments,omitempty"`
+}
+
+// trashRetention is how long deleted items stay restorable
+func trashRetention() time.Duration {
+	return time.Duration(getenvInt("TRASH_RETENTION_DAYS", 30)) * 24 * time.Hour
+}
+
+// trashRegistry is the in-memory trash
+type trashRegistry struct {
+	mu    sync.Mutex
+	items map[string]TrashItem
+}
+
+func newTrashRegistry() *trashRegistry {
+	return &trashRegistry{items: make(map[string]TrashItem)}
+}
+
+func (r *trashRegistry) Add(item TrashItem) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.items[item.ID] = item
+}
+
+func (r *trashRegistry) Get(id string) (TrashItem, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	item, ok := r.items[id]
+	return item, ok
+}
+
+func (r *trashRegistry) Remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.items, id)
+}
+
+// ForOrg lists an organization's trash, most recently deleted first
+func (r *trashRegistry) ForOrg(orgID string) []TrashItem {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	items := []TrashItem{}
+	for _, item := range r.items {
+		if item.OrgID == orgID {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+	return items
+}
+
+// Purge drops expired items
+func (r *trashRegistry) Purge(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, item := range r.items {
+		if !now.Before(item.ExpiresAt) {
+			delete(r.items, id)
+		}
+	}
+}
+
+var trash = newTrashRegistry()
+
+// purgeTrash is the background job emptying expired trash
+func purgeTrash(now time.Time) {
+	trash.Purge(now)
+}
+
+// Restore puts a trashed event or slot back with its responses. A slot
+// can only return to an event that still exists.
+func (s *Scheduler) Restore(id string) (TrashItem, error) {
+	item, ok := trash.Get(id)
+	if !ok || !s.clock.Now().Before(item.ExpiresAt) {
+		return TrashItem{}, ErrTrashItemNotFound
+	}
+
+	err := s.store.WithTransaction(func(tx Store) error {
+		if item.Kind == TrashEvent {
+			if _, err := tx.GetEvent(item.EventID); err == nil {
+				return ErrRestoreConflict
+			}
+			if err := tx.CreateEvent(*item.Event); err != nil {
+				return err
+			}
+		} else if _, err := tx.GetEvent(item.EventID); err != nil {
+			return ErrRestoreConflict
+		}
+		for _, slot := range item.TimeSlots {
+			if _, err := tx.GetTimeSlot(slot.ID); err == nil {
+				return ErrRestoreConflict
+			}
+			if err := tx.CreateTimeSlot(slot); err != nil {
+				return err
+			}
+		}
+		for _, avail := range item.Availability {
+			if _, err := tx.FindAvailability(avail.EventID, avail.UserID, avail.TimeSlotID); err == nil {
+				continue
+			}
+			if err := tx.CreateAvailability(avail); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return TrashItem{}, err
+	}
+
+	trash.Remove(id)
+	for _, comment := range item.Comments {
+		comments.Add(comment)
+	}
+	if item.Kind == TrashEvent {
+		s.publish(EventRestored, item.EventID, *item.Event)
+	} else {
+		for _, slot := range item.TimeSlots {
+			s.publish(TimeSlotCreated, item.EventID, slot)
+		}
+	}
+	return item, nil
+}
+
+// Trash handlers show organizers their organization's trash
+func listTrash(c *gin.Context) {
+	user, _ := currentUser(c)
+	trash.Purge(clock.Now())
+	c.JSON(http.StatusOK, trash.ForOrg(user.OrgID))
+}
+
+func restoreTrashItem(c *gin.Context) {
+	user, _ := currentUser(c)
+	if item, ok := trash.Get(c.Param("id")); !ok || item.OrgID != user.OrgID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trash item not found"})
+		return
+	}
+
+	item, err := contextScheduler(c).Restore(c.Param("id"))
+	switch {
+	case errors.Is(err, ErrTrashItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trash item not found"})
+	case errors.Is(err, ErrRestoreConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot restore: its event was deleted or the ID is in use"})
+	case err != nil:
+		respondError(c, err)
+	default:
+		c.JSON(http.StatusOK, item)
+	}
+}
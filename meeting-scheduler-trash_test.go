@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetTrash(t *testing.T) {
+	previous := trash
+	trash = newTrashRegistry()
+	t.Cleanup(func() { trash = previous })
+}
+
+func TestRestoreDeletedSlotAndEventWithResponses(t *testing.T) {
+	resetTrash(t)
+	scheduler, fake := newTestScheduler(t)
+	event, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	start := fake.Now().Add(24 * time.Hour)
+	slot, err := scheduler.CreateTimeSlot(event.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(event.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+
+	require.NoError(t, scheduler.DeleteTimeSlot(slot.ID))
+	item, err := scheduler.Restore(slot.ID)
+	require.NoError(t, err)
+	assert.Equal(t, TrashTimeSlot, item.Kind)
+	responses, err := store.ListAvailability(event.ID)
+	require.NoError(t, err)
+	require.Len(t, responses, 1, "the slot comes back with its responses")
+	_, err = scheduler.Restore(slot.ID)
+	assert.ErrorIs(t, err, ErrTrashItemNotFound)
+
+	require.NoError(t, scheduler.DeleteTimeSlot(slot.ID))
+	require.NoError(t, scheduler.DeleteEvent(event.ID))
+	_, err = scheduler.Restore(slot.ID)
+	assert.ErrorIs(t, err, ErrRestoreConflict, "a slot needs its event back first")
+	_, err = scheduler.Restore(event.ID)
+	require.NoError(t, err)
+	_, err = scheduler.Restore(slot.ID)
+	require.NoError(t, err)
+	restored, err := scheduler.GetEvent(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Kickoff", restored.Title)
+	responses, err = store.ListAvailability(event.ID)
+	require.NoError(t, err)
+	assert.Len(t, responses, 1)
+
+	require.NoError(t, scheduler.DeleteEvent(event.ID))
+	fake.Advance(trashRetention())
+	_, err = scheduler.Restore(event.ID)
+	assert.ErrorIs(t, err, ErrTrashItemNotFound, "expired items can't be restored")
+	purgeTrash(fake.Now())
+	_, ok := trash.Get(event.ID)
+	assert.False(t, ok)
+}
+
+func TestTrashIsScopedToTheOrganization(t *testing.T) {
+	resetDirectory(t)
+	resetTrash(t)
+	router := newTestRouter(t)
+	ada := User{ID: "ada", OrgID: "acme", Role: RoleOrganizer}
+	users.Save(ada)
+	event, err := currentScheduler().CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", RequiredDuration: 30})
+	require.NoError(t, err)
+	require.NoError(t, currentScheduler().DeleteEvent(event.ID))
+
+	send := func(user User, method, path string) *httptest.ResponseRecorder {
+		users.Save(user)
+		token, err := issueSessionToken(user)
+		require.NoError(t, err)
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	outsider := User{ID: "mallory", OrgID: "globex", Role: RoleOrganizer}
+
+	var listed []TrashItem
+	decodeJSON(t, send(outsider, http.MethodGet, "/api/v1/trash"), &listed)
+	assert.Empty(t, listed)
+	assert.Equal(t, http.StatusNotFound, send(outsider, http.MethodPost, "/api/v1/trash/"+event.ID+"/restore").Code)
+	decodeJSON(t, send(ada, http.MethodGet, "/api/v1/trash"), &listed)
+	require.Len(t, listed, 1)
+	assert.Equal(t, event.ID, listed[0].ID)
+	assert.Equal(t, http.StatusOK, send(ada, http.MethodPost, "/api/v1/trash/"+event.ID+"/restore").Code)
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TOTP parameters, the defaults authenticator apps assume (RFC 6238)
+const (
+	totpStep   = 30 * time.Second
+	totpDigits = 6
+	// totpSkew is how many steps either side of now a code is accepted,
+	// for phones whose clocks drift
+	totpSkew = 1
+)
+
+// TwoFactorPolicy decides whether an organization's organizers and admins
+// must use two-factor authentication
+type TwoFactorPolicy struct {
+	// Required makes organizers and admins enroll, and verify a code in
+	// the current session, before cancelling or handing over events
+	Required bool `json:"required"`
+}
+
+// TwoFactor is a user's TOTP enrollment
+type TwoFactor struct {
+	UserID string `json:"userId"`
+	// Secret is sealed at rest; it is only shown once, on enrollment
+	Secret string `json:"-"`
+	// Enabled is set once the user confirms a code from their app
+	Enabled   bool      `json:"enabled"`
+	EnabledAt time.Time `json:"enabledAt,omitempty"`
+	// lastCounter is the time step of the last accepted code, so a code
+	// can't be replayed within its window
+	lastCounter uint64
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorStore keeps TOTP enrollments where every replica sees them, so
+// they outlive restarts
+type TwoFactorStore interface {
+	// SaveTwoFactor creates or replaces the user's enrollment
+	SaveTwoFactor(enrollment TwoFactor) error
+	// GetTwoFactor returns ErrNotFound for users who haven't enrolled
+	GetTwoFactor(userID string) (TwoFactor, error)
+	// AcceptTwoFactorCode records that a code from the time step counter
+	// was used, confirming a pending enrollment at now. It returns
+	// ErrNotFound if a code from that step or a later one was already
+	// used, so each code works once across replicas.
+	AcceptTwoFactorCode(userID string, counter uint64, now time.Time) error
+	DeleteTwoFactor(userID string) error
+}
+
+// twoFactorStoreFor is the database underneath the store when it can keep
+// enrollments. Otherwise they are kept in memory and lost on restart.
+func twoFactorStoreFor(s Store) TwoFactorStore {
+	if twoFactorStore, ok := underlyingStore(s).(TwoFactorStore); ok {
+		return twoFactorStore
+	}
+	return newMemoryTwoFactors()
+}
+
+// twoFactorRegistry enrolls and verifies TOTP codes kept in a
+// TwoFactorStore
+type twoFactorRegistry struct {
+	store TwoFactorStore
+}
+
+// newTwoFactorRegistry returns a registry keeping enrollments in memory
+func newTwoFactorRegistry() *twoFactorRegistry {
+	return &twoFactorRegistry{store: newMemoryTwoFactors()}
+}
+
+// Enroll starts a pending enrollment with a new secret, replacing any
+// earlier pending one, and returns the secret
+func (r *twoFactorRegistry) Enroll(userID string) (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+	sealed, err := sealString(secret)
+	if err != nil {
+		return "", err
+	}
+	if err := r.store.SaveTwoFactor(TwoFactor{UserID: userID, Secret: sealed}); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// Enabled reports whether the user has confirmed an enrollment
+func (r *twoFactorRegistry) Enabled(userID string) (bool, error) {
+	entry, err := r.store.GetTwoFactor(userID)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return entry.Enabled, err
+}
+
+// Verify checks a code against the user's secret, accepting each time
+// step's code once. A correct code confirms a pending enrollment.
+func (r *twoFactorRegistry) Verify(userID, code string, now time.Time) (bool, error) {
+	entry, err := r.store.GetTwoFactor(userID)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	secret, err := openString(entry.Secret)
+	if err != nil {
+		log.Printf("Failed to decrypt two-factor secret of user %s: %v", userID, err)
+		return false, nil
+	}
+	counter, ok := matchTOTP(secret, code, now)
+	if !ok || counter <= entry.lastCounter {
+		return false, nil
+	}
+	err = r.store.AcceptTwoFactorCode(userID, counter, now)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *twoFactorRegistry) Delete(userID string) error {
+	return r.store.DeleteTwoFactor(userID)
+}
+
+var twoFactors = newTwoFactorRegistry()
+
+// memoryTwoFactors is the TwoFactorStore for single-instance deployments
+type memoryTwoFactors struct {
+	mu      sync.Mutex
+	entries map[string]TwoFactor
+}
+
+func newMemoryTwoFactors() *memoryTwoFactors {
+	return &memoryTwoFactors{entries: make(map[string]TwoFactor)}
+}
+
+func (m *memoryTwoFactors) SaveTwoFactor(enrollment TwoFactor) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[enrollment.UserID] = enrollment
+	return nil
+}
+
+func (m *memoryTwoFactors) GetTwoFactor(userID string) (TwoFactor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[userID]
+	if !ok {
+		return TwoFactor{}, ErrNotFound
+	}
+	return entry, nil
+}
+
+func (m *memoryTwoFactors) AcceptTwoFactorCode(userID string, counter uint64, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[userID]
+	if !ok || counter <= entry.lastCounter {
+		return ErrNotFound
+	}
+	entry.lastCounter = counter
+	if !entry.Enabled {
+		entry.Enabled, entry.EnabledAt = true, now
+	}
+	m.entries[userID] = entry
+	return nil
+}
+
+func (m *memoryTwoFactors) DeleteTwoFactor(userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, userID)
+	return nil
+}
+
+// totpCode is the code for one time step (RFC 4226 dynamic truncation)
+func totpCode(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// matchTOTP returns the time step a code belongs to, if it is one of the
+// steps around now
+func matchTOTP(secret, code string, now time.Time) (uint64, bool) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	current := uint64(now.Unix()) / uint64(totpStep/time.Second)
+	for skew := -totpSkew; skew <= totpSkew; skew++ {
+		counter := current + uint64(skew)
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, counter)), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// otpauthURL is what authenticator apps scan from a QR code
+func otpauthURL(user User, secret string) string {
+	account := user.Email
+	if account == "" {
+		account = user.ID
+	}
+	values := url.Values{}
+	values.Set("secret", secret)
+	values.Set("issuer", "MeetingScheduler")
+	return "otpauth://totp/" + url.PathEscape("MeetingScheduler:"+account) + "?" + values.Encode()
+}
+
+// twoFactorRequired reports whether the user's organization makes them
+// use two-factor authentication
+func twoFactorRequired(user User) bool {
+	if !roleAtLeast(user.Role, RoleOrganizer) || strings.HasPrefix(user.ID, serviceUserPrefix) {
+		return false
+	}
+	org, ok := organizations.Get(user.OrgID)
+	return ok && org.TwoFactor.Required
+}
+
+// eventRequiresTwoFactor reports whether the organization owning the
+// request's event requires two-factor authentication. Missing events are
+// left for the handler to answer.
+func eventRequiresTwoFactor(c *gin.Context) bool {
+	event, err := requestScheduler(c).GetEvent(c.Param("eventId"))
+	if err != nil {
+		return false
+	}
+	org, ok := organizations.Get(event.OrgID)
+	return ok && org.TwoFactor.Required
+}
+
+// checkTwoFactor lets a destructive operation on the request's event
+// through if the caller needs no second factor or has verified one in this
+// session. Otherwise it answers 403, saying whether to enroll or verify,
+// and returns false. Anonymous callers can't show a second factor, so
+// they get 401 wherever the event's organization requires one.
+func checkTwoFactor(c *gin.Context) bool {
+	user, ok := currentUser(c)
+	if !ok {
+		if eventRequiresTwoFactor(c) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return false
+		}
+		return true
+	}
+	if !twoFactorRequired(user) {
+		return true
+	}
+	enabled, err := twoFactors.Enabled(user.ID)
+	if err != nil {
+		c.Abort()
+		respondError(c, err)
+		return false
+	}
+	if !enabled {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication must be set up first", "twoFactor": "enroll"})
+		return false
+	}
+	sessionID, _ := c.Get(currentSessionKey)
+	if id, _ := sessionID.(string); !sessions.TwoFactorVerified(id) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Verify a two-factor code first", "twoFactor": "verify"})
+		return false
+	}
+	return true
+}
+
+// requireTwoFactor guards destructive routes with checkTwoFactor
+func requireTwoFactor(c *gin.Context) {
+	if checkTwoFactor(c) {
+		c.Next()
+	}
+}
+
+// enrollTwoFactor issues a new TOTP secret for the user to add to their
+// authenticator app. It isn't used until a code from it is confirmed.
+func enrollTwoFactor(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	enabled, err := twoFactors.Enabled(user.ID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already set up"})
+		return
+	}
+	secret, err := twoFactors.Enroll(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"secret": secret, "otpauthUrl": otpauthURL(user, secret)})
+}
+
+// verifyTwoFactor checks a code from the user's app, confirming a pending
+// enrollment, and marks the current session as having passed two-factor
+// authentication
+func verifyTwoFactor(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	var req TwoFactorCodeRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	verified, err := twoFactors.Verify(user.ID, req.Code, clock.Now())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if !verified {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+	if sessionID, ok := c.Get(currentSessionKey); ok {
+		if err := sessions.VerifyTwoFactor(sessionID.(string)); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": true})
+}
+
+// disableTwoFactor removes the user's enrollment, which takes a verified
+// session and isn't allowed where the organization requires it
+func disableTwoFactor(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	if twoFactorRequired(user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your organization requires two-factor authentication"})
+		return
+	}
+	enabled, err := twoFactors.Enabled(user.ID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	sessionID, _ := c.Get(currentSessionKey)
+	if id, _ := sessionID.(string); enabled && !sessions.TwoFactorVerified(id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Verify a two-factor code first", "twoFactor": "verify"})
+		return
+	}
+	if err := twoFactors.Delete(user.ID); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func updateTwoFactorPolicy(c *gin.Context) {
+	org, ok := organizations.Get(c.Param("orgId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+	var req TwoFactorPolicy
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org.TwoFactor = req
+	org.UpdatedAt = clock.Now()
+	organizations.Save(org)
+	c.JSON(http.StatusOK, req)
+}
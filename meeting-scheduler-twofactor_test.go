@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTPMatchesRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+	assert.Equal(t, "287082", totpCode(key, 59/30))
+	assert.Equal(t, "081804", totpCode(key, 1111111109/30))
+
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(key)
+	now := time.Unix(1111111109, 0)
+	_, ok := matchTOTP(secret, "081804", now.Add(totpStep))
+	assert.True(t, ok, "a step of clock drift is allowed")
+	_, ok = matchTOTP(secret, "081804", now.Add(3*totpStep))
+	assert.False(t, ok)
+}
+
+func TestOrganizersNeedTwoFactorToDeleteOrHandOverEvents(t *testing.T) {
+	resetDirectory(t)
+	router := newTestRouter(t)
+	fake := useFakeClock(t, time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	previousFactors, previousSessions := twoFactors, sessions
+	twoFactors, sessions = newTwoFactorRegistry(), newSessionRegistry()
+	t.Cleanup(func() { twoFactors, sessions = previousFactors, previousSessions })
+	organizations.Save(Organization{ID: "acme", TwoFactor: TwoFactorPolicy{Required: true}})
+	ada := User{ID: "ada", OrgID: "acme", Role: RoleOrganizer}
+	users.Save(ada)
+	users.Save(User{ID: "bob", OrgID: "acme", Role: RoleOrganizer})
+	token, err := issueSessionToken(ada)
+	require.NoError(t, err)
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	w := doJSON(router, http.MethodPost, "/api/v1/events", CreateEventRequest{Title: "Sync", OrganizerID: "ada", RequiredDuration: 30})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var event Event
+	decodeJSON(t, w, &event)
+	transfer := CreateEventRequest{Title: "Sync", OrganizerID: "bob", RequiredDuration: 30}
+
+	w = send(http.MethodDelete, "/api/v1/events/"+event.ID, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"twoFactor":"enroll"`)
+	assert.Equal(t, http.StatusUnauthorized, doJSON(router, http.MethodDelete, "/api/v1/events/"+event.ID, nil).Code, "leaving out the token doesn't get around it")
+	assert.Equal(t, http.StatusUnauthorized, doJSON(router, http.MethodPut, "/api/v1/events/"+event.ID, transfer).Code)
+
+	w = send(http.MethodPost, "/api/v1/users/me/two-factor", nil)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var enrollment struct {
+		Secret     string `json:"secret"`
+		OtpauthURL string `json:"otpauthUrl"`
+	}
+	decodeJSON(t, w, &enrollment)
+	assert.Contains(t, enrollment.OtpauthURL, "secret="+enrollment.Secret)
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+	require.NoError(t, err)
+	code := totpCode(key, uint64(fake.Now().Unix())/30)
+
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPut, "/api/v1/events/"+event.ID, transfer).Code, "enrollment isn't confirmed yet")
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/api/v1/auth/two-factor", TwoFactorCodeRequest{Code: "000000"}).Code)
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/api/v1/auth/two-factor", TwoFactorCodeRequest{Code: code}).Code)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/api/v1/auth/two-factor", TwoFactorCodeRequest{Code: code}).Code, "codes can't be replayed")
+	assert.Equal(t, http.StatusForbidden, send(http.MethodDelete, "/api/v1/users/me/two-factor", nil).Code, "the organization requires it")
+
+	assert.Equal(t, http.StatusOK, send(http.MethodPut, "/api/v1/events/"+event.ID, transfer).Code)
+	assert.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/api/v1/events/"+event.ID, nil).Code)
+
+	// A new session has to verify again
+	w = doJSON(router, http.MethodPost, "/api/v1/events", CreateEventRequest{Title: "Retro", OrganizerID: "ada", RequiredDuration: 30})
+	require.Equal(t, http.StatusCreated, w.Code)
+	decodeJSON(t, w, &event)
+	token, err = issueSessionToken(ada)
+	require.NoError(t, err)
+	w = send(http.MethodDelete, "/api/v1/events/"+event.ID, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"twoFactor":"verify"`)
+}
+
+func TestTwoFactorGuessesLockOutTheAccount(t *testing.T) {
+	t.Setenv("THROTTLE_MAX_FAILURES", "3")
+	resetDirectory(t)
+	router := newTestRouter(t)
+	fake := useFakeClock(t, time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	previousFactors, previousSessions := twoFactors, sessions
+	twoFactors, sessions = newTwoFactorRegistry(), newSessionRegistry()
+	t.Cleanup(func() { twoFactors, sessions = previousFactors, previousSessions })
+	ada := User{ID: "ada", OrgID: "acme", Role: RoleOrganizer}
+	bob := User{ID: "bob", OrgID: "acme", Role: RoleOrganizer}
+	users.Save(ada)
+	users.Save(bob)
+
+	// send posts as user from ip
+	send := func(user User, ip, path string, body interface{}) *httptest.ResponseRecorder {
+		token, err := issueSessionToken(user)
+		require.NoError(t, err)
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("X-Forwarded-For", ip)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	w := send(ada, "192.0.2.1", "/api/v1/users/me/two-factor", nil)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var enrollment struct {
+		Secret string `json:"secret"`
+	}
+	decodeJSON(t, w, &enrollment)
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+	require.NoError(t, err)
+
+	// Guesses spread over addresses still add up against the account
+	for _, ip := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+		require.Equal(t, http.StatusUnauthorized, send(ada, ip, "/api/v1/auth/two-factor", TwoFactorCodeRequest{Code: "000000"}).Code)
+	}
+	code := totpCode(key, uint64(fake.Now().Unix())/30)
+	assert.Equal(t, http.StatusTooManyRequests, send(ada, "203.0.113.4", "/api/v1/auth/two-factor", TwoFactorCodeRequest{Code: code}).Code)
+	assert.Equal(t, http.StatusUnauthorized, send(bob, "203.0.113.4", "/api/v1/auth/two-factor", TwoFactorCodeRequest{Code: "000000"}).Code,
+		"other accounts aren't locked out")
+
+	fake.Advance(15 * time.Minute)
+	code = totpCode(key, uint64(fake.Now().Unix())/30)
+	assert.Equal(t, http.StatusOK, send(ada, "203.0.113.4", "/api/v1/auth/two-factor", TwoFactorCodeRequest{Code: code}).Code)
+}
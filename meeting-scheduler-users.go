@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Roles, in increasing order of privilege. Guests are outsiders who booked
+// or were invited to a meeting and have no API access.
+const (
+	RoleGuest     = "guest"
+	RoleMember    = "member"
+	RoleOrganizer = "organizer"
+	RoleAdmin     = "admin"
+)
+
+var roleRank = map[string]int{
+	RoleGuest:     0,
+	RoleMember:    1,
+	RoleOrganizer: 2,
+	RoleAdmin:     3,
+}
+
+// roleAtLeast reports whether role grants at least the privileges of minimum
+func roleAtLeast(role, minimum string) bool {
+	return roleRank[role] >= roleRank[minimum]
+}
+
+type User struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	// Phone is an E.164 number for SMS, used only once PhoneVerified
+	Phone         string `json:"phone,omitempty"`
+	PhoneVerified bool   `json:"phoneVerified,omitempty"`
+	OrgID         string `json:"orgId,omitempty"`
+	Role          string `json:"role"`
+	// ExternalID is the identity provider's subject for SSO-provisioned users
+	ExternalID string `json:"externalId,omitempty"`
+	// Deactivated users have been deprovisioned and can no longer sign in
+	Deactivated bool         `json:"deactivated,omitempty"`
+	Settings    UserSettings `json:"settings"`
+	// PinnedEvents are the event IDs on the user's dashboard, in pin order
+	PinnedEvents []string `json:"pinnedEvents,omitempty"`
+	// Consents are the privacy policy versions the user has agreed to
+	Consents  []Consent `json:"consents,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// UserSettings are scheduling preferences users manage themselves
+type UserSettings struct {
+	// MinNoticeMinutes is how far ahead meetings must be proposed to them
+	MinNoticeMinutes int `json:"minNoticeMinutes"`
+	// MaxMeetingsPerDay caps confirmed meetings per day; 0 means no cap
+	MaxMeetingsPerDay int `json:"maxMeetingsPerDay"`
+	// Shift is a rotating shift pattern; slots inside a shift count as
+	// available without a response
+	Shift *ShiftPattern `json:"shift,omitempty"`
+	// SMSOptIn allows texts for confirmations, reminders and broadcasts
+	SMSOptIn bool `json:"smsOptIn,omitempty"`
+	// ResponseUpdates is how organizers hear about responses to their
+	// polls: not at all, immediately, or in hourly or daily digests
+	ResponseUpdates string `json:"responseUpdates,omitempty"`
+	// QuietHours hold back non-urgent notifications until they end
+	QuietHours *QuietHours `json:"quietHours,omitempty"`
+	// EventDefaults override the organization's settings for events the
+	// user organizes
+	EventDefaults *EventSettings `json:"eventDefaults,omitempty"`
+}
+
+// userDirectory is the in-memory user registry (would use a database in
+// production). Users are indexed by ID, by their SSO identity and by a
+// blind index of their email, which is only stored encrypted, as is their
+// phone number.
+type userDirectory struct {
+	mu       sync.RWMutex
+	users    map[string]User
+	external map[string]string // orgID + "/" + externalID -> user ID
+	emails   map[string]string // emailKey -> user ID
+}
+
+func newUserDirectory() *userDirectory {
+	return &userDirectory{
+		users:    make(map[string]User),
+		external: make(map[string]string),
+		emails:   make(map[string]string),
+	}
+}
+
+func externalKey(orgID, externalID string) string {
+	return orgID + "/" + externalID
+}
+
+func emailKey(orgID, email string) string {
+	return orgID + "/" + blindIndex(strings.ToLower(email))
+}
+
+// reveal decrypts a stored user's email and phone
+func (d *userDirectory) reveal(user User) User {
+	email, err := openString(user.Email)
+	if err != nil {
+		log.Printf("Failed to decrypt email of user %s: %v", user.ID, err)
+		email = ""
+	}
+	user.Email = email
+	phone, err := openString(user.Phone)
+	if err != nil {
+		log.Printf("Failed to decrypt phone of user %s: %v", user.ID, err)
+		phone = ""
+	}
+	user.Phone = phone
+	return user
+}
+
+func (d *userDirectory) Get(id string) (User, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	user, ok := d.users[id]
+	if !ok {
+		return User{}, false
+	}
+	return d.reveal(user), true
+}
+
+func (d *userDirectory) FindExternal(orgID, externalID string) (User, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	id, ok := d.external[externalKey(orgID, externalID)]
+	if !ok {
+		return User{}, false
+	}
+	user, ok := d.users[id]
+	return d.reveal(user), ok
+}
+
+func (d *userDirectory) FindByEmail(orgID, email string) (User, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	id, ok := d.emails[emailKey(orgID, email)]
+	if !ok {
+		return User{}, false
+	}
+	user, ok := d.users[id]
+	return d.reveal(user), ok
+}
+
+// Save inserts or replaces a user, encrypting their email and phone
+func (d *userDirectory) Save(user User) {
+	sealed, err := sealString(user.Email)
+	if err != nil {
+		// Never fall back to storing the address in the clear
+		log.Printf("Failed to encrypt email of user %s: %v", user.ID, err)
+		sealed = ""
+	}
+	sealedPhone, err := sealString(user.Phone)
+	if err != nil {
+		log.Printf("Failed to encrypt phone of user %s: %v", user.ID, err)
+		sealedPhone, user.PhoneVerified = "", false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if previous, ok := d.users[user.ID]; ok {
+		d.unindex(previous)
+	}
+	if user.Email != "" {
+		d.emails[emailKey(user.OrgID, user.Email)] = user.ID
+	}
+	user.Email = sealed
+	user.Phone = sealedPhone
+	d.users[user.ID] = user
+	if user.ExternalID != "" {
+		d.external[externalKey(user.OrgID, user.ExternalID)] = user.ID
+	}
+}
+
+// unindex drops a stored user's secondary index entries
+func (d *userDirectory) unindex(user User) {
+	if user.ExternalID != "" {
+		delete(d.external, externalKey(user.OrgID, user.ExternalID))
+	}
+	for key, id := range d.emails {
+		if id == user.ID {
+			delete(d.emails, key)
+		}
+	}
+}
+
+// Delete removes a user from the directory
+func (d *userDirectory) Delete(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	user, ok := d.users[id]
+	if !ok {
+		return false
+	}
+	d.unindex(user)
+	delete(d.users, id)
+	return true
+}
+
+// List returns the users belonging to an organization
+func (d *userDirectory) List(orgID string) []User {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var userList []User
+	for _, user := range d.users {
+		if user.OrgID == orgID {
+			userList = append(userList, d.reveal(user))
+		}
+	}
+	return userList
+}
+
+// rewrap moves every stored email and phone under the active encryption
+// key and returns how many users changed
+func (d *userDirectory) rewrap() (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	changed := 0
+	for id, user := range d.users {
+		sealed, emailChanged, err := rewrapString(user.Email)
+		if err != nil {
+			return changed, fmt.Errorf("user %s: %w", id, err)
+		}
+		sealedPhone, phoneChanged, err := rewrapString(user.Phone)
+		if err != nil {
+			return changed, fmt.Errorf("user %s: %w", id, err)
+		}
+		if emailChanged || phoneChanged {
+			user.Email, user.Phone = sealed, sealedPhone
+			d.users[id] = user
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+var users = newUserDirectory()
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	webhookSignatureHeader = "X-Scheduler-Signature"
+	// defaultSecretGrace is how long the previous secret keeps signing
+	// deliveries after a rotation
+	defaultSecretGrace = 24 * time.Hour
+	maxSecretGrace     = 7 * 24 * time.Hour
+)
+
+// Webhook is an organization's subscription to domain events. Deliveries
+// are POSTed as the JSON DomainEvent and signed with the webhook's secret.
+type Webhook struct {
+	ID    string `json:"id"`
+	OrgID string `json:"orgId"`
+	URL   string `json:"url"`
+	// Events filters deliveries by type; empty means every type
+	Events       []DomainEventType `json:"events,omitempty"`
+	SealedSecret []byte            `json:"-"`
+	// PreviousSealedSecret also signs deliveries until PreviousSecretExpiresAt,
+	// so consumers can switch secrets without rejecting events
+	PreviousSealedSecret    []byte     `json:"-"`
+	PreviousSecretExpiresAt *time.Time `json:"previousSecretExpiresAt,omitempty"`
+	CreatedAt               time.Time  `json:"createdAt"`
+	UpdatedAt               time.Time  `json:"updatedAt"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string            `json:"url" binding:"required"`
+	Events []DomainEventType `json:"events"`
+}
+
+// RotateWebhookSecretRequest sets how long the old secret stays valid
+type RotateWebhookSecretRequest struct {
+	GraceSeconds *int `json:"graceSeconds"`
+}
+
+// WebhookSecretResponse is the only place a secret is ever returned
+type WebhookSecretResponse struct {
+	Webhook Webhook `json:"webhook"`
+	Secret  string  `json:"secret"`
+}
+
+// wants reports whether the webhook subscribes to the event
+func (w Webhook) wants(event DomainEvent) bool {
+	if event.OrgID != w.OrgID {
+		return false
+	}
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, eventType := range w.Events {
+		if eventType == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// signingSecrets returns the secrets deliveries are signed with at now:
+// the current one, plus the previous one during a rotation's grace window
+func (w Webhook) signingSecrets(now time.Time) ([]string, error) {
+	current, err := openSecret(w.SealedSecret)
+	if err != nil {
+		return nil, err
+	}
+	secretList := []string{current}
+	if len(w.PreviousSealedSecret) > 0 && w.PreviousSecretExpiresAt != nil && now.Before(*w.PreviousSecretExpiresAt) {
+		previous, err := openSecret(w.PreviousSealedSecret)
+		if err != nil {
+			return nil, err
+		}
+		secretList = append(secretList, previous)
+	}
+	return secretList, nil
+}
+
+// signWebhook builds the signature header value: the delivery timestamp and
+// one hex HMAC-SHA256 of "<timestamp>.<body>" per secret, e.g.
+// "t=1736672400,v1=5257a8...,v1=9f0c1d...". Receivers accept the delivery
+// if any v1 matches and the timestamp is recent.
+func signWebhook(secretList []string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	parts := []string{"t=" + ts}
+	for _, secret := range secretList {
+		parts = append(parts, "v1="+webhookMAC(secret, ts, body))
+	}
+	return strings.Join(parts, ",")
+}
+
+func webhookMAC(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyWebhookSignature checks a signWebhook header against secret,
+// rejecting timestamps further than tolerance from now
+func verifyWebhookSignature(header, secret string, body []byte, now time.Time, tolerance time.Duration) bool {
+	var ts string
+	var candidates []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			candidates = append(candidates, value)
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || now.Sub(time.Unix(unix, 0)).Abs() > tolerance {
+		return false
+	}
+
+	expected := webhookMAC(secret, ts, body)
+	for _, candidate := range candidates {
+		if hmac.Equal([]byte(candidate), []byte(expected)) {
+			return true
+		}
+	}
+	return false
+}
+
+// newWebhookSecret returns a random secret with a recognizable prefix
+func newWebhookSecret() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(raw), nil
+}
+
+// webhookRegistry is the in-memory webhook store
+type webhookRegistry struct {
+	mu       sync.RWMutex
+	webhooks map[string]Webhook
+}
+
+func newWebhookRegistry() *webhookRegistry {
+	return &webhookRegistry{webhooks: make(map[string]Webhook)}
+}
+
+// Get returns the webhook if it belongs to the organization
+func (r *webhookRegistry) Get(orgID, id string) (Webhook, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	webhook, ok := r.webhooks[id]
+	if !ok || webhook.OrgID != orgID {
+		return Webhook{}, false
+	}
+	return webhook, true
+}
+
+func (r *webhookRegistry) Save(webhook Webhook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.webhooks[webhook.ID] = webhook
+}
+
+func (r *webhookRegistry) Delete(orgID, id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	webhook, ok := r.webhooks[id]
+	if !ok || webhook.OrgID != orgID {
+		return false
+	}
+	delete(r.webhooks, id)
+	return true
+}
+
+// List returns the organization's webhooks in creation order
+func (r *webhookRegistry) List(orgID string) []Webhook {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	webhookList := []Webhook{}
+	for _, webhook := range r.webhooks {
+		if webhook.OrgID == orgID {
+			webhookList = append(webhookList, webhook)
+		}
+	}
+	sort.Slice(webhookList, func(i, j int) bool {
+		return webhookList[i].CreatedAt.Before(webhookList[j].CreatedAt)
+	})
+	return webhookList
+}
+
+// Subscribed returns every webhook that wants the event
+func (r *webhookRegistry) Subscribed(event DomainEvent) []Webhook {
+	var subscribed []Webhook
+	for _, webhook := range r.List(event.OrgID) {
+		if webhook.wants(event) {
+			subscribed = append(subscribed, webhook)
+		}
+	}
+	return subscribed
+}
+
+// rewrap moves stored secrets under the active encryption key
+func (r *webhookRegistry) rewrap() (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := 0
+	for id, webhook := range r.webhooks {
+		sealed, ok, err := rewrapSecret(webhook.SealedSecret)
+		if err != nil {
+			return changed, fmt.Errorf("webhook %s: %w", id, err)
+		}
+		if ok {
+			webhook.SealedSecret = sealed
+			changed++
+		}
+		if len(webhook.PreviousSealedSecret) > 0 {
+			previous, ok, err := rewrapSecret(webhook.PreviousSealedSecret)
+			if err != nil {
+				return changed, fmt.Errorf("webhook %s: %w", id, err)
+			}
+			if ok {
+				webhook.PreviousSealedSecret = previous
+			}
+		}
+		r.webhooks[id] = webhook
+	}
+	return changed, nil
+}
+
+var webhooks = newWebhookRegistry()
+
+// webhookDispatcher delivers bus events to subscribed webhooks from a
+// background goroutine, like brokerForwarder, so slow receivers never delay
+// API requests. Events are dropped (and logged) when the buffer is full.
+type webhookDispatcher struct {
+	registry   *webhookRegistry
+	deliveries *deliveryLog
+	queue      chan DomainEvent
+	done       chan struct{}
+}
+
+func newWebhookDispatcher(registry *webhookRegistry, deliveries *deliveryLog, buffer int) *webhookDispatcher {
+	d := &webhookDispatcher{
+		registry:   registry,
+		deliveries: deliveries,
+		queue:      make(chan DomainEvent, buffer),
+		done:       make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// Enqueue is a DomainEventHandler
+func (d *webhookDispatcher) Enqueue(event DomainEvent) {
+	if event.OrgID == "" {
+		return
+	}
+	select {
+	case d.queue <- event:
+	default:
+		log.Printf("Webhook queue full, dropping %s for event %s", event.Type, event.EventID)
+	}
+}
+
+func (d *webhookDispatcher) run() {
+	defer close(d.done)
+	for event := range d.queue {
+		for _, webhook := range d.registry.Subscribed(event) {
+			delivery, err := newWebhookDelivery(webhook, event)
+			if err != nil {
+				log.Printf("Failed to encode %s for webhook %s: %v", event.Type, webhook.ID, err)
+				continue
+			}
+			attempt := attemptDelivery(webhook, &delivery, false)
+			d.deliveries.Save(delivery)
+			if attempt.Error != "" {
+				log.Printf("Webhook %s delivery of %s failed: %s", webhook.ID, event.Type, attempt.Error)
+			}
+		}
+	}
+}
+
+// Close flushes queued deliveries
+func (d *webhookDispatcher) Close() {
+	close(d.queue)
+	<-d.done
+}
+
+func validWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+// Webhook handlers are scoped to the calling admin's organization
+func listWebhooks(c *gin.Context) {
+	user, _ := currentUser(c)
+	c.JSON(http.StatusOK, webhooks.List(user.OrgID))
+}
+
+func getWebhook(c *gin.Context) {
+	user, _ := currentUser(c)
+	webhook, ok := webhooks.Get(user.OrgID, c.Param("webhookId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	c.JSON(http.StatusOK, webhook)
+}
+
+func createWebhook(c *gin.Context) {
+	user, _ := currentUser(c)
+	var req CreateWebhookRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validWebhookURL(req.URL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "URL must be an absolute http(s) URL"})
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	sealed, err := sealSecret(secret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store secret"})
+		return
+	}
+
+	now := clock.Now()
+	webhook := Webhook{
+		ID:           uuid.New().String(),
+		OrgID:        user.OrgID,
+		URL:          req.URL,
+		Events:       req.Events,
+		SealedSecret: sealed,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	webhooks.Save(webhook)
+	c.JSON(http.StatusCreated, WebhookSecretResponse{Webhook: webhook, Secret: secret})
+}
+
+func deleteWebhook(c *gin.Context) {
+	user, _ := currentUser(c)
+	if !webhooks.Delete(user.OrgID, c.Param("webhookId")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	webhookDeliveries.Forget(c.Param("webhookId"))
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// rotateWebhookSecret issues a new secret. The old one keeps signing
+// deliveries alongside it for the grace period, so consumers can deploy the
+// new secret without rejecting events in between.
+func rotateWebhookSecret(c *gin.Context) {
+	user, _ := currentUser(c)
+	webhook, ok := webhooks.Get(user.OrgID, c.Param("webhookId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	var req RotateWebhookSecretRequest
+	if c.Request.ContentLength != 0 {
+		if err := bindJSON(c, &req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	grace := defaultSecretGrace
+	if req.GraceSeconds != nil {
+		grace = time.Duration(*req.GraceSeconds) * time.Second
+	}
+	if grace < 0 || grace > maxSecretGrace {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Grace period must be between 0 and 7 days"})
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	sealed, err := sealSecret(secret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store secret"})
+		return
+	}
+
+	now := clock.Now()
+	webhook.PreviousSealedSecret = nil
+	webhook.PreviousSecretExpiresAt = nil
+	if grace > 0 {
+		expires := now.Add(grace)
+		webhook.PreviousSealedSecret = webhook.SealedSecret
+		webhook.PreviousSecretExpiresAt = &expires
+	}
+	webhook.SealedSecret = sealed
+	webhook.UpdatedAt = now
+	webhooks.Save(webhook)
+	c.JSON(http.StatusOK, WebhookSecretResponse{Webhook: webhook, Secret: secret})
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetWebhooks(t *testing.T) {
+	previous, previousDeliveries := webhooks, webhookDeliveries
+	webhooks, webhookDeliveries = newWebhookRegistry(), newDeliveryLog()
+	t.Cleanup(func() { webhooks, webhookDeliveries = previous, previousDeliveries })
+}
+
+type receivedWebhook struct {
+	body      []byte
+	signature string
+}
+
+// webhookReceiver records deliveries on a channel
+func webhookReceiver(t *testing.T) (*httptest.Server, chan receivedWebhook) {
+	received := make(chan receivedWebhook, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedWebhook{body: body, signature: r.Header.Get(webhookSignatureHeader)}
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+// verifies checks the signature header the way a receiver would
+func verifies(signature, secret string, body []byte) bool {
+	var timestamp string
+	var candidates []string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			candidates = append(candidates, value)
+		}
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + string(body)))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	for _, candidate := range candidates {
+		if hmac.Equal([]byte(candidate), []byte(expected)) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWebhookSecretRotation(t *testing.T) {
+	resetDirectory(t)
+	resetWebhooks(t)
+	fake := useFakeClock(t, time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC))
+	router := newTestRouter(t)
+	organizations.Save(Organization{ID: "acme", Name: "Acme"})
+	admin := User{ID: "ada", OrgID: "acme", Role: RoleAdmin}
+	users.Save(admin)
+	token, err := issueSessionToken(admin)
+	require.NoError(t, err)
+	server, received := webhookReceiver(t)
+
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := call("POST", "/api/v1/webhooks", `{"url":"ftp://nope"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = call("POST", "/api/v1/webhooks", `{"url":"`+server.URL+`","events":["event.finalized"]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created WebhookSecretResponse
+	decodeJSON(t, w, &created)
+	assert.True(t, strings.HasPrefix(created.Secret, "whsec_"))
+	assert.NotContains(t, call("GET", "/api/v1/webhooks/"+created.Webhook.ID, "").Body.String(), created.Secret)
+
+	w = call("POST", "/api/v1/webhooks/"+created.Webhook.ID+"/secret/rotate", `{"graceSeconds":3600}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var rotated WebhookSecretResponse
+	decodeJSON(t, w, &rotated)
+	assert.NotEqual(t, created.Secret, rotated.Secret)
+
+	dispatcher := newWebhookDispatcher(webhooks, webhookDeliveries, 10)
+	finalized := DomainEvent{Type: EventFinalized, EventID: "evt1", OrgID: "acme", OccurredAt: fake.Now()}
+	dispatcher.Enqueue(DomainEvent{Type: EventCreated, EventID: "evt1", OrgID: "acme"})
+	dispatcher.Enqueue(DomainEvent{Type: EventFinalized, EventID: "evt2", OrgID: "other"})
+	dispatcher.Enqueue(finalized)
+
+	// Within the grace window both secrets verify
+	delivery := <-received
+	var payload DomainEvent
+	require.NoError(t, json.Unmarshal(delivery.body, &payload))
+	assert.Equal(t, "evt1", payload.EventID)
+	assert.True(t, verifies(delivery.signature, rotated.Secret, delivery.body))
+	assert.True(t, verifies(delivery.signature, created.Secret, delivery.body))
+	assert.False(t, verifies(delivery.signature, "whsec_wrong", delivery.body))
+
+	fake.Advance(2 * time.Hour)
+	dispatcher.Enqueue(finalized)
+	dispatcher.Close()
+	delivery = <-received
+	assert.True(t, verifies(delivery.signature, rotated.Secret, delivery.body))
+	assert.False(t, verifies(delivery.signature, created.Secret, delivery.body))
+	assert.Empty(t, received)
+}
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"type":"event.created"}`)
+	signature := signWebhook([]string{"a", "b"}, time.Unix(1736672400, 0), body)
+	parts := strings.Split(signature, ",")
+	require.Len(t, parts, 3)
+	assert.Equal(t, "t=1736672400", parts[0])
+	assert.NotEqual(t, parts[1], parts[2])
+	assert.Equal(t, signature, signWebhook([]string{"a", "b"}, time.Unix(1736672400, 0), bytes.Clone(body)))
+}
+
+func TestWebhookDeliveryLogAndReplay(t *testing.T) {
+	resetDirectory(t)
+	resetWebhooks(t)
+	router := newTestRouter(t)
+	admin := User{ID: "ada", OrgID: "acme", Role: RoleAdmin}
+	users.Save(admin)
+	token, err := issueSessionToken(admin)
+	require.NoError(t, err)
+
+	down := true
+	var deliveryIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deliveryIDs = append(deliveryIDs, r.Header.Get("X-Scheduler-Delivery"))
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	sealed, err := sealSecret("whsec_test")
+	require.NoError(t, err)
+	webhooks.Save(Webhook{ID: "wh1", OrgID: "acme", URL: server.URL, SealedSecret: sealed})
+
+	dispatcher := newWebhookDispatcher(webhooks, webhookDeliveries, 10)
+	dispatcher.Enqueue(DomainEvent{Type: EventCreated, EventID: "evt1", OrgID: "acme"})
+	dispatcher.Close()
+
+	call := func(method, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := call("GET", "/api/v1/webhooks/wh1/deliveries")
+	require.Equal(t, http.StatusOK, w.Code)
+	var deliveries []WebhookDelivery
+	decodeJSON(t, w, &deliveries)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, DeliveryFailed, deliveries[0].Status)
+	assert.Equal(t, http.StatusServiceUnavailable, deliveries[0].Attempts[0].StatusCode)
+
+	down = false
+	w = call("POST", "/api/v1/webhooks/wh1/deliveries/"+deliveries[0].ID+"/replay")
+	require.Equal(t, http.StatusOK, w.Code)
+	var replayed WebhookDelivery
+	decodeJSON(t, w, &replayed)
+	assert.Equal(t, DeliverySucceeded, replayed.Status)
+	require.Len(t, replayed.Attempts, 2)
+	assert.True(t, replayed.Attempts[1].Replay)
+	assert.Equal(t, http.StatusOK, replayed.Attempts[1].StatusCode)
+	assert.JSONEq(t, string(deliveries[0].Payload), string(replayed.Payload))
+	assert.Equal(t, []string{deliveries[0].ID, deliveries[0].ID}, deliveryIDs)
+
+	assert.Equal(t, http.StatusNotFound, call("POST", "/api/v1/webhooks/wh1/deliveries/nope/replay").Code)
+	webhooks.Save(Webhook{ID: "wh2", OrgID: "other", URL: server.URL, SealedSecret: sealed})
+	assert.Equal(t, http.StatusNotFound, call("GET", "/api/v1/webhooks/wh2/deliveries").Code)
+}
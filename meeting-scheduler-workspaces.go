@@ -0,0 +1,375 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Workspace groups an organization's related events, such as a project's
+// meetings, so they share participants and defaults and can be reviewed
+// together
+type Workspace struct {
+	ID          string `json:"id"`
+	OrgID       string `json:"orgId"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Participants are invited to every event created in the workspace
+	Participants []string          `json:"participants,omitempty"`
+	Defaults     WorkspaceDefaults `json:"defaults"`
+	CreatedAt    time.Time         `json:"createdAt"`
+	UpdatedAt    time.Time         `json:"updatedAt"`
+}
+
+// WorkspaceDefaults fill in what a workspace's event requests leave unset
+type WorkspaceDefaults struct {
+	DurationMinutes  int    `json:"durationMinutes,omitempty"`
+	MeetingTypeID    string `json:"meetingTypeId,omitempty"`
+	MinNoticeMinutes int    `json:"minNoticeMinutes,omitempty"`
+	BufferMinutes    int    `json:"bufferMinutes,omitempty"`
+	LocationMode     string `json:"locationMode,omitempty"`
+	Location         string `json:"location,omitempty"`
+}
+
+type WorkspaceRequest struct {
+	Name         string            `json:"name" binding:"required"`
+	Description  string            `json:"description"`
+	Participants []string          `json:"participants"`
+	Defaults     WorkspaceDefaults `json:"defaults"`
+}
+
+func (req WorkspaceRequest) validate(orgID string) error {
+	d := req.Defaults
+	if d.DurationMinutes < 0 {
+		return invalid("Default duration cannot be negative")
+	}
+	if d.MinNoticeMinutes < 0 {
+		return invalid("Default minimum notice cannot be negative")
+	}
+	if err := validateBuffer(d.BufferMinutes); err != nil {
+		return err
+	}
+	if !validLocationMode(d.LocationMode) {
+		return invalid("Location mode must be video, phone or in_person")
+	}
+	if d.MeetingTypeID != "" {
+		if _, ok := meetingTypes.Get(orgID, d.MeetingTypeID); !ok {
+			return invalid(fmt.Sprintf("Unknown meeting type %q", d.MeetingTypeID))
+		}
+	}
+	return nil
+}
+
+func (w *Workspace) apply(req WorkspaceRequest, now time.Time) {
+	w.Name = req.Name
+	w.Description = req.Description
+	w.Participants = req.Participants
+	w.Defaults = req.Defaults
+	w.UpdatedAt = now
+}
+
+// workspaceRegistry is the in-memory workspace store
+type workspaceRegistry struct {
+	mu         sync.RWMutex
+	workspaces map[string]Workspace
+}
+
+func newWorkspaceRegistry() *workspaceRegistry {
+	return &workspaceRegistry{workspaces: make(map[string]Workspace)}
+}
+
+// Get returns the workspace if it belongs to the organization
+func (r *workspaceRegistry) Get(orgID, id string) (Workspace, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	workspace, ok := r.workspaces[id]
+	if !ok || workspace.OrgID != orgID {
+		return Workspace{}, false
+	}
+	return workspace, true
+}
+
+func (r *workspaceRegistry) Save(workspace Workspace) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.workspaces[workspace.ID] = workspace
+}
+
+func (r *workspaceRegistry) Delete(orgID, id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	workspace, ok := r.workspaces[id]
+	if !ok || workspace.OrgID != orgID {
+		return false
+	}
+	delete(r.workspaces, id)
+	return true
+}
+
+// List returns the organization's workspaces by name
+func (r *workspaceRegistry) List(orgID string) []Workspace {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	workspaceList := []Workspace{}
+	for _, workspace := range r.workspaces {
+		if workspace.OrgID == orgID {
+			workspaceList = append(workspaceList, workspace)
+		}
+	}
+	sort.Slice(workspaceList, func(i, j int) bool { return workspaceList[i].Name < workspaceList[j].Name })
+	return workspaceList
+}
+
+var workspaces = newWorkspaceRegistry()
+
+// withWorkspace fills the fields an event request leaves empty from its
+// workspace, which must belong to the organizer's organization, and
+// invites the workspace's participants
+func withWorkspace(req CreateEventRequest) (CreateEventRequest, error) {
+	if req.WorkspaceID == "" {
+		return req, nil
+	}
+	organizer, _ := users.Get(req.OrganizerID)
+	workspace, ok := workspaces.Get(organizer.OrgID, req.WorkspaceID)
+	if !ok {
+		return req, invalid(fmt.Sprintf("Unknown workspace %q", req.WorkspaceID))
+	}
+	d := workspace.Defaults
+	if req.MeetingTypeID == "" {
+		req.MeetingTypeID = d.MeetingTypeID
+	}
+	if req.RequiredDuration == 0 {
+		req.RequiredDuration = d.DurationMinutes
+	}
+	if req.MinNoticeMinutes == 0 {
+		req.MinNoticeMinutes = d.MinNoticeMinutes
+	}
+	if req.BufferMinutes == 0 {
+		req.BufferMinutes = d.BufferMinutes
+	}
+	if req.LocationMode == "" {
+		req.LocationMode = d.LocationMode
+		if req.Location == "" {
+			req.Location = d.Location
+		}
+	}
+
+	invitees := append([]string{}, req.Invitees...)
+	for _, id := range workspace.Participants {
+		if id != req.OrganizerID && !containsString(invitees, id) {
+			invitees = append(invitees, id)
+		}
+	}
+	req.Invitees = invitees
+	return req, nil
+}
+
+// workspaceEvents returns the events in a workspace, oldest first
+func (s *Scheduler) workspaceEvents(workspace Workspace) ([]Event, error) {
+	eventList, err := s.store.ListEvents()
+	if err != nil {
+		return nil, err
+	}
+	var inWorkspace []Event
+	for _, event := range eventList {
+		if event.WorkspaceID == workspace.ID && event.OrgID == workspace.OrgID {
+			inWorkspace = append(inWorkspace, event)
+		}
+	}
+	sort.Slice(inWorkspace, func(i, j int) bool { return inWorkspace[i].CreatedAt.Before(inWorkspace[j].CreatedAt) })
+	return inWorkspace, nil
+}
+
+// AgendaItem is a confirmed meeting on a workspace's agenda
+type AgendaItem struct {
+	EventID  string    `json:"eventId"`
+	Title    string    `json:"title"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Location string    `json:"location,omitempty"`
+}
+
+// WorkspaceAgenda lists the workspace's confirmed meetings in time order
+func (s *Scheduler) WorkspaceAgenda(workspace Workspace) ([]AgendaItem, error) {
+	eventList, err := s.workspaceEvents(workspace)
+	if err != nil {
+		return nil, err
+	}
+	agenda := []AgendaItem{}
+	for _, event := range eventList {
+		if event.Status != "finalized" || event.FinalTimeSlotID == "" {
+			continue
+		}
+		slot, err := s.store.GetTimeSlot(event.FinalTimeSlotID)
+		if err != nil {
+			continue
+		}
+		agenda = append(agenda, AgendaItem{
+			EventID:  event.ID,
+			Title:    event.Title,
+			Start:    slot.StartTime,
+			End:      slot.StartTime.Add(event.duration()),
+			Location: event.Location,
+		})
+	}
+	sort.SliceStable(agenda, func(i, j int) bool { return agenda[i].Start.Before(agenda[j].Start) })
+	return agenda, nil
+}
+
+// EventResponseRate is how many of an event's participants have responded
+type EventResponseRate struct {
+	EventID      string  `json:"eventId"`
+	Title        string  `json:"title"`
+	Status       string  `json:"status"`
+	Participants int     `json:"participants"`
+	Responded    int     `json:"responded"`
+	ResponseRate float64 `json:"responseRate"`
+}
+
+// WorkspaceResponseRates rolls up response rates across a workspace's
+// events. ResponseRate is over all of their participants together.
+type WorkspaceResponseRates struct {
+	WorkspaceID  string              `json:"workspaceId"`
+	Events       []EventResponseRate `json:"events"`
+	Participants int                 `json:"participants"`
+	Responded    int                 `json:"responded"`
+	ResponseRate float64             `json:"responseRate"`
+}
+
+// WorkspaceResponseRates counts, for each of the workspace's events, the
+// participants who have answered at least one slot
+func (s *Scheduler) WorkspaceResponseRates(workspace Workspace) (WorkspaceResponseRates, error) {
+	eventList, err := s.workspaceEvents(workspace)
+	if err != nil {
+		return WorkspaceResponseRates{}, err
+	}
+	rates := WorkspaceResponseRates{WorkspaceID: workspace.ID, Events: []EventResponseRate{}}
+	for _, event := range eventList {
+		progress, err := s.Progress(event.ID)
+		if err != nil {
+			return WorkspaceResponseRates{}, err
+		}
+		rate := EventResponseRate{
+			EventID:      event.ID,
+			Title:        event.Title,
+			Status:       event.Status,
+			Participants: len(progress.Participants),
+			Responded:    progress.Complete + progress.Partial,
+		}
+		rate.ResponseRate = percentage(rate.Responded, rate.Participants)
+		rates.Events = append(rates.Events, rate)
+		rates.Participants += rate.Participants
+		rates.Responded += rate.Responded
+	}
+	rates.ResponseRate = percentage(rates.Responded, rates.Participants)
+	return rates, nil
+}
+
+func percentage(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
+
+// Workspace handlers are scoped to the caller's organization; members can
+// see them and organizers manage them
+func listWorkspaces(c *gin.Context) {
+	user, _ := currentUser(c)
+	c.JSON(http.StatusOK, workspaces.List(user.OrgID))
+}
+
+func getWorkspace(c *gin.Context) {
+	user, _ := currentUser(c)
+	workspace, ok := workspaces.Get(user.OrgID, c.Param("workspaceId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+		return
+	}
+	c.JSON(http.StatusOK, workspace)
+}
+
+func createWorkspace(c *gin.Context) {
+	user, _ := currentUser(c)
+	var req WorkspaceRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(user.OrgID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	now := clock.Now()
+	workspace := Workspace{ID: uuid.New().String(), OrgID: user.OrgID, CreatedAt: now}
+	workspace.apply(req, now)
+	workspaces.Save(workspace)
+	c.JSON(http.StatusCreated, workspace)
+}
+
+func updateWorkspace(c *gin.Context) {
+	user, _ := currentUser(c)
+	workspace, ok := workspaces.Get(user.OrgID, c.Param("workspaceId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+		return
+	}
+	var req WorkspaceRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(user.OrgID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	workspace.apply(req, clock.Now())
+	workspaces.Save(workspace)
+	c.JSON(http.StatusOK, workspace)
+}
+
+func deleteWorkspace(c *gin.Context) {
+	user, _ := currentUser(c)
+	if !workspaces.Delete(user.OrgID, c.Param("workspaceId")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func getWorkspaceAgenda(c *gin.Context) {
+	user, _ := currentUser(c)
+	workspace, ok := workspaces.Get(user.OrgID, c.Param("workspaceId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+		return
+	}
+	agenda, err := contextScheduler(c).WorkspaceAgenda(workspace)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, agenda)
+}
+
+func getWorkspaceResponseRates(c *gin.Context) {
+	user, _ := currentUser(c)
+	workspace, ok := workspaces.Get(user.OrgID, c.Param("workspaceId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+		return
+	}
+	rates, err := contextScheduler(c).WorkspaceResponseRates(workspace)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rates)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetWorkspaces(t *testing.T) {
+	previous := workspaces
+	workspaces = newWorkspaceRegistry()
+	t.Cleanup(func() { workspaces = previous })
+}
+
+func TestWorkspaceDefaultsAndRollUps(t *testing.T) {
+	resetDirectory(t)
+	resetWorkspaces(t)
+	scheduler, fake := newTestScheduler(t)
+	users.Save(User{ID: "ada", OrgID: "acme"})
+	users.Save(User{ID: "outsider", OrgID: "globex"})
+	workspaces.Save(Workspace{ID: "launch", OrgID: "acme", Name: "Launch", Participants: []string{"ada", "bob", "cy"},
+		Defaults: WorkspaceDefaults{DurationMinutes: 45, LocationMode: LocationVideo, Location: "https://meet.example/launch"}})
+
+	kickoff, err := scheduler.CreateEvent(CreateEventRequest{Title: "Kickoff", OrganizerID: "ada", WorkspaceID: "launch", Invitees: []string{"dee"}})
+	require.NoError(t, err)
+	assert.Equal(t, 45, kickoff.RequiredDuration)
+	assert.Equal(t, "https://meet.example/launch", kickoff.Location)
+	assert.Equal(t, []string{"dee", "bob", "cy"}, kickoff.Invitees)
+	review, err := scheduler.CreateEvent(CreateEventRequest{Title: "Review", OrganizerID: "ada", WorkspaceID: "launch", RequiredDuration: 30})
+	require.NoError(t, err)
+	assert.Equal(t, 30, review.RequiredDuration)
+	_, err = scheduler.CreateEvent(CreateEventRequest{Title: "Elsewhere", OrganizerID: "outsider", WorkspaceID: "launch"})
+	assert.Error(t, err, "workspaces are scoped to their organization")
+
+	start := fake.Now().Add(24 * time.Hour)
+	slot, err := scheduler.CreateTimeSlot(kickoff.ID, CreateTimeSlotRequest{StartTime: start, EndTime: start.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = scheduler.SubmitAvailability(kickoff.ID, "bob", UserAvailabilityRequest{TimeSlotID: slot.ID, Status: "available"})
+	require.NoError(t, err)
+	_, err = scheduler.FinalizeEvent(kickoff.ID, FinalizeEventRequest{TimeSlotID: slot.ID})
+	require.NoError(t, err)
+
+	workspace, _ := workspaces.Get("acme", "launch")
+	agenda, err := scheduler.WorkspaceAgenda(workspace)
+	require.NoError(t, err)
+	require.Len(t, agenda, 1)
+	assert.Equal(t, AgendaItem{EventID: kickoff.ID, Title: "Kickoff", Start: start, End: start.Add(45 * time.Minute), Location: "https://meet.example/launch"}, agenda[0])
+
+	rates, err := scheduler.WorkspaceResponseRates(workspace)
+	require.NoError(t, err)
+	require.Len(t, rates.Events, 2)
+	byEvent := map[string]EventResponseRate{}
+	for _, rate := range rates.Events {
+		byEvent[rate.EventID] = rate
+	}
+	assert.Equal(t, 3, byEvent[kickoff.ID].Participants)
+	assert.Equal(t, 1, byEvent[kickoff.ID].Responded)
+	assert.Equal(t, 0, byEvent[review.ID].Responded)
+	assert.InDelta(t, 20.0, rates.ResponseRate, 0.01)
+}
@@ -0,0 +1,13 @@
+DROP INDEX user_availability_event_user_idx ON user_availability;
+
+ALTER TABLE user_availability DROP COLUMN details;
+ALTER TABLE time_slots DROP COLUMN details;
+ALTER TABLE events
+    DROP COLUMN details,
+    DROP COLUMN final_time_slot_id,
+    DROP COLUMN org_id;
+
+ALTER TABLE user_availability MODIFY user_id CHAR(36) NOT NULL;
+ALTER TABLE user_availability ADD CONSTRAINT user_availability_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id);
+ALTER TABLE events MODIFY organizer_id CHAR(36) NOT NULL;
+ALTER TABLE events ADD CONSTRAINT events_organizer_id_fkey FOREIGN KEY (organizer_id) REFERENCES users(id);
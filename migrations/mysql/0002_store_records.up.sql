@@ -0,0 +1,16 @@
+-- Users live in the user directory, not this database, and their IDs
+-- needn't be UUIDs
+ALTER TABLE events DROP FOREIGN KEY events_organizer_id_fkey;
+ALTER TABLE events MODIFY organizer_id VARCHAR(255) NOT NULL;
+ALTER TABLE user_availability DROP FOREIGN KEY user_availability_user_id_fkey;
+ALTER TABLE user_availability MODIFY user_id VARCHAR(255) NOT NULL;
+
+-- details holds the full record; the other columns are for querying
+ALTER TABLE events
+    ADD COLUMN org_id VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN final_time_slot_id VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN details JSON NOT NULL DEFAULT ('{}');
+ALTER TABLE time_slots ADD COLUMN details JSON NOT NULL DEFAULT ('{}');
+ALTER TABLE user_availability ADD COLUMN details JSON NOT NULL DEFAULT ('{}');
+
+CREATE INDEX user_availability_event_user_idx ON user_availability (event_id, user_id);
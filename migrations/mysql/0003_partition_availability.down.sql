@@ -0,0 +1,12 @@
+ALTER TABLE user_availability REMOVE PARTITIONING;
+
+ALTER TABLE user_availability
+    DROP INDEX user_availability_user_slot_key,
+    ADD UNIQUE INDEX user_id (user_id, time_slot_id),
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (id),
+    DROP COLUMN archived;
+
+ALTER TABLE user_availability
+    ADD CONSTRAINT user_availability_event_id_fkey FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+    ADD CONSTRAINT user_availability_time_slot_id_fkey FOREIGN KEY (time_slot_id) REFERENCES time_slots(id) ON DELETE CASCADE;
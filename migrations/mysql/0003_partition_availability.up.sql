@@ -0,0 +1,24 @@
+-- Responses are split by event: live events' rows are spread over 16
+-- key subpartitions, so each one's indexes stay small, and finished
+-- events' rows move to an archive partition, which can be optimized,
+-- exchanged out or dumped on its own.
+-- Partitioned tables can't have foreign keys; the server deletes an
+-- event's slots and responses itself.
+ALTER TABLE user_availability
+    DROP FOREIGN KEY user_availability_event_id_fkey,
+    DROP FOREIGN KEY user_availability_time_slot_id_fkey;
+
+-- Unique keys must include the partition keys
+ALTER TABLE user_availability
+    ADD COLUMN archived BOOLEAN NOT NULL DEFAULT FALSE,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (id, event_id, archived),
+    DROP INDEX user_id,
+    ADD UNIQUE INDEX user_availability_user_slot_key (user_id, time_slot_id, event_id, archived);
+
+ALTER TABLE user_availability
+    PARTITION BY LIST (archived)
+    SUBPARTITION BY KEY (event_id) SUBPARTITIONS 16 (
+        PARTITION live VALUES IN (0),
+        PARTITION archived VALUES IN (1)
+    );
@@ -0,0 +1,8 @@
+-- Failed attempts and lockouts on signed links and logins, shared by
+-- every replica
+CREATE TABLE throttles (
+    throttle_key VARCHAR(255) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    window_start DATETIME(6) NOT NULL,
+    locked_until DATETIME(6) NULL
+);
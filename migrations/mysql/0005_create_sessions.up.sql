@@ -0,0 +1,18 @@
+-- Sign-in sessions, shared by every replica so tokens work on any of
+-- them and outlive restarts. Refresh tokens are kept as SHA-256 hashes.
+CREATE TABLE sessions (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    user_agent TEXT NOT NULL,
+    client_ip VARCHAR(64) NOT NULL DEFAULT '',
+    impersonator_id VARCHAR(255) NOT NULL DEFAULT '',
+    two_factor_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    refresh_hash CHAR(64) NOT NULL DEFAULT '',
+    previous_hash CHAR(64) NOT NULL DEFAULT '',
+    created_at DATETIME(6) NOT NULL,
+    last_used_at DATETIME(6) NOT NULL,
+    expires_at DATETIME(6) NOT NULL
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+CREATE INDEX sessions_expires_at_idx ON sessions (expires_at);
@@ -0,0 +1,10 @@
+-- TOTP enrollments, one per user. Secrets are sealed by the application;
+-- last_counter is the time step of the last code accepted, so a code
+-- can't be replayed on another replica.
+CREATE TABLE two_factors (
+    user_id VARCHAR(255) PRIMARY KEY,
+    secret TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    enabled_at DATETIME(6) NULL,
+    last_counter BIGINT NOT NULL DEFAULT 0
+);
@@ -0,0 +1,19 @@
+-- The impersonation audit log, shared by every replica so operators'
+-- sessions are accounted for wherever they ran and outlive restarts. The
+-- ID orders the log and pages through it.
+CREATE TABLE impersonations (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    session_id VARCHAR(36) NOT NULL,
+    impersonator_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    reason TEXT NOT NULL,
+    client_ip VARCHAR(64) NOT NULL DEFAULT '',
+    method VARCHAR(16) NOT NULL DEFAULT '',
+    path TEXT NOT NULL,
+    status INT NOT NULL DEFAULT 0,
+    created_at DATETIME(6) NOT NULL
+);
+
+CREATE INDEX impersonations_session_id_idx ON impersonations (session_id, id);
+CREATE INDEX impersonations_created_at_idx ON impersonations (created_at);
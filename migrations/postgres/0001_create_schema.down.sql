@@ -0,0 +1,4 @@
+DROP TABLE user_availability;
+DROP TABLE time_slots;
+DROP TABLE events;
+DROP TABLE users;
@@ -0,0 +1,14 @@
+DROP INDEX user_availability_event_user_idx;
+DROP INDEX time_slots_event_id_idx;
+
+ALTER TABLE user_availability DROP COLUMN details;
+ALTER TABLE time_slots DROP COLUMN details;
+ALTER TABLE events
+    DROP COLUMN details,
+    DROP COLUMN final_time_slot_id,
+    DROP COLUMN org_id;
+
+ALTER TABLE user_availability ALTER COLUMN user_id TYPE UUID USING user_id::uuid;
+ALTER TABLE user_availability ADD CONSTRAINT user_availability_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id);
+ALTER TABLE events ALTER COLUMN organizer_id TYPE UUID USING organizer_id::uuid;
+ALTER TABLE events ADD CONSTRAINT events_organizer_id_fkey FOREIGN KEY (organizer_id) REFERENCES users(id);
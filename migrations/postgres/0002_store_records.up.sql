@@ -0,0 +1,17 @@
+-- Users live in the user directory, not this database, and their IDs
+-- needn't be UUIDs
+ALTER TABLE events DROP CONSTRAINT events_organizer_id_fkey;
+ALTER TABLE events ALTER COLUMN organizer_id TYPE VARCHAR(255);
+ALTER TABLE user_availability DROP CONSTRAINT user_availability_user_id_fkey;
+ALTER TABLE user_availability ALTER COLUMN user_id TYPE VARCHAR(255);
+
+-- details holds the full record; the other columns are for querying
+ALTER TABLE events
+    ADD COLUMN org_id VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN final_time_slot_id VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN details JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE time_slots ADD COLUMN details JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE user_availability ADD COLUMN details JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX time_slots_event_id_idx ON time_slots (event_id);
+CREATE INDEX user_availability_event_user_idx ON user_availability (event_id, user_id);
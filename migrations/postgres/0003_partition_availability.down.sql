@@ -0,0 +1,23 @@
+CREATE TABLE user_availability_unpartitioned (
+    id UUID PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    time_slot_id UUID NOT NULL REFERENCES time_slots(id) ON DELETE CASCADE,
+    status VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    details JSONB NOT NULL DEFAULT '{}',
+    UNIQUE (user_id, time_slot_id)
+);
+
+INSERT INTO user_availability_unpartitioned (id, user_id, event_id, time_slot_id, status, details, created_at, updated_at)
+    SELECT id, user_id, event_id, time_slot_id, status, details, created_at, updated_at
+    FROM user_availability;
+DROP TABLE user_availability;
+
+ALTER TABLE user_availability_unpartitioned RENAME TO user_availability;
+ALTER INDEX user_availability_unpartitioned_pkey RENAME TO user_availability_pkey;
+ALTER INDEX user_availability_unpartitioned_user_id_time_slot_id_key RENAME TO user_availability_user_id_time_slot_id_key;
+ALTER TABLE user_availability RENAME CONSTRAINT user_availability_unpartitioned_event_id_fkey TO user_availability_event_id_fkey;
+ALTER TABLE user_availability RENAME CONSTRAINT user_availability_unpartitioned_time_slot_id_fkey TO user_availability_time_slot_id_fkey;
+CREATE INDEX user_availability_event_user_idx ON user_availability (event_id, user_id);
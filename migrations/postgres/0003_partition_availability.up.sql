@@ -0,0 +1,40 @@
+-- Responses are split by event: live events' rows are spread over 16
+-- hash partitions, so each partition's indexes stay small, and finished
+-- events' rows move to an archive partition, which can be vacuumed,
+-- detached or dumped on its own
+ALTER TABLE user_availability RENAME TO user_availability_unpartitioned;
+ALTER INDEX user_availability_pkey RENAME TO user_availability_unpartitioned_pkey;
+ALTER INDEX user_availability_user_id_time_slot_id_key RENAME TO user_availability_unpartitioned_user_id_time_slot_id_key;
+DROP INDEX user_availability_event_user_idx;
+
+-- Unique keys must include the partition keys
+CREATE TABLE user_availability (
+    id UUID NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    time_slot_id UUID NOT NULL REFERENCES time_slots(id) ON DELETE CASCADE,
+    status VARCHAR(50) NOT NULL,
+    details JSONB NOT NULL DEFAULT '{}',
+    archived BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (id, archived, event_id),
+    UNIQUE (user_id, time_slot_id, archived, event_id)
+) PARTITION BY LIST (archived);
+
+CREATE TABLE user_availability_live PARTITION OF user_availability
+    FOR VALUES IN (FALSE) PARTITION BY HASH (event_id);
+DO $$
+BEGIN
+    FOR i IN 0..15 LOOP
+        EXECUTE format('CREATE TABLE user_availability_live_%s PARTITION OF user_availability_live FOR VALUES WITH (MODULUS 16, REMAINDER %s)', i, i);
+    END LOOP;
+END $$;
+CREATE TABLE user_availability_archived PARTITION OF user_availability FOR VALUES IN (TRUE);
+
+CREATE INDEX user_availability_event_user_idx ON user_availability (event_id, user_id);
+
+INSERT INTO user_availability (id, user_id, event_id, time_slot_id, status, details, created_at, updated_at)
+    SELECT id, user_id, event_id, time_slot_id, status, details, created_at, updated_at
+    FROM user_availability_unpartitioned;
+DROP TABLE user_availability_unpartitioned;
@@ -0,0 +1,20 @@
+-- Sign-in sessions, shared by every replica so tokens work on any of
+-- them and outlive restarts. Refresh tokens are kept as SHA-256 hashes.
+-- IDs arrive in refresh tokens from clients, so they are text rather than
+-- UUID and a mangled one simply matches nothing.
+CREATE TABLE sessions (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    client_ip VARCHAR(64) NOT NULL DEFAULT '',
+    impersonator_id VARCHAR(255) NOT NULL DEFAULT '',
+    two_factor_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    refresh_hash CHAR(64) NOT NULL DEFAULT '',
+    previous_hash CHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+CREATE INDEX sessions_expires_at_idx ON sessions (expires_at);
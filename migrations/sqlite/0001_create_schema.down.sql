@@ -0,0 +1,3 @@
+DROP TABLE user_availability;
+DROP TABLE time_slots;
+DROP TABLE events;
@@ -0,0 +1,44 @@
+-- The same tables as the PostgreSQL schema in its current form. Users
+-- live in the user directory, not this database. SQLite has no
+-- partitioning, so archived responses stay in the one table, flagged.
+CREATE TABLE events (
+    id TEXT PRIMARY KEY,
+    title TEXT NOT NULL,
+    description TEXT,
+    organizer_id TEXT NOT NULL,
+    org_id TEXT NOT NULL DEFAULT '',
+    required_duration INTEGER NOT NULL, -- in minutes
+    status TEXT NOT NULL,
+    final_time_slot_id TEXT NOT NULL DEFAULT '',
+    -- details holds the full record; the other columns are for querying
+    details TEXT NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE time_slots (
+    id TEXT PRIMARY KEY,
+    event_id TEXT NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL,
+    details TEXT NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX time_slots_event_id_idx ON time_slots (event_id);
+
+CREATE TABLE user_availability (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    event_id TEXT NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    time_slot_id TEXT NOT NULL REFERENCES time_slots(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    details TEXT NOT NULL DEFAULT '{}',
+    archived BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    UNIQUE (user_id, time_slot_id)
+);
+
+CREATE INDEX user_availability_event_user_idx ON user_availability (event_id, user_id);
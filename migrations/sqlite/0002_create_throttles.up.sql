@@ -0,0 +1,7 @@
+-- Failed attempts and lockouts on signed links and logins
+CREATE TABLE throttles (
+    throttle_key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    window_start TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP
+);
@@ -0,0 +1,18 @@
+-- Sign-in sessions, so tokens outlive restarts. Refresh tokens are kept
+-- as SHA-256 hashes.
+CREATE TABLE sessions (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    client_ip TEXT NOT NULL DEFAULT '',
+    impersonator_id TEXT NOT NULL DEFAULT '',
+    two_factor_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    refresh_hash TEXT NOT NULL DEFAULT '',
+    previous_hash TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+CREATE INDEX sessions_expires_at_idx ON sessions (expires_at);
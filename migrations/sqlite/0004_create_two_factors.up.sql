@@ -0,0 +1,9 @@
+-- TOTP enrollments, one per user. Secrets are sealed by the application;
+-- last_counter is the time step of the last code accepted.
+CREATE TABLE two_factors (
+    user_id TEXT PRIMARY KEY,
+    secret TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    enabled_at TIMESTAMP,
+    last_counter INTEGER NOT NULL DEFAULT 0
+);
@@ -0,0 +1,17 @@
+-- The impersonation audit log. The ID orders the log and pages through it.
+CREATE TABLE impersonations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL,
+    impersonator_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    action TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    client_ip TEXT NOT NULL DEFAULT '',
+    method TEXT NOT NULL DEFAULT '',
+    path TEXT NOT NULL DEFAULT '',
+    status INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX impersonations_session_id_idx ON impersonations (session_id, id);
+CREATE INDEX impersonations_created_at_idx ON impersonations (created_at);